@@ -21,6 +21,9 @@ class Extension extends Model
         'max_contacts',
         'direct_media',
         'qualify_frequency',
+        'profile',
+        'media_encryption',
+        'nat',
         'caller_id',
         'voicemail_enabled',
         'notes',
@@ -29,6 +32,7 @@ class Extension extends Model
     protected $casts = [
         'enabled' => 'boolean',
         'voicemail_enabled' => 'boolean',
+        'nat' => 'boolean',
         'codecs' => 'array',
         'qualify_frequency' => 'integer',
     ];
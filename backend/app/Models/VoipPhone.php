@@ -19,6 +19,8 @@ class VoipPhone extends Model
         'mac',
         'extension',
         'name',
+        'location',
+        'notes',
         'vendor',
         'model',
         'firmware',
@@ -0,0 +1,44 @@
+<?php
+
+use Illuminate\Database\Migrations\Migration;
+use Illuminate\Database\Schema\Blueprint;
+use Illuminate\Support\Facades\Schema;
+
+return new class extends Migration
+{
+    /**
+     * Extension profiles are named sets of endpoint settings (e.g. desk-phone,
+     * softphone-webrtc). They are written to pjsip.conf as [name](!) templates
+     * and extensions referencing a profile inherit every field they don't override.
+     */
+    public function up(): void
+    {
+        Schema::create('extension_profiles', function (Blueprint $table) {
+            $table->id();
+            $table->string('name', 40)->unique();
+            $table->string('description')->nullable()->charset('utf8mb4')->collation('utf8mb4_unicode_ci');
+            $table->string('context')->default('from-internal');
+            $table->string('transport')->default('transport-udp');
+            $table->json('codecs')->nullable();
+            $table->string('direct_media', 10)->default('no');
+            $table->integer('max_contacts')->default(1);
+            $table->integer('qualify_frequency')->default(60);
+            $table->timestamps();
+        });
+
+        Schema::table('extensions', function (Blueprint $table) {
+            $table->string('profile', 40)->nullable()->after('qualify_frequency');
+            $table->index('profile');
+        });
+    }
+
+    public function down(): void
+    {
+        Schema::table('extensions', function (Blueprint $table) {
+            $table->dropIndex(['profile']);
+            $table->dropColumn('profile');
+        });
+
+        Schema::dropIfExists('extension_profiles');
+    }
+};
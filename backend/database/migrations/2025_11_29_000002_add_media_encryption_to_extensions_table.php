@@ -0,0 +1,26 @@
+<?php
+
+use Illuminate\Database\Migrations\Migration;
+use Illuminate\Database\Schema\Blueprint;
+use Illuminate\Support\Facades\Schema;
+
+return new class extends Migration
+{
+    /**
+     * Per-extension SRTP mode written to pjsip.conf as media_encryption:
+     * no, sdes (keys in SDP, use with SIP over TLS) or dtls.
+     */
+    public function up(): void
+    {
+        Schema::table('extensions', function (Blueprint $table) {
+            $table->string('media_encryption', 10)->default('no')->after('profile');
+        });
+    }
+
+    public function down(): void
+    {
+        Schema::table('extensions', function (Blueprint $table) {
+            $table->dropColumn('media_encryption');
+        });
+    }
+};
@@ -0,0 +1,29 @@
+<?php
+
+use Illuminate\Database\Migrations\Migration;
+use Illuminate\Database\Schema\Blueprint;
+use Illuminate\Support\Facades\DB;
+use Illuminate\Support\Facades\Schema;
+
+return new class extends Migration
+{
+    /**
+     * Profiles for phones behind NAT (e.g. remote-worker-nat) get rewrite_contact,
+     * force_rport and rtp_symmetric in their pjsip.conf template.
+     */
+    public function up(): void
+    {
+        Schema::table('extension_profiles', function (Blueprint $table) {
+            $table->boolean('nat')->default(false)->after('qualify_frequency');
+        });
+
+        DB::table('extension_profiles')->where('name', 'remote-worker-nat')->update(['nat' => true]);
+    }
+
+    public function down(): void
+    {
+        Schema::table('extension_profiles', function (Blueprint $table) {
+            $table->dropColumn('nat');
+        });
+    }
+};
@@ -0,0 +1,31 @@
+<?php
+
+use Illuminate\Database\Migrations\Migration;
+use Illuminate\Database\Schema\Blueprint;
+use Illuminate\Support\Facades\DB;
+use Illuminate\Support\Facades\Schema;
+
+return new class extends Migration
+{
+    /**
+     * Per-extension NAT traversal (rewrite_contact, force_rport, rtp_symmetric).
+     * Members of a NAT profile start with the profile's value so nothing is reported as overridden.
+     */
+    public function up(): void
+    {
+        Schema::table('extensions', function (Blueprint $table) {
+            $table->boolean('nat')->default(false)->after('media_encryption');
+        });
+
+        DB::table('extensions')
+            ->whereIn('profile', DB::table('extension_profiles')->where('nat', true)->select('name'))
+            ->update(['nat' => true]);
+    }
+
+    public function down(): void
+    {
+        Schema::table('extensions', function (Blueprint $table) {
+            $table->dropColumn('nat');
+        });
+    }
+};
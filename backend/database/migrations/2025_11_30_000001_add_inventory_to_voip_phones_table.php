@@ -0,0 +1,56 @@
+<?php
+
+use Illuminate\Database\Migrations\Migration;
+use Illuminate\Database\Schema\Blueprint;
+use Illuminate\Support\Facades\Schema;
+
+return new class extends Migration
+{
+    /**
+     * Turns voip_phones into a device inventory keyed by MAC address. The IP
+     * becomes nullable so a phone that moved can release its old address,
+     * every address a phone was seen at is kept in voip_phone_ip_history, and
+     * extensions are assigned per line key in voip_phone_lines for provisioning.
+     */
+    public function up(): void
+    {
+        Schema::table('voip_phones', function (Blueprint $table) {
+            $table->string('ip', 45)->nullable()->change();
+            $table->string('location', 100)->nullable()->after('name');
+            $table->text('notes')->nullable()->after('location');
+        });
+
+        Schema::create('voip_phone_lines', function (Blueprint $table) {
+            $table->id();
+            $table->foreignId('voip_phone_id')->constrained('voip_phones')->onDelete('cascade');
+            $table->unsignedTinyInteger('line');
+            $table->string('extension', 32);
+            $table->timestamps();
+
+            $table->unique(['voip_phone_id', 'line']);
+            $table->index('extension');
+        });
+
+        Schema::create('voip_phone_ip_history', function (Blueprint $table) {
+            $table->id();
+            $table->foreignId('voip_phone_id')->constrained('voip_phones')->onDelete('cascade');
+            $table->string('ip', 45);
+            $table->string('source', 20)->nullable(); // lldp, arp, nmap, http, manual, sip
+            $table->timestamp('first_seen')->nullable();
+            $table->timestamp('last_seen')->nullable();
+
+            $table->unique(['voip_phone_id', 'ip']);
+        });
+    }
+
+    public function down(): void
+    {
+        Schema::dropIfExists('voip_phone_ip_history');
+        Schema::dropIfExists('voip_phone_lines');
+
+        Schema::table('voip_phones', function (Blueprint $table) {
+            $table->dropColumn(['location', 'notes']);
+            $table->string('ip', 45)->nullable(false)->change();
+        });
+    }
+};
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultAMIPort is the default Asterisk Manager Interface port
+const DefaultAMIPort = "5038"
+
+// AMIMessage is a single AMI response or event as key/value pairs
+type AMIMessage map[string]string
+
+// Event returns the event name, or an empty string for responses
+func (msg AMIMessage) Event() string {
+	return msg["Event"]
+}
+
+// AMIClient is a minimal Asterisk Manager Interface client used to follow events
+type AMIClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	mu     sync.Mutex
+}
+
+// DialAMI connects to the Asterisk Manager Interface and logs in
+func DialAMI(host, port, username, secret string, timeout time.Duration) (*AMIClient, error) {
+	if port == "" {
+		port = DefaultAMIPort
+	}
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, port), timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to AMI: %v", err)
+	}
+	client := &AMIClient{conn: conn, reader: bufio.NewReader(conn)}
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	banner, err := client.reader.ReadString('\n')
+	if err != nil || !strings.HasPrefix(banner, "Asterisk Call Manager") {
+		conn.Close()
+		return nil, fmt.Errorf("failed to read AMI banner: unexpected %q", strings.TrimSpace(banner))
+	}
+
+	if err := client.Send("Login", "Username", username, "Secret", secret, "Events", "on"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// Events may arrive before the login response
+	for {
+		msg, err := client.ReadMessage()
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if response, ok := msg["Response"]; ok {
+			if !strings.EqualFold(response, "Success") {
+				conn.Close()
+				return nil, fmt.Errorf("AMI login failed: %s", msg["Message"])
+			}
+			break
+		}
+	}
+	conn.SetDeadline(time.Time{})
+	return client, nil
+}
+
+// Send sends an action with alternating header names and values
+func (c *AMIClient) Send(action string, headers ...string) error {
+	var b strings.Builder
+	b.WriteString("Action: " + action + "\r\n")
+	for i := 0; i+1 < len(headers); i += 2 {
+		b.WriteString(headers[i] + ": " + headers[i+1] + "\r\n")
+	}
+	b.WriteString("\r\n")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.conn.Write([]byte(b.String())); err != nil {
+		return fmt.Errorf("failed to send AMI action %s: %v", action, err)
+	}
+	return nil
+}
+
+// Action sends an action and waits for its response, skipping events in between
+func (c *AMIClient) Action(action string, headers ...string) (AMIMessage, error) {
+	id := fmt.Sprintf("rayanpbx-%d", time.Now().UnixNano())
+	if err := c.Send(action, append([]string{"ActionID", id}, headers...)...); err != nil {
+		return nil, err
+	}
+	c.conn.SetReadDeadline(time.Now().Add(amiDialTimeout))
+	defer c.conn.SetReadDeadline(time.Time{})
+	for {
+		msg, err := c.ReadMessage()
+		if err != nil {
+			return nil, err
+		}
+		response, ok := msg["Response"]
+		if !ok || (msg["ActionID"] != "" && msg["ActionID"] != id) {
+			continue
+		}
+		if !strings.EqualFold(response, "Success") {
+			return msg, fmt.Errorf("AMI %s failed: %s", action, msg["Message"])
+		}
+		return msg, nil
+	}
+}
+
+// ReadMessage reads the next response or event
+func (c *AMIClient) ReadMessage() (AMIMessage, error) {
+	return readAMIMessage(c.reader)
+}
+
+// Close logs off and closes the connection
+func (c *AMIClient) Close() error {
+	c.Send("Logoff")
+	return c.conn.Close()
+}
+
+// readAMIMessage reads key/value lines up to the blank line ending a message
+func readAMIMessage(r *bufio.Reader) (AMIMessage, error) {
+	msg := AMIMessage{}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("failed to read AMI message: %v", err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			if len(msg) == 0 {
+				continue
+			}
+			return msg, nil
+		}
+		if idx := strings.Index(line, ":"); idx > 0 {
+			msg[strings.TrimSpace(line[:idx])] = strings.TrimSpace(line[idx+1:])
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// appEncryptedPayload is the JSON envelope written by Laravel's Crypt::encryptString
+type appEncryptedPayload struct {
+	IV    string `json:"iv"`
+	Value string `json:"value"`
+	MAC   string `json:"mac"`
+	Tag   string `json:"tag"`
+}
+
+// appEncryptionKey returns the Laravel APP_KEY used to encrypt secrets shared with the backend
+func appEncryptionKey() ([]byte, error) {
+	return parseAppKey(getEnv("APP_KEY", ""))
+}
+
+// parseAppKey decodes an APP_KEY, which is either "base64:<key>" or the raw key
+func parseAppKey(appKey string) ([]byte, error) {
+	if appKey == "" {
+		return nil, fmt.Errorf("APP_KEY is not set")
+	}
+	key := []byte(appKey)
+	if strings.HasPrefix(appKey, "base64:") {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(appKey, "base64:"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid APP_KEY: %v", err)
+		}
+		key = decoded
+	}
+	if len(key) != 16 && len(key) != 32 {
+		return nil, fmt.Errorf("invalid APP_KEY: expected a 16 or 32 byte key, got %d bytes", len(key))
+	}
+	return key, nil
+}
+
+// EncryptAppString encrypts a value the same way as Laravel's Crypt::encryptString (AES-CBC with an HMAC)
+func EncryptAppString(key []byte, plain string) (string, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to create cipher: %v", err)
+	}
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(iv); err != nil {
+		return "", fmt.Errorf("failed to generate IV: %v", err)
+	}
+
+	padding := aes.BlockSize - len(plain)%aes.BlockSize
+	data := append([]byte(plain), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, data)
+
+	payload := appEncryptedPayload{
+		IV:    base64.StdEncoding.EncodeToString(iv),
+		Value: base64.StdEncoding.EncodeToString(data),
+	}
+	payload.MAC = appPayloadMAC(key, payload)
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode payload: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(encoded), nil
+}
+
+// DecryptAppString decrypts a value written by Laravel's Crypt::encryptString or EncryptAppString
+func DecryptAppString(key []byte, encrypted string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted payload: %v", err)
+	}
+	var payload appEncryptedPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return "", fmt.Errorf("invalid encrypted payload: %v", err)
+	}
+	if !hmac.Equal([]byte(payload.MAC), []byte(appPayloadMAC(key, payload))) {
+		return "", fmt.Errorf("the MAC is invalid")
+	}
+
+	iv, err := base64.StdEncoding.DecodeString(payload.IV)
+	if err != nil || len(iv) != aes.BlockSize {
+		return "", fmt.Errorf("invalid IV")
+	}
+	data, err := base64.StdEncoding.DecodeString(payload.Value)
+	if err != nil || len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return "", fmt.Errorf("invalid encrypted value")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to create cipher: %v", err)
+	}
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(data, data)
+
+	padding := int(data[len(data)-1])
+	if padding == 0 || padding > aes.BlockSize || padding > len(data) {
+		return "", fmt.Errorf("could not decrypt the data")
+	}
+	return string(data[:len(data)-padding]), nil
+}
+
+// appPayloadMAC returns the hex HMAC-SHA256 Laravel computes over the IV and value
+func appPayloadMAC(key []byte, payload appEncryptedPayload) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload.IV + payload.Value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// testLaravelPayload is Crypt::encryptString('{"username":"admin","password":"s3cret"}')
+// with APP_KEY "0123456789abcdef0123456789abcdef" and a fixed IV, built with openssl
+const testLaravelPayload = "eyJpdiI6IlptVmtZMkpoT1RnM05qVTBNekl4TUE9PSIsInZhbHVlIjoibm1hdzlHVzRCMkpyOXZtaDFkTm51VGJmVmc4c2ZyeFU4QUZ3MWZIZjYyMEdGZmxyczI3Unhac2I5eldhakZpayIsIm1hYyI6Ijc2MjBmNDNhMjY5MDlmZTA2Yzg1ZWI3OTBhOTRiODhkNTQ5MTNhZGNhNWNiZjdlN2RkMTliYjM5Zjk3ZTgxZTQiLCJ0YWciOiIifQ=="
+
+func TestAppCrypt(t *testing.T) {
+	key, err := parseAppKey("base64:" + base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef")))
+	if err != nil {
+		t.Fatalf("parseAppKey: %v", err)
+	}
+
+	plain, err := DecryptAppString(key, testLaravelPayload)
+	if err != nil || plain != `{"username":"admin","password":"s3cret"}` {
+		t.Fatalf("expected the Laravel payload to decrypt, got %q, %v", plain, err)
+	}
+
+	for _, value := range []string{"", "p", "exactly16bytes!!", strings.Repeat("x", 100)} {
+		encrypted, err := EncryptAppString(key, value)
+		if err != nil {
+			t.Fatalf("EncryptAppString(%q): %v", value, err)
+		}
+		if decrypted, err := DecryptAppString(key, encrypted); err != nil || decrypted != value {
+			t.Errorf("round trip of %q gave %q, %v", value, decrypted, err)
+		}
+	}
+
+	encrypted, _ := EncryptAppString(key, "secret")
+	otherKey, _ := parseAppKey("fedcba9876543210fedcba9876543210")
+	if _, err := DecryptAppString(otherKey, encrypted); err == nil {
+		t.Error("expected decrypting with another key to fail")
+	}
+	raw, _ := base64.StdEncoding.DecodeString(encrypted)
+	tampered := base64.StdEncoding.EncodeToString([]byte(strings.Replace(string(raw), `"value":"`, `"value":"A`, 1)))
+	if _, err := DecryptAppString(key, tampered); err == nil || !strings.Contains(err.Error(), "MAC") {
+		t.Errorf("expected a tampered value to fail the MAC check, got %v", err)
+	}
+
+	for _, appKey := range []string{"", "short", "base64:!!!", "base64:" + base64.StdEncoding.EncodeToString([]byte("24 byte key for aes-192"))} {
+		if _, err := parseAppKey(appKey); err == nil {
+			t.Errorf("expected parseAppKey(%q) to fail", appKey)
+		}
+	}
+}
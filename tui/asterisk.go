@@ -16,6 +16,7 @@ import (
 // AsteriskManager handles Asterisk service and CLI operations
 type AsteriskManager struct {
 	logger *SystemLogger
+	remote *SSHTarget // Run commands on a remote server over SSH, nil for the local host
 }
 
 // NewAsteriskManager creates a new Asterisk manager
@@ -25,9 +26,24 @@ func NewAsteriskManager() *AsteriskManager {
 	}
 }
 
+// NewRemoteAsteriskManager creates an Asterisk manager that runs its commands over SSH
+func NewRemoteAsteriskManager(target *SSHTarget) *AsteriskManager {
+	am := NewAsteriskManager()
+	am.remote = target
+	return am
+}
+
+// command builds a command that runs locally or, for remote servers, over SSH
+func (am *AsteriskManager) command(name string, args ...string) *exec.Cmd {
+	if am.remote != nil {
+		return am.remote.Command(name, args...)
+	}
+	return exec.Command(name, args...)
+}
+
 // GetServiceStatus checks Asterisk service status via systemctl
 func (am *AsteriskManager) GetServiceStatus() (string, error) {
-	cmd := exec.Command("systemctl", "status", "asterisk")
+	cmd := am.command("systemctl", "status", "asterisk")
 	output, err := cmd.CombinedOutput()
 
 	if err != nil {
@@ -53,7 +69,7 @@ func (am *AsteriskManager) StartService() error {
 	cyan := color.New(color.FgCyan)
 
 	cyan.Println("🔄 Starting Asterisk service...")
-	cmd := exec.Command("systemctl", "start", "asterisk")
+	cmd := am.command("systemctl", "start", "asterisk")
 	if err := cmd.Run(); err != nil {
 		am.logger.AsteriskError("Failed to start Asterisk service: %v", err)
 		return fmt.Errorf("failed to start service: %v", err)
@@ -70,7 +86,7 @@ func (am *AsteriskManager) StopService() error {
 	green := color.New(color.FgGreen)
 
 	yellow.Println("⏸️  Stopping Asterisk service...")
-	cmd := exec.Command("systemctl", "stop", "asterisk")
+	cmd := am.command("systemctl", "stop", "asterisk")
 	if err := cmd.Run(); err != nil {
 		am.logger.AsteriskError("Failed to stop Asterisk service: %v", err)
 		return fmt.Errorf("failed to stop service: %v", err)
@@ -87,7 +103,7 @@ func (am *AsteriskManager) RestartService() error {
 	green := color.New(color.FgGreen)
 
 	cyan.Println("🔄 Restarting Asterisk service...")
-	cmd := exec.Command("systemctl", "restart", "asterisk")
+	cmd := am.command("systemctl", "restart", "asterisk")
 	if err := cmd.Run(); err != nil {
 		am.logger.AsteriskError("Failed to restart Asterisk service: %v", err)
 		return fmt.Errorf("failed to restart service: %v", err)
@@ -100,7 +116,7 @@ func (am *AsteriskManager) RestartService() error {
 
 // ExecuteCLICommand executes an Asterisk CLI command
 func (am *AsteriskManager) ExecuteCLICommand(command string) (string, error) {
-	cmd := exec.Command("asterisk", "-rx", command)
+	cmd := am.command("asterisk", "-rx", command)
 	output, err := cmd.CombinedOutput()
 
 	if err != nil {
@@ -439,7 +455,7 @@ func (am *AsteriskManager) GetServiceStatusOutput() string {
 // StartServiceQuiet starts the Asterisk service without printing to stdout (for TUI use)
 // Returns any command output and an error if the operation failed
 func (am *AsteriskManager) StartServiceQuiet() (string, error) {
-	cmd := exec.Command("systemctl", "start", "asterisk")
+	cmd := am.command("systemctl", "start", "asterisk")
 	output, err := cmd.CombinedOutput()
 	outputStr := strings.TrimSpace(string(output))
 	if err != nil {
@@ -454,7 +470,7 @@ func (am *AsteriskManager) StartServiceQuiet() (string, error) {
 // StopServiceQuiet stops the Asterisk service without printing to stdout (for TUI use)
 // Returns any command output and an error if the operation failed
 func (am *AsteriskManager) StopServiceQuiet() (string, error) {
-	cmd := exec.Command("systemctl", "stop", "asterisk")
+	cmd := am.command("systemctl", "stop", "asterisk")
 	output, err := cmd.CombinedOutput()
 	outputStr := strings.TrimSpace(string(output))
 	if err != nil {
@@ -469,7 +485,7 @@ func (am *AsteriskManager) StopServiceQuiet() (string, error) {
 // RestartServiceQuiet restarts the Asterisk service without printing to stdout (for TUI use)
 // Returns any command output and an error if the operation failed
 func (am *AsteriskManager) RestartServiceQuiet() (string, error) {
-	cmd := exec.Command("systemctl", "restart", "asterisk")
+	cmd := am.command("systemctl", "restart", "asterisk")
 	output, err := cmd.CombinedOutput()
 	outputStr := strings.TrimSpace(string(output))
 	if err != nil {
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"strings"
@@ -12,18 +13,67 @@ import (
 
 // AsteriskConfigManager handles Asterisk configuration file management
 type AsteriskConfigManager struct {
-	pjsipConfigPath string
-	verbose         bool
+	pjsipConfigPath      string
+	extensionsConfigPath string
+	httpConfigPath       string
+	rtpConfigPath        string
+	tlsKeysDir           string // Where generated and imported TLS certificates are stored
+	verbose              bool
+	profiles             map[string]ExtensionProfile // Extension profiles by name, see SetExtensionProfiles
+	remote               *SSHTarget                  // Remote server whose config files are mirrored locally, see UseRemote
+	remoteFiles          map[string]string           // Local mirror path -> remote path
+	remoteSynced         map[string]string           // Local mirror path -> content last read from or written to the server
+	out                  io.Writer                   // Progress, warning and error messages, see SetOutput
 }
 
 // NewAsteriskConfigManager creates a new config manager
 func NewAsteriskConfigManager(verbose bool) *AsteriskConfigManager {
 	return &AsteriskConfigManager{
-		pjsipConfigPath: "/etc/asterisk/pjsip.conf",
-		verbose:         verbose,
+		pjsipConfigPath:      "/etc/asterisk/pjsip.conf",
+		extensionsConfigPath: "/etc/asterisk/extensions.conf",
+		httpConfigPath:       "/etc/asterisk/http.conf",
+		rtpConfigPath:        "/etc/asterisk/rtp.conf",
+		tlsKeysDir:           defaultTLSKeysDir,
+		verbose:              verbose,
+		out:                  os.Stdout,
 	}
 }
 
+// SetOutput sends the manager's messages to w instead of stdout
+func (acm *AsteriskConfigManager) SetOutput(w io.Writer) {
+	acm.out = w
+}
+
+// loadConfigOrNew parses an Asterisk config file, or returns an empty config with
+// the given header lines when the file does not exist yet
+func (acm *AsteriskConfigManager) loadConfigOrNew(path string, header []string) (*AsteriskConfig, error) {
+	if _, statErr := os.Stat(path); os.IsNotExist(statErr) {
+		yellow := color.New(color.FgYellow)
+		yellow.Fprintf(acm.out, "⚠️  Config file not found, creating: %s\n", path)
+		return &AsteriskConfig{
+			HeaderLines: header,
+			Sections:    []*AsteriskSection{},
+			FilePath:    path,
+		}, nil
+	}
+
+	config, err := ParseAsteriskConfig(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+	return config, nil
+}
+
+// LoadPjsipConfig loads pjsip.conf, creating an empty RayanPBX config if it is missing
+func (acm *AsteriskConfigManager) LoadPjsipConfig() (*AsteriskConfig, error) {
+	return acm.loadConfigOrNew(acm.pjsipConfigPath, []string{"; RayanPBX PJSIP Configuration", "; Generated by RayanPBX TUI", ""})
+}
+
+// LoadDialplanConfig loads extensions.conf, creating an empty RayanPBX dialplan if it is missing
+func (acm *AsteriskConfigManager) LoadDialplanConfig() (*AsteriskConfig, error) {
+	return acm.loadConfigOrNew(acm.extensionsConfigPath, []string{"; RayanPBX Dialplan Configuration", "; Generated by RayanPBX TUI", ""})
+}
+
 // GeneratePjsipEndpoint generates PJSIP configuration for an extension
 // Uses best-practice defaults from "Config #2" style while allowing customization
 // Returns the sections that should be added to the config
@@ -41,7 +91,7 @@ func (acm *AsteriskConfigManager) GeneratePjsipEndpoint(ext Extension) []*Asteri
 	}
 
 	// Create sections using the helper function
-	return CreatePjsipEndpointSections(
+	sections := CreatePjsipEndpointSections(
 		ext.ExtensionNumber,
 		ext.Secret,
 		ext.Context,
@@ -53,6 +103,57 @@ func (acm *AsteriskConfigManager) GeneratePjsipEndpoint(ext Extension) []*Asteri
 		ext.QualifyFrequency,
 		ext.VoicemailEnabled,
 	)
+
+	// Browser softphones need DTLS-SRTP, ICE and AVPF on top of the regular options
+	if isWebRTCTransport(ext.Transport) {
+		certFile, keyFile := acm.webRTCCertificate()
+		ApplyWebRTCEndpointOptions(sections[0], certFile, keyFile)
+	} else if ext.MediaEncryption == "sdes" || ext.MediaEncryption == "dtls" {
+		certFile, keyFile := acm.webRTCCertificate()
+		ApplyMediaEncryptionOptions(sections[0], ext.MediaEncryption, certFile, keyFile)
+	}
+
+	// NAT options are off unless set, so a member turning off its profile's NAT says so explicitly
+	profile, hasProfile := acm.GetExtensionProfile(ext.Profile)
+	if ext.NAT {
+		ApplyNATEndpointOptions(sections[0])
+	} else if hasProfile && profile.NAT {
+		for _, key := range []string{"rewrite_contact", "force_rport", "rtp_symmetric"} {
+			sections[0].SetProperty(key, "no")
+		}
+	}
+
+	// Members of a profile inherit its templates and keep only their overrides
+	if hasProfile {
+		applyPjsipProfileInheritance(sections, acm.pjsipProfileTemplateSections(profile))
+	}
+
+	return sections
+}
+
+// ensureInheritedTemplates adds the profile templates that the given sections inherit
+// from, when they are not in the config yet
+func (acm *AsteriskConfigManager) ensureInheritedTemplates(config *AsteriskConfig, sections []*AsteriskSection) {
+	for _, section := range sections {
+		for _, name := range section.Inherits {
+			found := false
+			for _, existing := range config.Sections {
+				if existing.Template && existing.Name == name {
+					found = true
+					break
+				}
+			}
+			if found {
+				continue
+			}
+			for _, profile := range acm.profiles {
+				if profile.Name == name || profileAorTemplateName(profile.Name) == name {
+					placeProfileTemplates(config, acm.pjsipProfileTemplateSections(profile))
+					break
+				}
+			}
+		}
+	}
 }
 
 // GeneratePjsipEndpointString generates PJSIP configuration for an extension as a string
@@ -71,6 +172,45 @@ func (acm *AsteriskConfigManager) GeneratePjsipEndpointString(ext Extension) str
 	return sb.String()
 }
 
+// WritePjsipExtensions replaces the sections of several extensions with a single config
+// write and a single Git snapshot, for bulk operations. Disabled extensions are removed.
+func (acm *AsteriskConfigManager) WritePjsipExtensions(extensions []Extension, identifier string) error {
+	yellow := color.New(color.FgYellow)
+
+	config, err := acm.LoadPjsipConfig()
+	if err != nil {
+		return err
+	}
+
+	acm.replacePjsipExtensions(config, extensions)
+
+	if err := config.Save(); err != nil {
+		return fmt.Errorf("failed to write config file: %v", err)
+	}
+
+	if err := acm.CommitConfigChange("pjsip-update", fmt.Sprintf("Updated PJSIP config: %s", identifier)); err != nil {
+		yellow.Fprintf(acm.out, "⚠️  Git commit warning: %v\n", err)
+	}
+
+	return nil
+}
+
+// replacePjsipExtensions replaces the sections of several extensions in a loaded pjsip.conf,
+// adding the profile templates they inherit. Disabled extensions are only removed.
+func (acm *AsteriskConfigManager) replacePjsipExtensions(config *AsteriskConfig, extensions []Extension) {
+	for _, ext := range extensions {
+		config.RemoveSectionsForExtension(ext.ExtensionNumber)
+		if !ext.Enabled {
+			continue
+		}
+		sections := acm.GeneratePjsipEndpoint(ext)
+		acm.ensureInheritedTemplates(config, sections)
+		for _, section := range sections {
+			config.AddSection(section)
+		}
+	}
+}
+
 // WritePjsipConfigSections writes or updates PJSIP configuration using sections
 // This function intelligently handles existing sections:
 // - If commented sections exist for this extension, they are removed and replaced
@@ -83,8 +223,8 @@ func (acm *AsteriskConfigManager) WritePjsipConfigSections(sections []*AsteriskS
 	red := color.New(color.FgRed)
 
 	if acm.verbose {
-		cyan.Printf("📝 Updating configuration file: %s\n", acm.pjsipConfigPath)
-		cyan.Printf("   Identifier: %s\n", identifier)
+		cyan.Fprintf(acm.out, "📝 Updating configuration file: %s\n", acm.pjsipConfigPath)
+		cyan.Fprintf(acm.out, "   Identifier: %s\n", identifier)
 	}
 
 	// Parse existing config or create new one
@@ -93,7 +233,7 @@ func (acm *AsteriskConfigManager) WritePjsipConfigSections(sections []*AsteriskS
 
 	if _, statErr := os.Stat(acm.pjsipConfigPath); os.IsNotExist(statErr) {
 		// Create new config with header
-		yellow.Printf("⚠️  Config file not found, creating: %s\n", acm.pjsipConfigPath)
+		yellow.Fprintf(acm.out, "⚠️  Config file not found, creating: %s\n", acm.pjsipConfigPath)
 		config = &AsteriskConfig{
 			HeaderLines: []string{"; RayanPBX PJSIP Configuration", "; Generated by RayanPBX TUI", ""},
 			Sections:    []*AsteriskSection{},
@@ -123,13 +263,13 @@ func (acm *AsteriskConfigManager) WritePjsipConfigSections(sections []*AsteriskS
 
 	if acm.verbose {
 		if hasActive {
-			cyan.Printf("   Found existing active sections for %s, replacing\n", extNumber)
+			cyan.Fprintf(acm.out, "   Found existing active sections for %s, replacing\n", extNumber)
 		}
 		if hasCommented {
-			cyan.Printf("   Found existing commented sections for %s, replacing\n", extNumber)
+			cyan.Fprintf(acm.out, "   Found existing commented sections for %s, replacing\n", extNumber)
 		}
 		if hasAltNaming {
-			cyan.Printf("   Found %d sections with alternative naming for %s, normalizing\n", len(altSections), extNumber)
+			cyan.Fprintf(acm.out, "   Found %d sections with alternative naming for %s, normalizing\n", len(altSections), extNumber)
 		}
 	}
 
@@ -137,6 +277,9 @@ func (acm *AsteriskConfigManager) WritePjsipConfigSections(sections []*AsteriskS
 	// This ensures [101-auth], [auth101], etc. are replaced with correctly named [101] sections
 	config.RemoveSectionsForExtension(extNumber)
 
+	// Make sure profile templates exist before the sections that inherit them
+	acm.ensureInheritedTemplates(config, sections)
+
 	// Add new sections (with correct/standard naming)
 	for _, section := range sections {
 		config.AddSection(section)
@@ -145,20 +288,20 @@ func (acm *AsteriskConfigManager) WritePjsipConfigSections(sections []*AsteriskS
 	// Write to file
 	err = config.Save()
 	if err != nil {
-		red.Printf("❌ Failed to write config file: %v\n", err)
-		yellow.Println("💡 Tip: Make sure the TUI has write permissions to /etc/asterisk/")
-		yellow.Println("💡 Try running with: sudo rayanpbx-tui")
+		red.Fprintf(acm.out, "❌ Failed to write config file: %v\n", err)
+		yellow.Fprintln(acm.out, "💡 Tip: Make sure the TUI has write permissions to /etc/asterisk/")
+		yellow.Fprintln(acm.out, "💡 Try running with: sudo rayanpbx-tui")
 		return fmt.Errorf("failed to write config file: %v", err)
 	}
 
 	if acm.verbose {
-		green.Printf("✅ Configuration updated successfully\n")
-		green.Printf("   File: %s\n", acm.pjsipConfigPath)
+		green.Fprintf(acm.out, "✅ Configuration updated successfully\n")
+		green.Fprintf(acm.out, "   File: %s\n", acm.pjsipConfigPath)
 	}
 
 	// Commit changes to Git repository
 	if err := acm.CommitConfigChange("pjsip-update", fmt.Sprintf("Updated PJSIP config: %s", identifier)); err != nil {
-		yellow.Printf("⚠️  Git commit warning: %v\n", err)
+		yellow.Fprintf(acm.out, "⚠️  Git commit warning: %v\n", err)
 	}
 
 	return nil
@@ -173,8 +316,8 @@ func (acm *AsteriskConfigManager) WritePjsipConfig(content, identifier string) e
 	red := color.New(color.FgRed)
 
 	if acm.verbose {
-		cyan.Printf("📝 Updating configuration file: %s\n", acm.pjsipConfigPath)
-		cyan.Printf("   Identifier: %s\n", identifier)
+		cyan.Fprintf(acm.out, "📝 Updating configuration file: %s\n", acm.pjsipConfigPath)
+		cyan.Fprintf(acm.out, "   Identifier: %s\n", identifier)
 	}
 
 	// Parse existing config or create new one
@@ -183,7 +326,7 @@ func (acm *AsteriskConfigManager) WritePjsipConfig(content, identifier string) e
 
 	if _, statErr := os.Stat(acm.pjsipConfigPath); os.IsNotExist(statErr) {
 		// Create new config with header
-		yellow.Printf("⚠️  Config file not found, creating: %s\n", acm.pjsipConfigPath)
+		yellow.Fprintf(acm.out, "⚠️  Config file not found, creating: %s\n", acm.pjsipConfigPath)
 		config = &AsteriskConfig{
 			HeaderLines: []string{"; RayanPBX PJSIP Configuration", "; Generated by RayanPBX TUI", ""},
 			Sections:    []*AsteriskSection{},
@@ -217,20 +360,20 @@ func (acm *AsteriskConfigManager) WritePjsipConfig(content, identifier string) e
 	// Write to file
 	err = config.Save()
 	if err != nil {
-		red.Printf("❌ Failed to write config file: %v\n", err)
-		yellow.Println("💡 Tip: Make sure the TUI has write permissions to /etc/asterisk/")
-		yellow.Println("💡 Try running with: sudo rayanpbx-tui")
+		red.Fprintf(acm.out, "❌ Failed to write config file: %v\n", err)
+		yellow.Fprintln(acm.out, "💡 Tip: Make sure the TUI has write permissions to /etc/asterisk/")
+		yellow.Fprintln(acm.out, "💡 Try running with: sudo rayanpbx-tui")
 		return fmt.Errorf("failed to write config file: %v", err)
 	}
 
 	if acm.verbose {
-		green.Printf("✅ Configuration updated successfully\n")
-		green.Printf("   File: %s\n", acm.pjsipConfigPath)
+		green.Fprintf(acm.out, "✅ Configuration updated successfully\n")
+		green.Fprintf(acm.out, "   File: %s\n", acm.pjsipConfigPath)
 	}
 
 	// Commit changes to Git repository
 	if err := acm.CommitConfigChange("pjsip-update", fmt.Sprintf("Updated PJSIP config: %s", identifier)); err != nil {
-		yellow.Printf("⚠️  Git commit warning: %v\n", err)
+		yellow.Fprintf(acm.out, "⚠️  Git commit warning: %v\n", err)
 	}
 
 	return nil
@@ -244,7 +387,7 @@ func (acm *AsteriskConfigManager) RemovePjsipConfig(identifier string) error {
 	red := color.New(color.FgRed)
 
 	if acm.verbose {
-		cyan.Printf("🗑️  Removing configuration for: %s\n", identifier)
+		cyan.Fprintf(acm.out, "🗑️  Removing configuration for: %s\n", identifier)
 	}
 
 	config, err := ParseAsteriskConfig(acm.pjsipConfigPath)
@@ -265,26 +408,26 @@ func (acm *AsteriskConfigManager) RemovePjsipConfig(identifier string) error {
 
 	if removed == 0 {
 		if acm.verbose {
-			cyan.Printf("   No sections found for: %s\n", sectionName)
+			cyan.Fprintf(acm.out, "   No sections found for: %s\n", sectionName)
 		}
 	}
 
 	// Write back
 	err = config.Save()
 	if err != nil {
-		red.Printf("❌ Failed to write config file: %v\n", err)
+		red.Fprintf(acm.out, "❌ Failed to write config file: %v\n", err)
 		return fmt.Errorf("failed to write config file: %v", err)
 	}
 
 	if acm.verbose {
-		green.Printf("✅ Configuration removed successfully (%d sections)\n", removed)
+		green.Fprintf(acm.out, "✅ Configuration removed successfully (%d sections)\n", removed)
 	}
 
 	// Commit changes to Git repository
 	if err := acm.CommitConfigChange("pjsip-remove", fmt.Sprintf("Removed PJSIP config: %s", identifier)); err != nil {
 		// Log but don't fail on git commit error
 		if acm.verbose {
-			yellow.Printf("⚠️  Git commit warning: %v\n", err)
+			yellow.Fprintf(acm.out, "⚠️  Git commit warning: %v\n", err)
 		}
 	}
 
@@ -300,7 +443,7 @@ func (acm *AsteriskConfigManager) CommentOutPjsipConfig(identifier string) error
 	red := color.New(color.FgRed)
 
 	if acm.verbose {
-		cyan.Printf("🔇 Disabling (commenting out) configuration for: %s\n", identifier)
+		cyan.Fprintf(acm.out, "🔇 Disabling (commenting out) configuration for: %s\n", identifier)
 	}
 
 	config, err := ParseAsteriskConfig(acm.pjsipConfigPath)
@@ -320,7 +463,7 @@ func (acm *AsteriskConfigManager) CommentOutPjsipConfig(identifier string) error
 
 	if commented == 0 {
 		if acm.verbose {
-			cyan.Printf("   No active sections found for: %s\n", sectionName)
+			cyan.Fprintf(acm.out, "   No active sections found for: %s\n", sectionName)
 		}
 		return nil
 	}
@@ -328,18 +471,18 @@ func (acm *AsteriskConfigManager) CommentOutPjsipConfig(identifier string) error
 	// Write back
 	err = config.Save()
 	if err != nil {
-		red.Printf("❌ Failed to write config file: %v\n", err)
+		red.Fprintf(acm.out, "❌ Failed to write config file: %v\n", err)
 		return fmt.Errorf("failed to write config file: %v", err)
 	}
 
 	if acm.verbose {
-		green.Printf("✅ Configuration disabled successfully (%d sections commented out)\n", commented)
+		green.Fprintf(acm.out, "✅ Configuration disabled successfully (%d sections commented out)\n", commented)
 	}
 
 	// Commit changes to Git repository
 	if err := acm.CommitConfigChange("pjsip-disable", fmt.Sprintf("Disabled PJSIP config: %s", identifier)); err != nil {
 		if acm.verbose {
-			yellow.Printf("⚠️  Git commit warning: %v\n", err)
+			yellow.Fprintf(acm.out, "⚠️  Git commit warning: %v\n", err)
 		}
 	}
 
@@ -354,7 +497,7 @@ func (acm *AsteriskConfigManager) UncommentPjsipConfig(identifier string) error
 	red := color.New(color.FgRed)
 
 	if acm.verbose {
-		cyan.Printf("🔊 Enabling (uncommenting) configuration for: %s\n", identifier)
+		cyan.Fprintf(acm.out, "🔊 Enabling (uncommenting) configuration for: %s\n", identifier)
 	}
 
 	config, err := ParseAsteriskConfig(acm.pjsipConfigPath)
@@ -373,7 +516,7 @@ func (acm *AsteriskConfigManager) UncommentPjsipConfig(identifier string) error
 
 	if uncommented == 0 {
 		if acm.verbose {
-			cyan.Printf("   No commented sections found for: %s\n", sectionName)
+			cyan.Fprintf(acm.out, "   No commented sections found for: %s\n", sectionName)
 		}
 		return nil
 	}
@@ -381,18 +524,18 @@ func (acm *AsteriskConfigManager) UncommentPjsipConfig(identifier string) error
 	// Write back
 	err = config.Save()
 	if err != nil {
-		red.Printf("❌ Failed to write config file: %v\n", err)
+		red.Fprintf(acm.out, "❌ Failed to write config file: %v\n", err)
 		return fmt.Errorf("failed to write config file: %v", err)
 	}
 
 	if acm.verbose {
-		green.Printf("✅ Configuration enabled successfully (%d sections uncommented)\n", uncommented)
+		green.Fprintf(acm.out, "✅ Configuration enabled successfully (%d sections uncommented)\n", uncommented)
 	}
 
 	// Commit changes to Git repository
 	if err := acm.CommitConfigChange("pjsip-enable", fmt.Sprintf("Enabled PJSIP config: %s", identifier)); err != nil {
 		if acm.verbose {
-			yellow.Printf("⚠️  Git commit warning: %v\n", err)
+			yellow.Fprintf(acm.out, "⚠️  Git commit warning: %v\n", err)
 		}
 	}
 
@@ -406,7 +549,11 @@ func (acm *AsteriskConfigManager) ReloadAsterisk() error {
 	red := color.New(color.FgRed)
 
 	if acm.verbose {
-		cyan.Println("🔄 Reloading Asterisk PJSIP module...")
+		cyan.Fprintln(acm.out, "🔄 Reloading Asterisk PJSIP module...")
+	}
+
+	if acm.remote != nil {
+		return acm.reloadRemote()
 	}
 
 	// Try to find asterisk binary in common locations
@@ -435,15 +582,15 @@ func (acm *AsteriskConfigManager) ReloadAsterisk() error {
 	output, err := cmd.CombinedOutput()
 	
 	if err != nil {
-		red.Printf("❌ Failed to reload Asterisk: %v\n", err)
-		red.Printf("Output: %s\n", string(output))
+		red.Fprintf(acm.out, "❌ Failed to reload Asterisk: %v\n", err)
+		red.Fprintf(acm.out, "Output: %s\n", string(output))
 		return fmt.Errorf("failed to reload asterisk: %v", err)
 	}
 
 	if acm.verbose {
-		green.Println("✅ Asterisk reloaded successfully")
+		green.Fprintln(acm.out, "✅ Asterisk reloaded successfully")
 		if len(output) > 0 {
-			fmt.Printf("   Output: %s\n", strings.TrimSpace(string(output)))
+			fmt.Fprintf(acm.out, "   Output: %s\n", strings.TrimSpace(string(output)))
 		}
 	}
 
@@ -467,13 +614,19 @@ func (acm *AsteriskConfigManager) GenerateTransportConfig() string {
 
 // EnsureTransportConfig ensures transport configuration exists in pjsip.conf
 func (acm *AsteriskConfigManager) EnsureTransportConfig() error {
+	return acm.EnsureTransportConfigWithTLS(nil)
+}
+
+// EnsureTransportConfigWithTLS ensures the UDP and TCP transports exist in pjsip.conf and,
+// when tlsOpts is set, creates or updates the TLS transport with those options
+func (acm *AsteriskConfigManager) EnsureTransportConfigWithTLS(tlsOpts *TLSTransportOptions) error {
 	cyan := color.New(color.FgCyan)
 	green := color.New(color.FgGreen)
 	yellow := color.New(color.FgYellow)
 	red := color.New(color.FgRed)
 
 	if acm.verbose {
-		cyan.Println("📡 Checking PJSIP transport configuration...")
+		cyan.Fprintln(acm.out, "📡 Checking PJSIP transport configuration...")
 	}
 
 	// Parse existing config or create new one
@@ -483,7 +636,7 @@ func (acm *AsteriskConfigManager) EnsureTransportConfig() error {
 	if _, statErr := os.Stat(acm.pjsipConfigPath); os.IsNotExist(statErr) {
 		// Create new file with transport config
 		if acm.verbose {
-			yellow.Printf("⚠️  Config file not found, creating: %s\n", acm.pjsipConfigPath)
+			yellow.Fprintf(acm.out, "⚠️  Config file not found, creating: %s\n", acm.pjsipConfigPath)
 		}
 		config = &AsteriskConfig{
 			HeaderLines: []string{"; RayanPBX PJSIP Configuration", "; Generated by RayanPBX", ""},
@@ -501,44 +654,77 @@ func (acm *AsteriskConfigManager) EnsureTransportConfig() error {
 	hasUDPTransport := config.HasSectionWithType("transport-udp", "transport")
 	hasTCPTransport := config.HasSectionWithType("transport-tcp", "transport")
 
-	if hasUDPTransport && hasTCPTransport {
+	if hasUDPTransport && hasTCPTransport && tlsOpts == nil {
 		if acm.verbose {
-			green.Println("✅ PJSIP transports already configured")
+			green.Fprintln(acm.out, "✅ PJSIP transports already configured")
 		}
 		return nil
 	}
 
-	if acm.verbose {
-		yellow.Println("⚠️  Transport configuration incomplete, updating...")
-	}
+	// Keep NAT settings written by the NAT wizard on recreated transports
+	natProps := transportNATProperties(config)
 
-	// Remove old transport sections if they exist (to ensure clean state)
-	config.RemoveSectionsByName("transport-udp")
-	config.RemoveSectionsByName("transport-tcp")
+	if !hasUDPTransport || !hasTCPTransport {
+		if acm.verbose {
+			yellow.Fprintln(acm.out, "⚠️  Transport configuration incomplete, updating...")
+		}
 
-	// Add new transport sections at the beginning of sections
-	transportSections := CreateTransportSections()
-	
-	// Prepend transport sections
-	newSections := make([]*AsteriskSection, 0, len(transportSections)+len(config.Sections))
-	newSections = append(newSections, transportSections...)
-	newSections = append(newSections, config.Sections...)
-	config.Sections = newSections
+		// Remove old transport sections if they exist (to ensure clean state)
+		config.RemoveSectionsByName("transport-udp")
+		config.RemoveSectionsByName("transport-tcp")
+
+		// Add new transport sections at the beginning of sections
+		transportSections := CreateTransportSections()
+		applyTransportNATProperties(transportSections, natProps)
+
+		// Prepend transport sections
+		newSections := make([]*AsteriskSection, 0, len(transportSections)+len(config.Sections))
+		newSections = append(newSections, transportSections...)
+		newSections = append(newSections, config.Sections...)
+		config.Sections = newSections
+	}
+
+	if tlsOpts != nil {
+		// Replace the TLS transport in place, or add it after the other transports
+		tlsSection := CreateTLSTransportSection(*tlsOpts)
+		applyTransportNATProperties([]*AsteriskSection{tlsSection}, natProps)
+		replaced := false
+		for i, section := range config.Sections {
+			if section.Name == tlsTransportName && section.Type == "transport" && !section.Commented {
+				config.Sections[i] = tlsSection
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			idx := 0
+			for i, section := range config.Sections {
+				if section.Type == "transport" {
+					idx = i + 1
+				}
+			}
+			newSections := make([]*AsteriskSection, 0, len(config.Sections)+1)
+			newSections = append(newSections, config.Sections[:idx]...)
+			newSections = append(newSections, tlsSection)
+			newSections = append(newSections, config.Sections[idx:]...)
+			config.Sections = newSections
+		}
+	}
 
 	// Write updated config
 	err = config.Save()
 	if err != nil {
-		red.Printf("❌ Failed to write transport config: %v\n", err)
+		red.Fprintf(acm.out, "❌ Failed to write transport config: %v\n", err)
 		return fmt.Errorf("failed to write config file: %v", err)
 	}
 
 	if acm.verbose {
-		green.Println("✅ Transport configuration added successfully")
+		green.Fprintln(acm.out, "✅ Transport configuration added successfully")
 	}
 
 	// Commit changes to Git repository
 	if err := acm.CommitConfigChange("transport-update", "Updated PJSIP transport configuration"); err != nil {
-		yellow.Printf("⚠️  Git commit warning: %v\n", err)
+		yellow.Fprintf(acm.out, "⚠️  Git commit warning: %v\n", err)
 	}
 
 	return nil
@@ -593,7 +779,7 @@ func (acm *AsteriskConfigManager) GenerateInternalDialplan(extensions []Extensio
 
 // WriteDialplanConfig writes or updates dialplan configuration in extensions.conf
 func (acm *AsteriskConfigManager) WriteDialplanConfig(content, identifier string) error {
-	extensionsConfigPath := "/etc/asterisk/extensions.conf"
+	extensionsConfigPath := acm.extensionsConfigPath
 	
 	cyan := color.New(color.FgCyan)
 	green := color.New(color.FgGreen)
@@ -601,8 +787,8 @@ func (acm *AsteriskConfigManager) WriteDialplanConfig(content, identifier string
 	red := color.New(color.FgRed)
 
 	if acm.verbose {
-		cyan.Printf("📝 Updating dialplan file: %s\n", extensionsConfigPath)
-		cyan.Printf("   Identifier: %s\n", identifier)
+		cyan.Fprintf(acm.out, "📝 Updating dialplan file: %s\n", extensionsConfigPath)
+		cyan.Fprintf(acm.out, "   Identifier: %s\n", identifier)
 	}
 
 	// Parse existing config or create new one
@@ -610,7 +796,7 @@ func (acm *AsteriskConfigManager) WriteDialplanConfig(content, identifier string
 	var err error
 
 	if _, statErr := os.Stat(extensionsConfigPath); os.IsNotExist(statErr) {
-		yellow.Printf("⚠️  Dialplan file not found, creating: %s\n", extensionsConfigPath)
+		yellow.Fprintf(acm.out, "⚠️  Dialplan file not found, creating: %s\n", extensionsConfigPath)
 		config = &AsteriskConfig{
 			HeaderLines: []string{"; RayanPBX Dialplan Configuration", "; Generated by RayanPBX TUI", ""},
 			Sections:    []*AsteriskSection{},
@@ -624,6 +810,12 @@ func (acm *AsteriskConfigManager) WriteDialplanConfig(content, identifier string
 	}
 
 	// For dialplan, we replace the [from-internal] context with the new content
+	// Keep includes of the existing context (e.g. imported routes) so they survive regeneration
+	var includes []string
+	for _, section := range config.FindActiveSectionsByName("from-internal") {
+		includes = append(includes, section.GetEntries("include")...)
+	}
+
 	// Remove existing from-internal context
 	config.RemoveSectionsByName("from-internal")
 
@@ -637,23 +829,27 @@ func (acm *AsteriskConfigManager) WriteDialplanConfig(content, identifier string
 		config.AddSection(section)
 	}
 
+	for _, include := range includes {
+		ensureDialplanInclude(config, "from-internal", include)
+	}
+
 	// Write to file
 	err = config.Save()
 	if err != nil {
-		red.Printf("❌ Failed to write dialplan file: %v\n", err)
-		yellow.Println("💡 Tip: Make sure the TUI has write permissions to /etc/asterisk/")
-		yellow.Println("💡 Try running with: sudo rayanpbx-tui")
+		red.Fprintf(acm.out, "❌ Failed to write dialplan file: %v\n", err)
+		yellow.Fprintln(acm.out, "💡 Tip: Make sure the TUI has write permissions to /etc/asterisk/")
+		yellow.Fprintln(acm.out, "💡 Try running with: sudo rayanpbx-tui")
 		return fmt.Errorf("failed to write dialplan file: %v", err)
 	}
 
 	if acm.verbose {
-		green.Printf("✅ Dialplan updated successfully\n")
-		green.Printf("   File: %s\n", extensionsConfigPath)
+		green.Fprintf(acm.out, "✅ Dialplan updated successfully\n")
+		green.Fprintf(acm.out, "   File: %s\n", extensionsConfigPath)
 	}
 
 	// Commit changes to Git repository
 	if err := acm.CommitConfigChange("dialplan-update", fmt.Sprintf("Updated dialplan: %s", identifier)); err != nil {
-		yellow.Printf("⚠️  Git commit warning: %v\n", err)
+		yellow.Fprintf(acm.out, "⚠️  Git commit warning: %v\n", err)
 	}
 
 	return nil
@@ -665,13 +861,18 @@ func (acm *AsteriskConfigManager) CommitConfigChange(action, description string)
 	green := color.New(color.FgGreen)
 	yellow := color.New(color.FgYellow)
 
+	// The local /etc/asterisk history does not describe remote servers
+	if acm.remote != nil {
+		return nil
+	}
+
 	// Check if /etc/asterisk is a Git repository
 	asteriskDir := "/etc/asterisk"
 	gitDir := asteriskDir + "/.git"
 
 	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
 		if acm.verbose {
-			yellow.Println("⚠️  /etc/asterisk is not a Git repository, skipping commit")
+			yellow.Fprintln(acm.out, "⚠️  /etc/asterisk is not a Git repository, skipping commit")
 		}
 		return nil // Not an error - just skip if not a git repo
 	}
@@ -697,13 +898,13 @@ func (acm *AsteriskConfigManager) CommitConfigChange(action, description string)
 		output, err := cmd.CombinedOutput()
 		if err != nil {
 			if acm.verbose {
-				yellow.Printf("⚠️  Git commit helper failed: %v\n", err)
-				yellow.Printf("   Output: %s\n", string(output))
+				yellow.Fprintf(acm.out, "⚠️  Git commit helper failed: %v\n", err)
+				yellow.Fprintf(acm.out, "   Output: %s\n", string(output))
 			}
 			// Fall through to inline git commit
 		} else {
 			if acm.verbose {
-				green.Printf("✅ Configuration snapshot saved\n")
+				green.Fprintf(acm.out, "✅ Configuration snapshot saved\n")
 			}
 			return nil
 		}
@@ -733,7 +934,7 @@ func (acm *AsteriskConfigManager) CommitConfigChange(action, description string)
 	
 	if len(strings.TrimSpace(string(statusOutput))) == 0 {
 		if acm.verbose {
-			yellow.Println("⚠️  No changes to commit")
+			yellow.Fprintln(acm.out, "⚠️  No changes to commit")
 		}
 		return nil
 	}
@@ -758,16 +959,16 @@ func (acm *AsteriskConfigManager) CommitConfigChange(action, description string)
 	statusCmd = exec.Command("git", "status", "--porcelain")
 	statusOutput, err = statusCmd.Output()
 	if err != nil {
-		yellow.Printf("⚠️  Warning: Could not verify commit status: %v\n", err)
+		yellow.Fprintf(acm.out, "⚠️  Warning: Could not verify commit status: %v\n", err)
 	} else if len(strings.TrimSpace(string(statusOutput))) > 0 {
 		// Repository is still dirty after commit - this is a problem
-		yellow.Println("⚠️  Warning: Repository still has uncommitted changes after commit")
-		yellow.Printf("   Uncommitted files:\n%s", string(statusOutput))
+		yellow.Fprintln(acm.out, "⚠️  Warning: Repository still has uncommitted changes after commit")
+		yellow.Fprintf(acm.out, "   Uncommitted files:\n%s", string(statusOutput))
 		return fmt.Errorf("repository still dirty after commit - some files may not have been committed")
 	}
 
 	if acm.verbose {
-		green.Println("✅ Configuration snapshot saved")
+		green.Fprintln(acm.out, "✅ Configuration snapshot saved")
 	}
 
 	return nil
@@ -24,9 +24,19 @@ type AsteriskSection struct {
 	Keys         []string          // Keys in order (for preserving insertion order)
 	Comments     []string          // Comments associated with this section (preceding lines starting with ;)
 	BodyComments []string          // Comments within the section body (lines starting with ; between properties)
+	Entries      []AsteriskEntry   // Repeatable "key => value" lines in order (dialplan exten/same/include)
+	Template     bool              // Whether this section is a template, written as [name](!)
+	Inherits     []string          // Templates this section inherits from, written as [name](tpl1,tpl2)
 	Commented    bool              // Whether this section is commented out (disabled)
 }
 
+// AsteriskEntry represents a repeatable "key => value" line such as a dialplan
+// exten, same or include line. Unlike properties, entries may share the same key.
+type AsteriskEntry struct {
+	Key   string
+	Value string
+}
+
 // AsteriskConfig represents an Asterisk configuration file
 type AsteriskConfig struct {
 	Sections    []*AsteriskSection // All sections in order
@@ -61,6 +71,50 @@ func (s *AsteriskSection) GetProperty(key string) (string, bool) {
 	return val, ok
 }
 
+// RemoveProperty removes a property and its position in the key order
+func (s *AsteriskSection) RemoveProperty(key string) {
+	if _, exists := s.Properties[key]; !exists {
+		return
+	}
+	delete(s.Properties, key)
+	for i, k := range s.Keys {
+		if k == key {
+			s.Keys = append(s.Keys[:i], s.Keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// HeaderOptions returns the template options that follow the section name,
+// e.g. "(!)" for a template or "(desk-phone)" for a section inheriting a template
+func (s *AsteriskSection) HeaderOptions() string {
+	var options []string
+	if s.Template {
+		options = append(options, "!")
+	}
+	options = append(options, s.Inherits...)
+	if len(options) == 0 {
+		return ""
+	}
+	return "(" + strings.Join(options, ",") + ")"
+}
+
+// AddEntry appends a repeatable "key => value" line (e.g. exten, same, include)
+func (s *AsteriskSection) AddEntry(key, value string) {
+	s.Entries = append(s.Entries, AsteriskEntry{Key: key, Value: value})
+}
+
+// GetEntries returns the values of all "key => value" lines with the given key
+func (s *AsteriskSection) GetEntries(key string) []string {
+	var values []string
+	for _, entry := range s.Entries {
+		if entry.Key == key {
+			values = append(values, entry.Value)
+		}
+	}
+	return values
+}
+
 // String renders the section as a config string
 // If the section is marked as Commented, all lines are prefixed with ';'
 func (s *AsteriskSection) String() string {
@@ -77,7 +131,7 @@ func (s *AsteriskSection) String() string {
 	}
 
 	// Write section header
-	sb.WriteString(fmt.Sprintf("%s[%s]\n", prefix, s.Name))
+	sb.WriteString(fmt.Sprintf("%s[%s]%s\n", prefix, s.Name, s.HeaderOptions()))
 
 	// Write properties in order
 	for _, key := range s.Keys {
@@ -86,6 +140,11 @@ func (s *AsteriskSection) String() string {
 		}
 	}
 
+	// Write repeatable entries in order
+	for _, entry := range s.Entries {
+		sb.WriteString(fmt.Sprintf("%s%s => %s\n", prefix, entry.Key, entry.Value))
+	}
+
 	// Write body comments (preserved for round-trip)
 	for _, comment := range s.BodyComments {
 		sb.WriteString(comment)
@@ -115,12 +174,15 @@ func ParseAsteriskConfigContent(content string, filePath string) (*AsteriskConfi
 	}
 
 	scanner := bufio.NewScanner(strings.NewReader(content))
-	// Match both regular and commented section headers: [name] or ;[name]
-	sectionRegex := regexp.MustCompile(`^\s*;?\s*\[([^\]]+)\]`)
+	// Match both regular and commented section headers: [name] or ;[name],
+	// optionally followed by template options: [name](!) or [name](template)
+	sectionRegex := regexp.MustCompile(`^\s*;?\s*\[([^\]]+)\](?:\(([^)]*)\))?`)
 	commentedSectionRegex := regexp.MustCompile(`^\s*;\s*\[([^\]]+)\]`)
 	kvRegex := regexp.MustCompile(`^\s*([^=;\s]+)\s*=\s*(.*)$`)
 	// Match commented key=value lines: ;key=value
 	commentedKvRegex := regexp.MustCompile(`^\s*;\s*([^=;\s]+)\s*=\s*(.*)$`)
+	// Match repeatable key => value lines (dialplan exten/same/include), active or commented
+	entryRegex := regexp.MustCompile(`^\s*(;?)\s*([^=;\s]+)\s*=>\s*(.*)$`)
 
 	var currentSection *AsteriskSection
 	var pendingComments []string
@@ -140,6 +202,14 @@ func ParseAsteriskConfigContent(content string, filePath string) (*AsteriskConfi
 			// Start new section
 			sectionName := matches[1]
 			currentSection = NewAsteriskSection(sectionName, "")
+			for _, option := range strings.Split(matches[2], ",") {
+				option = strings.TrimSpace(option)
+				if option == "!" {
+					currentSection.Template = true
+				} else if option != "" {
+					currentSection.Inherits = append(currentSection.Inherits, option)
+				}
+			}
 			currentSection.Comments = pendingComments
 			pendingComments = []string{}
 			inHeader = false
@@ -151,6 +221,12 @@ func ParseAsteriskConfigContent(content string, filePath string) (*AsteriskConfi
 			continue
 		}
 
+		// Check for key => value (repeatable entries must be checked before key=value)
+		if matches := entryRegex.FindStringSubmatch(line); matches != nil && currentSection != nil && (matches[1] == ";") == currentSection.Commented {
+			currentSection.AddEntry(strings.TrimSpace(matches[2]), strings.TrimSpace(matches[3]))
+			continue
+		}
+
 		// Check for key=value (active section)
 		if matches := kvRegex.FindStringSubmatch(line); matches != nil && currentSection != nil && !currentSection.Commented {
 			key := strings.TrimSpace(matches[1])
@@ -487,13 +563,17 @@ func CreatePjsipEndpointSections(extNumber, secret, context, transport string, c
 	endpoint.SetProperty("context", context)
 	endpoint.SetProperty("disallow", "all")
 
-	// Add codecs in order
+	// Add codecs in order as a single allow line, since properties are unique per key
+	var allowed []string
 	for _, codec := range codecs {
 		codec = strings.TrimSpace(codec)
 		if codec != "" {
-			endpoint.SetProperty("allow", codec)
+			allowed = append(allowed, codec)
 		}
 	}
+	if len(allowed) > 0 {
+		endpoint.SetProperty("allow", strings.Join(allowed, ","))
+	}
 
 	endpoint.SetProperty("transport", transport)
 	endpoint.SetProperty("auth", extNumber)
@@ -536,6 +616,82 @@ func CreatePjsipEndpointSections(extNumber, secret, context, transport string, c
 	return sections
 }
 
+// ApplyMediaEncryptionOptions sets SRTP on an endpoint section. "sdes" exchanges keys in SDP
+// (requires a TLS transport to be secure), "dtls" negotiates them with the given certificate.
+// Any other mode leaves media unencrypted.
+func ApplyMediaEncryptionOptions(endpoint *AsteriskSection, mode, certFile, keyFile string) {
+	switch mode {
+	case "sdes":
+		endpoint.SetProperty("media_encryption", "sdes")
+	case "dtls":
+		endpoint.SetProperty("media_encryption", "dtls")
+		endpoint.SetProperty("dtls_verify", "fingerprint")
+		endpoint.SetProperty("dtls_setup", "actpass")
+		endpoint.SetProperty("dtls_cert_file", certFile)
+		endpoint.SetProperty("dtls_private_key", keyFile)
+	}
+}
+
+// ApplyWebRTCEndpointOptions adds the options a browser softphone needs to an endpoint section:
+// DTLS-SRTP with the given certificate, ICE, AVPF and RTCP multiplexing
+func ApplyWebRTCEndpointOptions(endpoint *AsteriskSection, certFile, keyFile string) {
+	endpoint.SetProperty("webrtc", "yes")
+	endpoint.SetProperty("use_avpf", "yes")
+	ApplyMediaEncryptionOptions(endpoint, "dtls", certFile, keyFile)
+	endpoint.SetProperty("ice_support", "yes")
+	endpoint.SetProperty("rtcp_mux", "yes")
+	endpoint.SetProperty("media_use_received_transport", "yes")
+}
+
+// ApplyNATEndpointOptions makes an endpoint work for phones behind NAT: replies go to the
+// address requests came from and media is sent back to where it is received from
+func ApplyNATEndpointOptions(endpoint *AsteriskSection) {
+	endpoint.SetProperty("rewrite_contact", "yes")
+	endpoint.SetProperty("force_rport", "yes")
+	endpoint.SetProperty("rtp_symmetric", "yes")
+}
+
+// CreateWSSTransportSection creates the secure WebSocket transport used by WebRTC clients.
+// The listener itself is Asterisk's HTTP server, configured with TLS in http.conf.
+func CreateWSSTransportSection() *AsteriskSection {
+	wss := NewAsteriskSection(webRTCTransportName, "transport")
+	wss.SetProperty("type", "transport")
+	wss.SetProperty("protocol", "wss")
+	wss.SetProperty("bind", "0.0.0.0")
+	wss.SetProperty("allow_reload", "yes")
+	return wss
+}
+
+// CreateTLSTransportSection creates the SIP over TLS transport from the given options
+func CreateTLSTransportSection(opts TLSTransportOptions) *AsteriskSection {
+	port := opts.Port
+	if port == 0 {
+		port = DefaultTLSPort
+	}
+
+	tlsTransport := NewAsteriskSection(tlsTransportName, "transport")
+	tlsTransport.SetProperty("type", "transport")
+	tlsTransport.SetProperty("protocol", "tls")
+	tlsTransport.SetProperty("bind", fmt.Sprintf("0.0.0.0:%d", port))
+	tlsTransport.SetProperty("cert_file", opts.CertFile)
+	tlsTransport.SetProperty("priv_key_file", opts.KeyFile)
+	if opts.CAListFile != "" {
+		tlsTransport.SetProperty("ca_list_file", opts.CAListFile)
+	}
+	if opts.Method != "" {
+		tlsTransport.SetProperty("method", opts.Method)
+	}
+	if opts.Cipher != "" {
+		tlsTransport.SetProperty("cipher", opts.Cipher)
+	}
+	tlsTransport.SetProperty("verify_client", yesNo(opts.VerifyClient))
+	tlsTransport.SetProperty("verify_server", yesNo(opts.VerifyServer))
+	tlsTransport.SetProperty("require_client_cert", yesNo(opts.VerifyClient))
+	tlsTransport.SetProperty("allow_reload", "yes")
+
+	return tlsTransport
+}
+
 // CreateTransportSections creates transport sections for UDP and TCP
 func CreateTransportSections() []*AsteriskSection {
 	sections := make([]*AsteriskSection, 0, 2)
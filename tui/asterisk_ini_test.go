@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"os"
 	"path/filepath"
 	"strings"
@@ -849,3 +850,108 @@ if !config.HasSection("102") {
 t.Error("102 should still exist")
 }
 }
+
+// TestParseDialplanEntries tests that repeatable "key => value" lines are kept in order
+func TestParseDialplanEntries(t *testing.T) {
+	content := `[from-internal]
+include => other-context
+exten => 101,hint,PJSIP/101
+exten => 101,1,Dial(PJSIP/101,30)
+ same => n,Hangup()
+exten => 102,1,Dial(PJSIP/102,30)
+`
+	config, err := ParseAsteriskConfigContent(content, "")
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+
+	section := config.FindSectionsByName("from-internal")[0]
+	if len(section.Entries) != 5 {
+		t.Fatalf("Expected 5 entries, got %d", len(section.Entries))
+	}
+	if exten := section.GetEntries("exten"); len(exten) != 3 || exten[2] != "102,1,Dial(PJSIP/102,30)" {
+		t.Errorf("Unexpected exten entries: %v", exten)
+	}
+	if len(section.Properties) != 0 {
+		t.Errorf("Entries should not be stored as properties, got %v", section.Properties)
+	}
+
+	// Round trip keeps every line
+	reparsed, err := ParseAsteriskConfigContent(config.String(), "")
+	if err != nil {
+		t.Fatalf("Failed to reparse config: %v", err)
+	}
+	if len(reparsed.Sections[0].Entries) != 5 {
+		t.Errorf("Expected 5 entries after round trip, got %d:\n%s", len(reparsed.Sections[0].Entries), config.String())
+	}
+}
+
+// TestParseTemplateSections tests [name](!) templates and [name](template) inheritance
+func TestParseTemplateSections(t *testing.T) {
+	content := `[desk-phone](!)
+type=endpoint
+context=from-internal
+
+[101](desk-phone)
+type=endpoint
+auth=101
+
+[102](!,desk-phone, other)
+type=endpoint
+
+;[103](desk-phone)
+;type=endpoint
+`
+	config, err := ParseAsteriskConfigContent(content, "")
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	if len(config.Sections) != 4 {
+		t.Fatalf("Expected 4 sections, got %d", len(config.Sections))
+	}
+
+	tests := []struct {
+		name      string
+		template  bool
+		inherits  []string
+		commented bool
+	}{
+		{"desk-phone", true, nil, false},
+		{"101", false, []string{"desk-phone"}, false},
+		{"102", true, []string{"desk-phone", "other"}, false},
+		{"103", false, []string{"desk-phone"}, true},
+	}
+	for i, tt := range tests {
+		section := config.Sections[i]
+		if section.Name != tt.name || section.Template != tt.template || section.Commented != tt.commented ||
+			strings.Join(section.Inherits, ",") != strings.Join(tt.inherits, ",") {
+			t.Errorf("Section %d: got name=%s template=%v inherits=%v commented=%v", i, section.Name, section.Template, section.Inherits, section.Commented)
+		}
+		if section.Type != "endpoint" {
+			t.Errorf("Section %s: expected endpoint type, got %q", section.Name, section.Type)
+		}
+	}
+
+	output := config.String()
+	for _, header := range []string{"[desk-phone](!)\n", "[101](desk-phone)\n", "[102](!,desk-phone,other)\n", ";[103](desk-phone)\n"} {
+		if !strings.Contains(output, header) {
+			t.Errorf("Expected %q in output:\n%s", header, output)
+		}
+	}
+}
+
+// TestConfigManagerOutput tests that SetOutput redirects the manager's messages
+func TestConfigManagerOutput(t *testing.T) {
+	var out bytes.Buffer
+	acm := NewAsteriskConfigManager(true)
+	acm.pjsipConfigPath = filepath.Join(t.TempDir(), "pjsip.conf")
+	acm.SetOutput(&out)
+
+	ext := Extension{ExtensionNumber: "100", Secret: "pw", Context: "from-internal", Transport: "transport-udp", Codecs: "ulaw"}
+	if err := acm.WritePjsipConfigSections(acm.GeneratePjsipEndpoint(ext), "Extension 100"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "Updating configuration file") {
+		t.Errorf("Expected progress messages in the configured output, got %q", out.String())
+	}
+}
@@ -0,0 +1,544 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// DefaultCallQualityFile stores active and recent call quality between runs
+const DefaultCallQualityFile = "/var/lib/rayanpbx/call-quality.json"
+
+const (
+	// Thresholds for flagging a call leg as poor
+	poorMOSThreshold = 3.6
+	poorLossPercent  = 3.0
+	poorJitterMs     = 30.0
+	poorRTTMs        = 300.0
+
+	callQualityHistoryLimit = 200
+	callQualityRecentLimit  = 20
+	amiDialTimeout          = 5 * time.Second
+)
+
+// RTPStreamStats holds statistics for one direction of an RTP stream
+type RTPStreamStats struct {
+	Packets     uint64  `json:"packets"`
+	Lost        uint64  `json:"lost"`
+	LossPercent float64 `json:"loss_percent"`
+	JitterMs    float64 `json:"jitter_ms"`
+}
+
+// CallQuality holds RTP quality statistics for one call leg (channel)
+type CallQuality struct {
+	Channel   string         `json:"channel"`
+	Codec     string         `json:"codec,omitempty"`
+	Uptime    string         `json:"uptime,omitempty"`
+	Receive   RTPStreamStats `json:"receive"`
+	Transmit  RTPStreamStats `json:"transmit"`
+	RTTMs     float64        `json:"rtt_ms"`
+	MOS       float64        `json:"mos"`
+	MinMOS    float64        `json:"min_mos"`
+	Poor      bool           `json:"poor"`
+	Issues    []string       `json:"issues,omitempty"`
+	FirstSeen time.Time      `json:"first_seen"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	EndedAt   time.Time      `json:"ended_at,omitempty"`
+}
+
+// CallQualityReport is the JSON document produced for monitoring
+type CallQualityReport struct {
+	GeneratedAt time.Time      `json:"generated_at"`
+	Active      []*CallQuality `json:"active"`
+	Recent      []*CallQuality `json:"recent"`
+	PoorCalls   int            `json:"poor_calls"`
+}
+
+// EstimateMOS estimates the mean opinion score with a simplified ITU-T G.107 E-model
+func EstimateMOS(rttMs, jitterMs, lossPercent float64) float64 {
+	// One-way delay plus jitter buffer and codec delay
+	effective := rttMs/2 + 2*jitterMs + 10
+	r := 93.2
+	if effective < 160 {
+		r -= effective / 40
+	} else {
+		r -= (effective - 120) / 10
+	}
+	r -= 2.5 * lossPercent
+	r = math.Max(0, math.Min(100, r))
+
+	mos := 1 + 0.035*r + 7e-6*r*(r-60)*(100-r)
+	mos = math.Max(1, math.Min(4.5, mos))
+	return math.Round(mos*100) / 100
+}
+
+// evaluate recalculates the MOS and flags the leg when any metric is poor.
+// A leg that was poor once stays flagged so recent calls show the problem.
+func (q *CallQuality) evaluate() {
+	jitter := math.Max(q.Receive.JitterMs, q.Transmit.JitterMs)
+	loss := math.Max(q.Receive.LossPercent, q.Transmit.LossPercent)
+	q.MOS = EstimateMOS(q.RTTMs, jitter, loss)
+	if q.MinMOS == 0 || q.MOS < q.MinMOS {
+		q.MinMOS = q.MOS
+	}
+
+	var issues []string
+	if loss > poorLossPercent {
+		issues = append(issues, fmt.Sprintf("packet loss %.1f%%", loss))
+	}
+	if jitter > poorJitterMs {
+		issues = append(issues, fmt.Sprintf("jitter %.0fms", jitter))
+	}
+	if q.RTTMs > poorRTTMs {
+		issues = append(issues, fmt.Sprintf("round trip %.0fms", q.RTTMs))
+	}
+	if q.MOS < poorMOSThreshold {
+		issues = append(issues, fmt.Sprintf("MOS %.2f", q.MOS))
+	}
+	if len(issues) > 0 {
+		q.Poor = true
+		q.Issues = issues
+	}
+}
+
+// Summary returns a one-line description of the leg
+func (q *CallQuality) Summary() string {
+	status := "✅"
+	if q.Poor {
+		status = "⚠️ "
+	}
+	line := fmt.Sprintf("%s %-24s %-6s rx %5.1fms %4.1f%%  tx %5.1fms %4.1f%%  rtt %4.0fms  MOS %.2f",
+		status, q.Channel, q.Codec,
+		q.Receive.JitterMs, q.Receive.LossPercent,
+		q.Transmit.JitterMs, q.Transmit.LossPercent,
+		q.RTTMs, q.MOS)
+	if len(q.Issues) > 0 {
+		line += "  (" + strings.Join(q.Issues, ", ") + ")"
+	}
+	return line
+}
+
+// ParseChannelStats parses the output of "pjsip show channelstats".
+// Jitter and RTT are reported by Asterisk in seconds.
+func ParseChannelStats(output string) []*CallQuality {
+	var stats []*CallQuality
+	inBody := false
+	for _, line := range strings.Split(output, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "====") {
+			inBody = true
+			continue
+		}
+		if !inBody || trimmed == "" || strings.HasPrefix(trimmed, "Objects found") {
+			continue
+		}
+
+		fields := strings.Fields(trimmed)
+		if len(fields) < 12 {
+			continue
+		}
+		// The bridge ID column is empty for unbridged channels
+		f := fields[len(fields)-12:]
+		rxJitter, err1 := strconv.ParseFloat(f[6], 64)
+		txJitter, err2 := strconv.ParseFloat(f[10], 64)
+		rtt, err3 := strconv.ParseFloat(f[11], 64)
+		if err1 != nil || err2 != nil || err3 != nil {
+			continue
+		}
+
+		channel := f[0]
+		if !strings.Contains(channel, "/") {
+			channel = "PJSIP/" + channel
+		}
+		q := &CallQuality{
+			Channel: channel,
+			Uptime:  f[1],
+			Codec:   f[2],
+			Receive: RTPStreamStats{
+				Packets:  parseChannelStatsCount(f[3]),
+				Lost:     parseChannelStatsCount(f[4]),
+				JitterMs: rxJitter * 1000,
+			},
+			Transmit: RTPStreamStats{
+				Packets:  parseChannelStatsCount(f[7]),
+				Lost:     parseChannelStatsCount(f[8]),
+				JitterMs: txJitter * 1000,
+			},
+			RTTMs: rtt * 1000,
+		}
+		q.Receive.LossPercent = lossPercent(q.Receive.Packets, q.Receive.Lost)
+		q.Transmit.LossPercent = lossPercent(q.Transmit.Packets, q.Transmit.Lost)
+		stats = append(stats, q)
+	}
+	return stats
+}
+
+// parseChannelStatsCount parses a packet count, which Asterisk abbreviates with K above 100000
+func parseChannelStatsCount(s string) uint64 {
+	multiplier := uint64(1)
+	if strings.HasSuffix(s, "K") {
+		multiplier = 1000
+		s = strings.TrimSuffix(s, "K")
+	}
+	n, _ := strconv.ParseUint(s, 10, 64)
+	return n * multiplier
+}
+
+// lossPercent returns lost packets as a percentage of expected packets
+func lossPercent(received, lost uint64) float64 {
+	if received+lost == 0 {
+		return 0
+	}
+	return math.Round(float64(lost)*1000/float64(received+lost)) / 10
+}
+
+// rtpClockRate returns the RTP timestamp clock rate for a codec
+func rtpClockRate(codec string) float64 {
+	switch strings.ToLower(codec) {
+	case "opus":
+		return 48000
+	case "slin16", "siren7":
+		return 16000
+	}
+	// G.722 uses an 8kHz RTP clock for historical reasons
+	return 8000
+}
+
+// CallQualityMonitor keeps per-leg quality for active calls and a history of recent ones
+type CallQualityMonitor struct {
+	mu      sync.Mutex
+	path    string
+	active  map[string]*CallQuality
+	history []*CallQuality
+}
+
+// callQualityState is the on-disk form of the monitor
+type callQualityState struct {
+	Active  []*CallQuality `json:"active"`
+	History []*CallQuality `json:"history"`
+}
+
+// NewCallQualityMonitor creates a monitor and loads previously saved state from path
+func NewCallQualityMonitor(path string) *CallQualityMonitor {
+	cm := &CallQualityMonitor{path: path, active: make(map[string]*CallQuality)}
+	if path == "" {
+		return cm
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		var state callQualityState
+		if json.Unmarshal(data, &state) == nil {
+			for _, q := range state.Active {
+				cm.active[q.Channel] = q
+			}
+			cm.history = state.History
+		}
+	}
+	return cm
+}
+
+// Save writes active and recent calls to the state file
+func (cm *CallQualityMonitor) Save() error {
+	if cm.path == "" {
+		return nil
+	}
+	cm.mu.Lock()
+	state := callQualityState{Active: cm.sortedActive(), History: cm.history}
+	data, err := json.MarshalIndent(state, "", "  ")
+	cm.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode call quality history: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(cm.path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", filepath.Dir(cm.path), err)
+	}
+	if err := os.WriteFile(cm.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to save call quality history: %v", err)
+	}
+	return nil
+}
+
+// leg returns the active leg for a channel, creating it if needed
+func (cm *CallQualityMonitor) leg(channel string, now time.Time) *CallQuality {
+	q, ok := cm.active[channel]
+	if !ok {
+		q = &CallQuality{Channel: channel, FirstSeen: now}
+		cm.active[channel] = q
+	}
+	q.UpdatedAt = now
+	return q
+}
+
+// finish moves a leg from the active calls to the history
+func (cm *CallQualityMonitor) finish(channel string, now time.Time) {
+	q, ok := cm.active[channel]
+	if !ok {
+		return
+	}
+	delete(cm.active, channel)
+	q.EndedAt = now
+	cm.history = append(cm.history, q)
+	if len(cm.history) > callQualityHistoryLimit {
+		cm.history = cm.history[len(cm.history)-callQualityHistoryLimit:]
+	}
+}
+
+// UpdateChannelStats merges a channelstats snapshot. Legs missing from the
+// snapshot have ended and are moved to the history.
+func (cm *CallQualityMonitor) UpdateChannelStats(stats []*CallQuality, now time.Time) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	seen := make(map[string]bool)
+	for _, s := range stats {
+		seen[s.Channel] = true
+		q := cm.leg(s.Channel, now)
+		q.Codec = s.Codec
+		q.Uptime = s.Uptime
+		q.Receive = s.Receive
+		q.Transmit = s.Transmit
+		q.RTTMs = s.RTTMs
+		q.evaluate()
+	}
+	for channel := range cm.active {
+		if !seen[channel] {
+			cm.finish(channel, now)
+		}
+	}
+}
+
+// HandleAMIEvent updates legs from RTCPSent, RTCPReceived and Hangup events.
+// It returns true when the event was used.
+func (cm *CallQualityMonitor) HandleAMIEvent(event AMIMessage, now time.Time) bool {
+	channel := event["Channel"]
+	if channel == "" {
+		return false
+	}
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	switch event.Event() {
+	case "RTCPSent", "RTCPReceived":
+		if event["ReportCount"] == "" || event["ReportCount"] == "0" {
+			return false
+		}
+		q := cm.leg(channel, now)
+		// RTCPSent carries our report about the stream we receive, RTCPReceived
+		// carries the far end's report about the stream we transmit
+		stats := &q.Receive
+		if event.Event() == "RTCPReceived" {
+			stats = &q.Transmit
+			if rtt, err := strconv.ParseFloat(event["RTT"], 64); err == nil {
+				q.RTTMs = rtt * 1000
+			}
+		}
+		if fraction, err := strconv.ParseFloat(event["Report0FractionLost"], 64); err == nil {
+			stats.LossPercent = math.Round(fraction*1000/256) / 10
+		}
+		if lost, err := strconv.ParseUint(event["Report0CumulativeLost"], 10, 64); err == nil {
+			stats.Lost = lost
+		}
+		if jitter, err := strconv.ParseFloat(event["Report0IAJitter"], 64); err == nil {
+			stats.JitterMs = jitter * 1000 / rtpClockRate(q.Codec)
+		}
+		q.evaluate()
+		return true
+	case "Hangup":
+		if _, ok := cm.active[channel]; !ok {
+			return false
+		}
+		cm.finish(channel, now)
+		return true
+	}
+	return false
+}
+
+// sortedActive returns the active legs ordered by channel name
+func (cm *CallQualityMonitor) sortedActive() []*CallQuality {
+	active := make([]*CallQuality, 0, len(cm.active))
+	for _, q := range cm.active {
+		active = append(active, q)
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].Channel < active[j].Channel })
+	return active
+}
+
+// Report returns the active legs and the most recent finished legs, newest first
+func (cm *CallQualityMonitor) Report() *CallQualityReport {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	report := &CallQualityReport{GeneratedAt: time.Now(), Active: cm.sortedActive(), Recent: []*CallQuality{}}
+	for i := len(cm.history) - 1; i >= 0 && len(report.Recent) < callQualityRecentLimit; i-- {
+		report.Recent = append(report.Recent, cm.history[i])
+	}
+	for _, q := range append(report.Active, report.Recent...) {
+		if q.Poor {
+			report.PoorCalls++
+		}
+	}
+	return report
+}
+
+// CallQuality returns the call quality monitor, loading saved history on first use
+func (dm *DiagnosticsManager) CallQuality() *CallQualityMonitor {
+	if dm.callQuality == nil {
+		dm.callQuality = NewCallQualityMonitor(DefaultCallQualityFile)
+	}
+	return dm.callQuality
+}
+
+// CollectCallQuality reads "pjsip show channelstats", updates the monitor and returns a report
+func (dm *DiagnosticsManager) CollectCallQuality() (*CallQualityReport, error) {
+	output, err := dm.asterisk.ExecuteCLICommand("pjsip show channelstats")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read channel statistics: %v", err)
+	}
+	monitor := dm.CallQuality()
+	monitor.UpdateChannelStats(ParseChannelStats(output), time.Now())
+	if err := monitor.Save(); err != nil {
+		return monitor.Report(), err
+	}
+	return monitor.Report(), nil
+}
+
+// StartRTCPMonitor follows RTCP events over AMI in the background
+func (dm *DiagnosticsManager) StartRTCPMonitor(config *Config) error {
+	if dm.RTCPMonitorRunning() {
+		return nil
+	}
+	if config == nil || config.AMISecret == "" {
+		return fmt.Errorf("AMI credentials are not configured (ASTERISK_AMI_SECRET)")
+	}
+	client, err := DialAMI(config.AMIHost, config.AMIPort, config.AMIUsername, config.AMISecret, amiDialTimeout)
+	if err != nil {
+		return err
+	}
+
+	monitor := dm.CallQuality()
+	done := make(chan struct{})
+	dm.ami = client
+	dm.amiDone = done
+	go func() {
+		defer close(done)
+		for {
+			event, err := client.ReadMessage()
+			if err != nil {
+				return
+			}
+			monitor.HandleAMIEvent(event, time.Now())
+		}
+	}()
+	return nil
+}
+
+// RTCPMonitorRunning reports whether the AMI event stream is still connected
+func (dm *DiagnosticsManager) RTCPMonitorRunning() bool {
+	if dm.amiDone == nil {
+		return false
+	}
+	select {
+	case <-dm.amiDone:
+		return false
+	default:
+		return true
+	}
+}
+
+// StopRTCPMonitor disconnects from AMI
+func (dm *DiagnosticsManager) StopRTCPMonitor() {
+	if dm.ami != nil {
+		dm.ami.Close()
+		<-dm.amiDone
+	}
+	dm.ami = nil
+	dm.amiDone = nil
+}
+
+// initCallQuality opens the call quality screen
+func (m *model) initCallQuality() {
+	m.currentScreen = callQualityScreen
+	m.callQualityHistory = false
+	m.errorMsg = ""
+	m.successMsg = ""
+	m.callQualityNote = "RTCP events: connected to AMI"
+	if err := m.diagnosticsManager.StartRTCPMonitor(m.config); err != nil {
+		m.callQualityNote = fmt.Sprintf("RTCP events: unavailable (%v), using channelstats only", err)
+	}
+	m.refreshCallQuality()
+}
+
+// refreshCallQuality collects fresh statistics for the call quality screen
+func (m *model) refreshCallQuality() {
+	report, err := m.diagnosticsManager.CollectCallQuality()
+	if report != nil {
+		m.callQualityReport = report
+	}
+	if err != nil {
+		m.errorMsg = err.Error()
+		return
+	}
+	m.errorMsg = ""
+	m.successMsg = fmt.Sprintf("Updated at %s", report.GeneratedAt.Format("15:04:05"))
+}
+
+// handleCallQualityScreen handles keys on the call quality screen
+func (m *model) handleCallQualityScreen(msg tea.KeyMsg) (*model, tea.Cmd) {
+	switch msg.String() {
+	case "r":
+		m.refreshCallQuality()
+	case "h":
+		m.callQualityHistory = !m.callQualityHistory
+	case "esc":
+		m.diagnosticsManager.StopRTCPMonitor()
+		m.errorMsg = ""
+		m.successMsg = ""
+		m.currentScreen = diagnosticsMenuScreen
+		m.cursor = m.diagnosticsMenuCursor
+	case "q":
+		m.diagnosticsManager.StopRTCPMonitor()
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// renderCallQuality renders active or recent call legs
+func (m model) renderCallQuality() string {
+	content := infoStyle.Render("📶 Call Quality (RTP/RTCP)") + "\n\n"
+	content += helpStyle.Render(m.callQualityNote) + "\n\n"
+
+	report := m.callQualityReport
+	if report == nil {
+		content += "📭 No statistics collected\n"
+		return menuStyle.Render(content)
+	}
+
+	calls, title := report.Active, "Active calls"
+	if m.callQualityHistory {
+		calls, title = report.Recent, "Recent calls"
+	}
+	content += fmt.Sprintf("%s: %d • Poor calls: %d\n\n", title, len(calls), report.PoorCalls)
+	if len(calls) == 0 {
+		content += "📭 No calls\n"
+	}
+	for _, q := range calls {
+		line := q.Summary()
+		if q.Poor {
+			line = errorStyle.Render(line)
+		}
+		content += line + "\n"
+	}
+	content += "\n" + helpStyle.Render(fmt.Sprintf("Poor: MOS < %.1f, loss > %.0f%%, jitter > %.0fms or round trip > %.0fms",
+		poorMOSThreshold, poorLossPercent, poorJitterMs, poorRTTMs))
+
+	return menuStyle.Render(content)
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sampleChannelStats = `
+                                             ...........Receive......... .........Transmit..........
+ BridgeId ChannelId ........ UpTime.. Codec.   Count    Lost Pct  Jitter   Count    Lost Pct  Jitter RTT....
+ ===========================================================================================================
+
+ 0471a9a4 PJSIP/101-00000001 00:02:20 ulaw      7039       0   0   0.002    7044       0   0   0.003   0.020
+ 0471a9a4 PJSIP/102-00000002 00:02:20 ulaw      6500     540   7   0.045    7044       0   0   0.003   0.350
+          PJSIP/103-00000003 01:40:02 opus      300K      12   0   0.001    300K       0   0   0.001   0.010
+
+Objects found: 3
+`
+
+// TestEstimateMOS tests the E-model MOS estimate
+func TestEstimateMOS(t *testing.T) {
+	tests := []struct {
+		name          string
+		rtt, jit, pct float64
+		min, max      float64
+	}{
+		{"perfect", 0, 0, 0, 4.35, 4.45},
+		{"good LAN", 20, 5, 0.5, 4.1, 4.4},
+		{"heavy loss", 20, 5, 10, 1, 3.6},
+		{"high latency", 800, 40, 0, 1, 3.6},
+		{"total loss", 0, 0, 100, 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mos := EstimateMOS(tt.rtt, tt.jit, tt.pct)
+			if mos < tt.min || mos > tt.max {
+				t.Errorf("EstimateMOS(%v, %v, %v) = %v, expected between %v and %v", tt.rtt, tt.jit, tt.pct, mos, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+// TestParseChannelStats tests parsing pjsip show channelstats output
+func TestParseChannelStats(t *testing.T) {
+	stats := ParseChannelStats(sampleChannelStats)
+	if len(stats) != 3 {
+		t.Fatalf("Expected 3 legs, got %d", len(stats))
+	}
+
+	first := stats[0]
+	if first.Channel != "PJSIP/101-00000001" || first.Codec != "ulaw" || first.Uptime != "00:02:20" {
+		t.Errorf("Unexpected first leg: %+v", first)
+	}
+	if first.Receive.Packets != 7039 || first.Receive.JitterMs != 2 || first.Transmit.JitterMs != 3 || first.RTTMs != 20 {
+		t.Errorf("Unexpected first leg stats: %+v", first)
+	}
+	if stats[1].Receive.Lost != 540 || stats[1].Receive.LossPercent != 7.7 {
+		t.Errorf("Expected 540 lost packets (7.7%%), got %+v", stats[1].Receive)
+	}
+	// Unbridged channel with abbreviated counts
+	if stats[2].Channel != "PJSIP/103-00000003" || stats[2].Receive.Packets != 300000 {
+		t.Errorf("Unexpected third leg: %+v", stats[2])
+	}
+
+	if stats := ParseChannelStats("No objects found.\n"); len(stats) != 0 {
+		t.Errorf("Expected no legs, got %d", len(stats))
+	}
+}
+
+// TestCallQualityMonitor tests merging snapshots and RTCP events, history and persistence
+func TestCallQualityMonitor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "call-quality.json")
+	cm := NewCallQualityMonitor(path)
+	now := time.Now()
+
+	cm.UpdateChannelStats(ParseChannelStats(sampleChannelStats), now)
+	report := cm.Report()
+	if len(report.Active) != 3 || report.PoorCalls != 1 {
+		t.Fatalf("Expected 3 active legs with 1 poor, got %d and %d", len(report.Active), report.PoorCalls)
+	}
+	poor := report.Active[1]
+	if !poor.Poor || len(poor.Issues) != 4 || !strings.Contains(poor.Issues[0], "packet loss 7.7%") {
+		t.Errorf("Expected poor leg with loss, jitter, RTT and MOS issues, got %v", poor.Issues)
+	}
+
+	// The far end reports heavy loss and jitter for the stream we send on 101
+	used := cm.HandleAMIEvent(AMIMessage{
+		"Event":                 "RTCPReceived",
+		"Channel":               "PJSIP/101-00000001",
+		"ReportCount":           "1",
+		"RTT":                   "0.0400",
+		"Report0FractionLost":   "26",
+		"Report0CumulativeLost": "90",
+		"Report0IAJitter":       "480",
+	}, now.Add(time.Second))
+	if !used {
+		t.Fatal("Expected RTCPReceived event to be used")
+	}
+	leg := cm.Report().Active[0]
+	if leg.Transmit.LossPercent != 10.2 || leg.Transmit.JitterMs != 60 || leg.RTTMs != 40 || !leg.Poor {
+		t.Errorf("Unexpected leg after RTCP report: %+v", leg)
+	}
+	if cm.HandleAMIEvent(AMIMessage{"Event": "Newchannel", "Channel": "PJSIP/104-00000004"}, now) {
+		t.Error("Expected unrelated event to be ignored")
+	}
+
+	// Hangup and a snapshot without 102 both finish legs
+	cm.HandleAMIEvent(AMIMessage{"Event": "Hangup", "Channel": "PJSIP/103-00000003"}, now.Add(2*time.Second))
+	cm.UpdateChannelStats(ParseChannelStats(sampleChannelStats)[:1], now.Add(3*time.Second))
+	report = cm.Report()
+	if len(report.Active) != 1 || len(report.Recent) != 2 {
+		t.Fatalf("Expected 1 active and 2 recent legs, got %d and %d", len(report.Active), len(report.Recent))
+	}
+	if report.Recent[0].Channel != "PJSIP/102-00000002" || report.Recent[0].EndedAt.IsZero() {
+		t.Errorf("Expected most recently ended leg first, got %+v", report.Recent[0])
+	}
+	// Flagged legs stay poor after later good samples
+	if !report.Active[0].Poor || report.Active[0].MinMOS >= poorMOSThreshold {
+		t.Errorf("Expected leg to stay flagged, got %+v", report.Active[0])
+	}
+
+	if err := cm.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded := NewCallQualityMonitor(path).Report()
+	if len(loaded.Active) != 1 || len(loaded.Recent) != 2 || loaded.PoorCalls != 2 {
+		t.Errorf("Unexpected reloaded report: %d active, %d recent, %d poor", len(loaded.Active), len(loaded.Recent), loaded.PoorCalls)
+	}
+}
+
+// TestDialAMI tests logging in and reading events from a fake AMI server
+func TestDialAMI(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				conn.Write([]byte("Asterisk Call Manager/7.0.3\r\n"))
+				login, err := readAMIMessage(bufio.NewReader(conn))
+				if err != nil {
+					return
+				}
+				if login["Secret"] != "secret" {
+					conn.Write([]byte("Response: Error\r\nMessage: Authentication failed\r\n\r\n"))
+					return
+				}
+				conn.Write([]byte("Event: FullyBooted\r\nStatus: Fully Booted\r\n\r\n"))
+				conn.Write([]byte("Response: Success\r\nMessage: Authentication accepted\r\n\r\n"))
+				conn.Write([]byte("Event: RTCPSent\r\nChannel: PJSIP/101-00000001\r\nReportCount: 1\r\n\r\n"))
+				time.Sleep(100 * time.Millisecond)
+			}(conn)
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	client, err := DialAMI(host, port, "admin", "secret", time.Second)
+	if err != nil {
+		t.Fatalf("DialAMI failed: %v", err)
+	}
+	event, err := client.ReadMessage()
+	if err != nil || event.Event() != "RTCPSent" || event["Channel"] != "PJSIP/101-00000001" {
+		t.Errorf("Unexpected event %v: %v", event, err)
+	}
+	client.Close()
+
+	if _, err := DialAMI(host, port, "admin", "wrong", time.Second); err == nil || !strings.Contains(err.Error(), "Authentication failed") {
+		t.Errorf("Expected login failure, got %v", err)
+	}
+}
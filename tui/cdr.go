@@ -0,0 +1,704 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// DefaultCDRCSVPath is where cdr_csv writes call records
+const DefaultCDRCSVPath = "/var/log/asterisk/cdr-csv/Master.csv"
+
+// cdrTimeFormat is the timestamp layout used by cdr_csv and the MySQL cdr table
+const cdrTimeFormat = "2006-01-02 15:04:05"
+
+// DefaultCDRLimit caps how many records are loaded at once
+const DefaultCDRLimit = 5000
+
+// cdrPageSize is the number of records shown per page in the browser
+const cdrPageSize = 15
+
+// CDR filter form field indices
+const (
+	cdrFieldFrom = iota
+	cdrFieldTo
+	cdrFieldSource
+	cdrFieldDestination
+	cdrFieldDisposition
+	cdrFieldTrunk
+	cdrFieldExport
+)
+
+// CDRRecord is one call detail record
+type CDRRecord struct {
+	CallDate    time.Time `json:"calldate"`
+	CLID        string    `json:"clid"`
+	Src         string    `json:"src"`
+	Dst         string    `json:"dst"`
+	DContext    string    `json:"dcontext"`
+	Channel     string    `json:"channel"`
+	DstChannel  string    `json:"dstchannel"`
+	LastApp     string    `json:"lastapp"`
+	LastData    string    `json:"lastdata"`
+	Duration    int       `json:"duration"`
+	BillSec     int       `json:"billsec"`
+	Disposition string    `json:"disposition"`
+	AccountCode string    `json:"accountcode"`
+	UniqueID    string    `json:"uniqueid"`
+}
+
+// Answered returns true if the call was answered
+func (r CDRRecord) Answered() bool {
+	return strings.EqualFold(r.Disposition, "ANSWERED")
+}
+
+// CDRFilter selects call records; empty fields match everything
+type CDRFilter struct {
+	From        time.Time // Inclusive start, zero for no bound
+	To          time.Time // Exclusive end, zero for no bound
+	Source      string    // Substring of the caller number
+	Destination string    // Substring of the dialed number
+	Disposition string    // ANSWERED, NO ANSWER, BUSY, FAILED or CONGESTION
+	Trunk       string    // Endpoint name on either leg of the call
+}
+
+// ParseCDRFilter builds a filter from form or command line values.
+// Dates are YYYY-MM-DD or YYYY-MM-DD HH:MM; a date-only end includes the whole day.
+func ParseCDRFilter(from, to, source, destination, disposition, trunk string) (CDRFilter, error) {
+	filter := CDRFilter{
+		Source:      strings.TrimSpace(source),
+		Destination: strings.TrimSpace(destination),
+		Disposition: strings.TrimSpace(disposition),
+		Trunk:       strings.TrimSpace(trunk),
+	}
+	var err error
+	if filter.From, err = parseCDRDate(from, false); err != nil {
+		return filter, err
+	}
+	if filter.To, err = parseCDRDate(to, true); err != nil {
+		return filter, err
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return filter, fmt.Errorf("start date must be before end date")
+	}
+	if filter.Disposition != "" {
+		if _, ok := cdrDispositions[normalizeDisposition(filter.Disposition)]; !ok {
+			return filter, fmt.Errorf("unknown disposition %q (use answered, no answer, busy, failed or congestion)", filter.Disposition)
+		}
+	}
+	return filter, nil
+}
+
+// cdrDispositions maps normalized dispositions to the values Asterisk writes
+var cdrDispositions = map[string]string{
+	"ANSWERED":   "ANSWERED",
+	"NOANSWER":   "NO ANSWER",
+	"BUSY":       "BUSY",
+	"FAILED":     "FAILED",
+	"CONGESTION": "CONGESTION",
+}
+
+// normalizeDisposition upper-cases a disposition and drops spaces and underscores
+func normalizeDisposition(s string) string {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	return strings.NewReplacer(" ", "", "_", "").Replace(s)
+}
+
+// parseCDRDate parses a filter date in local time; a date-only end is moved to the next day
+func parseCDRDate(s string, end bool) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		if end {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04", cdrTimeFormat} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q (use YYYY-MM-DD or YYYY-MM-DD HH:MM)", s)
+}
+
+// channelEndpoint returns the endpoint of a channel name, e.g. 101 for PJSIP/101-00000001
+func channelEndpoint(channel string) string {
+	slash := strings.Index(channel, "/")
+	if slash < 0 {
+		return ""
+	}
+	name := channel[slash+1:]
+	if i := strings.LastIndex(name, "-"); i > 0 {
+		name = name[:i]
+	}
+	if i := strings.Index(name, "/"); i > 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// channelEndpointSQL is channelEndpoint as a MySQL expression over a channel column
+func channelEndpointSQL(column string) string {
+	name := fmt.Sprintf("SUBSTRING(%s, LOCATE('/', %s) + 1)", column, column)
+	// Index of the last dash, or the length of name when it has none
+	dash := fmt.Sprintf("(CHAR_LENGTH(%s) - LOCATE('-', REVERSE(%s)))", name, name)
+	trimmed := fmt.Sprintf("IF(%s > 0, LEFT(%s, %s), %s)", dash, name, dash, name)
+	endpoint := fmt.Sprintf("IF(LOCATE('/', %s) > 1, SUBSTRING_INDEX(%s, '/', 1), %s)", trimmed, trimmed, trimmed)
+	return fmt.Sprintf("IF(LOCATE('/', %s) > 0, %s, '')", column, endpoint)
+}
+
+// escapeLike escapes the LIKE wildcards in s, for patterns using ESCAPE '!'
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
+
+// Matches returns true if the record passes the filter
+func (f CDRFilter) Matches(r CDRRecord) bool {
+	if !f.From.IsZero() && r.CallDate.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !r.CallDate.Before(f.To) {
+		return false
+	}
+	if f.Source != "" && !strings.Contains(r.Src, f.Source) {
+		return false
+	}
+	if f.Destination != "" && !strings.Contains(r.Dst, f.Destination) {
+		return false
+	}
+	if f.Disposition != "" && normalizeDisposition(r.Disposition) != normalizeDisposition(f.Disposition) {
+		return false
+	}
+	if f.Trunk != "" && !strings.EqualFold(channelEndpoint(r.Channel), f.Trunk) && !strings.EqualFold(channelEndpoint(r.DstChannel), f.Trunk) {
+		return false
+	}
+	return true
+}
+
+// whereClause builds the SQL condition and arguments for the cdr table
+func (f CDRFilter) whereClause() (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	if !f.From.IsZero() {
+		conds = append(conds, "calldate >= ?")
+		args = append(args, f.From.Format(cdrTimeFormat))
+	}
+	if !f.To.IsZero() {
+		conds = append(conds, "calldate < ?")
+		args = append(args, f.To.Format(cdrTimeFormat))
+	}
+	if f.Source != "" {
+		conds = append(conds, "src LIKE ? ESCAPE '!'")
+		args = append(args, "%"+escapeLike(f.Source)+"%")
+	}
+	if f.Destination != "" {
+		conds = append(conds, "dst LIKE ? ESCAPE '!'")
+		args = append(args, "%"+escapeLike(f.Destination)+"%")
+	}
+	if f.Disposition != "" {
+		conds = append(conds, "disposition = ?")
+		args = append(args, cdrDispositions[normalizeDisposition(f.Disposition)])
+	}
+	if f.Trunk != "" {
+		conds = append(conds, fmt.Sprintf("(LOWER(%s) = LOWER(?) OR LOWER(%s) = LOWER(?))",
+			channelEndpointSQL("channel"), channelEndpointSQL("dstchannel")))
+		args = append(args, f.Trunk, f.Trunk)
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// QueryCDRs reads the newest matching records from the MySQL cdr table. The WHERE clause
+// matches exactly what CDRFilter.Matches does, so LIMIT gives full pages.
+func QueryCDRs(db *sql.DB, filter CDRFilter, limit int) ([]CDRRecord, error) {
+	where, args := filter.whereClause()
+	query := "SELECT calldate, clid, src, dst, dcontext, channel, dstchannel, lastapp, lastdata, duration, billsec, disposition, accountcode, uniqueid FROM cdr" +
+		where + " ORDER BY calldate DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cdr table: %v", err)
+	}
+	defer rows.Close()
+
+	var records []CDRRecord
+	for rows.Next() {
+		var r CDRRecord
+		if err := rows.Scan(&r.CallDate, &r.CLID, &r.Src, &r.Dst, &r.DContext, &r.Channel, &r.DstChannel,
+			&r.LastApp, &r.LastData, &r.Duration, &r.BillSec, &r.Disposition, &r.AccountCode, &r.UniqueID); err != nil {
+			return nil, fmt.Errorf("failed to read cdr row: %v", err)
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// cdrCSVPath returns the Master.csv path, overridable with ASTERISK_CDR_CSV
+func cdrCSVPath() string {
+	if path := os.Getenv("ASTERISK_CDR_CSV"); path != "" {
+		return path
+	}
+	return DefaultCDRCSVPath
+}
+
+// ReadMasterCSV reads matching records from a cdr_csv Master.csv file, newest first
+func ReadMasterCSV(path string, filter CDRFilter, limit int) ([]CDRRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+	return parseMasterCSV(f, filter, limit)
+}
+
+// parseMasterCSV parses cdr_csv rows: accountcode, src, dst, dcontext, clid, channel,
+// dstchannel, lastapp, lastdata, start, answer, end, duration, billsec, disposition,
+// amaflags and optionally uniqueid and userfield
+func parseMasterCSV(r io.Reader, filter CDRFilter, limit int) ([]CDRRecord, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	var records []CDRRecord
+	line := 0
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CDR line %d: %v", line, err)
+		}
+		if len(fields) < 15 {
+			continue
+		}
+		start, err := time.ParseInLocation(cdrTimeFormat, fields[9], time.Local)
+		if err != nil {
+			continue
+		}
+		duration, _ := strconv.Atoi(fields[12])
+		billsec, _ := strconv.Atoi(fields[13])
+		record := CDRRecord{
+			AccountCode: fields[0],
+			Src:         fields[1],
+			Dst:         fields[2],
+			DContext:    fields[3],
+			CLID:        fields[4],
+			Channel:     fields[5],
+			DstChannel:  fields[6],
+			LastApp:     fields[7],
+			LastData:    fields[8],
+			CallDate:    start,
+			Duration:    duration,
+			BillSec:     billsec,
+			Disposition: fields[14],
+		}
+		if len(fields) > 16 {
+			record.UniqueID = fields[16]
+		}
+		if filter.Matches(record) {
+			records = append(records, record)
+		}
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].CallDate.After(records[j].CallDate)
+	})
+	if limit > 0 && len(records) > limit {
+		records = records[:limit]
+	}
+	return records, nil
+}
+
+// LoadCDRs reads records from the cdr table, falling back to Master.csv.
+// It returns the records and a description of the source they came from.
+func LoadCDRs(db *sql.DB, filter CDRFilter, csvPath string, limit int) ([]CDRRecord, string, error) {
+	var dbErr error
+	if db != nil {
+		records, err := QueryCDRs(db, filter, limit)
+		if err == nil {
+			return records, "database cdr table", nil
+		}
+		dbErr = err
+	}
+	records, err := ReadMasterCSV(csvPath, filter, limit)
+	if err != nil {
+		if dbErr != nil {
+			return nil, "", fmt.Errorf("%v; %v", dbErr, err)
+		}
+		return nil, "", err
+	}
+	return records, csvPath, nil
+}
+
+// CDRExtensionSummary is the call total of one extension
+type CDRExtensionSummary struct {
+	Extension  string  `json:"extension"`
+	Calls      int     `json:"calls"`
+	Answered   int     `json:"answered"`
+	Minutes    float64 `json:"minutes"`
+	AnswerRate float64 `json:"answer_rate"`
+}
+
+// cdrParticipants returns the extensions on either leg of a call. Without a list
+// of known extensions, numeric endpoints are treated as extensions.
+func cdrParticipants(r CDRRecord, known map[string]bool) []string {
+	var result []string
+	for _, channel := range []string{r.Channel, r.DstChannel} {
+		endpoint := channelEndpoint(channel)
+		if endpoint == "" {
+			continue
+		}
+		if len(known) > 0 {
+			if !known[endpoint] {
+				continue
+			}
+		} else if _, err := strconv.Atoi(endpoint); err != nil {
+			continue
+		}
+		if len(result) == 0 || result[0] != endpoint {
+			result = append(result, endpoint)
+		}
+	}
+	return result
+}
+
+// SummarizeCDRs totals calls, talk minutes and answer rate per extension
+func SummarizeCDRs(records []CDRRecord, knownExtensions []string) []CDRExtensionSummary {
+	known := make(map[string]bool, len(knownExtensions))
+	for _, ext := range knownExtensions {
+		known[ext] = true
+	}
+
+	totals := make(map[string]*CDRExtensionSummary)
+	for _, r := range records {
+		for _, ext := range cdrParticipants(r, known) {
+			s, ok := totals[ext]
+			if !ok {
+				s = &CDRExtensionSummary{Extension: ext}
+				totals[ext] = s
+			}
+			s.Calls++
+			if r.Answered() {
+				s.Answered++
+			}
+			s.Minutes += float64(r.BillSec) / 60
+		}
+	}
+
+	summary := make([]CDRExtensionSummary, 0, len(totals))
+	for _, s := range totals {
+		s.AnswerRate = float64(s.Answered) / float64(s.Calls) * 100
+		summary = append(summary, *s)
+	}
+	sort.Slice(summary, func(i, j int) bool {
+		return summary[i].Extension < summary[j].Extension
+	})
+	return summary
+}
+
+// cdrCSVHeader is the column order of exported CDR files
+var cdrCSVHeader = []string{"calldate", "clid", "src", "dst", "dcontext", "channel", "dstchannel", "lastapp", "lastdata", "duration", "billsec", "disposition", "accountcode", "uniqueid"}
+
+// ExportCDRsCSV writes records as CSV with a header row
+func ExportCDRsCSV(w io.Writer, records []CDRRecord) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(cdrCSVHeader); err != nil {
+		return err
+	}
+	for _, r := range records {
+		if err := writer.Write([]string{
+			r.CallDate.Format(cdrTimeFormat), r.CLID, r.Src, r.Dst, r.DContext, r.Channel, r.DstChannel,
+			r.LastApp, r.LastData, strconv.Itoa(r.Duration), strconv.Itoa(r.BillSec), r.Disposition,
+			r.AccountCode, r.UniqueID,
+		}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// ExportCDRsFile writes records to a CSV file
+func ExportCDRsFile(path string, records []CDRRecord) error {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %v", err)
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", path, err)
+	}
+	if err := ExportCDRsCSV(f, records); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return f.Close()
+}
+
+// formatBillSec renders a talk time in seconds as m:ss
+func formatBillSec(seconds int) string {
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
+
+// initCDRBrowser opens the call history filter form
+func (m *model) initCDRBrowser() {
+	m.currentScreen = cdrScreen
+	m.inputMode = true
+	m.inputFields = []string{
+		"From (YYYY-MM-DD)",
+		"To (YYYY-MM-DD)",
+		"Source",
+		"Destination",
+		"Disposition",
+		"Trunk",
+		"Export file",
+	}
+	if len(m.inputValues) != len(m.inputFields) || m.cdrRecords == nil {
+		m.inputValues = []string{
+			time.Now().AddDate(0, 0, -7).Format("2006-01-02"),
+			time.Now().Format("2006-01-02"),
+			"", "", "", "",
+			"/tmp/rayanpbx-cdr.csv",
+		}
+	}
+	m.inputCursor = 0
+	m.errorMsg = ""
+	m.successMsg = ""
+}
+
+// loadCDRs applies the filter form and loads matching call records
+func (m *model) loadCDRs() {
+	v := m.inputValues
+	filter, err := ParseCDRFilter(v[cdrFieldFrom], v[cdrFieldTo], v[cdrFieldSource], v[cdrFieldDestination], v[cdrFieldDisposition], v[cdrFieldTrunk])
+	if err != nil {
+		m.errorMsg = err.Error()
+		return
+	}
+	records, source, err := LoadCDRs(m.db, filter, cdrCSVPath(), DefaultCDRLimit)
+	if err != nil {
+		m.errorMsg = err.Error()
+		return
+	}
+
+	if len(m.extensions) == 0 && m.db != nil {
+		if exts, err := GetExtensions(m.db); err == nil {
+			m.extensions = exts
+		}
+	}
+	var known []string
+	for _, ext := range m.extensions {
+		known = append(known, ext.ExtensionNumber)
+	}
+	m.cdrRecords = records
+	m.cdrSummary = SummarizeCDRs(records, known)
+	m.cdrSource = source
+	m.cdrExportPath = strings.TrimSpace(v[cdrFieldExport])
+	m.selectedCDRIdx = 0
+	m.cdrSummaryView = false
+	m.inputMode = false
+	m.errorMsg = ""
+	m.successMsg = fmt.Sprintf("Loaded %d call records from %s", len(records), source)
+}
+
+// exportCDRs writes the loaded records to the export file from the filter form
+func (m *model) exportCDRs() {
+	if m.cdrExportPath == "" {
+		m.errorMsg = "Export file is required, press f to set it"
+		return
+	}
+	if err := ExportCDRsFile(m.cdrExportPath, m.cdrRecords); err != nil {
+		m.errorMsg = err.Error()
+		return
+	}
+	m.errorMsg = ""
+	m.successMsg = fmt.Sprintf("Exported %d call records to %s", len(m.cdrRecords), m.cdrExportPath)
+}
+
+// handleCDRScreen processes keys while browsing call records
+func (m *model) handleCDRScreen(msg tea.KeyMsg) (*model, tea.Cmd) {
+	count := len(m.cdrRecords)
+	if m.cdrSummaryView {
+		count = len(m.cdrSummary)
+	}
+
+	switch msg.String() {
+	case "up", "k":
+		if m.selectedCDRIdx > 0 {
+			m.selectedCDRIdx--
+		} else if count > 0 {
+			m.selectedCDRIdx = count - 1
+		}
+	case "down", "j":
+		if m.selectedCDRIdx < count-1 {
+			m.selectedCDRIdx++
+		} else {
+			m.selectedCDRIdx = 0
+		}
+	case "pgup", "left":
+		m.selectedCDRIdx -= cdrPageSize
+		if m.selectedCDRIdx < 0 {
+			m.selectedCDRIdx = 0
+		}
+	case "pgdown", "right":
+		m.selectedCDRIdx += cdrPageSize
+		if m.selectedCDRIdx > count-1 {
+			m.selectedCDRIdx = count - 1
+		}
+		if m.selectedCDRIdx < 0 {
+			m.selectedCDRIdx = 0
+		}
+	case "s":
+		m.cdrSummaryView = !m.cdrSummaryView
+		m.selectedCDRIdx = 0
+	case "f":
+		// Change the filter
+		m.inputMode = true
+		m.inputCursor = 0
+	case "x":
+		m.exportCDRs()
+	case "r":
+		m.loadCDRs()
+	case "esc":
+		m.currentScreen = mainMenu
+		m.errorMsg = ""
+		m.successMsg = ""
+	case "q":
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// cdrPage returns the bounds of the page containing the selected row
+func cdrPage(selected, count int) (start, end, page, pages int) {
+	pages = (count + cdrPageSize - 1) / cdrPageSize
+	if pages == 0 {
+		pages = 1
+	}
+	page = selected / cdrPageSize
+	start = page * cdrPageSize
+	end = start + cdrPageSize
+	if end > count {
+		end = count
+	}
+	return start, end, page + 1, pages
+}
+
+// renderCDRBrowser renders the call history filter form, record list or summary
+func (m model) renderCDRBrowser() string {
+	content := infoStyle.Render("📒 Call History (CDR)") + "\n\n"
+
+	if m.inputMode {
+		fieldHelp := map[int]string{
+			cdrFieldFrom:        "First day to include, empty for no limit",
+			cdrFieldTo:          "Last day to include, empty for no limit",
+			cdrFieldSource:      "Caller number contains this text",
+			cdrFieldDestination: "Dialed number contains this text",
+			cdrFieldDisposition: "answered, no answer, busy, failed or congestion",
+			cdrFieldTrunk:       "Trunk endpoint name on either leg of the call",
+			cdrFieldExport:      "CSV file written when pressing x",
+		}
+		for i, field := range m.inputFields {
+			cursor := "  "
+			fieldStyle := lipgloss.NewStyle()
+			if i == m.inputCursor {
+				cursor = "▶ "
+				fieldStyle = selectedItemStyle
+			}
+			value := m.inputValues[i]
+			if value == "" {
+				value = helpStyle.Render("<empty>")
+			}
+			content += fmt.Sprintf("%s%s: %s\n", cursor, fieldStyle.Render(field), value)
+			if i == m.inputCursor {
+				content += helpStyle.Render(fmt.Sprintf("   💡 %s", fieldHelp[i])) + "\n"
+			}
+		}
+		content += "\n" + helpStyle.Render(fmt.Sprintf("Records are read from the cdr table, or %s if it is unavailable", cdrCSVPath())) + "\n"
+		return menuStyle.Render(content)
+	}
+
+	if m.cdrSummaryView {
+		return menuStyle.Render(content + m.renderCDRSummary())
+	}
+
+	if len(m.cdrRecords) == 0 {
+		content += "📭 No calls match the filter\n"
+		return menuStyle.Render(content)
+	}
+
+	start, end, page, pages := cdrPage(m.selectedCDRIdx, len(m.cdrRecords))
+	content += fmt.Sprintf("Total Calls: %s (page %d of %d)\n\n", successStyle.Render(strconv.Itoa(len(m.cdrRecords))), page, pages)
+	content += helpStyle.Render(fmt.Sprintf("  %-19s  %-12s  %-12s  %-10s  %6s  %s", "Date", "Source", "Destination", "Disposition", "Talk", "Trunk/Channel")) + "\n"
+	for i := start; i < end; i++ {
+		r := m.cdrRecords[i]
+		cursor := " "
+		if i == m.selectedCDRIdx {
+			cursor = "▶"
+		}
+		disposition := r.Disposition
+		if r.Answered() {
+			disposition = successStyle.Render(fmt.Sprintf("%-10s", disposition))
+		} else {
+			disposition = errorStyle.Render(fmt.Sprintf("%-10s", disposition))
+		}
+		channel := channelEndpoint(r.DstChannel)
+		if channel == "" {
+			channel = channelEndpoint(r.Channel)
+		}
+		content += fmt.Sprintf("%s %-19s  %-12s  %-12s  %s  %6s  %s\n",
+			cursor, r.CallDate.Format(cdrTimeFormat), r.Src, r.Dst, disposition, formatBillSec(r.BillSec), channel)
+	}
+
+	if m.selectedCDRIdx < len(m.cdrRecords) {
+		r := m.cdrRecords[m.selectedCDRIdx]
+		content += "\n" + helpStyle.Render(fmt.Sprintf("%s • %s → %s • %s(%s) • duration %ds",
+			r.CLID, r.Channel, r.DstChannel, r.LastApp, r.LastData, r.Duration)) + "\n"
+	}
+	return menuStyle.Render(content)
+}
+
+// renderCDRSummary renders the per-extension call totals
+func (m model) renderCDRSummary() string {
+	if len(m.cdrSummary) == 0 {
+		return "📭 No extension calls in the selected records\n"
+	}
+	start, end, page, pages := cdrPage(m.selectedCDRIdx, len(m.cdrSummary))
+	content := fmt.Sprintf("Summary by Extension (page %d of %d)\n\n", page, pages)
+	content += helpStyle.Render(fmt.Sprintf("  %-10s  %6s  %8s  %9s  %s", "Extension", "Calls", "Answered", "Minutes", "Answer Rate")) + "\n"
+	for i := start; i < end; i++ {
+		s := m.cdrSummary[i]
+		cursor := " "
+		if i == m.selectedCDRIdx {
+			cursor = "▶"
+		}
+		content += fmt.Sprintf("%s %-10s  %6d  %8d  %9.1f  %.0f%%\n", cursor, successStyle.Render(fmt.Sprintf("%-10s", s.Extension)), s.Calls, s.Answered, s.Minutes, s.AnswerRate)
+	}
+	return content
+}
+
+// cdrHelp returns the key help for the call history screen
+func (m model) cdrHelp() string {
+	if m.inputMode {
+		return "↑/↓: Navigate Fields • Enter: Next/Load • ESC: Back to Main Menu • q: Quit"
+	}
+	return "↑/↓: Navigate • ←/→: Page • s: Records/Summary • f: Filter • x: Export CSV • r: Reload • ESC: Back to Main Menu • q: Quit"
+}
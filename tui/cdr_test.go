@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// testMasterCSV is a cdr_csv Master.csv sample with internal, outbound and failed calls
+const testMasterCSV = `"","101","102","from-internal","""Alice"" <101>","PJSIP/101-00000001","PJSIP/102-00000002","Dial","PJSIP/102,30","2024-05-01 09:00:00","2024-05-01 09:00:05","2024-05-01 09:02:05",125,120,"ANSWERED","DOCUMENTATION","1714554000.1",""
+"","102","101","from-internal","""Bob"" <102>","PJSIP/102-00000003","PJSIP/101-00000004","Dial","PJSIP/101,30","2024-05-01 10:00:00","","2024-05-01 10:00:30",30,0,"NO ANSWER","DOCUMENTATION","1714557600.3",""
+"","101","09121234567","from-internal","""Alice"" <101>","PJSIP/101-00000005","PJSIP/provider-00000006","Dial","PJSIP/09121234567@provider","2024-05-02 11:00:00","2024-05-02 11:00:10","2024-05-02 11:05:10",310,300,"ANSWERED","DOCUMENTATION","1714647600.5",""
+"","103","09121234567","from-internal","""Carol"" <103>","PJSIP/103-00000007","","Dial","PJSIP/09121234567@provider","2024-05-03 12:00:00","","2024-05-03 12:00:02",2,0,"FAILED","DOCUMENTATION","1714737600.7",""
+"","bad","row"
+`
+
+// writeTestMasterCSV writes the sample Master.csv to a temporary directory
+func writeTestMasterCSV(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "Master.csv")
+	if err := os.WriteFile(path, []byte(testMasterCSV), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestReadMasterCSV tests parsing and filtering cdr_csv records
+func TestReadMasterCSV(t *testing.T) {
+	path := writeTestMasterCSV(t)
+	records, err := ReadMasterCSV(path, CDRFilter{}, 0)
+	if err != nil {
+		t.Fatalf("ReadMasterCSV failed: %v", err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("Expected 4 records, got %d", len(records))
+	}
+	first := records[0]
+	if first.Src != "103" || first.Disposition != "FAILED" || first.UniqueID != "1714737600.7" {
+		t.Errorf("Expected newest record first, got %+v", first)
+	}
+	if last := records[3]; last.CLID != `"Alice" <101>` || last.BillSec != 120 || last.Duration != 125 || last.LastData != "PJSIP/102,30" {
+		t.Errorf("Unexpected oldest record: %+v", last)
+	}
+
+	tests := []struct {
+		name  string
+		from  string
+		to    string
+		src   string
+		dst   string
+		disp  string
+		trunk string
+		want  []string
+	}{
+		{"date range", "2024-05-01", "2024-05-01", "", "", "", "", []string{"1714557600.3", "1714554000.1"}},
+		{"time range", "2024-05-01 09:30", "", "", "", "", "", []string{"1714737600.7", "1714647600.5", "1714557600.3"}},
+		{"source", "", "", "101", "", "", "", []string{"1714647600.5", "1714554000.1"}},
+		{"destination", "", "", "", "0912", "", "", []string{"1714737600.7", "1714647600.5"}},
+		{"disposition", "", "", "", "", "no_answer", "", []string{"1714557600.3"}},
+		{"trunk", "", "", "", "", "", "PROVIDER", []string{"1714647600.5"}},
+		{"combined", "2024-05-02", "2024-05-03", "", "0912", "answered", "", []string{"1714647600.5"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := ParseCDRFilter(tt.from, tt.to, tt.src, tt.dst, tt.disp, tt.trunk)
+			if err != nil {
+				t.Fatalf("ParseCDRFilter failed: %v", err)
+			}
+			records, err := ReadMasterCSV(path, filter, 0)
+			if err != nil {
+				t.Fatalf("ReadMasterCSV failed: %v", err)
+			}
+			var got []string
+			for _, r := range records {
+				got = append(got, r.UniqueID)
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	if records, _ := ReadMasterCSV(path, CDRFilter{}, 2); len(records) != 2 || records[0].UniqueID != "1714737600.7" {
+		t.Errorf("Expected the 2 newest records, got %+v", records)
+	}
+	if _, err := ReadMasterCSV(filepath.Join(t.TempDir(), "missing.csv"), CDRFilter{}, 0); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
+
+// TestParseCDRFilter tests filter validation and the SQL it produces
+func TestParseCDRFilter(t *testing.T) {
+	for _, args := range [][]string{
+		{"yesterday", "", "", "", "", ""},
+		{"2024-05-03", "2024-05-01", "", "", "", ""},
+		{"", "", "", "", "hungup", ""},
+	} {
+		if _, err := ParseCDRFilter(args[0], args[1], args[2], args[3], args[4], args[5]); err == nil {
+			t.Errorf("Expected error for %v", args)
+		}
+	}
+
+	filter, err := ParseCDRFilter("2024-05-01", "2024-05-01", "101", "", "no answer", "provider")
+	if err != nil {
+		t.Fatalf("ParseCDRFilter failed: %v", err)
+	}
+	where, args := filter.whereClause()
+	trunk := "(LOWER(" + channelEndpointSQL("channel") + ") = LOWER(?) OR LOWER(" + channelEndpointSQL("dstchannel") + ") = LOWER(?))"
+	if where != " WHERE calldate >= ? AND calldate < ? AND src LIKE ? ESCAPE '!' AND disposition = ? AND "+trunk {
+		t.Errorf("Unexpected where clause: %s", where)
+	}
+	if len(args) != 6 || args[1] != "2024-05-02 00:00:00" || args[2] != "%101%" || args[3] != "NO ANSWER" || args[4] != "provider" {
+		t.Errorf("Unexpected arguments: %v", args)
+	}
+	// LIKE wildcards typed by the user match literally
+	if _, args := (CDRFilter{Destination: "9_%!"}).whereClause(); args[0] != "%9!_!%!!%" {
+		t.Errorf("Expected escaped wildcards, got %v", args)
+	}
+	if where, args := (CDRFilter{}).whereClause(); where != "" || args != nil {
+		t.Errorf("Expected no where clause for an empty filter, got %q", where)
+	}
+
+	channels := map[string]string{
+		"PJSIP/101-00000001":                 "101",
+		"PJSIP/my-trunk-0000000a":            "my-trunk",
+		"SIP/provider/0912-0001":             "provider",
+		"Local/101@from-internal-00000001;1": "101@from-internal",
+		"":                                   "",
+	}
+	for channel, want := range channels {
+		if got := channelEndpoint(channel); got != want {
+			t.Errorf("channelEndpoint(%q) = %q, want %q", channel, got, want)
+		}
+	}
+}
+
+// TestSummarizeCDRs tests per-extension totals and CSV export
+func TestSummarizeCDRs(t *testing.T) {
+	records, err := ReadMasterCSV(writeTestMasterCSV(t), CDRFilter{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	summary := SummarizeCDRs(records, nil)
+	if len(summary) != 3 {
+		t.Fatalf("Expected 3 extensions, got %+v", summary)
+	}
+	alice := summary[0]
+	if alice.Extension != "101" || alice.Calls != 3 || alice.Answered != 2 || alice.Minutes != 7 || int(alice.AnswerRate) != 66 {
+		t.Errorf("Unexpected summary for 101: %+v", alice)
+	}
+	if carol := summary[2]; carol.Extension != "103" || carol.Calls != 1 || carol.AnswerRate != 0 {
+		t.Errorf("Unexpected summary for 103: %+v", carol)
+	}
+	if known := SummarizeCDRs(records, []string{"102", "provider"}); len(known) != 2 || known[0].Extension != "102" || known[1].Calls != 1 {
+		t.Errorf("Expected only known extensions, got %+v", known)
+	}
+
+	var buf bytes.Buffer
+	if err := ExportCDRsCSV(&buf, records[3:]); err != nil {
+		t.Fatalf("ExportCDRsCSV failed: %v", err)
+	}
+	want := "calldate,clid,src,dst,dcontext,channel,dstchannel,lastapp,lastdata,duration,billsec,disposition,accountcode,uniqueid\n" +
+		`2024-05-01 09:00:00,"""Alice"" <101>",101,102,from-internal,PJSIP/101-00000001,PJSIP/102-00000002,Dial,"PJSIP/102,30",125,120,ANSWERED,,1714554000.1` + "\n"
+	if buf.String() != want {
+		t.Errorf("Unexpected CSV:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+// TestCDRBrowser tests loading, paging, summary and export in the TUI
+func TestCDRBrowser(t *testing.T) {
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "Master.csv")
+	var rows strings.Builder
+	for i := 0; i < 20; i++ {
+		rows.WriteString(strings.Replace(strings.SplitN(testMasterCSV, "\n", 2)[0], "1714554000.1", "id"+string(rune('a'+i)), 1) + "\n")
+	}
+	os.WriteFile(csvPath, []byte(rows.String()), 0644)
+	t.Setenv("ASTERISK_CDR_CSV", csvPath)
+
+	m := initialModel(nil, nil, false)
+	m.initCDRBrowser()
+	if m.currentScreen != cdrScreen || !m.inputMode || len(m.inputValues) != len(m.inputFields) {
+		t.Fatalf("Expected filter form, got screen %d", m.currentScreen)
+	}
+	m.inputValues[cdrFieldFrom] = ""
+	m.inputValues[cdrFieldTo] = ""
+	m.inputValues[cdrFieldExport] = filepath.Join(dir, "export", "calls.csv")
+	m.loadCDRs()
+	if m.inputMode || len(m.cdrRecords) != 20 || m.cdrSource != csvPath {
+		t.Fatalf("Expected 20 records from %s, got %d (%s)", csvPath, len(m.cdrRecords), m.errorMsg)
+	}
+	if view := m.renderCDRBrowser(); !strings.Contains(view, "page 1 of 2") || strings.Count(view, "ANSWERED") != cdrPageSize {
+		t.Errorf("Unexpected first page:\n%s", view)
+	}
+
+	m.handleCDRScreen(tea.KeyMsg{Type: tea.KeyPgDown})
+	if m.selectedCDRIdx != cdrPageSize {
+		t.Errorf("Expected page down to select record %d, got %d", cdrPageSize, m.selectedCDRIdx)
+	}
+	if view := m.renderCDRBrowser(); !strings.Contains(view, "page 2 of 2") || strings.Count(view, "ANSWERED") != 5 {
+		t.Errorf("Unexpected second page:\n%s", view)
+	}
+
+	m.handleCDRScreen(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s")})
+	if view := m.renderCDRBrowser(); !m.cdrSummaryView || !strings.Contains(view, "Summary by Extension") || !strings.Contains(view, "100%") {
+		t.Errorf("Unexpected summary view:\n%s", view)
+	}
+
+	m.handleCDRScreen(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+	data, err := os.ReadFile(m.cdrExportPath)
+	if err != nil || strings.Count(string(data), "\n") != 21 || !strings.Contains(m.successMsg, "Exported 20") {
+		t.Errorf("Unexpected export (%v, %q):\n%s", err, m.successMsg, data)
+	}
+
+	m.handleCDRScreen(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.currentScreen != mainMenu {
+		t.Error("Expected ESC to return to the main menu")
+	}
+}
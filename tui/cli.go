@@ -0,0 +1,2079 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+// Exit codes for the non-interactive subcommands, aligned with the health command
+const (
+	cliExitOK       = 0
+	cliExitWarning  = 1
+	cliExitFailed   = 2
+	cliExitUsage    = 3
+	cliExitNotFound = 4
+)
+
+// cliUsage documents the scripting subcommands for the help output
+const cliUsage = `    rayanpbx-tui extensions list
+    rayanpbx-tui extensions add NUMBER --name NAME --password SECRET [extension options]
+    rayanpbx-tui extensions edit NUMBER [--number NEW] [extension options]
+    rayanpbx-tui extensions delete|enable|disable NUMBER
+    rayanpbx-tui trunks list
+    rayanpbx-tui trunks add NAME --host HOST [--port 5060] [--priority 1]
+    rayanpbx-tui trunks delete|enable|disable NAME
+    rayanpbx-tui sync status
+    rayanpbx-tui sync apply [NUMBER] [--direction db-to-asterisk|asterisk-to-db]
+    rayanpbx-tui phones discover [--network CIDR]
+    rayanpbx-tui phones inventory
+    rayanpbx-tui phones assign MAC|IP --lines EXT[,EXT...] [--location TEXT]
+    rayanpbx-tui phones bulk reboot|provision|set-config|firmware|enable-cti [--filter NAME] [--vendor VENDOR] [--model TEXT]
+                 [--firmware-version PREFIX] [--subnet CIDR] [--extensions FROM-TO] [--ip IP[,IP...]] [--set "key=value;..."]
+                 [--firmware-url URL] [--workers 8] [--timeout 60] [--username admin] [--report FILE]
+    rayanpbx-tui phones filters
+    rayanpbx-tui phones backup MAC|IP [--note TEXT] [--username admin]
+    rayanpbx-tui phones backups [MAC|IP]
+    rayanpbx-tui phones diff MAC|IP [--from VERSION] [--to VERSION] [--username admin]
+    rayanpbx-tui phones restore MAC|IP --version N [--keys KEY[,PREFIX*...]] [--username admin]
+    rayanpbx-tui phones keys MAC|IP [--set "POSITION=TYPE[:VALUE][/LABEL];..."] [--profile NAME|none]
+    rayanpbx-tui phones push-keys MAC|IP [--username admin]
+    rayanpbx-tui phones key-profiles
+    rayanpbx-tui phones key-profile NAME "POSITION=TYPE[:VALUE][/LABEL];..."|none
+    rayanpbx-tui phones provision IP --extension NUMBER [--username admin] [--account 1] [--vendor grandstream|yealink|polycom|snom|fanvil]
+    rayanpbx-tui phones reboot IP [--username admin] [--vendor grandstream|yealink|polycom|snom|fanvil]
+    rayanpbx-tui asterisk status
+    rayanpbx-tui asterisk reload [--module all|pjsip|dialplan]
+    rayanpbx-tui config get [KEY]
+    rayanpbx-tui config set KEY VALUE
+    rayanpbx-tui servers list|status
+    rayanpbx-tui cdr list|summary [cdr filters]
+    rayanpbx-tui cdr export FILE [cdr filters]
+    rayanpbx-tui provision serve|url
+    rayanpbx-tui provision render MAC [--vendor grandstream|yealink|fanvil|snom]
+    rayanpbx-tui provision dhcp [--server isc|dnsmasq|kea] [--host IP]
+    rayanpbx-tui provision rollout IP [IP...] [--username admin] [--vendor VENDOR]
+    rayanpbx-tui phonebook list [--search TEXT]
+    rayanpbx-tui phonebook add NAME NUMBER [--mobile NUMBER] [--company TEXT] [--group TEXT]
+    rayanpbx-tui phonebook delete NAME
+    rayanpbx-tui phonebook render grandstream|yealink|cisco [--search TEXT]
+    rayanpbx-tui firmware list
+    rayanpbx-tui firmware add VENDOR MODEL VERSION FILE
+    rayanpbx-tui firmware target VENDOR MODEL VERSION|none
+    rayanpbx-tui firmware status [phone selection]
+    rayanpbx-tui firmware upgrade [phone selection] [--canary 1|IP[,IP...]] [--workers 8] [--timeout 900]
+                 [--username admin] [--report FILE]
+
+EXTENSION OPTIONS (add/edit):
+    --name, --password, --profile, --codecs, --context, --transport,
+    --direct-media, --max-contacts, --qualify, --media-encryption, --nat yes|no
+
+PHONE SELECTION (phones bulk, firmware status/upgrade):
+    --filter NAME, --vendor, --model, --firmware-version PREFIX, --subnet CIDR,
+    --extensions FROM-TO, --ip IP[,IP...]
+
+PHONE LOGIN (phones, provision rollout, firmware upgrade):
+    RAYANPBX_PHONE_PASSWORD and RAYANPBX_PHONE_USERNAME (default admin), from the
+    environment or .env. --password PASS still works but is visible in ps output
+    and shell history.
+
+CDR FILTERS:
+    --from DATE, --to DATE (YYYY-MM-DD), --src, --dst, --disposition, --trunk,
+    --limit N (default 5000)
+
+COMMAND OPTIONS:
+    --output table|json|yaml   Output format (default table)
+
+EXIT CODES:
+    0 success, 1 completed with warnings, 2 failed, 3 usage error, 4 not found
+`
+
+// cliExtensionFlags maps extension options to the form fields used by the TUI
+var cliExtensionFlags = map[string]int{
+	"name":             extFieldName,
+	"password":         extFieldPassword,
+	"profile":          extFieldProfile,
+	"codecs":           extFieldCodecs,
+	"context":          extFieldContext,
+	"transport":        extFieldTransport,
+	"direct-media":     extFieldDirectMedia,
+	"max-contacts":     extFieldMaxContacts,
+	"qualify":          extFieldQualifyFreq,
+	"media-encryption": extFieldMediaEncryption,
+	"nat":              extFieldNAT,
+}
+
+// cliCommands maps top-level subcommands to their handlers
+var cliCommands = map[string]func(*cliContext) int{
+	"extensions": cliExtensions,
+	"trunks":     cliTrunks,
+	"sync":       cliSync,
+	"phones":     cliPhones,
+	"asterisk":   cliAsterisk,
+	"config":     cliConfig,
+	"servers":    cliServers,
+	"cdr":        cliCDR,
+	"provision":  cliProvision,
+	"firmware":   cliFirmware,
+	"phonebook":  cliPhonebook,
+}
+
+// isCLICommand returns true if name is a non-interactive subcommand
+func isCLICommand(name string) bool {
+	_, ok := cliCommands[name]
+	return ok
+}
+
+// cliArgs holds positional arguments and --flag values of a subcommand
+type cliArgs struct {
+	Positional []string
+	Flags      map[string]string
+}
+
+// parseCLIArgs splits args into positional arguments and --flag values.
+// Every flag takes a value, given either as --flag value or --flag=value.
+func parseCLIArgs(args []string) (*cliArgs, error) {
+	parsed := &cliArgs{Flags: make(map[string]string)}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "--") {
+			parsed.Positional = append(parsed.Positional, arg)
+			continue
+		}
+		name := strings.TrimPrefix(arg, "--")
+		value := ""
+		if idx := strings.Index(name, "="); idx >= 0 {
+			name, value = name[:idx], name[idx+1:]
+		} else if i+1 < len(args) {
+			value = args[i+1]
+			i++
+		} else {
+			return nil, fmt.Errorf("missing value for --%s", name)
+		}
+		if name == "" {
+			return nil, fmt.Errorf("invalid flag %q", arg)
+		}
+		parsed.Flags[name] = value
+	}
+	return parsed, nil
+}
+
+// cliOutput is the result of a subcommand, rendered as a table, JSON or YAML
+type cliOutput struct {
+	Columns []string // snake_case field names in display order
+	Rows    [][]interface{}
+	Single  bool // Render the only row as an object instead of a list
+}
+
+// addRow appends a row of values matching Columns
+func (o *cliOutput) addRow(values ...interface{}) {
+	o.Rows = append(o.Rows, values)
+}
+
+// records converts the rows into maps keyed by column name
+func (o *cliOutput) records() []map[string]interface{} {
+	records := make([]map[string]interface{}, 0, len(o.Rows))
+	for _, row := range o.Rows {
+		record := make(map[string]interface{}, len(o.Columns))
+		for i, column := range o.Columns {
+			if i < len(row) {
+				record[column] = row[i]
+			}
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// formatCLIOutput renders the output in the given format
+func formatCLIOutput(out *cliOutput, format string) (string, error) {
+	switch format {
+	case "json":
+		var data []byte
+		var err error
+		if out.Single && len(out.Rows) == 1 {
+			data, err = json.MarshalIndent(out.records()[0], "", "  ")
+		} else {
+			data, err = json.MarshalIndent(out.records(), "", "  ")
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to encode JSON: %v", err)
+		}
+		return string(data) + "\n", nil
+	case "yaml":
+		return formatCLIYAML(out), nil
+	case "table":
+		return formatCLITable(out), nil
+	}
+	return "", fmt.Errorf("unknown output format %q (use table, json or yaml)", format)
+}
+
+// formatCLITable renders the output as an aligned table with a header row
+func formatCLITable(out *cliOutput) string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	header := make([]string, len(out.Columns))
+	for i, column := range out.Columns {
+		header[i] = strings.ToUpper(column)
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range out.Rows {
+		cells := make([]string, len(row))
+		for i, value := range row {
+			cells[i] = tableCell(value)
+		}
+		fmt.Fprintln(w, strings.Join(cells, "\t"))
+	}
+	w.Flush()
+	return buf.String()
+}
+
+// tableCell formats a single value for table output
+func tableCell(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "-"
+	case string:
+		if v == "" {
+			return "-"
+		}
+		return v
+	case bool:
+		if v {
+			return "yes"
+		}
+		return "no"
+	case []string:
+		if len(v) == 0 {
+			return "-"
+		}
+		return strings.Join(v, "; ")
+	}
+	return fmt.Sprint(value)
+}
+
+// formatCLIYAML renders the output as YAML, a mapping for single results and a sequence otherwise
+func formatCLIYAML(out *cliOutput) string {
+	if !out.Single && len(out.Rows) == 0 {
+		return "[]\n"
+	}
+	var b strings.Builder
+	for _, row := range out.Rows {
+		for i, column := range out.Columns {
+			prefix := ""
+			if !out.Single {
+				prefix = "  "
+				if i == 0 {
+					prefix = "- "
+				}
+			}
+			var value interface{}
+			if i < len(row) {
+				value = row[i]
+			}
+			fmt.Fprintf(&b, "%s%s: %s\n", prefix, column, yamlScalar(value))
+		}
+	}
+	return b.String()
+}
+
+// yamlScalar formats a value as a YAML scalar or flow sequence.
+// JSON encoding is valid YAML, so anything that is not a plain string is emitted as JSON.
+func yamlScalar(value interface{}) string {
+	if s, ok := value.(string); ok && isPlainYAMLString(s) {
+		return s
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return strconv.Quote(fmt.Sprint(value))
+	}
+	return string(data)
+}
+
+// isPlainYAMLString returns true if s can be written unquoted without changing its type or meaning
+func isPlainYAMLString(s string) bool {
+	if s == "" || strings.TrimSpace(s) != s || strings.ContainsAny(s, ":#\n\"'") {
+		return false
+	}
+	if strings.ContainsAny(s[:1], "-?[]{},&*!|>%@`") {
+		return false
+	}
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "null", "~":
+		return false
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return false
+	}
+	return true
+}
+
+// cliContext carries the parsed arguments and shared resources of a subcommand
+type cliContext struct {
+	command string
+	action  string
+	args    *cliArgs
+	format  string
+	stdout  io.Writer
+	stderr  io.Writer
+	config  *Config
+	db      *sql.DB
+	env     *ConfigManager // .env manager for the config subcommand
+}
+
+// positional returns the i-th argument after the action, or "" if missing
+func (c *cliContext) positional(i int) string {
+	if i < len(c.args.Positional) {
+		return c.args.Positional[i]
+	}
+	return ""
+}
+
+// phoneLogin returns the phone web admin login from RAYANPBX_PHONE_USERNAME and
+// RAYANPBX_PHONE_PASSWORD, read from the environment or .env, for phones without stored
+// credentials. override is set when --password replaces them for every phone; it shows
+// up in ps output and shell history, so it warns.
+func (c *cliContext) phoneLogin() (username, password string, override bool) {
+	if c.config == nil {
+		c.config, _ = LoadConfig()
+	}
+	username = c.args.Flags["username"]
+	if username == "" {
+		username = getEnv("RAYANPBX_PHONE_USERNAME", "admin")
+	}
+	password = getEnv("RAYANPBX_PHONE_PASSWORD", "")
+	if flag := c.args.Flags["password"]; flag != "" {
+		fmt.Fprintln(c.stderr, "Warning: --password is visible in process lists and shell history, set RAYANPBX_PHONE_PASSWORD instead")
+		return username, flag, true
+	}
+	return username, password, false
+}
+
+// checkFlags reports a usage error for flags the action does not accept
+func (c *cliContext) checkFlags(allowed ...string) error {
+	var unknown []string
+	for name := range c.args.Flags {
+		found := false
+		for _, a := range allowed {
+			if name == a {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unknown = append(unknown, "--"+name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown option(s) for %s %s: %s", c.command, c.action, strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// usageError prints a usage error and returns the usage exit code
+func (c *cliContext) usageError(format string, a ...interface{}) int {
+	fmt.Fprintf(c.stderr, "Error: %s\n", fmt.Sprintf(format, a...))
+	fmt.Fprintf(c.stderr, "Run 'rayanpbx-tui help' for usage.\n")
+	return cliExitUsage
+}
+
+// fail prints an error and returns the given exit code
+func (c *cliContext) fail(code int, format string, a ...interface{}) int {
+	fmt.Fprintf(c.stderr, "Error: %s\n", fmt.Sprintf(format, a...))
+	return code
+}
+
+// print renders the output in the selected format
+func (c *cliContext) print(out *cliOutput) int {
+	text, err := formatCLIOutput(out, c.format)
+	if err != nil {
+		return c.fail(cliExitFailed, "%v", err)
+	}
+	fmt.Fprint(c.stdout, text)
+	return cliExitOK
+}
+
+// result prints the outcome of a change, returning the warning exit code if it was only partly applied
+func (c *cliContext) result(message, warning string) int {
+	status := "ok"
+	if warning != "" {
+		status = "warning"
+		fmt.Fprintf(c.stderr, "Warning: %s\n", warning)
+	}
+	out := &cliOutput{Columns: []string{"status", "message"}, Single: true}
+	out.addRow(status, message)
+	if code := c.print(out); code != cliExitOK {
+		return code
+	}
+	if warning != "" {
+		return cliExitWarning
+	}
+	return cliExitOK
+}
+
+// modelResult maps the success and error messages left by a model action to output and an exit code
+func (c *cliContext) modelResult(m *model) int {
+	if m.successMsg == "" {
+		message := m.errorMsg
+		if message == "" {
+			message = "operation failed"
+		}
+		return c.fail(cliExitFailed, "%s", message)
+	}
+	return c.result(m.successMsg, m.errorMsg)
+}
+
+// connectDB loads the configuration and connects to the database
+func (c *cliContext) connectDB() error {
+	if c.db != nil {
+		return nil
+	}
+	config, err := LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %v", err)
+	}
+	c.config = config
+	db, err := ConnectDB(config)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %v", err)
+	}
+	c.db = db
+	return nil
+}
+
+// newModel builds a headless model so actions run exactly as they do in the TUI
+func (c *cliContext) newModel() (*model, error) {
+	if err := c.connectDB(); err != nil {
+		return nil, err
+	}
+	m := initialModel(c.db, c.config, false)
+	// Keep stdout clean for --output json|yaml
+	m.configManager.SetOutput(c.stderr)
+	exts, err := GetExtensions(c.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load extensions: %v", err)
+	}
+	m.extensions = exts
+	return &m, nil
+}
+
+// runCLI runs a non-interactive subcommand and returns its exit code
+func runCLI(args []string) int {
+	return runCLIWithOutput(args, os.Stdout, os.Stderr)
+}
+
+// runCLIWithOutput runs a subcommand writing to the given streams
+func runCLIWithOutput(args []string, stdout, stderr io.Writer) int {
+	c := &cliContext{format: "table", stdout: stdout, stderr: stderr}
+	if len(args) == 0 {
+		return c.usageError("missing command")
+	}
+	c.command = args[0]
+	handler, ok := cliCommands[c.command]
+	if !ok {
+		return c.usageError("unknown command %q", c.command)
+	}
+
+	parsed, err := parseCLIArgs(args[1:])
+	if err != nil {
+		return c.usageError("%v", err)
+	}
+	if format, ok := parsed.Flags["output"]; ok {
+		c.format = format
+		delete(parsed.Flags, "output")
+	}
+	if c.format != "table" && c.format != "json" && c.format != "yaml" {
+		return c.usageError("unknown output format %q (use table, json or yaml)", c.format)
+	}
+	if len(parsed.Positional) == 0 {
+		return c.usageError("missing action for %s", c.command)
+	}
+	c.action = parsed.Positional[0]
+	parsed.Positional = parsed.Positional[1:]
+	c.args = parsed
+
+	defer func() {
+		if c.db != nil {
+			c.db.Close()
+		}
+	}()
+	return handler(c)
+}
+
+// cliExtensions handles the extensions subcommand
+func cliExtensions(c *cliContext) int {
+	extensionFlags := make([]string, 0, len(cliExtensionFlags)+1)
+	for name := range cliExtensionFlags {
+		extensionFlags = append(extensionFlags, name)
+	}
+
+	switch c.action {
+	case "list":
+		if err := c.checkFlags(); err != nil {
+			return c.usageError("%v", err)
+		}
+		if err := c.connectDB(); err != nil {
+			return c.fail(cliExitFailed, "%v", err)
+		}
+		exts, err := GetExtensions(c.db)
+		if err != nil {
+			return c.fail(cliExitFailed, "failed to load extensions: %v", err)
+		}
+		out := &cliOutput{Columns: []string{"number", "name", "enabled", "context", "transport", "codecs", "profile", "media_encryption"}}
+		for _, ext := range exts {
+			out.addRow(ext.ExtensionNumber, ext.Name, ext.Enabled, ext.Context, ext.Transport, ext.Codecs, ext.Profile, ext.MediaEncryption)
+		}
+		return c.print(out)
+
+	case "add":
+		if err := c.checkFlags(extensionFlags...); err != nil {
+			return c.usageError("%v", err)
+		}
+		number := c.positional(0)
+		if number == "" {
+			return c.usageError("extension number is required")
+		}
+		m, err := c.newModel()
+		if err != nil {
+			return c.fail(cliExitFailed, "%v", err)
+		}
+		m.initCreateExtension()
+		m.inputValues[extFieldNumber] = number
+		for name, value := range c.args.Flags {
+			m.inputValues[cliExtensionFlags[name]] = value
+		}
+		m.createExtension()
+		return c.modelResult(m)
+
+	case "edit", "delete", "enable", "disable":
+		allowed := []string{}
+		if c.action == "edit" {
+			allowed = append(extensionFlags, "number")
+		}
+		if err := c.checkFlags(allowed...); err != nil {
+			return c.usageError("%v", err)
+		}
+		number := c.positional(0)
+		if number == "" {
+			return c.usageError("extension number is required")
+		}
+		m, err := c.newModel()
+		if err != nil {
+			return c.fail(cliExitFailed, "%v", err)
+		}
+		idx := -1
+		for i, ext := range m.extensions {
+			if ext.ExtensionNumber == number {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			return c.fail(cliExitNotFound, "extension %s not found", number)
+		}
+		m.selectedExtensionIdx = idx
+
+		switch c.action {
+		case "edit":
+			m.initEditExtension()
+			for name, value := range c.args.Flags {
+				if name == "number" {
+					m.inputValues[extFieldNumber] = value
+				} else {
+					m.inputValues[cliExtensionFlags[name]] = value
+				}
+			}
+			m.editExtension()
+		case "delete":
+			m.deleteExtension()
+		default:
+			enable := c.action == "enable"
+			if m.extensions[idx].Enabled == enable {
+				return c.result(fmt.Sprintf("Extension %s is already %sd", number, c.action), "")
+			}
+			m.toggleExtension()
+		}
+		return c.modelResult(m)
+	}
+	return c.usageError("unknown action %q for extensions", c.action)
+}
+
+// cliTrunks handles the trunks subcommand
+func cliTrunks(c *cliContext) int {
+	switch c.action {
+	case "list":
+		if err := c.checkFlags(); err != nil {
+			return c.usageError("%v", err)
+		}
+		if err := c.connectDB(); err != nil {
+			return c.fail(cliExitFailed, "%v", err)
+		}
+		trunks, err := GetTrunks(c.db)
+		if err != nil {
+			return c.fail(cliExitFailed, "failed to load trunks: %v", err)
+		}
+		out := &cliOutput{Columns: []string{"name", "host", "port", "priority", "enabled"}}
+		for _, trunk := range trunks {
+			out.addRow(trunk.Name, trunk.Host, trunk.Port, trunk.Priority, trunk.Enabled)
+		}
+		return c.print(out)
+
+	case "add":
+		if err := c.checkFlags("host", "port", "priority"); err != nil {
+			return c.usageError("%v", err)
+		}
+		name := c.positional(0)
+		if name == "" || c.args.Flags["host"] == "" {
+			return c.usageError("trunk name and --host are required")
+		}
+		for _, flag := range []string{"port", "priority"} {
+			if value, ok := c.args.Flags[flag]; ok {
+				if _, err := strconv.Atoi(value); err != nil {
+					return c.usageError("--%s must be a number", flag)
+				}
+			}
+		}
+		m, err := c.newModel()
+		if err != nil {
+			return c.fail(cliExitFailed, "%v", err)
+		}
+		m.initCreateTrunk()
+		m.inputValues[trunkFieldName] = name
+		m.inputValues[trunkFieldHost] = c.args.Flags["host"]
+		if port, ok := c.args.Flags["port"]; ok {
+			m.inputValues[trunkFieldPort] = port
+		}
+		if priority, ok := c.args.Flags["priority"]; ok {
+			m.inputValues[trunkFieldPriority] = priority
+		}
+		m.createTrunk()
+		return c.modelResult(m)
+
+	case "delete", "enable", "disable":
+		if err := c.checkFlags(); err != nil {
+			return c.usageError("%v", err)
+		}
+		name := c.positional(0)
+		if name == "" {
+			return c.usageError("trunk name is required")
+		}
+		if err := c.connectDB(); err != nil {
+			return c.fail(cliExitFailed, "%v", err)
+		}
+		var res sql.Result
+		var err error
+		if c.action == "delete" {
+			res, err = c.db.Exec(`DELETE FROM trunks WHERE name = ?`, name)
+		} else {
+			res, err = c.db.Exec(`UPDATE trunks SET enabled = ?, updated_at = NOW() WHERE name = ?`, c.action == "enable", name)
+		}
+		if err != nil {
+			return c.fail(cliExitFailed, "failed to %s trunk: %v", c.action, err)
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			if c.action != "delete" && trunkExists(c.db, name) {
+				return c.result(fmt.Sprintf("Trunk %s is already %sd", name, c.action), "")
+			}
+			return c.fail(cliExitNotFound, "trunk %s not found", name)
+		}
+		return c.result(fmt.Sprintf("Trunk %s %sd successfully!", name, c.action), "")
+	}
+	return c.usageError("unknown action %q for trunks", c.action)
+}
+
+// trunkExists returns true if a trunk with the given name is in the database
+func trunkExists(db *sql.DB, name string) bool {
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM trunks WHERE name = ?`, name).Scan(&count); err != nil {
+		return false
+	}
+	return count > 0
+}
+
+// syncStatusName returns the machine-readable name of a sync status
+func syncStatusName(status SyncStatus) string {
+	switch status {
+	case SyncStatusMatch:
+		return "match"
+	case SyncStatusDBOnly:
+		return "db_only"
+	case SyncStatusAsteriskOnly:
+		return "asterisk_only"
+	case SyncStatusMismatch:
+		return "mismatch"
+	}
+	return "unknown"
+}
+
+// cliSync handles the sync subcommand
+func cliSync(c *cliContext) int {
+	switch c.action {
+	case "status":
+		if err := c.checkFlags(); err != nil {
+			return c.usageError("%v", err)
+		}
+		m, err := c.newModel()
+		if err != nil {
+			return c.fail(cliExitFailed, "%v", err)
+		}
+		infos, err := m.extensionSyncManager.CompareExtensions()
+		if err != nil {
+			return c.fail(cliExitFailed, "failed to compare extensions: %v", err)
+		}
+		out := &cliOutput{Columns: []string{"number", "status", "differences"}}
+		outOfSync := 0
+		for _, info := range infos {
+			if info.SyncStatus != SyncStatusMatch {
+				outOfSync++
+			}
+			differences := info.Differences
+			if differences == nil {
+				differences = []string{}
+			}
+			out.addRow(info.ExtensionNumber, syncStatusName(info.SyncStatus), differences)
+		}
+		if code := c.print(out); code != cliExitOK {
+			return code
+		}
+		// Let scripts detect drift without parsing the output
+		if outOfSync > 0 {
+			return cliExitWarning
+		}
+		return cliExitOK
+
+	case "apply":
+		if err := c.checkFlags("direction"); err != nil {
+			return c.usageError("%v", err)
+		}
+		direction := c.args.Flags["direction"]
+		if direction == "" {
+			direction = "db-to-asterisk"
+		}
+		if direction != "db-to-asterisk" && direction != "asterisk-to-db" {
+			return c.usageError("unknown direction %q (use db-to-asterisk or asterisk-to-db)", direction)
+		}
+		m, err := c.newModel()
+		if err != nil {
+			return c.fail(cliExitFailed, "%v", err)
+		}
+		esm := m.extensionSyncManager
+
+		if number := c.positional(0); number != "" {
+			if direction == "db-to-asterisk" {
+				err = esm.SyncDatabaseToAsterisk(number)
+			} else {
+				err = esm.SyncAsteriskToDatabase(number)
+			}
+			if err != nil {
+				if strings.Contains(err.Error(), "not found") {
+					return c.fail(cliExitNotFound, "%v", err)
+				}
+				return c.fail(cliExitFailed, "failed to sync extension %s: %v", number, err)
+			}
+			return c.result(fmt.Sprintf("Extension %s synced (%s)", number, direction), "")
+		}
+
+		var synced int
+		var errs []error
+		if direction == "db-to-asterisk" {
+			synced, errs = esm.SyncAllDatabaseToAsterisk()
+		} else {
+			synced, errs = esm.SyncAllAsteriskToDatabase()
+		}
+		if len(errs) == 0 {
+			return c.result(fmt.Sprintf("Synced %d extensions (%s)", synced, direction), "")
+		}
+		messages := make([]string, len(errs))
+		for i, e := range errs {
+			messages[i] = e.Error()
+		}
+		if synced == 0 {
+			return c.fail(cliExitFailed, "sync failed: %s", strings.Join(messages, "; "))
+		}
+		return c.result(fmt.Sprintf("Synced %d extensions (%s)", synced, direction),
+			fmt.Sprintf("%d failed: %s", len(errs), strings.Join(messages, "; ")))
+	}
+	return c.usageError("unknown action %q for sync", c.action)
+}
+
+// cliPhonesBulk runs an action on the inventory phones matching a filter
+func cliPhonesBulk(c *cliContext, phoneManager *PhoneManager) int {
+	if err := c.checkFlags("filter", "vendor", "model", "firmware-version", "subnet", "extensions", "ip", "set",
+		"firmware-url", "workers", "timeout", "password", "username", "report"); err != nil {
+		return c.usageError("%v", err)
+	}
+	flags := c.args.Flags
+	op := BulkOperation{Action: c.positional(0), FirmwareURL: flags["firmware-url"], Workers: DefaultBulkWorkers, Timeout: DefaultBulkTimeout}
+	if op.Action == bulkActionSetConfig {
+		config, err := ParseConfigAssignments(flags["set"])
+		if err != nil {
+			return c.usageError("%v", err)
+		}
+		op.Config = config
+	}
+	if value, ok := flags["workers"]; ok {
+		workers, err := strconv.Atoi(value)
+		if err != nil {
+			return c.usageError("--workers must be a number")
+		}
+		op.Workers = workers
+	}
+	if value, ok := flags["timeout"]; ok {
+		seconds, err := strconv.Atoi(value)
+		if err != nil {
+			return c.usageError("--timeout must be a number of seconds")
+		}
+		op.Timeout = time.Duration(seconds) * time.Second
+	}
+	if err := op.Validate(); err != nil {
+		return c.usageError("%v", err)
+	}
+
+	phones, filter, code := cliSelectPhones(c)
+	if code != cliExitOK {
+		return code
+	}
+
+	report := RunBulkOperation(phoneManager, phones, op, LoadProvisioningSettings(), nil)
+	report.Filter = filter.String()
+	if path := flags["report"]; path != "" {
+		if err := WriteBulkReport(path, report); err != nil {
+			return c.fail(cliExitFailed, "%v", err)
+		}
+	}
+	out := &cliOutput{Columns: []string{"ip", "mac", "vendor", "status", "message", "duration_ms"}}
+	for _, result := range report.Results {
+		out.addRow(result.IP, result.MAC, result.Vendor, result.Status, result.Message, result.DurationMS)
+	}
+	if code := c.print(out); code != cliExitOK {
+		return code
+	}
+	if report.Succeeded == 0 {
+		return cliExitFailed
+	}
+	if report.Failed > 0 || report.Skipped > 0 {
+		return cliExitWarning
+	}
+	return cliExitOK
+}
+
+// cliSelectPhones returns the phones chosen with the --filter, --vendor, --model,
+// --firmware-version, --subnet, --extensions and --ip flags, with the phone login applied
+func cliSelectPhones(c *cliContext) ([]BulkPhone, PhoneFilter, int) {
+	flags := c.args.Flags
+	filter := PhoneFilter{}
+	if name := flags["filter"]; name != "" {
+		saved, err := LoadPhoneFilters(phoneFiltersFilePath())
+		if err != nil {
+			return nil, filter, c.fail(cliExitFailed, "%v", err)
+		}
+		found := FindPhoneFilter(saved, name)
+		if found == nil {
+			return nil, filter, c.fail(cliExitNotFound, "no saved filter named %q", name)
+		}
+		filter = *found
+	}
+	override := func(dst *string, value string) {
+		if value != "" {
+			*dst = value
+		}
+	}
+	override(&filter.Vendor, strings.ToLower(flags["vendor"]))
+	override(&filter.Model, flags["model"])
+	override(&filter.Firmware, flags["firmware-version"])
+	override(&filter.Subnet, flags["subnet"])
+	if extensions := flags["extensions"]; extensions != "" {
+		filter.ExtensionFrom, filter.ExtensionTo = ParseExtensionRange(extensions)
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, filter, c.usageError("%v", err)
+	}
+
+	var ips []string
+	if flags["ip"] != "" {
+		ips = strings.Split(flags["ip"], ",")
+	}
+	var phones []BulkPhone
+	if err := c.connectDB(); err == nil {
+		inventory, err := GetInventoryPhones(c.db)
+		if err != nil {
+			return nil, filter, c.fail(cliExitFailed, "%v", err)
+		}
+		credentials, _ := LoadPhoneCredentials(c.db)
+		phones = FilterPhones(BulkPhonesFromInventory(inventory, credentials), filter)
+	} else if len(ips) == 0 || !filter.Empty() {
+		return nil, filter, c.fail(cliExitFailed, "%v (without the inventory, give phones with --ip and no filter)", err)
+	} else {
+		for _, ip := range ips {
+			phones = append(phones, BulkPhone{IP: strings.TrimSpace(ip)})
+		}
+	}
+	if len(ips) > 0 {
+		wanted := make(map[string]bool)
+		for _, ip := range ips {
+			wanted[strings.TrimSpace(ip)] = true
+		}
+		var selected []BulkPhone
+		for _, phone := range phones {
+			if wanted[phone.IP] {
+				selected = append(selected, phone)
+			}
+		}
+		phones = selected
+	}
+	if len(phones) == 0 {
+		return nil, filter, c.fail(cliExitNotFound, "no phones match %s", filter)
+	}
+	if username, password, override := c.phoneLogin(); password != "" {
+		for i := range phones {
+			if !override && phones[i].Credentials["password"] != "" {
+				continue
+			}
+			phones[i].Credentials = map[string]string{"username": username, "password": password}
+		}
+	}
+	return phones, filter, cliExitOK
+}
+
+// cliBackupPhone finds the phone given by MAC or IP, through the inventory when the
+// database is available and through its latest config snapshot otherwise
+func cliBackupPhone(c *cliContext, store *PhoneBackupStore, key string) (BulkPhone, int) {
+	phone := BulkPhone{IP: key}
+	if mac := normalizeMAC(key); mac != "" {
+		phone = BulkPhone{MAC: formatMAC(mac)}
+	}
+	if err := c.connectDB(); err == nil {
+		inventory, err := GetInventoryPhones(c.db)
+		if err != nil {
+			return phone, c.fail(cliExitFailed, "%v", err)
+		}
+		if found := FindInventoryPhone(inventory, key); found != nil && found.IP != "" {
+			credentials, _ := LoadPhoneCredentials(c.db)
+			phone = BulkPhonesFromInventory([]InventoryPhone{*found}, credentials)[0]
+		}
+	}
+	if phone.IP == "" {
+		snapshot, err := store.Load(phone.MAC, 0)
+		if err != nil {
+			return phone, c.fail(cliExitNotFound, "phone %s has no known address", key)
+		}
+		phone.IP, phone.Vendor = snapshot.IP, snapshot.Vendor
+	}
+	if username, password, override := c.phoneLogin(); password != "" && (override || phone.Credentials["password"] == "") {
+		phone.Credentials = map[string]string{"username": username, "password": password}
+	}
+	if phone.Credentials["password"] == "" {
+		return phone, c.usageError("no credentials stored for %s, set RAYANPBX_PHONE_PASSWORD", phone.IP)
+	}
+	return phone, cliExitOK
+}
+
+// cliBackupMAC returns the MAC of the phone given by MAC or IP, preferring the inventory
+// over the addresses recorded in its snapshots
+func cliBackupMAC(c *cliContext, store *PhoneBackupStore, key string) (string, error) {
+	if normalizeMAC(key) == "" && c.connectDB() == nil {
+		if inventory, err := GetInventoryPhones(c.db); err == nil {
+			if found := FindInventoryPhone(inventory, key); found != nil && found.MAC != "" {
+				return formatMAC(found.MAC), nil
+			}
+		}
+	}
+	return store.ResolveMAC(key)
+}
+
+// cliSnapshotVersion parses a snapshot version flag, 0 when it is not given
+func cliSnapshotVersion(c *cliContext, flag string) (int, error) {
+	value, ok := c.args.Flags[flag]
+	if !ok {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(value)
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("--%s must be a snapshot version", flag)
+	}
+	return version, nil
+}
+
+// cliPhoneBackups takes, lists, compares and restores phone config snapshots
+func cliPhoneBackups(c *cliContext, phoneManager *PhoneManager) int {
+	store := NewPhoneBackupStore(phoneBackupDirPath())
+	sessions := NewGrandStreamSessionManager(nil)
+	key := c.positional(0)
+
+	switch c.action {
+	case "backups":
+		if err := c.checkFlags(); err != nil {
+			return c.usageError("%v", err)
+		}
+		out := &cliOutput{Columns: []string{"mac", "version", "taken_at", "ip", "vendor", "model", "firmware", "params", "note"}}
+		var snapshots []PhoneConfigSnapshot
+		var err error
+		if key == "" {
+			snapshots, err = store.Latest()
+		} else {
+			var mac string
+			if mac, err = cliBackupMAC(c, store, key); err != nil {
+				return c.fail(cliExitNotFound, "%v", err)
+			}
+			snapshots, err = store.List(mac)
+		}
+		if err != nil {
+			return c.fail(cliExitFailed, "%v", err)
+		}
+		for _, snapshot := range snapshots {
+			out.addRow(snapshot.MAC, snapshot.Version, snapshot.TakenAt, snapshot.IP, snapshot.Vendor,
+				snapshot.Model, snapshot.Firmware, len(snapshot.Params), snapshot.Note)
+		}
+		return c.print(out)
+
+	case "backup":
+		if err := c.checkFlags("note", "password", "username"); err != nil {
+			return c.usageError("%v", err)
+		}
+		if key == "" {
+			return c.usageError("phone MAC or IP is required")
+		}
+		phone, code := cliBackupPhone(c, store, key)
+		if code != cliExitOK {
+			return code
+		}
+		snapshot, err := BackupPhoneConfig(phoneManager, sessions, store, phone, c.args.Flags["note"])
+		if err != nil {
+			return c.fail(cliExitFailed, "failed to back up %s: %v", phone.IP, err)
+		}
+		return c.result(fmt.Sprintf("Saved version %d of %s with %d parameters", snapshot.Version, snapshot.MAC, len(snapshot.Params)), "")
+
+	case "diff":
+		if err := c.checkFlags("from", "to", "password", "username"); err != nil {
+			return c.usageError("%v", err)
+		}
+		if key == "" {
+			return c.usageError("phone MAC or IP is required")
+		}
+		from, err := cliSnapshotVersion(c, "from")
+		if err != nil {
+			return c.usageError("%v", err)
+		}
+		to, err := cliSnapshotVersion(c, "to")
+		if err != nil {
+			return c.usageError("%v", err)
+		}
+		mac, err := cliBackupMAC(c, store, key)
+		if err != nil {
+			return c.fail(cliExitNotFound, "%v", err)
+		}
+		snapshot, err := store.Load(mac, from)
+		if err != nil {
+			return c.fail(cliExitNotFound, "%v", err)
+		}
+		var changes []ConfigChange
+		if to != 0 {
+			newer, err := store.Load(mac, to)
+			if err != nil {
+				return c.fail(cliExitNotFound, "%v", err)
+			}
+			changes = DiffPhoneConfigs(snapshot.Params, newer.Params)
+		} else {
+			phone, code := cliBackupPhone(c, store, key)
+			if code != cliExitOK {
+				return code
+			}
+			if changes, err = DiffPhoneLive(phoneManager, sessions, phone, snapshot); err != nil {
+				return c.fail(cliExitFailed, "failed to read %s: %v", phone.IP, err)
+			}
+		}
+		out := &cliOutput{Columns: []string{"key", "change", "old", "new"}}
+		for _, change := range changes {
+			out.addRow(change.Key, change.Kind, change.Old, change.New)
+		}
+		return c.print(out)
+
+	case "restore":
+		if err := c.checkFlags("version", "keys", "password", "username"); err != nil {
+			return c.usageError("%v", err)
+		}
+		version, err := cliSnapshotVersion(c, "version")
+		if key == "" || version == 0 || err != nil {
+			return c.usageError("phone MAC or IP and --version are required")
+		}
+		var patterns []string
+		if keys := c.args.Flags["keys"]; keys != "" {
+			for _, pattern := range strings.Split(keys, ",") {
+				if pattern = strings.TrimSpace(pattern); pattern != "" {
+					patterns = append(patterns, pattern)
+				}
+			}
+		}
+		mac, err := cliBackupMAC(c, store, key)
+		if err != nil {
+			return c.fail(cliExitNotFound, "%v", err)
+		}
+		snapshot, err := store.Load(mac, version)
+		if err != nil {
+			return c.fail(cliExitNotFound, "%v", err)
+		}
+		if _, err := SelectRestoreParams(snapshot.Params, nil, patterns); err != nil {
+			return c.usageError("%v", err)
+		}
+		phone, code := cliBackupPhone(c, store, key)
+		if code != cliExitOK {
+			return code
+		}
+		restored, err := RestorePhoneSnapshot(phoneManager, sessions, store, phone, snapshot, patterns)
+		if err != nil {
+			return c.fail(cliExitFailed, "failed to restore %s: %v", phone.IP, err)
+		}
+		if len(restored) == 0 {
+			return c.result(fmt.Sprintf("Phone %s already matches version %d", phone.IP, version), "")
+		}
+		return c.result(fmt.Sprintf("Restored %s on %s from version %d", strings.Join(restored, ", "), phone.IP, version), "")
+	}
+	return c.usageError("unknown action %q for phones", c.action)
+}
+
+// cliKeyLayoutHints returns the dialplan hints BLF keys are checked against, or nil when
+// the database is not available
+func cliKeyLayoutHints(c *cliContext) (map[string]string, error) {
+	if c.connectDB() != nil {
+		return nil, nil
+	}
+	acm := NewAsteriskConfigManager(false)
+	acm.SetOutput(c.stderr)
+	return loadKeyLayoutHints(c.db, acm)
+}
+
+// cliPhoneKeys shows, edits and pushes phone key layouts and key layout profiles
+func cliPhoneKeys(c *cliContext, phoneManager *PhoneManager) int {
+	path := keyLayoutsFilePath()
+	name := c.positional(0)
+
+	switch c.action {
+	case "key-profiles":
+		if err := c.checkFlags(); err != nil {
+			return c.usageError("%v", err)
+		}
+		layouts, err := LoadKeyLayouts(path)
+		if err != nil {
+			return c.fail(cliExitFailed, "%v", err)
+		}
+		out := &cliOutput{Columns: []string{"name", "phones", "keys"}}
+		for _, profile := range layouts.Profiles {
+			phones := 0
+			for _, phone := range layouts.Phones {
+				if phone.Profile == profile.Name {
+					phones++
+				}
+			}
+			out.addRow(profile.Name, phones, FormatKeyLayout(profile.Keys))
+		}
+		return c.print(out)
+
+	case "key-profile":
+		if err := c.checkFlags(); err != nil {
+			return c.usageError("%v", err)
+		}
+		spec := c.positional(1)
+		if name == "" || spec == "" {
+			return c.usageError("profile NAME and a key layout (or none) are required")
+		}
+		if spec == "none" {
+			if _, err := DeleteKeyProfile(path, name); err != nil {
+				return c.fail(cliExitFailed, "%v", err)
+			}
+			return c.result(fmt.Sprintf("Deleted key layout profile %s", name), "")
+		}
+		keys, err := ParseKeyLayout(spec)
+		if err != nil {
+			return c.usageError("%v", err)
+		}
+		hints, err := cliKeyLayoutHints(c)
+		if err != nil {
+			return c.fail(cliExitFailed, "%v", err)
+		}
+		if _, err := SetKeyProfile(path, KeyLayout{Name: name, Keys: keys}, hints); err != nil {
+			return c.fail(cliExitFailed, "%v", err)
+		}
+		return c.result(fmt.Sprintf("Saved key layout profile %s with %d key(s)", name, len(keys)), "")
+	}
+
+	allowed := []string{"set", "profile"}
+	if c.action == "push-keys" {
+		allowed = []string{"password", "username"}
+	}
+	if err := c.checkFlags(allowed...); err != nil {
+		return c.usageError("%v", err)
+	}
+	if name == "" {
+		return c.usageError("phone MAC or IP is required")
+	}
+	hints, err := cliKeyLayoutHints(c)
+	if err != nil {
+		return c.fail(cliExitFailed, "%v", err)
+	}
+	phone := BulkPhone{MAC: formatMAC(name)}
+	if c.db != nil {
+		inventory, err := GetInventoryPhones(c.db)
+		if err != nil {
+			return c.fail(cliExitFailed, "%v", err)
+		}
+		if found := FindInventoryPhone(inventory, name); found != nil {
+			credentials, _ := LoadPhoneCredentials(c.db)
+			phone = BulkPhone{IP: found.IP, MAC: found.MAC, Vendor: inventoryPhoneVendor(*found), Credentials: credentials[found.IP]}
+		}
+	}
+	if normalizeMAC(phone.MAC) == "" {
+		return c.fail(cliExitNotFound, "phone %s is not in the inventory, give its MAC address", name)
+	}
+	layouts, err := LoadKeyLayouts(path)
+	if err != nil {
+		return c.fail(cliExitFailed, "%v", err)
+	}
+	keys := layouts.PhoneKeys(phone.MAC)
+
+	if c.action == "push-keys" {
+		if phone.IP == "" {
+			return c.fail(cliExitNotFound, "phone %s has no known address", name)
+		}
+		if len(keys) == 0 {
+			return c.fail(cliExitNotFound, "phone %s has no key layout", name)
+		}
+		if username, password, override := c.phoneLogin(); password != "" && (override || phone.Credentials["password"] == "") {
+			phone.Credentials = map[string]string{"username": username, "password": password}
+		}
+		if _, err := PushKeyLayout(phoneManager, phone, keys); err != nil {
+			return c.fail(cliExitFailed, "%v", err)
+		}
+		return c.result(fmt.Sprintf("Pushed %d key(s) to %s", len(keys), phone.IP), "")
+	}
+
+	layout := KeyLayout{MAC: phone.MAC}
+	if existing := layouts.Phone(phone.MAC); existing != nil {
+		layout = *existing
+	}
+	spec, set := c.args.Flags["set"]
+	profile, useProfile := c.args.Flags["profile"]
+	if set {
+		if layout.Keys, err = ParseKeyLayout(spec); err != nil {
+			return c.usageError("%v", err)
+		}
+	}
+	if useProfile {
+		layout.Profile = profile
+		if profile == "none" {
+			layout.Profile = ""
+		}
+	}
+	if set || useProfile {
+		if layouts, err = SetPhoneKeyLayout(path, layout, phone.Vendor, hints); err != nil {
+			return c.fail(cliExitFailed, "%v", err)
+		}
+		keys = layouts.PhoneKeys(phone.MAC)
+	}
+
+	own := make(map[int]bool)
+	if saved := layouts.Phone(phone.MAC); saved != nil {
+		for _, key := range saved.Keys {
+			own[key.Key] = true
+		}
+	}
+	out := &cliOutput{Columns: []string{"key", "type", "value", "label", "from", "problem"}}
+	problems := 0
+	for _, key := range keys {
+		source, problem := "profile", ""
+		if own[key.Key] {
+			source = "phone"
+		}
+		if err := key.Check(phone.Vendor, hints); err != nil {
+			problem = err.Error()
+			problems++
+		}
+		out.addRow(key.Key, key.Type, key.Value, key.Label, source, problem)
+	}
+	if code := c.print(out); code != cliExitOK {
+		return code
+	}
+	if problems > 0 {
+		return cliExitWarning
+	}
+	return cliExitOK
+}
+
+// cliPhones handles the phones subcommand
+func cliPhones(c *cliContext) int {
+	phoneManager := NewPhoneManager(NewAsteriskManager())
+
+	switch c.action {
+	case "discover":
+		if err := c.checkFlags("network"); err != nil {
+			return c.usageError("%v", err)
+		}
+		network := c.args.Flags["network"]
+		if network == "" {
+			network = DefaultNetworkSubnet
+			if config, err := LoadConfig(); err == nil && config.NetworkSubnet != "" {
+				network = config.NetworkSubnet
+			}
+		}
+		phones, err := NewPhoneDiscovery(phoneManager).DiscoverPhones(network)
+		if err != nil {
+			return c.fail(cliExitFailed, "discovery failed: %v", err)
+		}
+		if c.connectDB() == nil {
+			for _, phone := range phones {
+				RecordPhoneSighting(c.db, discoverySighting(phone))
+			}
+		}
+		out := &cliOutput{Columns: []string{"ip", "mac", "vendor", "model", "discovery_type", "online"}}
+		for _, phone := range phones {
+			out.addRow(phone.IP, phone.MAC, phone.Vendor, phone.Model, phone.DiscoveryType, phone.Online)
+		}
+		return c.print(out)
+
+	case "inventory":
+		if err := c.checkFlags(); err != nil {
+			return c.usageError("%v", err)
+		}
+		if err := c.connectDB(); err != nil {
+			return c.fail(cliExitFailed, "%v", err)
+		}
+		phones, err := GetInventoryPhones(c.db)
+		if err != nil {
+			return c.fail(cliExitFailed, "%v", err)
+		}
+		out := &cliOutput{Columns: []string{"mac", "ip", "name", "vendor", "model", "firmware", "location", "lines", "credentials", "last_seen"}}
+		for _, phone := range phones {
+			out.addRow(phone.MAC, phone.IP, phone.Name, phone.Vendor, phone.Model, phone.Firmware,
+				phone.Location, describePhoneLines(phone.Lines), phone.HasCredentials, phone.LastSeen)
+		}
+		return c.print(out)
+
+	case "assign":
+		if err := c.checkFlags("lines", "location"); err != nil {
+			return c.usageError("%v", err)
+		}
+		key := c.positional(0)
+		if key == "" {
+			return c.usageError("phone MAC or IP is required")
+		}
+		entries, ok := c.args.Flags["lines"]
+		if !ok {
+			return c.usageError("--lines is required, e.g. --lines 101,,103 to leave line 2 unassigned")
+		}
+		if err := c.connectDB(); err != nil {
+			return c.fail(cliExitFailed, "%v", err)
+		}
+		phones, err := GetInventoryPhones(c.db)
+		if err != nil {
+			return c.fail(cliExitFailed, "%v", err)
+		}
+		phone := FindInventoryPhone(phones, key)
+		if phone == nil {
+			return c.fail(cliExitNotFound, "phone %s is not in the inventory", key)
+		}
+		exts, err := GetExtensions(c.db)
+		if err != nil {
+			return c.fail(cliExitFailed, "failed to load extensions: %v", err)
+		}
+		lines, err := ParsePhoneLines(strings.Split(entries, ","), exts)
+		if err != nil {
+			return c.usageError("%v", err)
+		}
+		if err := SetPhoneLines(c.db, phone.ID, lines); err != nil {
+			return c.fail(cliExitFailed, "%v", err)
+		}
+		if location, ok := c.args.Flags["location"]; ok {
+			if err := SetPhoneDetails(c.db, phone.ID, phone.Name, location, phone.Notes); err != nil {
+				return c.fail(cliExitFailed, "%v", err)
+			}
+		}
+		return c.result(fmt.Sprintf("Assigned %s to phone %s", describePhoneLines(lines), key), "")
+
+	case "bulk":
+		return cliPhonesBulk(c, phoneManager)
+
+	case "backup", "backups", "diff", "restore":
+		return cliPhoneBackups(c, phoneManager)
+
+	case "keys", "push-keys", "key-profiles", "key-profile":
+		return cliPhoneKeys(c, phoneManager)
+
+	case "filters":
+		if err := c.checkFlags(); err != nil {
+			return c.usageError("%v", err)
+		}
+		filters, err := LoadPhoneFilters(phoneFiltersFilePath())
+		if err != nil {
+			return c.fail(cliExitFailed, "%v", err)
+		}
+		out := &cliOutput{Columns: []string{"name", "filter"}}
+		for _, filter := range filters {
+			out.addRow(filter.Name, filter.String())
+		}
+		return c.print(out)
+
+	case "provision", "reboot":
+		allowed := []string{"username", "password", "vendor"}
+		if c.action == "provision" {
+			allowed = append(allowed, "extension", "account")
+		}
+		if err := c.checkFlags(allowed...); err != nil {
+			return c.usageError("%v", err)
+		}
+		ip := c.positional(0)
+		username, password, _ := c.phoneLogin()
+		if ip == "" || password == "" {
+			return c.usageError("phone IP and RAYANPBX_PHONE_PASSWORD are required")
+		}
+		credentials := map[string]string{"username": username, "password": password}
+		vendor := c.args.Flags["vendor"]
+		if vendor == "" {
+			vendor = DefaultPhoneVendor
+		}
+		phone, err := phoneManager.CreatePhone(ip, vendor, credentials)
+		if err != nil {
+			return c.usageError("%v", err)
+		}
+
+		if c.action == "reboot" {
+			if err := phone.Reboot(); err != nil {
+				return c.fail(cliExitFailed, "failed to reboot %s: %v", ip, err)
+			}
+			return c.result(fmt.Sprintf("Phone %s is rebooting", ip), "")
+		}
+
+		number := c.args.Flags["extension"]
+		if number == "" {
+			return c.usageError("--extension is required")
+		}
+		accountNumber := 1
+		if account, ok := c.args.Flags["account"]; ok {
+			if accountNumber, err = strconv.Atoi(account); err != nil || accountNumber < 1 {
+				return c.usageError("--account must be a positive number")
+			}
+		}
+		if err := c.connectDB(); err != nil {
+			return c.fail(cliExitFailed, "%v", err)
+		}
+		exts, err := GetExtensions(c.db)
+		if err != nil {
+			return c.fail(cliExitFailed, "failed to load extensions: %v", err)
+		}
+		for _, ext := range exts {
+			if ext.ExtensionNumber != number {
+				continue
+			}
+			if err := phone.ProvisionExtension(ext, accountNumber); err != nil {
+				return c.fail(cliExitFailed, "failed to provision: %v", err)
+			}
+			return c.result(fmt.Sprintf("Extension %s provisioned on account %d of %s", number, accountNumber, ip), "")
+		}
+		return c.fail(cliExitNotFound, "extension %s not found", number)
+	}
+	return c.usageError("unknown action %q for phones", c.action)
+}
+
+// cliAsterisk handles the asterisk subcommand
+func cliAsterisk(c *cliContext) int {
+	am := NewAsteriskManager()
+
+	switch c.action {
+	case "status":
+		if err := c.checkFlags(); err != nil {
+			return c.usageError("%v", err)
+		}
+		status, err := am.GetServiceStatus()
+		out := &cliOutput{Columns: []string{"service", "status", "uptime_seconds", "active_channels", "active_calls"}, Single: true}
+		var uptime int64
+		var channels, calls int
+		if status == "running" {
+			if output, err := am.ExecuteCLICommand("core show uptime seconds"); err == nil {
+				uptime, _ = parseUptimeSeconds(output)
+			}
+			if output, err := am.ExecuteCLICommand("core show channels count"); err == nil {
+				channels, calls = parseChannelsCount(output)
+			}
+		}
+		out.addRow("asterisk", status, uptime, channels, calls)
+		if code := c.print(out); code != cliExitOK {
+			return code
+		}
+		if err != nil {
+			fmt.Fprintf(c.stderr, "Warning: could not determine service status: %v\n", err)
+		}
+		// Like systemctl is-active, anything but a running service is a failure
+		if status != "running" {
+			return cliExitFailed
+		}
+		return cliExitOK
+
+	case "reload":
+		if err := c.checkFlags("module"); err != nil {
+			return c.usageError("%v", err)
+		}
+		module := c.args.Flags["module"]
+		var err error
+		switch module {
+		case "", "all":
+			module = "all"
+			_, err = am.ReloadAllQuiet()
+		case "pjsip":
+			_, err = am.ReloadPJSIPQuiet()
+		case "dialplan":
+			_, err = am.ReloadDialplanQuiet()
+		default:
+			return c.usageError("unknown module %q (use all, pjsip or dialplan)", module)
+		}
+		if err != nil {
+			return c.fail(cliExitFailed, "failed to reload %s: %v", module, err)
+		}
+		return c.result(fmt.Sprintf("Reloaded %s", module), "")
+	}
+	return c.usageError("unknown action %q for asterisk", c.action)
+}
+
+// cliConfig handles the config subcommand
+func cliConfig(c *cliContext) int {
+	if err := c.checkFlags(); err != nil {
+		return c.usageError("%v", err)
+	}
+	cm := c.env
+	if cm == nil {
+		cm = NewConfigManager(false)
+	}
+
+	switch c.action {
+	case "get":
+		if err := cm.LoadConfigs(); err != nil {
+			return c.fail(cliExitFailed, "failed to load configuration: %v", err)
+		}
+		key := c.positional(0)
+		if key != "" {
+			config := cm.GetConfig(key)
+			if config == nil {
+				return c.fail(cliExitNotFound, "key not found: %s", key)
+			}
+			out := &cliOutput{Columns: []string{"key", "value"}, Single: true}
+			out.addRow(config.Key, config.Value)
+			return c.print(out)
+		}
+		// Listing everything never reveals secrets, ask for a key explicitly instead
+		out := &cliOutput{Columns: []string{"key", "value", "sensitive"}}
+		for _, config := range cm.GetConfigs() {
+			if config.IsSection {
+				continue
+			}
+			value := config.Value
+			if config.Sensitive && value != "" {
+				value = "********"
+			}
+			out.addRow(config.Key, value, config.Sensitive)
+		}
+		return c.print(out)
+
+	case "set":
+		key, value := c.positional(0), c.positional(1)
+		if key == "" || len(c.args.Positional) < 2 {
+			return c.usageError("config set requires KEY and VALUE")
+		}
+		if err := cm.LoadConfigs(); err != nil {
+			return c.fail(cliExitFailed, "failed to load configuration: %v", err)
+		}
+		var err error
+		message := fmt.Sprintf("Updated %s", key)
+		if cm.GetConfig(key) == nil {
+			err = cm.AddConfig(key, value)
+			message = fmt.Sprintf("Added %s", key)
+		} else {
+			err = cm.UpdateConfig(key, value)
+		}
+		if err != nil {
+			return c.fail(cliExitFailed, "%v", err)
+		}
+		return c.result(message, "")
+	}
+	return c.usageError("unknown action %q for config", c.action)
+}
+
+// cliServers handles the servers subcommand
+func cliServers(c *cliContext) int {
+	if err := c.checkFlags(); err != nil {
+		return c.usageError("%v", err)
+	}
+	inventory, err := LoadServerInventory(serversFilePath())
+	if err != nil {
+		return c.fail(cliExitFailed, "%v", err)
+	}
+
+	switch c.action {
+	case "list":
+		out := &cliOutput{Columns: []string{"name", "ssh_host", "db_host", "description"}}
+		for _, server := range inventory.Servers {
+			sshHost := ""
+			if server.IsRemote() {
+				sshHost = server.SSH.Host
+			}
+			out.addRow(server.Name, sshHost, server.DBHost, server.Description)
+		}
+		return c.print(out)
+
+	case "status":
+		config, _ := LoadConfig()
+		profiles := append([]ServerProfile{{Name: localServerName}}, inventory.Servers...)
+		statuses := CollectServerStatuses(profiles, config)
+		out := &cliOutput{Columns: []string{"name", "asterisk_up", "database_up", "endpoints_registered", "endpoints_unregistered", "active_calls", "active_channels", "trunks_registered", "trunks_total"}}
+		down := 0
+		for _, s := range statuses {
+			if !s.AsteriskUp || !s.DatabaseUp {
+				down++
+			}
+			out.addRow(s.Name, s.AsteriskUp, s.DatabaseUp, s.EndpointsRegistered, s.EndpointsUnregistered, s.ActiveCalls, s.ActiveChannels, s.TrunksRegistered, s.TrunksTotal)
+		}
+		if code := c.print(out); code != cliExitOK {
+			return code
+		}
+		if down == len(statuses) {
+			return cliExitFailed
+		}
+		if down > 0 {
+			return cliExitWarning
+		}
+		return cliExitOK
+	}
+	return c.usageError("unknown action %q for servers", c.action)
+}
+
+// cliCDR handles the cdr subcommand
+func cliCDR(c *cliContext) int {
+	if c.action != "list" && c.action != "summary" && c.action != "export" {
+		return c.usageError("unknown action %q for cdr", c.action)
+	}
+	if err := c.checkFlags("from", "to", "src", "dst", "disposition", "trunk", "limit"); err != nil {
+		return c.usageError("%v", err)
+	}
+	path := c.positional(0)
+	if c.action == "export" && path == "" {
+		return c.usageError("cdr export requires a FILE")
+	}
+	f := c.args.Flags
+	filter, err := ParseCDRFilter(f["from"], f["to"], f["src"], f["dst"], f["disposition"], f["trunk"])
+	if err != nil {
+		return c.usageError("%v", err)
+	}
+	limit := DefaultCDRLimit
+	if value, ok := f["limit"]; ok {
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+			return c.usageError("--limit must be a positive number")
+		}
+	}
+
+	// The database is optional, records fall back to Master.csv
+	var known []string
+	if err := c.connectDB(); err == nil {
+		if exts, err := GetExtensions(c.db); err == nil {
+			for _, ext := range exts {
+				known = append(known, ext.ExtensionNumber)
+			}
+		}
+	}
+	records, _, err := LoadCDRs(c.db, filter, cdrCSVPath(), limit)
+	if err != nil {
+		return c.fail(cliExitFailed, "%v", err)
+	}
+
+	switch c.action {
+	case "summary":
+		out := &cliOutput{Columns: []string{"extension", "calls", "answered", "minutes", "answer_rate"}}
+		for _, s := range SummarizeCDRs(records, known) {
+			out.addRow(s.Extension, s.Calls, s.Answered, fmt.Sprintf("%.1f", s.Minutes), fmt.Sprintf("%.0f%%", s.AnswerRate))
+		}
+		return c.print(out)
+	case "export":
+		if err := ExportCDRsFile(path, records); err != nil {
+			return c.fail(cliExitFailed, "%v", err)
+		}
+		return c.result(fmt.Sprintf("Exported %d call records to %s", len(records), path), "")
+	}
+	out := &cliOutput{Columns: []string{"calldate", "src", "dst", "disposition", "duration", "billsec", "channel", "dstchannel", "uniqueid"}}
+	for _, r := range records {
+		out.addRow(r.CallDate.Format(cdrTimeFormat), r.Src, r.Dst, r.Disposition, r.Duration, r.BillSec, r.Channel, r.DstChannel, r.UniqueID)
+	}
+	return c.print(out)
+}
+
+// cliProvision handles the provision subcommand
+func cliProvision(c *cliContext) int {
+	allowed := map[string][]string{
+		"render":  {"vendor"},
+		"dhcp":    {"server", "host"},
+		"rollout": {"username", "password", "vendor"},
+	}
+	if err := c.checkFlags(allowed[c.action]...); err != nil {
+		return c.usageError("%v", err)
+	}
+	settings := LoadProvisioningSettings()
+
+	switch c.action {
+	case "dhcp":
+		if host := c.args.Flags["host"]; host != "" {
+			settings.Host = host
+		}
+		server := c.args.Flags["server"]
+		out := &cliOutput{Columns: []string{"server", "file", "text"}}
+		var text strings.Builder
+		for _, snippet := range DHCPProvisioningSnippets(settings.URL()) {
+			if server != "" && snippet.Server != server {
+				continue
+			}
+			out.addRow(snippet.Server, snippet.File, snippet.Text)
+			fmt.Fprintf(&text, "# %s (%s)\n%s\n", snippet.Title, snippet.File, snippet.Text)
+		}
+		if len(out.Rows) == 0 {
+			return c.usageError("unknown DHCP server %q (use isc, dnsmasq or kea)", server)
+		}
+		if c.format == "table" {
+			fmt.Fprint(c.stdout, text.String())
+			return cliExitOK
+		}
+		return c.print(out)
+
+	case "rollout":
+		username, password, _ := c.phoneLogin()
+		if len(c.args.Positional) == 0 || password == "" {
+			return c.usageError("phone IPs and RAYANPBX_PHONE_PASSWORD are required")
+		}
+		credentials := map[string]string{"username": username, "password": password}
+		var targets []ProvisioningRolloutTarget
+		for _, ip := range c.args.Positional {
+			targets = append(targets, ProvisioningRolloutTarget{IP: ip, Vendor: c.args.Flags["vendor"], Credentials: credentials})
+		}
+		results := RolloutProvisioningServer(NewPhoneManager(NewAsteriskManager()), targets, settings)
+		out := &cliOutput{Columns: []string{"ip", "vendor", "ok", "message"}}
+		failed := 0
+		for _, result := range results {
+			if !result.OK {
+				failed++
+			}
+			out.addRow(result.IP, result.Vendor, result.OK, result.Message)
+		}
+		if code := c.print(out); code != cliExitOK {
+			return code
+		}
+		if failed == len(results) {
+			return cliExitFailed
+		}
+		if failed > 0 {
+			return cliExitWarning
+		}
+		return cliExitOK
+
+	case "url":
+		out := &cliOutput{Columns: []string{"protocol", "url"}}
+		out.addRow("http", settings.URL())
+		if tftp := settings.TFTPURL(); tftp != "" {
+			out.addRow("tftp", tftp)
+		}
+		if host, port, ok := settings.PhonebookLDAPAddress(); ok {
+			out.addRow("ldap", "ldap://"+net.JoinHostPort(host, port)+"/"+settings.PhonebookBase())
+		}
+		return c.print(out)
+
+	case "render", "serve":
+		mac := normalizeMAC(c.positional(0))
+		if c.action == "render" && mac == "" {
+			return c.usageError("provision render requires a MAC address")
+		}
+		if err := c.connectDB(); err != nil {
+			return c.fail(cliExitFailed, "%v", err)
+		}
+		server, err := NewProvisioningServer(settings, DBProvisioningLookup(c.db))
+		if err != nil {
+			return c.fail(cliExitFailed, "%v", err)
+		}
+		if c.action == "serve" {
+			server.SetPhonebook(DBPhonebookSource(c.db))
+			return cliServeProvisioning(c, server)
+		}
+
+		phone, err := DBProvisioningLookup(c.db)(mac)
+		if err != nil {
+			return c.fail(cliExitFailed, "%v", err)
+		}
+		if phone == nil || len(phone.Lines) == 0 {
+			return c.fail(cliExitNotFound, "no extensions assigned to phone %s", mac)
+		}
+		vendor := c.args.Flags["vendor"]
+		if vendor == "" {
+			vendor = strings.ToLower(phone.Vendor)
+		}
+		if vendor == "" {
+			vendor = phoneDriverVendor(PhoneDriverForMAC(mac))
+		}
+		data, err := server.RenderPhone(vendor, phone)
+		if err != nil {
+			return c.fail(cliExitFailed, "%v", err)
+		}
+		c.stdout.Write(data)
+		return cliExitOK
+	}
+	return c.usageError("unknown action %q for provision", c.action)
+}
+
+// cliServeProvisioning runs the provisioning server until interrupted
+func cliServeProvisioning(c *cliContext, server *ProvisioningServer) int {
+	if err := server.Start(); err != nil {
+		return c.fail(cliExitFailed, "%v", err)
+	}
+	defer server.Stop()
+	settings := server.Settings()
+	fmt.Fprintf(c.stderr, "Serving phone configs at %s\n", settings.URL())
+	if tftp := settings.TFTPURL(); tftp != "" {
+		fmt.Fprintf(c.stderr, "Serving phone configs at %s\n", tftp)
+	} else if settings.TFTPAddr != "" {
+		fmt.Fprintf(c.stderr, "Warning: TFTP refuses configs while PROVISIONING_USERNAME is set, phones must use HTTP\n")
+	}
+	fmt.Fprintf(c.stderr, "Serving the phonebook at %s%s\n", settings.URL(), strings.TrimPrefix(phonebookURLPrefix, "/"))
+	if host, port, ok := settings.PhonebookLDAPAddress(); ok {
+		fmt.Fprintf(c.stderr, "Serving the phonebook at ldap://%s/%s\n", net.JoinHostPort(host, port), settings.PhonebookBase())
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+	return cliExitOK
+}
+
+// cliPhonebook handles the phonebook subcommand
+func cliPhonebook(c *cliContext) int {
+	allowed := map[string][]string{
+		"list":   {"search"},
+		"add":    {"mobile", "company", "group"},
+		"render": {"search"},
+	}
+	if err := c.checkFlags(allowed[c.action]...); err != nil {
+		return c.usageError("%v", err)
+	}
+	path := phonebookFilePath()
+
+	switch c.action {
+	case "add":
+		if len(c.args.Positional) != 2 {
+			return c.usageError("phonebook add needs NAME NUMBER")
+		}
+		contact := PhonebookEntry{Name: c.positional(0), Number: c.positional(1), Mobile: c.args.Flags["mobile"],
+			Company: c.args.Flags["company"], Group: c.args.Flags["group"]}
+		if err := contact.Validate(); err != nil {
+			return c.usageError("%v", err)
+		}
+		if _, err := SavePhonebookContact(path, "", contact); err != nil {
+			return c.fail(cliExitFailed, "%v", err)
+		}
+		return c.result(fmt.Sprintf("Saved contact %s (%s)", contact.Name, contact.Number), "")
+
+	case "delete":
+		if len(c.args.Positional) != 1 {
+			return c.usageError("phonebook delete needs a NAME")
+		}
+		if _, err := DeletePhonebookContact(path, c.positional(0)); err != nil {
+			return c.fail(cliExitNotFound, "%v", err)
+		}
+		return c.result("Deleted contact "+c.positional(0), "")
+
+	case "list", "render":
+		format := ""
+		if c.action == "render" {
+			format = c.positional(0)
+			if _, err := RenderPhonebook(format, nil); err != nil {
+				return c.usageError("%v", err)
+			}
+		}
+		if err := c.connectDB(); err != nil {
+			return c.fail(cliExitFailed, "%v", err)
+		}
+		entries, err := DBPhonebookSource(c.db)()
+		if err != nil {
+			return c.fail(cliExitFailed, "%v", err)
+		}
+		entries = FilterPhonebook(entries, c.args.Flags["search"])
+		if c.action == "render" {
+			data, err := RenderPhonebook(format, entries)
+			if err != nil {
+				return c.fail(cliExitFailed, "%v", err)
+			}
+			c.stdout.Write(data)
+			return cliExitOK
+		}
+		out := &cliOutput{Columns: []string{"name", "number", "mobile", "company", "group", "source"}}
+		for _, e := range entries {
+			source := "contact"
+			if e.Extension {
+				source = "extension"
+			}
+			out.addRow(e.Name, e.Number, e.Mobile, e.Company, e.Group, source)
+		}
+		return c.print(out)
+	}
+	return c.usageError("unknown action %q for phonebook", c.action)
+}
+
+// cliFirmware handles the firmware subcommand
+func cliFirmware(c *cliContext) int {
+	selection := []string{"filter", "vendor", "model", "firmware-version", "subnet", "extensions", "ip"}
+	allowed := map[string][]string{
+		"status":  selection,
+		"upgrade": append(selection, "canary", "workers", "timeout", "password", "username", "report"),
+	}
+	if err := c.checkFlags(allowed[c.action]...); err != nil {
+		return c.usageError("%v", err)
+	}
+	settings := LoadProvisioningSettings()
+	repo := NewFirmwareRepository(settings.FirmwareDir)
+	images, err := repo.Images()
+	if err != nil {
+		return c.fail(cliExitFailed, "%v", err)
+	}
+	policy, err := LoadFirmwarePolicy(firmwarePolicyFilePath())
+	if err != nil {
+		return c.fail(cliExitFailed, "%v", err)
+	}
+
+	switch c.action {
+	case "list":
+		out := &cliOutput{Columns: []string{"vendor", "model", "version", "file", "size", "target", "url"}}
+		for _, image := range images {
+			target := FindFirmwareTarget(policy, image.Vendor, image.Model)
+			out.addRow(image.Vendor, image.Model, image.Version, image.File, image.Size,
+				target != nil && target.Version == image.Version, FirmwareURL(settings, image))
+		}
+		return c.print(out)
+
+	case "add":
+		if len(c.args.Positional) != 4 {
+			return c.usageError("firmware add needs VENDOR MODEL VERSION FILE")
+		}
+		image, err := repo.Add(c.positional(0), c.positional(1), c.positional(2), c.positional(3))
+		if err != nil {
+			return c.fail(cliExitFailed, "%v", err)
+		}
+		return c.result(fmt.Sprintf("Added %s %s firmware %s at %s", image.Vendor, image.Model, image.Version, FirmwareURL(settings, image)), "")
+
+	case "target":
+		if len(c.args.Positional) != 3 {
+			return c.usageError("firmware target needs VENDOR MODEL VERSION (or none to remove the target)")
+		}
+		target := FirmwareTarget{Vendor: c.positional(0), Model: c.positional(1), Version: c.positional(2)}
+		if target.Version == "none" {
+			target.Version = ""
+		}
+		if _, err := SetFirmwareTarget(firmwarePolicyFilePath(), target, images); err != nil {
+			return c.fail(cliExitFailed, "%v", err)
+		}
+		if target.Version == "" {
+			return c.result(fmt.Sprintf("Removed the firmware target of %s %s", target.Vendor, target.Model), "")
+		}
+		return c.result(fmt.Sprintf("%s %s phones should run %s", target.Vendor, target.Model, target.Version), "")
+
+	case "status":
+		phones, _, code := cliSelectPhones(c)
+		if code != cliExitOK {
+			return code
+		}
+		entries := CheckFirmwareCompliance(phones, policy, images)
+		out := &cliOutput{Columns: []string{"ip", "mac", "vendor", "model", "firmware", "target", "status", "image"}}
+		outOfPolicy := 0
+		for _, entry := range entries {
+			if entry.OutOfPolicy() {
+				outOfPolicy++
+			}
+			out.addRow(entry.Phone.IP, entry.Phone.MAC, entry.Phone.Vendor, entry.Phone.Model, entry.Phone.Firmware,
+				entry.Target, entry.Status, entry.Image != nil)
+		}
+		if code := c.print(out); code != cliExitOK {
+			return code
+		}
+		if outOfPolicy > 0 {
+			return cliExitWarning
+		}
+		return cliExitOK
+
+	case "upgrade":
+		flags := c.args.Flags
+		u := FirmwareUpgrade{CanaryCount: DefaultUpgradeCanaries, Workers: DefaultBulkWorkers,
+			Timeout: DefaultUpgradeTimeout, PollInterval: DefaultUpgradePollInterval}
+		if value, ok := flags["canary"]; ok {
+			if u.Canaries, u.CanaryCount, err = ParseCanarySpec(value); err != nil {
+				return c.usageError("%v", err)
+			}
+		}
+		if value, ok := flags["workers"]; ok {
+			if u.Workers, err = strconv.Atoi(value); err != nil {
+				return c.usageError("--workers must be a number")
+			}
+		}
+		if value, ok := flags["timeout"]; ok {
+			seconds, err := strconv.Atoi(value)
+			if err != nil {
+				return c.usageError("--timeout must be a number of seconds")
+			}
+			u.Timeout = time.Duration(seconds) * time.Second
+		}
+		if err := u.Validate(); err != nil {
+			return c.usageError("%v", err)
+		}
+		phones, _, code := cliSelectPhones(c)
+		if code != cliExitOK {
+			return code
+		}
+		targets := FirmwareUpgradeTargets(CheckFirmwareCompliance(phones, policy, images))
+		if len(targets) == 0 {
+			return c.result("No outdated phones with a target image in the repository", "")
+		}
+		canary, rest, err := SplitCanaryTargets(targets, u)
+		if err != nil {
+			return c.usageError("%v", err)
+		}
+
+		fmt.Fprintf(c.stderr, "Upgrading %d canary phone(s), then %d more, from %s\n", len(canary), len(rest), settings.URL())
+		var mu sync.Mutex
+		report := RunStagedUpgrade(NewPhoneManager(NewAsteriskManager()), canary, rest, u, settings, func(result BulkPhoneResult) {
+			mu.Lock()
+			defer mu.Unlock()
+			fmt.Fprintf(c.stderr, "%s %s: %s %s\n", result.Stage, result.IP, result.Status, result.Message)
+		})
+		if c.connectDB() == nil {
+			recordUpgradedFirmware(c.db, report)
+		}
+		if path := flags["report"]; path != "" {
+			if err := WriteBulkReport(path, report); err != nil {
+				return c.fail(cliExitFailed, "%v", err)
+			}
+		}
+		out := &cliOutput{Columns: []string{"stage", "ip", "mac", "vendor", "status", "firmware", "message", "duration_ms"}}
+		for _, result := range report.Results {
+			out.addRow(result.Stage, result.IP, result.MAC, result.Vendor, result.Status, result.Firmware, result.Message, result.DurationMS)
+		}
+		if code := c.print(out); code != cliExitOK {
+			return code
+		}
+		if report.Succeeded == 0 {
+			return cliExitFailed
+		}
+		if report.Failed > 0 || report.Skipped > 0 {
+			return cliExitWarning
+		}
+		return cliExitOK
+	}
+	return c.usageError("unknown action %q for firmware", c.action)
+}
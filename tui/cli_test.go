@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestParseCLIArgs tests splitting positional arguments from flag values
+func TestParseCLIArgs(t *testing.T) {
+	args, err := parseCLIArgs([]string{"add", "101", "--name", "Alice Smith", "--codecs=g722,ulaw", "--output", "json"})
+	if err != nil {
+		t.Fatalf("parseCLIArgs failed: %v", err)
+	}
+	if strings.Join(args.Positional, " ") != "add 101" {
+		t.Errorf("Unexpected positional arguments: %v", args.Positional)
+	}
+	if args.Flags["name"] != "Alice Smith" || args.Flags["codecs"] != "g722,ulaw" || args.Flags["output"] != "json" {
+		t.Errorf("Unexpected flags: %v", args.Flags)
+	}
+
+	if _, err := parseCLIArgs([]string{"add", "--name"}); err == nil {
+		t.Error("Expected error for flag without a value")
+	}
+	if args, err := parseCLIArgs([]string{"--password="}); err != nil || args.Flags["password"] != "" {
+		t.Errorf("Expected empty value to be accepted, got %v (%v)", args, err)
+	}
+}
+
+// TestFormatCLIOutput tests table, JSON and YAML rendering
+func TestFormatCLIOutput(t *testing.T) {
+	out := &cliOutput{Columns: []string{"number", "name", "enabled", "differences"}}
+	out.addRow("101", "Alice", true, []string{})
+	out.addRow("102", "yes", false, []string{"context differs", "codecs: g722"})
+
+	table, err := formatCLIOutput(out, "table")
+	if err != nil {
+		t.Fatalf("table failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimRight(table, "\n"), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "NUMBER  NAME   ENABLED  DIFFERENCES") ||
+		!strings.HasPrefix(lines[1], "101     Alice  yes      -") || !strings.HasSuffix(lines[2], "context differs; codecs: g722") {
+		t.Errorf("Unexpected table:\n%s", table)
+	}
+
+	data, err := formatCLIOutput(out, "json")
+	if err != nil {
+		t.Fatalf("json failed: %v", err)
+	}
+	var records []map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &records); err != nil {
+		t.Fatalf("Invalid JSON: %v\n%s", err, data)
+	}
+	if len(records) != 2 || records[0]["number"] != "101" || records[0]["enabled"] != true {
+		t.Errorf("Unexpected JSON: %s", data)
+	}
+
+	yaml, err := formatCLIOutput(out, "yaml")
+	if err != nil {
+		t.Fatalf("yaml failed: %v", err)
+	}
+	want := `- number: "101"
+  name: Alice
+  enabled: true
+  differences: []
+- number: "102"
+  name: "yes"
+  enabled: false
+  differences: ["context differs","codecs: g722"]
+`
+	if yaml != want {
+		t.Errorf("Unexpected YAML:\n%s\nwant:\n%s", yaml, want)
+	}
+
+	single := &cliOutput{Columns: []string{"status", "message"}, Single: true}
+	single.addRow("ok", "Extension 101 created and activated!")
+	if yaml, _ := formatCLIOutput(single, "yaml"); yaml != "status: ok\nmessage: Extension 101 created and activated!\n" {
+		t.Errorf("Unexpected single YAML:\n%s", yaml)
+	}
+	if data, _ := formatCLIOutput(single, "json"); !strings.HasPrefix(data, "{") {
+		t.Errorf("Expected a JSON object for a single result, got %s", data)
+	}
+	if yaml, _ := formatCLIOutput(&cliOutput{Columns: []string{"name"}}, "yaml"); yaml != "[]\n" {
+		t.Errorf("Expected empty YAML sequence, got %q", yaml)
+	}
+	if _, err := formatCLIOutput(out, "xml"); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+}
+
+// TestRunCLIUsageErrors tests that usage errors are reported before touching the system
+func TestRunCLIUsageErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"no command", nil, "missing command"},
+		{"unknown command", []string{"dialplan", "list"}, "unknown command"},
+		{"missing action", []string{"extensions"}, "missing action"},
+		{"unknown action", []string{"trunks", "rename", "provider"}, "unknown action"},
+		{"bad output", []string{"extensions", "list", "--output", "xml"}, "unknown output format"},
+		{"unknown flag", []string{"extensions", "delete", "101", "--name", "x"}, "unknown option(s) for extensions delete: --name"},
+		{"missing number", []string{"extensions", "add", "--name", "Alice"}, "extension number is required"},
+		{"bad port", []string{"trunks", "add", "provider", "--host", "sip.example.com", "--port", "abc"}, "--port must be a number"},
+		{"bad direction", []string{"sync", "apply", "--direction", "sideways"}, "unknown direction"},
+		{"missing password", []string{"phones", "reboot", "192.168.1.50"}, "RAYANPBX_PHONE_PASSWORD are required"},
+		{"bad vendor", []string{"phones", "reboot", "192.168.1.50", "--password", "x", "--vendor", "acme"}, "unsupported vendor"},
+		{"bad module", []string{"asterisk", "reload", "--module", "sip"}, "unknown module"},
+		{"missing value", []string{"config", "set", "SIP_PORT"}, "requires KEY and VALUE"},
+		{"missing export file", []string{"cdr", "export", "--from", "2024-05-01"}, "requires a FILE"},
+		{"bad cdr date", []string{"cdr", "list", "--from", "yesterday"}, "invalid date"},
+		{"bad limit", []string{"cdr", "summary", "--limit", "0"}, "--limit must be a positive number"},
+		{"bad canary", []string{"firmware", "upgrade", "--canary", "-1"}, "canary phones cannot be negative"},
+		{"missing firmware file", []string{"firmware", "add", "yealink", "T46S", "66.86.0.15"}, "VENDOR MODEL VERSION FILE"},
+		{"restore without version", []string{"phones", "restore", "80:5e:c0:11:22:33"}, "--version are required"},
+		{"bad diff version", []string{"phones", "diff", "80:5e:c0:11:22:33", "--from", "latest"}, "--from must be a snapshot version"},
+		{"bad key layout", []string{"phones", "key-profile", "desk", "blf:101"}, "POSITION=TYPE"},
+		{"bad phonebook number", []string{"phonebook", "add", "Support", "12-34"}, "invalid number"},
+		{"bad phonebook format", []string{"phonebook", "render", "polycom"}, "unknown phonebook format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := runCLIWithOutput(tt.args, &stdout, &stderr)
+			if code != cliExitUsage {
+				t.Errorf("Expected exit code %d, got %d", cliExitUsage, code)
+			}
+			if !strings.Contains(stderr.String(), tt.want) || stdout.Len() != 0 {
+				t.Errorf("Expected %q on stderr only, got stdout %q, stderr %q", tt.want, stdout.String(), stderr.String())
+			}
+		})
+	}
+}
+
+// TestCLIPhoneLogin tests that phone passwords come from the environment and that
+// --password still works with a warning
+func TestCLIPhoneLogin(t *testing.T) {
+	t.Setenv("RAYANPBX_PHONE_PASSWORD", "from-env")
+	var stderr bytes.Buffer
+	c := &cliContext{stderr: &stderr, config: &Config{}, args: &cliArgs{Flags: map[string]string{}}}
+	if username, password, override := c.phoneLogin(); username != "admin" || password != "from-env" || override {
+		t.Errorf("Expected the environment login, got %s %s %v", username, password, override)
+	}
+	if stderr.Len() != 0 {
+		t.Errorf("Expected no warning, got %q", stderr.String())
+	}
+
+	c.args.Flags = map[string]string{"password": "from-flag", "username": "root"}
+	if username, password, override := c.phoneLogin(); username != "root" || password != "from-flag" || !override {
+		t.Errorf("Expected the --password login, got %s %s %v", username, password, override)
+	}
+	if !strings.Contains(stderr.String(), "visible in process lists") {
+		t.Errorf("Expected a warning for --password, got %q", stderr.String())
+	}
+}
+
+// TestCLIConfig tests reading and writing .env values
+func TestCLIConfig(t *testing.T) {
+	envPath := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(envPath, []byte("# Database\nDB_HOST=127.0.0.1\nDB_PASSWORD=secret\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(args ...string) (int, string, string) {
+		var stdout, stderr bytes.Buffer
+		parsed, err := parseCLIArgs(args[1:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := &cliContext{command: "config", action: args[0], args: parsed, format: "json",
+			stdout: &stdout, stderr: &stderr, env: &ConfigManager{envPath: envPath}}
+		return cliConfig(c), stdout.String(), stderr.String()
+	}
+
+	if code, out, _ := run("get", "DB_PASSWORD"); code != cliExitOK || !strings.Contains(out, `"value": "secret"`) {
+		t.Errorf("Expected secret value for explicit key, got %d %s", code, out)
+	}
+	if code, out, _ := run("get"); code != cliExitOK || strings.Contains(out, "secret") || !strings.Contains(out, `"value": "127.0.0.1"`) {
+		t.Errorf("Expected masked listing, got %d %s", code, out)
+	}
+	if code, _, stderr := run("get", "MISSING_KEY"); code != cliExitNotFound || !strings.Contains(stderr, "key not found") {
+		t.Errorf("Expected not found, got %d %s", code, stderr)
+	}
+
+	if code, out, _ := run("set", "DB_HOST", "10.0.0.5"); code != cliExitOK || !strings.Contains(out, "Updated DB_HOST") {
+		t.Errorf("Unexpected update result %d %s", code, out)
+	}
+	if code, out, _ := run("set", "SIP_PORT", "5080"); code != cliExitOK || !strings.Contains(out, "Added SIP_PORT") {
+		t.Errorf("Unexpected add result %d %s", code, out)
+	}
+	if code, _, _ := run("set", "bad-key", "x"); code != cliExitFailed {
+		t.Errorf("Expected invalid key to fail, got %d", code)
+	}
+	content, _ := os.ReadFile(envPath)
+	if !strings.Contains(string(content), "DB_HOST=10.0.0.5\n") || !strings.Contains(string(content), "SIP_PORT=5080\n") {
+		t.Errorf("Unexpected .env content:\n%s", content)
+	}
+}
@@ -36,6 +36,10 @@ type Config struct {
 	AppEnv        string
 	AppDebug      bool
 	NetworkSubnet string
+	AMIHost       string
+	AMIPort       string
+	AMIUsername   string
+	AMISecret     string
 }
 
 // LoadConfig loads configuration from multiple .env file paths in priority order.
@@ -104,6 +108,10 @@ func LoadConfig() (*Config, error) {
 		AppEnv:        getEnv("APP_ENV", "production"),
 		AppDebug:      getEnv("APP_DEBUG", "false") == "true",
 		NetworkSubnet: getEnv("NETWORK_SUBNET", "192.168.1.0/24"),
+		AMIHost:       getEnv("ASTERISK_AMI_HOST", "127.0.0.1"),
+		AMIPort:       getEnv("ASTERISK_AMI_PORT", DefaultAMIPort),
+		AMIUsername:   getEnv("ASTERISK_AMI_USERNAME", "admin"),
+		AMISecret:     getEnv("ASTERISK_AMI_SECRET", ""),
 	}
 
 	return config, nil
@@ -150,7 +158,7 @@ func getEnv(key, defaultValue string) string {
 
 // ConnectDB connects to MySQL database
 func ConnectDB(config *Config) (*sql.DB, error) {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true",
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true&timeout=10s",
 		config.DBUsername,
 		config.DBPassword,
 		config.DBHost,
@@ -212,6 +220,9 @@ type Extension struct {
 	Codecs           string // Comma-separated list of codecs (e.g., "ulaw,alaw,g722")
 	DirectMedia      string // "yes" or "no"
 	QualifyFrequency int    // Seconds between qualify checks
+	Profile          string // Name of the ExtensionProfile this extension inherits from (optional)
+	MediaEncryption  string // "no", "sdes" or "dtls" (SRTP)
+	NAT              bool   // Phone behind NAT: rewrite_contact, force_rport and rtp_symmetric
 	CreatedAt        string
 	UpdatedAt        string
 }
@@ -232,7 +243,8 @@ func GetExtensions(db *sql.DB) ([]Extension, error) {
 	query := `SELECT id, extension_number, name, COALESCE(secret, ''), COALESCE(email, ''), 
 	          enabled, COALESCE(context, 'from-internal'), COALESCE(transport, 'transport-udp'), 
 	          COALESCE(caller_id, ''), COALESCE(max_contacts, 1), COALESCE(voicemail_enabled, 0),
-	          COALESCE(codecs, '["ulaw","alaw","g722"]'), COALESCE(direct_media, 'no'), COALESCE(qualify_frequency, 60)
+	          COALESCE(codecs, '["ulaw","alaw","g722"]'), COALESCE(direct_media, 'no'), COALESCE(qualify_frequency, 60),
+	          COALESCE(profile, ''), COALESCE(media_encryption, 'no'), COALESCE(nat, 0)
 	          FROM extensions ORDER BY extension_number`
 	rows, err := db.Query(query)
 	if err != nil {
@@ -246,7 +258,7 @@ func GetExtensions(db *sql.DB) ([]Extension, error) {
 		var codecsJSON string
 		if err := rows.Scan(&ext.ID, &ext.ExtensionNumber, &ext.Name, &ext.Secret, &ext.Email,
 			&ext.Enabled, &ext.Context, &ext.Transport, &ext.CallerID, &ext.MaxContacts, &ext.VoicemailEnabled,
-			&codecsJSON, &ext.DirectMedia, &ext.QualifyFrequency); err != nil {
+			&codecsJSON, &ext.DirectMedia, &ext.QualifyFrequency, &ext.Profile, &ext.MediaEncryption, &ext.NAT); err != nil {
 			continue
 		}
 		// Convert JSON array to comma-separated string for TUI display
@@ -398,7 +410,7 @@ func GetVoIPPhones(db *sql.DB) ([]VoIPPhoneDB, error) {
 	          COALESCE(vendor, 'grandstream'), COALESCE(model, ''), COALESCE(firmware, ''),
 	          COALESCE(status, 'discovered'), COALESCE(discovery_type, ''), COALESCE(user_agent, ''),
 	          COALESCE(cti_enabled, 0), COALESCE(snmp_enabled, 0), COALESCE(last_seen, '')
-	          FROM voip_phones ORDER BY last_seen DESC`
+	          FROM voip_phones WHERE ip IS NOT NULL ORDER BY last_seen DESC`
 	rows, err := db.Query(query)
 	if err != nil {
 		// Table might not exist yet
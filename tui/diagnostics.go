@@ -1,6 +1,7 @@
 package main
 
 import (
+	"database/sql"
 	"fmt"
 	"os/exec"
 	"strings"
@@ -10,7 +11,13 @@ import (
 
 // DiagnosticsManager handles diagnostics and debugging operations
 type DiagnosticsManager struct {
-	asterisk *AsteriskManager
+	asterisk      *AsteriskManager
+	configManager *AsteriskConfigManager // Optional, used to find TLS certificates
+	db            *sql.DB                // Optional, used by health checks
+	syncManager   *ExtensionSyncManager  // Optional, used by health checks
+	callQuality   *CallQualityMonitor    // Created on first use
+	ami           *AMIClient             // AMI connection following RTCP events
+	amiDone       chan struct{}          // Closed when the AMI event loop stops
 }
 
 // NewDiagnosticsManager creates a new diagnostics manager
@@ -255,41 +262,48 @@ func (dm *DiagnosticsManager) RunHealthCheck() {
 	cyan.Println("\n🏥 Running Health Check...")
 	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 
-	// Check Asterisk service
-	fmt.Print("Asterisk Service: ")
-	status, err := dm.asterisk.GetServiceStatus()
-	if err == nil && status == "running" {
-		green.Println("✅ Running")
-	} else {
-		red.Println("❌ Not Running")
-		// Show errors if service is not running
-		dm.ShowAsteriskErrors()
-	}
-
-	// Check PJSIP endpoints
-	fmt.Print("PJSIP Endpoints: ")
-	if output, err := dm.asterisk.ExecuteCLICommand("pjsip show endpoints"); err == nil {
-		if !strings.Contains(output, "No objects found") {
-			green.Println("✅ Configured")
-		} else {
-			yellow.Println("⚠️  None configured")
+	report := dm.RunHealthChecks()
+	for _, check := range report.Checks {
+		fmt.Printf("%s: ", check.Name)
+		switch check.Status {
+		case HealthOK:
+			green.Printf("%s %s\n", check.Status.Icon(), check.Message)
+		case HealthWarn:
+			yellow.Printf("%s %s\n", check.Status.Icon(), check.Message)
+		default:
+			red.Printf("%s %s\n", check.Status.Icon(), check.Message)
+		}
+		if check.Remediation != "" && check.Status != HealthOK {
+			fmt.Printf("   💡 %s\n", check.Remediation)
 		}
-	} else {
-		red.Println("❌ Error checking")
 	}
 
-	// Check active channels
+	// Active channels are informational and not part of the structured checks
 	fmt.Print("Active Channels: ")
-	if output, err := dm.asterisk.ExecuteCLICommand("core show channels count"); err == nil {
-		green.Printf("✅ %s", strings.TrimSpace(output))
+	if channels, err := dm.activeChannels(); err == nil {
+		green.Printf("✅ %s\n", channels)
 	} else {
 		red.Println("❌ Error checking")
 	}
 
+	// Show errors if service is not running
+	if report.AsteriskDown() {
+		dm.ShowAsteriskErrors()
+	}
+
 	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 	fmt.Println()
 }
 
+// activeChannels returns the output of "core show channels count"
+func (dm *DiagnosticsManager) activeChannels() (string, error) {
+	output, err := dm.asterisk.ExecuteCLICommand("core show channels count")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(output), nil
+}
+
 // GetHealthCheckOutput returns health check results as a string (for TUI use)
 func (dm *DiagnosticsManager) GetHealthCheckOutput() string {
 	var result strings.Builder
@@ -297,13 +311,11 @@ func (dm *DiagnosticsManager) GetHealthCheckOutput() string {
 	result.WriteString("🏥 Health Check Results\n")
 	result.WriteString("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n\n")
 
-	// Check Asterisk service
-	status, err := dm.asterisk.GetServiceStatus()
-	if err == nil && status == "running" {
-		result.WriteString("Asterisk Service: ✅ Running\n")
-	} else {
-		result.WriteString("Asterisk Service: ❌ Not Running\n")
-		// Get error summary
+	report := dm.RunHealthChecks()
+	result.WriteString(report.Text())
+
+	// Show recent errors if the Asterisk service is not running
+	if report.AsteriskDown() {
 		errors, _ := dm.GetAsteriskErrorsSummary()
 		if len(errors) > 0 {
 			result.WriteString("\nRecent Errors:\n")
@@ -316,29 +328,31 @@ func (dm *DiagnosticsManager) GetHealthCheckOutput() string {
 		}
 	}
 
-	// Check PJSIP endpoints
-	if output, err := dm.asterisk.ExecuteCLICommand("pjsip show endpoints"); err == nil {
-		if !strings.Contains(output, "No objects found") {
-			result.WriteString("PJSIP Endpoints: ✅ Configured\n")
-		} else {
-			result.WriteString("PJSIP Endpoints: ⚠️  None configured\n")
-		}
+	// Active channels are informational and not part of the structured checks
+	if channels, err := dm.activeChannels(); err == nil {
+		result.WriteString(fmt.Sprintf("\nActive Channels: ✅ %s\n", channels))
 	} else {
-		result.WriteString("PJSIP Endpoints: ❌ Error checking\n")
+		result.WriteString("\nActive Channels: ❌ Error checking\n")
 	}
 
-	// Check active channels
-	if output, err := dm.asterisk.ExecuteCLICommand("core show channels count"); err == nil {
-		result.WriteString(fmt.Sprintf("Active Channels: %s\n", strings.TrimSpace(output)))
-	} else {
-		result.WriteString("Active Channels: ❌ Error checking\n")
-	}
-
-	result.WriteString("\n━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	result.WriteString(fmt.Sprintf("\nOverall: %s %s\n", report.Status.Icon(), strings.ToUpper(string(report.Status))))
+	result.WriteString("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
 
 	return result.String()
 }
 
+// certificateStatuses checks the certificates used by the TLS and WSS transports
+func (dm *DiagnosticsManager) certificateStatuses() []CertificateStatus {
+	if dm.configManager == nil {
+		return nil
+	}
+	var statuses []CertificateStatus
+	for _, file := range dm.configManager.TLSCertificateFiles() {
+		statuses = append(statuses, CheckCertificateExpiry(file))
+	}
+	return statuses
+}
+
 // ShowAsteriskErrors displays Asterisk service errors
 func (dm *DiagnosticsManager) ShowAsteriskErrors() {
 	red := color.New(color.FgRed, color.Bold)
@@ -0,0 +1,698 @@
+package main
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// bulkExtensionColumns is the canonical column order used for CSV export and import
+var bulkExtensionColumns = []string{
+	"number", "name", "secret", "email", "context", "transport", "codecs",
+	"caller_id", "max_contacts", "voicemail", "direct_media", "qualify_frequency", "enabled",
+	"media_encryption", "nat",
+}
+
+// bulkExtensionColumnAliases maps accepted header spellings to canonical column names
+var bulkExtensionColumnAliases = map[string]string{
+	"extension":         "number",
+	"extension_number":  "number",
+	"password":          "secret",
+	"callerid":          "caller_id",
+	"qualify":           "qualify_frequency",
+	"voicemail_enabled": "voicemail",
+}
+
+// BulkExtensionRecord is one extension as it appears in an import/export file
+type BulkExtensionRecord struct {
+	Number           string `json:"number"`
+	Name             string `json:"name"`
+	Secret           string `json:"secret,omitempty"`
+	Email            string `json:"email,omitempty"`
+	Context          string `json:"context,omitempty"`
+	Transport        string `json:"transport,omitempty"`
+	Codecs           string `json:"codecs,omitempty"`
+	CallerID         string `json:"caller_id,omitempty"`
+	MaxContacts      *int   `json:"max_contacts,omitempty"`
+	Voicemail        string `json:"voicemail,omitempty"`
+	DirectMedia      string `json:"direct_media,omitempty"`
+	QualifyFrequency *int   `json:"qualify_frequency,omitempty"`
+	Enabled          string `json:"enabled,omitempty"`
+	MediaEncryption  string `json:"media_encryption,omitempty"`
+	NAT              string `json:"nat,omitempty"`
+}
+
+// BulkImportRow is a parsed record together with its validation result
+type BulkImportRow struct {
+	Line            int // CSV line or JSON array index (1-based)
+	Record          BulkExtensionRecord
+	Extension       Extension
+	Action          string // "create" or "update"
+	GeneratedSecret bool
+	Errors          []string
+}
+
+// BulkImportPreview holds the validated rows of a bulk import before it is applied
+type BulkImportPreview struct {
+	Source string
+	Rows   []BulkImportRow
+}
+
+// Valid returns true when no row has validation errors
+func (p *BulkImportPreview) Valid() bool {
+	return p.ErrorCount() == 0
+}
+
+// ErrorCount returns the number of rows with validation errors
+func (p *BulkImportPreview) ErrorCount() int {
+	count := 0
+	for _, row := range p.Rows {
+		if len(row.Errors) > 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// Extensions returns the extensions that would be written by the import
+func (p *BulkImportPreview) Extensions() []Extension {
+	var exts []Extension
+	for _, row := range p.Rows {
+		exts = append(exts, row.Extension)
+	}
+	return exts
+}
+
+// Report renders the preview as a per-row summary
+func (p *BulkImportPreview) Report() string {
+	var sb strings.Builder
+	creates, updates, generated := 0, 0, 0
+	for _, row := range p.Rows {
+		if len(row.Errors) > 0 {
+			continue
+		}
+		if row.Action == "update" {
+			updates++
+		} else {
+			creates++
+		}
+		if row.GeneratedSecret {
+			generated++
+		}
+	}
+
+	sb.WriteString(fmt.Sprintf("Source: %s\n", p.Source))
+	sb.WriteString(fmt.Sprintf("Rows: %d • Create: %d • Update: %d • Errors: %d\n", len(p.Rows), creates, updates, p.ErrorCount()))
+	if generated > 0 {
+		sb.WriteString(fmt.Sprintf("Generated secrets: %d\n", generated))
+	}
+	sb.WriteString("\n")
+
+	for _, row := range p.Rows {
+		if len(row.Errors) > 0 {
+			sb.WriteString(fmt.Sprintf("❌ Row %d (%s): %s\n", row.Line, row.Record.Number, strings.Join(row.Errors, "; ")))
+			continue
+		}
+		note := ""
+		if row.GeneratedSecret {
+			note = " (secret generated)"
+		}
+		sb.WriteString(fmt.Sprintf("✅ Row %d: %s %s - %s%s\n", row.Line, row.Action, row.Extension.ExtensionNumber, row.Extension.Name, note))
+	}
+
+	return sb.String()
+}
+
+// normalizeBulkColumn maps a header cell to its canonical column name
+func normalizeBulkColumn(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.ReplaceAll(name, " ", "_")
+	name = strings.ReplaceAll(name, "-", "_")
+	if alias, ok := bulkExtensionColumnAliases[name]; ok {
+		return alias
+	}
+	return name
+}
+
+// ParseBulkExtensionsCSV parses a CSV file with a header row into bulk import rows
+func ParseBulkExtensionsCSV(r io.Reader) ([]BulkImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("CSV file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %v", err)
+	}
+
+	known := make(map[string]bool)
+	for _, col := range bulkExtensionColumns {
+		known[col] = true
+	}
+
+	columns := make([]string, len(header))
+	hasNumber := false
+	for i, cell := range header {
+		col := normalizeBulkColumn(strings.TrimPrefix(cell, "\ufeff"))
+		if !known[col] {
+			return nil, fmt.Errorf("unknown CSV column: %s", cell)
+		}
+		if col == "number" {
+			hasNumber = true
+		}
+		columns[i] = col
+	}
+	if !hasNumber {
+		return nil, fmt.Errorf("CSV header must contain a number column")
+	}
+
+	var rows []BulkImportRow
+	line := 1
+	for {
+		cells, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV line %d: %v", line, err)
+		}
+
+		row := BulkImportRow{Line: line}
+		empty := true
+		for i, cell := range cells {
+			cell = strings.TrimSpace(cell)
+			if cell != "" {
+				empty = false
+			}
+			if i >= len(columns) {
+				row.Errors = append(row.Errors, "too many columns")
+				break
+			}
+			if err := setBulkRecordField(&row.Record, columns[i], cell); err != nil {
+				row.Errors = append(row.Errors, err.Error())
+			}
+		}
+		if empty {
+			continue
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// setBulkRecordField assigns a CSV cell to the matching record field
+func setBulkRecordField(rec *BulkExtensionRecord, column, value string) error {
+	parseOptionalInt := func(v string) (*int, error) {
+		if v == "" {
+			return nil, nil
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a number", column)
+		}
+		return &n, nil
+	}
+
+	var err error
+	switch column {
+	case "number":
+		rec.Number = value
+	case "name":
+		rec.Name = value
+	case "secret":
+		rec.Secret = value
+	case "email":
+		rec.Email = value
+	case "context":
+		rec.Context = value
+	case "transport":
+		rec.Transport = value
+	case "codecs":
+		rec.Codecs = value
+	case "caller_id":
+		rec.CallerID = value
+	case "max_contacts":
+		rec.MaxContacts, err = parseOptionalInt(value)
+	case "voicemail":
+		rec.Voicemail = value
+	case "direct_media":
+		rec.DirectMedia = value
+	case "qualify_frequency":
+		rec.QualifyFrequency, err = parseOptionalInt(value)
+	case "enabled":
+		rec.Enabled = value
+	case "media_encryption":
+		rec.MediaEncryption = value
+	case "nat":
+		rec.NAT = value
+	}
+	return err
+}
+
+// ParseBulkExtensionsJSON parses a JSON array of extension records into bulk import rows
+func ParseBulkExtensionsJSON(r io.Reader) ([]BulkImportRow, error) {
+	var records []BulkExtensionRecord
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&records); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %v", err)
+	}
+
+	rows := make([]BulkImportRow, 0, len(records))
+	for i, rec := range records {
+		rows = append(rows, BulkImportRow{Line: i + 1, Record: rec})
+	}
+	return rows, nil
+}
+
+// LoadBulkExtensionsFile reads a .csv or .json file and validates it against the existing extensions
+func LoadBulkExtensionsFile(path string, existing []Extension) (*BulkImportPreview, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	var rows []BulkImportRow
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		rows, err = ParseBulkExtensionsCSV(f)
+	case ".json":
+		rows, err = ParseBulkExtensionsJSON(f)
+	default:
+		return nil, fmt.Errorf("unsupported file type %q (use .csv or .json)", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	preview := ValidateBulkImport(rows, existing)
+	preview.Source = path
+	return preview, nil
+}
+
+// parseBulkBool parses yes/no style flags, returning def when the value is empty
+func parseBulkBool(value string, def bool) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "":
+		return def, nil
+	case "1", "yes", "y", "true", "on", "enabled":
+		return true, nil
+	case "0", "no", "n", "false", "off", "disabled":
+		return false, nil
+	}
+	return def, fmt.Errorf("invalid yes/no value %q", value)
+}
+
+// ValidateBulkImport validates rows and marks each as a create, filled with defaults, or an update of
+// the stored extension that changes only the columns present in the row
+func ValidateBulkImport(rows []BulkImportRow, existing []Extension) *BulkImportPreview {
+	existingByNumber := make(map[string]Extension)
+	for _, ext := range existing {
+		existingByNumber[ext.ExtensionNumber] = ext
+	}
+
+	seen := make(map[string]int)
+	preview := &BulkImportPreview{}
+
+	for _, row := range rows {
+		rec := row.Record
+		rec.Number = strings.TrimSpace(rec.Number)
+
+		// Updates start from the stored extension so columns missing from the file keep their values
+		prev, update := existingByNumber[rec.Number]
+		ext := Extension{
+			ExtensionNumber:  rec.Number,
+			Name:             "Extension " + rec.Number,
+			Context:          DefaultExtensionContext,
+			Transport:        DefaultExtensionTransport,
+			Codecs:           DefaultCodecs,
+			DirectMedia:      DefaultDirectMedia,
+			MediaEncryption:  "no",
+			MaxContacts:      DefaultMaxContacts,
+			QualifyFrequency: DefaultQualifyFrequency,
+			Enabled:          true,
+		}
+		row.Action = "create"
+		if update {
+			ext = prev
+			row.Action = "update"
+		}
+		set := func(field *string, value string) {
+			if value = strings.TrimSpace(value); value != "" {
+				*field = value
+			}
+		}
+		set(&ext.Name, rec.Name)
+		set(&ext.Email, rec.Email)
+		set(&ext.Context, rec.Context)
+		set(&ext.Transport, rec.Transport)
+		set(&ext.CallerID, rec.CallerID)
+		set(&ext.Codecs, strings.ReplaceAll(rec.Codecs, " ", ""))
+
+		if !isValidExtension(rec.Number) {
+			row.Errors = append(row.Errors, "invalid extension number (alphanumeric, max 20 characters)")
+		} else if prev, dup := seen[rec.Number]; dup {
+			row.Errors = append(row.Errors, fmt.Sprintf("duplicate of row %d", prev))
+		} else {
+			seen[rec.Number] = row.Line
+		}
+
+		switch {
+		case rec.Secret != "":
+			if !isValidPassword(rec.Secret) {
+				row.Errors = append(row.Errors, "invalid secret (contains forbidden characters or is too long)")
+			}
+			ext.Secret = rec.Secret
+		case !update:
+			secret, err := generateExtensionSecret()
+			if err != nil {
+				row.Errors = append(row.Errors, fmt.Sprintf("failed to generate secret: %v", err))
+			}
+			ext.Secret = secret
+			row.GeneratedSecret = true
+		}
+
+		switch directMedia := strings.ToLower(strings.TrimSpace(rec.DirectMedia)); directMedia {
+		case "":
+		case "yes", "no":
+			ext.DirectMedia = directMedia
+		default:
+			row.Errors = append(row.Errors, "direct_media must be yes or no")
+		}
+
+		if strings.TrimSpace(rec.MediaEncryption) != "" {
+			if mode, err := parseMediaEncryption(rec.MediaEncryption); err != nil {
+				row.Errors = append(row.Errors, err.Error())
+			} else {
+				ext.MediaEncryption = mode
+			}
+		}
+
+		if rec.MaxContacts != nil {
+			if *rec.MaxContacts < 1 {
+				row.Errors = append(row.Errors, "max_contacts must be at least 1")
+			}
+			ext.MaxContacts = *rec.MaxContacts
+		}
+		if rec.QualifyFrequency != nil {
+			if *rec.QualifyFrequency < 0 {
+				row.Errors = append(row.Errors, "qualify_frequency must not be negative")
+			}
+			ext.QualifyFrequency = *rec.QualifyFrequency
+		}
+
+		var err error
+		if ext.VoicemailEnabled, err = parseBulkBool(rec.Voicemail, ext.VoicemailEnabled); err != nil {
+			row.Errors = append(row.Errors, "voicemail: "+err.Error())
+		}
+		if ext.Enabled, err = parseBulkBool(rec.Enabled, ext.Enabled); err != nil {
+			row.Errors = append(row.Errors, "enabled: "+err.Error())
+		}
+		if ext.NAT, err = parseBulkBool(rec.NAT, ext.NAT); err != nil {
+			row.Errors = append(row.Errors, "nat: "+err.Error())
+		}
+
+		row.Record = rec
+		row.Extension = ext
+		preview.Rows = append(preview.Rows, row)
+	}
+
+	return preview
+}
+
+// generateExtensionSecret returns a random alphanumeric SIP secret
+func generateExtensionSecret() (string, error) {
+	const charset = "abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	secret := make([]byte, 16)
+	for i := range secret {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+		if err != nil {
+			return "", err
+		}
+		secret[i] = charset[n.Int64()]
+	}
+	return string(secret), nil
+}
+
+// bulkRecordFromExtension converts an extension to its export representation
+func bulkRecordFromExtension(ext Extension) BulkExtensionRecord {
+	maxContacts := ext.MaxContacts
+	qualify := ext.QualifyFrequency
+	return BulkExtensionRecord{
+		Number:           ext.ExtensionNumber,
+		Name:             ext.Name,
+		Secret:           ext.Secret,
+		Email:            ext.Email,
+		Context:          ext.Context,
+		Transport:        ext.Transport,
+		Codecs:           ext.Codecs,
+		CallerID:         ext.CallerID,
+		MaxContacts:      &maxContacts,
+		Voicemail:        yesNo(ext.VoicemailEnabled),
+		DirectMedia:      ext.DirectMedia,
+		QualifyFrequency: &qualify,
+		Enabled:          yesNo(ext.Enabled),
+		MediaEncryption:  ext.MediaEncryption,
+		NAT:              yesNo(ext.NAT),
+	}
+}
+
+// ExportExtensionsCSV writes extensions as CSV with a header row
+func ExportExtensionsCSV(w io.Writer, extensions []Extension) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(bulkExtensionColumns); err != nil {
+		return err
+	}
+	for _, ext := range extensions {
+		rec := bulkRecordFromExtension(ext)
+		if err := writer.Write([]string{
+			rec.Number, rec.Name, rec.Secret, rec.Email, rec.Context, rec.Transport, rec.Codecs,
+			rec.CallerID, strconv.Itoa(*rec.MaxContacts), rec.Voicemail, rec.DirectMedia,
+			strconv.Itoa(*rec.QualifyFrequency), rec.Enabled, rec.MediaEncryption, rec.NAT,
+		}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// ExportExtensionsJSON writes extensions as an indented JSON array
+func ExportExtensionsJSON(w io.Writer, extensions []Extension) error {
+	records := make([]BulkExtensionRecord, 0, len(extensions))
+	for _, ext := range extensions {
+		records = append(records, bulkRecordFromExtension(ext))
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(records)
+}
+
+// ExportBulkExtensionsFile writes extensions to a .csv or .json file
+func ExportBulkExtensionsFile(path string, extensions []Extension) error {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext != ".csv" && ext != ".json" {
+		return fmt.Errorf("unsupported file type %q (use .csv or .json)", filepath.Ext(path))
+	}
+
+	// Exports contain SIP secrets, keep them private
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", path, err)
+	}
+	defer f.Close()
+
+	if ext == ".csv" {
+		return ExportExtensionsCSV(f, extensions)
+	}
+	return ExportExtensionsJSON(f, extensions)
+}
+
+// ApplyBulkImport writes all rows to the database in one transaction, then
+// regenerates pjsip.conf once and reloads Asterisk once
+func ApplyBulkImport(db *sql.DB, configManager *AsteriskConfigManager, preview *BulkImportPreview) error {
+	if preview == nil || len(preview.Rows) == 0 {
+		return fmt.Errorf("nothing to import")
+	}
+	if !preview.Valid() {
+		return fmt.Errorf("%d row(s) have errors, fix them before importing", preview.ErrorCount())
+	}
+	if db == nil {
+		return fmt.Errorf("database not connected")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+
+	query := `INSERT INTO extensions (extension_number, name, secret, email, context, transport, caller_id, max_contacts,
+			  voicemail_enabled, codecs, direct_media, qualify_frequency, enabled, media_encryption, nat, created_at, updated_at)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NOW(), NOW()) AS new
+			  ON DUPLICATE KEY UPDATE name=new.name, secret=new.secret, email=new.email, context=new.context,
+			  transport=new.transport, caller_id=new.caller_id, max_contacts=new.max_contacts,
+			  voicemail_enabled=new.voicemail_enabled, codecs=new.codecs, direct_media=new.direct_media,
+			  qualify_frequency=new.qualify_frequency, enabled=new.enabled, media_encryption=new.media_encryption,
+			  nat=new.nat, updated_at=NOW()`
+
+	for _, row := range preview.Rows {
+		ext := row.Extension
+		if _, err := tx.Exec(query, ext.ExtensionNumber, ext.Name, ext.Secret, ext.Email, ext.Context, ext.Transport,
+			ext.CallerID, ext.MaxContacts, ext.VoicemailEnabled, codecsToJSON(ext.Codecs), ext.DirectMedia,
+			ext.QualifyFrequency, ext.Enabled, ext.MediaEncryption, ext.NAT); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to save extension %s (row %d): %v", ext.ExtensionNumber, row.Line, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	if configManager == nil {
+		return nil
+	}
+
+	if err := configManager.EnsureTransportConfig(); err != nil {
+		yellow := color.New(color.FgYellow)
+		yellow.Fprintf(configManager.out, "⚠️  Transport config warning: %v\n", err)
+	}
+
+	exts := preview.Extensions()
+	for _, ext := range exts {
+		if isWebRTCTransport(ext.Transport) {
+			if err := configManager.EnsureWebRTCConfig(); err != nil {
+				yellow := color.New(color.FgYellow)
+				yellow.Fprintf(configManager.out, "⚠️  WebRTC config warning: %v\n", err)
+			}
+			break
+		}
+	}
+	if err := configManager.WritePjsipExtensions(exts, fmt.Sprintf("Bulk import (%d extensions)", len(exts))); err != nil {
+		return fmt.Errorf("database updated but failed to write pjsip.conf: %v", err)
+	}
+
+	if err := configManager.ReloadAsterisk(); err != nil {
+		return fmt.Errorf("config written but failed to reload Asterisk: %v", err)
+	}
+
+	return nil
+}
+
+// initBulkExtensions opens the bulk import/export screen
+func (m *model) initBulkExtensions() {
+	m.currentScreen = bulkExtensionsScreen
+	m.inputMode = true
+	m.inputFields = []string{"File path (.csv or .json)", "Mode (import/export)"}
+	m.inputValues = []string{"/root/extensions.csv", "import"}
+	m.inputCursor = 0
+	m.bulkImportPreview = nil
+	m.bulkExtensionsOutput = ""
+	m.errorMsg = ""
+	m.successMsg = ""
+}
+
+// executeBulkExtensions previews an import or performs an export
+func (m *model) executeBulkExtensions() {
+	path := strings.TrimSpace(m.inputValues[0])
+	mode := strings.ToLower(strings.TrimSpace(m.inputValues[1]))
+	if path == "" {
+		m.errorMsg = "File path is required"
+		return
+	}
+
+	switch mode {
+	case "export":
+		exts, err := GetExtensions(m.db)
+		if err != nil {
+			m.errorMsg = fmt.Sprintf("Failed to load extensions: %v", err)
+			return
+		}
+		if err := ExportBulkExtensionsFile(path, exts); err != nil {
+			m.errorMsg = fmt.Sprintf("Export failed: %v", err)
+			return
+		}
+		m.inputMode = false
+		m.bulkExtensionsOutput = fmt.Sprintf("Exported %d extensions to %s\n", len(exts), path)
+		m.successMsg = fmt.Sprintf("✅ Exported %d extensions", len(exts))
+
+	case "import":
+		preview, err := LoadBulkExtensionsFile(path, m.extensions)
+		if err != nil {
+			m.errorMsg = err.Error()
+			return
+		}
+		m.inputMode = false
+		m.bulkImportPreview = preview
+		m.bulkExtensionsOutput = preview.Report()
+		if preview.Valid() {
+			m.successMsg = "Preview ready - review the rows and press 'y' to import"
+		} else {
+			m.errorMsg = fmt.Sprintf("%d row(s) have errors - fix the file and try again", preview.ErrorCount())
+		}
+
+	default:
+		m.errorMsg = "Mode must be 'import' or 'export'"
+	}
+}
+
+// applyBulkExtensions applies the previewed bulk import
+func (m *model) applyBulkExtensions() {
+	if m.bulkImportPreview == nil {
+		m.errorMsg = "Nothing to import. Run the preview first."
+		return
+	}
+
+	count := len(m.bulkImportPreview.Rows)
+	if err := ApplyBulkImport(m.db, m.configManager, m.bulkImportPreview); err != nil {
+		m.errorMsg = fmt.Sprintf("Bulk import failed: %v", err)
+		return
+	}
+
+	m.bulkImportPreview = nil
+	m.bulkExtensionsOutput += fmt.Sprintf("\nImported %d extensions\n", count)
+	m.successMsg = fmt.Sprintf("✅ Imported %d extensions", count)
+
+	if exts, err := GetExtensions(m.db); err == nil {
+		m.extensions = exts
+		m.loadExtensionSyncInfo()
+	}
+}
+
+// renderBulkExtensions renders the bulk import/export screen
+func (m model) renderBulkExtensions() string {
+	content := infoStyle.Render("📋 Bulk Import / Export Extensions") + "\n\n"
+
+	if m.inputMode {
+		content += helpStyle.Render("Columns: "+strings.Join(bulkExtensionColumns, ", ")) + "\n\n"
+		for i, field := range m.inputFields {
+			cursor := " "
+			if i == m.inputCursor {
+				cursor = "▶"
+				field = selectedItemStyle.Render(field)
+			}
+			content += fmt.Sprintf("%s %s: %s\n", cursor, field, m.inputValues[i])
+		}
+		content += "\n" + helpStyle.Render("Enter: Next/Run • ESC: Cancel")
+		return menuStyle.Render(content)
+	}
+
+	content += m.bulkExtensionsOutput
+	return menuStyle.Render(content)
+}
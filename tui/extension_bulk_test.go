@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestParseBulkExtensionsCSV tests CSV parsing with header aliases and defaults
+func TestParseBulkExtensionsCSV(t *testing.T) {
+	input := "Extension,Name,Password,Codecs,max_contacts,Voicemail\n" +
+		"101,Alice,Secret101,\"ulaw, g722\",2,yes\n" +
+		"\n" +
+		"102,,,,,\n"
+
+	rows, err := ParseBulkExtensionsCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseBulkExtensionsCSV failed: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("Expected 2 rows, got %d", len(rows))
+	}
+
+	stored := Extension{ID: 7, ExtensionNumber: "102", Name: "Bob", Secret: "Stored102", Context: "from-internal", Transport: "transport-tcp",
+		Codecs: "alaw", DirectMedia: "yes", MaxContacts: 3, QualifyFrequency: 30, Enabled: true, VoicemailEnabled: true, MediaEncryption: "sdes"}
+	preview := ValidateBulkImport(rows, []Extension{stored})
+	if !preview.Valid() {
+		t.Fatalf("Expected valid preview, got:\n%s", preview.Report())
+	}
+
+	alice := preview.Rows[0].Extension
+	if alice.Secret != "Secret101" || alice.Codecs != "ulaw,g722" || alice.MaxContacts != 2 || !alice.VoicemailEnabled {
+		t.Errorf("Unexpected extension for row 1: %+v", alice)
+	}
+	if alice.Context != DefaultExtensionContext || alice.QualifyFrequency != DefaultQualifyFrequency || !alice.Enabled {
+		t.Errorf("Expected defaults for row 1, got %+v", alice)
+	}
+	if preview.Rows[0].Action != "create" {
+		t.Errorf("Expected create for 101, got %s", preview.Rows[0].Action)
+	}
+
+	bob := preview.Rows[1]
+	if bob.Action != "update" || bob.Extension.ID != 7 {
+		t.Errorf("Expected update of existing 102, got %s (id %d)", bob.Action, bob.Extension.ID)
+	}
+	if bob.GeneratedSecret || bob.Extension.Secret != "Stored102" {
+		t.Errorf("Expected 102 to keep its secret, got %q (generated %v)", bob.Extension.Secret, bob.GeneratedSecret)
+	}
+	if bob.Extension != stored {
+		t.Errorf("Expected the blank row to keep the stored values, got %+v", bob.Extension)
+	}
+}
+
+// TestParseBulkExtensionsCSVHeaderErrors tests file-level CSV errors
+func TestParseBulkExtensionsCSVHeaderErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"empty", ""},
+		{"unknown column", "number,name,pin\n101,Alice,1234\n"},
+		{"missing number", "name,secret\nAlice,abc\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseBulkExtensionsCSV(strings.NewReader(tt.input)); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
+
+// TestValidateBulkImportErrors tests per-row validation errors
+func TestValidateBulkImportErrors(t *testing.T) {
+	input := "number,secret,direct_media,max_contacts,voicemail,media_encryption\n" +
+		"101,good,no,1,no,sdes\n" +
+		"10 1,good,,,,\n" +
+		"102,bad;secret,,,,\n" +
+		"103,good,maybe,,,\n" +
+		"104,good,,abc,,\n" +
+		"105,good,,0,,\n" +
+		"106,good,,,sometimes,\n" +
+		"107,good,,,,zrtp\n" +
+		"101,good,,,,\n"
+
+	rows, err := ParseBulkExtensionsCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseBulkExtensionsCSV failed: %v", err)
+	}
+	preview := ValidateBulkImport(rows, nil)
+
+	if len(preview.Rows[0].Errors) != 0 {
+		t.Errorf("Expected row 2 to be valid, got %v", preview.Rows[0].Errors)
+	}
+	for _, row := range preview.Rows[1:] {
+		if len(row.Errors) == 0 {
+			t.Errorf("Expected errors for line %d (%s)", row.Line, row.Record.Number)
+		}
+	}
+	if preview.ErrorCount() != 8 || preview.Valid() {
+		t.Errorf("Expected 8 invalid rows, got %d", preview.ErrorCount())
+	}
+	if !strings.Contains(preview.Report(), "duplicate of row 2") {
+		t.Errorf("Expected duplicate error in report:\n%s", preview.Report())
+	}
+	if err := ApplyBulkImport(nil, nil, preview); err == nil {
+		t.Error("Expected ApplyBulkImport to refuse a preview with errors")
+	}
+}
+
+// TestParseBulkExtensionsJSON tests JSON parsing
+func TestParseBulkExtensionsJSON(t *testing.T) {
+	input := `[{"number":"201","name":"Carol","qualify_frequency":0,"direct_media":"yes","enabled":"no"}]`
+	rows, err := ParseBulkExtensionsJSON(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseBulkExtensionsJSON failed: %v", err)
+	}
+	preview := ValidateBulkImport(rows, nil)
+	if !preview.Valid() {
+		t.Fatalf("Expected valid preview, got:\n%s", preview.Report())
+	}
+	ext := preview.Rows[0].Extension
+	if ext.QualifyFrequency != 0 || ext.DirectMedia != "yes" || ext.Enabled {
+		t.Errorf("Unexpected extension: %+v", ext)
+	}
+
+	if _, err := ParseBulkExtensionsJSON(strings.NewReader(`[{"number":"201","pin":"1"}]`)); err == nil {
+		t.Error("Expected error for unknown JSON field")
+	}
+}
+
+// TestExportBulkExtensionsRoundTrip tests that exported files can be imported again
+func TestExportBulkExtensionsRoundTrip(t *testing.T) {
+	exts := []Extension{
+		{ExtensionNumber: "101", Name: "Alice, Sales", Secret: "pw101", Email: "a@example.com", Enabled: true,
+			Context: "from-internal", Transport: "transport-tcp", CallerID: "\"Alice\" <101>", MaxContacts: 3,
+			VoicemailEnabled: true, Codecs: "opus,ulaw", DirectMedia: "no", QualifyFrequency: 30, MediaEncryption: "sdes"},
+		{ExtensionNumber: "102", Name: "Bob", Secret: "pw102", Context: "from-internal", Transport: "transport-udp",
+			MaxContacts: 1, Codecs: "ulaw", DirectMedia: "yes", QualifyFrequency: 60, MediaEncryption: "no"},
+	}
+
+	dir := t.TempDir()
+	for _, name := range []string{"exts.csv", "exts.json"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			if err := ExportBulkExtensionsFile(path, exts); err != nil {
+				t.Fatalf("Export failed: %v", err)
+			}
+			preview, err := LoadBulkExtensionsFile(path, nil)
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			if !preview.Valid() || len(preview.Rows) != 2 {
+				t.Fatalf("Expected 2 valid rows, got:\n%s", preview.Report())
+			}
+			for i, row := range preview.Rows {
+				if row.Extension != exts[i] {
+					t.Errorf("Round trip mismatch:\n got  %+v\n want %+v", row.Extension, exts[i])
+				}
+			}
+		})
+	}
+
+	if err := ExportBulkExtensionsFile(filepath.Join(dir, "exts.txt"), exts); err == nil {
+		t.Error("Expected error for unsupported export format")
+	}
+}
+
+// TestExportExtensionsCSVHeader tests the CSV header row
+func TestExportExtensionsCSVHeader(t *testing.T) {
+	var buf bytes.Buffer
+	if err := ExportExtensionsCSV(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(buf.String()) != strings.Join(bulkExtensionColumns, ",") {
+		t.Errorf("Unexpected header: %q", buf.String())
+	}
+}
+
+// TestWritePjsipExtensions tests writing several extensions in a single pass
+func TestWritePjsipExtensions(t *testing.T) {
+	dir := t.TempDir()
+	acm := NewAsteriskConfigManager(false)
+	acm.pjsipConfigPath = filepath.Join(dir, "pjsip.conf")
+
+	existing := CreatePjsipEndpointSections("102", "old", "from-internal", "transport-udp",
+		[]string{"ulaw"}, "no", "", 1, 60, false)[0].String()
+	if err := os.WriteFile(acm.pjsipConfigPath, []byte(existing), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	exts := []Extension{
+		{ExtensionNumber: "101", Secret: "pw101", Enabled: true, Context: "from-internal", Transport: "transport-udp", MaxContacts: 1},
+		{ExtensionNumber: "102", Secret: "pw102", Enabled: false, Context: "from-internal", Transport: "transport-udp", MaxContacts: 1},
+	}
+	if err := acm.WritePjsipExtensions(exts, "test"); err != nil {
+		t.Fatalf("WritePjsipExtensions failed: %v", err)
+	}
+
+	config, err := ParseAsteriskConfig(acm.pjsipConfigPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.FindSectionByNameAndType("101", "endpoint") == nil || config.FindSectionByNameAndType("101", "auth") == nil {
+		t.Errorf("Expected sections for 101:\n%s", config.String())
+	}
+	if len(config.FindSectionsByName("102")) != 0 {
+		t.Errorf("Expected disabled extension 102 to be removed:\n%s", config.String())
+	}
+}
@@ -0,0 +1,821 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/fatih/color"
+)
+
+// ExtensionProfile is a named set of endpoint settings shared by several extensions.
+// Each profile is written to pjsip.conf as [name](!) and [name-aor](!) templates,
+// and member extensions inherit from them, keeping only the values they override.
+type ExtensionProfile struct {
+	ID               int
+	Name             string
+	Description      string
+	Context          string
+	Transport        string
+	Codecs           string // Comma-separated list of codecs
+	DirectMedia      string
+	MaxContacts      int
+	QualifyFrequency int
+	NAT              bool // Phones behind NAT: rewrite_contact, force_rport and rtp_symmetric
+}
+
+// Field indices for the extension profile form
+const (
+	profileFieldName = iota
+	profileFieldDescription
+	profileFieldCodecs
+	profileFieldContext
+	profileFieldTransport
+	profileFieldDirectMedia
+	profileFieldMaxContacts
+	profileFieldQualifyFreq
+	profileFieldNAT
+)
+
+// profileManagedExtensionFields lists the extension form fields that can be inherited from a profile
+var profileManagedExtensionFields = []int{
+	extFieldCodecs,
+	extFieldContext,
+	extFieldTransport,
+	extFieldDirectMedia,
+	extFieldMaxContacts,
+	extFieldQualifyFreq,
+	extFieldNAT,
+}
+
+// DefaultExtensionProfiles returns the built-in profiles created on first use
+func DefaultExtensionProfiles() []ExtensionProfile {
+	return []ExtensionProfile{
+		{
+			Name:             "desk-phone",
+			Description:      "Desk phone on the office LAN",
+			Context:          DefaultExtensionContext,
+			Transport:        DefaultExtensionTransport,
+			Codecs:           "g722,ulaw,alaw",
+			DirectMedia:      DefaultDirectMedia,
+			MaxContacts:      1,
+			QualifyFrequency: DefaultQualifyFrequency,
+		},
+		{
+			Name:             "softphone-webrtc",
+			Description:      "Browser softphone over WebSocket (WSS, DTLS-SRTP)",
+			Context:          DefaultExtensionContext,
+			Transport:        webRTCTransportName,
+			Codecs:           "opus,ulaw",
+			DirectMedia:      "no",
+			MaxContacts:      3,
+			QualifyFrequency: 30,
+		},
+		{
+			Name:             "remote-worker-nat",
+			Description:      "Phone behind a home or hotel NAT router",
+			Context:          DefaultExtensionContext,
+			Transport:        DefaultExtensionTransport,
+			Codecs:           "g722,ulaw,alaw",
+			DirectMedia:      "no",
+			MaxContacts:      1,
+			QualifyFrequency: 30,
+			NAT:              true,
+		},
+	}
+}
+
+// isValidProfileName checks a profile name is safe to use as a PJSIP section name.
+// Purely numeric names are rejected so they cannot clash with extension sections.
+func isValidProfileName(name string) bool {
+	if name == "" || len(name) > 40 {
+		return false
+	}
+	hasLetter := false
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z':
+			hasLetter = true
+		case c >= '0' && c <= '9', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+	return hasLetter
+}
+
+// profileAorTemplateName returns the name of the AOR template generated for a profile
+func profileAorTemplateName(name string) string {
+	return name + "-aor"
+}
+
+// FieldValue returns the profile value for an extension form field
+func (p ExtensionProfile) FieldValue(field int) (string, bool) {
+	switch field {
+	case extFieldCodecs:
+		return p.Codecs, true
+	case extFieldContext:
+		return p.Context, true
+	case extFieldTransport:
+		return p.Transport, true
+	case extFieldDirectMedia:
+		return p.DirectMedia, true
+	case extFieldMaxContacts:
+		return strconv.Itoa(p.MaxContacts), true
+	case extFieldQualifyFreq:
+		return strconv.Itoa(p.QualifyFrequency), true
+	case extFieldNAT:
+		return yesNo(p.NAT), true
+	}
+	return "", false
+}
+
+// OverriddenFields returns the names of the fields where an extension differs from the profile
+func (p ExtensionProfile) OverriddenFields(ext Extension) []string {
+	var fields []string
+	if ext.Codecs != p.Codecs {
+		fields = append(fields, "codecs")
+	}
+	if ext.Context != p.Context {
+		fields = append(fields, "context")
+	}
+	if ext.Transport != p.Transport {
+		fields = append(fields, "transport")
+	}
+	if ext.DirectMedia != p.DirectMedia {
+		fields = append(fields, "direct_media")
+	}
+	if ext.MaxContacts != p.MaxContacts {
+		fields = append(fields, "max_contacts")
+	}
+	if ext.QualifyFrequency != p.QualifyFrequency {
+		fields = append(fields, "qualify_frequency")
+	}
+	if ext.NAT != p.NAT {
+		fields = append(fields, "nat")
+	}
+	return fields
+}
+
+// applyProfileChange moves the fields ext inherits from old to their values in profile. Fields
+// the extension overrides keep their value; the result reports whether anything changed.
+func applyProfileChange(old, profile ExtensionProfile, ext Extension) (Extension, bool) {
+	updated := ext
+	if ext.Codecs == old.Codecs {
+		updated.Codecs = profile.Codecs
+	}
+	if ext.Context == old.Context {
+		updated.Context = profile.Context
+	}
+	if ext.Transport == old.Transport {
+		updated.Transport = profile.Transport
+	}
+	if ext.DirectMedia == old.DirectMedia {
+		updated.DirectMedia = profile.DirectMedia
+	}
+	if ext.MaxContacts == old.MaxContacts {
+		updated.MaxContacts = profile.MaxContacts
+	}
+	if ext.QualifyFrequency == old.QualifyFrequency {
+		updated.QualifyFrequency = profile.QualifyFrequency
+	}
+	if ext.NAT == old.NAT {
+		updated.NAT = profile.NAT
+	}
+	return updated, updated != ext
+}
+
+// CreatePjsipProfileTemplateSections creates the endpoint and AOR template sections for a profile.
+// They are built with CreatePjsipEndpointSections so templates and endpoints never drift apart.
+func CreatePjsipProfileTemplateSections(profile ExtensionProfile) []*AsteriskSection {
+	base := CreatePjsipEndpointSections(
+		profile.Name,
+		"",
+		profile.Context,
+		profile.Transport,
+		strings.Split(profile.Codecs, ","),
+		profile.DirectMedia,
+		"",
+		profile.MaxContacts,
+		profile.QualifyFrequency,
+		false,
+	)
+
+	// Per-extension keys stay in the member sections
+	endpoint := base[0]
+	endpoint.RemoveProperty("auth")
+	endpoint.RemoveProperty("aors")
+	endpoint.Template = true
+	endpoint.Comments = []string{fmt.Sprintf("; RayanPBX profile: %s", profile.Name)}
+
+	if profile.NAT {
+		ApplyNATEndpointOptions(endpoint)
+	}
+
+	aor := base[2]
+	aor.Name = profileAorTemplateName(profile.Name)
+	aor.Template = true
+
+	return []*AsteriskSection{endpoint, aor}
+}
+
+// pjsipProfileTemplateSections creates the template sections of a profile, adding the
+// WebRTC options when the profile uses the WSS transport
+func (acm *AsteriskConfigManager) pjsipProfileTemplateSections(profile ExtensionProfile) []*AsteriskSection {
+	templates := CreatePjsipProfileTemplateSections(profile)
+	if isWebRTCTransport(profile.Transport) {
+		certFile, keyFile := acm.webRTCCertificate()
+		ApplyWebRTCEndpointOptions(templates[0], certFile, keyFile)
+	}
+	return templates
+}
+
+// applyPjsipProfileInheritance makes extension sections inherit from the profile templates,
+// removing the properties that have the same value as the template
+func applyPjsipProfileInheritance(sections []*AsteriskSection, templates []*AsteriskSection) {
+	for _, template := range templates {
+		for _, section := range sections {
+			if section.Type != template.Type {
+				continue
+			}
+			section.Inherits = []string{template.Name}
+
+			// An overridden codec list must keep disallow=all, otherwise it would
+			// be appended to the inherited allow list
+			codecsInherited := section.Properties["allow"] == template.Properties["allow"]
+			for _, key := range template.Keys {
+				if key == "type" || ((key == "allow" || key == "disallow") && !codecsInherited) {
+					continue
+				}
+				if value, ok := section.GetProperty(key); ok && value == template.Properties[key] {
+					section.RemoveProperty(key)
+				}
+			}
+		}
+	}
+}
+
+// SetExtensionProfiles sets the profiles used when generating extension sections
+func (acm *AsteriskConfigManager) SetExtensionProfiles(profiles []ExtensionProfile) {
+	acm.profiles = make(map[string]ExtensionProfile)
+	for _, profile := range profiles {
+		acm.profiles[profile.Name] = profile
+	}
+}
+
+// GetExtensionProfile returns the profile with the given name, if it is known
+func (acm *AsteriskConfigManager) GetExtensionProfile(name string) (ExtensionProfile, bool) {
+	profile, ok := acm.profiles[name]
+	return profile, ok
+}
+
+// placeProfileTemplates replaces the template sections of a profile, inserting them
+// before the first endpoint so that every member can inherit from them
+func placeProfileTemplates(config *AsteriskConfig, templates []*AsteriskSection) {
+	names := make(map[string]bool)
+	for _, template := range templates {
+		names[template.Name] = true
+	}
+
+	var kept []*AsteriskSection
+	for _, section := range config.Sections {
+		if !(section.Template && names[section.Name]) {
+			kept = append(kept, section)
+		}
+	}
+
+	idx := len(kept)
+	for i, section := range kept {
+		if section.Template || section.Type == "transport" || section.Type == "global" || section.Type == "system" {
+			continue
+		}
+		idx = i
+		break
+	}
+
+	sections := make([]*AsteriskSection, 0, len(kept)+len(templates))
+	sections = append(sections, kept[:idx]...)
+	sections = append(sections, templates...)
+	sections = append(sections, kept[idx:]...)
+	config.Sections = sections
+}
+
+// WritePjsipProfile writes the templates of a profile and regenerates its members
+// in a single config write and Git snapshot
+func (acm *AsteriskConfigManager) WritePjsipProfile(profile ExtensionProfile, members []Extension) error {
+	yellow := color.New(color.FgYellow)
+
+	config, err := acm.LoadPjsipConfig()
+	if err != nil {
+		return err
+	}
+
+	placeProfileTemplates(config, acm.pjsipProfileTemplateSections(profile))
+
+	for _, ext := range members {
+		config.RemoveSectionsForExtension(ext.ExtensionNumber)
+		if !ext.Enabled {
+			continue
+		}
+		for _, section := range acm.GeneratePjsipEndpoint(ext) {
+			config.AddSection(section)
+		}
+	}
+
+	if err := config.Save(); err != nil {
+		return fmt.Errorf("failed to write config file: %v", err)
+	}
+
+	if err := acm.CommitConfigChange("pjsip-update", fmt.Sprintf("Updated PJSIP profile: %s (%d members)", profile.Name, len(members))); err != nil {
+		yellow.Fprintf(acm.out, "⚠️  Git commit warning: %v\n", err)
+	}
+
+	return nil
+}
+
+// RemovePjsipProfile removes the template sections of a profile from pjsip.conf
+func (acm *AsteriskConfigManager) RemovePjsipProfile(name string) error {
+	yellow := color.New(color.FgYellow)
+
+	config, err := acm.LoadPjsipConfig()
+	if err != nil {
+		return err
+	}
+
+	var kept []*AsteriskSection
+	for _, section := range config.Sections {
+		if !(section.Template && (section.Name == name || section.Name == profileAorTemplateName(name))) {
+			kept = append(kept, section)
+		}
+	}
+	config.Sections = kept
+
+	if err := config.Save(); err != nil {
+		return fmt.Errorf("failed to write config file: %v", err)
+	}
+
+	if err := acm.CommitConfigChange("pjsip-remove", fmt.Sprintf("Removed PJSIP profile: %s", name)); err != nil {
+		yellow.Fprintf(acm.out, "⚠️  Git commit warning: %v\n", err)
+	}
+
+	return nil
+}
+
+// GetExtensionProfiles fetches extension profiles from database
+func GetExtensionProfiles(db *sql.DB) ([]ExtensionProfile, error) {
+	query := `SELECT id, name, COALESCE(description, ''), COALESCE(context, 'from-internal'),
+	          COALESCE(transport, 'transport-udp'), COALESCE(codecs, '["ulaw","alaw","g722"]'),
+	          COALESCE(direct_media, 'no'), COALESCE(max_contacts, 1), COALESCE(qualify_frequency, 60), COALESCE(nat, 0)
+	          FROM extension_profiles ORDER BY name`
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var profiles []ExtensionProfile
+	for rows.Next() {
+		var p ExtensionProfile
+		var codecsJSON string
+		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.Context, &p.Transport, &codecsJSON,
+			&p.DirectMedia, &p.MaxContacts, &p.QualifyFrequency, &p.NAT); err != nil {
+			continue
+		}
+		p.Codecs = parseCodecsJSON(codecsJSON)
+		profiles = append(profiles, p)
+	}
+
+	return profiles, nil
+}
+
+// EnsureDefaultExtensionProfiles creates the built-in profiles that do not exist yet
+func EnsureDefaultExtensionProfiles(db *sql.DB) error {
+	for _, p := range DefaultExtensionProfiles() {
+		_, err := db.Exec(`INSERT IGNORE INTO extension_profiles (name, description, context, transport, codecs, direct_media,
+			max_contacts, qualify_frequency, nat, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, NOW(), NOW())`,
+			p.Name, p.Description, p.Context, p.Transport, codecsToJSON(p.Codecs), p.DirectMedia, p.MaxContacts, p.QualifyFrequency, p.NAT)
+		if err != nil {
+			return fmt.Errorf("failed to create profile %s: %v", p.Name, err)
+		}
+	}
+	return nil
+}
+
+// SaveExtensionProfile creates or updates a profile. When an existing profile changes,
+// members that inherited the old value of a field are moved to the new value, so
+// overrides are kept and everything else follows the profile.
+func SaveExtensionProfile(db *sql.DB, old *ExtensionProfile, profile ExtensionProfile) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+
+	if old == nil {
+		_, err = tx.Exec(`INSERT INTO extension_profiles (name, description, context, transport, codecs, direct_media,
+			max_contacts, qualify_frequency, nat, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, NOW(), NOW())`,
+			profile.Name, profile.Description, profile.Context, profile.Transport, codecsToJSON(profile.Codecs),
+			profile.DirectMedia, profile.MaxContacts, profile.QualifyFrequency, profile.NAT)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to create profile: %v", err)
+		}
+		return tx.Commit()
+	}
+
+	_, err = tx.Exec(`UPDATE extension_profiles SET description = ?, context = ?, transport = ?, codecs = ?, direct_media = ?,
+		max_contacts = ?, qualify_frequency = ?, nat = ?, updated_at = NOW() WHERE id = ?`,
+		profile.Description, profile.Context, profile.Transport, codecsToJSON(profile.Codecs), profile.DirectMedia,
+		profile.MaxContacts, profile.QualifyFrequency, profile.NAT, old.ID)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update profile: %v", err)
+	}
+
+	// Codecs are stored as JSON, so members are compared in Go rather than in the WHERE clause
+	rows, err := tx.Query(`SELECT id, COALESCE(context, 'from-internal'), COALESCE(transport, 'transport-udp'),
+		COALESCE(codecs, '["ulaw","alaw","g722"]'), COALESCE(direct_media, 'no'), COALESCE(max_contacts, 1),
+		COALESCE(qualify_frequency, 60), COALESCE(nat, 0) FROM extensions WHERE profile = ?`, old.Name)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to load profile members: %v", err)
+	}
+	var members []Extension
+	for rows.Next() {
+		var ext Extension
+		var codecsJSON string
+		if err := rows.Scan(&ext.ID, &ext.Context, &ext.Transport, &codecsJSON, &ext.DirectMedia,
+			&ext.MaxContacts, &ext.QualifyFrequency, &ext.NAT); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return fmt.Errorf("failed to load profile members: %v", err)
+		}
+		ext.Codecs = parseCodecsJSON(codecsJSON)
+		members = append(members, ext)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to load profile members: %v", err)
+	}
+
+	for _, ext := range members {
+		updated, changed := applyProfileChange(*old, profile, ext)
+		if !changed {
+			continue
+		}
+		if _, err := tx.Exec(`UPDATE extensions SET context = ?, transport = ?, codecs = ?, direct_media = ?,
+			max_contacts = ?, qualify_frequency = ?, nat = ?, updated_at = NOW() WHERE id = ?`,
+			updated.Context, updated.Transport, codecsToJSON(updated.Codecs), updated.DirectMedia,
+			updated.MaxContacts, updated.QualifyFrequency, updated.NAT, updated.ID); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to update profile members: %v", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DeleteExtensionProfile deletes a profile that no extension uses anymore
+func DeleteExtensionProfile(db *sql.DB, name string) error {
+	var members int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM extensions WHERE profile = ?`, name).Scan(&members); err != nil {
+		return fmt.Errorf("failed to count profile members: %v", err)
+	}
+	if members > 0 {
+		return fmt.Errorf("profile %s is used by %d extension(s)", name, members)
+	}
+	if _, err := db.Exec(`DELETE FROM extension_profiles WHERE name = ?`, name); err != nil {
+		return fmt.Errorf("failed to delete profile: %v", err)
+	}
+	return nil
+}
+
+// profileMembers returns the extensions that reference the given profile
+func profileMembers(extensions []Extension, name string) []Extension {
+	var members []Extension
+	for _, ext := range extensions {
+		if ext.Profile == name {
+			members = append(members, ext)
+		}
+	}
+	return members
+}
+
+// loadExtensionProfiles loads profiles from the database and hands them to the config manager
+func (m *model) loadExtensionProfiles() {
+	if m.db == nil {
+		return
+	}
+	profiles, err := GetExtensionProfiles(m.db)
+	if err != nil {
+		return
+	}
+	m.extensionProfiles = profiles
+	m.configManager.SetExtensionProfiles(profiles)
+}
+
+// findExtensionProfile returns the loaded profile with the given name
+func (m *model) findExtensionProfile(name string) *ExtensionProfile {
+	for i := range m.extensionProfiles {
+		if m.extensionProfiles[i].Name == name {
+			return &m.extensionProfiles[i]
+		}
+	}
+	return nil
+}
+
+// applyProfileToExtensionForm fills the profile-managed extension form fields from the entered profile
+func (m *model) applyProfileToExtensionForm() {
+	name := strings.TrimSpace(m.inputValues[extFieldProfile])
+	if name == "" {
+		return
+	}
+	profile := m.findExtensionProfile(name)
+	if profile == nil {
+		m.errorMsg = fmt.Sprintf("Unknown profile: %s", name)
+		return
+	}
+	for _, field := range profileManagedExtensionFields {
+		m.inputValues[field], _ = profile.FieldValue(field)
+	}
+	m.errorMsg = ""
+}
+
+// renderProfileFieldState returns the inherited/overridden marker for an extension form field
+func (m model) renderProfileFieldState(field int) string {
+	name := strings.TrimSpace(m.inputValues[extFieldProfile])
+	if name == "" {
+		return ""
+	}
+	profile := m.findExtensionProfile(name)
+	if profile == nil {
+		return ""
+	}
+	value, managed := profile.FieldValue(field)
+	if !managed {
+		return ""
+	}
+	if strings.TrimSpace(m.inputValues[field]) == value {
+		return helpStyle.Render(fmt.Sprintf("  ↳ inherited from %s", profile.Name))
+	}
+	return warningStyle.Render(fmt.Sprintf("  ✎ overrides %s (%s)", profile.Name, value))
+}
+
+// initExtensionProfiles opens the extension profiles screen
+func (m *model) initExtensionProfiles() {
+	if m.db != nil {
+		if err := EnsureDefaultExtensionProfiles(m.db); err != nil {
+			m.errorMsg = err.Error()
+		}
+	}
+	m.loadExtensionProfiles()
+	m.currentScreen = extensionProfilesScreen
+	m.selectedProfileIdx = 0
+	m.successMsg = ""
+}
+
+// handleExtensionProfilesScreen processes input for the profile list
+func (m *model) handleExtensionProfilesScreen(msg tea.KeyMsg) (*model, tea.Cmd) {
+	switch msg.String() {
+	case "up", "k":
+		if m.selectedProfileIdx > 0 {
+			m.selectedProfileIdx--
+		}
+	case "down", "j":
+		if m.selectedProfileIdx < len(m.extensionProfiles)-1 {
+			m.selectedProfileIdx++
+		}
+	case "a":
+		m.initExtensionProfileForm(nil)
+	case "e", "enter":
+		if m.selectedProfileIdx < len(m.extensionProfiles) {
+			m.initExtensionProfileForm(&m.extensionProfiles[m.selectedProfileIdx])
+		}
+	case "d":
+		m.deleteSelectedExtensionProfile()
+	case "esc":
+		m.currentScreen = extensionsScreen
+		m.errorMsg = ""
+		m.successMsg = ""
+	case "q":
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// initExtensionProfileForm opens the profile form, for a new profile when profile is nil
+func (m *model) initExtensionProfileForm(profile *ExtensionProfile) {
+	m.currentScreen = extensionProfileEditScreen
+	m.inputMode = true
+	m.inputFields = []string{
+		"Profile Name",
+		"Description",
+		"Codecs (ulaw,alaw,g722)",
+		"Context",
+		"Transport",
+		"Direct Media (yes/no)",
+		"Max Contacts",
+		"Qualify Frequency (sec)",
+		"NAT traversal (yes/no)",
+	}
+	m.editingProfile = profile
+	if profile == nil {
+		m.inputValues = []string{
+			"", "", DefaultCodecs, DefaultExtensionContext, DefaultExtensionTransport, DefaultDirectMedia,
+			strconv.Itoa(DefaultMaxContacts), strconv.Itoa(DefaultQualifyFrequency), "no",
+		}
+	} else {
+		m.inputValues = []string{
+			profile.Name, profile.Description, profile.Codecs, profile.Context, profile.Transport, profile.DirectMedia,
+			strconv.Itoa(profile.MaxContacts), strconv.Itoa(profile.QualifyFrequency), yesNo(profile.NAT),
+		}
+	}
+	m.inputCursor = 0
+	m.errorMsg = ""
+	m.successMsg = ""
+}
+
+// parseNATTraversal parses a yes/no NAT traversal value; empty means no
+func parseNATTraversal(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "yes":
+		return true, nil
+	case "no", "":
+		return false, nil
+	}
+	return false, fmt.Errorf("NAT traversal must be yes or no")
+}
+
+// parseExtensionProfileForm validates the profile form values
+func parseExtensionProfileForm(values []string) (ExtensionProfile, error) {
+	profile := ExtensionProfile{
+		Name:        strings.TrimSpace(values[profileFieldName]),
+		Description: strings.TrimSpace(values[profileFieldDescription]),
+		Codecs:      strings.ReplaceAll(strings.TrimSpace(values[profileFieldCodecs]), " ", ""),
+		Context:     strings.TrimSpace(values[profileFieldContext]),
+		Transport:   strings.TrimSpace(values[profileFieldTransport]),
+		DirectMedia: strings.ToLower(strings.TrimSpace(values[profileFieldDirectMedia])),
+	}
+
+	if !isValidProfileName(profile.Name) {
+		return profile, fmt.Errorf("profile name must use lowercase letters, digits, '-' or '_' (max 40)")
+	}
+	if profile.Codecs == "" || profile.Context == "" || profile.Transport == "" {
+		return profile, fmt.Errorf("codecs, context and transport are required")
+	}
+	if profile.DirectMedia != "yes" && profile.DirectMedia != "no" {
+		return profile, fmt.Errorf("direct media must be yes or no")
+	}
+
+	maxContacts, err := strconv.Atoi(strings.TrimSpace(values[profileFieldMaxContacts]))
+	if err != nil || maxContacts < 1 || maxContacts > 10 {
+		return profile, fmt.Errorf("max contacts must be between 1 and 10")
+	}
+	profile.MaxContacts = maxContacts
+
+	qualify, err := strconv.Atoi(strings.TrimSpace(values[profileFieldQualifyFreq]))
+	if err != nil || qualify < 0 {
+		return profile, fmt.Errorf("qualify frequency must be 0 or more seconds")
+	}
+	profile.QualifyFrequency = qualify
+
+	if profile.NAT, err = parseNATTraversal(values[profileFieldNAT]); err != nil {
+		return profile, err
+	}
+
+	return profile, nil
+}
+
+// saveExtensionProfile saves the profile form, rewrites its templates and members and reloads Asterisk
+func (m *model) saveExtensionProfile() {
+	profile, err := parseExtensionProfileForm(m.inputValues)
+	if err != nil {
+		m.errorMsg = err.Error()
+		return
+	}
+	if m.editingProfile != nil && profile.Name != m.editingProfile.Name {
+		m.errorMsg = "Profile name cannot be changed"
+		return
+	}
+	if m.editingProfile == nil && m.findExtensionProfile(profile.Name) != nil {
+		m.errorMsg = fmt.Sprintf("Profile %s already exists", profile.Name)
+		return
+	}
+	if m.db == nil {
+		m.errorMsg = "Database not connected"
+		return
+	}
+
+	if err := SaveExtensionProfile(m.db, m.editingProfile, profile); err != nil {
+		m.errorMsg = err.Error()
+		return
+	}
+
+	m.loadExtensionProfiles()
+	var members []Extension
+	if exts, err := GetExtensions(m.db); err == nil {
+		m.extensions = exts
+		members = profileMembers(exts, profile.Name)
+	}
+
+	m.inputMode = false
+	m.editingProfile = nil
+	m.currentScreen = extensionProfilesScreen
+
+	if isWebRTCTransport(profile.Transport) {
+		if err := m.configManager.EnsureWebRTCConfig(); err != nil {
+			m.errorMsg = fmt.Sprintf("Failed to configure WebRTC transport: %v", err)
+			return
+		}
+	}
+	if err := m.configManager.WritePjsipProfile(profile, members); err != nil {
+		m.errorMsg = fmt.Sprintf("Profile saved in DB but failed to write config: %v", err)
+		return
+	}
+	if err := m.configManager.ReloadAsterisk(); err != nil {
+		m.errorMsg = fmt.Sprintf("Config written but Asterisk reload failed: %v", err)
+		return
+	}
+	m.successMsg = fmt.Sprintf("Profile %s saved (%d members updated)", profile.Name, len(members))
+}
+
+// deleteSelectedExtensionProfile deletes the selected profile if it has no members
+func (m *model) deleteSelectedExtensionProfile() {
+	if m.selectedProfileIdx >= len(m.extensionProfiles) || m.db == nil {
+		return
+	}
+	name := m.extensionProfiles[m.selectedProfileIdx].Name
+	if err := DeleteExtensionProfile(m.db, name); err != nil {
+		m.errorMsg = err.Error()
+		return
+	}
+	if err := m.configManager.RemovePjsipProfile(name); err != nil {
+		m.errorMsg = fmt.Sprintf("Profile deleted in DB but failed to update config: %v", err)
+	} else {
+		m.successMsg = fmt.Sprintf("Profile %s deleted", name)
+	}
+	m.loadExtensionProfiles()
+	if m.selectedProfileIdx >= len(m.extensionProfiles) && m.selectedProfileIdx > 0 {
+		m.selectedProfileIdx--
+	}
+}
+
+// renderExtensionProfiles renders the extension profiles list
+func (m model) renderExtensionProfiles() string {
+	content := infoStyle.Render("🧩 Extension Profiles") + "\n\n"
+
+	if len(m.extensionProfiles) == 0 {
+		content += "📭 No profiles configured\n"
+		return menuStyle.Render(content)
+	}
+
+	for i, p := range m.extensionProfiles {
+		cursor := " "
+		name := p.Name
+		if i == m.selectedProfileIdx {
+			cursor = "▶"
+			name = selectedItemStyle.Render(name)
+		}
+		members := profileMembers(m.extensions, p.Name)
+		content += fmt.Sprintf("%s %s - %s (%d members)\n", cursor, name, p.Description, len(members))
+		if i == m.selectedProfileIdx {
+			content += helpStyle.Render(fmt.Sprintf("   codecs=%s context=%s transport=%s direct_media=%s max_contacts=%d qualify=%ds nat=%s",
+				p.Codecs, p.Context, p.Transport, p.DirectMedia, p.MaxContacts, p.QualifyFrequency, yesNo(p.NAT))) + "\n"
+			for _, ext := range members {
+				overrides := p.OverriddenFields(ext)
+				if len(overrides) > 0 {
+					content += helpStyle.Render(fmt.Sprintf("   • %s overrides %s", ext.ExtensionNumber, strings.Join(overrides, ", "))) + "\n"
+				}
+			}
+		}
+	}
+
+	return menuStyle.Render(content)
+}
+
+// renderExtensionProfileForm renders the profile create/edit form
+func (m model) renderExtensionProfileForm() string {
+	title := "🧩 New Extension Profile"
+	if m.editingProfile != nil {
+		title = "🧩 Edit Extension Profile"
+	}
+	content := infoStyle.Render(title) + "\n\n"
+
+	for i, field := range m.inputFields {
+		cursor := "  "
+		if i == m.inputCursor {
+			cursor = "▶ "
+			field = selectedItemStyle.Render(field)
+		}
+		value := m.inputValues[i]
+		if value == "" {
+			value = helpStyle.Render("<enter value>")
+		}
+		content += fmt.Sprintf("%s%s: %s\n", cursor, field, value)
+	}
+
+	content += "\n" + helpStyle.Render("💡 Members inherit every field they do not override")
+	return menuStyle.Render(content)
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testDeskPhoneProfile() ExtensionProfile {
+	return ExtensionProfile{
+		Name:             "desk-phone",
+		Context:          "from-internal",
+		Transport:        "transport-udp",
+		Codecs:           "g722",
+		DirectMedia:      "no",
+		MaxContacts:      1,
+		QualifyFrequency: 60,
+	}
+}
+
+// TestCreatePjsipProfileTemplateSections tests the generated template sections
+func TestCreatePjsipProfileTemplateSections(t *testing.T) {
+	sections := CreatePjsipProfileTemplateSections(testDeskPhoneProfile())
+	if len(sections) != 2 {
+		t.Fatalf("Expected endpoint and aor templates, got %d sections", len(sections))
+	}
+
+	endpoint, aor := sections[0], sections[1]
+	if !endpoint.Template || endpoint.Name != "desk-phone" || endpoint.Type != "endpoint" {
+		t.Errorf("Unexpected endpoint template: %s", endpoint.String())
+	}
+	if _, ok := endpoint.GetProperty("auth"); ok {
+		t.Error("Endpoint template must not contain auth")
+	}
+	if _, ok := endpoint.GetProperty("aors"); ok {
+		t.Error("Endpoint template must not contain aors")
+	}
+	if !aor.Template || aor.Name != "desk-phone-aor" || aor.Type != "aor" {
+		t.Errorf("Unexpected aor template: %s", aor.String())
+	}
+	if !strings.Contains(endpoint.String(), "[desk-phone](!)\n") {
+		t.Errorf("Expected template header, got:\n%s", endpoint.String())
+	}
+}
+
+// TestGeneratePjsipEndpointWithProfile tests that members keep only their overrides
+func TestGeneratePjsipEndpointWithProfile(t *testing.T) {
+	acm := NewAsteriskConfigManager(false)
+	acm.SetExtensionProfiles([]ExtensionProfile{testDeskPhoneProfile()})
+
+	inherited := Extension{ExtensionNumber: "101", Secret: "pw", Context: "from-internal", Transport: "transport-udp",
+		Codecs: "g722", DirectMedia: "no", MaxContacts: 1, QualifyFrequency: 60, Profile: "desk-phone"}
+	sections := acm.GeneratePjsipEndpoint(inherited)
+
+	endpoint, auth, aor := sections[0], sections[1], sections[2]
+	if strings.Join(endpoint.Inherits, ",") != "desk-phone" || strings.Join(aor.Inherits, ",") != "desk-phone-aor" {
+		t.Errorf("Expected sections to inherit templates, got %v and %v", endpoint.Inherits, aor.Inherits)
+	}
+	if len(auth.Inherits) != 0 {
+		t.Errorf("Auth section should not inherit, got %v", auth.Inherits)
+	}
+	for _, key := range []string{"context", "allow", "disallow", "transport", "direct_media"} {
+		if _, ok := endpoint.GetProperty(key); ok {
+			t.Errorf("Expected %s to be inherited, got:\n%s", key, endpoint.String())
+		}
+	}
+	if v, _ := endpoint.GetProperty("aors"); v != "101" {
+		t.Errorf("Expected aors=101 in member endpoint, got %q", v)
+	}
+	if len(aor.Keys) != 1 || aor.Keys[0] != "type" {
+		t.Errorf("Expected aor to only keep type, got:\n%s", aor.String())
+	}
+
+	overridden := inherited
+	overridden.Codecs = "opus"
+	overridden.MaxContacts = 3
+	sections = acm.GeneratePjsipEndpoint(overridden)
+	if v, _ := sections[0].GetProperty("allow"); v != "opus" {
+		t.Errorf("Expected overridden codec, got %q", v)
+	}
+	if _, ok := sections[0].GetProperty("disallow"); !ok {
+		t.Error("Overridden codecs must keep disallow=all")
+	}
+	if v, _ := sections[2].GetProperty("max_contacts"); v != "3" {
+		t.Errorf("Expected overridden max_contacts, got %q", v)
+	}
+
+	// Unknown profiles fall back to full sections
+	unknown := inherited
+	unknown.Profile = "missing"
+	sections = acm.GeneratePjsipEndpoint(unknown)
+	if len(sections[0].Inherits) != 0 {
+		t.Errorf("Expected no inheritance for unknown profile, got %v", sections[0].Inherits)
+	}
+	if _, ok := sections[0].GetProperty("context"); !ok {
+		t.Error("Expected full endpoint for unknown profile")
+	}
+}
+
+// TestWritePjsipConfigSectionsAddsTemplates tests that templates are written before their members
+func TestWritePjsipConfigSectionsAddsTemplates(t *testing.T) {
+	dir := t.TempDir()
+	acm := NewAsteriskConfigManager(false)
+	acm.pjsipConfigPath = filepath.Join(dir, "pjsip.conf")
+	acm.SetExtensionProfiles([]ExtensionProfile{testDeskPhoneProfile()})
+
+	existing := "[transport-udp]\ntype=transport\nprotocol=udp\n\n[100]\ntype=endpoint\ncontext=from-internal\n"
+	if err := os.WriteFile(acm.pjsipConfigPath, []byte(existing), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ext := Extension{ExtensionNumber: "101", Secret: "pw", Enabled: true, Context: "from-internal", Transport: "transport-udp",
+		Codecs: "g722", DirectMedia: "no", MaxContacts: 1, QualifyFrequency: 60, Profile: "desk-phone"}
+	if err := acm.WritePjsipConfigSections(acm.GeneratePjsipEndpoint(ext), "Extension 101"); err != nil {
+		t.Fatalf("WritePjsipConfigSections failed: %v", err)
+	}
+
+	config, err := ParseAsteriskConfig(acm.pjsipConfigPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, section := range config.Sections {
+		names = append(names, section.Name)
+	}
+	if got := strings.Join(names, ","); got != "transport-udp,desk-phone,desk-phone-aor,100,101,101,101" {
+		t.Errorf("Unexpected section order: %s", got)
+	}
+
+	// The sync parser resolves inherited values, so DB and Asterisk still match
+	esm := NewExtensionSyncManager(nil, nil, acm)
+	parsed, err := esm.parsePjsipContent(config.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, astExt := range parsed {
+		if astExt.ExtensionNumber != "101" {
+			continue
+		}
+		if diffs := esm.findDifferences(&ext, &astExt); len(diffs) != 0 {
+			t.Errorf("Expected no differences for inherited extension, got %v", diffs)
+		}
+		if strings.Join(astExt.Codecs, ",") != "g722" || astExt.Secret != "pw" {
+			t.Errorf("Unexpected parsed extension: %+v", astExt)
+		}
+		return
+	}
+	t.Error("Extension 101 not found by sync parser")
+}
+
+// TestWritePjsipProfile tests updating a profile rewrites its templates and members once
+func TestWritePjsipProfile(t *testing.T) {
+	dir := t.TempDir()
+	acm := NewAsteriskConfigManager(false)
+	acm.pjsipConfigPath = filepath.Join(dir, "pjsip.conf")
+
+	profile := testDeskPhoneProfile()
+	acm.SetExtensionProfiles([]ExtensionProfile{profile})
+	member := Extension{ExtensionNumber: "101", Secret: "pw", Enabled: true, Context: "from-internal", Transport: "transport-udp",
+		Codecs: "g722", DirectMedia: "no", MaxContacts: 1, QualifyFrequency: 60, Profile: "desk-phone"}
+	if err := acm.WritePjsipProfile(profile, []Extension{member}); err != nil {
+		t.Fatalf("WritePjsipProfile failed: %v", err)
+	}
+
+	profile.Codecs = "opus"
+	acm.SetExtensionProfiles([]ExtensionProfile{profile})
+	member.Codecs = "opus"
+	if err := acm.WritePjsipProfile(profile, []Extension{member}); err != nil {
+		t.Fatalf("WritePjsipProfile failed: %v", err)
+	}
+
+	config, err := ParseAsteriskConfig(acm.pjsipConfigPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	templates := config.FindSectionsByName("desk-phone")
+	if len(templates) != 1 || templates[0].Properties["allow"] != "opus" {
+		t.Errorf("Expected a single updated template:\n%s", config.String())
+	}
+	if _, ok := config.FindSectionByNameAndType("101", "endpoint").GetProperty("allow"); ok {
+		t.Errorf("Member should inherit the new codecs:\n%s", config.String())
+	}
+
+	if err := acm.RemovePjsipProfile("desk-phone"); err != nil {
+		t.Fatal(err)
+	}
+	config, _ = ParseAsteriskConfig(acm.pjsipConfigPath)
+	if config.HasSection("desk-phone") || config.HasSection("desk-phone-aor") {
+		t.Errorf("Expected templates to be removed:\n%s", config.String())
+	}
+}
+
+// TestIsValidProfileName tests profile name validation
+func TestIsValidProfileName(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"desk-phone", true},
+		{"remote_worker2", true},
+		{"", false},
+		{"101", false},
+		{"Desk", false},
+		{"desk phone", false},
+		{"desk]phone", false},
+		{strings.Repeat("a", 41), false},
+	}
+	for _, tt := range tests {
+		if got := isValidProfileName(tt.name); got != tt.valid {
+			t.Errorf("isValidProfileName(%q) = %v, want %v", tt.name, got, tt.valid)
+		}
+	}
+}
+
+// TestParseExtensionProfileForm tests profile form validation
+func TestParseExtensionProfileForm(t *testing.T) {
+	valid := []string{"desk-phone", "Desk phones", "g722, ulaw", "from-internal", "transport-udp", "No", "2", "30", "yes"}
+	profile, err := parseExtensionProfileForm(valid)
+	if err != nil {
+		t.Fatalf("Expected valid profile, got %v", err)
+	}
+	if profile.Codecs != "g722,ulaw" || profile.DirectMedia != "no" || profile.MaxContacts != 2 || profile.QualifyFrequency != 30 || !profile.NAT {
+		t.Errorf("Unexpected profile: %+v", profile)
+	}
+
+	invalid := map[string]int{
+		"name":         profileFieldName,
+		"direct media": profileFieldDirectMedia,
+		"max contacts": profileFieldMaxContacts,
+		"qualify":      profileFieldQualifyFreq,
+		"nat":          profileFieldNAT,
+	}
+	for name, field := range invalid {
+		t.Run(name, func(t *testing.T) {
+			values := append([]string(nil), valid...)
+			values[field] = "bad value"
+			if _, err := parseExtensionProfileForm(values); err == nil {
+				t.Error("Expected validation error")
+			}
+		})
+	}
+}
+
+// TestExtensionFormProfileState tests filling the form from a profile and the inherited/overridden markers
+func TestExtensionFormProfileState(t *testing.T) {
+	m := initialModel(nil, nil, false)
+	m.extensionProfiles = []ExtensionProfile{testDeskPhoneProfile()}
+	m.initCreateExtension()
+
+	m.inputValues[extFieldProfile] = "desk-phone"
+	m.applyProfileToExtensionForm()
+	if m.inputValues[extFieldCodecs] != "g722" || m.inputValues[extFieldQualifyFreq] != "60" {
+		t.Errorf("Expected form to be filled from profile, got %v", m.inputValues)
+	}
+	if !strings.Contains(m.renderProfileFieldState(extFieldCodecs), "inherited from desk-phone") {
+		t.Errorf("Expected codecs to be inherited, got %q", m.renderProfileFieldState(extFieldCodecs))
+	}
+
+	m.inputValues[extFieldCodecs] = "opus"
+	if !strings.Contains(m.renderProfileFieldState(extFieldCodecs), "overrides desk-phone") {
+		t.Errorf("Expected codecs to be overridden, got %q", m.renderProfileFieldState(extFieldCodecs))
+	}
+	if m.renderProfileFieldState(extFieldName) != "" {
+		t.Error("Name is not managed by profiles")
+	}
+
+	m.inputValues[extFieldProfile] = "unknown"
+	m.applyProfileToExtensionForm()
+	if m.errorMsg == "" {
+		t.Error("Expected error for unknown profile")
+	}
+}
+
+// TestProfileOverriddenFields tests override detection for the profile list
+func TestProfileOverriddenFields(t *testing.T) {
+	profile := testDeskPhoneProfile()
+	ext := Extension{Context: "from-internal", Transport: "transport-tcp", Codecs: "g722", DirectMedia: "no", MaxContacts: 1, QualifyFrequency: 60}
+	if got := strings.Join(profile.OverriddenFields(ext), ","); got != "transport" {
+		t.Errorf("Expected only transport to be overridden, got %q", got)
+	}
+}
+
+func TestApplyProfileChangeCodecs(t *testing.T) {
+	old := testDeskPhoneProfile()
+	profile := old
+	profile.Codecs = "opus,g722"
+
+	// Members are read back from the JSON codecs column
+	member := Extension{ID: 1, Context: "from-internal", Transport: "transport-udp", Codecs: parseCodecsJSON(codecsToJSON("g722")),
+		DirectMedia: "no", MaxContacts: 1, QualifyFrequency: 60}
+	updated, changed := applyProfileChange(old, profile, member)
+	if !changed || updated.Codecs != "opus,g722" {
+		t.Errorf("Expected the codecs change to reach the member, got %q (changed %v)", updated.Codecs, changed)
+	}
+
+	overridden := member
+	overridden.Codecs = "ulaw"
+	if updated, changed := applyProfileChange(old, profile, overridden); changed || updated.Codecs != "ulaw" {
+		t.Errorf("Expected overridden codecs to be kept, got %q (changed %v)", updated.Codecs, changed)
+	}
+}
+
+// TestProfileNAT tests that NAT follows the profile unless the member overrides it
+func TestProfileNAT(t *testing.T) {
+	old := testDeskPhoneProfile()
+	profile := old
+	profile.NAT = true
+
+	member := Extension{ID: 1, Context: "from-internal", Transport: "transport-udp", Codecs: "g722",
+		DirectMedia: "no", MaxContacts: 1, QualifyFrequency: 60, Profile: "desk-phone"}
+	updated, changed := applyProfileChange(old, profile, member)
+	if !changed || !updated.NAT {
+		t.Errorf("Expected NAT to reach the member, got %v (changed %v)", updated.NAT, changed)
+	}
+	if got := strings.Join(profile.OverriddenFields(updated), ","); got != "" {
+		t.Errorf("Expected no overrides after the change, got %q", got)
+	}
+
+	// Turning NAT off again follows the profile, while a member's own NAT is kept and reported
+	if updated, changed := applyProfileChange(profile, old, updated); !changed || updated.NAT {
+		t.Errorf("Expected NAT to be removed with the profile, got %v (changed %v)", updated.NAT, changed)
+	}
+	overridden := member
+	overridden.NAT = true
+	if updated, changed := applyProfileChange(old, old, overridden); changed || !updated.NAT {
+		t.Errorf("Expected overridden NAT to be kept, got %v (changed %v)", updated.NAT, changed)
+	}
+	if got := strings.Join(old.OverriddenFields(overridden), ","); got != "nat" {
+		t.Errorf("Expected nat to be overridden, got %q", got)
+	}
+
+	// Members of a NAT profile inherit the options; turning them off is written explicitly
+	acm := NewAsteriskConfigManager(false)
+	acm.SetExtensionProfiles([]ExtensionProfile{profile})
+	member.NAT = true
+	if _, ok := acm.GeneratePjsipEndpoint(member)[0].GetProperty("rewrite_contact"); ok {
+		t.Error("Expected rewrite_contact to be inherited from the profile")
+	}
+	member.NAT = false
+	endpoint := acm.GeneratePjsipEndpoint(member)[0]
+	for _, key := range []string{"rewrite_contact", "force_rport", "rtp_symmetric"} {
+		if v, _ := endpoint.GetProperty(key); v != "no" {
+			t.Errorf("Expected %s=no for a member overriding NAT, got %q", key, v)
+		}
+	}
+}
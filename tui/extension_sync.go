@@ -175,17 +175,27 @@ func (esm *ExtensionSyncManager) ParsePjsipConfig() ([]AsteriskExtension, error)
 	return esm.parsePjsipContent(string(content))
 }
 
+// pjsipTemplateProperty is a key=value line of a PJSIP template section
+type pjsipTemplateProperty struct {
+	key   string
+	value string
+}
+
 // parsePjsipContent parses the content of pjsip.conf and extracts extensions
 // This function handles both standard naming (all sections named [101]) and
 // alternative naming patterns ([101-auth], [auth101], etc.) by extracting
 // the base extension number from any recognized pattern.
+// Sections inheriting templates ([101](desk-phone)) get the template properties first.
 func (esm *ExtensionSyncManager) parsePjsipContent(content string) ([]AsteriskExtension, error) {
 	extensions := make(map[string]*AsteriskExtension)
+	templates := make(map[string][]pjsipTemplateProperty)
 	
 	lines := strings.Split(content, "\n")
 	var currentSection string
 	var currentExtNumber string // The extracted base extension number
 	var currentType string
+	var currentTemplate string // Name of the template section being read
+	var pendingInherits []string // Templates to apply once the section type is known
 	
 	for _, line := range lines {
 		line = strings.TrimSpace(line)
@@ -195,10 +205,29 @@ func (esm *ExtensionSyncManager) parsePjsipContent(content string) ([]AsteriskEx
 			continue
 		}
 		
-		// Check for section header [name]
-		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
-			currentSection = strings.TrimPrefix(strings.TrimSuffix(line, "]"), "[")
+		// Check for section header [name] or [name](options)
+		if strings.HasPrefix(line, "[") && strings.Contains(line, "]") {
+			end := strings.Index(line, "]")
+			currentSection = line[1:end]
 			currentType = ""
+			currentTemplate = ""
+			pendingInherits = nil
+			
+			options := strings.TrimSpace(line[end+1:])
+			if strings.HasPrefix(options, "(") && strings.HasSuffix(options, ")") {
+				for _, option := range strings.Split(options[1:len(options)-1], ",") {
+					option = strings.TrimSpace(option)
+					if option == "!" {
+						currentTemplate = currentSection
+						templates[currentTemplate] = nil
+					} else if option != "" {
+						pendingInherits = append(pendingInherits, option)
+					}
+				}
+			}
+			if currentTemplate != "" {
+				continue
+			}
 			
 			// Try to extract extension number from section name
 			// This handles both standard ([101]) and alternative ([101-auth]) naming
@@ -226,10 +255,18 @@ func (esm *ExtensionSyncManager) parsePjsipContent(content string) ([]AsteriskEx
 		key := strings.TrimSpace(parts[0])
 		value := strings.TrimSpace(parts[1])
 		
-		// Check the type of this section
+		// Remember template properties for the sections that inherit them
+		if currentTemplate != "" {
+			templates[currentTemplate] = append(templates[currentTemplate], pjsipTemplateProperty{key: key, value: value})
+			continue
+		}
+		
+		// Check the type of this section (inherited properties are applied once it is known)
 		if key == "type" {
 			currentType = value
-			continue
+			if len(pendingInherits) == 0 {
+				continue
+			}
 		}
 		
 		// Only process endpoint, auth, and aor types for extensions
@@ -256,38 +293,17 @@ func (esm *ExtensionSyncManager) parsePjsipContent(content string) ([]AsteriskEx
 			extensions[currentExtNumber] = ext
 		}
 		
-		// Parse properties based on type
-		switch currentType {
-		case "endpoint":
-			switch key {
-			case "context":
-				ext.Context = value
-			case "transport":
-				ext.Transport = value
-			case "allow":
-				ext.Codecs = append(ext.Codecs, value)
-			case "callerid":
-				ext.CallerID = value
-			case "direct_media":
-				ext.DirectMedia = value
-			}
-		case "auth":
-			switch key {
-			case "password":
-				ext.Secret = value
-			}
-		case "aor":
-			switch key {
-			case "max_contacts":
-				if val, err := strconv.Atoi(value); err == nil {
-					ext.MaxContacts = val
-				}
-			case "qualify_frequency":
-				if val, err := strconv.Atoi(value); err == nil {
-					ext.QualifyFrequency = val
+		// Apply inherited template properties before the section's own ones
+		for _, name := range pendingInherits {
+			for _, prop := range templates[name] {
+				if prop.key != "type" {
+					applyAsteriskExtensionProperty(ext, currentType, prop.key, prop.value)
 				}
 			}
 		}
+		pendingInherits = nil
+		
+		applyAsteriskExtensionProperty(ext, currentType, key, value)
 	}
 	
 	// Build result - we already filtered to only numeric extension numbers
@@ -302,6 +318,47 @@ func (esm *ExtensionSyncManager) parsePjsipContent(content string) ([]AsteriskEx
 	return result, nil
 }
 
+// applyAsteriskExtensionProperty stores a PJSIP property on the parsed extension based on section type
+func applyAsteriskExtensionProperty(ext *AsteriskExtension, sectionType, key, value string) {
+	switch sectionType {
+	case "endpoint":
+		switch key {
+		case "context":
+			ext.Context = value
+		case "transport":
+			ext.Transport = value
+		case "disallow":
+			ext.Codecs = nil
+		case "allow":
+			for _, codec := range strings.Split(value, ",") {
+				if codec = strings.TrimSpace(codec); codec != "" {
+					ext.Codecs = append(ext.Codecs, codec)
+				}
+			}
+		case "callerid":
+			ext.CallerID = value
+		case "direct_media":
+			ext.DirectMedia = value
+		}
+	case "auth":
+		switch key {
+		case "password":
+			ext.Secret = value
+		}
+	case "aor":
+		switch key {
+		case "max_contacts":
+			if val, err := strconv.Atoi(value); err == nil {
+				ext.MaxContacts = val
+			}
+		case "qualify_frequency":
+			if val, err := strconv.Atoi(value); err == nil {
+				ext.QualifyFrequency = val
+			}
+		}
+	}
+}
+
 // GetDatabaseExtensions fetches all extensions from the database
 func (esm *ExtensionSyncManager) GetDatabaseExtensions() ([]Extension, error) {
 	return GetExtensions(esm.db)
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Fanvil web interface endpoints, all served by ConfigManApp.com
+const (
+	fvAppPath          = "/cgi-bin/ConfigManApp.com"
+	fvStatusPath       = fvAppPath + "?Id=1"
+	fvConfigPath       = fvAppPath + "?Id=26"
+	fvRebootPath       = fvAppPath + "?Command=Reboot"
+	fvFactoryResetPath = fvAppPath + "?Command=FactoryReset"
+	fvConfigHeader     = "<<VOIP CONFIG FILE>>Version:2.0002"
+	fvSIPModule        = "SIP CONFIG MODULE"
+	fvFeatureModule    = "CALL FEATURE MODULE"
+	fvDNDKey           = fvFeatureModule + "/Enable DND"
+)
+
+// Fanvil Action URL keys, sent as ?key=K1;K2;... (one request per key sequence)
+const (
+	FVKeyAccept   = "OK"
+	FVKeyReject   = "CANCEL"
+	FVKeyEndCall  = "ENDCALL"
+	FVKeyHold     = "HOLD" // Toggles hold on the active call
+	FVKeyTransfer = "TRAN"
+)
+
+// FanvilPhone implements VoIPPhone for Fanvil X-series devices
+type FanvilPhone struct {
+	phoneWebClient
+}
+
+// NewFanvilPhone creates a new Fanvil phone instance
+func NewFanvilPhone(ip string, credentials map[string]string, httpClient *http.Client) *FanvilPhone {
+	return &FanvilPhone{
+		phoneWebClient: newPhoneWebClient(ip, credentials, httpClient, "enable Action URL control for this server on the phone"),
+	}
+}
+
+// pressKeys sends a key sequence in a single Action URL request
+func (fp *FanvilPhone) pressKeys(keys ...string) error {
+	_, err := fp.do("GET", fvAppPath+"?key="+url.QueryEscape(strings.Join(keys, ";")), "", nil)
+	return err
+}
+
+// GetStatus retrieves the phone status
+func (fp *FanvilPhone) GetStatus() (*PhoneStatus, error) {
+	data, err := fp.do("GET", fvStatusPath, "", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status: %w", err)
+	}
+	info := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		if idx := strings.Index(line, ":"); idx > 0 {
+			info[strings.TrimSpace(line[:idx])] = strings.TrimSpace(line[idx+1:])
+		}
+	}
+
+	status := &PhoneStatus{
+		IP:         fp.ip,
+		MAC:        info["MAC Address"],
+		Model:      info["Product Name"],
+		Firmware:   info["Software Version"],
+		Vendor:     "Fanvil",
+		Uptime:     info["Uptime"],
+		LastUpdate: time.Now(),
+	}
+	for i := 1; ; i++ {
+		line := fmt.Sprintf("SIP%d", i)
+		registration, ok := info[line+" Status"]
+		if !ok {
+			break
+		}
+		status.Accounts = append(status.Accounts, Account{
+			Number:    i,
+			Extension: info[line+" Phone Number"],
+			Status:    registration,
+			Server:    info[line+" Register Addr"],
+		})
+		if strings.EqualFold(registration, "Registered") {
+			status.Registered = true
+		}
+	}
+	return status, nil
+}
+
+// Reboot reboots the phone
+func (fp *FanvilPhone) Reboot() error {
+	if _, err := fp.do("GET", fvRebootPath, "", nil); err != nil {
+		return fmt.Errorf("failed to reboot phone: %w", err)
+	}
+	return nil
+}
+
+// FactoryReset performs a factory reset
+func (fp *FanvilPhone) FactoryReset() error {
+	if _, err := fp.do("GET", fvFactoryResetPath, "", nil); err != nil {
+		return fmt.Errorf("failed to factory reset phone: %w", err)
+	}
+	return nil
+}
+
+// GetConfig retrieves the phone configuration as "MODULE/Key" parameters
+func (fp *FanvilPhone) GetConfig() (map[string]interface{}, error) {
+	data, err := fp.do("GET", fvConfigPath, "", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get config: %w", err)
+	}
+	config := make(map[string]interface{})
+	for key, value := range ParseFanvilConfig(string(data)) {
+		config[key] = value
+	}
+	return config, nil
+}
+
+// SetConfig sets "MODULE/Key" parameters by importing a config file
+func (fp *FanvilPhone) SetConfig(config map[string]interface{}) error {
+	values := make(map[string]string, len(config))
+	for key, value := range config {
+		values[key] = fmt.Sprint(value)
+	}
+	content, err := RenderFanvilConfig(values)
+	if err != nil {
+		return fmt.Errorf("failed to set config: %w", err)
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", "rayanpbx.txt")
+	if err != nil {
+		return fmt.Errorf("failed to build config upload: %w", err)
+	}
+	part.Write([]byte(content))
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to build config upload: %w", err)
+	}
+
+	if _, err := fp.do("POST", fvConfigPath, writer.FormDataContentType(), &body); err != nil {
+		return fmt.Errorf("failed to set config: %w", err)
+	}
+	return nil
+}
+
+// ParseFanvilConfig parses a Fanvil text config into "MODULE/Key" parameters
+func ParseFanvilConfig(content string) map[string]string {
+	values := make(map[string]string)
+	module := ""
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, "<<") || strings.HasPrefix(line, "--"):
+			continue
+		case strings.HasPrefix(line, "<") && strings.HasSuffix(line, ">"):
+			module = strings.Trim(line, "<>")
+			continue
+		}
+		idx := strings.Index(line, ":")
+		if idx <= 0 || module == "" {
+			continue
+		}
+		values[module+"/"+strings.TrimSpace(line[:idx])] = strings.TrimSpace(line[idx+1:])
+	}
+	return values
+}
+
+// RenderFanvilConfig renders "MODULE/Key" parameters as a Fanvil text config
+func RenderFanvilConfig(values map[string]string) (string, error) {
+	modules := make(map[string][]string)
+	for key := range values {
+		idx := strings.Index(key, "/")
+		if idx <= 0 {
+			return "", fmt.Errorf("parameter %q must be written as MODULE/Key", key)
+		}
+		modules[key[:idx]] = append(modules[key[:idx]], key[idx+1:])
+	}
+	names := make([]string, 0, len(modules))
+	for name := range modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString(fvConfigHeader + "\n")
+	for _, name := range names {
+		b.WriteString(fmt.Sprintf("\n<%s>\n", name))
+		keys := modules[name]
+		sort.Strings(keys)
+		for _, key := range keys {
+			b.WriteString(fmt.Sprintf("%-24s:%s\n", key, values[name+"/"+key]))
+		}
+	}
+	return b.String(), nil
+}
+
+// FanvilLineConfig returns the SIP line parameters of one account
+func FanvilLineConfig(ext Extension, lineNumber int, server string) map[string]string {
+	prefix := fmt.Sprintf("%s/SIP%d ", fvSIPModule, lineNumber)
+	config := map[string]string{
+		prefix + "Enable Reg":    "1",
+		prefix + "Phone Number":  ext.ExtensionNumber,
+		prefix + "Display Name":  provisionDisplayName(ext),
+		prefix + "Register Addr": server,
+		prefix + "Register Port": "5060",
+		prefix + "Register User": ext.ExtensionNumber,
+		prefix + "Register Pswd": ext.Secret,
+		prefix + "Transport":     "0",
+	}
+	if provisionUsesTLS(ext) {
+		config[prefix+"Register Port"] = "5061"
+		config[prefix+"Transport"] = "3"
+	}
+	return config
+}
+
+// ProvisionExtension provisions an extension on the phone
+func (fp *FanvilPhone) ProvisionExtension(ext Extension, accountNumber int) error {
+	server := sipServerFor(fp.ip, fp.credentials)
+	if server == "" {
+		return fmt.Errorf("could not determine the SIP server address for %s", fp.ip)
+	}
+	config := make(map[string]interface{})
+	for key, value := range FanvilLineConfig(ext, accountNumber, server) {
+		config[key] = value
+	}
+	return fp.SetConfig(config)
+}
+
+// CTI Operations - Fanvil Action URL keys act on the active call, lineID is ignored
+
+// AcceptCall answers an incoming call
+func (fp *FanvilPhone) AcceptCall(lineID int) error {
+	return fp.pressKeys(FVKeyAccept)
+}
+
+// RejectCall rejects an incoming call
+func (fp *FanvilPhone) RejectCall(lineID int) error {
+	return fp.pressKeys(FVKeyReject)
+}
+
+// EndCall terminates the current call
+func (fp *FanvilPhone) EndCall(lineID int) error {
+	return fp.pressKeys(FVKeyEndCall)
+}
+
+// HoldCall places the call on hold (HOLD toggles, Fanvil exposes no call state)
+func (fp *FanvilPhone) HoldCall(lineID int) error {
+	return fp.pressKeys(FVKeyHold)
+}
+
+// ResumeCall resumes a held call
+func (fp *FanvilPhone) ResumeCall(lineID int) error {
+	return fp.pressKeys(FVKeyHold)
+}
+
+// Dial initiates an outgoing call by entering the number and pressing OK
+func (fp *FanvilPhone) Dial(number string, lineID int) error {
+	keys, err := dtmfKeys(number)
+	if err != nil || number == "" {
+		return fmt.Errorf("invalid number %q", number)
+	}
+	return fp.pressKeys(append(keys, FVKeyAccept)...)
+}
+
+// SendDTMF sends DTMF tones
+func (fp *FanvilPhone) SendDTMF(digits string, lineID int) error {
+	keys, err := dtmfKeys(digits)
+	if err != nil {
+		return err
+	}
+	return fp.pressKeys(keys...)
+}
+
+// BlindTransfer performs blind transfer by pressing TRAN, dialing the target and pressing TRAN again
+func (fp *FanvilPhone) BlindTransfer(target string, lineID int) error {
+	keys, err := dtmfKeys(target)
+	if err != nil || target == "" {
+		return fmt.Errorf("invalid transfer target %q", target)
+	}
+	keys = append(append([]string{FVKeyTransfer}, keys...), FVKeyTransfer)
+	if err := fp.pressKeys(keys...); err != nil {
+		return fmt.Errorf("failed to transfer: %w", err)
+	}
+	return nil
+}
+
+// SetDND enables/disables Do Not Disturb
+func (fp *FanvilPhone) SetDND(enable bool) error {
+	value := "0"
+	if enable {
+		value = "1"
+	}
+	return fp.SetConfig(map[string]interface{}{fvDNDKey: value})
+}
+
+// GetPhoneState returns the DND state; Fanvil does not report calls over HTTP
+func (fp *FanvilPhone) GetPhoneState() (*CTIPhoneState, error) {
+	config, err := fp.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get phone state: %w", err)
+	}
+	return &CTIPhoneState{DNDEnabled: config[fvDNDKey] == "1"}, nil
+}
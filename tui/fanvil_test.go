@@ -0,0 +1,131 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const fanvilTestConfig = `<<VOIP CONFIG FILE>>Version:2.0002
+
+<SIP CONFIG MODULE>
+SIP  Port               :5060
+--SIP Line List--       :
+SIP1 Phone Number       :101
+SIP1 Register Addr      :192.168.1.10
+
+<CALL FEATURE MODULE>
+Enable DND              :1
+`
+
+// fakeFanvil is a minimal Fanvil ConfigManApp.com recording key sequences and imports
+type fakeFanvil struct {
+	mu       sync.Mutex
+	keys     []string
+	imported string
+	commands []string
+}
+
+func newFakeFanvil(t *testing.T) (*fakeFanvil, *FanvilPhone) {
+	t.Helper()
+	fake := &fakeFanvil{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "admin" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+
+		q := r.URL.Query()
+		switch {
+		case q.Get("key") != "":
+			fake.keys = append(fake.keys, q.Get("key"))
+		case q.Get("Command") != "":
+			fake.commands = append(fake.commands, q.Get("Command"))
+		case q.Get("Id") == "1":
+			w.Write([]byte("Product Name: X4U\nSoftware Version: 2.4.1\nMAC Address: 0c:38:3e:11:22:33\nSIP1 Status: Registered\nSIP1 Phone Number: 101\n"))
+		case q.Get("Id") == "26" && r.Method == "GET":
+			w.Write([]byte(fanvilTestConfig))
+		case q.Get("Id") == "26":
+			file, _, err := r.FormFile("file")
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			data, _ := io.ReadAll(file)
+			fake.imported = string(data)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	creds := map[string]string{"username": "admin", "password": "secret", "sip_server": "192.168.1.10"}
+	return fake, NewFanvilPhone(ts.URL[7:], creds, nil)
+}
+
+func TestFanvilConfigFormat(t *testing.T) {
+	values := ParseFanvilConfig(fanvilTestConfig)
+	if values["SIP CONFIG MODULE/SIP1 Phone Number"] != "101" || values[fvDNDKey] != "1" || len(values) != 4 {
+		t.Errorf("unexpected parsed config: %v", values)
+	}
+
+	rendered, err := RenderFanvilConfig(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ParseFanvilConfig(rendered), values) {
+		t.Errorf("config did not round-trip:\n%s", rendered)
+	}
+	if _, err := RenderFanvilConfig(map[string]string{"NoModule": "1"}); err == nil {
+		t.Error("expected error for parameter without module")
+	}
+}
+
+func TestFanvilPhone(t *testing.T) {
+	fake, phone := newFakeFanvil(t)
+
+	status, err := phone.GetStatus()
+	if err != nil {
+		t.Fatalf("GetStatus failed: %v", err)
+	}
+	if status.Model != "X4U" || !status.Registered || len(status.Accounts) != 1 {
+		t.Errorf("unexpected status: %+v", status)
+	}
+
+	if state, err := phone.GetPhoneState(); err != nil || !state.DNDEnabled {
+		t.Errorf("expected DND enabled, got %+v (err %v)", state, err)
+	}
+
+	if err := phone.ProvisionExtension(Extension{ExtensionNumber: "105", Secret: "pa55"}, 2); err != nil {
+		t.Fatalf("ProvisionExtension failed: %v", err)
+	}
+	if !strings.HasPrefix(fake.imported, fvConfigHeader) {
+		t.Errorf("import missing header:\n%s", fake.imported)
+	}
+	imported := ParseFanvilConfig(fake.imported)
+	if imported["SIP CONFIG MODULE/SIP2 Register User"] != "105" || imported["SIP CONFIG MODULE/SIP2 Register Pswd"] != "pa55" {
+		t.Errorf("unexpected provisioning parameters: %v", imported)
+	}
+
+	if err := phone.Dial("102", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := phone.BlindTransfer("1*", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := phone.Reboot(); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"1;0;2;OK", "TRAN;1;STAR;TRAN"}; !reflect.DeepEqual(fake.keys, expected) {
+		t.Errorf("expected key sequences %v, got %v", expected, fake.keys)
+	}
+	if !reflect.DeepEqual(fake.commands, []string{"Reboot"}) {
+		t.Errorf("unexpected commands %v", fake.commands)
+	}
+}
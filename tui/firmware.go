@@ -0,0 +1,1147 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Firmware repository defaults, overridable through PROVISIONING_FIRMWARE_DIR and RAYANPBX_FIRMWARE_POLICY
+const (
+	DefaultFirmwareDir        = "/var/lib/rayanpbx/firmware"
+	DefaultFirmwarePolicyFile = "/etc/rayanpbx/firmware-policy.json"
+	DefaultFirmwareReportPath = "/tmp/rayanpbx-firmware-upgrade.json"
+	firmwareURLPrefix         = "/firmware/"
+)
+
+// Defaults for staged firmware upgrades
+const (
+	DefaultUpgradeCanaries     = 1
+	DefaultUpgradeTimeout      = 15 * time.Minute
+	DefaultUpgradePollInterval = 20 * time.Second
+)
+
+// firmwareUpgradeAction names staged upgrades in bulk reports
+const firmwareUpgradeAction = "firmware-upgrade"
+
+// Stages of a staged firmware upgrade
+const (
+	firmwareStageCanary  = "canary"
+	firmwareStageRollout = "rollout"
+)
+
+// Firmware policy statuses of a phone
+const (
+	firmwareCompliant = "compliant"
+	firmwareOutdated  = "outdated"
+	firmwareAhead     = "ahead"
+	firmwareUnknown   = "unknown" // The phone has not reported its version
+	firmwareNoPolicy  = "no-policy"
+)
+
+// errFirmwareNotFound is returned for paths that are not an image in the repository
+var errFirmwareNotFound = errors.New("no such firmware image")
+
+// FirmwareImage is a firmware file stored as <vendor>/<model>/<version>/<file>
+type FirmwareImage struct {
+	Vendor   string    `json:"vendor"`
+	Model    string    `json:"model"`
+	Version  string    `json:"version"`
+	File     string    `json:"file"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+	Path     string    `json:"-"`
+}
+
+// URLPath returns the path the provisioning server serves the image at
+func (img FirmwareImage) URLPath() string {
+	segments := []string{img.Vendor, img.Model, img.Version, img.File}
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return firmwareURLPrefix + strings.Join(segments, "/")
+}
+
+// FirmwareURL returns the URL phones download an image from, with the server credentials if set
+func FirmwareURL(settings ProvisioningSettings, image FirmwareImage) string {
+	base := settings.URL()
+	if settings.Username != "" {
+		base = strings.Replace(base, "://", "://"+settings.Username+":"+settings.Password+"@", 1)
+	}
+	return base + strings.TrimPrefix(image.URLPath(), "/")
+}
+
+// FirmwareRepository stores firmware images per vendor, model and version
+type FirmwareRepository struct {
+	Dir string
+}
+
+// NewFirmwareRepository returns the repository rooted at dir
+func NewFirmwareRepository(dir string) *FirmwareRepository {
+	return &FirmwareRepository{Dir: dir}
+}
+
+// validFirmwareSegment reports whether s can be used as one path segment of the repository
+func validFirmwareSegment(s string) bool {
+	return s != "" && !strings.HasPrefix(s, ".") && !strings.ContainsAny(s, `/\`)
+}
+
+// Images lists the images in the repository. A missing directory means no images.
+func (r *FirmwareRepository) Images() ([]FirmwareImage, error) {
+	files, err := filepath.Glob(filepath.Join(r.Dir, "*", "*", "*", "*"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list firmware images: %v", err)
+	}
+	var images []FirmwareImage
+	for _, path := range files {
+		rel, err := filepath.Rel(r.Dir, path)
+		if err != nil {
+			continue
+		}
+		image, err := r.image(strings.Split(rel, string(filepath.Separator)))
+		if err != nil {
+			continue
+		}
+		images = append(images, *image)
+	}
+	sort.Slice(images, func(i, j int) bool {
+		a, b := images[i], images[j]
+		if a.Vendor != b.Vendor {
+			return a.Vendor < b.Vendor
+		}
+		if a.Model != b.Model {
+			return a.Model < b.Model
+		}
+		return CompareFirmwareVersions(a.Version, b.Version) < 0
+	})
+	return images, nil
+}
+
+// Lookup returns the image served at a /firmware/ URL path
+func (r *FirmwareRepository) Lookup(urlPath string) (*FirmwareImage, error) {
+	return r.image(strings.Split(strings.TrimPrefix(urlPath, firmwareURLPrefix), "/"))
+}
+
+// image returns the image at the vendor, model, version and file segments
+func (r *FirmwareRepository) image(segments []string) (*FirmwareImage, error) {
+	if len(segments) != 4 {
+		return nil, errFirmwareNotFound
+	}
+	for _, segment := range segments {
+		if !validFirmwareSegment(segment) {
+			return nil, errFirmwareNotFound
+		}
+	}
+	path := filepath.Join(r.Dir, filepath.Join(segments...))
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return nil, errFirmwareNotFound
+	}
+	return &FirmwareImage{Vendor: segments[0], Model: segments[1], Version: segments[2], File: segments[3],
+		Size: info.Size(), Modified: info.ModTime(), Path: path}, nil
+}
+
+// Add copies an image file into the repository, replacing the image of the same version
+func (r *FirmwareRepository) Add(vendor, model, version, src string) (FirmwareImage, error) {
+	vendor = strings.ToLower(strings.TrimSpace(vendor))
+	model = strings.TrimSpace(model)
+	version = strings.TrimSpace(version)
+	name := filepath.Base(src)
+	if driver := LookupPhoneDriver(vendor); driver == nil || !driver.Manageable() {
+		return FirmwareImage{}, fmt.Errorf("unknown vendor %q (use %s)", vendor, strings.Join(ManageablePhoneVendors(), ", "))
+	}
+	for _, segment := range []string{model, version, name} {
+		if !validFirmwareSegment(segment) {
+			return FirmwareImage{}, fmt.Errorf("invalid model, version or file name %q", segment)
+		}
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return FirmwareImage{}, fmt.Errorf("failed to open firmware image: %v", err)
+	}
+	defer in.Close()
+	dir := filepath.Join(r.Dir, vendor, model, version)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return FirmwareImage{}, fmt.Errorf("failed to create directory: %v", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return FirmwareImage{}, fmt.Errorf("failed to store firmware image: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, in)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return FirmwareImage{}, fmt.Errorf("failed to store firmware image: %v", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return FirmwareImage{}, fmt.Errorf("failed to store firmware image: %v", err)
+	}
+
+	// A version holds a single image
+	old, _ := filepath.Glob(filepath.Join(dir, "*"))
+	for _, path := range old {
+		if filepath.Base(path) != name && validFirmwareSegment(filepath.Base(path)) {
+			os.Remove(path)
+		}
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, name)); err != nil {
+		return FirmwareImage{}, fmt.Errorf("failed to store firmware image: %v", err)
+	}
+	image, err := r.image([]string{vendor, model, version, name})
+	if err != nil {
+		return FirmwareImage{}, err
+	}
+	return *image, nil
+}
+
+// serveFirmware serves an image of the firmware repository
+func (ps *ProvisioningServer) serveFirmware(w http.ResponseWriter, r *http.Request, entry *ProvisioningLogEntry) {
+	image, err := NewFirmwareRepository(ps.settings.FirmwareDir).Lookup(r.URL.Path)
+	if err != nil {
+		entry.Status = http.StatusNotFound
+		http.NotFound(w, r)
+		return
+	}
+	f, err := os.Open(image.Path)
+	if err != nil {
+		entry.Status = http.StatusInternalServerError
+		http.Error(w, "failed to open firmware image", entry.Status)
+		return
+	}
+	defer f.Close()
+
+	entry.Vendor = image.Vendor
+	entry.Status = http.StatusOK
+	entry.Bytes = int(image.Size)
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, r, image.File, image.Modified, f)
+}
+
+// firmwareModelKey normalizes a model name so "SIP-T46S" and "T46S" match
+func firmwareModelKey(model string) string {
+	var b strings.Builder
+	for _, c := range strings.ToLower(model) {
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') {
+			b.WriteRune(c)
+		}
+	}
+	return strings.TrimPrefix(b.String(), "sip")
+}
+
+// sameFirmwareModel reports whether a vendor and model pair names the same phone model
+func sameFirmwareModel(vendorA, modelA, vendorB, modelB string) bool {
+	return strings.EqualFold(vendorA, vendorB) && firmwareModelKey(modelA) != "" && firmwareModelKey(modelA) == firmwareModelKey(modelB)
+}
+
+// FindFirmwareImage returns the image of a model and version, or nil
+func FindFirmwareImage(images []FirmwareImage, vendor, model, version string) *FirmwareImage {
+	for i := range images {
+		if sameFirmwareModel(images[i].Vendor, images[i].Model, vendor, model) && images[i].Version == version {
+			return &images[i]
+		}
+	}
+	return nil
+}
+
+// CompareFirmwareVersions compares dotted versions part by part, numerically where both parts
+// are numbers, returning -1, 0 or 1
+func CompareFirmwareVersions(a, b string) int {
+	split := func(v string) []string {
+		return strings.FieldsFunc(v, func(c rune) bool { return c == '.' || c == '-' || c == '_' || c == ' ' })
+	}
+	pa, pb := split(a), split(b)
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		x, y := "0", "0"
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		nx, errX := strconv.Atoi(x)
+		ny, errY := strconv.Atoi(y)
+		switch {
+		case errX == nil && errY == nil && nx != ny:
+			if nx < ny {
+				return -1
+			}
+			return 1
+		case (errX != nil || errY != nil) && x != y:
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// FirmwareTarget is the version every phone of a model should run
+type FirmwareTarget struct {
+	Vendor  string `json:"vendor"`
+	Model   string `json:"model"`
+	Version string `json:"version"`
+}
+
+// firmwarePolicyFilePath returns the firmware policy path, overridable with RAYANPBX_FIRMWARE_POLICY
+func firmwarePolicyFilePath() string {
+	return getEnv("RAYANPBX_FIRMWARE_POLICY", DefaultFirmwarePolicyFile)
+}
+
+// LoadFirmwarePolicy reads the target versions. A missing file means no targets.
+func LoadFirmwarePolicy(path string) ([]FirmwareTarget, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read firmware policy: %v", err)
+	}
+	var policy struct {
+		Targets []FirmwareTarget `json:"targets"`
+	}
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse firmware policy %s: %v", path, err)
+	}
+	return policy.Targets, nil
+}
+
+// SetFirmwareTarget sets the target version of a model, or removes it when the version is
+// empty. The target version must have an image in the repository.
+func SetFirmwareTarget(path string, target FirmwareTarget, images []FirmwareImage) ([]FirmwareTarget, error) {
+	target.Vendor = strings.ToLower(strings.TrimSpace(target.Vendor))
+	target.Model = strings.TrimSpace(target.Model)
+	target.Version = strings.TrimSpace(target.Version)
+	if target.Vendor == "" || firmwareModelKey(target.Model) == "" {
+		return nil, fmt.Errorf("a firmware target needs a vendor and a model")
+	}
+	if target.Version != "" && FindFirmwareImage(images, target.Vendor, target.Model, target.Version) == nil {
+		return nil, fmt.Errorf("no %s %s image of version %s in the firmware repository", target.Vendor, target.Model, target.Version)
+	}
+	policy, err := LoadFirmwarePolicy(path)
+	if err != nil {
+		return nil, err
+	}
+	var updated []FirmwareTarget
+	for _, existing := range policy {
+		if !sameFirmwareModel(existing.Vendor, existing.Model, target.Vendor, target.Model) {
+			updated = append(updated, existing)
+		}
+	}
+	if target.Version != "" {
+		updated = append(updated, target)
+	}
+	sort.Slice(updated, func(i, j int) bool {
+		if updated[i].Vendor != updated[j].Vendor {
+			return updated[i].Vendor < updated[j].Vendor
+		}
+		return updated[i].Model < updated[j].Model
+	})
+
+	data, err := json.MarshalIndent(struct {
+		Targets []FirmwareTarget `json:"targets"`
+	}{updated}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode firmware policy: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return nil, fmt.Errorf("failed to save firmware policy: %v", err)
+	}
+	return updated, nil
+}
+
+// FindFirmwareTarget returns the target of a phone model, or nil
+func FindFirmwareTarget(policy []FirmwareTarget, vendor, model string) *FirmwareTarget {
+	for i := range policy {
+		if sameFirmwareModel(policy[i].Vendor, policy[i].Model, vendor, model) {
+			return &policy[i]
+		}
+	}
+	return nil
+}
+
+// FirmwareComplianceEntry is a phone checked against the firmware policy
+type FirmwareComplianceEntry struct {
+	Phone  BulkPhone
+	Target string         // Target version, empty without a policy for the model
+	Status string         // compliant, outdated, ahead, unknown or no-policy
+	Image  *FirmwareImage // Image of the target version
+}
+
+// OutOfPolicy reports whether the phone does not run its target version
+func (e FirmwareComplianceEntry) OutOfPolicy() bool {
+	return e.Status == firmwareOutdated || e.Status == firmwareAhead || e.Status == firmwareUnknown
+}
+
+// Upgradable reports whether the phone should be upgraded to the target image
+func (e FirmwareComplianceEntry) Upgradable() bool {
+	return (e.Status == firmwareOutdated || e.Status == firmwareUnknown) && e.Image != nil
+}
+
+// CheckFirmwareCompliance compares every phone's version with the target of its model
+func CheckFirmwareCompliance(phones []BulkPhone, policy []FirmwareTarget, images []FirmwareImage) []FirmwareComplianceEntry {
+	entries := make([]FirmwareComplianceEntry, 0, len(phones))
+	for _, phone := range phones {
+		entry := FirmwareComplianceEntry{Phone: phone, Status: firmwareNoPolicy}
+		if target := FindFirmwareTarget(policy, phone.Vendor, phone.Model); target != nil {
+			entry.Target = target.Version
+			entry.Image = FindFirmwareImage(images, target.Vendor, target.Model, target.Version)
+			switch cmp := CompareFirmwareVersions(phone.Firmware, target.Version); {
+			case phone.Firmware == "":
+				entry.Status = firmwareUnknown
+			case cmp < 0:
+				entry.Status = firmwareOutdated
+			case cmp > 0:
+				entry.Status = firmwareAhead
+			default:
+				entry.Status = firmwareCompliant
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// FirmwareUpgradeTarget is a phone and the image it is upgraded to
+type FirmwareUpgradeTarget struct {
+	Phone BulkPhone
+	Image FirmwareImage
+}
+
+// FirmwareUpgradeTargets returns the upgradable phones of a compliance check
+func FirmwareUpgradeTargets(entries []FirmwareComplianceEntry) []FirmwareUpgradeTarget {
+	var targets []FirmwareUpgradeTarget
+	for _, entry := range entries {
+		if entry.Upgradable() {
+			targets = append(targets, FirmwareUpgradeTarget{Phone: entry.Phone, Image: *entry.Image})
+		}
+	}
+	return targets
+}
+
+// FirmwareUpgrade describes a staged upgrade: the canary phones first, then the rest
+// only if every canary booted its new version
+type FirmwareUpgrade struct {
+	Canaries     []string      // Canary phones by IP
+	CanaryCount  int           // Number of canary phones when Canaries is empty
+	Workers      int           // Phones upgraded at once within a stage
+	Timeout      time.Duration // Limit for each phone to install and boot the new version
+	PollInterval time.Duration // Time between version checks while a phone upgrades
+}
+
+// ParseCanarySpec parses a number of canary phones or a comma-separated list of their IPs
+func ParseCanarySpec(value string) (ips []string, count int, err error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, 0, nil
+	}
+	if n, err := strconv.Atoi(value); err == nil {
+		if n < 0 {
+			return nil, 0, fmt.Errorf("the number of canary phones cannot be negative")
+		}
+		return nil, n, nil
+	}
+	for _, ip := range strings.Split(value, ",") {
+		if ip = strings.TrimSpace(ip); ip != "" {
+			ips = append(ips, ip)
+		}
+	}
+	return ips, 0, nil
+}
+
+// Validate checks that the upgrade settings are usable
+func (u FirmwareUpgrade) Validate() error {
+	if u.CanaryCount < 0 {
+		return fmt.Errorf("the number of canary phones cannot be negative")
+	}
+	if u.Workers < 1 || u.Workers > maxBulkWorkers {
+		return fmt.Errorf("workers must be between 1 and %d", maxBulkWorkers)
+	}
+	if u.Timeout <= 0 || u.PollInterval <= 0 {
+		return fmt.Errorf("the per-phone timeout and poll interval must be positive")
+	}
+	return nil
+}
+
+// SplitCanaryTargets splits the targets into the canary stage and the rest
+func SplitCanaryTargets(targets []FirmwareUpgradeTarget, u FirmwareUpgrade) (canary, rest []FirmwareUpgradeTarget, err error) {
+	if len(u.Canaries) == 0 {
+		n := u.CanaryCount
+		if n > len(targets) {
+			n = len(targets)
+		}
+		return targets[:n], targets[n:], nil
+	}
+	wanted := make(map[string]bool, len(u.Canaries))
+	for _, ip := range u.Canaries {
+		wanted[ip] = true
+	}
+	for _, target := range targets {
+		if wanted[target.Phone.IP] {
+			canary = append(canary, target)
+			delete(wanted, target.Phone.IP)
+		} else {
+			rest = append(rest, target)
+		}
+	}
+	for _, ip := range u.Canaries {
+		if wanted[ip] {
+			return nil, nil, fmt.Errorf("canary %s is not a phone to upgrade", ip)
+		}
+	}
+	return canary, rest, nil
+}
+
+// RunStagedUpgrade upgrades the canary phones, verifies they booted the new version, and
+// then upgrades the rest. When a canary fails the rest are skipped. progress, if set, is
+// called from the workers as each phone finishes.
+func RunStagedUpgrade(pm *PhoneManager, canary, rest []FirmwareUpgradeTarget, u FirmwareUpgrade, settings ProvisioningSettings, progress func(BulkPhoneResult)) BulkReport {
+	report := BulkReport{Action: firmwareUpgradeAction, StartedAt: time.Now(), Total: len(canary) + len(rest)}
+	sessions := NewGrandStreamSessionManager(nil)
+	run := func(targets []FirmwareUpgradeTarget, stage string) []BulkPhoneResult {
+		results := make([]BulkPhoneResult, len(targets))
+		runPhoneWorkers(len(targets), u.Workers, func(i int) {
+			started := time.Now()
+			results[i] = upgradeFirmwarePhone(pm, sessions, targets[i], u, settings)
+			results[i].Stage = stage
+			results[i].DurationMS = time.Since(started).Milliseconds()
+			if progress != nil {
+				progress(results[i])
+			}
+		})
+		return results
+	}
+
+	results := run(canary, firmwareStageCanary)
+	canaryFailed := false
+	for _, result := range results {
+		if result.Status != bulkStatusOK {
+			canaryFailed = true
+		}
+	}
+	if canaryFailed {
+		for _, target := range rest {
+			result := BulkPhoneResult{IP: target.Phone.IP, MAC: target.Phone.MAC, Vendor: target.Phone.Vendor, Stage: firmwareStageRollout,
+				Status: bulkStatusSkipped, Message: "not upgraded because a canary phone failed"}
+			results = append(results, result)
+			if progress != nil {
+				progress(result)
+			}
+		}
+	} else {
+		results = append(results, run(rest, firmwareStageRollout)...)
+	}
+
+	report.FinishedAt = time.Now()
+	report.Results = results
+	report.tally()
+	return report
+}
+
+// upgradeFirmwarePhone upgrades one phone and waits until it runs the new version
+func upgradeFirmwarePhone(pm *PhoneManager, sessions *GrandStreamSessionManager, target FirmwareUpgradeTarget, u FirmwareUpgrade, settings ProvisioningSettings) BulkPhoneResult {
+	vp, result, ok := openBulkPhone(pm, target.Phone, CapFirmware, "firmware upgrade")
+	if !ok {
+		return result
+	}
+	version := target.Image.Version
+	read := func() (string, error) {
+		return readFirmwareVersion(vp, sessions)
+	}
+	if current, err := read(); err == nil && CompareFirmwareVersions(current, version) == 0 {
+		result.Status = bulkStatusOK
+		result.Firmware = current
+		result.Message = "already running " + current
+		return result
+	}
+
+	if err := UpgradePhoneFirmware(vp, FirmwareURL(settings, target.Image)); err != nil {
+		result.Status = bulkStatusFailed
+		result.Message = err.Error()
+		return result
+	}
+	booted, err := waitForFirmwareVersion(read, version, u.Timeout, u.PollInterval)
+	result.Firmware = booted
+	if err != nil {
+		result.Status = bulkStatusFailed
+		result.Message = err.Error()
+		return result
+	}
+	from := target.Phone.Firmware
+	if from == "" {
+		from = "unknown version"
+	}
+	result.Status = bulkStatusOK
+	result.Message = fmt.Sprintf("upgraded from %s to %s", from, booted)
+	return result
+}
+
+// UpgradePhoneFirmware starts a firmware upgrade from the given firmware file URL
+func UpgradePhoneFirmware(phone VoIPPhone, firmwareURL string) error {
+	switch p := phone.(type) {
+	case *GrandStreamPhone:
+		resp, err := NewGrandStreamCTI(p).TriggerUpgrade(firmwareURL)
+		if err != nil {
+			return fmt.Errorf("failed to start firmware upgrade: %v", err)
+		}
+		if !resp.Success {
+			return fmt.Errorf("phone refused the firmware upgrade: %s", resp.Response)
+		}
+		return nil
+	case *YealinkPhone:
+		if err := p.SetConfig(map[string]interface{}{"static.firmware.url": firmwareURL}); err != nil {
+			return fmt.Errorf("failed to set firmware URL: %v", err)
+		}
+		if err := p.AutoProvision(); err != nil {
+			return fmt.Errorf("firmware URL set, but failed to start the upgrade: %v", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("firmware upgrades are not supported for this phone")
+}
+
+// readFirmwareVersion asks a phone which firmware version it runs. GrandStream phones
+// report it through GetDeviceInfo, with a fresh session since sessions do not survive a reboot.
+func readFirmwareVersion(phone VoIPPhone, sessions *GrandStreamSessionManager) (string, error) {
+	if gs, ok := phone.(*GrandStreamPhone); ok {
+		username := gs.credentials["username"]
+		if username == "" {
+			username = "admin"
+		}
+		sessions.Logout(gs.ip)
+		session, err := sessions.Login(gs.ip, username, gs.credentials["password"])
+		if err != nil {
+			return "", err
+		}
+		info, err := sessions.GetDeviceInfo(session)
+		if err != nil {
+			return "", err
+		}
+		return info.ProgVersion, nil
+	}
+	status, err := phone.GetStatus()
+	if err != nil {
+		return "", err
+	}
+	return status.Firmware, nil
+}
+
+// waitForFirmwareVersion polls the phone until it reports version, returning the last version read
+func waitForFirmwareVersion(read func() (string, error), version string, timeout, interval time.Duration) (string, error) {
+	deadline := time.Now().Add(timeout)
+	last := ""
+	var lastErr error
+	for {
+		time.Sleep(interval)
+		current, err := read()
+		if err == nil {
+			last, lastErr = current, nil
+			if CompareFirmwareVersions(current, version) == 0 {
+				return current, nil
+			}
+		} else {
+			lastErr = err
+		}
+		if time.Now().Add(interval).After(deadline) {
+			break
+		}
+	}
+	if lastErr != nil {
+		return last, fmt.Errorf("phone did not come back with %s within %s: %v", version, timeout, lastErr)
+	}
+	return last, fmt.Errorf("phone still runs %s after %s, expected %s", last, timeout, version)
+}
+
+// recordUpgradedFirmware stores the versions the upgraded phones booted in the inventory
+func recordUpgradedFirmware(db *sql.DB, report BulkReport) {
+	for _, result := range report.Results {
+		if result.Status == bulkStatusOK && result.Firmware != "" {
+			RecordPhoneSighting(db, PhoneSighting{MAC: result.MAC, IP: result.IP, Firmware: result.Firmware, Status: "online", Source: "http"})
+		}
+	}
+}
+
+// Views of the firmware screen
+const (
+	firmwareViewCompliance = iota
+	firmwareViewRepository
+)
+
+// Firmware screen forms
+const (
+	firmwareFormImport = iota
+	firmwareFormTarget
+	firmwareFormUpgrade
+)
+
+// Firmware upgrade form field indices
+const (
+	firmwareFieldCanary = iota
+	firmwareFieldWorkers
+	firmwareFieldTimeout
+	firmwareFieldReport
+)
+
+// firmwareProgressMsg reports one finished phone of a running staged upgrade
+type firmwareProgressMsg struct {
+	result BulkPhoneResult
+}
+
+// firmwareDoneMsg carries the report of a finished staged upgrade
+type firmwareDoneMsg struct {
+	report BulkReport
+}
+
+// initFirmware opens the firmware screen with the compliance of every phone
+func (m *model) initFirmware() {
+	m.currentScreen = firmwareScreen
+	m.errorMsg = ""
+	m.successMsg = ""
+	m.firmwareView = firmwareViewCompliance
+	m.firmwareCursor = 0
+	m.refreshFirmware()
+}
+
+// refreshFirmware reloads the images, the policy and the phones
+func (m *model) refreshFirmware() {
+	images, err := NewFirmwareRepository(m.provisioningSettings().FirmwareDir).Images()
+	if err != nil {
+		m.errorMsg = err.Error()
+	}
+	policy, err := LoadFirmwarePolicy(firmwarePolicyFilePath())
+	if err != nil {
+		m.errorMsg = err.Error()
+	}
+	m.firmwareImages = images
+	m.firmwarePolicy = policy
+	m.firmwareCompliance = CheckFirmwareCompliance(m.bulkPhones(), policy, images)
+	if m.firmwareCursor >= m.firmwareListLen() {
+		m.firmwareCursor = 0
+	}
+}
+
+// firmwareListLen returns the number of rows of the current view
+func (m model) firmwareListLen() int {
+	if m.firmwareView == firmwareViewRepository {
+		return len(m.firmwareImages)
+	}
+	return len(m.firmwareCompliance)
+}
+
+// initFirmwareImportForm opens the form to add an image, filled from the selected row
+func (m *model) initFirmwareImportForm() {
+	vendor, phoneModel, _ := m.selectedFirmwareModel()
+	m.firmwareForm = firmwareFormImport
+	m.inputFields = []string{"Vendor (" + strings.Join(ManageablePhoneVendors(), ", ") + ")", "Model", "Version", "Image file path"}
+	m.inputValues = []string{vendor, phoneModel, "", ""}
+	m.inputMode = true
+	m.inputCursor = 0
+	m.errorMsg = ""
+	m.successMsg = ""
+}
+
+// importFirmwareImage copies the image from the import form into the repository
+func (m *model) importFirmwareImage() {
+	v := m.inputValues
+	image, err := NewFirmwareRepository(m.provisioningSettings().FirmwareDir).Add(v[0], v[1], v[2], strings.TrimSpace(v[3]))
+	if err != nil {
+		m.errorMsg = err.Error()
+		return
+	}
+	m.inputMode = false
+	m.refreshFirmware()
+	m.errorMsg = ""
+	m.successMsg = fmt.Sprintf("Added %s %s firmware %s (%d bytes) - press t to make it the target", image.Vendor, image.Model, image.Version, image.Size)
+}
+
+// selectedFirmwareModel returns the vendor, model and suggested version of the selected row
+func (m model) selectedFirmwareModel() (vendor, phoneModel, version string) {
+	if m.firmwareView == firmwareViewRepository {
+		if m.firmwareCursor < len(m.firmwareImages) {
+			image := m.firmwareImages[m.firmwareCursor]
+			return image.Vendor, image.Model, image.Version
+		}
+		return "", "", ""
+	}
+	if m.firmwareCursor >= len(m.firmwareCompliance) {
+		return "", "", ""
+	}
+	entry := m.firmwareCompliance[m.firmwareCursor]
+	version = entry.Target
+	for _, image := range m.firmwareImages {
+		if version == "" && sameFirmwareModel(image.Vendor, image.Model, entry.Phone.Vendor, entry.Phone.Model) {
+			version = image.Version // Newest image comes last
+		}
+	}
+	return entry.Phone.Vendor, entry.Phone.Model, version
+}
+
+// initFirmwareTargetForm opens the form to set a model's target version
+func (m *model) initFirmwareTargetForm() {
+	vendor, phoneModel, version := m.selectedFirmwareModel()
+	m.firmwareForm = firmwareFormTarget
+	m.inputFields = []string{"Vendor", "Model", "Target version (empty removes the target)"}
+	m.inputValues = []string{vendor, phoneModel, version}
+	m.inputMode = true
+	m.inputCursor = 0
+	m.errorMsg = ""
+	m.successMsg = ""
+}
+
+// saveFirmwareTarget saves the target from the target form
+func (m *model) saveFirmwareTarget() {
+	target := FirmwareTarget{Vendor: m.inputValues[0], Model: m.inputValues[1], Version: m.inputValues[2]}
+	if _, err := SetFirmwareTarget(firmwarePolicyFilePath(), target, m.firmwareImages); err != nil {
+		m.errorMsg = err.Error()
+		return
+	}
+	m.inputMode = false
+	m.refreshFirmware()
+	m.errorMsg = ""
+	if strings.TrimSpace(target.Version) == "" {
+		m.successMsg = fmt.Sprintf("Removed the firmware target of %s %s", target.Vendor, target.Model)
+		return
+	}
+	m.successMsg = fmt.Sprintf("%s %s phones should run %s", target.Vendor, target.Model, strings.TrimSpace(target.Version))
+}
+
+// initFirmwareUpgradeForm opens the form for a staged upgrade of the out-of-policy phones
+func (m *model) initFirmwareUpgradeForm() {
+	if len(FirmwareUpgradeTargets(m.firmwareCompliance)) == 0 {
+		m.errorMsg = "No outdated phones with a target image in the repository"
+		return
+	}
+	m.firmwareForm = firmwareFormUpgrade
+	m.inputFields = []string{
+		"Canary phones (a number, or IPs separated by commas)",
+		"Workers",
+		"Timeout per phone (seconds)",
+		"Report file (JSON)",
+	}
+	m.inputValues = []string{strconv.Itoa(DefaultUpgradeCanaries), strconv.Itoa(DefaultBulkWorkers),
+		strconv.Itoa(int(DefaultUpgradeTimeout.Seconds())), DefaultFirmwareReportPath}
+	m.inputMode = true
+	m.inputCursor = 0
+	m.errorMsg = ""
+	m.successMsg = ""
+}
+
+// parseFirmwareUpgradeForm builds the staged upgrade from the upgrade form
+func (m model) parseFirmwareUpgradeForm() (FirmwareUpgrade, error) {
+	v := m.inputValues
+	u := FirmwareUpgrade{PollInterval: DefaultUpgradePollInterval}
+	var err error
+	if u.Canaries, u.CanaryCount, err = ParseCanarySpec(v[firmwareFieldCanary]); err != nil {
+		return u, err
+	}
+	if u.Workers, err = strconv.Atoi(strings.TrimSpace(v[firmwareFieldWorkers])); err != nil {
+		return u, fmt.Errorf("workers must be a number")
+	}
+	seconds, err := strconv.Atoi(strings.TrimSpace(v[firmwareFieldTimeout]))
+	if err != nil {
+		return u, fmt.Errorf("the timeout must be a number of seconds")
+	}
+	u.Timeout = time.Duration(seconds) * time.Second
+	return u, u.Validate()
+}
+
+// startFirmwareUpgrade starts the staged upgrade from the upgrade form, streaming progress to the screen
+func (m *model) startFirmwareUpgrade() tea.Cmd {
+	u, err := m.parseFirmwareUpgradeForm()
+	if err != nil {
+		m.errorMsg = err.Error()
+		return nil
+	}
+	canary, rest, err := SplitCanaryTargets(FirmwareUpgradeTargets(m.firmwareCompliance), u)
+	if err != nil {
+		m.errorMsg = err.Error()
+		return nil
+	}
+	if m.phoneManager == nil {
+		m.phoneManager = NewPhoneManager(m.asteriskManager)
+	}
+	pm := m.phoneManager
+	settings := m.provisioningSettings()
+
+	updates := make(chan tea.Msg, len(canary)+len(rest)+1)
+	go func() {
+		report := RunStagedUpgrade(pm, canary, rest, u, settings, func(result BulkPhoneResult) {
+			updates <- firmwareProgressMsg{result: result}
+		})
+		updates <- firmwareDoneMsg{report: report}
+	}()
+
+	m.inputMode = false
+	m.firmwareReportPath = strings.TrimSpace(m.inputValues[firmwareFieldReport])
+	m.firmwareUpdates = updates
+	m.firmwareRunning = true
+	m.firmwareTotal = len(canary) + len(rest)
+	m.firmwareResults = nil
+	m.firmwareReport = nil
+	m.errorMsg = ""
+	m.successMsg = fmt.Sprintf("Upgrading %d canary phone(s), then %d more...", len(canary), len(rest))
+	return waitForBulkProgress(updates)
+}
+
+// recordFirmwareProgress adds a finished phone and waits for the next one
+func (m *model) recordFirmwareProgress(result BulkPhoneResult) tea.Cmd {
+	m.firmwareResults = append(m.firmwareResults, result)
+	return waitForBulkProgress(m.firmwareUpdates)
+}
+
+// finishFirmwareUpgrade shows the final report, writes it to the report file and
+// stores the new versions in the inventory
+func (m *model) finishFirmwareUpgrade(report BulkReport) {
+	m.firmwareRunning = false
+	m.firmwareUpdates = nil
+	m.firmwareReport = &report
+	m.firmwareResults = report.Results
+	if m.db != nil {
+		recordUpgradedFirmware(m.db, report)
+	}
+	m.errorMsg = ""
+	m.successMsg = ""
+	summary := fmt.Sprintf("Firmware upgrade: %d ok, %d failed, %d skipped", report.Succeeded, report.Failed, report.Skipped)
+	if m.firmwareReportPath != "" {
+		if err := WriteBulkReport(m.firmwareReportPath, report); err != nil {
+			m.errorMsg = fmt.Sprintf("%s - %v", summary, err)
+			return
+		}
+		summary += " - report saved to " + m.firmwareReportPath
+	}
+	if report.Failed > 0 {
+		m.errorMsg = summary
+	} else {
+		m.successMsg = summary
+	}
+}
+
+// handleFirmwareScreen handles keys on the firmware screen
+func (m *model) handleFirmwareScreen(msg tea.KeyMsg) (*model, tea.Cmd) {
+	if m.firmwareRunning {
+		if msg.String() == "q" || msg.String() == "ctrl+c" {
+			return m, tea.Quit
+		}
+		return m, nil // Wait for the running upgrade
+	}
+	if m.firmwareReport != nil {
+		switch msg.String() {
+		case "esc", "enter":
+			m.firmwareReport = nil
+			m.firmwareResults = nil
+			m.errorMsg = ""
+			m.successMsg = ""
+			m.refreshFirmware()
+		case "q":
+			return m, tea.Quit
+		}
+		return m, nil
+	}
+
+	switch msg.String() {
+	case "up", "k":
+		if m.firmwareCursor > 0 {
+			m.firmwareCursor--
+		} else if n := m.firmwareListLen(); n > 0 {
+			m.firmwareCursor = n - 1
+		}
+	case "down", "j":
+		if m.firmwareCursor < m.firmwareListLen()-1 {
+			m.firmwareCursor++
+		} else {
+			m.firmwareCursor = 0
+		}
+	case "v":
+		if m.firmwareView == firmwareViewCompliance {
+			m.firmwareView = firmwareViewRepository
+		} else {
+			m.firmwareView = firmwareViewCompliance
+		}
+		m.firmwareCursor = 0
+	case "i":
+		m.initFirmwareImportForm()
+	case "t":
+		m.initFirmwareTargetForm()
+	case "u":
+		m.initFirmwareUpgradeForm()
+	case "r":
+		m.errorMsg = ""
+		m.successMsg = ""
+		m.refreshFirmware()
+	case "esc":
+		m.errorMsg = ""
+		m.successMsg = ""
+		m.currentScreen = voipPhonesScreen
+	case "q":
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// handleFirmwareInput submits the open firmware form
+func (m *model) handleFirmwareInput() tea.Cmd {
+	switch m.firmwareForm {
+	case firmwareFormImport:
+		m.importFirmwareImage()
+	case firmwareFormTarget:
+		m.saveFirmwareTarget()
+	case firmwareFormUpgrade:
+		return m.startFirmwareUpgrade()
+	}
+	return nil
+}
+
+// renderFirmware renders the compliance or repository view, the open form, or the upgrade progress
+func (m model) renderFirmware() string {
+	content := infoStyle.Render("💾 Phone Firmware") + "\n\n"
+
+	if m.inputMode {
+		title := "Add firmware image"
+		switch m.firmwareForm {
+		case firmwareFormTarget:
+			title = "Set target version"
+		case firmwareFormUpgrade:
+			title = fmt.Sprintf("Staged upgrade of %d phone(s)", len(FirmwareUpgradeTargets(m.firmwareCompliance)))
+		}
+		content += title + "\n\n"
+		for i, field := range m.inputFields {
+			cursor := "  "
+			fieldStyle := lipgloss.NewStyle()
+			if i == m.inputCursor {
+				cursor = "▶ "
+				fieldStyle = selectedItemStyle
+			}
+			value := m.inputValues[i]
+			if value == "" {
+				value = helpStyle.Render("<empty>")
+			}
+			content += fmt.Sprintf("%s%s: %s\n", cursor, fieldStyle.Render(field), value)
+		}
+		if m.firmwareForm == firmwareFormUpgrade {
+			content += "\n" + helpStyle.Render("Canary phones upgrade first; the rest follow only if every canary boots the new version") + "\n"
+		}
+		return menuStyle.Render(content)
+	}
+
+	if m.firmwareRunning || m.firmwareReport != nil {
+		done := len(m.firmwareResults)
+		total := m.firmwareTotal
+		if m.firmwareReport != nil {
+			total = m.firmwareReport.Total
+		}
+		content += renderBulkProgressBar(done, total, 30) + fmt.Sprintf(" %d/%d\n\n", done, total)
+		for _, result := range m.firmwareResults {
+			icon := successStyle.Render("✓")
+			switch result.Status {
+			case bulkStatusFailed:
+				icon = errorStyle.Render("✗")
+			case bulkStatusSkipped:
+				icon = helpStyle.Render("-")
+			}
+			content += fmt.Sprintf("%s %-7s  %-15s  %-11s  %s\n", icon, result.Stage, result.IP, result.Vendor, result.Message)
+		}
+		return menuStyle.Render(content)
+	}
+
+	if m.provisioningServer == nil || !m.provisioningServer.Running() {
+		content += warningStyle.Render("⚠️  Phones download images from the provisioning server - start it before upgrading") + "\n\n"
+	}
+	if m.firmwareView == firmwareViewRepository {
+		return menuStyle.Render(content + m.renderFirmwareRepository())
+	}
+
+	outOfPolicy := 0
+	for _, entry := range m.firmwareCompliance {
+		if entry.OutOfPolicy() {
+			outOfPolicy++
+		}
+	}
+	content += fmt.Sprintf("%d phone(s) • %d out of policy • %d upgradable • %d target(s)\n\n",
+		len(m.firmwareCompliance), outOfPolicy, len(FirmwareUpgradeTargets(m.firmwareCompliance)), len(m.firmwarePolicy))
+	if len(m.firmwareCompliance) == 0 {
+		content += "📭 No phones in the inventory\n"
+		return menuStyle.Render(content)
+	}
+	content += helpStyle.Render(fmt.Sprintf("  %-15s  %-11s  %-12s  %-14s  %-14s  %s", "IP", "Vendor", "Model", "Firmware", "Target", "Status")) + "\n"
+	for i, entry := range m.firmwareCompliance {
+		status := entry.Status
+		if entry.OutOfPolicy() && entry.Image == nil {
+			status += " (no image)"
+		}
+		line := fmt.Sprintf("%-15s  %-11s  %-12s  %-14s  %-14s  ", entry.Phone.IP, entry.Phone.Vendor, truncateText(entry.Phone.Model, 12),
+			truncateText(entry.Phone.Firmware, 14), truncateText(entry.Target, 14))
+		switch {
+		case i == m.firmwareCursor:
+			content += "▶ " + selectedItemStyle.Render(line+status) + "\n"
+			continue
+		case entry.Status == firmwareCompliant:
+			status = successStyle.Render(status)
+		case entry.OutOfPolicy():
+			status = warningStyle.Render(status)
+		default:
+			status = helpStyle.Render(status)
+		}
+		content += "  " + line + status + "\n"
+	}
+	return menuStyle.Render(content)
+}
+
+// renderFirmwareRepository lists the stored images, marking the target versions
+func (m model) renderFirmwareRepository() string {
+	content := fmt.Sprintf("Repository: %s • %d image(s)\n\n", m.provisioningSettings().FirmwareDir, len(m.firmwareImages))
+	if len(m.firmwareImages) == 0 {
+		return content + "📭 No firmware images - press i to add one\n"
+	}
+	content += helpStyle.Render(fmt.Sprintf("  %-11s  %-12s  %-14s  %-24s  %10s  %s", "Vendor", "Model", "Version", "File", "Size", "Target")) + "\n"
+	for i, image := range m.firmwareImages {
+		target := ""
+		if t := FindFirmwareTarget(m.firmwarePolicy, image.Vendor, image.Model); t != nil && t.Version == image.Version {
+			target = "★"
+		}
+		line := fmt.Sprintf("%-11s  %-12s  %-14s  %-24s  %10d  %s", image.Vendor, truncateText(image.Model, 12),
+			truncateText(image.Version, 14), truncateText(image.File, 24), image.Size, target)
+		if i == m.firmwareCursor {
+			content += "▶ " + selectedItemStyle.Render(line) + "\n"
+		} else {
+			content += "  " + line + "\n"
+		}
+	}
+	return content
+}
+
+// firmwareHelp returns the key help for the firmware screen
+func (m model) firmwareHelp() string {
+	switch {
+	case m.inputMode:
+		return "↑/↓: Navigate Fields • Enter: Next/Submit • ESC: Cancel • q: Quit"
+	case m.firmwareRunning:
+		return "Upgrading... • q: Quit"
+	case m.firmwareReport != nil:
+		return "Enter/ESC: Back to Firmware • q: Quit"
+	}
+	view := "v: Repository"
+	if m.firmwareView == firmwareViewRepository {
+		view = "v: Compliance"
+	}
+	return "↑/↓: Navigate • " + view + " • i: Add Image • t: Set Target • u: Staged Upgrade • r: Refresh • ESC: Back • q: Quit"
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// fakeGrandStreamFirmware is a GrandStream phone that boots newVersion after an upgrade request
+type fakeGrandStreamFirmware struct {
+	mu         sync.Mutex
+	version    string
+	newVersion string
+	upgradeURL string
+}
+
+func newFakeGrandStreamFirmware(t *testing.T, version, newVersion string) (*fakeGrandStreamFirmware, string) {
+	t.Helper()
+	fake := &fakeGrandStreamFirmware{version: version, newVersion: newVersion}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		switch r.URL.Path {
+		case "/cgi-bin/dologin":
+			r.ParseForm()
+			if r.Form.Get("password") != "secret" {
+				w.Write([]byte(`{"response":"error","body":"auth"}`))
+				return
+			}
+			w.Write([]byte(`{"response":"success","body":{"sid":"sid1","role":"admin"}}`))
+		case "/cgi-bin/api.values.get":
+			json.NewEncoder(w).Encode(map[string]interface{}{"response": "success",
+				"body": map[string]interface{}{"phone_model": "GXP1625", "prog_version": fake.version}})
+		case "/cgi-bin/api-phone_operation":
+			fake.upgradeURL = r.URL.Query().Get("url")
+			fake.version = fake.newVersion
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return fake, ts.URL[7:]
+}
+
+func TestCompareFirmwareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0.7.13", "1.0.7.13", 0},
+		{"1.0.7.9", "1.0.7.13", -1},
+		{"66.86.0.15", "66.85.0.5", 1},
+		{"1.0", "1.0.0", 0},
+		{"2.1-rc1", "2.1-rc2", -1},
+		{"", "1.0", -1},
+	}
+	for _, tt := range tests {
+		if got := CompareFirmwareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareFirmwareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+	if !sameFirmwareModel("yealink", "SIP-T46S", "Yealink", "t46s") || sameFirmwareModel("yealink", "T46S", "yealink", "T48S") {
+		t.Error("unexpected model matching")
+	}
+}
+
+func TestFirmwareRepository(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(t.TempDir(), "gxp1600fw.bin")
+	if err := os.WriteFile(src, []byte("firmware-1"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	repo := NewFirmwareRepository(filepath.Join(dir, "firmware"))
+
+	if images, err := repo.Images(); err != nil || len(images) != 0 {
+		t.Fatalf("expected an empty repository, got %v %v", images, err)
+	}
+	image, err := repo.Add("GrandStream", "GXP1625", "1.0.7.13", src)
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if image.Vendor != "grandstream" || image.Size != 10 || image.URLPath() != "/firmware/grandstream/GXP1625/1.0.7.13/gxp1600fw.bin" {
+		t.Errorf("unexpected image: %+v", image)
+	}
+	if _, err := repo.Add("grandstream", "GXP1625", "1.0.7.9", src); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	for _, bad := range [][]string{{"acme", "X1", "1.0"}, {"yealink", "..", "1.0"}, {"yealink", "T46S", "a/b"}} {
+		if _, err := repo.Add(bad[0], bad[1], bad[2], src); err == nil {
+			t.Errorf("expected %v to be rejected", bad)
+		}
+	}
+
+	// Adding another file for the same version replaces the image
+	other := filepath.Join(t.TempDir(), "gxp1600fw-new.bin")
+	os.WriteFile(other, []byte("firmware-1b"), 0600)
+	if _, err := repo.Add("grandstream", "GXP1625", "1.0.7.13", other); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	images, err := repo.Images()
+	if err != nil || len(images) != 2 || images[0].Version != "1.0.7.9" || images[1].File != "gxp1600fw-new.bin" {
+		t.Fatalf("unexpected images: %+v %v", images, err)
+	}
+	if _, err := repo.Lookup("/firmware/grandstream/GXP1625/../../../etc/passwd"); err == nil {
+		t.Error("expected path traversal to be rejected")
+	}
+
+	settings := ProvisioningSettings{HTTPAddr: ":8088", Host: "10.0.0.5", Username: "prov", Password: "pw", FirmwareDir: repo.Dir}
+	if url := FirmwareURL(settings, images[1]); url != "http://prov:pw@10.0.0.5:8088/firmware/grandstream/GXP1625/1.0.7.13/gxp1600fw-new.bin" {
+		t.Errorf("unexpected firmware URL %q", url)
+	}
+
+	server, err := NewProvisioningServer(settings, func(mac string) (*ProvisionedPhone, error) { return nil, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	get := func(path string, auth bool) (int, string) {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		if auth {
+			req.SetBasicAuth("prov", "pw")
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+	if code, body := get(images[1].URLPath(), true); code != http.StatusOK || body != "firmware-1b" {
+		t.Errorf("expected the image to be served, got %d %q", code, body)
+	}
+	if code, _ := get(images[1].URLPath(), false); code != http.StatusUnauthorized {
+		t.Errorf("expected basic auth to be required, got %d", code)
+	}
+	if code, _ := get("/firmware/grandstream/GXP1625/9.9/missing.bin", true); code != http.StatusNotFound {
+		t.Errorf("expected a missing image to be 404, got %d", code)
+	}
+	if log := server.Log(); len(log) != 3 || log[2].Vendor != "grandstream" || log[2].Bytes != 11 {
+		t.Errorf("unexpected request log: %+v", log)
+	}
+}
+
+func TestFirmwarePolicyAndCompliance(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "firmware-policy.json")
+	images := []FirmwareImage{
+		{Vendor: "grandstream", Model: "GXP1625", Version: "1.0.7.13", File: "gxp.bin"},
+		{Vendor: "yealink", Model: "T46S", Version: "66.86.0.15", File: "t46s.rom"},
+	}
+
+	if _, err := SetFirmwareTarget(path, FirmwareTarget{Vendor: "yealink", Model: "T46S", Version: "66.99.0.1"}, images); err == nil {
+		t.Error("expected a target without an image to be rejected")
+	}
+	if _, err := SetFirmwareTarget(path, FirmwareTarget{Vendor: "grandstream", Model: "GXP1625", Version: "1.0.7.13"}, images); err != nil {
+		t.Fatalf("SetFirmwareTarget: %v", err)
+	}
+	policy, err := SetFirmwareTarget(path, FirmwareTarget{Vendor: "Yealink", Model: "SIP-T46S", Version: "66.86.0.15"}, images)
+	if err != nil || len(policy) != 2 {
+		t.Fatalf("expected two targets, got %+v %v", policy, err)
+	}
+	if policy, _ := LoadFirmwarePolicy(path); len(policy) != 2 || policy[1].Vendor != "yealink" {
+		t.Fatalf("unexpected saved policy: %+v", policy)
+	}
+
+	phones := []BulkPhone{
+		{IP: "10.0.0.1", Vendor: "grandstream", Model: "GXP1625", Firmware: "1.0.7.9"},
+		{IP: "10.0.0.2", Vendor: "grandstream", Model: "GXP1625", Firmware: "1.0.7.13"},
+		{IP: "10.0.0.3", Vendor: "yealink", Model: "SIP-T46S", Firmware: "66.87.0.1"},
+		{IP: "10.0.0.4", Vendor: "yealink", Model: "T46S"},
+		{IP: "10.0.0.5", Vendor: "fanvil", Model: "X4U", Firmware: "2.0"},
+	}
+	entries := CheckFirmwareCompliance(phones, policy, images)
+	want := []string{firmwareOutdated, firmwareCompliant, firmwareAhead, firmwareUnknown, firmwareNoPolicy}
+	for i, entry := range entries {
+		if entry.Status != want[i] {
+			t.Errorf("%s: expected %s, got %s", entry.Phone.IP, want[i], entry.Status)
+		}
+	}
+	targets := FirmwareUpgradeTargets(entries)
+	if len(targets) != 2 || targets[0].Phone.IP != "10.0.0.1" || targets[1].Image.File != "t46s.rom" {
+		t.Fatalf("unexpected upgrade targets: %+v", targets)
+	}
+
+	canary, rest, err := SplitCanaryTargets(targets, FirmwareUpgrade{CanaryCount: 1})
+	if err != nil || len(canary) != 1 || len(rest) != 1 || canary[0].Phone.IP != "10.0.0.1" {
+		t.Errorf("unexpected split by count: %+v %+v %v", canary, rest, err)
+	}
+	canary, rest, err = SplitCanaryTargets(targets, FirmwareUpgrade{Canaries: []string{"10.0.0.4"}})
+	if err != nil || len(canary) != 1 || canary[0].Phone.IP != "10.0.0.4" || len(rest) != 1 {
+		t.Errorf("unexpected split by IP: %+v %+v %v", canary, rest, err)
+	}
+	if _, _, err := SplitCanaryTargets(targets, FirmwareUpgrade{Canaries: []string{"10.0.0.2"}}); err == nil {
+		t.Error("expected a compliant canary to be rejected")
+	}
+	if ips, count, err := ParseCanarySpec("10.0.0.1, 10.0.0.4"); err != nil || count != 0 || len(ips) != 2 {
+		t.Errorf("unexpected canary spec: %v %d %v", ips, count, err)
+	}
+	if _, _, err := ParseCanarySpec("-2"); err == nil {
+		t.Error("expected a negative canary count to be rejected")
+	}
+
+	if _, err := SetFirmwareTarget(path, FirmwareTarget{Vendor: "yealink", Model: "T46S"}, images); err != nil {
+		t.Fatalf("removing a target: %v", err)
+	}
+	if policy, _ := LoadFirmwarePolicy(path); len(policy) != 1 {
+		t.Errorf("expected the target to be removed, got %+v", policy)
+	}
+}
+
+func TestRunStagedUpgrade(t *testing.T) {
+	creds := map[string]string{"username": "admin", "password": "secret"}
+	image := FirmwareImage{Vendor: "grandstream", Model: "GXP1625", Version: "1.0.7.13", File: "gxp.bin"}
+	settings := ProvisioningSettings{HTTPAddr: ":8088", Host: "10.0.0.5"}
+	u := FirmwareUpgrade{Workers: 2, Timeout: 500 * time.Millisecond, PollInterval: 10 * time.Millisecond}
+	pm := NewPhoneManager(NewAsteriskManager())
+	target := func(ip string) FirmwareUpgradeTarget {
+		return FirmwareUpgradeTarget{Phone: BulkPhone{IP: ip, Vendor: "grandstream", Model: "GXP1625", Firmware: "1.0.7.9", Credentials: creds}, Image: image}
+	}
+
+	canaryFake, canaryIP := newFakeGrandStreamFirmware(t, "1.0.7.9", "1.0.7.13")
+	restFake, restIP := newFakeGrandStreamFirmware(t, "1.0.7.9", "1.0.7.13")
+	current, currentIP := newFakeGrandStreamFirmware(t, "1.0.7.13", "1.0.7.13")
+	var mu sync.Mutex
+	var stages []string
+	report := RunStagedUpgrade(pm, []FirmwareUpgradeTarget{target(canaryIP)}, []FirmwareUpgradeTarget{target(restIP), target(currentIP)}, u, settings,
+		func(result BulkPhoneResult) {
+			mu.Lock()
+			stages = append(stages, result.Stage)
+			mu.Unlock()
+		})
+	if report.Succeeded != 3 || report.Action != firmwareUpgradeAction || len(stages) != 3 || stages[0] != firmwareStageCanary {
+		t.Fatalf("unexpected report (stages %v): %+v", stages, report)
+	}
+	if r := report.Results[0]; r.Firmware != "1.0.7.13" || !strings.Contains(r.Message, "upgraded from 1.0.7.9 to 1.0.7.13") {
+		t.Errorf("unexpected canary result: %+v", r)
+	}
+	if canaryFake.upgradeURL != "http://10.0.0.5:8088/firmware/grandstream/GXP1625/1.0.7.13/gxp.bin" || restFake.upgradeURL == "" {
+		t.Errorf("unexpected upgrade URLs %q %q", canaryFake.upgradeURL, restFake.upgradeURL)
+	}
+	if current.upgradeURL != "" || !strings.Contains(report.Results[2].Message, "already running") {
+		t.Errorf("expected a phone on the target version not to be upgraded, got %+v", report.Results[2])
+	}
+
+	// A canary that keeps its old version halts the rollout
+	stuck, stuckIP := newFakeGrandStreamFirmware(t, "1.0.7.9", "1.0.7.9")
+	untouched, untouchedIP := newFakeGrandStreamFirmware(t, "1.0.7.9", "1.0.7.13")
+	report = RunStagedUpgrade(pm, []FirmwareUpgradeTarget{target(stuckIP)}, []FirmwareUpgradeTarget{target(untouchedIP)}, u, settings, nil)
+	if report.Failed != 1 || report.Skipped != 1 || stuck.upgradeURL == "" || untouched.upgradeURL != "" {
+		t.Fatalf("expected the rollout to stop after the failed canary: %+v", report)
+	}
+	if r := report.Results[0]; !strings.Contains(r.Message, "still runs 1.0.7.9") {
+		t.Errorf("unexpected canary failure: %+v", r)
+	}
+	if r := report.Results[1]; r.Stage != firmwareStageRollout || !strings.Contains(r.Message, "canary phone failed") {
+		t.Errorf("unexpected skipped result: %+v", r)
+	}
+}
+
+func TestFirmwareScreen(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PROVISIONING_FIRMWARE_DIR", filepath.Join(dir, "firmware"))
+	t.Setenv("RAYANPBX_FIRMWARE_POLICY", filepath.Join(dir, "firmware-policy.json"))
+	src := filepath.Join(dir, "t46s.rom")
+	os.WriteFile(src, []byte("rom"), 0600)
+
+	m := initialModel(nil, nil, false)
+	m.voipPhones = []PhoneInfo{{IP: "192.0.2.10", Extension: "101", UserAgent: "Yealink SIP-T46S 66.86.0.15"}}
+	m.currentScreen = voipPhonesScreen
+	m.handleVoIPPhonesKeyPress("F")
+	if m.currentScreen != firmwareScreen || len(m.firmwareCompliance) != 1 {
+		t.Fatalf("expected the firmware screen with one phone, got screen %d", m.currentScreen)
+	}
+	if !strings.Contains(m.renderFirmware(), "start it before upgrading") {
+		t.Error("expected a hint to start the provisioning server")
+	}
+
+	m.handleFirmwareScreen(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'i'}})
+	if !m.inputMode || m.firmwareForm != firmwareFormImport || m.inputValues[0] != "yealink" {
+		t.Fatalf("expected the import form filled with the phone vendor, got %v", m.inputValues)
+	}
+	m.inputValues = []string{"yealink", "T46S", "66.86.0.20", src}
+	m.handleFirmwareInput()
+	if m.inputMode || len(m.firmwareImages) != 1 {
+		t.Fatalf("expected the image to be added, got %q", m.errorMsg)
+	}
+
+	m.handleFirmwareScreen(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'v'}})
+	m.handleFirmwareScreen(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'t'}})
+	if !m.inputMode || m.inputValues[2] != "66.86.0.20" {
+		t.Fatalf("expected the target form filled from the selected image, got %v", m.inputValues)
+	}
+	m.handleFirmwareInput()
+	if m.inputMode || len(m.firmwarePolicy) != 1 || !strings.Contains(m.renderFirmware(), "★") {
+		t.Fatalf("expected the target to be saved, got %q", m.errorMsg)
+	}
+
+	// The phone has no model outside the inventory, so there is nothing to upgrade
+	m.handleFirmwareScreen(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'u'}})
+	if m.inputMode || !strings.Contains(m.errorMsg, "No outdated phones") {
+		t.Errorf("expected no upgrade targets, got %q", m.errorMsg)
+	}
+	m.handleFirmwareScreen(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.currentScreen != voipPhonesScreen {
+		t.Errorf("expected ESC to return to the VoIP phones screen")
+	}
+}
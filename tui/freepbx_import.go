@@ -0,0 +1,1311 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// Managed dialplan contexts written by the FreePBX importer
+const (
+	freePBXImportInternalContext = "freepbx-imported-internal"
+	freePBXImportInboundContext  = "freepbx-imported-inbound"
+	freePBXImportRulePrefix      = "Imported from FreePBX"
+)
+
+// freePBXConfigFiles lists the generated FreePBX/Issabel files the importer understands
+var freePBXConfigFiles = []string{
+	"pjsip.endpoint.conf",
+	"pjsip.aor.conf",
+	"pjsip.auth.conf",
+	"pjsip.identify.conf",
+	"pjsip.registration.conf",
+	"extensions_additional.conf",
+	"voicemail.conf",
+	"sip_additional.conf",
+}
+
+// freePBXTrunkContexts are endpoint contexts FreePBX uses for trunks
+var freePBXTrunkContexts = map[string]bool{
+	"from-pstn":          true,
+	"from-pstn-toheader": true,
+	"from-pstn-e164-us":  true,
+	"from-trunk":         true,
+}
+
+// freePBXIgnoredEndpointOptions are endpoint settings RayanPBX does not manage yet.
+// They are reported as warnings so nothing is silently dropped.
+var freePBXIgnoredEndpointOptions = []string{
+	"media_encryption",
+	"webrtc",
+	"dtls_cert_file",
+	"ice_support",
+	"outbound_proxy",
+	"named_call_group",
+	"named_pickup_group",
+	"call_group",
+	"pickup_group",
+}
+
+// freePBXUnsupportedFeatures maps FreePBX dialplan contexts to features RayanPBX cannot import.
+// Keys ending in "-" are context prefixes, the rest are exact context names.
+var freePBXUnsupportedFeatures = map[string]string{
+	"ivr-":              "IVR",
+	"app-announcement-": "Announcement",
+	"ext-queues":        "Queue",
+	"timeconditions":    "Time condition",
+	"ext-meetme":        "Conference",
+	"app-daynight":      "Call flow control",
+	"ext-paging":        "Paging group",
+	"ext-findmefollow":  "Follow me",
+}
+
+// FreePBXSource holds the raw configuration files collected from a FreePBX/Issabel
+// system, keyed by base file name
+type FreePBXSource struct {
+	Path  string
+	Files map[string]string
+}
+
+// FreePBXTrunk is a PJSIP trunk mapped from FreePBX
+type FreePBXTrunk struct {
+	Name      string
+	Host      string
+	Port      int
+	Username  string
+	Secret    string
+	Transport string
+	Context   string
+	Codecs    []string
+	Register  bool
+	Priority  int
+}
+
+// FreePBXRingGroup is a ring group mapped from the [ext-group] context
+type FreePBXRingGroup struct {
+	Number   string
+	Strategy string
+	RingTime int
+	Members  []string
+	Failover string // Goto target (context,exten,priority) or empty
+}
+
+// FreePBXInboundRoute is a DID route mapped from the [ext-did-*] contexts
+type FreePBXInboundRoute struct {
+	DID         string
+	Destination string // Goto target (context,exten,priority)
+}
+
+// FreePBXOutboundRoute is a dial pattern of an outbound route mapped from [outrt-*]
+type FreePBXOutboundRoute struct {
+	Name    string
+	Pattern string
+	Trunks  []string // Trunks in failover order
+	Strip   int
+	Prepend string
+}
+
+// FreePBXUnmappedItem describes something in the source that cannot be imported
+type FreePBXUnmappedItem struct {
+	Kind   string
+	Name   string
+	Reason string
+}
+
+// FreePBXDialplanRule is a dialplan_rules row generated from imported routes and ring groups
+type FreePBXDialplanRule struct {
+	Name        string
+	Context     string
+	Pattern     string
+	App         string
+	AppData     string
+	RuleType    string
+	Description string
+}
+
+// FreePBXImportPlan is the result of mapping a FreePBX source onto RayanPBX records.
+// It is a dry run: nothing is written until it is passed to FreePBXImporter.Apply.
+type FreePBXImportPlan struct {
+	Source         string
+	Extensions     []Extension
+	Trunks         []FreePBXTrunk
+	RingGroups     []FreePBXRingGroup
+	InboundRoutes  []FreePBXInboundRoute
+	OutboundRoutes []FreePBXOutboundRoute
+	Warnings       []string
+	Unmapped       []FreePBXUnmappedItem
+}
+
+// FreePBXImportResult summarizes an applied import
+type FreePBXImportResult struct {
+	Extensions int
+	Trunks     int
+	Rules      int
+	Errors     []error
+}
+
+// FreePBXImporter applies an import plan to the database and Asterisk configuration
+type FreePBXImporter struct {
+	db              *sql.DB
+	configManager   *AsteriskConfigManager
+	syncManager     *ExtensionSyncManager
+	asteriskManager *AsteriskManager
+}
+
+// NewFreePBXImporter creates a new FreePBX importer
+func NewFreePBXImporter(db *sql.DB, configManager *AsteriskConfigManager, syncManager *ExtensionSyncManager, asteriskManager *AsteriskManager) *FreePBXImporter {
+	return &FreePBXImporter{
+		db:              db,
+		configManager:   configManager,
+		syncManager:     syncManager,
+		asteriskManager: asteriskManager,
+	}
+}
+
+// isFreePBXConfigFile checks whether a file name is one the importer reads
+func isFreePBXConfigFile(name string) bool {
+	for _, f := range freePBXConfigFiles {
+		if name == f {
+			return true
+		}
+	}
+	return false
+}
+
+// isArchivePath checks whether a path looks like a tar or gzipped tar archive
+func isArchivePath(p string) bool {
+	return strings.HasSuffix(p, ".tar") || strings.HasSuffix(p, ".tar.gz") || strings.HasSuffix(p, ".tgz")
+}
+
+// LoadFreePBXSource reads FreePBX configuration from a directory, a single config file or
+// a backup archive (.tar, .tar.gz, .tgz, including archives nested inside the backup).
+// Files are matched by base name anywhere in the tree.
+func LoadFreePBXSource(srcPath string) (*FreePBXSource, error) {
+	info, err := os.Stat(srcPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open import source: %w", err)
+	}
+
+	src := &FreePBXSource{Path: srcPath, Files: make(map[string]string)}
+
+	switch {
+	case info.IsDir():
+		err = filepath.Walk(srcPath, func(p string, fi os.FileInfo, walkErr error) error {
+			if walkErr != nil {
+				return walkErr
+			}
+			if fi.IsDir() || !isFreePBXConfigFile(fi.Name()) {
+				return nil
+			}
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			src.addFile(fi.Name(), string(data))
+			return nil
+		})
+	case isArchivePath(srcPath):
+		var f *os.File
+		f, err = os.Open(srcPath)
+		if err == nil {
+			defer f.Close()
+			err = src.loadArchive(f, srcPath)
+		}
+	default:
+		var data []byte
+		data, err = os.ReadFile(srcPath)
+		if err == nil {
+			src.addFile(filepath.Base(srcPath), string(data))
+		}
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read import source: %w", err)
+	}
+
+	if len(src.Files) == 0 {
+		return nil, fmt.Errorf("no FreePBX configuration files found in %s", srcPath)
+	}
+
+	return src, nil
+}
+
+// loadArchive extracts known config files from a (possibly gzipped) tar stream
+func (src *FreePBXSource) loadArchive(r io.Reader, name string) error {
+	if strings.HasSuffix(name, ".gz") || strings.HasSuffix(name, ".tgz") {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("failed to decompress %s: %w", name, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive %s: %w", name, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		base := path.Base(hdr.Name)
+		if isArchivePath(base) {
+			// FreePBX backups nest the /etc/asterisk tarball inside the backup archive
+			if err := src.loadArchive(tr, base); err != nil {
+				return err
+			}
+			continue
+		}
+		if !isFreePBXConfigFile(base) {
+			continue
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return fmt.Errorf("failed to read %s from archive: %w", hdr.Name, err)
+		}
+		src.addFile(base, string(data))
+	}
+}
+
+// addFile stores a config file, keeping the first copy if the source contains duplicates
+func (src *FreePBXSource) addFile(name, content string) {
+	if _, exists := src.Files[name]; !exists {
+		src.Files[name] = content
+	}
+}
+
+// pjsipSections parses and merges all generated pjsip.*.conf files
+func (src *FreePBXSource) pjsipSections() (*AsteriskConfig, error) {
+	merged := &AsteriskConfig{}
+	for _, name := range []string{"pjsip.endpoint.conf", "pjsip.aor.conf", "pjsip.auth.conf", "pjsip.identify.conf", "pjsip.registration.conf"} {
+		content, ok := src.Files[name]
+		if !ok {
+			continue
+		}
+		config, err := ParseAsteriskConfigContent(content, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+		for _, section := range config.Sections {
+			if !section.Commented {
+				merged.AddSection(section)
+			}
+		}
+	}
+	return merged, nil
+}
+
+// BuildFreePBXImportPlan maps a FreePBX source onto RayanPBX extensions, trunks, ring groups
+// and routes without changing anything. Items that cannot be mapped are listed in Unmapped.
+func BuildFreePBXImportPlan(src *FreePBXSource) (*FreePBXImportPlan, error) {
+	plan := &FreePBXImportPlan{Source: src.Path}
+
+	if _, ok := src.Files["sip_additional.conf"]; ok {
+		plan.Unmapped = append(plan.Unmapped, FreePBXUnmappedItem{
+			Kind:   "chan_sip",
+			Name:   "sip_additional.conf",
+			Reason: "chan_sip peers are not imported; convert them to PJSIP in FreePBX first",
+		})
+	}
+
+	pjsip, err := src.pjsipSections()
+	if err != nil {
+		return nil, err
+	}
+
+	emails := parseFreePBXVoicemailEmails(src.Files["voicemail.conf"])
+
+	for _, endpoint := range pjsip.Sections {
+		if endpoint.Type != "endpoint" {
+			continue
+		}
+		if isFreePBXTrunkEndpoint(endpoint) {
+			plan.mapTrunk(pjsip, endpoint)
+		} else {
+			plan.mapExtension(pjsip, endpoint, emails)
+		}
+	}
+
+	if content, ok := src.Files["extensions_additional.conf"]; ok {
+		if err := plan.mapDialplan(content); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(plan.Extensions, func(i, j int) bool {
+		return plan.Extensions[i].ExtensionNumber < plan.Extensions[j].ExtensionNumber
+	})
+
+	return plan, nil
+}
+
+// isFreePBXTrunkEndpoint decides whether a FreePBX endpoint is a trunk rather than an extension
+func isFreePBXTrunkEndpoint(endpoint *AsteriskSection) bool {
+	if context, ok := endpoint.GetProperty("context"); ok && freePBXTrunkContexts[context] {
+		return true
+	}
+	if _, ok := endpoint.GetProperty("outbound_auth"); ok {
+		return true
+	}
+	_, isExt := extractExtensionNumber(endpoint.Name)
+	return !isExt
+}
+
+// firstListValue returns the first entry of a comma separated property value
+func firstListValue(value string) string {
+	return strings.TrimSpace(strings.Split(value, ",")[0])
+}
+
+// mapFreePBXTransport converts FreePBX transport names (e.g. 0.0.0.0-udp) to RayanPBX names
+func mapFreePBXTransport(transport string) (string, bool) {
+	t := strings.ToLower(transport)
+	switch {
+	case t == "":
+		return DefaultExtensionTransport, true
+	case strings.HasSuffix(t, "-udp") || t == "udp" || t == "transport-udp":
+		return "transport-udp", true
+	case strings.HasSuffix(t, "-tcp") || t == "tcp" || t == "transport-tcp":
+		return "transport-tcp", true
+	case strings.HasSuffix(t, "-tls") || t == "tls":
+		return "transport-tls", false
+	case strings.HasSuffix(t, "-wss") || strings.HasSuffix(t, "-ws") || t == "wss" || t == "ws":
+		return "transport-wss", false
+	}
+	return DefaultExtensionTransport, false
+}
+
+// parseFreePBXCodecs splits an allow= value ("ulaw,alaw" or "ulaw&alaw") into codecs
+func parseFreePBXCodecs(allow string) []string {
+	var codecs []string
+	for _, codec := range strings.FieldsFunc(allow, func(r rune) bool { return r == ',' || r == '&' }) {
+		codec = strings.TrimSpace(codec)
+		if codec == "" || codec == "all" || strings.HasPrefix(codec, "!") {
+			continue
+		}
+		codecs = append(codecs, codec)
+	}
+	return codecs
+}
+
+// callerIDNameRegex extracts the name part of "Name" <number>
+var callerIDNameRegex = regexp.MustCompile(`^\s*"?([^"<]*?)"?\s*<[^>]*>`)
+
+// parseFreePBXVoicemailEmails reads mailbox email addresses from voicemail.conf
+func parseFreePBXVoicemailEmails(content string) map[string]string {
+	emails := make(map[string]string)
+	if content == "" {
+		return emails
+	}
+	config, err := ParseAsteriskConfigContent(content, "voicemail.conf")
+	if err != nil {
+		return emails
+	}
+	for _, section := range config.Sections {
+		if section.Name == "general" || section.Name == "zonemessages" {
+			continue
+		}
+		// Mailbox lines look like: 101 => 1234,John Smith,john@example.com,,attach=yes
+		for _, entry := range section.Entries {
+			fields := strings.Split(entry.Value, ",")
+			if len(fields) >= 3 && strings.Contains(fields[2], "@") {
+				emails[entry.Key] = strings.TrimSpace(fields[2])
+			}
+		}
+	}
+	return emails
+}
+
+// mapExtension maps a FreePBX user endpoint with its auth and aor onto an Extension
+func (plan *FreePBXImportPlan) mapExtension(pjsip *AsteriskConfig, endpoint *AsteriskSection, emails map[string]string) {
+	number := endpoint.Name
+	if !isValidExtension(number) {
+		plan.addUnmapped("Extension", number, "extension number contains unsupported characters")
+		return
+	}
+
+	authName, _ := endpoint.GetProperty("auth")
+	auth := pjsip.FindSectionByNameAndType(firstListValue(authName), "auth")
+	if auth == nil {
+		plan.addUnmapped("Extension", number, "no auth section found")
+		return
+	}
+	secret, _ := auth.GetProperty("password")
+	if secret == "" {
+		plan.addUnmapped("Extension", number, "auth section has no password")
+		return
+	}
+	if !isValidPassword(secret) {
+		plan.addUnmapped("Extension", number, "password contains characters RayanPBX does not accept")
+		return
+	}
+
+	ext := Extension{
+		ExtensionNumber:  number,
+		Name:             fmt.Sprintf("Extension %s", number),
+		Secret:           secret,
+		Email:            emails[number],
+		Enabled:          true,
+		Context:          DefaultExtensionContext,
+		MaxContacts:      DefaultMaxContacts,
+		QualifyFrequency: DefaultQualifyFrequency,
+		Codecs:           DefaultCodecs,
+		DirectMedia:      DefaultDirectMedia,
+		MediaEncryption:  "no",
+	}
+
+	if context, ok := endpoint.GetProperty("context"); ok && context != "" {
+		ext.Context = context
+	}
+
+	transport, _ := endpoint.GetProperty("transport")
+	mapped, ok := mapFreePBXTransport(transport)
+	ext.Transport = mapped
+	if !ok {
+		plan.addWarning("%s: transport %q mapped to %s, make sure it is configured", number, transport, mapped)
+	}
+
+	if allow, ok := endpoint.GetProperty("allow"); ok {
+		if codecs := parseFreePBXCodecs(allow); len(codecs) > 0 {
+			ext.Codecs = strings.Join(codecs, ",")
+		}
+	}
+
+	if directMedia, ok := endpoint.GetProperty("direct_media"); ok && directMedia != "" {
+		ext.DirectMedia = directMedia
+	}
+
+	if callerID, ok := endpoint.GetProperty("callerid"); ok && callerID != "" {
+		ext.CallerID = callerID
+		if m := callerIDNameRegex.FindStringSubmatch(callerID); m != nil && strings.TrimSpace(m[1]) != "" {
+			ext.Name = strings.TrimSpace(m[1])
+		}
+	}
+
+	if mailboxes, ok := endpoint.GetProperty("mailboxes"); ok && mailboxes != "" {
+		ext.VoicemailEnabled = true
+	}
+
+	aorName, _ := endpoint.GetProperty("aors")
+	if aor := pjsip.FindSectionByNameAndType(firstListValue(aorName), "aor"); aor != nil {
+		if v, ok := aor.GetProperty("max_contacts"); ok {
+			if n, err := strconv.Atoi(v); err == nil {
+				ext.MaxContacts = n
+			}
+		}
+		if v, ok := aor.GetProperty("qualify_frequency"); ok {
+			if n, err := strconv.Atoi(v); err == nil {
+				ext.QualifyFrequency = n
+			}
+		}
+	}
+
+	for _, key := range freePBXIgnoredEndpointOptions {
+		if v, ok := endpoint.GetProperty(key); ok && v != "" && v != "no" {
+			plan.addWarning("%s: setting %s=%s is not imported", number, key, v)
+		}
+	}
+
+	plan.Extensions = append(plan.Extensions, ext)
+}
+
+// parseSIPURIHost extracts host and port from sip:user@host:port style URIs
+func parseSIPURIHost(uri string) (string, int) {
+	uri = strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(uri), "sips:"), "sip:")
+	if idx := strings.Index(uri, "@"); idx >= 0 {
+		uri = uri[idx+1:]
+	}
+	if idx := strings.IndexAny(uri, ";>"); idx >= 0 {
+		uri = uri[:idx]
+	}
+	host, port := uri, 5060
+	if idx := strings.LastIndex(uri, ":"); idx >= 0 {
+		if p, err := strconv.Atoi(uri[idx+1:]); err == nil {
+			host, port = uri[:idx], p
+		}
+	}
+	return host, port
+}
+
+// mapTrunk maps a FreePBX trunk endpoint with its auth, aor and registration onto a trunk
+func (plan *FreePBXImportPlan) mapTrunk(pjsip *AsteriskConfig, endpoint *AsteriskSection) {
+	trunk := FreePBXTrunk{
+		Name:     endpoint.Name,
+		Port:     5060,
+		Context:  "from-trunk",
+		Codecs:   []string{"ulaw", "alaw"},
+		Priority: len(plan.Trunks) + 1,
+	}
+
+	transport, _ := endpoint.GetProperty("transport")
+	mapped, ok := mapFreePBXTransport(transport)
+	trunk.Transport = mapped
+	if !ok {
+		plan.addWarning("trunk %s: transport %q mapped to %s, make sure it is configured", trunk.Name, transport, mapped)
+	}
+
+	if allow, ok := endpoint.GetProperty("allow"); ok {
+		if codecs := parseFreePBXCodecs(allow); len(codecs) > 0 {
+			trunk.Codecs = codecs
+		}
+	}
+
+	aorName, _ := endpoint.GetProperty("aors")
+	if aor := pjsip.FindSectionByNameAndType(firstListValue(aorName), "aor"); aor != nil {
+		if contact, ok := aor.GetProperty("contact"); ok && contact != "" {
+			trunk.Host, trunk.Port = parseSIPURIHost(contact)
+		}
+	}
+
+	if reg := pjsip.FindSectionByNameAndType(trunk.Name, "registration"); reg != nil {
+		trunk.Register = true
+		if trunk.Host == "" {
+			if serverURI, ok := reg.GetProperty("server_uri"); ok {
+				trunk.Host, trunk.Port = parseSIPURIHost(serverURI)
+			}
+		}
+	}
+
+	if trunk.Host == "" {
+		if identify := pjsip.FindSectionByNameAndType(trunk.Name, "identify"); identify != nil {
+			if match, ok := identify.GetProperty("match"); ok {
+				trunk.Host = strings.Split(firstListValue(match), "/")[0]
+			}
+		}
+	}
+
+	if trunk.Host == "" {
+		plan.addUnmapped("Trunk", trunk.Name, "no contact, registration or identify host found")
+		return
+	}
+
+	authName, ok := endpoint.GetProperty("outbound_auth")
+	if !ok {
+		authName = trunk.Name
+	}
+	if auth := pjsip.FindSectionByNameAndType(firstListValue(authName), "auth"); auth != nil {
+		trunk.Username, _ = auth.GetProperty("username")
+		trunk.Secret, _ = auth.GetProperty("password")
+	}
+
+	plan.Trunks = append(plan.Trunks, trunk)
+}
+
+// dialplanApp splits a dialplan entry value "exten,priority,App(args)" into exten and app
+func dialplanApp(value string) (string, string) {
+	parts := strings.SplitN(value, ",", 3)
+	if len(parts) < 3 {
+		return strings.TrimSpace(parts[0]), ""
+	}
+	return strings.TrimSpace(parts[0]), strings.TrimSpace(parts[2])
+}
+
+var (
+	freePBXGotoRegex         = regexp.MustCompile(`Goto\(([^,()]+),([^,()]+),([^,()]+)\)`)
+	freePBXRingMethodRegex   = regexp.MustCompile(`RingGroupMethod=([\w-]+)`)
+	freePBXRingDialRegex     = regexp.MustCompile(`(?:Macro\(dial,|macro-dial,s,1\()(\d+),[^,]*,([^)]+)\)`)
+	freePBXDialoutTrunkRegex = regexp.MustCompile(`dialout-trunk(?:,s,1\(|,)(\d+),([^,]*),`)
+	freePBXDialNumberRegex   = regexp.MustCompile(`^(\d*)\$\{EXTEN(?::(\d+))?\}$`)
+	freePBXRouteNameRegex    = regexp.MustCompile(`(?m)^\[(outrt-[^\]]+)\]\s*;\s*(.+?)\s*$`)
+)
+
+// mapFreePBXDestination converts a FreePBX Goto target into a RayanPBX Goto target
+func mapFreePBXDestination(context, exten string) (string, string) {
+	switch context {
+	case "from-did-direct", "ext-local", "from-internal", "ext-group":
+		if strings.HasPrefix(exten, "vm") {
+			return "", "voicemail destinations are not supported"
+		}
+		if !isValidExtension(exten) {
+			return "", fmt.Sprintf("destination %s is not an extension", exten)
+		}
+		return fmt.Sprintf("from-internal,%s,1", exten), ""
+	}
+
+	for prefix, feature := range freePBXUnsupportedFeatures {
+		if context == prefix || (strings.HasSuffix(prefix, "-") && strings.HasPrefix(context, prefix)) {
+			return "", fmt.Sprintf("destination is a %s (%s), which is not supported", feature, context)
+		}
+	}
+	return "", fmt.Sprintf("destination context %s is not supported", context)
+}
+
+// groupEntriesByExten groups exten => lines of a context by their extension, in order
+func groupEntriesByExten(section *AsteriskSection) ([]string, map[string][]string) {
+	var order []string
+	apps := make(map[string][]string)
+	for _, value := range section.GetEntries("exten") {
+		exten, app := dialplanApp(value)
+		if exten == "" || exten == "h" || exten == "i" || exten == "t" {
+			continue
+		}
+		if _, seen := apps[exten]; !seen {
+			order = append(order, exten)
+		}
+		apps[exten] = append(apps[exten], app)
+	}
+	return order, apps
+}
+
+// mapDialplan maps ring groups, inbound and outbound routes from extensions_additional.conf
+func (plan *FreePBXImportPlan) mapDialplan(content string) error {
+	config, err := ParseAsteriskConfigContent(content, "extensions_additional.conf")
+	if err != nil {
+		return fmt.Errorf("failed to parse extensions_additional.conf: %w", err)
+	}
+
+	// Outbound route names live in the comment after the context header
+	routeNames := make(map[string]string)
+	for _, m := range freePBXRouteNameRegex.FindAllStringSubmatch(content, -1) {
+		routeNames[m[1]] = m[2]
+	}
+
+	// Trunk dial strings are defined as OUT_<id> globals
+	trunkByID := make(map[string]string)
+	if globals := config.FindSectionsByName("globals"); len(globals) > 0 {
+		for key, value := range globals[0].Properties {
+			if strings.HasPrefix(key, "OUT_") {
+				trunkByID[strings.TrimPrefix(key, "OUT_")] = value
+			}
+		}
+	}
+
+	for _, section := range config.Sections {
+		if section.Commented {
+			continue
+		}
+		name := section.Name
+		switch {
+		case name == "ext-group":
+			plan.mapRingGroups(section)
+		case strings.HasPrefix(name, "ext-did"):
+			plan.mapInboundRoutes(section)
+		case strings.HasPrefix(name, "outrt-") && !strings.HasSuffix(name, "-custom"):
+			routeName := routeNames[name]
+			if routeName == "" {
+				routeName = name
+			}
+			plan.mapOutboundRoute(section, routeName, trunkByID)
+		default:
+			plan.mapUnsupportedContext(section)
+		}
+	}
+
+	return nil
+}
+
+// mapRingGroups maps the [ext-group] context
+func (plan *FreePBXImportPlan) mapRingGroups(section *AsteriskSection) {
+	order, apps := groupEntriesByExten(section)
+	for _, number := range order {
+		if number == "s" {
+			continue
+		}
+		group := FreePBXRingGroup{Number: number, Strategy: "ringall", RingTime: 20}
+		found := false
+		for _, app := range apps[number] {
+			if m := freePBXRingMethodRegex.FindStringSubmatch(app); m != nil {
+				group.Strategy = m[1]
+			}
+			if m := freePBXRingDialRegex.FindStringSubmatch(app); m != nil {
+				found = true
+				group.RingTime, _ = strconv.Atoi(m[1])
+				for _, member := range strings.Split(m[2], "-") {
+					member = strings.TrimSpace(member)
+					if strings.HasSuffix(member, "#") {
+						plan.addWarning("ring group %s: external member %s dropped", number, strings.TrimSuffix(member, "#"))
+						continue
+					}
+					if member != "" {
+						group.Members = append(group.Members, member)
+					}
+				}
+			}
+			if m := freePBXGotoRegex.FindStringSubmatch(app); m != nil && found {
+				target, reason := mapFreePBXDestination(m[1], m[2])
+				if reason != "" {
+					plan.addWarning("ring group %s: failover not imported, %s", number, reason)
+				}
+				group.Failover = target
+			}
+		}
+
+		if !found || len(group.Members) == 0 {
+			plan.addUnmapped("Ring group", number, "no dialable members found")
+			continue
+		}
+		if group.Strategy != "ringall" {
+			plan.addWarning("ring group %s: strategy %s imported as ringall", number, group.Strategy)
+		}
+		plan.RingGroups = append(plan.RingGroups, group)
+	}
+}
+
+// mapInboundRoutes maps an [ext-did-*] context
+func (plan *FreePBXImportPlan) mapInboundRoutes(section *AsteriskSection) {
+	order, apps := groupEntriesByExten(section)
+	for _, did := range order {
+		var target, reason string
+		for _, app := range apps[did] {
+			if m := freePBXGotoRegex.FindStringSubmatch(app); m != nil {
+				target, reason = mapFreePBXDestination(m[1], m[2])
+			}
+		}
+		if target == "" {
+			if reason == "" {
+				reason = "no destination found"
+			}
+			plan.addUnmapped("Inbound route", did, reason)
+			continue
+		}
+		plan.InboundRoutes = append(plan.InboundRoutes, FreePBXInboundRoute{DID: did, Destination: target})
+	}
+}
+
+// mapOutboundRoute maps an [outrt-*] context, one route per dial pattern
+func (plan *FreePBXImportPlan) mapOutboundRoute(section *AsteriskSection, routeName string, trunkByID map[string]string) {
+	order, apps := groupEntriesByExten(section)
+	for _, pattern := range order {
+		route := FreePBXOutboundRoute{Name: routeName, Pattern: pattern}
+		var reason string
+		for _, app := range apps[pattern] {
+			m := freePBXDialoutTrunkRegex.FindStringSubmatch(app)
+			if m == nil {
+				continue
+			}
+			dialString, ok := trunkByID[m[1]]
+			if !ok {
+				reason = fmt.Sprintf("trunk %s is not defined", m[1])
+				continue
+			}
+			if !strings.HasPrefix(dialString, "PJSIP/") {
+				reason = fmt.Sprintf("trunk %s is not a PJSIP trunk", dialString)
+				continue
+			}
+			route.Trunks = append(route.Trunks, strings.TrimPrefix(dialString, "PJSIP/"))
+
+			if n := freePBXDialNumberRegex.FindStringSubmatch(m[2]); n != nil {
+				route.Prepend = n[1]
+				route.Strip, _ = strconv.Atoi(n[2])
+			} else {
+				plan.addWarning("outbound route %s (%s): number %s imported without manipulation", routeName, pattern, m[2])
+			}
+		}
+
+		if len(route.Trunks) == 0 {
+			if reason == "" {
+				reason = "no trunk found"
+			}
+			plan.addUnmapped("Outbound route", fmt.Sprintf("%s (%s)", routeName, pattern), reason)
+			continue
+		}
+		plan.OutboundRoutes = append(plan.OutboundRoutes, route)
+	}
+}
+
+// mapUnsupportedContext reports FreePBX features RayanPBX has no equivalent for
+func (plan *FreePBXImportPlan) mapUnsupportedContext(section *AsteriskSection) {
+	for prefix, feature := range freePBXUnsupportedFeatures {
+		if strings.HasSuffix(prefix, "-") {
+			if strings.HasPrefix(section.Name, prefix) && !strings.HasSuffix(section.Name, "-custom") {
+				plan.addUnmapped(feature, section.Name, "not supported by RayanPBX")
+			}
+			continue
+		}
+		if section.Name != prefix {
+			continue
+		}
+		order, _ := groupEntriesByExten(section)
+		for _, exten := range order {
+			if exten != "s" {
+				plan.addUnmapped(feature, exten, "not supported by RayanPBX")
+			}
+		}
+	}
+}
+
+// addWarning records a non-fatal mapping note
+func (plan *FreePBXImportPlan) addWarning(format string, args ...interface{}) {
+	plan.Warnings = append(plan.Warnings, fmt.Sprintf(format, args...))
+}
+
+// addUnmapped records an item that cannot be imported
+func (plan *FreePBXImportPlan) addUnmapped(kind, name, reason string) {
+	plan.Unmapped = append(plan.Unmapped, FreePBXUnmappedItem{Kind: kind, Name: name, Reason: reason})
+}
+
+// TrunkSections generates the PJSIP sections for an imported trunk
+func (t FreePBXTrunk) TrunkSections() []*AsteriskSection {
+	sections := make([]*AsteriskSection, 0, 5)
+
+	endpoint := NewAsteriskSection(t.Name, "endpoint")
+	endpoint.Comments = []string{fmt.Sprintf("; %s: trunk %s", freePBXImportRulePrefix, t.Name)}
+	endpoint.SetProperty("type", "endpoint")
+	endpoint.SetProperty("transport", t.Transport)
+	endpoint.SetProperty("context", t.Context)
+	endpoint.SetProperty("disallow", "all")
+	endpoint.SetProperty("allow", strings.Join(t.Codecs, ","))
+	endpoint.SetProperty("aors", t.Name)
+	if t.Secret != "" {
+		endpoint.SetProperty("outbound_auth", t.Name)
+	}
+	sections = append(sections, endpoint)
+
+	if t.Secret != "" {
+		auth := NewAsteriskSection(t.Name, "auth")
+		auth.SetProperty("type", "auth")
+		auth.SetProperty("auth_type", "userpass")
+		auth.SetProperty("username", t.Username)
+		auth.SetProperty("password", t.Secret)
+		sections = append(sections, auth)
+	}
+
+	aor := NewAsteriskSection(t.Name, "aor")
+	aor.SetProperty("type", "aor")
+	aor.SetProperty("contact", fmt.Sprintf("sip:%s:%d", t.Host, t.Port))
+	aor.SetProperty("qualify_frequency", "60")
+	sections = append(sections, aor)
+
+	identify := NewAsteriskSection(t.Name, "identify")
+	identify.SetProperty("type", "identify")
+	identify.SetProperty("endpoint", t.Name)
+	identify.SetProperty("match", t.Host)
+	sections = append(sections, identify)
+
+	if t.Register && t.Secret != "" {
+		reg := NewAsteriskSection(t.Name, "registration")
+		reg.SetProperty("type", "registration")
+		reg.SetProperty("transport", t.Transport)
+		reg.SetProperty("outbound_auth", t.Name)
+		reg.SetProperty("server_uri", fmt.Sprintf("sip:%s:%d", t.Host, t.Port))
+		reg.SetProperty("client_uri", fmt.Sprintf("sip:%s@%s:%d", t.Username, t.Host, t.Port))
+		reg.SetProperty("retry_interval", "60")
+		sections = append(sections, reg)
+	}
+
+	return sections
+}
+
+// DialplanRules converts ring groups and routes into dialplan_rules rows
+func (plan *FreePBXImportPlan) DialplanRules() []FreePBXDialplanRule {
+	var rules []FreePBXDialplanRule
+
+	for _, group := range plan.RingGroups {
+		var targets []string
+		for _, member := range group.Members {
+			targets = append(targets, "PJSIP/"+member)
+		}
+		rules = append(rules, FreePBXDialplanRule{
+			Name:        fmt.Sprintf("Ring group %s", group.Number),
+			Context:     freePBXImportInternalContext,
+			Pattern:     group.Number,
+			App:         "Dial",
+			AppData:     fmt.Sprintf("%s,%d", strings.Join(targets, "&"), group.RingTime),
+			RuleType:    "internal",
+			Description: fmt.Sprintf("%s: ring group %s", freePBXImportRulePrefix, group.Number),
+		})
+	}
+
+	for _, route := range plan.OutboundRoutes {
+		rules = append(rules, FreePBXDialplanRule{
+			Name:        route.Name,
+			Context:     freePBXImportInternalContext,
+			Pattern:     route.Pattern,
+			App:         "Dial",
+			AppData:     route.dialString(route.Trunks[0]),
+			RuleType:    "outbound",
+			Description: fmt.Sprintf("%s: outbound route %s via %s", freePBXImportRulePrefix, route.Name, strings.Join(route.Trunks, ", ")),
+		})
+	}
+
+	for _, route := range plan.InboundRoutes {
+		rules = append(rules, FreePBXDialplanRule{
+			Name:        fmt.Sprintf("Inbound %s", route.DID),
+			Context:     freePBXImportInboundContext,
+			Pattern:     route.DID,
+			App:         "Goto",
+			AppData:     route.Destination,
+			RuleType:    "inbound",
+			Description: fmt.Sprintf("%s: inbound route %s", freePBXImportRulePrefix, route.DID),
+		})
+	}
+
+	return rules
+}
+
+// dialString builds the Dial() argument for an outbound route through a trunk
+func (route FreePBXOutboundRoute) dialString(trunk string) string {
+	number := "${EXTEN}"
+	if route.Strip > 0 {
+		number = fmt.Sprintf("${EXTEN:%d}", route.Strip)
+	}
+	return fmt.Sprintf("PJSIP/%s%s@%s,60", route.Prepend, number, trunk)
+}
+
+// DialplanSections generates the managed dialplan contexts for ring groups and routes
+func (plan *FreePBXImportPlan) DialplanSections() []*AsteriskSection {
+	internal := NewAsteriskSection(freePBXImportInternalContext, "")
+	internal.Comments = []string{fmt.Sprintf("; %s: ring groups and outbound routes (managed by RayanPBX)", freePBXImportRulePrefix)}
+
+	for _, group := range plan.RingGroups {
+		var targets []string
+		for _, member := range group.Members {
+			targets = append(targets, "PJSIP/"+member)
+		}
+		internal.AddEntry("exten", fmt.Sprintf("%s,1,NoOp(Ring group %s)", group.Number, group.Number))
+		internal.AddEntry("same", fmt.Sprintf("n,Dial(%s,%d)", strings.Join(targets, "&"), group.RingTime))
+		if group.Failover != "" {
+			internal.AddEntry("same", fmt.Sprintf("n,Goto(%s)", group.Failover))
+		}
+		internal.AddEntry("same", "n,Hangup()")
+	}
+
+	for _, route := range plan.OutboundRoutes {
+		internal.AddEntry("exten", fmt.Sprintf("%s,1,NoOp(Outbound route %s)", route.Pattern, route.Name))
+		// Trunks are tried in order until one answers
+		for _, trunk := range route.Trunks {
+			internal.AddEntry("same", fmt.Sprintf("n,Dial(%s)", route.dialString(trunk)))
+		}
+		internal.AddEntry("same", "n,Hangup()")
+	}
+
+	inbound := NewAsteriskSection(freePBXImportInboundContext, "")
+	inbound.Comments = []string{fmt.Sprintf("; %s: inbound routes (managed by RayanPBX)", freePBXImportRulePrefix)}
+	for _, route := range plan.InboundRoutes {
+		inbound.AddEntry("exten", fmt.Sprintf("%s,1,NoOp(Inbound route %s)", route.DID, route.DID))
+		inbound.AddEntry("same", fmt.Sprintf("n,Goto(%s)", route.Destination))
+	}
+
+	return []*AsteriskSection{internal, inbound}
+}
+
+// Report renders the dry-run report for the plan
+func (plan *FreePBXImportPlan) Report() string {
+	var sb strings.Builder
+
+	sb.WriteString("📦 FreePBX Import (dry run)\n")
+	if plan.Source != "" {
+		sb.WriteString(fmt.Sprintf("   Source: %s\n", plan.Source))
+	}
+
+	sb.WriteString(fmt.Sprintf("\n📱 Extensions: %d\n", len(plan.Extensions)))
+	for _, ext := range plan.Extensions {
+		sb.WriteString(fmt.Sprintf("   %-8s %-24s %s, %s, %s\n", ext.ExtensionNumber, ext.Name, ext.Context, ext.Transport, ext.Codecs))
+	}
+
+	sb.WriteString(fmt.Sprintf("\n🔗 Trunks: %d\n", len(plan.Trunks)))
+	for _, trunk := range plan.Trunks {
+		register := ""
+		if trunk.Register {
+			register = " (registers)"
+		}
+		sb.WriteString(fmt.Sprintf("   %-16s %s:%d%s\n", trunk.Name, trunk.Host, trunk.Port, register))
+	}
+
+	sb.WriteString(fmt.Sprintf("\n🔔 Ring Groups: %d\n", len(plan.RingGroups)))
+	for _, group := range plan.RingGroups {
+		sb.WriteString(fmt.Sprintf("   %-8s %ds → %s\n", group.Number, group.RingTime, strings.Join(group.Members, ", ")))
+	}
+
+	sb.WriteString(fmt.Sprintf("\n📥 Inbound Routes: %d\n", len(plan.InboundRoutes)))
+	for _, route := range plan.InboundRoutes {
+		sb.WriteString(fmt.Sprintf("   %-16s → %s\n", route.DID, route.Destination))
+	}
+
+	sb.WriteString(fmt.Sprintf("\n📤 Outbound Routes: %d\n", len(plan.OutboundRoutes)))
+	for _, route := range plan.OutboundRoutes {
+		sb.WriteString(fmt.Sprintf("   %-16s %s via %s\n", route.Pattern, route.Name, strings.Join(route.Trunks, ", ")))
+	}
+
+	if len(plan.Warnings) > 0 {
+		sb.WriteString(fmt.Sprintf("\n⚠️  Warnings: %d\n", len(plan.Warnings)))
+		for _, warning := range plan.Warnings {
+			sb.WriteString(fmt.Sprintf("   • %s\n", warning))
+		}
+	}
+
+	if len(plan.Unmapped) > 0 {
+		sb.WriteString(fmt.Sprintf("\n❌ Cannot be mapped: %d\n", len(plan.Unmapped)))
+		for _, item := range plan.Unmapped {
+			sb.WriteString(fmt.Sprintf("   • [%s] %s: %s\n", item.Kind, item.Name, item.Reason))
+		}
+	} else {
+		sb.WriteString("\n✅ Everything in the source can be mapped\n")
+	}
+
+	return sb.String()
+}
+
+// Summary returns a one-line summary of the applied import
+func (r *FreePBXImportResult) Summary() string {
+	summary := fmt.Sprintf("Imported %d extensions, %d trunks and %d dialplan rules", r.Extensions, r.Trunks, r.Rules)
+	if len(r.Errors) > 0 {
+		summary += fmt.Sprintf(" (%d errors)", len(r.Errors))
+	}
+	return summary
+}
+
+// Apply writes the plan to Asterisk configuration and the database, then reloads Asterisk.
+// Configuration is written first so that extensions can be synced into the database with
+// SyncAsteriskToDatabase, exactly like extensions created directly in pjsip.conf.
+func (fi *FreePBXImporter) Apply(plan *FreePBXImportPlan) (*FreePBXImportResult, error) {
+	result := &FreePBXImportResult{}
+
+	// Extensions reach the database through the sync manager, check before anything is written
+	if fi.db != nil && fi.syncManager == nil {
+		return result, fmt.Errorf("extension sync manager is required to import into the database")
+	}
+
+	if err := fi.writePjsipConfig(plan); err != nil {
+		return result, err
+	}
+	if err := fi.writeDialplanConfig(plan); err != nil {
+		return result, err
+	}
+
+	if fi.db != nil {
+		fi.applyDatabase(plan, result)
+	}
+
+	if fi.asteriskManager != nil {
+		if _, err := fi.asteriskManager.ReloadAllQuiet(); err != nil {
+			result.Errors = append(result.Errors, fmt.Errorf("configuration written but reload failed: %w", err))
+		}
+	}
+
+	return result, nil
+}
+
+// writePjsipConfig replaces imported extensions and trunks in pjsip.conf with a single write
+func (fi *FreePBXImporter) writePjsipConfig(plan *FreePBXImportPlan) error {
+	if len(plan.Extensions) == 0 && len(plan.Trunks) == 0 {
+		return nil
+	}
+
+	config, err := fi.configManager.LoadPjsipConfig()
+	if err != nil {
+		return err
+	}
+
+	// Extensions go through the same writer as every other extension change
+	fi.configManager.replacePjsipExtensions(config, plan.Extensions)
+
+	for _, trunk := range plan.Trunks {
+		config.RemoveSectionsByName(trunk.Name)
+		for _, section := range trunk.TrunkSections() {
+			config.AddSection(section)
+		}
+	}
+
+	if err := config.Save(); err != nil {
+		return fmt.Errorf("failed to write config file: %v", err)
+	}
+
+	if err := fi.configManager.CommitConfigChange("freepbx-import", fmt.Sprintf("Imported %d extensions and %d trunks from FreePBX", len(plan.Extensions), len(plan.Trunks))); err != nil {
+		color.New(color.FgYellow).Fprintf(fi.configManager.out, "⚠️  Git commit warning: %v\n", err)
+	}
+
+	return nil
+}
+
+// ensureDialplanInclude makes sure a context includes another one, creating the context if needed
+func ensureDialplanInclude(config *AsteriskConfig, context, include string) {
+	sections := config.FindActiveSectionsByName(context)
+	if len(sections) == 0 {
+		section := NewAsteriskSection(context, "")
+		section.AddEntry("include", include)
+		config.AddSection(section)
+		return
+	}
+
+	for _, existing := range sections[0].GetEntries("include") {
+		if existing == include {
+			return
+		}
+	}
+	sections[0].AddEntry("include", include)
+}
+
+// writeDialplanConfig writes the managed ring group and route contexts to extensions.conf
+func (fi *FreePBXImporter) writeDialplanConfig(plan *FreePBXImportPlan) error {
+	if len(plan.RingGroups) == 0 && len(plan.InboundRoutes) == 0 && len(plan.OutboundRoutes) == 0 {
+		return nil
+	}
+
+	config, err := fi.configManager.LoadDialplanConfig()
+	if err != nil {
+		return err
+	}
+
+	for _, section := range plan.DialplanSections() {
+		config.RemoveSectionsByName(section.Name)
+		config.AddSection(section)
+	}
+	ensureDialplanInclude(config, DefaultExtensionContext, freePBXImportInternalContext)
+	ensureDialplanInclude(config, "from-trunk", freePBXImportInboundContext)
+
+	if err := config.Save(); err != nil {
+		return fmt.Errorf("failed to write dialplan file: %v", err)
+	}
+
+	if err := fi.configManager.CommitConfigChange("freepbx-import", "Imported ring groups and routes from FreePBX"); err != nil {
+		color.New(color.FgYellow).Fprintf(fi.configManager.out, "⚠️  Git commit warning: %v\n", err)
+	}
+
+	return nil
+}
+
+// applyDatabase stores imported extensions, trunks and dialplan rules in the database
+func (fi *FreePBXImporter) applyDatabase(plan *FreePBXImportPlan, result *FreePBXImportResult) {
+	for _, ext := range plan.Extensions {
+		if err := fi.syncManager.SyncAsteriskToDatabase(ext.ExtensionNumber); err != nil {
+			result.Errors = append(result.Errors, fmt.Errorf("extension %s: %w", ext.ExtensionNumber, err))
+			continue
+		}
+
+		// SyncAsteriskToDatabase only knows what pjsip.conf holds, fill in the rest
+		_, err := fi.db.Exec(`UPDATE extensions SET name = ?, email = ?, caller_id = ?, voicemail_enabled = ?,
+			codecs = ?, updated_at = NOW() WHERE extension_number = ?`,
+			ext.Name, ext.Email, ext.CallerID, ext.VoicemailEnabled, codecsToJSONSync(ext.Codecs), ext.ExtensionNumber)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Errorf("extension %s: database write error: %w", ext.ExtensionNumber, err))
+			continue
+		}
+		result.Extensions++
+	}
+
+	for _, trunk := range plan.Trunks {
+		_, err := fi.db.Exec(`INSERT INTO trunks (name, host, port, username, secret, transport, codecs, context, priority, enabled, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 1, NOW(), NOW())
+			ON DUPLICATE KEY UPDATE host = VALUES(host), port = VALUES(port), username = VALUES(username),
+			secret = VALUES(secret), transport = VALUES(transport), codecs = VALUES(codecs), updated_at = NOW()`,
+			trunk.Name, trunk.Host, trunk.Port, trunk.Username, trunk.Secret,
+			strings.TrimPrefix(trunk.Transport, "transport-"), codecsToJSONSync(strings.Join(trunk.Codecs, ",")),
+			trunk.Context, trunk.Priority)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Errorf("trunk %s: database write error: %w", trunk.Name, err))
+			continue
+		}
+		result.Trunks++
+	}
+
+	// Re-importing replaces the rules of the previous import
+	if _, err := fi.db.Exec("DELETE FROM dialplan_rules WHERE description LIKE ?", freePBXImportRulePrefix+"%"); err != nil {
+		result.Errors = append(result.Errors, fmt.Errorf("failed to clear previously imported rules: %w", err))
+		return
+	}
+
+	for i, rule := range plan.DialplanRules() {
+		_, err := fi.db.Exec(`INSERT INTO dialplan_rules (name, context, pattern, priority, app, app_data, enabled, rule_type, description, sort_order, created_at, updated_at)
+			VALUES (?, ?, ?, 1, ?, ?, 1, ?, ?, ?, NOW(), NOW())`,
+			rule.Name, rule.Context, rule.Pattern, rule.App, rule.AppData, rule.RuleType, rule.Description, i)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Errorf("dialplan rule %s: database write error: %w", rule.Name, err))
+			continue
+		}
+		result.Rules++
+	}
+}
+
+// initFreePBXImport opens the FreePBX import screen asking for the source path
+func (m *model) initFreePBXImport() {
+	m.currentScreen = freePBXImportScreen
+	m.inputMode = true
+	m.inputFields = []string{"FreePBX backup, config directory or file"}
+	m.inputValues = []string{"/etc/asterisk"}
+	m.inputCursor = 0
+	m.freePBXImportPlan = nil
+	m.freePBXImportOutput = ""
+	m.errorMsg = ""
+	m.successMsg = ""
+}
+
+// previewFreePBXImport builds the dry-run plan for the entered source
+func (m *model) previewFreePBXImport() {
+	src, err := LoadFreePBXSource(strings.TrimSpace(m.inputValues[0]))
+	if err != nil {
+		m.errorMsg = err.Error()
+		return
+	}
+
+	plan, err := BuildFreePBXImportPlan(src)
+	if err != nil {
+		m.errorMsg = fmt.Sprintf("Failed to map FreePBX configuration: %v", err)
+		return
+	}
+
+	m.inputMode = false
+	m.freePBXImportPlan = plan
+	m.freePBXImportOutput = plan.Report()
+	m.successMsg = "Dry run complete - review the report and press 'y' to import"
+}
+
+// applyFreePBXImport applies the previewed plan
+func (m *model) applyFreePBXImport() {
+	if m.freePBXImportPlan == nil {
+		m.errorMsg = "Nothing to import. Run the dry run first."
+		return
+	}
+
+	importer := NewFreePBXImporter(m.db, m.configManager, m.extensionSyncManager, m.asteriskManager)
+	result, err := importer.Apply(m.freePBXImportPlan)
+	if err != nil {
+		m.errorMsg = fmt.Sprintf("Import failed: %v", err)
+		return
+	}
+
+	m.freePBXImportPlan = nil
+	m.freePBXImportOutput = result.Summary() + "\n"
+	for _, importErr := range result.Errors {
+		m.freePBXImportOutput += fmt.Sprintf("   • %v\n", importErr)
+	}
+	if len(result.Errors) > 0 {
+		m.errorMsg = "Import finished with errors"
+	} else {
+		m.successMsg = result.Summary()
+	}
+
+	if exts, err := GetExtensions(m.db); err == nil {
+		m.extensions = exts
+		m.loadExtensionSyncInfo()
+	}
+}
+
+// renderFreePBXImport renders the FreePBX import screen
+func (m model) renderFreePBXImport() string {
+	content := infoStyle.Render("📦 Import from FreePBX / Issabel") + "\n\n"
+
+	if m.inputMode {
+		content += helpStyle.Render("Reads pjsip.endpoint/aor/auth.conf, extensions_additional.conf or a backup archive") + "\n\n"
+		for i, field := range m.inputFields {
+			cursor := " "
+			if i == m.inputCursor {
+				cursor = "▶"
+				field = selectedItemStyle.Render(field)
+			}
+			content += fmt.Sprintf("%s %s: %s\n", cursor, field, m.inputValues[i])
+		}
+		content += "\n" + helpStyle.Render("Enter: Run dry run • ESC: Cancel")
+		return menuStyle.Render(content)
+	}
+
+	content += m.freePBXImportOutput
+	return menuStyle.Render(content)
+}
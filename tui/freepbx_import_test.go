@@ -0,0 +1,452 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testFreePBXEndpoints = `
+[101]
+type=endpoint
+aors=101
+auth=101-auth
+allow=ulaw,alaw,g722
+context=from-internal
+callerid="John Smith" <101>
+direct_media=no
+mailboxes=101@default
+transport=0.0.0.0-udp
+
+[102]
+type=endpoint
+aors=102
+auth=102-auth
+allow=ulaw
+context=from-internal
+callerid=Jane <102>
+media_encryption=sdes
+transport=0.0.0.0-tls
+
+[103]
+type=endpoint
+aors=103
+auth=103-auth
+context=from-internal
+
+[mytrunk]
+type=endpoint
+transport=0.0.0.0-udp
+context=from-pstn
+allow=ulaw,alaw
+aors=mytrunk
+outbound_auth=mytrunk
+`
+
+const testFreePBXAors = `
+[101]
+type=aor
+max_contacts=3
+qualify_frequency=30
+
+[102]
+type=aor
+max_contacts=1
+
+[mytrunk]
+type=aor
+contact=sip:sip.provider.example:5080
+`
+
+const testFreePBXAuths = `
+[101-auth]
+type=auth
+auth_type=userpass
+username=101
+password=secret101
+
+[102-auth]
+type=auth
+auth_type=userpass
+username=102
+password=secret102
+
+[mytrunk]
+type=auth
+auth_type=userpass
+username=acct1
+password=trunkpass
+`
+
+const testFreePBXRegistrations = `
+[mytrunk]
+type=registration
+server_uri=sip:sip.provider.example:5080
+client_uri=sip:acct1@sip.provider.example:5080
+outbound_auth=mytrunk
+`
+
+const testFreePBXDialplan = `
+[globals]
+OUT_1 = PJSIP/mytrunk
+OUT_2 = SIP/oldtrunk
+
+[ext-group]
+include => ext-group-custom
+exten => 600,1,Macro(user-callerid,)
+exten => 600,n,Set(RingGroupMethod=hunt)
+exten => 600,n(DIALGRP),Macro(dial,25,${DIAL_OPTIONS},101-102-09125551234#)
+exten => 600,n,Goto(from-did-direct,103,1)
+exten => h,1,Macro(hangupcall,)
+
+[ext-did-0002]
+include => ext-did-0002-custom
+exten => 5551234,1,Set(__FROM_DID=${EXTEN})
+exten => 5551234,n(dest-ext),Goto(from-did-direct,101,1)
+exten => 5559999,1,Set(__FROM_DID=${EXTEN})
+exten => 5559999,n(dest-ext),Goto(ivr-3,s,1)
+
+[outrt-1] ; Default
+include => outrt-1-custom
+exten => _9NXXNXXXXXX,1,Macro(user-callerid,LIMIT,EXTERNAL,)
+exten => _9NXXNXXXXXX,n,Macro(dialout-trunk,1,${EXTEN:1},,off)
+exten => _9NXXNXXXXXX,n,Macro(outisbusy,)
+
+[outrt-2] ; Legacy
+exten => _8X.,1,Macro(dialout-trunk,2,${EXTEN:1},,off)
+
+[ivr-3]
+exten => s,1,Answer
+
+[ext-queues]
+exten => 700,1,Macro(user-callerid,)
+`
+
+const testFreePBXVoicemail = `
+[general]
+format=wav49
+
+[default]
+101 => 1234,John Smith,john@example.com,,attach=yes
+`
+
+// testFreePBXFiles returns the fixture files keyed by name
+func testFreePBXFiles() map[string]string {
+	return map[string]string{
+		"pjsip.endpoint.conf":        testFreePBXEndpoints,
+		"pjsip.aor.conf":             testFreePBXAors,
+		"pjsip.auth.conf":            testFreePBXAuths,
+		"pjsip.registration.conf":    testFreePBXRegistrations,
+		"extensions_additional.conf": testFreePBXDialplan,
+		"voicemail.conf":             testFreePBXVoicemail,
+	}
+}
+
+// buildTestFreePBXPlan builds a plan from the fixtures
+func buildTestFreePBXPlan(t *testing.T) *FreePBXImportPlan {
+	t.Helper()
+	plan, err := BuildFreePBXImportPlan(&FreePBXSource{Path: "test", Files: testFreePBXFiles()})
+	if err != nil {
+		t.Fatalf("BuildFreePBXImportPlan failed: %v", err)
+	}
+	return plan
+}
+
+// TestBuildFreePBXImportPlanExtensions tests mapping of FreePBX endpoints to extensions
+func TestBuildFreePBXImportPlanExtensions(t *testing.T) {
+	plan := buildTestFreePBXPlan(t)
+
+	if len(plan.Extensions) != 2 {
+		t.Fatalf("Expected 2 extensions, got %d: %+v", len(plan.Extensions), plan.Extensions)
+	}
+
+	ext := plan.Extensions[0]
+	if ext.ExtensionNumber != "101" || ext.Name != "John Smith" || ext.Secret != "secret101" {
+		t.Errorf("Unexpected extension 101: %+v", ext)
+	}
+	if ext.Email != "john@example.com" {
+		t.Errorf("Expected email from voicemail.conf, got %q", ext.Email)
+	}
+	if ext.Transport != "transport-udp" || ext.Codecs != "ulaw,alaw,g722" {
+		t.Errorf("Unexpected transport/codecs: %s / %s", ext.Transport, ext.Codecs)
+	}
+	if ext.MaxContacts != 3 || ext.QualifyFrequency != 30 || !ext.VoicemailEnabled {
+		t.Errorf("Unexpected aor/voicemail settings: %+v", ext)
+	}
+
+	ext = plan.Extensions[1]
+	if ext.Transport != "transport-tls" || ext.QualifyFrequency != DefaultQualifyFrequency {
+		t.Errorf("Unexpected extension 102: %+v", ext)
+	}
+
+	warnings := strings.Join(plan.Warnings, "\n")
+	if !strings.Contains(warnings, "media_encryption=sdes") {
+		t.Errorf("Expected warning for ignored media_encryption, got:\n%s", warnings)
+	}
+	if !strings.Contains(warnings, "0.0.0.0-tls") {
+		t.Errorf("Expected warning for TLS transport, got:\n%s", warnings)
+	}
+}
+
+// TestBuildFreePBXImportPlanTrunks tests mapping of FreePBX trunks
+func TestBuildFreePBXImportPlanTrunks(t *testing.T) {
+	plan := buildTestFreePBXPlan(t)
+
+	if len(plan.Trunks) != 1 {
+		t.Fatalf("Expected 1 trunk, got %d", len(plan.Trunks))
+	}
+	trunk := plan.Trunks[0]
+	if trunk.Name != "mytrunk" || trunk.Host != "sip.provider.example" || trunk.Port != 5080 {
+		t.Errorf("Unexpected trunk: %+v", trunk)
+	}
+	if trunk.Username != "acct1" || trunk.Secret != "trunkpass" || !trunk.Register {
+		t.Errorf("Unexpected trunk credentials: %+v", trunk)
+	}
+	if trunk.Context != "from-trunk" {
+		t.Errorf("Expected trunk context from-trunk, got %s", trunk.Context)
+	}
+
+	types := []string{}
+	for _, section := range trunk.TrunkSections() {
+		types = append(types, section.Type)
+	}
+	if strings.Join(types, ",") != "endpoint,auth,aor,identify,registration" {
+		t.Errorf("Unexpected trunk sections: %v", types)
+	}
+}
+
+// TestBuildFreePBXImportPlanDialplan tests mapping of ring groups and routes
+func TestBuildFreePBXImportPlanDialplan(t *testing.T) {
+	plan := buildTestFreePBXPlan(t)
+
+	if len(plan.RingGroups) != 1 {
+		t.Fatalf("Expected 1 ring group, got %d", len(plan.RingGroups))
+	}
+	group := plan.RingGroups[0]
+	if group.Number != "600" || group.RingTime != 25 || strings.Join(group.Members, ",") != "101,102" {
+		t.Errorf("Unexpected ring group: %+v", group)
+	}
+	if group.Failover != "from-internal,103,1" {
+		t.Errorf("Unexpected failover: %s", group.Failover)
+	}
+
+	if len(plan.InboundRoutes) != 1 || plan.InboundRoutes[0].DID != "5551234" || plan.InboundRoutes[0].Destination != "from-internal,101,1" {
+		t.Errorf("Unexpected inbound routes: %+v", plan.InboundRoutes)
+	}
+
+	if len(plan.OutboundRoutes) != 1 {
+		t.Fatalf("Expected 1 outbound route, got %d", len(plan.OutboundRoutes))
+	}
+	route := plan.OutboundRoutes[0]
+	if route.Name != "Default" || route.Pattern != "_9NXXNXXXXXX" || route.Strip != 1 || route.Trunks[0] != "mytrunk" {
+		t.Errorf("Unexpected outbound route: %+v", route)
+	}
+	if got := route.dialString("mytrunk"); got != "PJSIP/${EXTEN:1}@mytrunk,60" {
+		t.Errorf("Unexpected dial string: %s", got)
+	}
+
+	warnings := strings.Join(plan.Warnings, "\n")
+	for _, want := range []string{"strategy hunt", "external member 09125551234"} {
+		if !strings.Contains(warnings, want) {
+			t.Errorf("Expected warning containing %q, got:\n%s", want, warnings)
+		}
+	}
+}
+
+// TestBuildFreePBXImportPlanUnmapped tests that unsupported items are reported
+func TestBuildFreePBXImportPlanUnmapped(t *testing.T) {
+	plan := buildTestFreePBXPlan(t)
+
+	expected := map[string]string{
+		"103":           "no auth section",
+		"5559999":       "IVR",
+		"Legacy (_8X.)": "not a PJSIP trunk",
+		"ivr-3":         "not supported",
+		"700":           "not supported",
+	}
+
+	for name, reason := range expected {
+		found := false
+		for _, item := range plan.Unmapped {
+			if item.Name == name && strings.Contains(item.Reason, reason) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Expected unmapped item %s (%s), got %+v", name, reason, plan.Unmapped)
+		}
+	}
+
+	report := plan.Report()
+	for _, want := range []string{"Extensions: 2", "Trunks: 1", "Ring Groups: 1", "Cannot be mapped: 5"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("Report missing %q:\n%s", want, report)
+		}
+	}
+}
+
+// TestMapFreePBXTransport tests FreePBX transport name conversion
+func TestMapFreePBXTransport(t *testing.T) {
+	tests := []struct {
+		input  string
+		want   string
+		wantOk bool
+	}{
+		{"", DefaultExtensionTransport, true},
+		{"0.0.0.0-udp", "transport-udp", true},
+		{"0.0.0.0-tcp", "transport-tcp", true},
+		{"0.0.0.0-tls", "transport-tls", false},
+		{"0.0.0.0-wss", "transport-wss", false},
+		{"custom", DefaultExtensionTransport, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, ok := mapFreePBXTransport(tt.input)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("mapFreePBXTransport(%q) = (%s, %v), want (%s, %v)", tt.input, got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+// writeTestTar writes files into a tar stream, gzipped if requested
+func writeTestTar(t *testing.T, files map[string][]byte, gzipped bool) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var tw *tar.Writer
+	var gz *gzip.Writer
+	if gzipped {
+		gz = gzip.NewWriter(&buf)
+		tw = tar.NewWriter(gz)
+	} else {
+		tw = tar.NewWriter(&buf)
+	}
+	for name, data := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw.Close()
+	if gz != nil {
+		gz.Close()
+	}
+	return buf.Bytes()
+}
+
+// TestLoadFreePBXSourceNestedArchive tests reading configs from a nested backup archive
+func TestLoadFreePBXSourceNestedArchive(t *testing.T) {
+	inner := map[string][]byte{}
+	for name, content := range testFreePBXFiles() {
+		inner["etc/asterisk/"+name] = []byte(content)
+	}
+	inner["etc/asterisk/modules.conf"] = []byte("[modules]\n")
+
+	outer := writeTestTar(t, map[string][]byte{
+		"manifest":         []byte("backup"),
+		"astetcdir.tar.gz": writeTestTar(t, inner, true),
+	}, true)
+
+	path := filepath.Join(t.TempDir(), "backup.tgz")
+	if err := os.WriteFile(path, outer, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := LoadFreePBXSource(path)
+	if err != nil {
+		t.Fatalf("LoadFreePBXSource failed: %v", err)
+	}
+	if len(src.Files) != len(testFreePBXFiles()) {
+		t.Errorf("Expected %d files, got %d", len(testFreePBXFiles()), len(src.Files))
+	}
+	if _, ok := src.Files["modules.conf"]; ok {
+		t.Error("Unrelated files should not be loaded")
+	}
+}
+
+// TestLoadFreePBXSourceEmptyDir tests that a directory without configs is rejected
+func TestLoadFreePBXSourceEmptyDir(t *testing.T) {
+	if _, err := LoadFreePBXSource(t.TempDir()); err == nil {
+		t.Error("Expected error for directory without FreePBX configs")
+	}
+}
+
+// TestFreePBXImporterWritesConfig tests that applying a plan writes managed config blocks
+func TestFreePBXImporterWritesConfig(t *testing.T) {
+	dir := t.TempDir()
+	acm := NewAsteriskConfigManager(false)
+	acm.pjsipConfigPath = filepath.Join(dir, "pjsip.conf")
+	acm.extensionsConfigPath = filepath.Join(dir, "extensions.conf")
+
+	existing := "[from-internal]\nexten => 100,1,Dial(PJSIP/100)\n"
+	if err := os.WriteFile(acm.extensionsConfigPath, []byte(existing), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Extensions are written like any other, so profile templates come along
+	acm.SetExtensionProfiles([]ExtensionProfile{testDeskPhoneProfile()})
+	plan := buildTestFreePBXPlan(t)
+	plan.Extensions[0].Profile = "desk-phone"
+
+	importer := NewFreePBXImporter(nil, acm, nil, nil)
+	if _, err := importer.Apply(plan); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	pjsip, err := ParseAsteriskConfig(acm.pjsipConfigPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pjsip.FindSectionByNameAndType("101", "endpoint") == nil || pjsip.FindSectionByNameAndType("mytrunk", "registration") == nil {
+		t.Errorf("Expected extension and trunk sections in pjsip.conf:\n%s", pjsip.String())
+	}
+	if pjsip.FindSectionByNameAndType("desk-phone", "endpoint") == nil {
+		t.Errorf("Expected the profile template in pjsip.conf:\n%s", pjsip.String())
+	}
+
+	dialplan, err := ParseAsteriskConfig(acm.extensionsConfigPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	internal := dialplan.FindSectionsByName("from-internal")[0]
+	if len(internal.GetEntries("exten")) != 1 || internal.GetEntries("include")[0] != freePBXImportInternalContext {
+		t.Errorf("Expected existing from-internal to be kept with an include, got:\n%s", internal.String())
+	}
+	imported := dialplan.FindSectionsByName(freePBXImportInternalContext)
+	if len(imported) != 1 || !strings.Contains(imported[0].String(), "Dial(PJSIP/101&PJSIP/102,25)") {
+		t.Errorf("Expected ring group in managed context:\n%s", dialplan.String())
+	}
+	if len(dialplan.FindSectionsByName("from-trunk")) != 1 {
+		t.Error("Expected from-trunk context to be created for inbound routes")
+	}
+}
+
+// TestFreePBXImporterRequiresSyncManager tests that a database import without a sync manager fails before writing
+func TestFreePBXImporterRequiresSyncManager(t *testing.T) {
+	acm := NewAsteriskConfigManager(false)
+	acm.SetOutput(&bytes.Buffer{})
+	acm.pjsipConfigPath = filepath.Join(t.TempDir(), "pjsip.conf")
+
+	// sql.Open does not connect, the importer must refuse before touching the database
+	db, err := sql.Open("mysql", "user:pass@tcp(127.0.0.1:1)/rayanpbx?timeout=1s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	importer := NewFreePBXImporter(db, acm, nil, nil)
+	if _, err := importer.Apply(buildTestFreePBXPlan(t)); err == nil || !strings.Contains(err.Error(), "sync manager") {
+		t.Fatalf("Expected sync manager error, got %v", err)
+	}
+	if _, err := os.Stat(acm.pjsipConfigPath); !os.IsNotExist(err) {
+		t.Error("Expected pjsip.conf not to be written")
+	}
+}
@@ -0,0 +1,522 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/go-redis/redis/v8"
+)
+
+// DefaultRecordingsPath is where Asterisk stores call recordings
+const DefaultRecordingsPath = "/var/spool/asterisk/monitor"
+
+// healthCheckAsterisk is the name of the Asterisk service check
+const healthCheckAsterisk = "Asterisk Service"
+
+const (
+	diskWarnPercent    = 85
+	diskCritPercent    = 95
+	healthRedisTimeout = 2 * time.Second
+)
+
+// HealthStatus is the result level of a health check
+type HealthStatus string
+
+const (
+	HealthOK   HealthStatus = "ok"
+	HealthWarn HealthStatus = "warn"
+	HealthCrit HealthStatus = "crit"
+)
+
+// severity orders statuses from best to worst
+func (s HealthStatus) severity() int {
+	switch s {
+	case HealthWarn:
+		return 1
+	case HealthCrit:
+		return 2
+	}
+	return 0
+}
+
+// Icon returns the emoji used in human-readable output
+func (s HealthStatus) Icon() string {
+	switch s {
+	case HealthWarn:
+		return "⚠️ "
+	case HealthCrit:
+		return "❌"
+	}
+	return "✅"
+}
+
+// HealthCheckResult is the outcome of a single health check
+type HealthCheckResult struct {
+	Name        string       `json:"name"`
+	Status      HealthStatus `json:"status"`
+	Message     string       `json:"message"`
+	Remediation string       `json:"remediation,omitempty"`
+}
+
+// HealthReport holds all check results and the overall status
+type HealthReport struct {
+	Status      HealthStatus        `json:"status"`
+	GeneratedAt time.Time           `json:"generated_at"`
+	Checks      []HealthCheckResult `json:"checks"`
+}
+
+// NewHealthReport builds a report whose status is the worst of its checks
+func NewHealthReport(checks []HealthCheckResult) *HealthReport {
+	report := &HealthReport{Status: HealthOK, GeneratedAt: time.Now(), Checks: checks}
+	for _, check := range checks {
+		if check.Status.severity() > report.Status.severity() {
+			report.Status = check.Status
+		}
+	}
+	return report
+}
+
+// ExitCode returns the Nagios plugin exit code: 0 OK, 1 WARNING, 2 CRITICAL
+func (r *HealthReport) ExitCode() int {
+	return r.Status.severity()
+}
+
+// Count returns the number of checks with the given status
+func (r *HealthReport) Count(status HealthStatus) int {
+	n := 0
+	for _, check := range r.Checks {
+		if check.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Check returns the check with the given name, or nil if it did not run
+func (r *HealthReport) Check(name string) *HealthCheckResult {
+	for i := range r.Checks {
+		if r.Checks[i].Name == name {
+			return &r.Checks[i]
+		}
+	}
+	return nil
+}
+
+// AsteriskDown reports whether the Asterisk service check failed
+func (r *HealthReport) AsteriskDown() bool {
+	check := r.Check(healthCheckAsterisk)
+	return check != nil && check.Status == HealthCrit
+}
+
+// JSON returns the report as indented JSON
+func (r *HealthReport) JSON() (string, error) {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode health report: %v", err)
+	}
+	return string(data), nil
+}
+
+// YAML returns the report as YAML with the same fields as JSON
+func (r *HealthReport) YAML() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "status: %s\n", r.Status)
+	fmt.Fprintf(&b, "generated_at: %s\n", r.GeneratedAt.Format(time.RFC3339))
+	if len(r.Checks) == 0 {
+		b.WriteString("checks: []\n")
+		return b.String()
+	}
+	b.WriteString("checks:\n")
+	for _, check := range r.Checks {
+		fmt.Fprintf(&b, "  - name: %s\n", yamlScalar(check.Name))
+		fmt.Fprintf(&b, "    status: %s\n", check.Status)
+		fmt.Fprintf(&b, "    message: %s\n", yamlScalar(check.Message))
+		if check.Remediation != "" {
+			fmt.Fprintf(&b, "    remediation: %s\n", yamlScalar(check.Remediation))
+		}
+	}
+	return b.String()
+}
+
+// Nagios returns the report in Nagios plugin format: a status line with
+// performance data, followed by one line per check
+func (r *HealthReport) Nagios() string {
+	label := map[HealthStatus]string{HealthOK: "OK", HealthWarn: "WARNING", HealthCrit: "CRITICAL"}
+
+	summary := fmt.Sprintf("%d checks passed", len(r.Checks))
+	var problems []string
+	for _, check := range r.Checks {
+		if check.Status != HealthOK {
+			problems = append(problems, fmt.Sprintf("%s: %s", check.Name, check.Message))
+		}
+	}
+	if len(problems) > 0 {
+		summary = strings.Join(problems, "; ")
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "RAYANPBX %s - %s | ok=%d warn=%d crit=%d\n", label[r.Status], summary,
+		r.Count(HealthOK), r.Count(HealthWarn), r.Count(HealthCrit))
+	for _, check := range r.Checks {
+		fmt.Fprintf(&b, "[%s] %s: %s\n", label[check.Status], check.Name, check.Message)
+	}
+	return b.String()
+}
+
+// Text returns the report for humans, as shown in the TUI
+func (r *HealthReport) Text() string {
+	var b strings.Builder
+	for _, check := range r.Checks {
+		fmt.Fprintf(&b, "%s: %s %s\n", check.Name, check.Status.Icon(), check.Message)
+		if check.Remediation != "" && check.Status != HealthOK {
+			fmt.Fprintf(&b, "   💡 %s\n", check.Remediation)
+		}
+	}
+	return b.String()
+}
+
+// RunHealthChecks runs all health checks and returns structured results
+func (dm *DiagnosticsManager) RunHealthChecks() *HealthReport {
+	var checks []HealthCheckResult
+
+	asteriskUp := false
+	status, err := dm.asterisk.GetServiceStatus()
+	if err == nil && status == "running" {
+		asteriskUp = true
+		checks = append(checks, HealthCheckResult{Name: healthCheckAsterisk, Status: HealthOK, Message: "Running"})
+	} else {
+		checks = append(checks, HealthCheckResult{
+			Name: healthCheckAsterisk, Status: HealthCrit, Message: "Not running",
+			Remediation: "Start it with 'systemctl start asterisk' and check /var/log/asterisk/messages",
+		})
+	}
+
+	listening, addr, err := dm.CheckSIPPortQuiet(5060)
+	checks = append(checks, sipPortHealth(listening, addr, err))
+
+	if asteriskUp {
+		output, err := dm.asterisk.ShowTransports()
+		checks = append(checks, transportsHealth(output, err))
+		output, err = dm.asterisk.ExecuteCLICommand("pjsip show endpoints")
+		checks = append(checks, endpointsHealth(output, err))
+	}
+
+	checks = append(checks, databaseHealth(dm.db))
+	checks = append(checks, dm.redisHealth())
+
+	for _, status := range dm.certificateStatuses() {
+		checks = append(checks, certificateHealth(status))
+	}
+
+	checks = append(checks, diskSpaceHealth(getEnv("ASTERISK_RECORDINGS_PATH", DefaultRecordingsPath)))
+
+	if dm.syncManager != nil && dm.db != nil {
+		total, _, dbOnly, astOnly, mismatched, err := dm.syncManager.GetSyncSummary()
+		checks = append(checks, syncHealth(total, dbOnly, astOnly, mismatched, err))
+	}
+
+	return NewHealthReport(checks)
+}
+
+// sipPortHealth checks that something listens on the SIP port
+func sipPortHealth(listening bool, addr string, err error) HealthCheckResult {
+	result := HealthCheckResult{Name: "SIP Port"}
+	switch {
+	case err != nil:
+		result.Status = HealthWarn
+		result.Message = err.Error()
+		result.Remediation = "Install iproute2 (ss) or net-tools (netstat)"
+	case listening:
+		result.Status = HealthOK
+		result.Message = "Listening on " + addr
+	default:
+		result.Status = HealthCrit
+		result.Message = "Nothing is listening on port 5060"
+		result.Remediation = "Check the transports in /etc/asterisk/pjsip.conf and run 'pjsip reload'"
+	}
+	return result
+}
+
+// transportsHealth checks the output of "pjsip show transports"
+func transportsHealth(output string, err error) HealthCheckResult {
+	result := HealthCheckResult{Name: "PJSIP Transports"}
+	if err != nil {
+		result.Status = HealthCrit
+		result.Message = "Failed to query transports"
+		result.Remediation = "Check that Asterisk is running and res_pjsip is loaded"
+		return result
+	}
+
+	var transports []string
+	inBody := false
+	for _, line := range strings.Split(output, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "====") {
+			inBody = true
+			continue
+		}
+		fields := strings.Fields(trimmed)
+		if inBody && len(fields) >= 3 && fields[0] == "Transport:" {
+			transports = append(transports, fmt.Sprintf("%s (%s)", fields[1], fields[2]))
+		}
+	}
+	if len(transports) == 0 {
+		result.Status = HealthCrit
+		result.Message = "No transports loaded"
+		result.Remediation = "Add a transport to /etc/asterisk/pjsip.conf or run the TLS transport setup"
+		return result
+	}
+	result.Status = HealthOK
+	result.Message = strings.Join(transports, ", ")
+	return result
+}
+
+// endpointsHealth checks the output of "pjsip show endpoints"
+func endpointsHealth(output string, err error) HealthCheckResult {
+	result := HealthCheckResult{Name: "PJSIP Endpoints"}
+	if err != nil {
+		result.Status = HealthCrit
+		result.Message = "Failed to query endpoints"
+		result.Remediation = "Check that Asterisk is running and res_pjsip is loaded"
+		return result
+	}
+
+	endpoints := 0
+	inBody := false
+	for _, line := range strings.Split(output, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "====") {
+			inBody = true
+			continue
+		}
+		if inBody && strings.HasPrefix(trimmed, "Endpoint:") {
+			endpoints++
+		}
+	}
+	if endpoints == 0 {
+		result.Status = HealthWarn
+		result.Message = "None configured"
+		result.Remediation = "Add extensions on the Extensions screen and sync them to Asterisk"
+		return result
+	}
+	result.Status = HealthOK
+	result.Message = fmt.Sprintf("%d configured", endpoints)
+	return result
+}
+
+// databaseHealth checks the database connection
+func databaseHealth(db *sql.DB) HealthCheckResult {
+	result := HealthCheckResult{Name: "Database"}
+	if db == nil {
+		result.Status = HealthCrit
+		result.Message = "Not connected"
+		result.Remediation = "Check DB_HOST, DB_USERNAME and DB_PASSWORD in .env and that MySQL is running"
+		return result
+	}
+	if err := db.Ping(); err != nil {
+		result.Status = HealthCrit
+		result.Message = fmt.Sprintf("Ping failed: %v", err)
+		result.Remediation = "Check that MySQL is running: 'systemctl status mysql'"
+		return result
+	}
+	result.Status = HealthOK
+	result.Message = "Connected"
+	return result
+}
+
+// redisHealth checks the Redis server used for real-time events
+func (dm *DiagnosticsManager) redisHealth() HealthCheckResult {
+	addr := fmt.Sprintf("%s:%s", getEnv("REDIS_HOST", "127.0.0.1"), getEnv("REDIS_PORT", "6379"))
+	rdb := redis.NewClient(&redis.Options{
+		Addr:        addr,
+		Password:    getEnv("REDIS_PASSWORD", ""),
+		DialTimeout: healthRedisTimeout,
+		ReadTimeout: healthRedisTimeout,
+	})
+	defer rdb.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), healthRedisTimeout)
+	defer cancel()
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		// Calls keep working without Redis, only live updates stop
+		return HealthCheckResult{
+			Name: "Redis", Status: HealthWarn, Message: fmt.Sprintf("%s unreachable: %v", addr, err),
+			Remediation: "Start it with 'systemctl start redis-server' or check REDIS_HOST and REDIS_PASSWORD",
+		}
+	}
+	return HealthCheckResult{Name: "Redis", Status: HealthOK, Message: "Connected to " + addr}
+}
+
+// certificateHealth converts a certificate expiry check into a health result
+func certificateHealth(status CertificateStatus) HealthCheckResult {
+	result := HealthCheckResult{Name: "TLS Certificate " + filepath.Base(status.File)}
+	days := int(time.Until(status.NotAfter).Hours() / 24)
+	switch {
+	case status.Err != nil:
+		result.Status = HealthCrit
+		result.Message = status.Err.Error()
+		result.Remediation = "Renew or regenerate the certificate from Asterisk Management → TLS Transport"
+	case status.ExpiresSoon():
+		result.Status = HealthWarn
+		result.Message = fmt.Sprintf("Expires in %d days (%s)", days, status.NotAfter.Format("2006-01-02"))
+		result.Remediation = "Renew the certificate before it expires"
+	default:
+		result.Status = HealthOK
+		result.Message = fmt.Sprintf("Valid until %s (%d days)", status.NotAfter.Format("2006-01-02"), days)
+	}
+	return result
+}
+
+// diskSpaceHealth checks free space on the filesystem holding path
+func diskSpaceHealth(path string) HealthCheckResult {
+	result := HealthCheckResult{Name: "Recordings Disk"}
+
+	// Recordings may not exist yet; check the filesystem they will be written to
+	dir := path
+	for {
+		if _, err := os.Stat(dir); err == nil || dir == filepath.Dir(dir) {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+
+	output, err := exec.Command("df", "-P", "-k", dir).Output()
+	if err != nil {
+		result.Status = HealthWarn
+		result.Message = fmt.Sprintf("Failed to check disk space for %s: %v", dir, err)
+		return result
+	}
+	usedPercent, availKB, err := parseDfOutput(string(output))
+	if err != nil {
+		result.Status = HealthWarn
+		result.Message = err.Error()
+		return result
+	}
+	return diskUsageHealth(result, path, usedPercent, availKB)
+}
+
+// diskUsageHealth rates disk usage against the warning and critical thresholds
+func diskUsageHealth(result HealthCheckResult, path string, usedPercent int, availKB int64) HealthCheckResult {
+	result.Message = fmt.Sprintf("%d%% used, %.1f GB free for %s", usedPercent, float64(availKB)/1024/1024, path)
+	switch {
+	case usedPercent >= diskCritPercent:
+		result.Status = HealthCrit
+		result.Remediation = "Delete or archive old recordings to free space"
+	case usedPercent >= diskWarnPercent:
+		result.Status = HealthWarn
+		result.Remediation = "Plan to archive old recordings soon"
+	default:
+		result.Status = HealthOK
+	}
+	return result
+}
+
+// parseDfOutput parses POSIX "df -P -k" output for a single filesystem
+func parseDfOutput(output string) (usedPercent int, availKB int64, err error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) < 2 {
+		return 0, 0, fmt.Errorf("unexpected df output: %q", output)
+	}
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(fields) < 6 {
+		return 0, 0, fmt.Errorf("unexpected df output: %q", lines[len(lines)-1])
+	}
+	availKB, err = strconv.ParseInt(fields[3], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to parse available space: %v", err)
+	}
+	usedPercent, err = strconv.Atoi(strings.TrimSuffix(fields[4], "%"))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to parse disk usage: %v", err)
+	}
+	return usedPercent, availKB, nil
+}
+
+// syncHealth rates the database/Asterisk extension sync state
+func syncHealth(total, dbOnly, astOnly, mismatched int, err error) HealthCheckResult {
+	result := HealthCheckResult{Name: "Config Sync"}
+	if err != nil {
+		result.Status = HealthWarn
+		result.Message = fmt.Sprintf("Failed to compare extensions: %v", err)
+		return result
+	}
+	if issues := dbOnly + astOnly + mismatched; issues > 0 {
+		result.Status = HealthWarn
+		result.Message = fmt.Sprintf("%d of %d extensions out of sync (%d DB-only, %d Asterisk-only, %d mismatched)",
+			issues, total, dbOnly, astOnly, mismatched)
+		result.Remediation = "Resolve them in Extensions Management → Sync Manager"
+		return result
+	}
+	result.Status = HealthOK
+	result.Message = fmt.Sprintf("%d extensions in sync", total)
+	return result
+}
+
+// runHealthCommand runs "rayanpbx-tui health" and returns the exit code
+func runHealthCommand(args []string) int {
+	output := "text"
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--output" && i+1 < len(args):
+			output = args[i+1]
+			i++
+		case strings.HasPrefix(args[i], "--output="):
+			output = strings.TrimPrefix(args[i], "--output=")
+		}
+	}
+	if output == "table" {
+		output = "text"
+	}
+	if output != "text" && output != "json" && output != "yaml" && output != "nagios" {
+		fmt.Fprintf(os.Stderr, "Error: unknown output format %q (use text, table, json, yaml or nagios)\n", output)
+		return 3
+	}
+
+	// Keep stdout clean for monitoring systems
+	config, _ := LoadConfig()
+	dm := NewDiagnosticsManager(NewAsteriskManager())
+	dm.configManager = NewAsteriskConfigManager(false)
+	dm.configManager.SetOutput(os.Stderr)
+	if db, err := ConnectDB(config); err == nil {
+		defer db.Close()
+		dm.db = db
+		dm.syncManager = NewExtensionSyncManager(db, dm.asterisk, dm.configManager)
+	}
+
+	report := dm.RunHealthChecks()
+	switch output {
+	case "json":
+		data, err := report.JSON()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 3
+		}
+		fmt.Println(data)
+	case "yaml":
+		fmt.Print(report.YAML())
+	case "nagios":
+		fmt.Print(report.Nagios())
+	default:
+		colors := map[HealthStatus]*color.Color{
+			HealthOK:   color.New(color.FgGreen),
+			HealthWarn: color.New(color.FgYellow),
+			HealthCrit: color.New(color.FgRed),
+		}
+		for _, check := range report.Checks {
+			colors[check.Status].Printf("%s %s: %s\n", check.Status.Icon(), check.Name, check.Message)
+			if check.Remediation != "" && check.Status != HealthOK {
+				fmt.Printf("   💡 %s\n", check.Remediation)
+			}
+		}
+	}
+	return report.ExitCode()
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestHealthReportStatus tests the overall status, exit codes and output formats
+func TestHealthReportStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []HealthStatus
+		want     HealthStatus
+		exitCode int
+	}{
+		{"all ok", []HealthStatus{HealthOK, HealthOK}, HealthOK, 0},
+		{"warning", []HealthStatus{HealthOK, HealthWarn}, HealthWarn, 1},
+		{"critical wins", []HealthStatus{HealthWarn, HealthCrit, HealthOK}, HealthCrit, 2},
+		{"no checks", nil, HealthOK, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var checks []HealthCheckResult
+			for i, status := range tt.statuses {
+				checks = append(checks, HealthCheckResult{Name: string(rune('A' + i)), Status: status, Message: "msg"})
+			}
+			report := NewHealthReport(checks)
+			if report.Status != tt.want || report.ExitCode() != tt.exitCode {
+				t.Errorf("Expected %s/%d, got %s/%d", tt.want, tt.exitCode, report.Status, report.ExitCode())
+			}
+		})
+	}
+
+	report := NewHealthReport([]HealthCheckResult{
+		{Name: "Asterisk Service", Status: HealthOK, Message: "Running"},
+		{Name: "Redis", Status: HealthWarn, Message: "127.0.0.1:6379 unreachable", Remediation: "Start redis"},
+		{Name: "SIP Port", Status: HealthCrit, Message: "Nothing is listening on port 5060"},
+	})
+
+	nagios := report.Nagios()
+	lines := strings.Split(strings.TrimSpace(nagios), "\n")
+	wantFirst := "RAYANPBX CRITICAL - Redis: 127.0.0.1:6379 unreachable; SIP Port: Nothing is listening on port 5060 | ok=1 warn=1 crit=1"
+	if lines[0] != wantFirst {
+		t.Errorf("Unexpected Nagios status line:\n%s\nwant:\n%s", lines[0], wantFirst)
+	}
+	if len(lines) != 4 || lines[1] != "[OK] Asterisk Service: Running" || lines[2] != "[WARNING] Redis: 127.0.0.1:6379 unreachable" {
+		t.Errorf("Unexpected Nagios details:\n%s", nagios)
+	}
+	if ok := NewHealthReport([]HealthCheckResult{{Name: "A", Status: HealthOK}}).Nagios(); !strings.HasPrefix(ok, "RAYANPBX OK - 1 checks passed |") {
+		t.Errorf("Unexpected OK status line: %s", ok)
+	}
+	if check := report.Check("Redis"); check == nil || check.Status != HealthWarn || report.AsteriskDown() {
+		t.Errorf("Expected the Redis check by name and Asterisk up, got %+v", check)
+	}
+	down := NewHealthReport([]HealthCheckResult{{Name: "SIP Port", Status: HealthOK}, {Name: healthCheckAsterisk, Status: HealthCrit}})
+	if !down.AsteriskDown() {
+		t.Error("Expected Asterisk to be reported down wherever its check is")
+	}
+
+	data, err := report.JSON()
+	if err != nil {
+		t.Fatalf("JSON failed: %v", err)
+	}
+	var decoded struct {
+		Status string `json:"status"`
+		Checks []struct {
+			Name        string `json:"name"`
+			Status      string `json:"status"`
+			Remediation string `json:"remediation"`
+		} `json:"checks"`
+	}
+	if err := json.Unmarshal([]byte(data), &decoded); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if decoded.Status != "crit" || len(decoded.Checks) != 3 || decoded.Checks[1].Status != "warn" || decoded.Checks[1].Remediation != "Start redis" {
+		t.Errorf("Unexpected JSON: %s", data)
+	}
+
+	if yaml := report.YAML(); !strings.HasPrefix(yaml, "status: crit\n") || !strings.Contains(yaml, "  - name: Redis\n    status: warn\n    message: \"127.0.0.1:6379 unreachable\"\n    remediation: Start redis\n") {
+		t.Errorf("Unexpected YAML output:\n%s", yaml)
+	}
+
+	if text := report.Text(); !strings.Contains(text, "Redis: ⚠️  127.0.0.1:6379 unreachable\n   💡 Start redis\n") {
+		t.Errorf("Unexpected text output:\n%s", text)
+	}
+}
+
+// TestHealthCheckHelpers tests the individual check evaluators
+func TestHealthCheckHelpers(t *testing.T) {
+	transports := `
+Transport:  <TransportId........>  <Type>  <cos>  <tos>  <BindAddress....................>
+==========================================================================================
+
+Transport:  transport-udp             udp      0      0  0.0.0.0:5060
+Transport:  transport-tls             tls      0      0  0.0.0.0:5061
+
+Objects found: 2
+`
+	endpoints := `
+ Endpoint:  <Endpoint/CID.....................................>  <State.....>  <Channels.>
+==========================================================================================
+
+ Endpoint:  101/"Alice" <101>                                    Not in use    0 of inf
+     InAuth:  101/101
+ Endpoint:  102                                                  Unavailable   0 of inf
+
+Objects found: 2
+`
+	tests := []struct {
+		name    string
+		result  HealthCheckResult
+		status  HealthStatus
+		message string
+	}{
+		{"transports", transportsHealth(transports, nil), HealthOK, "transport-udp (udp), transport-tls (tls)"},
+		{"no transports", transportsHealth("No objects found.\n", nil), HealthCrit, "No transports loaded"},
+		{"transports error", transportsHealth("", errors.New("failed")), HealthCrit, "Failed to query"},
+		{"endpoints", endpointsHealth(endpoints, nil), HealthOK, "2 configured"},
+		{"no endpoints", endpointsHealth("No objects found.\n", nil), HealthWarn, "None configured"},
+		{"endpoints error", endpointsHealth("", errors.New("failed")), HealthCrit, "Failed to query"},
+		{"sip port listening", sipPortHealth(true, "10.0.0.1:5060", nil), HealthOK, "10.0.0.1:5060"},
+		{"sip port closed", sipPortHealth(false, "", nil), HealthCrit, "Nothing is listening"},
+		{"sip port unknown", sipPortHealth(false, "", errors.New("could not check port status")), HealthWarn, "could not check"},
+		{"database missing", databaseHealth(nil), HealthCrit, "Not connected"},
+		{"in sync", syncHealth(10, 0, 0, 0, nil), HealthOK, "10 extensions in sync"},
+		{"out of sync", syncHealth(10, 1, 2, 1, nil), HealthWarn, "4 of 10 extensions out of sync"},
+		{"certificate valid", certificateHealth(CertificateStatus{File: "/etc/asterisk/keys/asterisk.pem", NotAfter: time.Now().Add(90 * 24 * time.Hour)}), HealthOK, "Valid until"},
+		{"certificate expiring", certificateHealth(CertificateStatus{File: "a.pem", NotAfter: time.Now().Add(10 * 24 * time.Hour)}), HealthWarn, "Expires in"},
+		{"certificate expired", certificateHealth(CertificateStatus{File: "a.pem", Err: errors.New("certificate expired on 2024-01-01")}), HealthCrit, "expired"},
+		{"disk ok", diskUsageHealth(HealthCheckResult{}, "/rec", 40, 50*1024*1024), HealthOK, "40% used, 50.0 GB free"},
+		{"disk warn", diskUsageHealth(HealthCheckResult{}, "/rec", 88, 1024*1024), HealthWarn, "88% used"},
+		{"disk crit", diskUsageHealth(HealthCheckResult{}, "/rec", 97, 1024), HealthCrit, "97% used"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.result.Status != tt.status || !strings.Contains(tt.result.Message, tt.message) {
+				t.Errorf("Expected %s containing %q, got %s %q", tt.status, tt.message, tt.result.Status, tt.result.Message)
+			}
+			if tt.status == HealthCrit && tt.result.Remediation == "" {
+				t.Errorf("Expected remediation for %s", tt.name)
+			}
+		})
+	}
+
+	if name := certificateHealth(CertificateStatus{File: "/etc/asterisk/keys/asterisk.pem"}).Name; name != "TLS Certificate asterisk.pem" {
+		t.Errorf("Unexpected certificate check name %q", name)
+	}
+
+	// An unreachable database is critical
+	db, err := sql.Open("mysql", "user:pass@tcp(127.0.0.1:1)/rayanpbx?timeout=1s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if result := databaseHealth(db); result.Status != HealthCrit || !strings.Contains(result.Message, "Ping failed") {
+		t.Errorf("Expected failed ping, got %+v", result)
+	}
+}
+
+// TestParseDfOutput tests parsing POSIX df output
+func TestParseDfOutput(t *testing.T) {
+	output := `Filesystem     1024-blocks     Used Available Capacity Mounted on
+/dev/sda1        102687672 61612603  35816189      64% /
+`
+	used, avail, err := parseDfOutput(output)
+	if err != nil || used != 64 || avail != 35816189 {
+		t.Errorf("Expected 64%% used and 35816189 KB free, got %d, %d (%v)", used, avail, err)
+	}
+	if _, _, err := parseDfOutput("df: /nope: No such file or directory\n"); err == nil {
+		t.Error("Expected error for unexpected output")
+	}
+
+	// The real command works on the nearest existing directory
+	if result := diskSpaceHealth(t.TempDir() + "/missing/recordings"); result.Status == "" || !strings.Contains(result.Message, "used") {
+		t.Errorf("Unexpected disk check: %+v", result)
+	}
+}
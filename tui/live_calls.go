@@ -0,0 +1,655 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// liveCallsRefreshInterval is how often the live calls view refreshes itself
+const liveCallsRefreshInterval = 2 * time.Second
+
+// DefaultLiveCallContext is the dialplan context used for redirects and transfers
+const DefaultLiveCallContext = "from-internal"
+
+// Live call actions that need a destination form
+const (
+	liveCallRedirect = "redirect"
+	liveCallTransfer = "transfer"
+	liveCallSpy      = "spy"
+	liveCallWhisper  = "whisper"
+)
+
+// liveCallActionTitles names the actions in the destination form
+var liveCallActionTitles = map[string]string{
+	liveCallRedirect: "Redirect",
+	liveCallTransfer: "Transfer",
+	liveCallSpy:      "Spy",
+	liveCallWhisper:  "Whisper",
+}
+
+// liveCallTargetPattern restricts destinations and contexts to safe dialplan names
+var liveCallTargetPattern = regexp.MustCompile(`^[0-9A-Za-z*#+_.-]+$`)
+
+// LiveChannel is one line of "core show channels concise"
+type LiveChannel struct {
+	Name        string `json:"name"`
+	Context     string `json:"context"`
+	Exten       string `json:"exten"`
+	Priority    string `json:"priority"`
+	State       string `json:"state"`
+	Application string `json:"application"`
+	Data        string `json:"data"`
+	CallerID    string `json:"caller_id"`
+	AccountCode string `json:"account_code"`
+	Duration    int    `json:"duration"`
+	BridgeID    string `json:"bridge_id"`
+	UniqueID    string `json:"unique_id"`
+}
+
+// LiveCall is a call made of one or more bridged channels
+type LiveCall struct {
+	ID            string   `json:"id"`
+	Caller        string   `json:"caller"`
+	Callee        string   `json:"callee"`
+	CallerChannel string   `json:"caller_channel"`
+	CalleeChannel string   `json:"callee_channel,omitempty"`
+	Channels      []string `json:"channels"`
+	State         string   `json:"state"`
+	Duration      int      `json:"duration"`
+	Codec         string   `json:"codec,omitempty"`
+	Trunk         string   `json:"trunk,omitempty"`
+	Bridged       bool     `json:"bridged"`
+}
+
+// ParseChannelsConcise parses "core show channels concise" output, whose fields are
+// channel!context!exten!priority!state!application!data!callerid!accountcode!peeraccount!amaflags!duration!bridgeid!uniqueid
+func ParseChannelsConcise(output string) []LiveChannel {
+	var channels []LiveChannel
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), "!")
+		if len(fields) < 14 {
+			continue
+		}
+		duration, _ := strconv.Atoi(fields[11])
+		channels = append(channels, LiveChannel{
+			Name:        fields[0],
+			Context:     fields[1],
+			Exten:       fields[2],
+			Priority:    fields[3],
+			State:       fields[4],
+			Application: fields[5],
+			Data:        fields[6],
+			CallerID:    fields[7],
+			AccountCode: fields[8],
+			Duration:    duration,
+			BridgeID:    fields[12],
+			UniqueID:    fields[13],
+		})
+	}
+	return channels
+}
+
+// isOutgoingLeg returns true for channels created by Dial on behalf of another channel
+func (c LiveChannel) isOutgoingLeg() bool {
+	return c.Application == "AppDial" || c.Application == "AppDial2"
+}
+
+// channelPrefix returns the technology and endpoint of a channel, e.g. PJSIP/102
+func channelPrefix(name string) string {
+	if i := strings.LastIndex(name, "-"); i > 0 {
+		return name[:i]
+	}
+	return name
+}
+
+// BuildLiveCalls pairs bridged channels into calls. Unbridged outgoing legs are
+// matched to the channel dialing them; trunks lists the trunk endpoint names.
+func BuildLiveCalls(channels []LiveChannel, codecs map[string]string, trunks []string) []*LiveCall {
+	trunkSet := make(map[string]bool, len(trunks))
+	for _, t := range trunks {
+		trunkSet[strings.ToLower(t)] = true
+	}
+	isTrunk := func(endpoint string) bool {
+		if endpoint == "" {
+			return false
+		}
+		if len(trunkSet) > 0 {
+			return trunkSet[strings.ToLower(endpoint)]
+		}
+		_, err := strconv.Atoi(endpoint)
+		return err != nil
+	}
+
+	var groups [][]LiveChannel
+	bridges := make(map[string]int)
+	var dialing []LiveChannel
+	for _, ch := range channels {
+		switch {
+		case ch.BridgeID != "":
+			idx, ok := bridges[ch.BridgeID]
+			if !ok {
+				idx = len(groups)
+				bridges[ch.BridgeID] = idx
+				groups = append(groups, nil)
+			}
+			groups[idx] = append(groups[idx], ch)
+		case ch.isOutgoingLeg():
+			dialing = append(dialing, ch)
+		default:
+			groups = append(groups, []LiveChannel{ch})
+		}
+	}
+
+	// Ringing legs join the unbridged channel whose Dial data names their endpoint
+	for _, leg := range dialing {
+		prefix := channelPrefix(leg.Name)
+		matched := false
+		for i, group := range groups {
+			if group[0].BridgeID == "" && strings.Contains(group[0].Data, prefix) {
+				groups[i] = append(groups[i], leg)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			groups = append(groups, []LiveChannel{leg})
+		}
+	}
+
+	var calls []*LiveCall
+	for _, group := range groups {
+		// The caller is the channel that is not an outgoing leg, or the oldest one
+		sort.SliceStable(group, func(i, j int) bool {
+			if group[i].isOutgoingLeg() != group[j].isOutgoingLeg() {
+				return !group[i].isOutgoingLeg()
+			}
+			return group[i].Duration > group[j].Duration
+		})
+		caller := group[0]
+		call := &LiveCall{
+			ID:            caller.UniqueID,
+			CallerChannel: caller.Name,
+			Caller:        liveChannelParty(caller),
+			State:         caller.State,
+			Bridged:       caller.BridgeID != "",
+		}
+		if call.Bridged {
+			call.ID = caller.BridgeID
+		}
+
+		var callees []string
+		for _, ch := range group {
+			call.Channels = append(call.Channels, ch.Name)
+			if ch.Duration > call.Duration {
+				call.Duration = ch.Duration
+			}
+			if call.Codec == "" {
+				call.Codec = codecs[ch.Name]
+			}
+			endpoint := channelEndpoint(ch.Name)
+			if call.Trunk == "" && isTrunk(endpoint) {
+				call.Trunk = endpoint
+			}
+			if ch.Name == caller.Name {
+				continue
+			}
+			if call.CalleeChannel == "" {
+				call.CalleeChannel = ch.Name
+			}
+			// Outgoing legs carry the caller's ID, so the callee is named by its endpoint
+			if isTrunk(endpoint) {
+				callees = append(callees, caller.Exten)
+			} else {
+				callees = append(callees, endpoint)
+			}
+		}
+		if len(callees) == 0 {
+			callees = append(callees, caller.Exten)
+		}
+		call.Callee = strings.Join(callees, ", ")
+		if !call.Bridged && call.CalleeChannel != "" {
+			call.State = "Ringing"
+		}
+		calls = append(calls, call)
+	}
+
+	sort.SliceStable(calls, func(i, j int) bool {
+		if calls[i].Duration != calls[j].Duration {
+			return calls[i].Duration > calls[j].Duration
+		}
+		return calls[i].CallerChannel < calls[j].CallerChannel
+	})
+	return calls
+}
+
+// liveChannelParty returns the number shown for a channel, falling back to its endpoint
+func liveChannelParty(ch LiveChannel) string {
+	if ch.CallerID != "" && ch.CallerID != "<unknown>" {
+		return ch.CallerID
+	}
+	return channelEndpoint(ch.Name)
+}
+
+// formatCallDuration renders seconds as h:mm:ss or m:ss
+func formatCallDuration(seconds int) string {
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds%3600/60, seconds%60)
+	}
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
+
+// LiveCallManager lists active calls and acts on their channels
+type LiveCallManager struct {
+	asterisk *AsteriskManager
+	config   *Config
+}
+
+// NewLiveCallManager creates a live call manager
+func NewLiveCallManager(asterisk *AsteriskManager, config *Config) *LiveCallManager {
+	return &LiveCallManager{asterisk: asterisk, config: config}
+}
+
+// List reads channels and codecs from Asterisk and pairs them into calls
+func (lm *LiveCallManager) List(trunks []string) ([]*LiveCall, error) {
+	output, err := lm.asterisk.ExecuteCLICommand("core show channels concise")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list channels: %v", err)
+	}
+	channels := ParseChannelsConcise(output)
+
+	codecs := make(map[string]string)
+	if len(channels) > 0 {
+		// Codecs are only known for PJSIP channels with RTP
+		if stats, err := lm.asterisk.ExecuteCLICommand("pjsip show channelstats"); err == nil {
+			for _, q := range ParseChannelStats(stats) {
+				codecs[q.Channel] = q.Codec
+			}
+		}
+	}
+	return BuildLiveCalls(channels, codecs, trunks), nil
+}
+
+// Hangup hangs up every channel of the call
+func (lm *LiveCallManager) Hangup(call *LiveCall) error {
+	for _, channel := range call.Channels {
+		if _, err := lm.asterisk.ExecuteCLICommand("channel request hangup " + channel); err != nil {
+			return fmt.Errorf("failed to hang up %s: %v", channel, err)
+		}
+	}
+	return nil
+}
+
+// Redirect sends the caller to another extension, ending the other legs
+func (lm *LiveCallManager) Redirect(call *LiveCall, exten, context string) error {
+	if err := validateLiveCallTarget(exten, context); err != nil {
+		return err
+	}
+	command := fmt.Sprintf("channel redirect %s %s,%s,1", call.CallerChannel, context, exten)
+	if _, err := lm.asterisk.ExecuteCLICommand(command); err != nil {
+		return fmt.Errorf("failed to redirect %s: %v", call.CallerChannel, err)
+	}
+	return nil
+}
+
+// Transfer blind transfers the caller to another extension on behalf of the callee over AMI
+func (lm *LiveCallManager) Transfer(call *LiveCall, exten, context string) error {
+	if err := validateLiveCallTarget(exten, context); err != nil {
+		return err
+	}
+	if !call.Bridged || call.CalleeChannel == "" {
+		return fmt.Errorf("only answered calls can be transferred")
+	}
+	if lm.config == nil || lm.config.AMISecret == "" {
+		return fmt.Errorf("AMI credentials are not configured (ASTERISK_AMI_SECRET)")
+	}
+	client, err := DialAMI(lm.config.AMIHost, lm.config.AMIPort, lm.config.AMIUsername, lm.config.AMISecret, amiDialTimeout)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	_, err = client.Action("BlindTransfer", "Channel", call.CalleeChannel, "Context", context, "Exten", exten)
+	return err
+}
+
+// Spy calls a supervisor extension and connects it to ChanSpy on the call; whisper lets
+// the supervisor talk to the local party without the remote party hearing
+func (lm *LiveCallManager) Spy(call *LiveCall, supervisor string, whisper bool) error {
+	if err := validateLiveCallTarget(supervisor, DefaultLiveCallContext); err != nil {
+		return err
+	}
+	options := "q"
+	if whisper {
+		options = "qw"
+	}
+	command := fmt.Sprintf("channel originate PJSIP/%s application ChanSpy %s,%s", supervisor, liveCallSpyTarget(call), options)
+	if _, err := lm.asterisk.ExecuteCLICommand(command); err != nil {
+		return fmt.Errorf("failed to call supervisor %s: %v", supervisor, err)
+	}
+	return nil
+}
+
+// liveCallSpyTarget returns the leg to spy on, preferring the local extension over a trunk
+func liveCallSpyTarget(call *LiveCall) string {
+	if call.Trunk != "" && channelEndpoint(call.CallerChannel) == call.Trunk && call.CalleeChannel != "" {
+		return call.CalleeChannel
+	}
+	return call.CallerChannel
+}
+
+// StartRecording starts MixMonitor on the caller channel and returns the file name
+func (lm *LiveCallManager) StartRecording(call *LiveCall) (string, error) {
+	file := fmt.Sprintf("live-%s.wav", strings.NewReplacer("/", "-", " ", "").Replace(call.ID))
+	if _, err := lm.asterisk.ExecuteCLICommand(fmt.Sprintf("mixmonitor start %s %s", call.CallerChannel, file)); err != nil {
+		return "", fmt.Errorf("failed to start recording: %v", err)
+	}
+	return file, nil
+}
+
+// StopRecording stops MixMonitor on the caller channel
+func (lm *LiveCallManager) StopRecording(call *LiveCall) error {
+	if _, err := lm.asterisk.ExecuteCLICommand("mixmonitor stop " + call.CallerChannel); err != nil {
+		return fmt.Errorf("failed to stop recording: %v", err)
+	}
+	return nil
+}
+
+// validateLiveCallTarget checks an extension and context before they reach the CLI
+func validateLiveCallTarget(exten, context string) error {
+	if !liveCallTargetPattern.MatchString(exten) {
+		return fmt.Errorf("invalid extension %q", exten)
+	}
+	if !liveCallTargetPattern.MatchString(context) {
+		return fmt.Errorf("invalid context %q", context)
+	}
+	return nil
+}
+
+// liveCallsTickMsg triggers a refresh of the live calls view
+type liveCallsTickMsg struct {
+	id int
+}
+
+// liveCallsTick schedules the next refresh of the live calls view
+func liveCallsTick(id int) tea.Cmd {
+	return tea.Tick(liveCallsRefreshInterval, func(time.Time) tea.Msg {
+		return liveCallsTickMsg{id: id}
+	})
+}
+
+// liveCallManager returns a manager for the server currently being managed
+func (m *model) liveCallManager() *LiveCallManager {
+	return NewLiveCallManager(m.asteriskManager, m.config)
+}
+
+// initLiveCalls opens the live calls view and starts refreshing it
+func (m *model) initLiveCalls() tea.Cmd {
+	m.currentScreen = liveCallsScreen
+	m.selectedLiveCallIdx = 0
+	m.errorMsg = ""
+	m.successMsg = ""
+	if m.liveRecordings == nil {
+		m.liveRecordings = make(map[string]string)
+	}
+	if m.db != nil {
+		if trunks, err := GetTrunks(m.db); err == nil {
+			m.trunks = trunks
+		}
+	}
+	m.refreshLiveCalls()
+	m.liveCallsTickID++
+	return liveCallsTick(m.liveCallsTickID)
+}
+
+// refreshLiveCalls reloads the calls, keeping the selected call selected
+func (m *model) refreshLiveCalls() {
+	var selected string
+	if call := m.selectedLiveCall(); call != nil {
+		selected = call.CallerChannel
+	}
+
+	var trunks []string
+	for _, t := range m.trunks {
+		trunks = append(trunks, t.Name)
+	}
+	calls, err := m.liveCallManager().List(trunks)
+	if err != nil {
+		m.errorMsg = err.Error()
+		return
+	}
+	m.liveCalls = calls
+	m.liveCallsUpdated = time.Now()
+
+	m.selectedLiveCallIdx = 0
+	active := make(map[string]bool)
+	for i, call := range calls {
+		active[call.CallerChannel] = true
+		if call.CallerChannel == selected {
+			m.selectedLiveCallIdx = i
+		}
+	}
+	for channel := range m.liveRecordings {
+		if !active[channel] {
+			delete(m.liveRecordings, channel)
+		}
+	}
+}
+
+// selectedLiveCall returns the highlighted call, or nil if there are none
+func (m *model) selectedLiveCall() *LiveCall {
+	if m.selectedLiveCallIdx < len(m.liveCalls) {
+		return m.liveCalls[m.selectedLiveCallIdx]
+	}
+	return nil
+}
+
+// initLiveCallAction opens the destination form for an action on the selected call
+func (m *model) initLiveCallAction(action string) {
+	if m.selectedLiveCall() == nil {
+		m.errorMsg = "No call selected"
+		return
+	}
+	m.liveCallAction = action
+	m.inputMode = true
+	m.inputCursor = 0
+	m.errorMsg = ""
+	m.successMsg = ""
+	switch action {
+	case liveCallSpy, liveCallWhisper:
+		m.inputFields = []string{"Supervisor extension"}
+		m.inputValues = []string{""}
+	default:
+		m.inputFields = []string{"Destination extension", "Context"}
+		m.inputValues = []string{"", DefaultLiveCallContext}
+	}
+}
+
+// executeLiveCallAction runs the action from the destination form
+func (m *model) executeLiveCallAction() {
+	call := m.selectedLiveCall()
+	if call == nil {
+		m.inputMode = false
+		m.errorMsg = "The call has ended"
+		return
+	}
+	target := strings.TrimSpace(m.inputValues[0])
+	lm := m.liveCallManager()
+
+	var err error
+	var message string
+	switch m.liveCallAction {
+	case liveCallRedirect:
+		context := strings.TrimSpace(m.inputValues[1])
+		err = lm.Redirect(call, target, context)
+		message = fmt.Sprintf("Redirected %s to %s@%s", call.Caller, target, context)
+	case liveCallTransfer:
+		context := strings.TrimSpace(m.inputValues[1])
+		err = lm.Transfer(call, target, context)
+		message = fmt.Sprintf("Transferred %s to %s@%s", call.Caller, target, context)
+	case liveCallSpy, liveCallWhisper:
+		whisper := m.liveCallAction == liveCallWhisper
+		err = lm.Spy(call, target, whisper)
+		message = fmt.Sprintf("Calling %s to listen to %s", target, liveCallSpyTarget(call))
+		if whisper {
+			message = fmt.Sprintf("Calling %s to whisper to %s", target, liveCallSpyTarget(call))
+		}
+	}
+	if err != nil {
+		m.errorMsg = err.Error()
+		return
+	}
+	m.inputMode = false
+	m.errorMsg = ""
+	m.successMsg = message
+	m.refreshLiveCalls()
+}
+
+// toggleLiveCallRecording starts or stops recording the selected call
+func (m *model) toggleLiveCallRecording() {
+	call := m.selectedLiveCall()
+	if call == nil {
+		m.errorMsg = "No call selected"
+		return
+	}
+	lm := m.liveCallManager()
+	if file, ok := m.liveRecordings[call.CallerChannel]; ok {
+		if err := lm.StopRecording(call); err != nil {
+			m.errorMsg = err.Error()
+			return
+		}
+		delete(m.liveRecordings, call.CallerChannel)
+		m.errorMsg = ""
+		m.successMsg = fmt.Sprintf("Stopped recording to %s", file)
+		return
+	}
+	file, err := lm.StartRecording(call)
+	if err != nil {
+		m.errorMsg = err.Error()
+		return
+	}
+	m.liveRecordings[call.CallerChannel] = file
+	m.errorMsg = ""
+	m.successMsg = fmt.Sprintf("Recording to %s", file)
+}
+
+// handleLiveCallsScreen handles keys on the live calls view
+func (m *model) handleLiveCallsScreen(msg tea.KeyMsg) (*model, tea.Cmd) {
+	switch msg.String() {
+	case "up", "k":
+		if m.selectedLiveCallIdx > 0 {
+			m.selectedLiveCallIdx--
+		} else if len(m.liveCalls) > 0 {
+			m.selectedLiveCallIdx = len(m.liveCalls) - 1
+		}
+	case "down", "j":
+		if m.selectedLiveCallIdx < len(m.liveCalls)-1 {
+			m.selectedLiveCallIdx++
+		} else {
+			m.selectedLiveCallIdx = 0
+		}
+	case "r":
+		m.refreshLiveCalls()
+	case "h":
+		call := m.selectedLiveCall()
+		if call == nil {
+			m.errorMsg = "No call selected"
+			break
+		}
+		if err := m.liveCallManager().Hangup(call); err != nil {
+			m.errorMsg = err.Error()
+			break
+		}
+		m.errorMsg = ""
+		m.successMsg = fmt.Sprintf("Hung up %s → %s", call.Caller, call.Callee)
+		m.refreshLiveCalls()
+	case "d":
+		m.initLiveCallAction(liveCallRedirect)
+	case "t":
+		m.initLiveCallAction(liveCallTransfer)
+	case "s":
+		m.initLiveCallAction(liveCallSpy)
+	case "w":
+		m.initLiveCallAction(liveCallWhisper)
+	case "m":
+		m.toggleLiveCallRecording()
+	case "esc":
+		m.currentScreen = mainMenu
+		m.cursor = m.mainMenuCursor
+		m.errorMsg = ""
+		m.successMsg = ""
+	case "q":
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// renderLiveCalls renders the live calls view or the action form
+func (m model) renderLiveCalls() string {
+	content := infoStyle.Render("📞 Live Calls") + "\n\n"
+
+	if m.inputMode {
+		if call := m.selectedLiveCall(); call != nil {
+			content += fmt.Sprintf("%s: %s → %s\n\n", liveCallActionTitles[m.liveCallAction], call.Caller, call.Callee)
+		}
+		for i, field := range m.inputFields {
+			cursor := "  "
+			fieldStyle := lipgloss.NewStyle()
+			if i == m.inputCursor {
+				cursor = "▶ "
+				fieldStyle = selectedItemStyle
+			}
+			value := m.inputValues[i]
+			if value == "" {
+				value = helpStyle.Render("<empty>")
+			}
+			content += fmt.Sprintf("%s%s: %s\n", cursor, fieldStyle.Render(field), value)
+		}
+		return menuStyle.Render(content)
+	}
+
+	updated := "never"
+	if !m.liveCallsUpdated.IsZero() {
+		updated = m.liveCallsUpdated.Format("15:04:05")
+	}
+	content += fmt.Sprintf("Active Calls: %s • Updated %s\n\n", successStyle.Render(strconv.Itoa(len(m.liveCalls))), updated)
+	if len(m.liveCalls) == 0 {
+		content += "📭 No active calls\n"
+		return menuStyle.Render(content)
+	}
+
+	content += helpStyle.Render(fmt.Sprintf("  %-16s  %-16s  %-8s  %8s  %-6s  %s", "Caller", "Callee", "State", "Duration", "Codec", "Trunk")) + "\n"
+	for i, call := range m.liveCalls {
+		cursor := " "
+		if i == m.selectedLiveCallIdx {
+			cursor = "▶"
+		}
+		line := fmt.Sprintf("%-16s  %-16s  %-8s  %8s  %-6s  %s", call.Caller, call.Callee, call.State,
+			formatCallDuration(call.Duration), call.Codec, call.Trunk)
+		if _, ok := m.liveRecordings[call.CallerChannel]; ok {
+			line += " ⏺"
+		}
+		if i == m.selectedLiveCallIdx {
+			line = selectedItemStyle.Render(line)
+		}
+		content += cursor + " " + line + "\n"
+	}
+
+	if call := m.selectedLiveCall(); call != nil {
+		content += "\n" + helpStyle.Render("Channels: "+strings.Join(call.Channels, ", ")) + "\n"
+	}
+	return menuStyle.Render(content)
+}
+
+// liveCallsHelp returns the key help for the live calls view
+func (m model) liveCallsHelp() string {
+	if m.inputMode {
+		return "↑/↓: Navigate Fields • Enter: Next/Run • ESC: Cancel • q: Quit"
+	}
+	return "↑/↓: Navigate • h: Hang Up • d: Redirect • t: Transfer • s: Spy • w: Whisper • m: Record • r: Refresh • ESC: Back • q: Quit"
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// testChannelsConcise has an internal call, an outbound trunk call, a ringing call and an IVR caller
+const testChannelsConcise = `PJSIP/101-00000001!from-internal!102!2!Up!Dial!PJSIP/102,30!101!!!3!95!bridge-1!1714554000.1
+PJSIP/102-00000002!from-internal!!1!Up!AppDial!(Outgoing Line)!101!!!3!94!bridge-1!1714554000.2
+PJSIP/103-00000003!from-internal!09121234567!3!Up!Dial!PJSIP/09121234567@provider,60!103!!!3!3725!bridge-2!1714554000.3
+PJSIP/provider-00000004!from-trunk!!1!Up!AppDial!(Outgoing Line)!103!!!3!3720!bridge-2!1714554000.4
+PJSIP/104-00000005!from-internal!105!2!Ring!Dial!PJSIP/105,30!104!!!3!5!!1714554000.5
+PJSIP/105-00000006!from-internal!!1!Ringing!AppDial!(Outgoing Line)!104!!!3!5!!1714554000.6
+PJSIP/provider-00000007!from-trunk!s!4!Up!BackGround!ivr-welcome!02188776655!!!3!12!!1714554000.7
+`
+
+// testLiveChannelStats is "pjsip show channelstats" output for the bridged calls
+const testLiveChannelStats = `
+...........Receive......... .........Transmit..........
+ BridgeId ChannelId ........ UpTime.. Codec.   Count    Lost Pct  Jitter   Count    Lost Pct  Jitter RTT....
+===========================================================================================================
+
+ bridge-1 101-00000001       00:01:35 g722      4750       0   0   0.002    4750       0   0   0.001   0.020
+ bridge-2 provider-00000004  01:02:00 alaw    186000       0   0   0.003  186000       0   0   0.002   0.040
+
+Objects found: 2
+`
+
+// installFakeAsterisk puts an asterisk command on PATH that answers the live call
+// queries and logs every command it receives
+func installFakeAsterisk(t *testing.T, concise string) string {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "concise.txt"), []byte(concise), 0644)
+	os.WriteFile(filepath.Join(dir, "channelstats.txt"), []byte(testLiveChannelStats), 0644)
+	script := `#!/bin/sh
+echo "$2" >> "` + dir + `/asterisk.log"
+case "$2" in
+"core show channels concise") cat "` + dir + `/concise.txt" ;;
+"pjsip show channelstats") cat "` + dir + `/channelstats.txt" ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(dir, "asterisk"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return dir
+}
+
+// fakeAsteriskLog returns the commands received by the fake asterisk command
+func fakeAsteriskLog(dir string) []string {
+	data, _ := os.ReadFile(filepath.Join(dir, "asterisk.log"))
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+// TestBuildLiveCalls tests pairing channels into calls
+func TestBuildLiveCalls(t *testing.T) {
+	channels := ParseChannelsConcise(testChannelsConcise + "Not a channel line\n")
+	if len(channels) != 7 || channels[1].Application != "AppDial" || channels[2].Duration != 3725 || channels[0].BridgeID != "bridge-1" {
+		t.Fatalf("Unexpected channels: %+v", channels)
+	}
+
+	codecs := map[string]string{"PJSIP/101-00000001": "g722", "PJSIP/provider-00000004": "alaw"}
+	tests := []struct {
+		name   string
+		trunks []string
+	}{
+		{"known trunks", []string{"Provider"}},
+		{"numeric extensions", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := BuildLiveCalls(channels, codecs, tt.trunks)
+			if len(calls) != 4 {
+				t.Fatalf("Expected 4 calls, got %d", len(calls))
+			}
+			want := []struct {
+				caller, callee, state, codec, trunk, callerChannel, calleeChannel string
+				duration                                                          int
+			}{
+				{"103", "09121234567", "Up", "alaw", "provider", "PJSIP/103-00000003", "PJSIP/provider-00000004", 3725},
+				{"101", "102", "Up", "g722", "", "PJSIP/101-00000001", "PJSIP/102-00000002", 95},
+				{"02188776655", "s", "Up", "", "provider", "PJSIP/provider-00000007", "", 12},
+				{"104", "105", "Ringing", "", "", "PJSIP/104-00000005", "PJSIP/105-00000006", 5},
+			}
+			for i, w := range want {
+				c := calls[i]
+				if c.Caller != w.caller || c.Callee != w.callee || c.State != w.state || c.Codec != w.codec || c.Trunk != w.trunk ||
+					c.CallerChannel != w.callerChannel || c.CalleeChannel != w.calleeChannel || c.Duration != w.duration {
+					t.Errorf("Call %d: unexpected %+v", i, c)
+				}
+			}
+			if !calls[0].Bridged || calls[0].ID != "bridge-2" || calls[3].Bridged || calls[3].ID != "1714554000.5" {
+				t.Errorf("Unexpected call IDs: %s %s", calls[0].ID, calls[3].ID)
+			}
+		})
+	}
+
+	if got := formatCallDuration(3725); got != "1:02:05" {
+		t.Errorf("Expected 1:02:05, got %s", got)
+	}
+	if got := formatCallDuration(95); got != "1:35" {
+		t.Errorf("Expected 1:35, got %s", got)
+	}
+}
+
+// TestLiveCallActions tests the commands sent for each action
+func TestLiveCallActions(t *testing.T) {
+	dir := installFakeAsterisk(t, testChannelsConcise)
+	lm := NewLiveCallManager(NewAsteriskManager(), nil)
+	calls, err := lm.List([]string{"provider"})
+	if err != nil || len(calls) != 4 {
+		t.Fatalf("Expected 4 calls, got %d (%v)", len(calls), err)
+	}
+	trunkCall, internalCall := calls[0], calls[1]
+	if trunkCall.Codec != "alaw" || internalCall.Codec != "g722" {
+		t.Errorf("Expected codecs from channelstats, got %q and %q", trunkCall.Codec, internalCall.Codec)
+	}
+
+	if err := lm.Hangup(internalCall); err != nil {
+		t.Fatalf("Hangup failed: %v", err)
+	}
+	if err := lm.Redirect(internalCall, "200", "from-internal"); err != nil {
+		t.Fatalf("Redirect failed: %v", err)
+	}
+	if err := lm.Spy(trunkCall, "150", false); err != nil {
+		t.Fatalf("Spy failed: %v", err)
+	}
+	if err := lm.Spy(internalCall, "150", true); err != nil {
+		t.Fatalf("Whisper failed: %v", err)
+	}
+	file, err := lm.StartRecording(trunkCall)
+	if err != nil || file != "live-bridge-2.wav" {
+		t.Fatalf("StartRecording returned %q, %v", file, err)
+	}
+	if err := lm.StopRecording(trunkCall); err != nil {
+		t.Fatalf("StopRecording failed: %v", err)
+	}
+
+	want := []string{
+		"core show channels concise",
+		"pjsip show channelstats",
+		"channel request hangup PJSIP/101-00000001",
+		"channel request hangup PJSIP/102-00000002",
+		"channel redirect PJSIP/101-00000001 from-internal,200,1",
+		"channel originate PJSIP/150 application ChanSpy PJSIP/103-00000003,q",
+		"channel originate PJSIP/150 application ChanSpy PJSIP/101-00000001,qw",
+		"mixmonitor start PJSIP/103-00000003 live-bridge-2.wav",
+		"mixmonitor stop PJSIP/103-00000003",
+	}
+	if got := fakeAsteriskLog(dir); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected commands:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	for _, target := range []string{"", "200 extra", "200;reboot"} {
+		if err := lm.Redirect(internalCall, target, "from-internal"); err == nil {
+			t.Errorf("Expected invalid extension %q to be rejected", target)
+		}
+	}
+	if err := lm.Redirect(internalCall, "200", "from internal"); err == nil {
+		t.Error("Expected invalid context to be rejected")
+	}
+	if err := lm.Transfer(calls[3], "200", "from-internal"); err == nil || !strings.Contains(err.Error(), "only answered calls") {
+		t.Errorf("Expected ringing call transfer to fail, got %v", err)
+	}
+	if err := lm.Transfer(internalCall, "200", "from-internal"); err == nil || !strings.Contains(err.Error(), "AMI credentials") {
+		t.Errorf("Expected missing AMI credentials, got %v", err)
+	}
+}
+
+// TestLiveCallTransfer tests blind transfers over AMI
+func TestLiveCallTransfer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	actions := make(chan AMIMessage, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		conn.Write([]byte("Asterisk Call Manager/7.0.3\r\n"))
+		readAMIMessage(reader)
+		conn.Write([]byte("Response: Success\r\nMessage: Authentication accepted\r\n\r\n"))
+		action, err := readAMIMessage(reader)
+		if err != nil {
+			return
+		}
+		actions <- action
+		conn.Write([]byte("Event: Newchannel\r\nChannel: PJSIP/200-00000009\r\n\r\n"))
+		conn.Write([]byte("Response: Success\r\nActionID: " + action["ActionID"] + "\r\nMessage: Transfer succeeded\r\n\r\n"))
+		readAMIMessage(reader)
+	}()
+
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	lm := NewLiveCallManager(NewAsteriskManager(), &Config{AMIHost: host, AMIPort: port, AMIUsername: "admin", AMISecret: "secret"})
+	call := &LiveCall{CallerChannel: "PJSIP/101-00000001", CalleeChannel: "PJSIP/102-00000002", Bridged: true}
+	if err := lm.Transfer(call, "200", "from-internal"); err != nil {
+		t.Fatalf("Transfer failed: %v", err)
+	}
+	action := <-actions
+	if action["Action"] != "BlindTransfer" || action["Channel"] != "PJSIP/102-00000002" || action["Exten"] != "200" || action["Context"] != "from-internal" {
+		t.Errorf("Unexpected AMI action: %v", action)
+	}
+}
+
+// TestLiveCallsScreen tests refreshing and acting on calls from the TUI
+func TestLiveCallsScreen(t *testing.T) {
+	dir := installFakeAsterisk(t, testChannelsConcise)
+	m := initialModel(nil, nil, false)
+	if cmd := m.initLiveCalls(); cmd == nil {
+		t.Fatal("Expected a refresh command")
+	}
+	if m.currentScreen != liveCallsScreen || len(m.liveCalls) != 4 {
+		t.Fatalf("Expected 4 live calls, got %d (%s)", len(m.liveCalls), m.errorMsg)
+	}
+	view := m.renderLiveCalls()
+	if !strings.Contains(view, "Active Calls: ") || !strings.Contains(view, "09121234567") || !strings.Contains(view, "1:02:05") {
+		t.Errorf("Unexpected view:\n%s", view)
+	}
+
+	// The selection follows the call across refreshes
+	m.handleLiveCallsScreen(tea.KeyMsg{Type: tea.KeyDown})
+	os.WriteFile(filepath.Join(dir, "concise.txt"), []byte(strings.Join(strings.Split(testChannelsConcise, "\n")[:2], "\n")), 0644)
+	updated, cmd := m.Update(liveCallsTickMsg{id: m.liveCallsTickID})
+	m = updated.(model)
+	if cmd == nil || len(m.liveCalls) != 1 || m.selectedLiveCall().CallerChannel != "PJSIP/101-00000001" {
+		t.Fatalf("Expected refreshed call list with the same selection, got %d calls", len(m.liveCalls))
+	}
+	if _, cmd := m.Update(liveCallsTickMsg{id: m.liveCallsTickID - 1}); cmd != nil {
+		t.Error("Expected stale refresh loops to stop")
+	}
+
+	m.handleLiveCallsScreen(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("m")})
+	if m.liveRecordings["PJSIP/101-00000001"] != "live-bridge-1.wav" || !strings.Contains(m.renderLiveCalls(), "⏺") {
+		t.Errorf("Expected recording to start, got %q %q", m.successMsg, m.errorMsg)
+	}
+
+	m.handleLiveCallsScreen(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("d")})
+	if !m.inputMode || m.liveCallAction != liveCallRedirect || m.inputValues[1] != DefaultLiveCallContext {
+		t.Fatalf("Expected redirect form, got %v", m.inputFields)
+	}
+	m.inputValues[0] = "200"
+	m.inputCursor = 1
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = updated.(model)
+	if m.inputMode || !strings.Contains(m.successMsg, "Redirected 101 to 200@from-internal") {
+		t.Errorf("Expected redirect, got %q %q", m.successMsg, m.errorMsg)
+	}
+
+	m.handleLiveCallsScreen(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.currentScreen != mainMenu {
+		t.Error("Expected ESC to return to the main menu")
+	}
+	if _, cmd := m.Update(liveCallsTickMsg{id: m.liveCallsTickID}); cmd != nil {
+		t.Error("Expected refreshing to stop after leaving the view")
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
@@ -10,6 +11,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -72,12 +74,15 @@ const (
 	extFieldNumber = iota
 	extFieldName
 	extFieldPassword
+	extFieldProfile
 	extFieldCodecs
 	extFieldContext
 	extFieldTransport
 	extFieldDirectMedia
 	extFieldMaxContacts
 	extFieldQualifyFreq
+	extFieldMediaEncryption
+	extFieldNAT
 )
 
 // Field indices for trunk creation form
@@ -169,6 +174,24 @@ const (
 	resetConfirmScreen
 	consolePhoneScreen // Console as SIP phone/intercom
 	dialplanScreen     // Dialplan management
+	freePBXImportScreen // FreePBX/Issabel configuration import
+	extensionProfilesScreen    // Extension profiles (PJSIP templates)
+	extensionProfileEditScreen // Create/edit an extension profile
+	bulkExtensionsScreen // Bulk CSV/JSON extension import/export
+	tlsTransportScreen   // PJSIP transports with SIP over TLS
+	natWizardScreen      // NAT traversal wizard
+	sipLadderScreen      // SIP ladder diagrams from captures
+	callQualityScreen    // RTP quality statistics per call
+	serversScreen        // Multi-server switcher and aggregated status
+	cdrScreen            // Call history browser and reports
+	liveCallsScreen      // Live calls with per-channel actions
+	provisioningScreen   // Zero-touch provisioning server
+	phoneInventoryScreen // Phone inventory and line assignments
+	phoneBulkScreen      // Bulk operations on many phones
+	firmwareScreen       // Firmware repository, policy and staged upgrades
+	phoneBackupsScreen   // Config snapshots, diffs and restores of one phone
+	phoneKeysScreen      // Programmable key layout of one phone or profile
+	phonebookScreen      // Company phonebook served to phones
 )
 
 type model struct {
@@ -295,6 +318,122 @@ type model struct {
 	dialplanMenu          []string // Menu items for dialplan operations
 	dialplanOutput        string   // Output from dialplan operations
 	dialplanPreview       string   // Preview of current dialplan
+
+	// FreePBX import
+	freePBXImportPlan     *FreePBXImportPlan // Dry-run plan waiting for confirmation
+	freePBXImportOutput   string             // Dry-run report or import result
+
+	// Extension profiles
+	extensionProfiles     []ExtensionProfile // Profiles loaded from the database
+	selectedProfileIdx    int                // Selected profile in the profiles list
+	editingProfile        *ExtensionProfile  // Profile being edited, nil when creating one
+
+	// Bulk extension import/export
+	bulkImportPreview     *BulkImportPreview // Validated rows waiting for confirmation
+	bulkExtensionsOutput  string             // Preview report or export result
+
+	// SIP ladder viewer
+	ladderFlows           []*SIPCallFlow // Call flows of the loaded capture
+	ladderFlowIdx         int            // Selected call flow
+	ladderMsgIdx          int            // Selected message in the ladder
+	ladderView            int            // Call list, ladder or message detail
+
+	// Call quality
+	callQualityReport     *CallQualityReport // Last collected statistics
+	callQualityHistory    bool               // Show recent calls instead of active ones
+	callQualityNote       string             // RTCP event stream status
+
+	// Multi-server mode
+	serverInventory    *ServerInventory  // Servers from the inventory file
+	serverInventoryErr error             // Error loading the inventory, shown on the switcher
+	serverConnection   *ServerConnection // Active remote server, nil while managing the local one
+	localConnection    *ServerConnection // Original setup, kept to switch back
+	selectedServerIdx  int
+	serverStatuses     []ServerStatus // Last aggregated status collection
+	serverStatusView   bool           // Show aggregated status instead of the switcher
+
+	// Call history
+	cdrRecords     []CDRRecord           // Records matching the last filter
+	cdrSummary     []CDRExtensionSummary // Per-extension totals of the loaded records
+	cdrSource      string                // Table or file the records came from
+	cdrExportPath  string                // CSV file written on export
+	selectedCDRIdx int
+	cdrSummaryView bool // Show the per-extension summary instead of records
+
+	// Live calls
+	liveCalls           []*LiveCall
+	selectedLiveCallIdx int
+	liveCallsUpdated    time.Time
+	liveCallAction      string            // Action of the open destination form
+	liveRecordings      map[string]string // Caller channel to MixMonitor file
+	liveCallsTickID     int               // Identifies the current refresh loop
+
+	// Zero-touch provisioning
+	provisioningServer      *ProvisioningServer // Running or last started server, nil before the first start
+	provisioningView        int                 // Request log, DHCP snippets or rollout results
+	provisioningHosts       []string            // Addresses the server can be announced on, configured host first
+	provisioningHostIdx     int
+	provisioningRollout     []ProvisioningRolloutResult // Per-phone results of the last rollout
+	provisioningRolloutBusy bool
+
+	// Phone inventory
+	inventoryPhones      []InventoryPhone
+	selectedInventoryIdx int
+	inventoryHistory     []PhoneIPHistoryEntry // Addresses of the selected phone
+
+	// Bulk phone operations
+	bulkAllPhones      []BulkPhone
+	bulkVisible        []BulkPhone // Phones matching bulkFilter
+	bulkSelected       map[string]bool
+	bulkCursor         int
+	bulkFilter         PhoneFilter
+	bulkSavedFilters   []PhoneFilter
+	bulkSavedFilterIdx int // -1 when no saved filter is applied
+	bulkForm           int // Filter or action form while in input mode
+	bulkUpdates        chan tea.Msg
+	bulkRunning        bool
+	bulkTotal          int
+	bulkResults        []BulkPhoneResult // Finished phones, in completion order while running
+	bulkReport         *BulkReport
+	bulkReportPath     string
+
+	// Firmware repository and staged upgrades
+	firmwareView       int // Compliance or repository
+	firmwareImages     []FirmwareImage
+	firmwarePolicy     []FirmwareTarget
+	firmwareCompliance []FirmwareComplianceEntry
+	firmwareCursor     int
+	firmwareForm       int // Import, target or upgrade form while in input mode
+	firmwareUpdates    chan tea.Msg
+	firmwareRunning    bool
+	firmwareTotal      int
+	firmwareResults    []BulkPhoneResult // Finished phones, in completion order while running
+	firmwareReport     *BulkReport
+	firmwareReportPath string
+
+	// Phone config snapshots
+	backupPhone      BulkPhone // Phone whose snapshots are shown
+	backupSnapshots  []PhoneConfigSnapshot // Newest first
+	backupCursor     int
+	backupDiff       []ConfigChange
+	backupDiffTitle  string // Set while a diff is shown
+	backupDiffOffset int
+	backupBusy       bool
+
+	// Programmable key layouts
+	keyLayouts       KeyLayouts
+	keyLayoutPhone   BulkPhone // Phone whose keys are edited
+	keyLayoutProfile string    // Profile being edited, "" while editing the phone
+	keyLayoutCursor  int
+	keyLayoutForm    int // Key or profile form while in input mode
+	keyLayoutEditing int // Position of the key being edited, 0 when adding one
+	keyLayoutHints   map[string]string // BLF targets from the dialplan hints, nil when unknown
+	keyLayoutBusy    bool
+
+	// Company phonebook
+	phonebookEntries []PhonebookEntry // Extensions and contacts, sorted by name
+	phonebookCursor  int
+	phonebookEditing string // Name of the contact being edited, "" when adding one
 }
 
 // isDiagnosticsInputScreen returns true if the current screen is a diagnostics input screen
@@ -305,7 +444,8 @@ func (m model) isDiagnosticsInputScreen() bool {
 		m.currentScreen == diagPortTestScreen ||
 		m.currentScreen == sipTestRegisterScreen ||
 		m.currentScreen == sipTestCallScreen ||
-		m.currentScreen == sipTestFullScreen
+		m.currentScreen == sipTestFullScreen ||
+		m.currentScreen == natWizardScreen
 }
 
 // getSelectedExtension returns the currently selected extension based on the display mode.
@@ -357,9 +497,25 @@ func initialModel(db *sql.DB, config *Config, verbose bool) model {
 	asteriskManager := NewAsteriskManager()
 	diagnosticsManager := NewDiagnosticsManager(asteriskManager)
 	configManager := NewAsteriskConfigManager(verbose)
+	diagnosticsManager.configManager = configManager
 	extensionSyncManager := NewExtensionSyncManager(db, asteriskManager, configManager)
+	if db != nil {
+		diagnosticsManager.db = db
+		diagnosticsManager.syncManager = extensionSyncManager
+	}
 	resetConfiguration := NewResetConfiguration(db, configManager, asteriskManager, verbose)
 	
+	// Profiles must be known before any extension config is generated
+	var extensionProfiles []ExtensionProfile
+	if db != nil {
+		if profiles, err := GetExtensionProfiles(db); err == nil {
+			extensionProfiles = profiles
+			configManager.SetExtensionProfiles(profiles)
+		}
+	}
+	
+	serverInventory, serverInventoryErr := LoadServerInventory(serversFilePath())
+
 	return model{
 		currentScreen: mainMenu,
 		menuItems: []string{
@@ -386,7 +542,10 @@ func initialModel(db *sql.DB, config *Config, verbose bool) model {
 		diagnosticsManager:    diagnosticsManager,
 		configManager:         configManager,
 		extensionSyncManager:  extensionSyncManager,
+		extensionProfiles:     extensionProfiles,
 		resetConfiguration:    resetConfiguration,
+		serverInventory:       serverInventory,
+		serverInventoryErr:    serverInventoryErr,
 		verbose:               verbose,
 		liveConsoleVerbosity:  5,
 		liveConsoleMaxLines:   500,
@@ -416,6 +575,10 @@ func initialModel(db *sql.DB, config *Config, verbose bool) model {
 			"🔗 Test Trunk Connectivity",
 			"🛣️  Test Call Routing",
 			"🌐 Test Port Connectivity",
+			"🔐 Check WebRTC (WSS/DTLS)",
+			"🧭 NAT Traversal Wizard",
+			"🪜 SIP Ladder Viewer",
+			"📶 Call Quality (RTP/RTCP)",
 			"🧪 SIP Testing Suite",
 			"🔙 Back to Main Menu",
 		},
@@ -566,7 +729,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		   m.currentScreen == voipPhoneControlScreen || m.currentScreen == voipPhoneProvisionScreen {
 			// Handle VoIP-specific keys first
 			switch msg.String() {
-			case "a", "m", "c", "r", "p", "e", "A", "d", "left", "right", "h", "l":
+			case "a", "m", "c", "r", "p", "e", "A", "d", "i", "b", "F", "left", "right", "h", "l":
 				m.handleVoIPPhonesKeyPress(msg.String())
 				return m, nil
 			}
@@ -594,6 +757,71 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if m.currentScreen == dialplanScreen {
 			return m.handleDialplanScreen(msg)
 		}
+
+		// Handle extension profiles list
+		if m.currentScreen == extensionProfilesScreen {
+			return m.handleExtensionProfilesScreen(msg)
+		}
+
+		// Handle SIP ladder viewer while browsing call flows
+		if m.currentScreen == sipLadderScreen && !m.inputMode {
+			return m.handleSIPLadderScreen(msg)
+		}
+
+		// Handle call quality screen
+		if m.currentScreen == callQualityScreen {
+			return m.handleCallQualityScreen(msg)
+		}
+
+		// Handle server switcher
+		if m.currentScreen == serversScreen {
+			return m.handleServersScreen(msg)
+		}
+
+		// Handle call history while browsing records
+		if m.currentScreen == cdrScreen && !m.inputMode {
+			return m.handleCDRScreen(msg)
+		}
+
+		// Handle live calls view
+		if m.currentScreen == liveCallsScreen && !m.inputMode {
+			return m.handleLiveCallsScreen(msg)
+		}
+
+		// Handle provisioning server screen
+		if m.currentScreen == provisioningScreen {
+			return m.handleProvisioningScreen(msg)
+		}
+
+		// Handle phone inventory list
+		if m.currentScreen == phoneInventoryScreen && !m.inputMode {
+			return m.handlePhoneInventoryScreen(msg)
+		}
+
+		// Handle bulk phone operations outside the filter and action forms
+		if m.currentScreen == phoneBulkScreen && !m.inputMode {
+			return m.handlePhoneBulkScreen(msg)
+		}
+
+		// Handle the firmware screen outside its forms
+		if m.currentScreen == firmwareScreen && !m.inputMode {
+			return m.handleFirmwareScreen(msg)
+		}
+
+		// Handle the config snapshots screen outside the restore form
+		if m.currentScreen == phoneBackupsScreen && !m.inputMode {
+			return m.handlePhoneBackupsScreen(msg)
+		}
+
+		// Handle the key layout editor outside its forms
+		if m.currentScreen == phoneKeysScreen && !m.inputMode {
+			return m.handlePhoneKeysScreen(msg)
+		}
+
+		// Handle the phonebook outside the contact form
+		if m.currentScreen == phonebookScreen && !m.inputMode {
+			return m.handlePhonebookScreen(msg)
+		}
 		
 		// Handle VoIP discovery screen
 		if m.currentScreen == voipDiscoveryScreen {
@@ -956,7 +1184,9 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		
 		case "S":
 			// Open Sync Screen (uppercase S to avoid conflict with 's' for SIP debug)
-			if m.currentScreen == extensionsScreen {
+			if m.currentScreen == mainMenu {
+				m.initServers()
+			} else if m.currentScreen == extensionsScreen {
 				m.loadExtensionSyncInfo()
 				m.currentScreen = extensionSyncScreen
 				m.cursor = 0
@@ -964,11 +1194,47 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.errorMsg = ""
 				m.successMsg = ""
 			}
+
+		case "C":
+			// Open call history
+			if m.currentScreen == mainMenu {
+				m.initCDRBrowser()
+			}
+
+		case "L":
+			// Open live calls
+			if m.currentScreen == mainMenu {
+				return m, m.initLiveCalls()
+			}
 		
+		case "I":
+			// Import extensions, trunks and routes from FreePBX/Issabel
+			if m.currentScreen == extensionsScreen {
+				m.initFreePBXImport()
+			}
+
+		case "P":
+			// Manage extension profiles, or open the provisioning server from the main menu
+			if m.currentScreen == extensionsScreen {
+				m.initExtensionProfiles()
+			} else if m.currentScreen == mainMenu {
+				m.initProvisioning()
+			}
+
+		case "B":
+			// Bulk import/export extensions from CSV or JSON
+			if m.currentScreen == extensionsScreen {
+				m.initBulkExtensions()
+			}
+
 		case "y":
 			// Confirm deletion
 			if m.currentScreen == deleteExtensionScreen {
 				m.deleteExtension()
+			} else if m.currentScreen == freePBXImportScreen {
+				m.applyFreePBXImport()
+			} else if m.currentScreen == bulkExtensionsScreen {
+				m.applyBulkExtensions()
 			} else if m.currentScreen == resetConfirmScreen {
 				// Execute the reset
 				m.executeResetConfiguration()
@@ -1113,7 +1379,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.errorMsg = ""
 					m.successMsg = ""
 					m.diagnosticsOutput = ""
-				} else if m.currentScreen == extensionSyncScreen {
+				} else if m.currentScreen == extensionSyncScreen || m.currentScreen == freePBXImportScreen || m.currentScreen == bulkExtensionsScreen {
 					m.currentScreen = extensionsScreen
 					m.cursor = 0
 					m.errorMsg = ""
@@ -1208,6 +1474,46 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 
+	case provisioningRolloutMsg:
+		m.finishProvisioningRollout(msg.results)
+		return m, nil
+
+	case phoneInventoryProvisionMsg:
+		m.finishInventoryProvision(msg.result)
+		return m, nil
+
+	case phoneBulkProgressMsg:
+		return m, m.recordBulkProgress(msg.result)
+
+	case phoneBulkDoneMsg:
+		m.finishPhoneBulk(msg.report)
+		return m, nil
+
+	case firmwareProgressMsg:
+		return m, m.recordFirmwareProgress(msg.result)
+
+	case firmwareDoneMsg:
+		m.finishFirmwareUpgrade(msg.report)
+		return m, nil
+
+	case phoneBackupMsg:
+		m.finishPhoneBackupAction(msg)
+		return m, nil
+
+	case keyLayoutPushMsg:
+		m.finishKeyLayoutPush(msg)
+		return m, nil
+
+	case liveCallsTickMsg:
+		// Refresh the live calls view in place until it is left
+		if m.currentScreen != liveCallsScreen || msg.id != m.liveCallsTickID {
+			return m, nil
+		}
+		if !m.inputMode {
+			m.refreshLiveCalls()
+		}
+		return m, liveCallsTick(msg.id)
+
 	case commandFinishedMsg:
 		// Handle completion of external command execution
 		if msg.err != nil {
@@ -1235,6 +1541,9 @@ func (m model) View() string {
 	// Header with emojis
 	header := titleStyle.Render("🎯 RayanPBX - Modern SIP Server Management 🚀")
 	s += header + "\n\n"
+	if m.serverConnection != nil {
+		s += warningStyle.Render("🖥️  Managing server: "+m.serverConnection.Profile.Name) + "\n\n"
+	}
 
 	// Show error if any
 	if m.errorMsg != "" {
@@ -1337,16 +1646,86 @@ func (m model) View() string {
 		s += m.renderConsolePhone()
 	case dialplanScreen:
 		s += m.renderDialplanScreen()
+	case freePBXImportScreen:
+		s += m.renderFreePBXImport()
+	case bulkExtensionsScreen:
+		s += m.renderBulkExtensions()
+	case extensionProfilesScreen:
+		s += m.renderExtensionProfiles()
+	case extensionProfileEditScreen:
+		s += m.renderExtensionProfileForm()
+	case tlsTransportScreen:
+		s += m.renderTLSTransportForm()
+	case natWizardScreen:
+		s += m.renderNATWizard()
+	case sipLadderScreen:
+		s += m.renderSIPLadder()
+	case callQualityScreen:
+		s += m.renderCallQuality()
+	case serversScreen:
+		s += m.renderServers()
+	case cdrScreen:
+		s += m.renderCDRBrowser()
+	case liveCallsScreen:
+		s += m.renderLiveCalls()
+	case provisioningScreen:
+		s += m.renderProvisioning()
+	case phoneInventoryScreen:
+		s += m.renderPhoneInventory()
+	case phoneBulkScreen:
+		s += m.renderPhoneBulk()
+	case firmwareScreen:
+		s += m.renderFirmware()
+	case phoneBackupsScreen:
+		s += m.renderPhoneBackups()
+	case phoneKeysScreen:
+		s += m.renderPhoneKeys()
+	case phonebookScreen:
+		s += m.renderPhonebook()
 	}
 
 	// Footer with emojis
 	s += "\n\n"
 	if m.currentScreen == mainMenu {
-		s += helpStyle.Render("↑/↓ or j/k: Navigate • Enter: Select • q: Quit")
+		s += helpStyle.Render("↑/↓ or j/k: Navigate • Enter: Select • S: Servers • C: Call History • L: Live Calls • P: Provisioning • q: Quit")
 	} else if m.currentScreen == extensionsScreen {
-		s += helpStyle.Render("↑/↓: Navigate • a: Add • e: Edit • d: Delete • t: Toggle • i: Info • S: Sync • h: Help • ESC: Back")
+		s += helpStyle.Render("↑/↓: Navigate • a: Add • e: Edit • d: Delete • t: Toggle • i: Info • S: Sync • P: Profiles • I: Import • B: Bulk • h: Help • ESC: Back")
 	} else if m.currentScreen == extensionSyncScreen {
 		s += helpStyle.Render("↑/↓: Navigate • Enter: Select/Execute • ESC: Back to Extensions • q: Quit")
+	} else if m.currentScreen == freePBXImportScreen && !m.inputMode {
+		s += helpStyle.Render("y: Apply Import • ESC: Back to Extensions • q: Quit")
+	} else if m.currentScreen == extensionProfilesScreen {
+		s += helpStyle.Render("↑/↓: Navigate • a: Add • e/Enter: Edit • d: Delete • ESC: Back to Extensions • q: Quit")
+	} else if m.currentScreen == sipLadderScreen {
+		s += helpStyle.Render(m.sipLadderHelp())
+	} else if m.currentScreen == cdrScreen {
+		s += helpStyle.Render(m.cdrHelp())
+	} else if m.currentScreen == liveCallsScreen {
+		s += helpStyle.Render(m.liveCallsHelp())
+	} else if m.currentScreen == provisioningScreen {
+		s += helpStyle.Render(m.provisioningHelp())
+	} else if m.currentScreen == phoneInventoryScreen {
+		s += helpStyle.Render(m.phoneInventoryHelp())
+	} else if m.currentScreen == phoneBulkScreen {
+		s += helpStyle.Render(m.phoneBulkHelp())
+	} else if m.currentScreen == firmwareScreen {
+		s += helpStyle.Render(m.firmwareHelp())
+	} else if m.currentScreen == phoneBackupsScreen {
+		s += helpStyle.Render(m.phoneBackupsHelp())
+	} else if m.currentScreen == phoneKeysScreen {
+		s += helpStyle.Render(m.phoneKeysHelp())
+	} else if m.currentScreen == phonebookScreen {
+		s += helpStyle.Render(m.phonebookHelp())
+	} else if m.currentScreen == serversScreen {
+		if m.serverStatusView {
+			s += helpStyle.Render("r: Refresh • l: Server List • ESC: Back to Main Menu • q: Quit")
+		} else {
+			s += helpStyle.Render("↑/↓: Navigate • Enter: Switch Server • a: Status of All Servers • ESC: Back to Main Menu • q: Quit")
+		}
+	} else if m.currentScreen == callQualityScreen {
+		s += helpStyle.Render("r: Refresh • h: Toggle Active/Recent Calls • ESC: Back to Diagnostics • q: Quit")
+	} else if m.currentScreen == bulkExtensionsScreen && !m.inputMode {
+		s += helpStyle.Render("y: Apply Import • ESC: Back to Extensions • q: Quit")
 	} else if m.currentScreen == extensionInfoScreen {
 		s += helpStyle.Render("r: Reload PJSIP • t: Test Suite • s: SIP Debug • h: Help Guide • ESC: Back • q: Quit")
 	} else if m.currentScreen == sipHelpScreen {
@@ -1956,14 +2335,16 @@ func (m model) renderSipTestRegister() string {
 			case "Password":
 				value = helpStyle.Render("<enter password>")
 			case "Server":
-				value = helpStyle.Render("<server IP (default: 127.0.0.1)>")
+				value = helpStyle.Render("<server IP[:port] (default: 127.0.0.1)>")
+			case "Transport":
+				value = helpStyle.Render("<udp, tcp or tls>")
 			}
 		}
 
 		content += fmt.Sprintf("%s%s: %s\n", cursor, fieldStyle.Render(field), value)
 	}
 
-	content += "\n" + helpStyle.Render("💡 Test SIP registration for an extension")
+	content += "\n" + helpStyle.Render("💡 Sends OPTIONS and a digest-authenticated REGISTER using the built-in SIP client")
 
 	return menuStyle.Render(content)
 }
@@ -1995,14 +2376,16 @@ func (m model) renderSipTestCall() string {
 			case "To Password":
 				value = helpStyle.Render("<destination password>")
 			case "Server":
-				value = helpStyle.Render("<server IP (default: 127.0.0.1)>")
+				value = helpStyle.Render("<server IP[:port] (default: 127.0.0.1)>")
+			case "Transport":
+				value = helpStyle.Render("<udp, tcp or tls>")
 			}
 		}
 
 		content += fmt.Sprintf("%s%s: %s\n", cursor, fieldStyle.Render(field), value)
 	}
 
-	content += "\n" + helpStyle.Render("💡 Test call between two extensions")
+	content += "\n" + helpStyle.Render("💡 Registers both extensions and places a test call; the callee answers automatically")
 
 	return menuStyle.Render(content)
 }
@@ -2394,29 +2777,36 @@ func (m *model) runCommandExternally(command string) tea.Cmd {
 
 // initCreateExtension initializes the extension creation form with advanced PJSIP options
 func (m *model) initCreateExtension() {
+	m.loadExtensionProfiles()
 	m.currentScreen = createExtensionScreen
 	m.inputMode = true
 	m.inputFields = []string{
 		"Extension Number",
 		"Name",
 		"Password",
+		"Profile (optional)",
 		"Codecs (ulaw,alaw,g722)",
 		"Context",
 		"Transport",
 		"Direct Media (yes/no)",
 		"Max Contacts",
 		"Qualify Frequency (sec)",
+		"Media Encryption (no/sdes/dtls)",
+		"NAT Traversal (yes/no)",
 	}
 	m.inputValues = []string{
 		"",                          // Extension Number
 		"",                          // Name
 		"",                          // Password
+		"",                          // Profile
 		DefaultCodecs,               // Codecs
 		DefaultExtensionContext,     // Context
 		DefaultExtensionTransport,   // Transport
 		DefaultDirectMedia,          // Direct Media
 		fmt.Sprintf("%d", DefaultMaxContacts),     // Max Contacts
 		fmt.Sprintf("%d", DefaultQualifyFrequency), // Qualify Frequency
+		"no",                        // Media Encryption
+		"no",                        // NAT Traversal
 	}
 	m.inputCursor = 0
 	m.errorMsg = ""
@@ -2442,18 +2832,22 @@ func (m *model) initEditExtension() {
 		return
 	}
 	
+	m.loadExtensionProfiles()
 	m.currentScreen = editExtensionScreen
 	m.inputMode = true
 	m.inputFields = []string{
 		"Extension Number",
 		"Name",
 		"Password",
+		"Profile (optional)",
 		"Codecs (ulaw,alaw,g722)",
 		"Context",
 		"Transport",
 		"Direct Media (yes/no)",
 		"Max Contacts",
 		"Qualify Frequency (sec)",
+		"Media Encryption (no/sdes/dtls)",
+		"NAT Traversal (yes/no)",
 	}
 	
 	// Get codecs string, default if empty
@@ -2474,17 +2868,25 @@ func (m *model) initEditExtension() {
 		qualifyFreq = DefaultQualifyFrequency
 	}
 	
+	mediaEncryption := ext.MediaEncryption
+	if mediaEncryption == "" {
+		mediaEncryption = "no"
+	}
+	
 	// Pre-fill with current values (password will be empty for security)
 	m.inputValues = []string{
 		ext.ExtensionNumber,
 		ext.Name,
 		"", // Password empty for security
+		ext.Profile,
 		codecs,
 		ext.Context,
 		ext.Transport,
 		directMedia,
 		fmt.Sprintf("%d", ext.MaxContacts),
 		fmt.Sprintf("%d", qualifyFreq),
+		mediaEncryption,
+		yesNo(ext.NAT),
 	}
 	m.inputCursor = 0
 	m.errorMsg = ""
@@ -2497,10 +2899,20 @@ func (m model) handleInputMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case "esc":
 		// Cancel input
 		m.inputMode = false
-		if m.currentScreen == createExtensionScreen || m.currentScreen == editExtensionScreen {
+		if m.currentScreen == createExtensionScreen || m.currentScreen == editExtensionScreen || m.currentScreen == freePBXImportScreen || m.currentScreen == bulkExtensionsScreen {
 			m.currentScreen = extensionsScreen
 		} else if m.currentScreen == createTrunkScreen {
 			m.currentScreen = trunksScreen
+		} else if m.currentScreen == extensionProfileEditScreen {
+			m.currentScreen = extensionProfilesScreen
+			m.editingProfile = nil
+		} else if m.currentScreen == tlsTransportScreen {
+			m.currentScreen = asteriskMenuScreen
+		} else if m.currentScreen == sipLadderScreen && len(m.ladderFlows) == 0 {
+			m.currentScreen = diagnosticsMenuScreen
+			m.cursor = m.diagnosticsMenuCursor
+		} else if m.currentScreen == cdrScreen && m.cdrRecords == nil {
+			m.currentScreen = mainMenu
 		} else if m.currentScreen == usageInputScreen {
 			m.currentScreen = usageScreen
 			m.usageCommandTemplate = ""
@@ -2541,6 +2953,11 @@ func (m model) handleInputMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 
 	case "enter":
+		// Leaving the profile field fills the inherited fields from the profile
+		if (m.currentScreen == createExtensionScreen || m.currentScreen == editExtensionScreen) && m.inputCursor == extFieldProfile {
+			m.applyProfileToExtensionForm()
+		}
+
 		// Move to next field or submit
 		if m.inputCursor < len(m.inputFields)-1 {
 			m.inputCursor++
@@ -2566,14 +2983,42 @@ func (m model) handleInputMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				m.executeSipTestCall()
 			} else if m.currentScreen == sipTestFullScreen {
 				m.executeSipTestFull()
+			} else if m.currentScreen == natWizardScreen {
+				m.executeNATWizard()
+			} else if m.currentScreen == sipLadderScreen {
+				m.loadSIPLadder()
+			} else if m.currentScreen == cdrScreen {
+				m.loadCDRs()
+			} else if m.currentScreen == liveCallsScreen {
+				m.executeLiveCallAction()
 			} else if m.currentScreen == voipManualIPScreen {
 				m.executeManualIPAdd()
 			} else if m.currentScreen == voipPhoneProvisionScreen {
 				m.executeVoIPProvision()
+			} else if m.currentScreen == phoneInventoryScreen {
+				m.savePhoneInventoryEdit()
+			} else if m.currentScreen == phoneBulkScreen {
+				return m, m.handlePhoneBulkInput()
+			} else if m.currentScreen == firmwareScreen {
+				return m, m.handleFirmwareInput()
+			} else if m.currentScreen == phoneBackupsScreen {
+				return m, m.submitPhoneRestoreForm()
+			} else if m.currentScreen == phoneKeysScreen {
+				m.handleKeyLayoutInput()
+			} else if m.currentScreen == phonebookScreen {
+				m.savePhonebookForm()
 			} else if m.currentScreen == usageInputScreen {
 				return m, m.executeParameterizedCommand()
 			} else if m.currentScreen == consolePhoneScreen {
 				m.handleConsolePhoneInput()
+			} else if m.currentScreen == freePBXImportScreen {
+				m.previewFreePBXImport()
+			} else if m.currentScreen == bulkExtensionsScreen {
+				m.executeBulkExtensions()
+			} else if m.currentScreen == extensionProfileEditScreen {
+				m.saveExtensionProfile()
+			} else if m.currentScreen == tlsTransportScreen {
+				m.executeTLSTransportSetup()
 			}
 		}
 
@@ -2602,12 +3047,15 @@ func (m model) renderCreateExtension() string {
 		extFieldNumber:      "Unique extension number (e.g., 100, 101)",
 		extFieldName:        "Display name for the extension",
 		extFieldPassword:    "SIP authentication password (min 8 chars)",
+		extFieldProfile:     "Profile to inherit settings from (Enter fills the fields below)",
 		extFieldCodecs:      "Audio codecs: ulaw (US), alaw (EU), g722 (HD)",
 		extFieldContext:     "Dialplan context (from-internal recommended)",
-		extFieldTransport:   "SIP transport (transport-udp recommended)",
+		extFieldTransport:   "SIP transport (transport-udp recommended, transport-wss for WebRTC)",
 		extFieldDirectMedia: "Allow direct RTP (no=NAT-safe, yes=LAN only)",
 		extFieldMaxContacts: "Max simultaneous registrations (1-10)",
 		extFieldQualifyFreq: "Seconds between keep-alive checks (0=disabled)",
+		extFieldMediaEncryption: "SRTP: no, sdes (use with transport-tls) or dtls",
+		extFieldNAT:         "Phone behind NAT: rewrite contact, force rport, symmetric RTP",
 	}
 
 	for i, field := range m.inputFields {
@@ -2626,7 +3074,7 @@ func (m model) renderCreateExtension() string {
 			value = "********"
 		}
 
-		content += fmt.Sprintf("%s%s: %s\n", cursor, fieldStyle.Render(field), value)
+		content += fmt.Sprintf("%s%s: %s%s\n", cursor, fieldStyle.Render(field), value, m.renderProfileFieldState(i))
 		
 		// Show help for selected field
 		if i == m.inputCursor {
@@ -2742,14 +3190,32 @@ func (m *model) createExtension() {
 	// Parse and validate all extension options
 	maxContacts, qualifyFreq, codecs, context, transport, directMedia := parseExtensionInputValues(m.inputValues)
 	
+	profile := strings.TrimSpace(m.inputValues[extFieldProfile])
+	if profile != "" && m.findExtensionProfile(profile) == nil {
+		m.errorMsg = fmt.Sprintf("Unknown profile: %s", profile)
+		return
+	}
+	
+	mediaEncryption, err := parseMediaEncryption(m.inputValues[extFieldMediaEncryption])
+	if err != nil {
+		m.errorMsg = err.Error()
+		return
+	}
+	
+	nat, err := parseNATTraversal(m.inputValues[extFieldNAT])
+	if err != nil {
+		m.errorMsg = err.Error()
+		return
+	}
+	
 	// Convert codecs to JSON format for database storage
 	codecsJSON := codecsToJSON(codecs)
 
 	// Insert into database with all PJSIP configuration values
-	query := `INSERT INTO extensions (extension_number, name, secret, context, transport, enabled, max_contacts, codecs, direct_media, qualify_frequency, created_at, updated_at)
-			  VALUES (?, ?, ?, ?, ?, 1, ?, ?, ?, ?, NOW(), NOW())`
+	query := `INSERT INTO extensions (extension_number, name, secret, context, transport, enabled, max_contacts, codecs, direct_media, qualify_frequency, profile, media_encryption, nat, created_at, updated_at)
+			  VALUES (?, ?, ?, ?, ?, 1, ?, ?, ?, ?, ?, ?, ?, NOW(), NOW())`
 
-	_, err := m.db.Exec(query, 
+	_, err = m.db.Exec(query, 
 		m.inputValues[extFieldNumber], 
 		m.inputValues[extFieldName], 
 		m.inputValues[extFieldPassword],
@@ -2758,7 +3224,10 @@ func (m *model) createExtension() {
 		maxContacts,
 		codecsJSON,
 		directMedia,
-		qualifyFreq)
+		qualifyFreq,
+		sql.NullString{String: profile, Valid: profile != ""},
+		mediaEncryption,
+		nat)
 	if err != nil {
 		m.errorMsg = fmt.Sprintf("Failed to create extension: %v", err)
 		return
@@ -2776,12 +3245,20 @@ func (m *model) createExtension() {
 		Codecs:           codecs,
 		DirectMedia:      directMedia,
 		QualifyFrequency: qualifyFreq,
+		Profile:          profile,
+		MediaEncryption:  mediaEncryption,
+		NAT:              nat,
 	}
 
 	// Ensure transport configuration exists before writing extension config
 	if err := m.configManager.EnsureTransportConfig(); err != nil {
 		m.errorMsg = fmt.Sprintf("Warning: Failed to ensure transport config: %v", err)
 	}
+	if isWebRTCTransport(ext.Transport) {
+		if err := m.configManager.EnsureWebRTCConfig(); err != nil {
+			m.errorMsg = fmt.Sprintf("Warning: Failed to ensure WebRTC config: %v", err)
+		}
+	}
 
 	// Generate and write PJSIP configuration
 	sections := m.configManager.GeneratePjsipEndpoint(ext)
@@ -2895,6 +3372,25 @@ func (m *model) editExtension() {
 		directMedia = ext.DirectMedia
 	}
 	
+	profile := strings.TrimSpace(m.inputValues[extFieldProfile])
+	if profile != "" && m.findExtensionProfile(profile) == nil {
+		m.errorMsg = fmt.Sprintf("Unknown profile: %s", profile)
+		return
+	}
+	profileValue := sql.NullString{String: profile, Valid: profile != ""}
+	
+	mediaEncryption, err := parseMediaEncryption(m.inputValues[extFieldMediaEncryption])
+	if err != nil {
+		m.errorMsg = err.Error()
+		return
+	}
+	
+	nat, err := parseNATTraversal(m.inputValues[extFieldNAT])
+	if err != nil {
+		m.errorMsg = err.Error()
+		return
+	}
+	
 	// Convert codecs to JSON format for database storage
 	codecsJSON := codecsToJSON(codecs)
 	
@@ -2904,17 +3400,17 @@ func (m *model) editExtension() {
 	
 	if m.inputValues[extFieldPassword] != "" {
 		query = `UPDATE extensions SET extension_number = ?, name = ?, secret = ?, context = ?, transport = ?, 
-		         codecs = ?, direct_media = ?, max_contacts = ?, qualify_frequency = ?, updated_at = NOW() WHERE id = ?`
+		         codecs = ?, direct_media = ?, max_contacts = ?, qualify_frequency = ?, profile = ?, media_encryption = ?, nat = ?, updated_at = NOW() WHERE id = ?`
 		args = []interface{}{newNumber, m.inputValues[extFieldName], m.inputValues[extFieldPassword],
-			context, transport, codecsJSON, directMedia, maxContacts, qualifyFreq, ext.ID}
+			context, transport, codecsJSON, directMedia, maxContacts, qualifyFreq, profileValue, mediaEncryption, nat, ext.ID}
 	} else {
 		query = `UPDATE extensions SET extension_number = ?, name = ?, context = ?, transport = ?, 
-		         codecs = ?, direct_media = ?, max_contacts = ?, qualify_frequency = ?, updated_at = NOW() WHERE id = ?`
+		         codecs = ?, direct_media = ?, max_contacts = ?, qualify_frequency = ?, profile = ?, media_encryption = ?, nat = ?, updated_at = NOW() WHERE id = ?`
 		args = []interface{}{newNumber, m.inputValues[extFieldName],
-			context, transport, codecsJSON, directMedia, maxContacts, qualifyFreq, ext.ID}
+			context, transport, codecsJSON, directMedia, maxContacts, qualifyFreq, profileValue, mediaEncryption, nat, ext.ID}
 	}
 	
-	_, err := m.db.Exec(query, args...)
+	_, err = m.db.Exec(query, args...)
 	if err != nil {
 		m.errorMsg = fmt.Sprintf("Failed to update extension: %v", err)
 		return
@@ -2940,11 +3436,20 @@ func (m *model) editExtension() {
 		Enabled:          ext.Enabled,
 		CallerID:         ext.CallerID,
 		VoicemailEnabled: ext.VoicemailEnabled,
+		Profile:          profile,
+		MediaEncryption:  mediaEncryption,
+		NAT:              nat,
 	}
 	if m.inputValues[extFieldPassword] != "" {
 		updatedExt.Secret = m.inputValues[extFieldPassword]
 	}
 	
+	if isWebRTCTransport(updatedExt.Transport) {
+		if err := m.configManager.EnsureWebRTCConfig(); err != nil {
+			m.errorMsg = fmt.Sprintf("Warning: Failed to ensure WebRTC config: %v", err)
+		}
+	}
+
 	// Generate and write updated config
 	sections := m.configManager.GeneratePjsipEndpoint(updatedExt)
 	if err := m.configManager.WritePjsipConfigSections(sections, fmt.Sprintf("Extension %s", updatedExt.ExtensionNumber)); err != nil {
@@ -3064,6 +3569,9 @@ func (m *model) toggleExtension() {
 		Enabled:          newEnabled, // Updated enabled state
 		CallerID:         ext.CallerID,
 		VoicemailEnabled: ext.VoicemailEnabled,
+		Profile:          ext.Profile,
+		MediaEncryption:  ext.MediaEncryption,
+		NAT:              ext.NAT,
 	}
 	
 	if newEnabled {
@@ -3181,7 +3689,7 @@ func (m model) renderEditExtension() string {
 			value = "********"
 		}
 
-		content += fmt.Sprintf("%s%s: %s\n", cursor, fieldStyle.Render(field), value)
+		content += fmt.Sprintf("%s%s: %s%s\n", cursor, fieldStyle.Render(field), value, m.renderProfileFieldState(i))
 	}
 
 	content += "\n" + helpStyle.Render("💡 Leave password empty to keep current password")
@@ -3428,10 +3936,24 @@ func (m *model) handleDiagnosticsMenuSelection() {
 		m.inputFields = []string{"Host", "Port"}
 		m.inputValues = []string{"", DefaultSIPPort}
 		m.inputCursor = 0
-	case 9: // SIP Testing Suite
+	case 9: // Check WebRTC
+		settings, err := m.configManager.LoadHTTPTLSSettings()
+		if err != nil {
+			m.errorMsg = err.Error()
+			return
+		}
+		m.diagnosticsOutput = m.diagnosticsManager.CheckWebRTC(settings)
+		m.successMsg = "WebRTC check completed"
+	case 10: // NAT Traversal Wizard
+		m.initNATWizard()
+	case 11: // SIP Ladder Viewer
+		m.initSIPLadderViewer()
+	case 12: // Call Quality
+		m.initCallQuality()
+	case 13: // SIP Testing Suite
 		m.currentScreen = sipTestMenuScreen
 		m.cursor = 0
-	case 10: // Back to Main Menu
+	case 14: // Back to Main Menu
 		m.currentScreen = mainMenu
 		m.cursor = m.mainMenuCursor
 	}
@@ -3679,14 +4201,14 @@ func (m *model) handleSipTestMenuSelection() {
 	case 2: // Test Registration
 		m.currentScreen = sipTestRegisterScreen
 		m.inputMode = true
-		m.inputFields = []string{"Extension", "Password", "Server"}
-		m.inputValues = []string{"", "", "127.0.0.1"}
+		m.inputFields = []string{"Extension", "Password", "Server", "Transport"}
+		m.inputValues = []string{"", "", "127.0.0.1", DefaultSIPTransport}
 		m.inputCursor = 0
 	case 3: // Test Call
 		m.currentScreen = sipTestCallScreen
 		m.inputMode = true
-		m.inputFields = []string{"From Extension", "From Password", "To Extension", "To Password", "Server"}
-		m.inputValues = []string{"", "", "", "", "127.0.0.1"}
+		m.inputFields = []string{"From Extension", "From Password", "To Extension", "To Password", "Server", "Transport"}
+		m.inputValues = []string{"", "", "", "", "127.0.0.1", DefaultSIPTransport}
 		m.inputCursor = 0
 	case 4: // Run Full Test Suite
 		m.currentScreen = sipTestFullScreen
@@ -3774,18 +4296,7 @@ func (m *model) handleAsteriskMenuSelection() {
 			}
 		}
 	case 7: // Configure PJSIP Transports
-		err := m.configManager.EnsureTransportConfig()
-		if err != nil {
-			m.errorMsg = fmt.Sprintf("Failed to configure transports: %v", err)
-		} else {
-			m.successMsg = "PJSIP transports configured successfully (UDP and TCP on port 5060)"
-			// Reload PJSIP to apply changes
-			if _, reloadErr := m.asteriskManager.ReloadPJSIPQuiet(); reloadErr != nil {
-				m.asteriskOutput = "⚠️ Transports configured but reload failed. You may need to restart Asterisk."
-			} else {
-				m.asteriskOutput = "✅ UDP Transport: 0.0.0.0:5060\n✅ TCP Transport: 0.0.0.0:5060\n\nConfiguration reloaded successfully."
-			}
-		}
+		m.initTLSTransportForm()
 	case 8: // Show PJSIP Endpoints
 		output, err := m.asteriskManager.ShowEndpoints()
 		if err != nil {
@@ -3916,38 +4427,27 @@ func (m *model) executeSipTestRegister() {
 		server = "127.0.0.1"
 	}
 
-	// Validate inputs to prevent command injection
 	if !isValidExtension(ext) {
 		m.errorMsg = "Invalid extension number (only alphanumeric characters allowed)"
 		return
 	}
-	if !isValidPassword(pass) {
-		m.errorMsg = "Invalid password (special shell characters not allowed)"
-		return
-	}
-	if !isValidServer(server) {
-		m.errorMsg = "Invalid server address (only alphanumeric, dots, and hyphens allowed)"
+	transport, err := parseSIPTransport(m.inputValues[3])
+	if err != nil {
+		m.errorMsg = err.Error()
 		return
 	}
-
-	scriptPath := getSipTestScriptPath()
-	if scriptPath == "" {
-		m.errorMsg = "SIP test script not found"
-		m.sipTestOutput = formatScriptNotFoundError()
-		m.inputMode = false
+	if _, err := resolveSIPServer(server, transport); err != nil {
+		m.errorMsg = err.Error()
 		return
 	}
 
-	cmd := exec.Command("bash", scriptPath, "register", ext, pass, server)
-	output, err := cmd.CombinedOutput()
-	
-	if err != nil {
-		details := ParseCommandError(err, output)
-		m.errorMsg = fmt.Sprintf("Test failed: %s (exit code %d)", details.ErrorType, details.ExitCode)
-		m.sipTestOutput = FormatVerboseError(details)
-	} else {
+	// Built-in SIP client, no external tools needed
+	output, ok := RunSIPRegisterTest(server, transport, ext, pass, sipTestTimeout)
+	m.sipTestOutput = output
+	if ok {
 		m.successMsg = "Registration test completed"
-		m.sipTestOutput = string(output)
+	} else {
+		m.errorMsg = fmt.Sprintf("Registration test failed for extension %s", ext)
 	}
 
 	m.inputMode = false
@@ -3969,38 +4469,26 @@ func (m *model) executeSipTestCall() {
 		server = "127.0.0.1"
 	}
 
-	// Validate inputs to prevent command injection
 	if !isValidExtension(fromExt) || !isValidExtension(toExt) {
 		m.errorMsg = "Invalid extension number (only alphanumeric characters allowed)"
 		return
 	}
-	if !isValidPassword(fromPass) || !isValidPassword(toPass) {
-		m.errorMsg = "Invalid password (special shell characters not allowed)"
-		return
-	}
-	if !isValidServer(server) {
-		m.errorMsg = "Invalid server address (only alphanumeric, dots, and hyphens allowed)"
+	transport, err := parseSIPTransport(m.inputValues[5])
+	if err != nil {
+		m.errorMsg = err.Error()
 		return
 	}
-
-	scriptPath := getSipTestScriptPath()
-	if scriptPath == "" {
-		m.errorMsg = "SIP test script not found"
-		m.sipTestOutput = formatScriptNotFoundError()
-		m.inputMode = false
+	if _, err := resolveSIPServer(server, transport); err != nil {
+		m.errorMsg = err.Error()
 		return
 	}
 
-	cmd := exec.Command("bash", scriptPath, "call", fromExt, fromPass, toExt, toPass, server)
-	output, err := cmd.CombinedOutput()
-	
-	if err != nil {
-		details := ParseCommandError(err, output)
-		m.errorMsg = fmt.Sprintf("Test failed: %s (exit code %d)", details.ErrorType, details.ExitCode)
-		m.sipTestOutput = FormatVerboseError(details)
-	} else {
+	output, ok := RunSIPCallTest(server, transport, fromExt, fromPass, toExt, toPass, sipTestTimeout)
+	m.sipTestOutput = output
+	if ok {
 		m.successMsg = "Call test completed"
-		m.sipTestOutput = string(output)
+	} else {
+		m.errorMsg = fmt.Sprintf("Call test from %s to %s failed", fromExt, toExt)
 	}
 
 	m.inputMode = false
@@ -5224,11 +5712,18 @@ func main() {
 		fmt.Println()
 		fmt.Println("USAGE:")
 		fmt.Println("    rayanpbx-tui [OPTIONS]")
+		fmt.Println("    rayanpbx-tui serve [--listen ADDR]   Serve Prometheus metrics on /metrics (default :9101)")
+		fmt.Println("    rayanpbx-tui health [--output text|json|yaml|nagios]   Run health checks (exit 0 ok, 1 warn, 2 crit)")
+		fmt.Println("    rayanpbx-tui COMMAND ACTION [ARGS] [--output table|json|yaml]   Script TUI operations (see below)")
 		fmt.Println()
 		fmt.Println("OPTIONS:")
 		fmt.Println("    -h, --help       Show this help message")
 		fmt.Println("    -v, --version    Show version information")
 		fmt.Println("    --verbose        Show detailed information about config file updates")
+		fmt.Println("    --call-quality-json  Print RTP quality statistics for active and recent calls as JSON")
+		fmt.Println()
+		fmt.Println("COMMANDS:")
+		fmt.Print(cliUsage)
 		fmt.Println()
 		fmt.Println("FEATURES:")
 		fmt.Println("    • Interactive terminal UI for managing RayanPBX")
@@ -5240,6 +5735,39 @@ func main() {
 		return
 	}
 
+	// Serve Prometheus metrics instead of the interactive UI
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		if err := runMetricsServer(os.Args[2:]); err != nil {
+			color.New(color.FgRed).Printf("❌ %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Run health checks non-interactively, e.g. from cron or Nagios
+	if len(os.Args) > 1 && os.Args[1] == "health" {
+		os.Exit(runHealthCommand(os.Args[2:]))
+	}
+
+	// Run scripting subcommands without the interactive UI
+	if len(os.Args) > 1 && isCLICommand(os.Args[1]) {
+		os.Exit(runCLI(os.Args[1:]))
+	}
+
+	// Print call quality statistics for monitoring
+	if len(os.Args) > 1 && os.Args[1] == "--call-quality-json" {
+		report, err := NewDiagnosticsManager(NewAsteriskManager()).CollectCallQuality()
+		if report != nil {
+			data, _ := json.MarshalIndent(report, "", "  ")
+			fmt.Println(string(data))
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Print beautiful banner
 	PrintBanner()
 
@@ -76,10 +76,10 @@ func TestInputFieldsValidation(t *testing.T) {
 		t.Error("Expected inputMode to be true after initCreateExtension")
 	}
 	
-	// Now we have 9 fields for extension creation (including advanced PJSIP options)
-	// Extension Number, Name, Password, Codecs, Context, Transport, Direct Media, Max Contacts, Qualify Frequency
-	if len(m.inputFields) != 9 {
-		t.Errorf("Expected 9 input fields for extension (including advanced PJSIP options), got %d", len(m.inputFields))
+	// Now we have 12 fields for extension creation (including profile and advanced PJSIP options)
+	// Extension Number, Name, Password, Profile, Codecs, Context, Transport, Direct Media, Max Contacts, Qualify Frequency, Media Encryption, NAT Traversal
+	if len(m.inputFields) != 12 {
+		t.Errorf("Expected 12 input fields for extension (including profile and advanced PJSIP options), got %d", len(m.inputFields))
 	}
 	
 	// Test trunk creation initialization
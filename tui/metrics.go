@@ -0,0 +1,406 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// DefaultMetricsAddr is the listen address of "rayanpbx-tui serve"
+const DefaultMetricsAddr = ":9101"
+
+const metricsWebSocketTimeout = 2 * time.Second
+
+var (
+	queueSummaryRegex = regexp.MustCompile(`^(\S+) has (\d+) calls? `)
+	// Registration states reported by "pjsip show registrations"
+	trunkRegistrationStates = []string{"Registered", "Unregistered", "Rejected", "Stopped", "Failed"}
+)
+
+// TrunkRegistration is the outbound registration state of a trunk
+type TrunkRegistration struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+// Registered returns true if the trunk is currently registered
+func (t TrunkRegistration) Registered() bool {
+	return t.Status == "Registered"
+}
+
+// PBXSnapshot is a point-in-time view of PBX health shared by the metrics endpoint and health checks
+type PBXSnapshot struct {
+	AsteriskUp            bool                `json:"asterisk_up"`
+	DatabaseUp            bool                `json:"database_up"`
+	EndpointsRegistered   int                 `json:"endpoints_registered"`
+	EndpointsUnregistered int                 `json:"endpoints_unregistered"`
+	ActiveChannels        int                 `json:"active_channels"`
+	ActiveCalls           int                 `json:"active_calls"`
+	Trunks                []TrunkRegistration `json:"trunks"`
+	QueueWaiting          map[string]int      `json:"queue_waiting"`
+	UptimeSeconds         int64               `json:"uptime_seconds"`
+	LastReloadSeconds     int64               `json:"last_reload_seconds"`
+	SyncAvailable         bool                `json:"sync_available"`
+	SyncTotal             int                 `json:"sync_total"`
+	SyncMatched           int                 `json:"sync_matched"`
+	SyncDBOnly            int                 `json:"sync_db_only"`
+	SyncAsteriskOnly      int                 `json:"sync_asterisk_only"`
+	SyncMismatched        int                 `json:"sync_mismatched"`
+	WebSocketClients      int                 `json:"websocket_clients"` // -1 when the daemon is unreachable
+}
+
+// SnapshotCollector gathers PBX state from Asterisk, the database and the WebSocket daemon
+type SnapshotCollector struct {
+	asterisk     *AsteriskManager
+	syncManager  *ExtensionSyncManager // Optional, nil without a database
+	webSocketURL string                // Health URL of the WebSocket daemon, empty to skip
+}
+
+// NewSnapshotCollector creates a collector
+func NewSnapshotCollector(asterisk *AsteriskManager, syncManager *ExtensionSyncManager, webSocketURL string) *SnapshotCollector {
+	return &SnapshotCollector{
+		asterisk:     asterisk,
+		syncManager:  syncManager,
+		webSocketURL: webSocketURL,
+	}
+}
+
+// Collect takes a snapshot. Sources that cannot be reached leave their fields at zero.
+func (sc *SnapshotCollector) Collect() *PBXSnapshot {
+	snap := &PBXSnapshot{QueueWaiting: map[string]int{}, WebSocketClients: -1}
+
+	if output, err := sc.asterisk.ExecuteCLICommand("core show uptime seconds"); err == nil {
+		snap.AsteriskUp = true
+		snap.UptimeSeconds, snap.LastReloadSeconds = parseUptimeSeconds(output)
+	}
+	if snap.AsteriskUp {
+		if output, err := sc.asterisk.ExecuteCLICommand("core show channels count"); err == nil {
+			snap.ActiveChannels, snap.ActiveCalls = parseChannelsCount(output)
+		}
+		if output, err := sc.asterisk.ShowPeers(); err == nil {
+			snap.Trunks = ParseTrunkRegistrations(output)
+		}
+		if output, err := sc.asterisk.ExecuteCLICommand("queue show"); err == nil {
+			snap.QueueWaiting = ParseQueueWaiting(output)
+		}
+	}
+
+	if sc.syncManager != nil {
+		if snap.AsteriskUp {
+			if live, err := sc.syncManager.GetLiveAsteriskEndpoints(); err == nil {
+				for _, registered := range live {
+					if registered {
+						snap.EndpointsRegistered++
+					} else {
+						snap.EndpointsUnregistered++
+					}
+				}
+			}
+		}
+		if sc.syncManager.db != nil && sc.syncManager.db.Ping() == nil {
+			snap.DatabaseUp = true
+			total, matched, dbOnly, astOnly, mismatched, err := sc.syncManager.GetSyncSummary()
+			if err == nil {
+				snap.SyncAvailable = true
+				snap.SyncTotal, snap.SyncMatched, snap.SyncDBOnly = total, matched, dbOnly
+				snap.SyncAsteriskOnly, snap.SyncMismatched = astOnly, mismatched
+			}
+		}
+	}
+
+	if sc.webSocketURL != "" {
+		if clients, err := fetchWebSocketClients(sc.webSocketURL); err == nil {
+			snap.WebSocketClients = clients
+		}
+	}
+	return snap
+}
+
+// parseUptimeSeconds parses "core show uptime seconds"
+func parseUptimeSeconds(output string) (uptime, lastReload int64) {
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "System uptime":
+			uptime = n
+		case "Last reload":
+			lastReload = n
+		}
+	}
+	return uptime, lastReload
+}
+
+// parseChannelsCount parses "core show channels count"
+func parseChannelsCount(output string) (channels, calls int) {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[1] != "active" {
+			continue
+		}
+		n, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		switch strings.TrimSuffix(fields[2], "s") {
+		case "channel":
+			channels = n
+		case "call":
+			calls = n
+		}
+	}
+	return channels, calls
+}
+
+// ParseTrunkRegistrations parses "pjsip show registrations"
+func ParseTrunkRegistrations(output string) []TrunkRegistration {
+	var trunks []TrunkRegistration
+	inBody := false
+	for _, line := range strings.Split(output, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "====") {
+			inBody = true
+			continue
+		}
+		if !inBody || trimmed == "" || strings.HasPrefix(trimmed, "Objects found") {
+			continue
+		}
+		fields := strings.Fields(trimmed)
+		name, _, _ := strings.Cut(fields[0], "/")
+		for _, field := range fields[1:] {
+			if containsString(trunkRegistrationStates, field) {
+				trunks = append(trunks, TrunkRegistration{Name: name, Status: field})
+				break
+			}
+		}
+	}
+	return trunks
+}
+
+// ParseQueueWaiting parses "queue show" into waiting callers per queue
+func ParseQueueWaiting(output string) map[string]int {
+	waiting := make(map[string]int)
+	for _, line := range strings.Split(output, "\n") {
+		if match := queueSummaryRegex.FindStringSubmatch(line); match != nil {
+			n, _ := strconv.Atoi(match[2])
+			waiting[match[1]] = n
+		}
+	}
+	return waiting
+}
+
+// webSocketHealthURL returns the health endpoint of the WebSocket daemon from the environment
+func webSocketHealthURL() string {
+	host := getEnv("WEBSOCKET_HOST", "0.0.0.0")
+	if host == "0.0.0.0" || host == "" {
+		host = "127.0.0.1"
+	}
+	return fmt.Sprintf("http://%s/health", net.JoinHostPort(host, getEnv("WEBSOCKET_PORT", "9000")))
+}
+
+// fetchWebSocketClients reads the connected client count from the WebSocket daemon
+func fetchWebSocketClients(url string) (int, error) {
+	client := &http.Client{Timeout: metricsWebSocketTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reach WebSocket daemon: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var health struct {
+		Clients int `json:"clients"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		return 0, fmt.Errorf("failed to parse WebSocket health: %v", err)
+	}
+	return health.Clients, nil
+}
+
+// MetricsExporter serves snapshots in the Prometheus text exposition format
+type MetricsExporter struct {
+	collect func() *PBXSnapshot
+
+	mu         sync.Mutex
+	reloadedAt int64 // Uptime at which the last seen reload happened
+	lastUptime int64
+	reloads    int
+	scrapes    int
+}
+
+// NewMetricsExporter creates an exporter that takes a snapshot on every scrape
+func NewMetricsExporter(collect func() *PBXSnapshot) *MetricsExporter {
+	return &MetricsExporter{collect: collect}
+}
+
+// observeReloads counts reloads seen between scrapes; Asterisk only reports the latest one
+func (me *MetricsExporter) observeReloads(snap *PBXSnapshot) {
+	if !snap.AsteriskUp {
+		return
+	}
+	// Both values are truncated separately, so allow a second of drift.
+	// A restart resets uptime and is not counted as a reload.
+	reloadedAt := snap.UptimeSeconds - snap.LastReloadSeconds
+	restarted := snap.UptimeSeconds < me.lastUptime
+	if me.scrapes > 0 && !restarted && reloadedAt > me.reloadedAt+1 {
+		me.reloads++
+	}
+	me.reloadedAt = reloadedAt
+	me.lastUptime = snap.UptimeSeconds
+	me.scrapes++
+}
+
+// ServeHTTP implements http.Handler
+func (me *MetricsExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	snap := me.collect()
+	me.mu.Lock()
+	me.observeReloads(snap)
+	reloads := me.reloads
+	me.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	fmt.Fprint(w, FormatPrometheusMetrics(snap, reloads))
+}
+
+// metricsWriter writes metric families in the Prometheus text format
+type metricsWriter struct {
+	b strings.Builder
+}
+
+// family writes the HELP and TYPE lines of a metric
+func (mw *metricsWriter) family(name, typ, help string) {
+	fmt.Fprintf(&mw.b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// sample writes one sample with optional label pairs
+func (mw *metricsWriter) sample(name string, value float64, labels ...string) {
+	mw.b.WriteString(name)
+	if len(labels) > 0 {
+		var pairs []string
+		for i := 0; i+1 < len(labels); i += 2 {
+			pairs = append(pairs, fmt.Sprintf("%s=%q", labels[i], labels[i+1]))
+		}
+		mw.b.WriteString("{" + strings.Join(pairs, ",") + "}")
+	}
+	mw.b.WriteString(" " + strconv.FormatFloat(value, 'g', -1, 64) + "\n")
+}
+
+// gauge writes a metric family with a single unlabelled sample
+func (mw *metricsWriter) gauge(name, help string, value float64) {
+	mw.family(name, "gauge", help)
+	mw.sample(name, value)
+}
+
+// boolValue converts a bool to a metric value
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// FormatPrometheusMetrics renders a snapshot in the Prometheus text exposition format
+func FormatPrometheusMetrics(snap *PBXSnapshot, reloads int) string {
+	mw := &metricsWriter{}
+	mw.gauge("rayanpbx_asterisk_up", "Whether Asterisk answers CLI commands", boolValue(snap.AsteriskUp))
+	mw.gauge("rayanpbx_database_up", "Whether the RayanPBX database is reachable", boolValue(snap.DatabaseUp))
+	mw.gauge("rayanpbx_asterisk_uptime_seconds", "Seconds since Asterisk started", float64(snap.UptimeSeconds))
+	mw.gauge("rayanpbx_asterisk_last_reload_seconds", "Seconds since the last Asterisk reload", float64(snap.LastReloadSeconds))
+	mw.family("rayanpbx_asterisk_reloads_total", "counter", "Asterisk reloads observed by this exporter")
+	mw.sample("rayanpbx_asterisk_reloads_total", float64(reloads))
+
+	mw.family("rayanpbx_endpoints", "gauge", "PJSIP extension endpoints by registration state")
+	mw.sample("rayanpbx_endpoints", float64(snap.EndpointsRegistered), "state", "registered")
+	mw.sample("rayanpbx_endpoints", float64(snap.EndpointsUnregistered), "state", "unregistered")
+
+	mw.gauge("rayanpbx_active_channels", "Active Asterisk channels", float64(snap.ActiveChannels))
+	mw.gauge("rayanpbx_active_calls", "Active Asterisk calls", float64(snap.ActiveCalls))
+
+	mw.family("rayanpbx_trunk_registered", "gauge", "Whether the outbound registration of a trunk is registered")
+	for _, trunk := range snap.Trunks {
+		mw.sample("rayanpbx_trunk_registered", boolValue(trunk.Registered()), "trunk", trunk.Name, "status", trunk.Status)
+	}
+
+	mw.family("rayanpbx_queue_waiting_callers", "gauge", "Callers waiting in each queue")
+	queues := make([]string, 0, len(snap.QueueWaiting))
+	for queue := range snap.QueueWaiting {
+		queues = append(queues, queue)
+	}
+	sort.Strings(queues)
+	for _, queue := range queues {
+		mw.sample("rayanpbx_queue_waiting_callers", float64(snap.QueueWaiting[queue]), "queue", queue)
+	}
+
+	if snap.SyncAvailable {
+		mw.family("rayanpbx_extension_sync", "gauge", "Extensions by database/Asterisk sync status")
+		mw.sample("rayanpbx_extension_sync", float64(snap.SyncMatched), "status", "matched")
+		mw.sample("rayanpbx_extension_sync", float64(snap.SyncDBOnly), "status", "db_only")
+		mw.sample("rayanpbx_extension_sync", float64(snap.SyncAsteriskOnly), "status", "asterisk_only")
+		mw.sample("rayanpbx_extension_sync", float64(snap.SyncMismatched), "status", "mismatched")
+		mw.gauge("rayanpbx_extension_sync_mismatches", "Extensions that are not in sync", float64(snap.SyncDBOnly+snap.SyncAsteriskOnly+snap.SyncMismatched))
+	}
+
+	if snap.WebSocketClients >= 0 {
+		mw.gauge("rayanpbx_websocket_clients", "Clients connected to the WebSocket daemon", float64(snap.WebSocketClients))
+	}
+	mw.gauge("rayanpbx_websocket_up", "Whether the WebSocket daemon health endpoint answers", boolValue(snap.WebSocketClients >= 0))
+	return mw.b.String()
+}
+
+// runMetricsServer runs "rayanpbx-tui serve", exporting metrics over HTTP
+func runMetricsServer(args []string) error {
+	addr := ""
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--listen" && i+1 < len(args):
+			addr = args[i+1]
+			i++
+		case strings.HasPrefix(args[i], "--listen="):
+			addr = strings.TrimPrefix(args[i], "--listen=")
+		}
+	}
+
+	config, err := LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %v", err)
+	}
+	if addr == "" {
+		addr = getEnv("METRICS_LISTEN", DefaultMetricsAddr)
+	}
+
+	yellow := color.New(color.FgYellow)
+	green := color.New(color.FgGreen)
+
+	asteriskMgr := NewAsteriskManager()
+	var syncManager *ExtensionSyncManager
+	if db, err := ConnectDB(config); err == nil {
+		defer db.Close()
+		syncManager = NewExtensionSyncManager(db, asteriskMgr, NewAsteriskConfigManager(false))
+	} else {
+		yellow.Printf("⚠️  Database unavailable, sync metrics disabled: %v\n", err)
+	}
+
+	collector := NewSnapshotCollector(asteriskMgr, syncManager, webSocketHealthURL())
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", NewMetricsExporter(collector.Collect))
+
+	green.Printf("📈 Metrics endpoint: http://%s/metrics\n", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		return fmt.Errorf("metrics server stopped: %v", err)
+	}
+	return nil
+}
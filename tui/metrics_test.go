@@ -0,0 +1,150 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestParseAsteriskStatusOutputs tests parsing the CLI outputs used for snapshots
+func TestParseAsteriskStatusOutputs(t *testing.T) {
+	uptime, reload := parseUptimeSeconds("System uptime: 86400\nLast reload: 3600\n")
+	if uptime != 86400 || reload != 3600 {
+		t.Errorf("Expected uptime 86400 and reload 3600, got %d and %d", uptime, reload)
+	}
+
+	channels, calls := parseChannelsCount("3 active channels\n1 active call\n42 calls processed\n")
+	if channels != 3 || calls != 1 {
+		t.Errorf("Expected 3 channels and 1 call, got %d and %d", channels, calls)
+	}
+
+	registrations := `
+ <Registration/ServerURI..............................>  <Auth..........>  <Status.......>
+==========================================================================================
+
+ provider/sip:sip.provider.com:5060                      provider-auth     Registered        (exp. 3545s)
+ backup/sip:backup.example.net                                             Rejected
+
+Objects found: 2
+`
+	trunks := ParseTrunkRegistrations(registrations)
+	if len(trunks) != 2 || trunks[0].Name != "provider" || !trunks[0].Registered() || trunks[1].Status != "Rejected" || trunks[1].Registered() {
+		t.Errorf("Unexpected trunks: %+v", trunks)
+	}
+
+	queues := ParseQueueWaiting(`support has 2 calls (max unlimited) in 'ringall' strategy (0s holdtime, 0s talktime), W:0, C:3, A:0, SL:0.0%, SL2:0.0% within 0s
+   Members:
+      PJSIP/101 (ringinuse disabled) (Not in use) has taken no calls yet
+   Callers:
+      1. PJSIP/200-00000001 (wait: 0:05, prio: 0)
+      2. PJSIP/201-00000002 (wait: 0:02, prio: 0)
+
+sales has 0 calls (max unlimited) in 'rrmemory' strategy (0s holdtime, 0s talktime), W:0, C:0, A:0, SL:0.0%, SL2:0.0% within 0s
+   No Members
+   No Callers
+`)
+	if len(queues) != 2 || queues["support"] != 2 || queues["sales"] != 0 {
+		t.Errorf("Unexpected queues: %v", queues)
+	}
+}
+
+// TestMetricsExporter tests the exposition format and reload counting across scrapes
+func TestMetricsExporter(t *testing.T) {
+	snap := &PBXSnapshot{
+		AsteriskUp:            true,
+		DatabaseUp:            true,
+		EndpointsRegistered:   4,
+		EndpointsUnregistered: 1,
+		ActiveChannels:        2,
+		ActiveCalls:           1,
+		Trunks:                []TrunkRegistration{{Name: "provider", Status: "Registered"}},
+		QueueWaiting:          map[string]int{"support": 2, "billing": 0},
+		UptimeSeconds:         1000,
+		LastReloadSeconds:     500,
+		SyncAvailable:         true,
+		SyncTotal:             6,
+		SyncMatched:           4,
+		SyncDBOnly:            1,
+		SyncMismatched:        1,
+		WebSocketClients:      3,
+	}
+	exporter := NewMetricsExporter(func() *PBXSnapshot { return snap })
+	server := httptest.NewServer(exporter)
+	defer server.Close()
+
+	scrape := func() string {
+		resp, err := http.Get(server.URL)
+		if err != nil {
+			t.Fatalf("Scrape failed: %v", err)
+		}
+		defer resp.Body.Close()
+		if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain; version=0.0.4") {
+			t.Errorf("Unexpected content type %q", resp.Header.Get("Content-Type"))
+		}
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	body := scrape()
+	for _, want := range []string{
+		"# TYPE rayanpbx_asterisk_up gauge\nrayanpbx_asterisk_up 1\n",
+		`rayanpbx_endpoints{state="registered"} 4`,
+		`rayanpbx_endpoints{state="unregistered"} 1`,
+		"rayanpbx_active_calls 1\n",
+		`rayanpbx_trunk_registered{trunk="provider",status="Registered"} 1`,
+		"rayanpbx_queue_waiting_callers{queue=\"billing\"} 0\nrayanpbx_queue_waiting_callers{queue=\"support\"} 2\n",
+		`rayanpbx_extension_sync{status="db_only"} 1`,
+		"rayanpbx_extension_sync_mismatches 2\n",
+		"rayanpbx_websocket_clients 3\n",
+		"# TYPE rayanpbx_asterisk_reloads_total counter\nrayanpbx_asterisk_reloads_total 0\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q in metrics:\n%s", want, body)
+		}
+	}
+
+	tests := []struct {
+		name           string
+		uptime, reload int64
+		reloads        string
+	}{
+		{"time passes", 1060, 560, "rayanpbx_asterisk_reloads_total 0\n"},
+		{"reload", 1120, 10, "rayanpbx_asterisk_reloads_total 1\n"},
+		{"restart", 30, 30, "rayanpbx_asterisk_reloads_total 1\n"},
+		{"reload after restart", 90, 5, "rayanpbx_asterisk_reloads_total 2\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snap.UptimeSeconds, snap.LastReloadSeconds = tt.uptime, tt.reload
+			if body := scrape(); !strings.Contains(body, tt.reloads) {
+				t.Errorf("Expected %q in metrics", tt.reloads)
+			}
+		})
+	}
+
+	// Unreachable sources are reported as down rather than as zero counts
+	down := FormatPrometheusMetrics(&PBXSnapshot{WebSocketClients: -1}, 0)
+	if strings.Contains(down, "rayanpbx_websocket_clients") || strings.Contains(down, "rayanpbx_extension_sync") ||
+		!strings.Contains(down, "rayanpbx_websocket_up 0\n") || !strings.Contains(down, "rayanpbx_asterisk_up 0\n") {
+		t.Errorf("Unexpected metrics for unreachable sources:\n%s", down)
+	}
+}
+
+// TestFetchWebSocketClients tests reading the client count from the daemon health endpoint
+func TestFetchWebSocketClients(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"healthy","clients":7}`))
+	}))
+	defer server.Close()
+
+	clients, err := fetchWebSocketClients(server.URL + "/health")
+	if err != nil || clients != 7 {
+		t.Errorf("Expected 7 clients, got %d (%v)", clients, err)
+	}
+	server.Close()
+	if _, err := fetchWebSocketClients(server.URL + "/health"); err == nil {
+		t.Error("Expected error for unreachable daemon")
+	}
+}
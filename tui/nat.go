@@ -0,0 +1,631 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/fatih/color"
+)
+
+// NAT defaults
+const (
+	DefaultSTUNServer = "stun.l.google.com:19302"
+	DefaultRTPStart   = 10000
+	DefaultRTPEnd     = 20000
+)
+
+// STUN message constants (RFC 5389)
+const (
+	stunBindingRequest       = 0x0001
+	stunBindingSuccess       = 0x0101
+	stunMagicCookie          = 0x2112A442
+	stunHeaderSize           = 20
+	stunAttrMappedAddress    = 0x0001
+	stunAttrXorMappedAddress = 0x0020
+)
+
+// NAT wizard form field indices
+const (
+	natFieldExternalAddress = iota
+	natFieldLocalNets
+	natFieldRTPStart
+	natFieldRTPEnd
+	natFieldSTUNServer
+	natFieldProfiles
+)
+
+// natTransportKeys are the transport options written by the NAT wizard
+var natTransportKeys = []string{"external_media_address", "external_signaling_address", "local_net"}
+
+// NATSettings holds the NAT traversal settings of the transports and rtp.conf
+type NATSettings struct {
+	ExternalAddress string   // Public IP or host name phones outside the LAN reach us on
+	LocalNets       []string // Networks reached without NAT, in CIDR notation
+	RTPStart        int
+	RTPEnd          int
+	STUNServer      string // rtp.conf stunaddr, used for ICE candidates (optional)
+}
+
+// STUNQuery asks a STUN server for the public address of this host.
+// The request is sent up to three times within the timeout.
+func STUNQuery(server string, timeout time.Duration) (net.IP, error) {
+	conn, err := net.DialTimeout("udp", server, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to contact STUN server: %v", err)
+	}
+	defer conn.Close()
+
+	request := make([]byte, stunHeaderSize)
+	binary.BigEndian.PutUint16(request[0:2], stunBindingRequest)
+	binary.BigEndian.PutUint32(request[4:8], stunMagicCookie)
+	if _, err := rand.Read(request[8:20]); err != nil {
+		return nil, fmt.Errorf("failed to generate transaction ID: %v", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	buf := make([]byte, 1500)
+	var lastErr error
+	for attempt := 0; attempt < 3 && time.Now().Before(deadline); attempt++ {
+		if _, err := conn.Write(request); err != nil {
+			return nil, fmt.Errorf("failed to send STUN request: %v", err)
+		}
+		attemptDeadline := time.Now().Add(timeout / 3)
+		if attemptDeadline.After(deadline) {
+			attemptDeadline = deadline
+		}
+		conn.SetReadDeadline(attemptDeadline)
+
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				lastErr = err
+				break
+			}
+			ip, err := parseSTUNBindingResponse(buf[:n], request[8:20])
+			if err != nil {
+				// Ignore stray datagrams, wait for our response
+				lastErr = err
+				continue
+			}
+			return ip, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no STUN response from %s: %v", server, lastErr)
+}
+
+// parseSTUNBindingResponse extracts the mapped address from a binding success response
+func parseSTUNBindingResponse(msg []byte, txID []byte) (net.IP, error) {
+	if len(msg) < stunHeaderSize {
+		return nil, fmt.Errorf("STUN message too short")
+	}
+	if binary.BigEndian.Uint16(msg[0:2]) != stunBindingSuccess {
+		return nil, fmt.Errorf("unexpected STUN message type 0x%04x", binary.BigEndian.Uint16(msg[0:2]))
+	}
+	if binary.BigEndian.Uint32(msg[4:8]) != stunMagicCookie || string(msg[8:20]) != string(txID) {
+		return nil, fmt.Errorf("STUN transaction mismatch")
+	}
+	length := int(binary.BigEndian.Uint16(msg[2:4]))
+	if stunHeaderSize+length > len(msg) {
+		return nil, fmt.Errorf("truncated STUN message")
+	}
+
+	var mapped net.IP
+	attrs := msg[stunHeaderSize : stunHeaderSize+length]
+	for len(attrs) >= 4 {
+		attrType := binary.BigEndian.Uint16(attrs[0:2])
+		attrLen := int(binary.BigEndian.Uint16(attrs[2:4]))
+		if 4+attrLen > len(attrs) {
+			return nil, fmt.Errorf("truncated STUN attribute")
+		}
+		value := attrs[4 : 4+attrLen]
+
+		switch attrType {
+		case stunAttrXorMappedAddress:
+			if ip := decodeSTUNAddress(value, msg[4:20]); ip != nil {
+				return ip, nil
+			}
+		case stunAttrMappedAddress:
+			mapped = decodeSTUNAddress(value, nil)
+		}
+
+		// Attributes are padded to a multiple of 4 bytes
+		next := 4 + (attrLen+3)/4*4
+		if next > len(attrs) {
+			break
+		}
+		attrs = attrs[next:]
+	}
+
+	if mapped == nil {
+		return nil, fmt.Errorf("STUN response has no mapped address")
+	}
+	return mapped, nil
+}
+
+// decodeSTUNAddress decodes a (XOR-)MAPPED-ADDRESS value. xorKey is the magic cookie
+// followed by the transaction ID, or nil for a plain MAPPED-ADDRESS.
+func decodeSTUNAddress(value []byte, xorKey []byte) net.IP {
+	if len(value) < 4 {
+		return nil
+	}
+	var size int
+	switch value[1] {
+	case 0x01:
+		size = net.IPv4len
+	case 0x02:
+		size = net.IPv6len
+	default:
+		return nil
+	}
+	if len(value) < 4+size {
+		return nil
+	}
+	ip := make(net.IP, size)
+	copy(ip, value[4:4+size])
+	if xorKey != nil {
+		for i := range ip {
+			ip[i] ^= xorKey[i]
+		}
+	}
+	return ip
+}
+
+// DetectLocalNets returns the networks of the given local addresses, using the
+// netmasks of the host interfaces
+func DetectLocalNets(ips []string) []string {
+	addrs, _ := net.InterfaceAddrs()
+	return localNetsForIPs(ips, addrs)
+}
+
+// localNetsForIPs maps addresses to their interface networks; IPv4 addresses
+// without a known interface are assumed to be in a /24
+func localNetsForIPs(ips []string, addrs []net.Addr) []string {
+	var nets []string
+	seen := make(map[string]bool)
+	for _, s := range ips {
+		ip := net.ParseIP(s)
+		if ip == nil || ip.IsLoopback() {
+			continue
+		}
+
+		var network string
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+				network = (&net.IPNet{IP: ip.Mask(ipNet.Mask), Mask: ipNet.Mask}).String()
+				break
+			}
+		}
+		if network == "" {
+			if ip.To4() == nil {
+				continue
+			}
+			mask := net.CIDRMask(24, 32)
+			network = (&net.IPNet{IP: ip.Mask(mask), Mask: mask}).String()
+		}
+
+		if !seen[network] {
+			seen[network] = true
+			nets = append(nets, network)
+		}
+	}
+	return nets
+}
+
+// isNATTransport returns true for transports that carry SIP to phones directly.
+// WebSocket transports use the HTTP server and have no NAT settings of their own.
+func isNATTransport(section *AsteriskSection) bool {
+	if section.Commented || section.Type != "transport" {
+		return false
+	}
+	protocol := section.Properties["protocol"]
+	return protocol != "ws" && protocol != "wss"
+}
+
+// transportNATProperties returns the NAT settings of the first transport that has them
+func transportNATProperties(config *AsteriskConfig) map[string]string {
+	for _, section := range config.Sections {
+		if !isNATTransport(section) {
+			continue
+		}
+		props := make(map[string]string)
+		for _, key := range natTransportKeys {
+			if value, ok := section.GetProperty(key); ok {
+				props[key] = value
+			}
+		}
+		if len(props) > 0 {
+			return props
+		}
+	}
+	return nil
+}
+
+// applyTransportNATProperties copies NAT settings to transport sections
+func applyTransportNATProperties(sections []*AsteriskSection, props map[string]string) {
+	for _, section := range sections {
+		if !isNATTransport(section) {
+			continue
+		}
+		for _, key := range natTransportKeys {
+			if value, ok := props[key]; ok {
+				section.SetProperty(key, value)
+			}
+		}
+	}
+}
+
+// LoadRTPConfig loads rtp.conf, creating an empty config if it is missing
+func (acm *AsteriskConfigManager) LoadRTPConfig() (*AsteriskConfig, error) {
+	return acm.loadConfigOrNew(acm.rtpConfigPath, []string{"; RayanPBX RTP Configuration", "; Generated by RayanPBX TUI", ""})
+}
+
+// LoadNATSettings reads the current NAT settings from pjsip.conf and rtp.conf
+func (acm *AsteriskConfigManager) LoadNATSettings() (*NATSettings, error) {
+	settings := &NATSettings{RTPStart: DefaultRTPStart, RTPEnd: DefaultRTPEnd}
+
+	if _, err := os.Stat(acm.pjsipConfigPath); err == nil {
+		config, err := ParseAsteriskConfig(acm.pjsipConfigPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %v", err)
+		}
+		props := transportNATProperties(config)
+		settings.ExternalAddress = props["external_media_address"]
+		for _, network := range strings.Split(props["local_net"], ",") {
+			if network = strings.TrimSpace(network); network != "" {
+				settings.LocalNets = append(settings.LocalNets, network)
+			}
+		}
+	}
+
+	if _, err := os.Stat(acm.rtpConfigPath); err == nil {
+		config, err := ParseAsteriskConfig(acm.rtpConfigPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read rtp.conf: %v", err)
+		}
+		for _, section := range config.FindActiveSectionsByName("general") {
+			if v, err := strconv.Atoi(section.Properties["rtpstart"]); err == nil {
+				settings.RTPStart = v
+			}
+			if v, err := strconv.Atoi(section.Properties["rtpend"]); err == nil {
+				settings.RTPEnd = v
+			}
+			if v, ok := section.GetProperty("stunaddr"); ok {
+				settings.STUNServer = v
+			}
+		}
+	}
+
+	return settings, nil
+}
+
+// Validate checks the NAT settings before they are written
+func (s *NATSettings) Validate() error {
+	if s.ExternalAddress != "" && net.ParseIP(s.ExternalAddress) == nil && !isHostName(s.ExternalAddress) {
+		return fmt.Errorf("invalid external address: %s", s.ExternalAddress)
+	}
+	for _, network := range s.LocalNets {
+		if _, _, err := net.ParseCIDR(network); err != nil {
+			return fmt.Errorf("invalid local network %s (use CIDR, e.g. 192.168.1.0/24)", network)
+		}
+	}
+	if s.RTPStart < 1024 || s.RTPEnd > 65535 || s.RTPStart >= s.RTPEnd {
+		return fmt.Errorf("RTP port range must be within 1024-65535 and start below end")
+	}
+	return nil
+}
+
+// WriteNATConfig writes the external addresses and local networks to every SIP transport
+// and the RTP port range to rtp.conf, in a single Git snapshot
+func (acm *AsteriskConfigManager) WriteNATConfig(settings NATSettings) error {
+	green := color.New(color.FgGreen)
+	yellow := color.New(color.FgYellow)
+
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+
+	pjsipConfig, err := acm.LoadPjsipConfig()
+	if err != nil {
+		return err
+	}
+	updated := 0
+	for _, section := range pjsipConfig.Sections {
+		if !isNATTransport(section) {
+			continue
+		}
+		for _, key := range natTransportKeys {
+			section.RemoveProperty(key)
+		}
+		if settings.ExternalAddress != "" {
+			section.SetProperty("external_media_address", settings.ExternalAddress)
+			section.SetProperty("external_signaling_address", settings.ExternalAddress)
+		}
+		if len(settings.LocalNets) > 0 {
+			section.SetProperty("local_net", strings.Join(settings.LocalNets, ","))
+		}
+		updated++
+	}
+	if updated == 0 {
+		return fmt.Errorf("no SIP transports found in pjsip.conf, configure PJSIP transports first")
+	}
+	if err := pjsipConfig.Save(); err != nil {
+		return fmt.Errorf("failed to write config file: %v", err)
+	}
+
+	rtpConfig, err := acm.LoadRTPConfig()
+	if err != nil {
+		return err
+	}
+	var general *AsteriskSection
+	if sections := rtpConfig.FindActiveSectionsByName("general"); len(sections) > 0 {
+		general = sections[0]
+	} else {
+		general = NewAsteriskSection("general", "")
+		rtpConfig.Sections = append([]*AsteriskSection{general}, rtpConfig.Sections...)
+	}
+	general.SetProperty("rtpstart", strconv.Itoa(settings.RTPStart))
+	general.SetProperty("rtpend", strconv.Itoa(settings.RTPEnd))
+	if settings.STUNServer != "" {
+		general.SetProperty("stunaddr", settings.STUNServer)
+	} else {
+		general.RemoveProperty("stunaddr")
+	}
+	if err := rtpConfig.Save(); err != nil {
+		return fmt.Errorf("failed to write rtp.conf: %v", err)
+	}
+
+	if acm.verbose {
+		green.Fprintf(acm.out, "✅ NAT settings written to %d transport(s)\n", updated)
+	}
+
+	if err := acm.CommitConfigChange("nat-update", fmt.Sprintf("Updated NAT settings (external address: %s)", settings.ExternalAddress)); err != nil {
+		yellow.Fprintf(acm.out, "⚠️  Git commit warning: %v\n", err)
+	}
+
+	return nil
+}
+
+// isHostName checks that a value is a syntactically valid DNS name
+func isHostName(name string) bool {
+	if len(name) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// splitCommaList splits a comma-separated form value, dropping empty items
+func splitCommaList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// initNATWizard opens the NAT wizard, pre-filled with the current settings or detected values
+func (m *model) initNATWizard() {
+	m.loadExtensionProfiles()
+	m.currentScreen = natWizardScreen
+	m.inputMode = true
+	m.diagnosticsOutput = ""
+	m.errorMsg = ""
+	m.successMsg = ""
+
+	settings, err := m.configManager.LoadNATSettings()
+	if err != nil {
+		m.errorMsg = err.Error()
+		settings = &NATSettings{RTPStart: DefaultRTPStart, RTPEnd: DefaultRTPEnd}
+	}
+
+	var detected []string
+	if settings.ExternalAddress == "" {
+		if ip, err := STUNQuery(DefaultSTUNServer, 3*time.Second); err != nil {
+			m.errorMsg = fmt.Sprintf("Public address discovery failed, enter it manually: %v", err)
+		} else {
+			settings.ExternalAddress = ip.String()
+			detected = append(detected, "external address (STUN)")
+		}
+	}
+	if len(settings.LocalNets) == 0 {
+		settings.LocalNets = DetectLocalNets(GetLocalIPAddresses())
+		detected = append(detected, "local networks")
+	}
+	stunServer := settings.STUNServer
+	if stunServer == "" {
+		stunServer = DefaultSTUNServer
+	}
+
+	var natProfiles []string
+	for _, p := range m.extensionProfiles {
+		if p.NAT {
+			natProfiles = append(natProfiles, p.Name)
+		}
+	}
+
+	m.inputFields = []string{
+		"External address",
+		"Local networks (CIDR, comma-separated)",
+		"RTP port start",
+		"RTP port end",
+		"STUN server for ICE (host:port, optional)",
+		"Remote-worker profiles (comma-separated)",
+	}
+	m.inputValues = []string{
+		settings.ExternalAddress,
+		strings.Join(settings.LocalNets, ","),
+		strconv.Itoa(settings.RTPStart),
+		strconv.Itoa(settings.RTPEnd),
+		stunServer,
+		strings.Join(natProfiles, ","),
+	}
+	m.inputCursor = 0
+	if len(detected) > 0 {
+		m.diagnosticsOutput = "🔎 Detected " + strings.Join(detected, " and ") + ", review before applying"
+	}
+}
+
+// parseNATWizardForm validates the NAT wizard form values
+func parseNATWizardForm(values []string) (NATSettings, []string, error) {
+	settings := NATSettings{
+		ExternalAddress: strings.TrimSpace(values[natFieldExternalAddress]),
+		LocalNets:       splitCommaList(values[natFieldLocalNets]),
+		STUNServer:      strings.TrimSpace(values[natFieldSTUNServer]),
+	}
+
+	var err error
+	if settings.RTPStart, err = strconv.Atoi(strings.TrimSpace(values[natFieldRTPStart])); err != nil {
+		return settings, nil, fmt.Errorf("invalid RTP port start")
+	}
+	if settings.RTPEnd, err = strconv.Atoi(strings.TrimSpace(values[natFieldRTPEnd])); err != nil {
+		return settings, nil, fmt.Errorf("invalid RTP port end")
+	}
+	if settings.STUNServer != "" {
+		if _, _, err := net.SplitHostPort(settings.STUNServer); err != nil {
+			return settings, nil, fmt.Errorf("STUN server must be host:port")
+		}
+	}
+	if err := settings.Validate(); err != nil {
+		return settings, nil, err
+	}
+
+	return settings, splitCommaList(values[natFieldProfiles]), nil
+}
+
+// executeNATWizard writes the NAT settings, updates the remote-worker profiles and reloads Asterisk
+func (m *model) executeNATWizard() {
+	settings, profileNames, err := parseNATWizardForm(m.inputValues)
+	if err != nil {
+		m.errorMsg = err.Error()
+		return
+	}
+	for _, name := range profileNames {
+		if m.findExtensionProfile(name) == nil {
+			m.errorMsg = fmt.Sprintf("Unknown profile: %s", name)
+			return
+		}
+	}
+
+	if err := m.configManager.EnsureTransportConfig(); err != nil {
+		m.errorMsg = fmt.Sprintf("Failed to configure transports: %v", err)
+		return
+	}
+	if err := m.configManager.WriteNATConfig(settings); err != nil {
+		m.errorMsg = fmt.Sprintf("Failed to write NAT settings: %v", err)
+		return
+	}
+
+	var out strings.Builder
+	out.WriteString("🧭 NAT Settings Applied\n")
+	out.WriteString("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n\n")
+	if settings.ExternalAddress != "" {
+		out.WriteString(fmt.Sprintf("✅ External address: %s\n", settings.ExternalAddress))
+	} else {
+		out.WriteString("⚠️  No external address, remote phones may get one-way audio\n")
+	}
+	out.WriteString(fmt.Sprintf("✅ Local networks: %s\n", strings.Join(settings.LocalNets, ", ")))
+	out.WriteString(fmt.Sprintf("✅ RTP ports: %d-%d (forward these UDP ports and 5060 on the router)\n", settings.RTPStart, settings.RTPEnd))
+
+	// The listed profiles become the remote-worker profiles, others lose the NAT options
+	wanted := make(map[string]bool)
+	for _, name := range profileNames {
+		wanted[name] = true
+	}
+	for _, p := range m.extensionProfiles {
+		if p.NAT == wanted[p.Name] {
+			continue
+		}
+		if m.db == nil {
+			m.errorMsg = "Database not connected, profiles were not updated"
+			break
+		}
+		old := p
+		p.NAT = wanted[p.Name]
+		if err := SaveExtensionProfile(m.db, &old, p); err != nil {
+			m.errorMsg = err.Error()
+			break
+		}
+		var members []Extension
+		if exts, err := GetExtensions(m.db); err == nil {
+			m.extensions = exts
+			members = profileMembers(exts, p.Name)
+		}
+		if err := m.configManager.WritePjsipProfile(p, members); err != nil {
+			m.errorMsg = fmt.Sprintf("Failed to write profile %s: %v", p.Name, err)
+			break
+		}
+		out.WriteString(fmt.Sprintf("✅ Profile %s: NAT options %s (%d members)\n", p.Name, map[bool]string{true: "enabled", false: "removed"}[p.NAT], len(members)))
+	}
+	m.loadExtensionProfiles()
+
+	if err := m.configManager.ReloadAsterisk(); err != nil {
+		out.WriteString(fmt.Sprintf("\n⚠️  Asterisk reload failed: %v\n", err))
+	} else {
+		out.WriteString("\n✅ Asterisk reloaded\n")
+	}
+
+	m.diagnosticsOutput = out.String()
+	m.inputMode = false
+	if m.errorMsg == "" {
+		m.successMsg = "NAT settings applied"
+	}
+}
+
+// renderNATWizard renders the NAT traversal wizard
+func (m model) renderNATWizard() string {
+	content := infoStyle.Render("🧭 NAT Traversal Wizard") + "\n\n"
+
+	if m.diagnosticsOutput != "" {
+		content += m.diagnosticsOutput + "\n\n"
+	}
+
+	fieldHelp := map[int]string{
+		natFieldExternalAddress: "Public IP or DNS name of the PBX, sent to phones outside the local networks",
+		natFieldLocalNets:       "Networks reached without NAT; add VPN subnets here",
+		natFieldRTPStart:        "First UDP port for media (rtp.conf rtpstart)",
+		natFieldRTPEnd:          "Last UDP port for media (rtp.conf rtpend)",
+		natFieldSTUNServer:      "Used for ICE candidates of WebRTC and ICE-capable phones",
+		natFieldProfiles:        "Profiles that get rewrite_contact, force_rport and rtp_symmetric",
+	}
+
+	for i, field := range m.inputFields {
+		cursor := "  "
+		fieldStyle := lipgloss.NewStyle()
+		if i == m.inputCursor && m.inputMode {
+			cursor = "▶ "
+			fieldStyle = selectedItemStyle
+		}
+		value := m.inputValues[i]
+		if value == "" {
+			value = helpStyle.Render("<empty>")
+		}
+		content += fmt.Sprintf("%s%s: %s\n", cursor, fieldStyle.Render(field), value)
+		if i == m.inputCursor && m.inputMode {
+			if help, ok := fieldHelp[i]; ok {
+				content += helpStyle.Render(fmt.Sprintf("   💡 %s", help)) + "\n"
+			}
+		}
+	}
+
+	content += "\n" + helpStyle.Render("💡 Press ↑/↓ to navigate, Enter on last field to apply, ESC to cancel")
+	return menuStyle.Render(content)
+}
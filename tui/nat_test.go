@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startFakeSTUNServer answers binding requests with the given mapped address.
+// When badTxID is set, responses carry a wrong transaction ID.
+func startFakeSTUNServer(t *testing.T, mapped net.IP, port int, badTxID bool) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if n < stunHeaderSize || binary.BigEndian.Uint16(buf[0:2]) != stunBindingRequest {
+				continue
+			}
+			header := make([]byte, stunHeaderSize)
+			copy(header, buf[:stunHeaderSize])
+			if badTxID {
+				header[19] ^= 0xff
+			}
+
+			ip4 := mapped.To4()
+			value := make([]byte, 8)
+			value[1] = 0x01
+			binary.BigEndian.PutUint16(value[2:4], uint16(port)^uint16(stunMagicCookie>>16))
+			for i := range ip4 {
+				value[4+i] = ip4[i] ^ header[4+i]
+			}
+
+			resp := append(header, 0, 0, 0, 0)
+			binary.BigEndian.PutUint16(resp[0:2], stunBindingSuccess)
+			binary.BigEndian.PutUint16(resp[2:4], uint16(4+len(value)))
+			binary.BigEndian.PutUint16(resp[20:22], stunAttrXorMappedAddress)
+			binary.BigEndian.PutUint16(resp[22:24], uint16(len(value)))
+			resp = append(resp, value...)
+			conn.WriteTo(resp, addr)
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+// TestSTUNQuery tests public address discovery against a local STUN stand-in
+func TestSTUNQuery(t *testing.T) {
+	server := startFakeSTUNServer(t, net.ParseIP("203.0.113.7"), 40000, false)
+	ip, err := STUNQuery(server, 2*time.Second)
+	if err != nil {
+		t.Fatalf("STUNQuery failed: %v", err)
+	}
+	if ip.String() != "203.0.113.7" {
+		t.Errorf("Expected 203.0.113.7, got %s", ip)
+	}
+
+	bad := startFakeSTUNServer(t, net.ParseIP("203.0.113.7"), 40000, true)
+	if _, err := STUNQuery(bad, 600*time.Millisecond); err == nil {
+		t.Error("Expected error for response with wrong transaction ID")
+	}
+
+	// Nobody answering
+	silent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+	if _, err := STUNQuery(silent.LocalAddr().String(), 300*time.Millisecond); err == nil {
+		t.Error("Expected timeout error")
+	}
+}
+
+// TestParseSTUNMappedAddress tests the plain MAPPED-ADDRESS fallback
+func TestParseSTUNMappedAddress(t *testing.T) {
+	txID := []byte("0123456789ab")
+	msg := make([]byte, stunHeaderSize, stunHeaderSize+12)
+	binary.BigEndian.PutUint16(msg[0:2], stunBindingSuccess)
+	binary.BigEndian.PutUint16(msg[2:4], 12)
+	binary.BigEndian.PutUint32(msg[4:8], stunMagicCookie)
+	copy(msg[8:20], txID)
+	msg = append(msg, 0x00, 0x01, 0x00, 0x08, 0x00, 0x01, 0x13, 0xc4, 198, 51, 100, 20)
+
+	ip, err := parseSTUNBindingResponse(msg, txID)
+	if err != nil || ip.String() != "198.51.100.20" {
+		t.Errorf("Expected 198.51.100.20, got %v (%v)", ip, err)
+	}
+
+	if _, err := parseSTUNBindingResponse(msg[:stunHeaderSize+4], txID); err == nil {
+		t.Error("Expected error for truncated message")
+	}
+}
+
+// TestLocalNetsForIPs tests local network detection from interface addresses
+func TestLocalNetsForIPs(t *testing.T) {
+	_, lan, _ := net.ParseCIDR("192.168.10.0/23")
+	_, vpn, _ := net.ParseCIDR("10.8.0.0/16")
+	addrs := []net.Addr{
+		&net.IPNet{IP: net.ParseIP("192.168.10.5"), Mask: lan.Mask},
+		&net.IPNet{IP: net.ParseIP("10.8.0.1"), Mask: vpn.Mask},
+	}
+
+	got := localNetsForIPs([]string{"192.168.10.5", "10.8.0.1", "127.0.0.1", "172.16.5.9", "10.8.0.1", "fe80::1"}, addrs)
+	want := "192.168.10.0/23,10.8.0.0/16,172.16.5.0/24"
+	if strings.Join(got, ",") != want {
+		t.Errorf("Expected %s, got %v", want, got)
+	}
+}
+
+// TestWriteNATConfig tests writing NAT settings to transports and rtp.conf
+func TestWriteNATConfig(t *testing.T) {
+	dir := t.TempDir()
+	acm := NewAsteriskConfigManager(false)
+	acm.pjsipConfigPath = filepath.Join(dir, "pjsip.conf")
+	acm.rtpConfigPath = filepath.Join(dir, "rtp.conf")
+
+	if err := acm.WriteNATConfig(NATSettings{RTPStart: 10000, RTPEnd: 20000}); err == nil {
+		t.Error("Expected error without transports")
+	}
+
+	pjsip := "[transport-udp]\ntype=transport\nprotocol=udp\n\n[transport-wss]\ntype=transport\nprotocol=wss\n\n[100]\ntype=endpoint\n"
+	if err := os.WriteFile(acm.pjsipConfigPath, []byte(pjsip), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(acm.rtpConfigPath, []byte("[general]\nrtpstart=5000\nrtpend=31000\nicesupport=yes\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	settings := NATSettings{
+		ExternalAddress: "pbx.example.com",
+		LocalNets:       []string{"192.168.1.0/24", "10.8.0.0/16"},
+		RTPStart:        16384,
+		RTPEnd:          16484,
+		STUNServer:      DefaultSTUNServer,
+	}
+	if err := acm.WriteNATConfig(settings); err != nil {
+		t.Fatalf("WriteNATConfig failed: %v", err)
+	}
+
+	loaded, err := acm.LoadNATSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.ExternalAddress != settings.ExternalAddress || strings.Join(loaded.LocalNets, ",") != "192.168.1.0/24,10.8.0.0/16" ||
+		loaded.RTPStart != 16384 || loaded.RTPEnd != 16484 || loaded.STUNServer != DefaultSTUNServer {
+		t.Errorf("Round trip mismatch: %+v", loaded)
+	}
+
+	config, err := ParseAsteriskConfig(acm.pjsipConfigPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := config.FindSectionByNameAndType("transport-udp", "transport").GetProperty("external_signaling_address"); v != "pbx.example.com" {
+		t.Errorf("Expected external_signaling_address on UDP transport, got %q", v)
+	}
+	if _, ok := config.FindSectionByNameAndType(webRTCTransportName, "transport").GetProperty("local_net"); ok {
+		t.Error("WebSocket transports must not get NAT settings")
+	}
+
+	rtpConf, _ := os.ReadFile(acm.rtpConfigPath)
+	if !strings.Contains(string(rtpConf), "icesupport=yes") {
+		t.Error("Expected other rtp.conf options to be kept")
+	}
+
+	// Recreated transports keep the NAT settings
+	if err := acm.EnsureTransportConfig(); err != nil {
+		t.Fatal(err)
+	}
+	config, _ = ParseAsteriskConfig(acm.pjsipConfigPath)
+	if v, _ := config.FindSectionByNameAndType("transport-tcp", "transport").GetProperty("external_media_address"); v != "pbx.example.com" {
+		t.Errorf("Expected NAT settings on new TCP transport, got %q", v)
+	}
+
+	// Clearing the external address removes it
+	settings.ExternalAddress = ""
+	if err := acm.WriteNATConfig(settings); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, _ := acm.LoadNATSettings(); loaded.ExternalAddress != "" {
+		t.Errorf("Expected external address to be removed, got %q", loaded.ExternalAddress)
+	}
+}
+
+// TestNATProfileTemplate tests the endpoint options of remote-worker profiles
+func TestNATProfileTemplate(t *testing.T) {
+	acm := NewAsteriskConfigManager(false)
+	for _, p := range DefaultExtensionProfiles() {
+		template := acm.pjsipProfileTemplateSections(p)[0]
+		for _, key := range []string{"rewrite_contact", "force_rport", "rtp_symmetric"} {
+			v, ok := template.GetProperty(key)
+			if p.NAT && v != "yes" {
+				t.Errorf("Profile %s: expected %s=yes, got %q", p.Name, key, v)
+			}
+			if !p.NAT && ok {
+				t.Errorf("Profile %s: unexpected %s", p.Name, key)
+			}
+		}
+	}
+}
+
+// TestParseNATWizardForm tests NAT wizard form validation
+func TestParseNATWizardForm(t *testing.T) {
+	valid := []string{"203.0.113.7", "192.168.1.0/24, 10.8.0.0/16", "10000", "20000", "", "remote-worker-nat"}
+	settings, profiles, err := parseNATWizardForm(valid)
+	if err != nil {
+		t.Fatalf("Expected valid form, got %v", err)
+	}
+	if len(settings.LocalNets) != 2 || strings.Join(profiles, ",") != "remote-worker-nat" {
+		t.Errorf("Unexpected settings %+v or profiles %v", settings, profiles)
+	}
+
+	invalid := map[string]map[int]string{
+		"address":     {natFieldExternalAddress: "bad host!"},
+		"cidr":        {natFieldLocalNets: "192.168.1.0"},
+		"start":       {natFieldRTPStart: "abc"},
+		"low port":    {natFieldRTPStart: "80"},
+		"range order": {natFieldRTPStart: "20000", natFieldRTPEnd: "10000"},
+		"high port":   {natFieldRTPEnd: "70000"},
+		"stun server": {natFieldSTUNServer: "stun.example.com"},
+	}
+	for name, changes := range invalid {
+		t.Run(name, func(t *testing.T) {
+			values := append([]string(nil), valid...)
+			for field, value := range changes {
+				values[field] = value
+			}
+			if _, _, err := parseNATWizardForm(values); err == nil {
+				t.Error("Expected validation error")
+			}
+		})
+	}
+}
@@ -0,0 +1,747 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// DefaultPhoneBackupDir holds the config snapshots, one directory per MAC
+const DefaultPhoneBackupDir = "/var/lib/rayanpbx/phone-backups"
+
+// configMask replaces sensitive values in diffs
+const configMask = "********"
+
+// phoneBackupDiffRows is the number of diff lines shown at once on the backups screen
+const phoneBackupDiffRows = 25
+
+// Kinds of config changes
+const (
+	configAdded   = "added"
+	configRemoved = "removed"
+	configChanged = "changed"
+)
+
+// sensitiveConfigWords mark parameter names whose values are masked in diffs
+var sensitiveConfigWords = []string{"pass", "pwd", "secret", "token", "private"}
+
+// grandStreamSensitiveParams are GrandStream P-values holding passwords
+var grandStreamSensitiveParams = map[string]bool{
+	gsPAdminPassword:      true,
+	"P196":                true, // End user password
+	PAuthPassword:         true,
+	gsPConfigHTTPPassword: true,
+}
+
+// grandStreamBackupParams are read through the session API when api-get_config is unavailable
+var grandStreamBackupParams = []string{
+	PAccountActive, PAccountName, PSIPServer, PSecondarySIPServer, POutboundProxy, PBackupOutboundProxy,
+	PBLFServer, PSIPUserID, PAuthID, PAuthPassword, PDisplayName, PVoicemail, PAccountDisplay,
+	gsPAdminPassword, gsPNTPServer, gsPConfigUpgradeVia, gsPConfigServerPath, gsPConfigHTTPUser, gsPConfigHTTPPassword,
+}
+
+// PhoneConfigSnapshot is a versioned copy of a phone's full parameter set
+type PhoneConfigSnapshot struct {
+	MAC      string            `json:"mac"`
+	IP       string            `json:"ip"`
+	Vendor   string            `json:"vendor"`
+	Model    string            `json:"model,omitempty"`
+	Firmware string            `json:"firmware,omitempty"`
+	Version  int               `json:"version"`
+	TakenAt  time.Time         `json:"taken_at"`
+	Note     string            `json:"note,omitempty"`
+	Params   map[string]string `json:"params"`
+}
+
+// ConfigChange is one parameter that differs between two configs
+type ConfigChange struct {
+	Key  string `json:"key"`
+	Kind string `json:"kind"` // added, removed or changed
+	Old  string `json:"old,omitempty"`
+	New  string `json:"new,omitempty"`
+}
+
+// phoneBackupDirPath returns the snapshot directory, overridable with RAYANPBX_PHONE_BACKUPS
+func phoneBackupDirPath() string {
+	return getEnv("RAYANPBX_PHONE_BACKUPS", DefaultPhoneBackupDir)
+}
+
+// PhoneBackupStore keeps snapshots as <dir>/<mac>/<version>.json
+type PhoneBackupStore struct {
+	Dir string
+}
+
+// NewPhoneBackupStore returns the store rooted at dir
+func NewPhoneBackupStore(dir string) *PhoneBackupStore {
+	return &PhoneBackupStore{Dir: dir}
+}
+
+// Save stores a snapshot as the next version of its phone. Snapshots hold passwords, so
+// they are only readable by the owner.
+func (s *PhoneBackupStore) Save(snapshot *PhoneConfigSnapshot) error {
+	mac := normalizeMAC(snapshot.MAC)
+	if mac == "" {
+		return fmt.Errorf("a config snapshot needs the phone's MAC address")
+	}
+	existing, err := s.List(mac)
+	if err != nil {
+		return err
+	}
+	snapshot.MAC = formatMAC(mac)
+	snapshot.Version = 1
+	if len(existing) > 0 {
+		snapshot.Version = existing[len(existing)-1].Version + 1
+	}
+
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode config snapshot: %v", err)
+	}
+	dir := filepath.Join(s.Dir, mac)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%06d.json", snapshot.Version))
+	if err := os.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to save config snapshot: %v", err)
+	}
+	return nil
+}
+
+// List returns the snapshots of a phone, oldest first
+func (s *PhoneBackupStore) List(mac string) ([]PhoneConfigSnapshot, error) {
+	bare := normalizeMAC(mac)
+	if bare == "" {
+		return nil, fmt.Errorf("invalid MAC address %q", mac)
+	}
+	files, err := filepath.Glob(filepath.Join(s.Dir, bare, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list config snapshots: %v", err)
+	}
+	var snapshots []PhoneConfigSnapshot
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config snapshot: %v", err)
+		}
+		var snapshot PhoneConfigSnapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			return nil, fmt.Errorf("failed to parse config snapshot %s: %v", path, err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Version < snapshots[j].Version })
+	return snapshots, nil
+}
+
+// Load returns one version of a phone's snapshots, or the latest when version is 0
+func (s *PhoneBackupStore) Load(mac string, version int) (*PhoneConfigSnapshot, error) {
+	snapshots, err := s.List(mac)
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshots) == 0 {
+		return nil, fmt.Errorf("no config snapshots of %s", formatMAC(mac))
+	}
+	if version == 0 {
+		return &snapshots[len(snapshots)-1], nil
+	}
+	for i := range snapshots {
+		if snapshots[i].Version == version {
+			return &snapshots[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%s has no config snapshot version %d", formatMAC(mac), version)
+}
+
+// Latest returns the newest snapshot of every phone with backups
+func (s *PhoneBackupStore) Latest() ([]PhoneConfigSnapshot, error) {
+	dirs, err := filepath.Glob(filepath.Join(s.Dir, "*"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list config snapshots: %v", err)
+	}
+	var latest []PhoneConfigSnapshot
+	for _, dir := range dirs {
+		mac := filepath.Base(dir)
+		if normalizeMAC(mac) != mac {
+			continue
+		}
+		snapshots, err := s.List(mac)
+		if err != nil {
+			return nil, err
+		}
+		if len(snapshots) > 0 {
+			latest = append(latest, snapshots[len(snapshots)-1])
+		}
+	}
+	return latest, nil
+}
+
+// ResolveMAC returns the MAC of a phone given by MAC, or by the IP of its latest snapshot
+func (s *PhoneBackupStore) ResolveMAC(key string) (string, error) {
+	if mac := normalizeMAC(key); mac != "" {
+		return formatMAC(mac), nil
+	}
+	latest, err := s.Latest()
+	if err != nil {
+		return "", err
+	}
+	for _, snapshot := range latest {
+		if snapshot.IP == key {
+			return snapshot.MAC, nil
+		}
+	}
+	return "", fmt.Errorf("no config snapshots of %s", key)
+}
+
+// IsSensitiveConfigKey reports whether a parameter holds a password or other secret
+func IsSensitiveConfigKey(key string) bool {
+	if grandStreamSensitiveParams[strings.ToUpper(key)] {
+		return true
+	}
+	lower := strings.ToLower(key)
+	for _, word := range sensitiveConfigWords {
+		if strings.Contains(lower, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// maskConfigValue hides the value of a sensitive parameter
+func maskConfigValue(key, value string) string {
+	if value != "" && IsSensitiveConfigKey(key) {
+		return configMask
+	}
+	return value
+}
+
+// DiffPhoneConfigs lists the parameters that differ from one config to another, sorted by
+// key. Sensitive values are masked; a changed password still shows up as a change.
+func DiffPhoneConfigs(from, to map[string]string) []ConfigChange {
+	var changes []ConfigChange
+	for key, old := range from {
+		value, ok := to[key]
+		switch {
+		case !ok:
+			changes = append(changes, ConfigChange{Key: key, Kind: configRemoved, Old: maskConfigValue(key, old)})
+		case value != old:
+			changes = append(changes, ConfigChange{Key: key, Kind: configChanged, Old: maskConfigValue(key, old), New: maskConfigValue(key, value)})
+		}
+	}
+	for key, value := range to {
+		if _, ok := from[key]; !ok {
+			changes = append(changes, ConfigChange{Key: key, Kind: configAdded, New: maskConfigValue(key, value)})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
+
+// SelectRestoreParams picks the snapshot parameters to restore. Patterns are keys, or key
+// prefixes ending in "*"; without patterns every parameter is picked. Parameters the live
+// phone already has are left out when live is given.
+func SelectRestoreParams(snapshot, live map[string]string, patterns []string) (map[string]string, error) {
+	selected := make(map[string]string)
+	for _, pattern := range patterns {
+		matched := false
+		for key, value := range snapshot {
+			if key == pattern || (strings.HasSuffix(pattern, "*") && strings.HasPrefix(key, strings.TrimSuffix(pattern, "*"))) {
+				selected[key] = value
+				matched = true
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("the snapshot has no parameter %q", pattern)
+		}
+	}
+	if len(patterns) == 0 {
+		for key, value := range snapshot {
+			selected[key] = value
+		}
+	}
+	if live != nil {
+		for key, value := range selected {
+			if current, ok := live[key]; ok && current == value {
+				delete(selected, key)
+			}
+		}
+	}
+	return selected, nil
+}
+
+// configValueString converts a config value from a phone API to text
+func configValueString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+// grandStreamSession returns a session API session for a GrandStream phone
+func grandStreamSession(sessions *GrandStreamSessionManager, gs *GrandStreamPhone) (*GrandStreamSession, error) {
+	username := gs.credentials["username"]
+	if username == "" {
+		username = "admin"
+	}
+	return sessions.GetOrCreateSession(gs.ip, username, gs.credentials["password"])
+}
+
+// ReadPhoneConfig pulls the full parameter set of a phone. GrandStream phones without
+// api-get_config are read through GetParameters instead.
+func ReadPhoneConfig(phone VoIPPhone, sessions *GrandStreamSessionManager) (map[string]string, error) {
+	config, err := phone.GetConfig()
+	if gs, ok := phone.(*GrandStreamPhone); ok && (err != nil || len(config) == 0) {
+		session, loginErr := grandStreamSession(sessions, gs)
+		if loginErr != nil {
+			return nil, fmt.Errorf("failed to read config: %v", loginErr)
+		}
+		if config, err = sessions.GetParameters(session, grandStreamBackupParams); err != nil {
+			return nil, fmt.Errorf("failed to read config: %v", err)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	params := make(map[string]string, len(config))
+	for key, value := range config {
+		params[key] = configValueString(value)
+	}
+	return params, nil
+}
+
+// WritePhoneConfig sets parameters on a phone, through SetParameters on GrandStream phones
+func WritePhoneConfig(phone VoIPPhone, sessions *GrandStreamSessionManager, params map[string]string) error {
+	if gs, ok := phone.(*GrandStreamPhone); ok {
+		session, err := grandStreamSession(sessions, gs)
+		if err != nil {
+			return fmt.Errorf("failed to restore config: %v", err)
+		}
+		if err := sessions.SetParameters(session, params); err != nil {
+			return fmt.Errorf("failed to restore config: %v", err)
+		}
+		return nil
+	}
+	config := make(map[string]interface{}, len(params))
+	for key, value := range params {
+		config[key] = value
+	}
+	return phone.SetConfig(config)
+}
+
+// openConfigPhone creates the client of a phone that can read and write its config
+func openConfigPhone(pm *PhoneManager, phone BulkPhone) (VoIPPhone, string, error) {
+	vp, result, ok := openBulkPhone(pm, phone, CapGetConfig, "config backup")
+	if !ok {
+		return nil, "", errors.New(result.Message)
+	}
+	return vp, result.Vendor, nil
+}
+
+// BackupPhoneConfig reads a phone's parameters and saves them as its next snapshot
+func BackupPhoneConfig(pm *PhoneManager, sessions *GrandStreamSessionManager, store *PhoneBackupStore, phone BulkPhone, note string) (*PhoneConfigSnapshot, error) {
+	vp, vendor, err := openConfigPhone(pm, phone)
+	if err != nil {
+		return nil, err
+	}
+	params, err := ReadPhoneConfig(vp, sessions)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &PhoneConfigSnapshot{MAC: phone.MAC, IP: phone.IP, Vendor: vendor, Model: phone.Model, Firmware: phone.Firmware,
+		TakenAt: time.Now(), Note: note, Params: params}
+	if status, err := vp.GetStatus(); err == nil {
+		if snapshot.MAC == "" {
+			snapshot.MAC = status.MAC
+		}
+		if status.Model != "" {
+			snapshot.Model = status.Model
+		}
+		if status.Firmware != "" {
+			snapshot.Firmware = status.Firmware
+		}
+	}
+	if normalizeMAC(snapshot.MAC) == "" {
+		return nil, fmt.Errorf("phone %s did not report its MAC address", phone.IP)
+	}
+	if err := store.Save(snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// DiffPhoneLive compares a snapshot with the phone's current parameters
+func DiffPhoneLive(pm *PhoneManager, sessions *GrandStreamSessionManager, phone BulkPhone, snapshot *PhoneConfigSnapshot) ([]ConfigChange, error) {
+	vp, _, err := openConfigPhone(pm, phone)
+	if err != nil {
+		return nil, err
+	}
+	live, err := ReadPhoneConfig(vp, sessions)
+	if err != nil {
+		return nil, err
+	}
+	return DiffPhoneConfigs(snapshot.Params, live), nil
+}
+
+// RestorePhoneSnapshot writes the selected snapshot parameters that differ from the phone
+// back to it, after saving the current config as a new snapshot. It returns the restored keys.
+func RestorePhoneSnapshot(pm *PhoneManager, sessions *GrandStreamSessionManager, store *PhoneBackupStore, phone BulkPhone, snapshot *PhoneConfigSnapshot, patterns []string) ([]string, error) {
+	vp, vendor, err := openConfigPhone(pm, phone)
+	if err != nil {
+		return nil, err
+	}
+	live, err := ReadPhoneConfig(vp, sessions)
+	if err != nil {
+		return nil, err
+	}
+	params, err := SelectRestoreParams(snapshot.Params, live, patterns)
+	if err != nil {
+		return nil, err
+	}
+	if len(params) == 0 {
+		return nil, nil
+	}
+
+	before := &PhoneConfigSnapshot{MAC: snapshot.MAC, IP: phone.IP, Vendor: vendor, Model: snapshot.Model, Firmware: phone.Firmware,
+		TakenAt: time.Now(), Note: fmt.Sprintf("before restoring version %d", snapshot.Version), Params: live}
+	if err := store.Save(before); err != nil {
+		return nil, err
+	}
+	if err := WritePhoneConfig(vp, sessions, params); err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// phoneBackupMsg carries the outcome of a backup, live diff or restore on the backups screen
+type phoneBackupMsg struct {
+	action   string // backup, diff or restore
+	snapshot *PhoneConfigSnapshot
+	changes  []ConfigChange
+	restored []string
+	err      error
+}
+
+// initPhoneBackups opens the config snapshots of the selected inventory phone
+func (m *model) initPhoneBackups() {
+	phone := m.selectedInventoryPhone()
+	switch {
+	case phone == nil:
+		m.errorMsg = "No phone selected"
+		return
+	case phone.MAC == "":
+		m.errorMsg = "The phone's MAC address is unknown - snapshots are kept per MAC"
+		return
+	}
+	m.backupPhone = BulkPhone{IP: phone.IP, MAC: phone.MAC, Name: phone.Name, Vendor: inventoryPhoneVendor(*phone),
+		Model: phone.Model, Firmware: phone.Firmware, Credentials: m.phoneCredentials[phone.IP]}
+	m.currentScreen = phoneBackupsScreen
+	m.errorMsg = ""
+	m.successMsg = ""
+	m.backupCursor = 0
+	m.backupDiffTitle = ""
+	m.backupBusy = false
+	m.refreshPhoneBackups()
+}
+
+// refreshPhoneBackups reloads the snapshots, newest first
+func (m *model) refreshPhoneBackups() {
+	snapshots, err := NewPhoneBackupStore(phoneBackupDirPath()).List(m.backupPhone.MAC)
+	if err != nil {
+		m.errorMsg = err.Error()
+	}
+	for i, j := 0, len(snapshots)-1; i < j; i, j = i+1, j-1 {
+		snapshots[i], snapshots[j] = snapshots[j], snapshots[i]
+	}
+	m.backupSnapshots = snapshots
+	if m.backupCursor >= len(snapshots) {
+		m.backupCursor = 0
+	}
+}
+
+// selectedPhoneBackup returns the snapshot under the cursor
+func (m *model) selectedPhoneBackup() *PhoneConfigSnapshot {
+	if m.backupCursor < len(m.backupSnapshots) {
+		return &m.backupSnapshots[m.backupCursor]
+	}
+	return nil
+}
+
+// startPhoneBackupAction runs a backup, live diff or restore in the background
+func (m *model) startPhoneBackupAction(action string, patterns []string) tea.Cmd {
+	phone := m.backupPhone
+	if phone.IP == "" {
+		m.errorMsg = "The phone has no known address"
+		return nil
+	}
+	if phone.Credentials["password"] == "" {
+		m.errorMsg = "No credentials stored for " + phone.IP + " - add them on the VoIP Phones screen"
+		return nil
+	}
+	snapshot := m.selectedPhoneBackup()
+	if action != "backup" && snapshot == nil {
+		m.errorMsg = "No snapshot selected - press s to take one"
+		return nil
+	}
+	if m.phoneManager == nil {
+		m.phoneManager = NewPhoneManager(m.asteriskManager)
+	}
+	pm := m.phoneManager
+	store := NewPhoneBackupStore(phoneBackupDirPath())
+	m.backupBusy = true
+	m.errorMsg = ""
+	m.successMsg = fmt.Sprintf("Reading the configuration of %s...", phone.IP)
+	return func() tea.Msg {
+		sessions := NewGrandStreamSessionManager(nil)
+		msg := phoneBackupMsg{action: action, snapshot: snapshot}
+		switch action {
+		case "backup":
+			msg.snapshot, msg.err = BackupPhoneConfig(pm, sessions, store, phone, "")
+		case "diff":
+			msg.changes, msg.err = DiffPhoneLive(pm, sessions, phone, snapshot)
+		case "restore":
+			msg.restored, msg.err = RestorePhoneSnapshot(pm, sessions, store, phone, snapshot, patterns)
+		}
+		return msg
+	}
+}
+
+// finishPhoneBackupAction shows the outcome of a backup, live diff or restore
+func (m *model) finishPhoneBackupAction(msg phoneBackupMsg) {
+	m.backupBusy = false
+	m.errorMsg = ""
+	m.successMsg = ""
+	if msg.err != nil {
+		m.errorMsg = fmt.Sprintf("Failed to %s %s: %v", msg.action, m.backupPhone.IP, msg.err)
+		return
+	}
+	switch msg.action {
+	case "backup":
+		m.backupCursor = 0
+		m.successMsg = fmt.Sprintf("Saved version %d with %d parameters", msg.snapshot.Version, len(msg.snapshot.Params))
+	case "diff":
+		m.showPhoneBackupDiff(fmt.Sprintf("Version %d → live phone", msg.snapshot.Version), msg.changes)
+	case "restore":
+		if len(msg.restored) == 0 {
+			m.successMsg = fmt.Sprintf("The phone already matches version %d", msg.snapshot.Version)
+			return
+		}
+		m.backupCursor = 0
+		m.successMsg = fmt.Sprintf("Restored %d parameter(s) from version %d - the previous config was saved first", len(msg.restored), msg.snapshot.Version)
+	}
+	m.refreshPhoneBackups()
+}
+
+// showPhoneBackupDiff opens the diff view
+func (m *model) showPhoneBackupDiff(title string, changes []ConfigChange) {
+	m.backupDiffTitle = title
+	m.backupDiff = changes
+	m.backupDiffOffset = 0
+}
+
+// comparePreviousBackup diffs the selected snapshot with the one before it
+func (m *model) comparePreviousBackup() {
+	if m.backupCursor+1 >= len(m.backupSnapshots) {
+		m.errorMsg = "Select a snapshot with an older version to compare with"
+		return
+	}
+	older, newer := m.backupSnapshots[m.backupCursor+1], m.backupSnapshots[m.backupCursor]
+	m.errorMsg = ""
+	m.showPhoneBackupDiff(fmt.Sprintf("Version %d → version %d", older.Version, newer.Version), DiffPhoneConfigs(older.Params, newer.Params))
+}
+
+// initPhoneRestoreForm asks which parameters of the selected snapshot to restore
+func (m *model) initPhoneRestoreForm() {
+	if m.selectedPhoneBackup() == nil {
+		m.errorMsg = "No snapshot selected - press s to take one"
+		return
+	}
+	m.inputFields = []string{"Keys to restore (comma-separated, prefix* allowed, empty for all)"}
+	m.inputValues = []string{""}
+	m.inputMode = true
+	m.inputCursor = 0
+	m.errorMsg = ""
+	m.successMsg = ""
+}
+
+// submitPhoneRestoreForm starts restoring the keys from the restore form
+func (m *model) submitPhoneRestoreForm() tea.Cmd {
+	var patterns []string
+	for _, key := range strings.Split(m.inputValues[0], ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			patterns = append(patterns, key)
+		}
+	}
+	if _, err := SelectRestoreParams(m.selectedPhoneBackup().Params, nil, patterns); err != nil {
+		m.errorMsg = err.Error()
+		return nil
+	}
+	m.inputMode = false
+	return m.startPhoneBackupAction("restore", patterns)
+}
+
+// handlePhoneBackupsScreen handles keys on the config snapshots screen
+func (m *model) handlePhoneBackupsScreen(msg tea.KeyMsg) (*model, tea.Cmd) {
+	if msg.String() == "q" || msg.String() == "ctrl+c" {
+		return m, tea.Quit
+	}
+	if m.backupBusy {
+		return m, nil // Wait for the phone
+	}
+	if m.backupDiffTitle != "" {
+		switch msg.String() {
+		case "up", "k":
+			if m.backupDiffOffset > 0 {
+				m.backupDiffOffset--
+			}
+		case "down", "j":
+			if m.backupDiffOffset+phoneBackupDiffRows < len(m.backupDiff) {
+				m.backupDiffOffset++
+			}
+		case "esc", "enter":
+			m.backupDiffTitle = ""
+			m.backupDiff = nil
+		}
+		return m, nil
+	}
+
+	switch msg.String() {
+	case "up", "k":
+		if m.backupCursor > 0 {
+			m.backupCursor--
+		} else if len(m.backupSnapshots) > 0 {
+			m.backupCursor = len(m.backupSnapshots) - 1
+		}
+	case "down", "j":
+		if m.backupCursor < len(m.backupSnapshots)-1 {
+			m.backupCursor++
+		} else {
+			m.backupCursor = 0
+		}
+	case "s":
+		return m, m.startPhoneBackupAction("backup", nil)
+	case "enter", "d":
+		return m, m.startPhoneBackupAction("diff", nil)
+	case "c":
+		m.comparePreviousBackup()
+	case "R":
+		m.initPhoneRestoreForm()
+	case "r":
+		m.errorMsg = ""
+		m.successMsg = ""
+		m.refreshPhoneBackups()
+	case "esc":
+		m.errorMsg = ""
+		m.successMsg = ""
+		m.currentScreen = phoneInventoryScreen
+	}
+	return m, nil
+}
+
+// renderPhoneBackups renders the snapshot list, the open diff or the restore form
+func (m model) renderPhoneBackups() string {
+	phone := m.backupPhone
+	content := infoStyle.Render("🗄️  Config Snapshots") + "\n\n"
+	content += fmt.Sprintf("%s  %s  %s %s\n\n", phone.MAC, phone.IP, phone.Vendor, phone.Model)
+
+	if m.inputMode {
+		if snapshot := m.selectedPhoneBackup(); snapshot != nil {
+			content += fmt.Sprintf("Restore from version %d\n\n", snapshot.Version)
+		}
+		for i, field := range m.inputFields {
+			cursor := "  "
+			fieldStyle := lipgloss.NewStyle()
+			if i == m.inputCursor {
+				cursor = "▶ "
+				fieldStyle = selectedItemStyle
+			}
+			value := m.inputValues[i]
+			if value == "" {
+				value = helpStyle.Render("<empty>")
+			}
+			content += fmt.Sprintf("%s%s: %s\n", cursor, fieldStyle.Render(field), value)
+		}
+		content += "\n" + helpStyle.Render("Only keys that differ from the phone are written; the current config is saved first") + "\n"
+		return menuStyle.Render(content)
+	}
+
+	if m.backupDiffTitle != "" {
+		content += fmt.Sprintf("%s • %d change(s)\n\n", m.backupDiffTitle, len(m.backupDiff))
+		if len(m.backupDiff) == 0 {
+			content += successStyle.Render("✓ No differences") + "\n"
+			return menuStyle.Render(content)
+		}
+		end := m.backupDiffOffset + phoneBackupDiffRows
+		if end > len(m.backupDiff) {
+			end = len(m.backupDiff)
+		}
+		for _, change := range m.backupDiff[m.backupDiffOffset:end] {
+			switch change.Kind {
+			case configAdded:
+				content += successStyle.Render(fmt.Sprintf("+ %s = %s", change.Key, change.New)) + "\n"
+			case configRemoved:
+				content += errorStyle.Render(fmt.Sprintf("- %s = %s", change.Key, change.Old)) + "\n"
+			default:
+				content += warningStyle.Render(fmt.Sprintf("~ %s: %s → %s", change.Key, change.Old, change.New)) + "\n"
+			}
+		}
+		if len(m.backupDiff) > phoneBackupDiffRows {
+			content += helpStyle.Render(fmt.Sprintf("\nLines %d-%d of %d", m.backupDiffOffset+1, end, len(m.backupDiff))) + "\n"
+		}
+		return menuStyle.Render(content)
+	}
+
+	if len(m.backupSnapshots) == 0 {
+		content += "📭 No snapshots yet - press s to back up the phone's configuration\n"
+		return menuStyle.Render(content)
+	}
+	content += helpStyle.Render(fmt.Sprintf("  %-7s  %-19s  %-15s  %-14s  %6s  %s", "Version", "Taken", "IP", "Firmware", "Params", "Note")) + "\n"
+	for i, snapshot := range m.backupSnapshots {
+		line := fmt.Sprintf("%-7d  %-19s  %-15s  %-14s  %6d  %s", snapshot.Version, snapshot.TakenAt.Format("2006-01-02 15:04:05"),
+			snapshot.IP, truncateText(snapshot.Firmware, 14), len(snapshot.Params), snapshot.Note)
+		if i == m.backupCursor {
+			content += "▶ " + selectedItemStyle.Render(line) + "\n"
+		} else {
+			content += "  " + line + "\n"
+		}
+	}
+	return menuStyle.Render(content)
+}
+
+// phoneBackupsHelp returns the key help for the config snapshots screen
+func (m model) phoneBackupsHelp() string {
+	switch {
+	case m.inputMode:
+		return "Enter: Restore • ESC: Cancel • q: Quit"
+	case m.backupBusy:
+		return "Talking to the phone... • q: Quit"
+	case m.backupDiffTitle != "":
+		return "↑/↓: Scroll • Enter/ESC: Close Diff • q: Quit"
+	}
+	return "↑/↓: Navigate • s: Snapshot Now • Enter/d: Diff with Phone • c: Diff with Previous • R: Restore • r: Refresh • ESC: Back • q: Quit"
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestDiffPhoneConfigs(t *testing.T) {
+	from := map[string]string{"account.1.user_name": "101", "account.1.password": "old", "P2": "admin", "P30": "pool.ntp.org", "P64": "TZA"}
+	to := map[string]string{"account.1.user_name": "102", "account.1.password": "new", "P2": "admin", "P30": "pool.ntp.org", "P1362": "en"}
+
+	changes := DiffPhoneConfigs(from, to)
+	want := []ConfigChange{
+		{Key: "P1362", Kind: configAdded, New: "en"},
+		{Key: "P64", Kind: configRemoved, Old: "TZA"},
+		{Key: "account.1.password", Kind: configChanged, Old: configMask, New: configMask},
+		{Key: "account.1.user_name", Kind: configChanged, Old: "101", New: "102"},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("expected %d changes, got %+v", len(want), changes)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("change %d: expected %+v, got %+v", i, want[i], changes[i])
+		}
+	}
+
+	for key, sensitive := range map[string]bool{"P2": true, "p34": true, "sip.token": true, "account.1.user_name": false, "P30": false} {
+		if IsSensitiveConfigKey(key) != sensitive {
+			t.Errorf("IsSensitiveConfigKey(%q) = %v", key, !sensitive)
+		}
+	}
+}
+
+func TestSelectRestoreParams(t *testing.T) {
+	snapshot := map[string]string{"account.1.user_name": "101", "account.1.password": "secret", "account.2.user_name": "102", "P30": "ntp"}
+	live := map[string]string{"account.1.user_name": "150", "account.1.password": "secret"}
+
+	params, err := SelectRestoreParams(snapshot, live, []string{"account.1.*", "P30"})
+	if err != nil {
+		t.Fatalf("SelectRestoreParams: %v", err)
+	}
+	if len(params) != 2 || params["account.1.user_name"] != "101" || params["P30"] != "ntp" {
+		t.Errorf("expected only the differing keys, got %v", params)
+	}
+	if params, _ := SelectRestoreParams(snapshot, live, nil); len(params) != 3 {
+		t.Errorf("expected every differing key without patterns, got %v", params)
+	}
+	if _, err := SelectRestoreParams(snapshot, nil, []string{"P99"}); err == nil {
+		t.Error("expected an error for a key the snapshot lacks")
+	}
+}
+
+func TestPhoneBackupStore(t *testing.T) {
+	store := NewPhoneBackupStore(t.TempDir())
+	if err := store.Save(&PhoneConfigSnapshot{IP: "192.0.2.10"}); err == nil {
+		t.Error("expected snapshots without a MAC to be rejected")
+	}
+	for i := 0; i < 2; i++ {
+		snapshot := &PhoneConfigSnapshot{MAC: "805EC0112233", IP: "192.0.2.10", TakenAt: time.Now(), Params: map[string]string{"n": string(rune('a' + i))}}
+		if err := store.Save(snapshot); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+		if snapshot.Version != i+1 || snapshot.MAC != "80:5e:c0:11:22:33" {
+			t.Errorf("unexpected snapshot %d: %+v", i, snapshot)
+		}
+	}
+
+	info, err := os.Stat(filepath.Join(store.Dir, "805ec0112233", "000002.json"))
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("expected a private snapshot file, got %v %v", info, err)
+	}
+	latest, err := store.Load("80-5E-C0-11-22-33", 0)
+	if err != nil || latest.Version != 2 || latest.Params["n"] != "b" {
+		t.Errorf("expected version 2 as the latest, got %+v %v", latest, err)
+	}
+	if _, err := store.Load("80:5e:c0:11:22:33", 5); err == nil {
+		t.Error("expected an error for a missing version")
+	}
+	if mac, err := store.ResolveMAC("192.0.2.10"); err != nil || mac != "80:5e:c0:11:22:33" {
+		t.Errorf("expected the IP to resolve through the snapshots, got %q %v", mac, err)
+	}
+}
+
+func TestPhoneBackupAndRestore(t *testing.T) {
+	fake, phone := newFakeYealink(t)
+	pm := NewPhoneManager(NewAsteriskManager())
+	sessions := NewGrandStreamSessionManager(nil)
+	store := NewPhoneBackupStore(t.TempDir())
+	target := BulkPhone{IP: phone.ip, Vendor: "yealink", Credentials: map[string]string{"username": "admin", "password": "secret"}}
+
+	snapshot, err := BackupPhoneConfig(pm, sessions, store, target, "before change")
+	if err != nil {
+		t.Fatalf("BackupPhoneConfig: %v", err)
+	}
+	if snapshot.Version != 1 || snapshot.MAC != "80:5e:c0:11:22:33" || snapshot.Model != "SIP-T46S" || snapshot.Params["local_time.time_zone"] != "+3:30" {
+		t.Fatalf("unexpected snapshot: %+v", snapshot)
+	}
+	changes, err := DiffPhoneLive(pm, sessions, target, snapshot)
+	if err != nil || len(changes) != 0 {
+		t.Fatalf("expected no changes against the live phone, got %+v %v", changes, err)
+	}
+
+	// An older config in which line 1 had another user and a password
+	older := &PhoneConfigSnapshot{MAC: snapshot.MAC, IP: target.IP, Params: map[string]string{
+		"account.1.user_name": "150", "account.1.password": "hunter2", "local_time.time_zone": "+3:30"}}
+	if err := store.Save(older); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	changes, _ = DiffPhoneLive(pm, sessions, target, older)
+	if len(changes) != 2 || changes[0].Kind != configRemoved || changes[0].Old != configMask || changes[1].Old != "150" {
+		t.Errorf("expected a masked password and a changed user, got %+v", changes)
+	}
+
+	restored, err := RestorePhoneSnapshot(pm, sessions, store, target, older, []string{"account.1.user_name"})
+	if err != nil {
+		t.Fatalf("RestorePhoneSnapshot: %v", err)
+	}
+	if len(restored) != 1 || !strings.Contains(fake.imported, "account.1.user_name = 150") || strings.Contains(fake.imported, "hunter2") {
+		t.Errorf("expected only the user name to be restored, got %v %q", restored, fake.imported)
+	}
+	before, err := store.Load(snapshot.MAC, 0)
+	if err != nil || before.Version != 3 || before.Params["account.1.user_name"] != "101" {
+		t.Errorf("expected the live config to be saved before restoring, got %+v %v", before, err)
+	}
+}
+
+func TestPhoneBackupsScreen(t *testing.T) {
+	t.Setenv("RAYANPBX_PHONE_BACKUPS", t.TempDir())
+	store := NewPhoneBackupStore(phoneBackupDirPath())
+	for _, params := range []map[string]string{{"P30": "pool.ntp.org", "P34": "old"}, {"P30": "ntp.local", "P34": "new"}} {
+		store.Save(&PhoneConfigSnapshot{MAC: "00:0b:82:11:22:33", IP: "192.0.2.20", TakenAt: time.Now(), Params: params})
+	}
+
+	m := initialModel(nil, nil, false)
+	m.currentScreen = phoneInventoryScreen
+	m.inventoryPhones = []InventoryPhone{{VoIPPhoneDB: VoIPPhoneDB{ID: 1, IP: "192.0.2.20", MAC: "00:0b:82:11:22:33", Vendor: "grandstream"}}}
+	m.handlePhoneInventoryScreen(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'b'}})
+	if m.currentScreen != phoneBackupsScreen || len(m.backupSnapshots) != 2 || m.backupSnapshots[0].Version != 2 {
+		t.Fatalf("expected the backups screen with the newest snapshot first, got screen %d %+v", m.currentScreen, m.backupSnapshots)
+	}
+
+	m.handlePhoneBackupsScreen(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'c'}})
+	view := m.renderPhoneBackups()
+	if !strings.Contains(view, "Version 1 → version 2") || !strings.Contains(view, "pool.ntp.org → ntp.local") || strings.Contains(view, "old") {
+		t.Errorf("expected a masked diff of the two versions, got %s", view)
+	}
+	m.handlePhoneBackupsScreen(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.backupDiffTitle != "" || m.currentScreen != phoneBackupsScreen {
+		t.Fatal("expected ESC to close the diff first")
+	}
+
+	// Live operations need the phone's credentials
+	m.handlePhoneBackupsScreen(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'s'}})
+	if m.backupBusy || !strings.Contains(m.errorMsg, "No credentials") {
+		t.Errorf("expected a missing credentials error, got %q", m.errorMsg)
+	}
+	m.handlePhoneBackupsScreen(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'R'}})
+	m.inputValues[0] = "P99"
+	m.submitPhoneRestoreForm()
+	if !m.inputMode || !strings.Contains(m.errorMsg, "P99") {
+		t.Errorf("expected unknown keys to be rejected, got %q", m.errorMsg)
+	}
+	m.inputMode = false
+	m.handlePhoneBackupsScreen(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.currentScreen != phoneInventoryScreen {
+		t.Error("expected ESC to return to the inventory")
+	}
+}
@@ -0,0 +1,972 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// DefaultPhoneFiltersFile holds the saved phone filters
+const DefaultPhoneFiltersFile = "/etc/rayanpbx/phone-filters.json"
+
+// DefaultBulkReportPath is where the TUI writes the report of a bulk operation
+const DefaultBulkReportPath = "/tmp/rayanpbx-phone-bulk.json"
+
+// Defaults for bulk phone operations
+const (
+	DefaultBulkWorkers = 8
+	DefaultBulkTimeout = 60 * time.Second
+	maxBulkWorkers     = 64
+)
+
+// Bulk phone actions
+const (
+	bulkActionReboot    = "reboot"
+	bulkActionProvision = "provision"
+	bulkActionSetConfig = "set-config"
+	bulkActionFirmware  = "firmware"
+	bulkActionEnableCTI = "enable-cti"
+)
+
+// bulkActions lists the bulk actions in menu order
+var bulkActions = []string{bulkActionReboot, bulkActionProvision, bulkActionSetConfig, bulkActionFirmware, bulkActionEnableCTI}
+
+// bulkActionCapabilities is the driver capability each bulk action needs
+var bulkActionCapabilities = map[string]PhoneCapability{
+	bulkActionReboot:    CapReboot,
+	bulkActionProvision: CapSetConfig,
+	bulkActionSetConfig: CapSetConfig,
+	bulkActionFirmware:  CapFirmware,
+	bulkActionEnableCTI: CapCTIFeatures,
+}
+
+// BulkPhone is a phone a bulk operation can target
+type BulkPhone struct {
+	IP          string            `json:"ip"`
+	MAC         string            `json:"mac,omitempty"`
+	Name        string            `json:"name,omitempty"`
+	Vendor      string            `json:"vendor,omitempty"` // Driver vendor, detected over HTTP when empty
+	Model       string            `json:"model,omitempty"`
+	Firmware    string            `json:"firmware,omitempty"`
+	Extensions  []string          `json:"extensions,omitempty"`
+	Credentials map[string]string `json:"-"`
+}
+
+// PhoneFilter selects phones for bulk operations. Empty fields match every phone.
+type PhoneFilter struct {
+	Name          string `json:"name"`
+	Vendor        string `json:"vendor,omitempty"`         // Exact vendor key, e.g. yealink
+	Model         string `json:"model,omitempty"`          // Substring of the model
+	Firmware      string `json:"firmware,omitempty"`       // Firmware version prefix
+	Subnet        string `json:"subnet,omitempty"`         // CIDR the phone's IP is in
+	ExtensionFrom string `json:"extension_from,omitempty"` // Lowest extension on any line
+	ExtensionTo   string `json:"extension_to,omitempty"`   // Highest extension on any line
+}
+
+// Validate checks the subnet and extension range
+func (f PhoneFilter) Validate() error {
+	if f.Subnet != "" {
+		if _, _, err := net.ParseCIDR(f.Subnet); err != nil {
+			return fmt.Errorf("invalid subnet %q: use CIDR notation such as 192.168.1.0/24", f.Subnet)
+		}
+	}
+	for _, bound := range []string{f.ExtensionFrom, f.ExtensionTo} {
+		if _, err := strconv.Atoi(bound); bound != "" && err != nil {
+			return fmt.Errorf("invalid extension %q: the range must be numeric", bound)
+		}
+	}
+	return nil
+}
+
+// Empty reports whether the filter matches every phone
+func (f PhoneFilter) Empty() bool {
+	f.Name = ""
+	return f == PhoneFilter{}
+}
+
+// Matches reports whether a phone passes the filter
+func (f PhoneFilter) Matches(phone BulkPhone) bool {
+	if f.Vendor != "" && !strings.EqualFold(phone.Vendor, f.Vendor) {
+		return false
+	}
+	if f.Model != "" && !strings.Contains(strings.ToLower(phone.Model), strings.ToLower(f.Model)) {
+		return false
+	}
+	if f.Firmware != "" && !strings.HasPrefix(strings.ToLower(phone.Firmware), strings.ToLower(f.Firmware)) {
+		return false
+	}
+	if f.Subnet != "" {
+		_, subnet, err := net.ParseCIDR(f.Subnet)
+		ip := net.ParseIP(phone.IP)
+		if err != nil || ip == nil || !subnet.Contains(ip) {
+			return false
+		}
+	}
+	if f.ExtensionFrom != "" || f.ExtensionTo != "" {
+		from, fromErr := strconv.Atoi(f.ExtensionFrom)
+		to, toErr := strconv.Atoi(f.ExtensionTo)
+		for _, ext := range phone.Extensions {
+			n, err := strconv.Atoi(ext)
+			if err == nil && (fromErr != nil || n >= from) && (toErr != nil || n <= to) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// String describes the filter conditions
+func (f PhoneFilter) String() string {
+	var parts []string
+	add := func(label, value string) {
+		if value != "" {
+			parts = append(parts, label+"="+value)
+		}
+	}
+	add("vendor", f.Vendor)
+	add("model", f.Model)
+	add("firmware", f.Firmware)
+	add("subnet", f.Subnet)
+	if f.ExtensionFrom != "" || f.ExtensionTo != "" {
+		parts = append(parts, "extensions="+f.ExtensionFrom+"-"+f.ExtensionTo)
+	}
+	if len(parts) == 0 {
+		return "all phones"
+	}
+	return strings.Join(parts, " ")
+}
+
+// ParseExtensionRange parses "100-199" or a single extension into filter bounds
+func ParseExtensionRange(value string) (from, to string) {
+	value = strings.TrimSpace(value)
+	if i := strings.Index(value, "-"); i >= 0 {
+		return strings.TrimSpace(value[:i]), strings.TrimSpace(value[i+1:])
+	}
+	return value, value
+}
+
+// BulkPhonesFromInventory converts the inventory phones that have an address, sorted by IP
+func BulkPhonesFromInventory(inventory []InventoryPhone, credentials map[string]map[string]string) []BulkPhone {
+	var phones []BulkPhone
+	for _, p := range inventory {
+		if p.IP == "" {
+			continue
+		}
+		phone := BulkPhone{IP: p.IP, MAC: p.MAC, Name: p.Name, Vendor: inventoryPhoneVendor(p),
+			Model: p.Model, Firmware: p.Firmware, Credentials: credentials[p.IP]}
+		for _, line := range p.Lines {
+			phone.Extensions = append(phone.Extensions, line.Extension)
+		}
+		if len(phone.Extensions) == 0 && p.Extension != "" {
+			phone.Extensions = []string{p.Extension}
+		}
+		phones = append(phones, phone)
+	}
+	sort.Slice(phones, func(i, j int) bool { return phones[i].IP < phones[j].IP })
+	return phones
+}
+
+// FilterPhones returns the phones matching the filter, in order
+func FilterPhones(phones []BulkPhone, filter PhoneFilter) []BulkPhone {
+	var matched []BulkPhone
+	for _, phone := range phones {
+		if filter.Matches(phone) {
+			matched = append(matched, phone)
+		}
+	}
+	return matched
+}
+
+// phoneFiltersFilePath returns the saved filters path, overridable with RAYANPBX_PHONE_FILTERS
+func phoneFiltersFilePath() string {
+	return getEnv("RAYANPBX_PHONE_FILTERS", DefaultPhoneFiltersFile)
+}
+
+// LoadPhoneFilters reads the saved filters. A missing file means no saved filters.
+func LoadPhoneFilters(path string) ([]PhoneFilter, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read phone filters: %v", err)
+	}
+	var saved struct {
+		Filters []PhoneFilter `json:"filters"`
+	}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("failed to parse phone filters %s: %v", path, err)
+	}
+	return saved.Filters, nil
+}
+
+// SavePhoneFilter adds a named filter to the saved filters, replacing one with the same name
+func SavePhoneFilter(path string, filter PhoneFilter) ([]PhoneFilter, error) {
+	if filter.Name == "" {
+		return nil, fmt.Errorf("the filter needs a name to be saved")
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	filters, err := LoadPhoneFilters(path)
+	if err != nil {
+		return nil, err
+	}
+	replaced := false
+	for i := range filters {
+		if filters[i].Name == filter.Name {
+			filters[i] = filter
+			replaced = true
+		}
+	}
+	if !replaced {
+		filters = append(filters, filter)
+	}
+	data, err := json.MarshalIndent(struct {
+		Filters []PhoneFilter `json:"filters"`
+	}{filters}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode phone filters: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return nil, fmt.Errorf("failed to save phone filters: %v", err)
+	}
+	return filters, nil
+}
+
+// FindPhoneFilter returns the saved filter with the given name, or nil
+func FindPhoneFilter(filters []PhoneFilter, name string) *PhoneFilter {
+	for i := range filters {
+		if filters[i].Name == name {
+			return &filters[i]
+		}
+	}
+	return nil
+}
+
+// ParseConfigAssignments parses "key=value;key=value" into config parameters
+func ParseConfigAssignments(value string) (map[string]string, error) {
+	config := make(map[string]string)
+	for _, pair := range strings.Split(value, ";") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(pair, "=")
+		if key = strings.TrimSpace(key); !ok || key == "" {
+			return nil, fmt.Errorf("invalid config assignment %q: use key=value", pair)
+		}
+		config[key] = strings.TrimSpace(val)
+	}
+	if len(config) == 0 {
+		return nil, fmt.Errorf("no config keys given")
+	}
+	return config, nil
+}
+
+// BulkOperation is an action to run on many phones
+type BulkOperation struct {
+	Action      string
+	Config      map[string]string // Parameters for set-config
+	FirmwareURL string            // Firmware file for firmware upgrades
+	Workers     int               // Phones handled at once
+	Timeout     time.Duration     // Limit for each phone
+}
+
+// Validate checks that the action and its arguments are usable
+func (op BulkOperation) Validate() error {
+	if _, ok := bulkActionCapabilities[op.Action]; !ok {
+		return fmt.Errorf("unknown action %q (use %s)", op.Action, strings.Join(bulkActions, ", "))
+	}
+	if op.Action == bulkActionSetConfig && len(op.Config) == 0 {
+		return fmt.Errorf("set-config needs at least one key=value")
+	}
+	if op.Action == bulkActionFirmware && !strings.HasPrefix(op.FirmwareURL, "http://") && !strings.HasPrefix(op.FirmwareURL, "https://") {
+		return fmt.Errorf("firmware upgrades need an http(s) URL of the firmware file")
+	}
+	if op.Workers < 1 || op.Workers > maxBulkWorkers {
+		return fmt.Errorf("workers must be between 1 and %d", maxBulkWorkers)
+	}
+	if op.Timeout <= 0 {
+		return fmt.Errorf("the per-phone timeout must be positive")
+	}
+	return nil
+}
+
+// BulkPhoneResult is the outcome of a bulk action on one phone
+type BulkPhoneResult struct {
+	IP         string `json:"ip"`
+	MAC        string `json:"mac,omitempty"`
+	Vendor     string `json:"vendor,omitempty"`
+	Status     string `json:"status"` // ok, failed or skipped
+	Message    string `json:"message,omitempty"`
+	Stage      string `json:"stage,omitempty"`    // canary or rollout for staged firmware upgrades
+	Firmware   string `json:"firmware,omitempty"` // Version running after a firmware upgrade
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// Bulk result statuses
+const (
+	bulkStatusOK      = "ok"
+	bulkStatusFailed  = "failed"
+	bulkStatusSkipped = "skipped"
+)
+
+// BulkReport summarises a bulk operation
+type BulkReport struct {
+	Action     string            `json:"action"`
+	Filter     string            `json:"filter,omitempty"`
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt time.Time         `json:"finished_at"`
+	Total      int               `json:"total"`
+	Succeeded  int               `json:"succeeded"`
+	Failed     int               `json:"failed"`
+	Skipped    int               `json:"skipped"`
+	Results    []BulkPhoneResult `json:"results"`
+}
+
+// WriteBulkReport writes a report as indented JSON
+func WriteBulkReport(path string, report BulkReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write report: %v", err)
+	}
+	return nil
+}
+
+// runPhoneWorkers calls work for every index in 0..n-1 using at most workers goroutines
+func runPhoneWorkers(n, workers int, work func(i int)) {
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				work(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// RunBulkOperation runs an operation on every phone with a bounded worker pool.
+// progress, if set, is called from the workers as each phone finishes.
+func RunBulkOperation(pm *PhoneManager, phones []BulkPhone, op BulkOperation, settings ProvisioningSettings, progress func(BulkPhoneResult)) BulkReport {
+	report := BulkReport{Action: op.Action, StartedAt: time.Now(), Total: len(phones)}
+	results := make([]BulkPhoneResult, len(phones))
+	runPhoneWorkers(len(phones), op.Workers, func(i int) {
+		results[i] = runBulkPhoneWithTimeout(pm, phones[i], op, settings)
+		if progress != nil {
+			progress(results[i])
+		}
+	})
+
+	report.FinishedAt = time.Now()
+	report.Results = results
+	report.tally()
+	return report
+}
+
+// tally counts the results by status
+func (r *BulkReport) tally() {
+	r.Succeeded, r.Failed, r.Skipped = 0, 0, 0
+	for _, result := range r.Results {
+		switch result.Status {
+		case bulkStatusOK:
+			r.Succeeded++
+		case bulkStatusSkipped:
+			r.Skipped++
+		default:
+			r.Failed++
+		}
+	}
+}
+
+// runBulkPhoneWithTimeout gives up on a phone that does not finish within the operation timeout
+func runBulkPhoneWithTimeout(pm *PhoneManager, phone BulkPhone, op BulkOperation, settings ProvisioningSettings) BulkPhoneResult {
+	started := time.Now()
+	done := make(chan BulkPhoneResult, 1)
+	go func() {
+		done <- runBulkPhone(pm, phone, op, settings)
+	}()
+
+	var result BulkPhoneResult
+	select {
+	case result = <-done:
+	case <-time.After(op.Timeout):
+		result = BulkPhoneResult{IP: phone.IP, MAC: phone.MAC, Vendor: phone.Vendor, Status: bulkStatusFailed,
+			Message: fmt.Sprintf("timed out after %s", op.Timeout)}
+	}
+	result.DurationMS = time.Since(started).Milliseconds()
+	return result
+}
+
+// openBulkPhone creates the client of a phone that supports capability. When it cannot,
+// ok is false and result says why the phone was skipped or failed.
+func openBulkPhone(pm *PhoneManager, phone BulkPhone, capability PhoneCapability, action string) (vp VoIPPhone, result BulkPhoneResult, ok bool) {
+	result = BulkPhoneResult{IP: phone.IP, MAC: phone.MAC, Vendor: phone.Vendor}
+	fail := func(status, format string, args ...interface{}) (VoIPPhone, BulkPhoneResult, bool) {
+		result.Status = status
+		result.Message = fmt.Sprintf(format, args...)
+		return nil, result, false
+	}
+	if phone.Credentials["password"] == "" {
+		return fail(bulkStatusSkipped, "no stored credentials")
+	}
+	if result.Vendor == "" {
+		vendor, err := pm.DetectPhoneVendor(phone.IP)
+		if err != nil {
+			return fail(bulkStatusFailed, "%v", err)
+		}
+		result.Vendor = vendor
+	}
+	driver := LookupPhoneDriver(result.Vendor)
+	if driver == nil || !driver.Manageable() {
+		return fail(bulkStatusSkipped, "%s phones cannot be managed", result.Vendor)
+	}
+	if !driver.Supports(capability) {
+		return fail(bulkStatusSkipped, "%s is not supported on %s phones", action, driver.DisplayName)
+	}
+	vp, err := pm.CreatePhone(phone.IP, result.Vendor, phone.Credentials)
+	if err != nil {
+		return fail(bulkStatusFailed, "%v", err)
+	}
+	return vp, result, true
+}
+
+// runBulkPhone runs the operation on a single phone
+func runBulkPhone(pm *PhoneManager, phone BulkPhone, op BulkOperation, settings ProvisioningSettings) BulkPhoneResult {
+	vp, result, ok := openBulkPhone(pm, phone, bulkActionCapabilities[op.Action], op.Action)
+	if !ok {
+		return result
+	}
+
+	var err error
+	message := ""
+	switch op.Action {
+	case bulkActionReboot:
+		err = vp.Reboot()
+		message = "rebooting"
+	case bulkActionProvision:
+		message, err = PointPhoneAtProvisioning(vp, result.Vendor, settings)
+	case bulkActionSetConfig:
+		config := make(map[string]interface{}, len(op.Config))
+		for key, value := range op.Config {
+			config[key] = value
+		}
+		err = vp.SetConfig(config)
+		message = fmt.Sprintf("set %d key(s)", len(config))
+	case bulkActionFirmware:
+		err = UpgradePhoneFirmware(vp, op.FirmwareURL)
+		message = "upgrading firmware"
+	case bulkActionEnableCTI:
+		if gs, ok := vp.(*GrandStreamPhone); ok {
+			err = gs.EnableCTIFeatures(false, nil)
+		}
+		message = "CTI enabled"
+	}
+	if err != nil {
+		result.Status = bulkStatusFailed
+		result.Message = err.Error()
+		return result
+	}
+	result.Status = bulkStatusOK
+	result.Message = message
+	return result
+}
+
+// Bulk phone operation screen forms
+const (
+	bulkFormFilter = iota
+	bulkFormAction
+)
+
+// Bulk action form field indices
+const (
+	bulkFieldAction = iota
+	bulkFieldConfig
+	bulkFieldFirmwareURL
+	bulkFieldWorkers
+	bulkFieldTimeout
+	bulkFieldReport
+)
+
+// phoneBulkProgressMsg reports one finished phone of a running bulk operation
+type phoneBulkProgressMsg struct {
+	result BulkPhoneResult
+}
+
+// phoneBulkDoneMsg carries the report of a finished bulk operation
+type phoneBulkDoneMsg struct {
+	report BulkReport
+}
+
+// waitForBulkProgress waits for the next progress or completion message of a bulk operation
+func waitForBulkProgress(updates <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		return <-updates
+	}
+}
+
+// bulkPhones returns the phones bulk operations can target: the inventory when the
+// database is available, otherwise the phones on the VoIP phones screen
+func (m model) bulkPhones() []BulkPhone {
+	if m.db != nil {
+		if inventory, err := GetInventoryPhones(m.db); err == nil {
+			return BulkPhonesFromInventory(inventory, m.phoneCredentials)
+		}
+	}
+	var phones []BulkPhone
+	for _, p := range m.voipPhones {
+		phone := BulkPhone{IP: p.IP, Name: p.Name, Credentials: m.phoneCredentials[p.IP]}
+		if driver := PhoneDriverForUserAgent(p.UserAgent); driver != nil && driver.Manageable() {
+			phone.Vendor = driver.Vendor
+		}
+		if p.Extension != "" {
+			phone.Extensions = []string{p.Extension}
+		}
+		phones = append(phones, phone)
+	}
+	sort.Slice(phones, func(i, j int) bool { return phones[i].IP < phones[j].IP })
+	return phones
+}
+
+// initPhoneBulk opens the bulk operations screen with every phone listed
+func (m *model) initPhoneBulk() {
+	m.currentScreen = phoneBulkScreen
+	m.errorMsg = ""
+	m.successMsg = ""
+	m.bulkFilter = PhoneFilter{}
+	m.bulkSelected = make(map[string]bool)
+	m.bulkCursor = 0
+	m.bulkSavedFilters, _ = LoadPhoneFilters(phoneFiltersFilePath())
+	m.bulkSavedFilterIdx = -1
+	m.refreshPhoneBulk()
+}
+
+// refreshPhoneBulk reloads the phones and applies the current filter
+func (m *model) refreshPhoneBulk() {
+	m.bulkAllPhones = m.bulkPhones()
+	m.applyBulkFilter()
+}
+
+// applyBulkFilter lists the phones matching the filter and drops selections that no longer match
+func (m *model) applyBulkFilter() {
+	m.bulkVisible = FilterPhones(m.bulkAllPhones, m.bulkFilter)
+	visible := make(map[string]bool, len(m.bulkVisible))
+	for _, phone := range m.bulkVisible {
+		visible[phone.IP] = true
+	}
+	for ip := range m.bulkSelected {
+		if !visible[ip] {
+			delete(m.bulkSelected, ip)
+		}
+	}
+	if m.bulkCursor >= len(m.bulkVisible) {
+		m.bulkCursor = 0
+	}
+}
+
+// bulkTargets returns the selected phones, or every listed phone when none are selected
+func (m model) bulkTargets() []BulkPhone {
+	if len(m.bulkSelected) == 0 {
+		return m.bulkVisible
+	}
+	var targets []BulkPhone
+	for _, phone := range m.bulkVisible {
+		if m.bulkSelected[phone.IP] {
+			targets = append(targets, phone)
+		}
+	}
+	return targets
+}
+
+// initBulkFilterForm opens the filter form filled with the current filter
+func (m *model) initBulkFilterForm() {
+	f := m.bulkFilter
+	extensions := ""
+	if f.ExtensionFrom != "" || f.ExtensionTo != "" {
+		extensions = f.ExtensionFrom + "-" + f.ExtensionTo
+	}
+	m.bulkForm = bulkFormFilter
+	m.inputFields = []string{"Filter name (to save it)", "Vendor", "Model contains", "Firmware starts with", "Subnet (CIDR)", "Extensions (e.g. 100-199)"}
+	m.inputValues = []string{f.Name, f.Vendor, f.Model, f.Firmware, f.Subnet, extensions}
+	m.inputMode = true
+	m.inputCursor = 0
+	m.errorMsg = ""
+	m.successMsg = ""
+}
+
+// applyBulkFilterForm applies the filter form and saves the filter when it is named
+func (m *model) applyBulkFilterForm() {
+	v := m.inputValues
+	filter := PhoneFilter{
+		Name:     strings.TrimSpace(v[0]),
+		Vendor:   strings.ToLower(strings.TrimSpace(v[1])),
+		Model:    strings.TrimSpace(v[2]),
+		Firmware: strings.TrimSpace(v[3]),
+		Subnet:   strings.TrimSpace(v[4]),
+	}
+	if strings.TrimSpace(v[5]) != "" {
+		filter.ExtensionFrom, filter.ExtensionTo = ParseExtensionRange(v[5])
+	}
+	if err := filter.Validate(); err != nil {
+		m.errorMsg = err.Error()
+		return
+	}
+	m.bulkFilter = filter
+	m.inputMode = false
+	m.applyBulkFilter()
+	m.errorMsg = ""
+	m.successMsg = fmt.Sprintf("%d phone(s) match %s", len(m.bulkVisible), filter)
+	if filter.Name != "" {
+		saved, err := SavePhoneFilter(phoneFiltersFilePath(), filter)
+		if err != nil {
+			m.errorMsg = fmt.Sprintf("Filter applied but not saved: %v", err)
+			return
+		}
+		m.bulkSavedFilters = saved
+		m.successMsg += fmt.Sprintf(" - saved as %q", filter.Name)
+	}
+}
+
+// nextSavedBulkFilter cycles through the saved filters and back to all phones
+func (m *model) nextSavedBulkFilter() {
+	m.errorMsg = ""
+	if len(m.bulkSavedFilters) == 0 {
+		m.errorMsg = "No saved filters - press f and give a filter a name to save it"
+		return
+	}
+	m.bulkSavedFilterIdx++
+	if m.bulkSavedFilterIdx >= len(m.bulkSavedFilters) {
+		m.bulkSavedFilterIdx = -1
+		m.bulkFilter = PhoneFilter{}
+	} else {
+		m.bulkFilter = m.bulkSavedFilters[m.bulkSavedFilterIdx]
+	}
+	m.applyBulkFilter()
+	m.successMsg = fmt.Sprintf("%d phone(s) match %s", len(m.bulkVisible), m.bulkFilter)
+}
+
+// initBulkActionForm opens the form for the action to run on the targeted phones
+func (m *model) initBulkActionForm() {
+	if len(m.bulkTargets()) == 0 {
+		m.errorMsg = "No phones to run an action on"
+		return
+	}
+	m.bulkForm = bulkFormAction
+	m.inputFields = []string{
+		"Action (" + strings.Join(bulkActions, ", ") + ")",
+		"Config keys (key=value;key=value)",
+		"Firmware URL",
+		"Workers",
+		"Timeout per phone (seconds)",
+		"Report file (JSON)",
+	}
+	m.inputValues = []string{bulkActionReboot, "", "", strconv.Itoa(DefaultBulkWorkers),
+		strconv.Itoa(int(DefaultBulkTimeout.Seconds())), DefaultBulkReportPath}
+	m.inputMode = true
+	m.inputCursor = 0
+	m.errorMsg = ""
+	m.successMsg = ""
+}
+
+// parseBulkActionForm builds the operation from the action form
+func (m model) parseBulkActionForm() (BulkOperation, error) {
+	v := m.inputValues
+	op := BulkOperation{Action: strings.TrimSpace(v[bulkFieldAction]), FirmwareURL: strings.TrimSpace(v[bulkFieldFirmwareURL])}
+	if op.Action == bulkActionSetConfig {
+		config, err := ParseConfigAssignments(v[bulkFieldConfig])
+		if err != nil {
+			return op, err
+		}
+		op.Config = config
+	}
+	workers, err := strconv.Atoi(strings.TrimSpace(v[bulkFieldWorkers]))
+	if err != nil {
+		return op, fmt.Errorf("workers must be a number")
+	}
+	op.Workers = workers
+	seconds, err := strconv.Atoi(strings.TrimSpace(v[bulkFieldTimeout]))
+	if err != nil {
+		return op, fmt.Errorf("the timeout must be a number of seconds")
+	}
+	op.Timeout = time.Duration(seconds) * time.Second
+	return op, op.Validate()
+}
+
+// startPhoneBulk starts the operation from the action form, streaming progress to the screen
+func (m *model) startPhoneBulk() tea.Cmd {
+	op, err := m.parseBulkActionForm()
+	if err != nil {
+		m.errorMsg = err.Error()
+		return nil
+	}
+	if m.phoneManager == nil {
+		m.phoneManager = NewPhoneManager(m.asteriskManager)
+	}
+	targets := m.bulkTargets()
+	pm := m.phoneManager
+	settings := m.provisioningSettings()
+	filter := m.bulkFilter.String()
+
+	updates := make(chan tea.Msg, len(targets)+1)
+	go func() {
+		report := RunBulkOperation(pm, targets, op, settings, func(result BulkPhoneResult) {
+			updates <- phoneBulkProgressMsg{result: result}
+		})
+		report.Filter = filter
+		updates <- phoneBulkDoneMsg{report: report}
+	}()
+
+	m.inputMode = false
+	m.bulkReportPath = strings.TrimSpace(m.inputValues[bulkFieldReport])
+	m.bulkUpdates = updates
+	m.bulkRunning = true
+	m.bulkTotal = len(targets)
+	m.bulkResults = nil
+	m.bulkReport = nil
+	m.errorMsg = ""
+	m.successMsg = fmt.Sprintf("Running %s on %d phone(s) with %d workers...", op.Action, len(targets), op.Workers)
+	return waitForBulkProgress(updates)
+}
+
+// recordBulkProgress adds a finished phone and waits for the next one
+func (m *model) recordBulkProgress(result BulkPhoneResult) tea.Cmd {
+	m.bulkResults = append(m.bulkResults, result)
+	return waitForBulkProgress(m.bulkUpdates)
+}
+
+// finishPhoneBulk shows the final report and writes it to the report file
+func (m *model) finishPhoneBulk(report BulkReport) {
+	m.bulkRunning = false
+	m.bulkUpdates = nil
+	m.bulkReport = &report
+	m.bulkResults = report.Results
+	m.errorMsg = ""
+	m.successMsg = ""
+	summary := fmt.Sprintf("%s: %d ok, %d failed, %d skipped", report.Action, report.Succeeded, report.Failed, report.Skipped)
+	if m.bulkReportPath != "" {
+		if err := WriteBulkReport(m.bulkReportPath, report); err != nil {
+			m.errorMsg = fmt.Sprintf("%s - %v", summary, err)
+			return
+		}
+		summary += " - report saved to " + m.bulkReportPath
+	}
+	if report.Failed > 0 {
+		m.errorMsg = summary
+	} else {
+		m.successMsg = summary
+	}
+}
+
+// handlePhoneBulkScreen handles keys on the bulk operations screen
+func (m *model) handlePhoneBulkScreen(msg tea.KeyMsg) (*model, tea.Cmd) {
+	if m.bulkRunning {
+		if msg.String() == "q" || msg.String() == "ctrl+c" {
+			return m, tea.Quit
+		}
+		return m, nil // Wait for the running operation
+	}
+	if m.bulkReport != nil {
+		switch msg.String() {
+		case "esc", "enter":
+			m.bulkReport = nil
+			m.bulkResults = nil
+			m.errorMsg = ""
+			m.successMsg = ""
+			m.refreshPhoneBulk()
+		case "q":
+			return m, tea.Quit
+		}
+		return m, nil
+	}
+
+	switch msg.String() {
+	case "up", "k":
+		if m.bulkCursor > 0 {
+			m.bulkCursor--
+		} else if len(m.bulkVisible) > 0 {
+			m.bulkCursor = len(m.bulkVisible) - 1
+		}
+	case "down", "j":
+		if m.bulkCursor < len(m.bulkVisible)-1 {
+			m.bulkCursor++
+		} else {
+			m.bulkCursor = 0
+		}
+	case " ":
+		if m.bulkCursor < len(m.bulkVisible) {
+			ip := m.bulkVisible[m.bulkCursor].IP
+			if m.bulkSelected[ip] {
+				delete(m.bulkSelected, ip)
+			} else {
+				m.bulkSelected[ip] = true
+			}
+		}
+	case "a":
+		if len(m.bulkSelected) == len(m.bulkVisible) {
+			m.bulkSelected = make(map[string]bool)
+		} else {
+			for _, phone := range m.bulkVisible {
+				m.bulkSelected[phone.IP] = true
+			}
+		}
+	case "f":
+		m.initBulkFilterForm()
+	case "n":
+		m.nextSavedBulkFilter()
+	case "enter", "x":
+		m.initBulkActionForm()
+	case "r":
+		m.errorMsg = ""
+		m.successMsg = ""
+		m.refreshPhoneBulk()
+	case "esc":
+		m.errorMsg = ""
+		m.successMsg = ""
+		m.currentScreen = voipPhonesScreen
+	case "q":
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// handlePhoneBulkInput submits the filter or action form
+func (m *model) handlePhoneBulkInput() tea.Cmd {
+	if m.bulkForm == bulkFormFilter {
+		m.applyBulkFilterForm()
+		return nil
+	}
+	return m.startPhoneBulk()
+}
+
+// renderPhoneBulk renders the phone list, the open form, or the progress and report
+func (m model) renderPhoneBulk() string {
+	content := infoStyle.Render("📦 Bulk Phone Operations") + "\n\n"
+
+	if m.inputMode {
+		title := "Filter phones"
+		if m.bulkForm == bulkFormAction {
+			title = fmt.Sprintf("Action for %d phone(s)", len(m.bulkTargets()))
+		}
+		content += title + "\n\n"
+		for i, field := range m.inputFields {
+			cursor := "  "
+			fieldStyle := lipgloss.NewStyle()
+			if i == m.inputCursor {
+				cursor = "▶ "
+				fieldStyle = selectedItemStyle
+			}
+			value := m.inputValues[i]
+			if value == "" {
+				value = helpStyle.Render("<empty>")
+			}
+			content += fmt.Sprintf("%s%s: %s\n", cursor, fieldStyle.Render(field), value)
+		}
+		return menuStyle.Render(content)
+	}
+
+	if m.bulkRunning || m.bulkReport != nil {
+		done := len(m.bulkResults)
+		total := m.bulkTotal
+		if m.bulkReport != nil {
+			total = m.bulkReport.Total
+		}
+		content += renderBulkProgressBar(done, total, 30) + fmt.Sprintf(" %d/%d\n\n", done, total)
+		for _, result := range m.bulkResults {
+			icon := successStyle.Render("✓")
+			switch result.Status {
+			case bulkStatusFailed:
+				icon = errorStyle.Render("✗")
+			case bulkStatusSkipped:
+				icon = helpStyle.Render("-")
+			}
+			content += fmt.Sprintf("%s %-15s  %-11s  %s\n", icon, result.IP, result.Vendor, result.Message)
+		}
+		return menuStyle.Render(content)
+	}
+
+	filterName := ""
+	if m.bulkFilter.Name != "" {
+		filterName = fmt.Sprintf(" (%s)", m.bulkFilter.Name)
+	}
+	content += fmt.Sprintf("Filter: %s%s • %d of %d phone(s) • %d selected\n\n",
+		m.bulkFilter, filterName, len(m.bulkVisible), len(m.bulkAllPhones), len(m.bulkSelected))
+	if len(m.bulkVisible) == 0 {
+		content += "📭 No phones match\n"
+		return menuStyle.Render(content)
+	}
+
+	content += helpStyle.Render(fmt.Sprintf("      %-15s  %-11s  %-12s  %-14s  %-10s  %s", "IP", "Vendor", "Model", "Firmware", "Extensions", "Creds")) + "\n"
+	for i, phone := range m.bulkVisible {
+		cursor := " "
+		if i == m.bulkCursor {
+			cursor = "▶"
+		}
+		check := "[ ]"
+		if m.bulkSelected[phone.IP] {
+			check = "[x]"
+		}
+		creds := "no"
+		if phone.Credentials["password"] != "" {
+			creds = "yes"
+		}
+		line := fmt.Sprintf("%s %-15s  %-11s  %-12s  %-14s  %-10s  %s", check, phone.IP, phone.Vendor, truncateText(phone.Model, 12),
+			truncateText(phone.Firmware, 14), truncateText(strings.Join(phone.Extensions, ","), 10), creds)
+		if i == m.bulkCursor {
+			line = selectedItemStyle.Render(line)
+		}
+		content += cursor + " " + line + "\n"
+	}
+	if len(m.bulkSelected) == 0 {
+		content += "\n" + helpStyle.Render("No phones selected: actions run on every listed phone") + "\n"
+	}
+	return menuStyle.Render(content)
+}
+
+// renderBulkProgressBar draws a text progress bar
+func renderBulkProgressBar(done, total, width int) string {
+	filled := 0
+	if total > 0 {
+		filled = done * width / total
+	}
+	return "[" + strings.Repeat("█", filled) + strings.Repeat("░", width-filled) + "]"
+}
+
+// phoneBulkHelp returns the key help for the bulk operations screen
+func (m model) phoneBulkHelp() string {
+	switch {
+	case m.inputMode:
+		return "↑/↓: Navigate Fields • Enter: Next/Run • ESC: Cancel • q: Quit"
+	case m.bulkRunning:
+		return "Running... • q: Quit"
+	case m.bulkReport != nil:
+		return "Enter/ESC: Back to Phone List • q: Quit"
+	}
+	return "↑/↓: Navigate • Space: Select • a: Select All/None • f: Filter • n: Next Saved Filter • Enter: Run Action • r: Refresh • ESC: Back • q: Quit"
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestPhoneFilter(t *testing.T) {
+	phones := []BulkPhone{
+		{IP: "192.168.1.20", Vendor: "yealink", Model: "SIP-T46S", Firmware: "66.86.0.15", Extensions: []string{"101", "150"}},
+		{IP: "192.168.2.30", Vendor: "grandstream", Model: "GXP2170", Firmware: "1.0.11.23", Extensions: []string{"201"}},
+		{IP: "192.168.1.40", Vendor: "yealink", Model: "SIP-T54W", Firmware: "96.86.0.10"},
+	}
+	tests := []struct {
+		filter PhoneFilter
+		want   []string
+	}{
+		{PhoneFilter{}, []string{"192.168.1.20", "192.168.2.30", "192.168.1.40"}},
+		{PhoneFilter{Vendor: "Yealink"}, []string{"192.168.1.20", "192.168.1.40"}},
+		{PhoneFilter{Model: "t54"}, []string{"192.168.1.40"}},
+		{PhoneFilter{Firmware: "66.86"}, []string{"192.168.1.20"}},
+		{PhoneFilter{Subnet: "192.168.2.0/24"}, []string{"192.168.2.30"}},
+		{PhoneFilter{ExtensionFrom: "140", ExtensionTo: "199"}, []string{"192.168.1.20"}},
+		{PhoneFilter{ExtensionFrom: "200"}, []string{"192.168.2.30"}},
+		{PhoneFilter{Vendor: "yealink", ExtensionTo: "199"}, []string{"192.168.1.20"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, phone := range FilterPhones(phones, tt.filter) {
+			got = append(got, phone.IP)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("filter %s matched %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+
+	if from, to := ParseExtensionRange(" 100 - 199 "); from != "100" || to != "199" {
+		t.Errorf("unexpected range %q-%q", from, to)
+	}
+	if from, to := ParseExtensionRange("101"); from != "101" || to != "101" {
+		t.Errorf("expected a single extension to be its own range, got %q-%q", from, to)
+	}
+	for _, filter := range []PhoneFilter{{Subnet: "192.168.1.0"}, {ExtensionFrom: "abc"}} {
+		if filter.Validate() == nil {
+			t.Errorf("expected filter %+v to be invalid", filter)
+		}
+	}
+	if s := (PhoneFilter{Vendor: "yealink", ExtensionFrom: "100", ExtensionTo: "199"}).String(); s != "vendor=yealink extensions=100-199" {
+		t.Errorf("unexpected filter description %q", s)
+	}
+	if !(PhoneFilter{Name: "all"}).Empty() || (PhoneFilter{Model: "T46"}).Empty() {
+		t.Error("unexpected Empty result")
+	}
+}
+
+func TestSavePhoneFilter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rayanpbx", "phone-filters.json")
+	if filters, err := LoadPhoneFilters(path); err != nil || len(filters) != 0 {
+		t.Fatalf("expected no saved filters, got %v, %v", filters, err)
+	}
+	if _, err := SavePhoneFilter(path, PhoneFilter{Vendor: "yealink"}); err == nil {
+		t.Error("expected a filter without a name to be refused")
+	}
+	SavePhoneFilter(path, PhoneFilter{Name: "yealinks", Vendor: "yealink"})
+	SavePhoneFilter(path, PhoneFilter{Name: "floor2", Subnet: "10.0.2.0/24"})
+	SavePhoneFilter(path, PhoneFilter{Name: "yealinks", Vendor: "yealink", Firmware: "66."})
+
+	filters, err := LoadPhoneFilters(path)
+	if err != nil || len(filters) != 2 {
+		t.Fatalf("expected two saved filters, got %+v, %v", filters, err)
+	}
+	if f := FindPhoneFilter(filters, "yealinks"); f == nil || f.Firmware != "66." {
+		t.Errorf("expected the filter to be replaced by name, got %+v", f)
+	}
+	if FindPhoneFilter(filters, "missing") != nil {
+		t.Error("expected no filter named missing")
+	}
+}
+
+func TestParseConfigAssignments(t *testing.T) {
+	config, err := ParseConfigAssignments("local_time.ntp_server1 = pool.ntp.org; P30=time.example.com;;url=http://x/?a=b")
+	if err != nil || len(config) != 3 || config["local_time.ntp_server1"] != "pool.ntp.org" || config["url"] != "http://x/?a=b" {
+		t.Errorf("unexpected config %v, %v", config, err)
+	}
+	for _, value := range []string{"", "novalue", "=value"} {
+		if _, err := ParseConfigAssignments(value); err == nil {
+			t.Errorf("expected %q to be rejected", value)
+		}
+	}
+}
+
+func TestRunBulkOperation(t *testing.T) {
+	fake, phone := newFakeYealink(t)
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Second)
+	}))
+	defer slow.Close()
+
+	creds := map[string]string{"username": "admin", "password": "secret"}
+	phones := []BulkPhone{
+		{IP: phone.ip, Vendor: "yealink", Credentials: creds},
+		{IP: "192.0.2.1", Vendor: "yealink"},
+		{IP: "192.0.2.2", Vendor: "cisco", Credentials: creds},
+		{IP: slow.URL[7:], Vendor: "yealink", Credentials: creds},
+	}
+	op := BulkOperation{Action: bulkActionReboot, Workers: 2, Timeout: 200 * time.Millisecond}
+	if err := op.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	var mu sync.Mutex
+	progress := 0
+	report := RunBulkOperation(NewPhoneManager(NewAsteriskManager()), phones, op, ProvisioningSettings{}, func(BulkPhoneResult) {
+		mu.Lock()
+		progress++
+		mu.Unlock()
+	})
+
+	if progress != 4 || report.Total != 4 || report.Succeeded != 1 || report.Skipped != 2 || report.Failed != 1 {
+		t.Fatalf("unexpected report (progress %d): %+v", progress, report)
+	}
+	if r := report.Results[1]; r.Status != bulkStatusSkipped || r.Message != "no stored credentials" {
+		t.Errorf("expected a phone without credentials to be skipped, got %+v", r)
+	}
+	if r := report.Results[2]; r.Status != bulkStatusSkipped || !strings.Contains(r.Message, "cannot be managed") {
+		t.Errorf("expected a detection-only vendor to be skipped, got %+v", r)
+	}
+	if r := report.Results[3]; r.Status != bulkStatusFailed || !strings.Contains(r.Message, "timed out") {
+		t.Errorf("expected the slow phone to time out, got %+v", r)
+	}
+	if keys := fake.pressed(); len(keys) != 1 || keys[0] != YLKeyReboot {
+		t.Errorf("expected a single reboot key press, got %v", keys)
+	}
+
+	report = RunBulkOperation(NewPhoneManager(NewAsteriskManager()), phones[:1],
+		BulkOperation{Action: bulkActionEnableCTI, Workers: 1, Timeout: time.Second}, ProvisioningSettings{}, nil)
+	if r := report.Results[0]; r.Status != bulkStatusSkipped || !strings.Contains(r.Message, "not supported on Yealink") {
+		t.Errorf("expected enable-cti to be skipped on Yealink, got %+v", r)
+	}
+
+	report = RunBulkOperation(NewPhoneManager(NewAsteriskManager()), phones[:1],
+		BulkOperation{Action: bulkActionFirmware, FirmwareURL: "http://10.0.0.5/fw/T46S.rom", Workers: 1, Timeout: time.Second}, ProvisioningSettings{}, nil)
+	if report.Succeeded != 1 || !strings.Contains(fake.imported, "static.firmware.url = http://10.0.0.5/fw/T46S.rom") {
+		t.Errorf("expected the firmware URL to be set, got %+v and %q", report.Results, fake.imported)
+	}
+
+	for _, op := range []BulkOperation{
+		{Action: "wipe", Workers: 1, Timeout: time.Second},
+		{Action: bulkActionSetConfig, Workers: 1, Timeout: time.Second},
+		{Action: bulkActionFirmware, FirmwareURL: "ftp://x", Workers: 1, Timeout: time.Second},
+		{Action: bulkActionReboot, Workers: 0, Timeout: time.Second},
+	} {
+		if op.Validate() == nil {
+			t.Errorf("expected %+v to be invalid", op)
+		}
+	}
+}
+
+func TestPhoneBulkScreen(t *testing.T) {
+	t.Setenv("RAYANPBX_PHONE_FILTERS", filepath.Join(t.TempDir(), "phone-filters.json"))
+	fake, phone := newFakeYealink(t)
+
+	m := initialModel(nil, nil, false)
+	m.phoneCredentials = map[string]map[string]string{phone.ip: {"username": "admin", "password": "secret"}}
+	m.voipPhones = []PhoneInfo{{IP: phone.ip, Extension: "101", UserAgent: "Yealink SIP-T46S 66.86.0.15"}, {IP: "192.0.2.1", Extension: "102", UserAgent: "Cisco/SPA504G"}}
+	m.currentScreen = voipPhonesScreen
+	m.handleVoIPPhonesKeyPress("b")
+	if m.currentScreen != phoneBulkScreen || len(m.bulkVisible) != 2 {
+		t.Fatalf("expected the bulk screen with two phones, got screen %d, %d phones", m.currentScreen, len(m.bulkVisible))
+	}
+
+	m.handlePhoneBulkScreen(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'f'}})
+	m.inputValues = []string{"yealinks", "yealink", "", "", "", "100-199"}
+	m.handlePhoneBulkInput()
+	if m.inputMode || len(m.bulkVisible) != 1 || len(m.bulkSavedFilters) != 1 {
+		t.Fatalf("expected the filter to match one phone and be saved, got %d phones, %q", len(m.bulkVisible), m.errorMsg)
+	}
+	m.handlePhoneBulkScreen(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'n'}})
+	m.handlePhoneBulkScreen(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'n'}})
+	if len(m.bulkVisible) != 2 || !m.bulkFilter.Empty() {
+		t.Errorf("expected cycling past the last saved filter to show all phones, got %d", len(m.bulkVisible))
+	}
+
+	m.handlePhoneBulkScreen(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}})
+	if len(m.bulkTargets()) != 1 || m.bulkTargets()[0].IP != phone.ip {
+		t.Fatalf("expected the selected phone to be the only target, got %+v", m.bulkTargets())
+	}
+
+	m.handlePhoneBulkScreen(tea.KeyMsg{Type: tea.KeyEnter})
+	if !m.inputMode || m.bulkForm != bulkFormAction {
+		t.Fatal("expected the action form")
+	}
+	m.inputValues[bulkFieldWorkers] = "many"
+	if cmd := m.handlePhoneBulkInput(); cmd != nil || !strings.Contains(m.errorMsg, "workers") {
+		t.Errorf("expected invalid workers to be rejected, got %q", m.errorMsg)
+	}
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+	m.inputValues[bulkFieldWorkers] = "4"
+	m.inputValues[bulkFieldReport] = reportPath
+	cmd := m.handlePhoneBulkInput()
+	if cmd == nil || !m.bulkRunning {
+		t.Fatalf("expected the operation to start, got %q", m.errorMsg)
+	}
+	for cmd != nil {
+		switch msg := cmd().(type) {
+		case phoneBulkProgressMsg:
+			cmd = m.recordBulkProgress(msg.result)
+		case phoneBulkDoneMsg:
+			m.finishPhoneBulk(msg.report)
+			cmd = nil
+		}
+	}
+	if m.bulkRunning || m.bulkReport == nil || m.bulkReport.Succeeded != 1 || !strings.Contains(m.successMsg, "1 ok") {
+		t.Fatalf("unexpected result: %+v, %q %q", m.bulkReport, m.successMsg, m.errorMsg)
+	}
+	if keys := fake.pressed(); len(keys) != 1 || keys[0] != YLKeyReboot {
+		t.Errorf("expected a single reboot key press, got %v", keys)
+	}
+
+	data, err := os.ReadFile(reportPath)
+	if err != nil {
+		t.Fatalf("expected the report to be written: %v", err)
+	}
+	var report BulkReport
+	if err := json.Unmarshal(data, &report); err != nil || report.Action != bulkActionReboot || len(report.Results) != 1 || report.Results[0].Status != bulkStatusOK {
+		t.Errorf("unexpected report %s (%v)", data, err)
+	}
+
+	m.handlePhoneBulkScreen(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.bulkReport != nil || m.currentScreen != phoneBulkScreen {
+		t.Error("expected ESC to close the report")
+	}
+	m.handlePhoneBulkScreen(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.currentScreen != voipPhonesScreen {
+		t.Error("expected ESC to return to the VoIP phones screen")
+	}
+}
@@ -885,33 +885,9 @@ func (pd *PhoneDiscovery) parseARPOutput(output string) ([]DiscoveredPhone, erro
 
 // detectVendorFromMAC attempts to identify the vendor from MAC address OUI
 func (pd *PhoneDiscovery) detectVendorFromMAC(mac string) string {
-	// Common VoIP phone vendor OUI prefixes
-	ouiPrefixes := map[string]string{
-		"00:0b:82": "GrandStream",
-		"00:19:15": "GrandStream",
-		"c0:74:ad": "GrandStream",
-		"ec:74:d7": "GrandStream",
-		"00:15:65": "Yealink",
-		"80:5e:c0": "Yealink",
-		"00:04:f2": "Polycom",
-		"64:16:7f": "Polycom",
-		"00:1e:c2": "Cisco",
-		"00:50:c2": "Cisco",
-		"00:04:13": "Snom",
-		"00:1b:63": "Panasonic",
-		"0c:38:3e": "Fanvil",
+	if driver := PhoneDriverForMAC(mac); driver != nil {
+		return driver.DisplayName
 	}
-
-	// Normalize MAC and get first 3 octets
-	mac = strings.ToLower(strings.ReplaceAll(mac, "-", ":"))
-	parts := strings.Split(mac, ":")
-	if len(parts) >= 3 {
-		oui := strings.Join(parts[:3], ":")
-		if vendor, ok := ouiPrefixes[oui]; ok {
-			return vendor
-		}
-	}
-
 	return ""
 }
 
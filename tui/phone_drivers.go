@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// PhoneCapability names an action a phone driver can perform
+type PhoneCapability string
+
+// Phone driver capabilities, used to filter the VoIP control menus
+const (
+	CapStatus        PhoneCapability = "status"
+	CapPhoneState    PhoneCapability = "phone_state" // Live call list
+	CapReboot        PhoneCapability = "reboot"
+	CapFactoryReset  PhoneCapability = "factory_reset"
+	CapGetConfig     PhoneCapability = "get_config"
+	CapSetConfig     PhoneCapability = "set_config"
+	CapProvision     PhoneCapability = "provision"
+	CapAnswer        PhoneCapability = "answer"
+	CapReject        PhoneCapability = "reject"
+	CapEndCall       PhoneCapability = "end_call"
+	CapHold          PhoneCapability = "hold"
+	CapDial          PhoneCapability = "dial"
+	CapDTMF          PhoneCapability = "dtmf"
+	CapBlindTransfer PhoneCapability = "blind_transfer"
+	CapDND           PhoneCapability = "dnd"
+	CapCodecs        PhoneCapability = "codecs"
+	CapCTIFeatures   PhoneCapability = "cti_features" // GrandStream CTI/SNMP setup and advanced CTI API
+	CapFirmware      PhoneCapability = "firmware"     // Firmware upgrade from a URL
+)
+
+// DefaultPhoneVendor is assumed when a phone's vendor cannot be detected
+const DefaultPhoneVendor = "grandstream"
+
+// basicPhoneCapabilities are supported by every manageable driver
+var basicPhoneCapabilities = []PhoneCapability{
+	CapStatus, CapReboot, CapGetConfig, CapSetConfig, CapProvision,
+	CapAnswer, CapReject, CapEndCall, CapHold, CapDial, CapDTMF, CapBlindTransfer, CapDND,
+}
+
+// PhoneDriver describes a phone vendor: how to recognise it and what it can do
+type PhoneDriver struct {
+	Vendor           string         // Lowercase vendor key used by CreatePhone and --vendor
+	DisplayName      string         // Human readable vendor name
+	OUIs             []string       // MAC address prefixes (aa:bb:cc)
+	HTTPFingerprints []string       // Lowercase substrings of the web server header or page
+	UserAgent        *regexp.Regexp // SIP User-Agent pattern
+	ModelPattern     *regexp.Regexp // Model name pattern in web pages
+	Capabilities     []PhoneCapability
+	New              func(ip string, credentials map[string]string, httpClient *http.Client) VoIPPhone // nil for detection-only vendors
+}
+
+// Supports reports whether the driver implements a capability
+func (d *PhoneDriver) Supports(capability PhoneCapability) bool {
+	for _, c := range d.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// Manageable reports whether phones of this vendor can be controlled
+func (d *PhoneDriver) Manageable() bool {
+	return d.New != nil
+}
+
+// phoneDriverRegistry lists known vendors in detection priority order
+var phoneDriverRegistry = []*PhoneDriver{
+	{
+		Vendor:           "grandstream",
+		DisplayName:      "GrandStream",
+		OUIs:             []string{"00:0b:82", "00:19:15", "c0:74:ad", "ec:74:d7"},
+		HTTPFingerprints: []string{"grandstream"},
+		UserAgent:        regexp.MustCompile(`(?i)grandstream`),
+		ModelPattern:     grandstreamModelPattern,
+		Capabilities:     append([]PhoneCapability{CapFactoryReset, CapPhoneState, CapCodecs, CapCTIFeatures, CapFirmware}, basicPhoneCapabilities...),
+		New: func(ip string, credentials map[string]string, httpClient *http.Client) VoIPPhone {
+			return NewGrandStreamPhone(ip, credentials, httpClient)
+		},
+	},
+	{
+		Vendor:           "yealink",
+		DisplayName:      "Yealink",
+		OUIs:             []string{"00:15:65", "80:5e:c0"},
+		HTTPFingerprints: []string{"yealink"},
+		UserAgent:        regexp.MustCompile(`(?i)yealink`),
+		ModelPattern:     yealinkModelPattern,
+		Capabilities:     append([]PhoneCapability{CapFactoryReset, CapPhoneState, CapFirmware}, basicPhoneCapabilities...),
+		New: func(ip string, credentials map[string]string, httpClient *http.Client) VoIPPhone {
+			return NewYealinkPhone(ip, credentials, httpClient)
+		},
+	},
+	{
+		Vendor:           "polycom",
+		DisplayName:      "Polycom",
+		OUIs:             []string{"00:04:f2", "64:16:7f"},
+		HTTPFingerprints: []string{"polycom"},
+		UserAgent:        regexp.MustCompile(`(?i)polycom`),
+		ModelPattern:     polycomModelPattern,
+		Capabilities:     append([]PhoneCapability{CapFactoryReset, CapPhoneState}, basicPhoneCapabilities...),
+		New: func(ip string, credentials map[string]string, httpClient *http.Client) VoIPPhone {
+			return NewPolycomPhone(ip, credentials, httpClient)
+		},
+	},
+	{
+		Vendor:           "cisco",
+		DisplayName:      "Cisco",
+		OUIs:             []string{"00:1e:c2", "00:50:c2"},
+		HTTPFingerprints: []string{"cisco"},
+		UserAgent:        regexp.MustCompile(`(?i)cisco`),
+		ModelPattern:     ciscoModelPattern,
+	},
+	{
+		Vendor:           "snom",
+		DisplayName:      "Snom",
+		OUIs:             []string{"00:04:13"},
+		HTTPFingerprints: []string{"snom"},
+		UserAgent:        regexp.MustCompile(`(?i)snom`),
+		ModelPattern:     snomModelPattern,
+		Capabilities:     basicPhoneCapabilities,
+		New: func(ip string, credentials map[string]string, httpClient *http.Client) VoIPPhone {
+			return NewSnomPhone(ip, credentials, httpClient)
+		},
+	},
+	{
+		Vendor:           "panasonic",
+		DisplayName:      "Panasonic",
+		OUIs:             []string{"00:1b:63"},
+		HTTPFingerprints: []string{"panasonic"},
+		UserAgent:        regexp.MustCompile(`(?i)panasonic`),
+		ModelPattern:     panasonicModelPattern,
+	},
+	{
+		Vendor:           "fanvil",
+		DisplayName:      "Fanvil",
+		OUIs:             []string{"0c:38:3e"},
+		HTTPFingerprints: []string{"fanvil"},
+		UserAgent:        regexp.MustCompile(`(?i)fanvil`),
+		ModelPattern:     fanvilModelPattern,
+		Capabilities:     append([]PhoneCapability{CapFactoryReset}, basicPhoneCapabilities...),
+		New: func(ip string, credentials map[string]string, httpClient *http.Client) VoIPPhone {
+			return NewFanvilPhone(ip, credentials, httpClient)
+		},
+	},
+}
+
+// PhoneDrivers returns all registered drivers in detection order
+func PhoneDrivers() []*PhoneDriver {
+	return phoneDriverRegistry
+}
+
+// ManageablePhoneVendors returns the vendor keys accepted by CreatePhone
+func ManageablePhoneVendors() []string {
+	var vendors []string
+	for _, d := range phoneDriverRegistry {
+		if d.Manageable() {
+			vendors = append(vendors, d.Vendor)
+		}
+	}
+	return vendors
+}
+
+// LookupPhoneDriver returns the driver for a vendor key, or nil
+func LookupPhoneDriver(vendor string) *PhoneDriver {
+	vendor = strings.ToLower(strings.TrimSpace(vendor))
+	for _, d := range phoneDriverRegistry {
+		if d.Vendor == vendor {
+			return d
+		}
+	}
+	return nil
+}
+
+// PhoneDriverForMAC returns the driver whose OUI matches the MAC address, or nil
+func PhoneDriverForMAC(mac string) *PhoneDriver {
+	if bare := normalizeMAC(mac); bare != "" {
+		mac = bare[0:2] + ":" + bare[2:4] + ":" + bare[4:6]
+	}
+	mac = strings.ToLower(strings.ReplaceAll(mac, "-", ":"))
+	parts := strings.Split(mac, ":")
+	if len(parts) < 3 {
+		return nil
+	}
+	oui := strings.Join(parts[:3], ":")
+	for _, d := range phoneDriverRegistry {
+		for _, prefix := range d.OUIs {
+			if prefix == oui {
+				return d
+			}
+		}
+	}
+	return nil
+}
+
+// PhoneDriverForUserAgent returns the driver matching a SIP User-Agent, or nil
+func PhoneDriverForUserAgent(userAgent string) *PhoneDriver {
+	if userAgent == "" {
+		return nil
+	}
+	for _, d := range phoneDriverRegistry {
+		if d.UserAgent != nil && d.UserAgent.MatchString(userAgent) {
+			return d
+		}
+	}
+	return nil
+}
+
+// MatchesHTTP reports whether one of the driver's fingerprints appears in text
+func (d *PhoneDriver) MatchesHTTP(text string) bool {
+	text = strings.ToLower(text)
+	for _, fingerprint := range d.HTTPFingerprints {
+		if strings.Contains(text, fingerprint) {
+			return true
+		}
+	}
+	return false
+}
+
+// PhoneDriverForHTTP returns the first driver whose fingerprint appears in text, or nil
+func PhoneDriverForHTTP(text string) *PhoneDriver {
+	for _, d := range phoneDriverRegistry {
+		if d.MatchesHTTP(text) {
+			return d
+		}
+	}
+	return nil
+}
+
+// phoneWebClient performs authenticated HTTP requests against a phone's web server
+type phoneWebClient struct {
+	ip            string
+	credentials   map[string]string
+	httpClient    *http.Client
+	forbiddenHint string // Appended to 403 errors, e.g. which setting to enable
+}
+
+// newPhoneWebClient creates a phone web client with the default timeout
+func newPhoneWebClient(ip string, credentials map[string]string, httpClient *http.Client, forbiddenHint string) phoneWebClient {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return phoneWebClient{ip: ip, credentials: credentials, httpClient: httpClient, forbiddenHint: forbiddenHint}
+}
+
+// do sends an authenticated request to the phone and returns the response body
+func (c *phoneWebClient) do(method, path, contentType string, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequest(method, buildBaseURL(c.ip)+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", GetUserAgent())
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if username, ok := c.credentials["username"]; ok {
+		req.SetBasicAuth(username, c.credentials["password"])
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach phone: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusAccepted, http.StatusNoContent:
+		return data, nil
+	case http.StatusUnauthorized:
+		return nil, fmt.Errorf("authentication failed (check admin username and password)")
+	case http.StatusForbidden:
+		if c.forbiddenHint != "" {
+			return nil, fmt.Errorf("access denied (%s)", c.forbiddenHint)
+		}
+		return nil, fmt.Errorf("access denied")
+	}
+	return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+}
+
+// doJSON sends in as a JSON body (if not nil) and decodes the response into out (if not nil)
+func (c *phoneWebClient) doJSON(method, path string, in, out interface{}) error {
+	var body io.Reader
+	contentType := ""
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		body = bytes.NewReader(data)
+		contentType = "application/json"
+	}
+	data, err := c.do(method, path, contentType, body)
+	if err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// provisionDisplayName returns the caller name to show for an extension
+func provisionDisplayName(ext Extension) string {
+	if ext.Name != "" {
+		return ext.Name
+	}
+	return fmt.Sprintf("Extension %s", ext.ExtensionNumber)
+}
+
+// provisionUsesTLS reports whether the extension registers over TLS
+func provisionUsesTLS(ext Extension) bool {
+	return strings.Contains(strings.ToLower(ext.Transport), "tls")
+}
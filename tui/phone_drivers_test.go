@@ -0,0 +1,88 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPhoneDriverDetection(t *testing.T) {
+	tests := []struct {
+		name   string
+		driver *PhoneDriver
+		want   string
+	}{
+		{"OUI Snom", PhoneDriverForMAC("00-04-13-aa-bb-cc"), "snom"},
+		{"OUI Fanvil", PhoneDriverForMAC("0C:38:3E:00:11:22"), "fanvil"},
+		{"OUI Polycom", PhoneDriverForMAC("64:16:7f:00:11:22"), "polycom"},
+		{"OUI bare MAC", PhoneDriverForMAC("805EC0112233"), "yealink"},
+		{"UA Yealink", PhoneDriverForUserAgent("Yealink SIP-T46S 66.86.0.15"), "yealink"},
+		{"UA Polycom", PhoneDriverForUserAgent("PolycomVVX-VVX_450-UA/6.3.0.14929"), "polycom"},
+		{"UA Snom", PhoneDriverForUserAgent("snomD785/10.1.54.13"), "snom"},
+		{"UA Fanvil", PhoneDriverForUserAgent("Fanvil X4U 2.4.1"), "fanvil"},
+		{"HTTP Snom", PhoneDriverForHTTP("<title>snom D785</title>"), "snom"},
+	}
+	for _, tt := range tests {
+		if tt.driver == nil || tt.driver.Vendor != tt.want {
+			t.Errorf("%s: expected %s, got %+v", tt.name, tt.want, tt.driver)
+		}
+	}
+
+	if PhoneDriverForMAC("aa:bb:cc:dd:ee:ff") != nil || PhoneDriverForUserAgent("") != nil {
+		t.Error("expected no driver for unknown MAC or empty user agent")
+	}
+	if d := phoneDriverFor(PhoneInfo{UserAgent: "Cisco/SPA504G"}); d.Vendor != DefaultPhoneVendor {
+		t.Errorf("detection-only vendor should fall back to %s, got %s", DefaultPhoneVendor, d.Vendor)
+	}
+}
+
+func TestCreatePhoneFromRegistry(t *testing.T) {
+	pm := NewPhoneManager(NewAsteriskManager())
+	if got := ManageablePhoneVendors(); !reflect.DeepEqual(got, []string{"grandstream", "yealink", "polycom", "snom", "fanvil"}) {
+		t.Errorf("unexpected manageable vendors: %v", got)
+	}
+	for _, vendor := range ManageablePhoneVendors() {
+		if _, err := pm.CreatePhone("192.168.1.100", vendor, nil); err != nil {
+			t.Errorf("CreatePhone(%s) failed: %v", vendor, err)
+		}
+	}
+	if _, err := pm.CreatePhone("192.168.1.100", "cisco", nil); err == nil {
+		t.Error("expected error for detection-only vendor")
+	}
+}
+
+func TestVoIPMenuFilteredByCapabilities(t *testing.T) {
+	// Every capability entry must name a real menu item
+	all := map[string]bool{}
+	for tab := range voipControlTabNames {
+		for _, item := range getVoIPControlMenuItems(tab) {
+			all[item] = true
+		}
+	}
+	for item := range voipMenuCapabilities {
+		if !all[item] {
+			t.Errorf("capability map references unknown menu item %q", item)
+		}
+	}
+
+	m := initialModel(nil, nil, false)
+	m.voipPhones = []PhoneInfo{{IP: "192.168.1.60", UserAgent: "snomD785/10.1.54.13"}}
+	m.selectedPhoneIdx = 0
+	m.initVoIPControlMenu()
+	m.voipControlTab = voipTabManagement
+	m.voipControlMenu = m.voipControlMenuItems()
+
+	expected := []string{"🔄 Reboot Phone", "📋 Get Configuration", "⚙️ Set Configuration", "🔙 Back to Phone List"}
+	if !reflect.DeepEqual(m.voipControlMenu, expected) {
+		t.Errorf("Snom management menu: expected %v, got %v", expected, m.voipControlMenu)
+	}
+
+	m.voipControlTab = voipTabCodecs
+	if items := m.voipControlMenuItems(); !reflect.DeepEqual(items, []string{"🔙 Back to Phone List"}) {
+		t.Errorf("Snom codecs menu should only offer Back, got %v", items)
+	}
+
+	m.voipPhones[0].UserAgent = "Grandstream GXP1630 1.0.7.64"
+	if items := m.voipControlMenuItems(); len(items) != len(getVoIPControlMenuItems(voipTabCodecs)) {
+		t.Errorf("GrandStream should keep the full codecs menu, got %v", items)
+	}
+}
@@ -0,0 +1,773 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// macMatchSQL compares a stored MAC with a normalized one regardless of separators
+const macMatchSQL = "LOWER(REPLACE(REPLACE(mac, ':', ''), '-', '')) = ?"
+
+// PhoneLine assigns an extension to one of a phone's line keys (accounts)
+type PhoneLine struct {
+	Line      int    `json:"line"`
+	Extension string `json:"extension"`
+}
+
+// PhoneIPHistoryEntry is an address a phone was seen at
+type PhoneIPHistoryEntry struct {
+	IP        string `json:"ip"`
+	Source    string `json:"source"`
+	FirstSeen string `json:"first_seen"`
+	LastSeen  string `json:"last_seen"`
+}
+
+// InventoryPhone is a phone in the device inventory with its assignments and history
+type InventoryPhone struct {
+	VoIPPhoneDB
+	Location       string
+	Notes          string
+	Lines          []PhoneLine
+	IPHistory      []PhoneIPHistoryEntry
+	HasCredentials bool
+}
+
+// PhoneSighting is an observation of a phone by a discovery method or a SIP registration.
+// Empty fields leave the stored values unchanged.
+type PhoneSighting struct {
+	MAC       string
+	IP        string
+	Name      string
+	Extension string // Extension seen registering, or entered when adding the phone
+	Vendor    string
+	Model     string
+	Firmware  string
+	UserAgent string
+	Status    string
+	Source    string // lldp, arp, nmap, http, manual or sip
+}
+
+// discoverySighting converts a network discovery result into an inventory sighting
+func discoverySighting(disc DiscoveredPhone) PhoneSighting {
+	return PhoneSighting{
+		MAC:      disc.MAC,
+		IP:       disc.IP,
+		Vendor:   disc.Vendor,
+		Model:    disc.Model,
+		Firmware: disc.FirmwareVersion,
+		Source:   disc.DiscoveryType,
+	}
+}
+
+// formatMAC returns a MAC as lowercase colon-separated octets, or "" if it is not a MAC address
+func formatMAC(mac string) string {
+	bare := normalizeMAC(mac)
+	if bare == "" {
+		return ""
+	}
+	octets := make([]string, 6)
+	for i := range octets {
+		octets[i] = bare[i*2 : i*2+2]
+	}
+	return strings.Join(octets, ":")
+}
+
+// RecordPhoneSighting adds or updates the inventory entry of an observed phone and returns its ID.
+// The MAC identifies a phone; without one the phone is matched by IP. A phone seen at an
+// address still held by a different phone takes the address over.
+func RecordPhoneSighting(db *sql.DB, s PhoneSighting) (int64, error) {
+	if db == nil {
+		return 0, fmt.Errorf("database connection is nil")
+	}
+	mac := formatMAC(s.MAC)
+	if mac == "" && s.IP == "" {
+		return 0, fmt.Errorf("a phone sighting needs a MAC or an IP address")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var macID, ipID int64
+	var ipMAC string
+	if mac != "" {
+		err := tx.QueryRow("SELECT id FROM voip_phones WHERE "+macMatchSQL, normalizeMAC(mac)).Scan(&macID)
+		if err != nil && err != sql.ErrNoRows {
+			return 0, fmt.Errorf("failed to look up phone %s: %v", mac, err)
+		}
+	}
+	if s.IP != "" {
+		err := tx.QueryRow("SELECT id, COALESCE(mac, '') FROM voip_phones WHERE ip = ?", s.IP).Scan(&ipID, &ipMAC)
+		if err != nil && err != sql.ErrNoRows {
+			return 0, fmt.Errorf("failed to look up phone %s: %v", s.IP, err)
+		}
+	}
+
+	id := macID
+	switch {
+	case ipID != 0 && ipID != macID && (mac == "" || ipMAC == "") && macID == 0:
+		// The phone was known by IP only
+		id = ipID
+	case ipID != 0 && ipID != macID && ipMAC == "":
+		// The same device was added by IP before its MAC was known
+		if err := mergeInventoryPhone(tx, ipID, macID); err != nil {
+			return 0, err
+		}
+	case ipID != 0 && ipID != macID:
+		// Another phone used to have this address
+		if _, err := tx.Exec("UPDATE voip_phones SET ip = NULL, status = 'offline', updated_at = NOW() WHERE id = ?", ipID); err != nil {
+			return 0, fmt.Errorf("failed to release address %s: %v", s.IP, err)
+		}
+	}
+
+	var macValue, ipValue interface{}
+	if mac != "" {
+		macValue = mac
+	}
+	if s.IP != "" {
+		ipValue = s.IP
+	}
+	if id == 0 {
+		vendor := s.Vendor
+		if vendor == "" {
+			vendor = DefaultPhoneVendor
+		}
+		status := s.Status
+		if status == "" {
+			status = "discovered"
+		}
+		userAgent := s.UserAgent
+		if userAgent == "" {
+			userAgent = strings.TrimSpace(s.Vendor + " " + s.Model)
+		}
+		result, err := tx.Exec(`INSERT INTO voip_phones (ip, mac, extension, name, vendor, model, firmware,
+		                        status, discovery_type, user_agent, last_seen, created_at, updated_at)
+		                        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NOW(), NOW(), NOW())`,
+			ipValue, macValue, s.Extension, s.Name, vendor, s.Model, s.Firmware, status, s.Source, userAgent)
+		if err != nil {
+			return 0, fmt.Errorf("failed to add phone: %v", err)
+		}
+		if id, err = result.LastInsertId(); err != nil {
+			return 0, fmt.Errorf("failed to add phone: %v", err)
+		}
+	} else {
+		_, err := tx.Exec(`UPDATE voip_phones SET ip = COALESCE(?, ip), mac = COALESCE(?, mac),
+		                  extension = COALESCE(NULLIF(?, ''), extension), name = COALESCE(NULLIF(?, ''), name),
+		                  vendor = COALESCE(NULLIF(?, ''), vendor), model = COALESCE(NULLIF(?, ''), model),
+		                  firmware = COALESCE(NULLIF(?, ''), firmware), status = COALESCE(NULLIF(?, ''), status),
+		                  discovery_type = COALESCE(NULLIF(?, ''), discovery_type), user_agent = COALESCE(NULLIF(?, ''), user_agent),
+		                  last_seen = NOW(), updated_at = NOW() WHERE id = ?`,
+			ipValue, macValue, s.Extension, s.Name, s.Vendor, s.Model, s.Firmware, s.Status, s.Source, s.UserAgent, id)
+		if err != nil {
+			return 0, fmt.Errorf("failed to update phone: %v", err)
+		}
+	}
+
+	if s.IP != "" {
+		_, err := tx.Exec(`INSERT INTO voip_phone_ip_history (voip_phone_id, ip, source, first_seen, last_seen)
+		                  VALUES (?, ?, ?, NOW(), NOW())
+		                  ON DUPLICATE KEY UPDATE last_seen = NOW(), source = VALUES(source)`, id, s.IP, s.Source)
+		if err != nil {
+			return 0, fmt.Errorf("failed to record address history: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to save phone: %v", err)
+	}
+	return id, nil
+}
+
+// mergeInventoryPhone folds an IP-only entry into the entry of the same device found by MAC,
+// keeping the line assignments, name and credentials the MAC entry does not have yet
+func mergeInventoryPhone(tx *sql.Tx, fromID, intoID int64) error {
+	statements := []string{
+		`UPDATE voip_phones AS dst JOIN voip_phones AS src ON src.id = ?
+		 SET dst.name = COALESCE(NULLIF(dst.name, ''), src.name),
+		     dst.location = COALESCE(NULLIF(dst.location, ''), src.location),
+		     dst.notes = COALESCE(NULLIF(dst.notes, ''), src.notes),
+		     dst.extension = COALESCE(NULLIF(dst.extension, ''), src.extension),
+		     dst.credentials = COALESCE(dst.credentials, src.credentials)
+		 WHERE dst.id = ?`,
+		`UPDATE IGNORE voip_phone_lines SET voip_phone_id = ? WHERE voip_phone_id = ?`,
+		`UPDATE IGNORE voip_phone_ip_history SET voip_phone_id = ? WHERE voip_phone_id = ?`,
+	}
+	args := [][]interface{}{{fromID, intoID}, {intoID, fromID}, {intoID, fromID}}
+	for i, statement := range statements {
+		if _, err := tx.Exec(statement, args[i]...); err != nil {
+			return fmt.Errorf("failed to merge phone entries: %v", err)
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM voip_phones WHERE id = ?", fromID); err != nil {
+		return fmt.Errorf("failed to merge phone entries: %v", err)
+	}
+	return nil
+}
+
+// GetInventoryPhones returns all phones in the inventory with their line assignments
+func GetInventoryPhones(db *sql.DB) ([]InventoryPhone, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	rows, err := db.Query(`SELECT id, COALESCE(ip, ''), COALESCE(mac, ''), COALESCE(extension, ''), COALESCE(name, ''),
+	                       COALESCE(vendor, ''), COALESCE(model, ''), COALESCE(firmware, ''), COALESCE(status, ''),
+	                       COALESCE(discovery_type, ''), COALESCE(user_agent, ''), COALESCE(last_seen, ''),
+	                       COALESCE(location, ''), COALESCE(notes, ''), credentials IS NOT NULL AND credentials <> ''
+	                       FROM voip_phones ORDER BY COALESCE(name, ''), ip`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load phone inventory: %v", err)
+	}
+	defer rows.Close()
+
+	var phones []InventoryPhone
+	index := make(map[int64]int)
+	for rows.Next() {
+		var p InventoryPhone
+		if err := rows.Scan(&p.ID, &p.IP, &p.MAC, &p.Extension, &p.Name, &p.Vendor, &p.Model, &p.Firmware,
+			&p.Status, &p.DiscoveryType, &p.UserAgent, &p.LastSeen, &p.Location, &p.Notes, &p.HasCredentials); err != nil {
+			return nil, fmt.Errorf("failed to read phone inventory: %v", err)
+		}
+		index[p.ID] = len(phones)
+		phones = append(phones, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read phone inventory: %v", err)
+	}
+
+	lineRows, err := db.Query("SELECT voip_phone_id, line, extension FROM voip_phone_lines ORDER BY voip_phone_id, line")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load line assignments: %v", err)
+	}
+	defer lineRows.Close()
+	for lineRows.Next() {
+		var id int64
+		var line PhoneLine
+		if err := lineRows.Scan(&id, &line.Line, &line.Extension); err != nil {
+			return nil, fmt.Errorf("failed to read line assignments: %v", err)
+		}
+		if i, ok := index[id]; ok {
+			phones[i].Lines = append(phones[i].Lines, line)
+		}
+	}
+	return phones, lineRows.Err()
+}
+
+// FindInventoryPhone returns the phone with the given MAC (in any notation) or IP, or nil
+func FindInventoryPhone(phones []InventoryPhone, key string) *InventoryPhone {
+	mac := normalizeMAC(key)
+	for i := range phones {
+		if (mac != "" && normalizeMAC(phones[i].MAC) == mac) || (phones[i].IP != "" && phones[i].IP == key) {
+			return &phones[i]
+		}
+	}
+	return nil
+}
+
+// GetPhoneIPHistory returns the addresses a phone was seen at, most recent first
+func GetPhoneIPHistory(db *sql.DB, phoneID int64) ([]PhoneIPHistoryEntry, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	rows, err := db.Query(`SELECT ip, COALESCE(source, ''), COALESCE(first_seen, ''), COALESCE(last_seen, '')
+	                       FROM voip_phone_ip_history WHERE voip_phone_id = ? ORDER BY last_seen DESC`, phoneID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load address history: %v", err)
+	}
+	defer rows.Close()
+	var history []PhoneIPHistoryEntry
+	for rows.Next() {
+		var entry PhoneIPHistoryEntry
+		if err := rows.Scan(&entry.IP, &entry.Source, &entry.FirstSeen, &entry.LastSeen); err != nil {
+			return nil, fmt.Errorf("failed to read address history: %v", err)
+		}
+		history = append(history, entry)
+	}
+	return history, rows.Err()
+}
+
+// SetPhoneDetails updates the name, location and notes of a phone
+func SetPhoneDetails(db *sql.DB, phoneID int64, name, location, notes string) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	if _, err := db.Exec("UPDATE voip_phones SET name = ?, location = ?, notes = ?, updated_at = NOW() WHERE id = ?",
+		name, location, notes, phoneID); err != nil {
+		return fmt.Errorf("failed to update phone: %v", err)
+	}
+	return nil
+}
+
+// SetPhoneLines replaces the line assignments of a phone. The extension column
+// mirrors line 1 so the phone list and the backend show the primary extension.
+func SetPhoneLines(db *sql.DB, phoneID int64, lines []PhoneLine) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM voip_phone_lines WHERE voip_phone_id = ?", phoneID); err != nil {
+		return fmt.Errorf("failed to clear line assignments: %v", err)
+	}
+	primary := ""
+	for _, line := range lines {
+		if _, err := tx.Exec(`INSERT INTO voip_phone_lines (voip_phone_id, line, extension, created_at, updated_at)
+		                     VALUES (?, ?, ?, NOW(), NOW())`, phoneID, line.Line, line.Extension); err != nil {
+			return fmt.Errorf("failed to assign line %d: %v", line.Line, err)
+		}
+		if line.Line == 1 {
+			primary = line.Extension
+		}
+	}
+	if _, err := tx.Exec("UPDATE voip_phones SET extension = ?, updated_at = NOW() WHERE id = ?", primary, phoneID); err != nil {
+		return fmt.Errorf("failed to update phone: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to save line assignments: %v", err)
+	}
+	return nil
+}
+
+// ParsePhoneLines validates line assignments entered as one extension per line key;
+// empty entries leave that line key unassigned
+func ParsePhoneLines(entries []string, extensions []Extension) ([]PhoneLine, error) {
+	known := make(map[string]bool, len(extensions))
+	for _, ext := range extensions {
+		known[ext.ExtensionNumber] = true
+	}
+	var lines []PhoneLine
+	seen := make(map[string]int)
+	for i, entry := range entries {
+		number := strings.TrimSpace(entry)
+		if number == "" {
+			continue
+		}
+		if !known[number] {
+			return nil, fmt.Errorf("line %d: extension %s does not exist", i+1, number)
+		}
+		if line, dup := seen[number]; dup {
+			return nil, fmt.Errorf("line %d: extension %s is already on line %d", i+1, number, line)
+		}
+		seen[number] = i + 1
+		lines = append(lines, PhoneLine{Line: i + 1, Extension: number})
+	}
+	return lines, nil
+}
+
+// SavePhoneCredentials stores a phone's web credentials encrypted with APP_KEY,
+// in the format the backend's VoipPhone model reads
+func SavePhoneCredentials(db *sql.DB, phoneID int64, credentials map[string]string) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	key, err := appEncryptionKey()
+	if err != nil {
+		return fmt.Errorf("credentials not saved: %v", err)
+	}
+	plain, err := json.Marshal(map[string]string{"username": credentials["username"], "password": credentials["password"]})
+	if err != nil {
+		return fmt.Errorf("failed to encode credentials: %v", err)
+	}
+	encrypted, err := EncryptAppString(key, string(plain))
+	if err != nil {
+		return err
+	}
+	if _, err := db.Exec("UPDATE voip_phones SET credentials = ?, updated_at = NOW() WHERE id = ?", encrypted, phoneID); err != nil {
+		return fmt.Errorf("failed to save credentials: %v", err)
+	}
+	return nil
+}
+
+// LoadPhoneCredentials returns the stored credentials of all phones with an address, keyed by IP
+func LoadPhoneCredentials(db *sql.DB) (map[string]map[string]string, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	key, err := appEncryptionKey()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.Query("SELECT ip, credentials FROM voip_phones WHERE ip IS NOT NULL AND credentials IS NOT NULL AND credentials <> ''")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load phone credentials: %v", err)
+	}
+	defer rows.Close()
+
+	credentials := make(map[string]map[string]string)
+	for rows.Next() {
+		var ip, encrypted string
+		if err := rows.Scan(&ip, &encrypted); err != nil {
+			return nil, fmt.Errorf("failed to read phone credentials: %v", err)
+		}
+		plain, err := DecryptAppString(key, encrypted)
+		if err != nil {
+			continue // Written with another APP_KEY
+		}
+		var creds map[string]string
+		if json.Unmarshal([]byte(plain), &creds) == nil && creds["password"] != "" {
+			credentials[ip] = creds
+		}
+	}
+	return credentials, rows.Err()
+}
+
+// loadStoredPhoneCredentials adds the credentials saved in the inventory to those entered this session
+func (m *model) loadStoredPhoneCredentials() {
+	if m.db == nil {
+		return
+	}
+	stored, err := LoadPhoneCredentials(m.db)
+	if err != nil {
+		return // No APP_KEY or no inventory yet; credentials are asked for when needed
+	}
+	if m.phoneCredentials == nil {
+		m.phoneCredentials = make(map[string]map[string]string)
+	}
+	for ip, creds := range stored {
+		if _, ok := m.phoneCredentials[ip]; !ok {
+			m.phoneCredentials[ip] = creds
+		}
+	}
+}
+
+// phoneLinesFromColumn reads the legacy extension column, which may list one extension per line
+func phoneLinesFromColumn(assigned string) []PhoneLine {
+	var lines []PhoneLine
+	for i, number := range strings.Split(assigned, ",") {
+		if number = strings.TrimSpace(number); number != "" {
+			lines = append(lines, PhoneLine{Line: i + 1, Extension: number})
+		}
+	}
+	return lines
+}
+
+// truncateText shortens s to at most n characters for a table column
+func truncateText(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
+
+// describePhoneLines formats line assignments as "1:101 2:102"
+func describePhoneLines(lines []PhoneLine) string {
+	sorted := append([]PhoneLine(nil), lines...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Line < sorted[j].Line })
+	parts := make([]string, len(sorted))
+	for i, line := range sorted {
+		parts[i] = strconv.Itoa(line.Line) + ":" + line.Extension
+	}
+	return strings.Join(parts, " ")
+}
+
+// phoneInventoryProvisionMsg carries the result of pointing an inventory phone at the provisioning server
+type phoneInventoryProvisionMsg struct {
+	result ProvisioningRolloutResult
+}
+
+// inventoryPhoneVendor returns the driver vendor of an inventory phone, or "" to detect it over HTTP
+func inventoryPhoneVendor(phone InventoryPhone) string {
+	if driver := PhoneDriverForUserAgent(phone.UserAgent); driver != nil && driver.Manageable() {
+		return driver.Vendor
+	}
+	if driver := PhoneDriverForMAC(phone.MAC); driver != nil && driver.Manageable() {
+		return driver.Vendor
+	}
+	return ""
+}
+
+// initPhoneInventory opens the phone inventory screen
+func (m *model) initPhoneInventory() {
+	m.currentScreen = phoneInventoryScreen
+	m.selectedInventoryIdx = 0
+	m.errorMsg = ""
+	m.successMsg = ""
+	m.refreshPhoneInventory()
+}
+
+// refreshPhoneInventory reloads the inventory and the address history of the selected phone
+func (m *model) refreshPhoneInventory() {
+	m.inventoryHistory = nil
+	if m.db == nil {
+		m.inventoryPhones = nil
+		m.errorMsg = "Database not available - the phone inventory is stored in the database"
+		return
+	}
+	phones, err := GetInventoryPhones(m.db)
+	if err != nil {
+		m.errorMsg = err.Error()
+		return
+	}
+	m.inventoryPhones = phones
+	if m.selectedInventoryIdx >= len(phones) {
+		m.selectedInventoryIdx = 0
+	}
+	m.loadInventoryHistory()
+}
+
+// loadInventoryHistory loads the address history of the selected phone
+func (m *model) loadInventoryHistory() {
+	m.inventoryHistory = nil
+	if phone := m.selectedInventoryPhone(); phone != nil && m.db != nil {
+		m.inventoryHistory, _ = GetPhoneIPHistory(m.db, phone.ID)
+	}
+}
+
+// selectedInventoryPhone returns the highlighted phone, or nil if the inventory is empty
+func (m *model) selectedInventoryPhone() *InventoryPhone {
+	if m.selectedInventoryIdx < len(m.inventoryPhones) {
+		return &m.inventoryPhones[m.selectedInventoryIdx]
+	}
+	return nil
+}
+
+// initPhoneInventoryEdit opens the form for the selected phone's details and line assignments
+func (m *model) initPhoneInventoryEdit() {
+	phone := m.selectedInventoryPhone()
+	if phone == nil {
+		m.errorMsg = "No phone selected"
+		return
+	}
+	m.inputFields = []string{"Name", "Location", "Notes"}
+	m.inputValues = []string{phone.Name, phone.Location, phone.Notes}
+	for line := 1; line <= provisioningMaxLines; line++ {
+		m.inputFields = append(m.inputFields, fmt.Sprintf("Line %d extension", line))
+		value := ""
+		for _, assigned := range phone.Lines {
+			if assigned.Line == line {
+				value = assigned.Extension
+			}
+		}
+		m.inputValues = append(m.inputValues, value)
+	}
+	m.inputMode = true
+	m.inputCursor = 0
+	m.errorMsg = ""
+	m.successMsg = ""
+}
+
+// savePhoneInventoryEdit saves the details and line assignments from the edit form
+func (m *model) savePhoneInventoryEdit() {
+	phone := m.selectedInventoryPhone()
+	if phone == nil || m.db == nil {
+		m.inputMode = false
+		m.errorMsg = "No phone selected"
+		return
+	}
+	extensions, err := GetExtensions(m.db)
+	if err != nil {
+		m.errorMsg = fmt.Sprintf("Failed to load extensions: %v", err)
+		return
+	}
+	lines, err := ParsePhoneLines(m.inputValues[3:], extensions)
+	if err != nil {
+		m.errorMsg = err.Error()
+		return
+	}
+	name := strings.TrimSpace(m.inputValues[0])
+	location := strings.TrimSpace(m.inputValues[1])
+	notes := strings.TrimSpace(m.inputValues[2])
+	if err := SetPhoneDetails(m.db, phone.ID, name, location, notes); err != nil {
+		m.errorMsg = err.Error()
+		return
+	}
+	if err := SetPhoneLines(m.db, phone.ID, lines); err != nil {
+		m.errorMsg = err.Error()
+		return
+	}
+	label := phone.MAC
+	if label == "" {
+		label = phone.IP
+	}
+	m.inputMode = false
+	m.errorMsg = ""
+	m.successMsg = fmt.Sprintf("Saved %s - press p to provision it", label)
+	if len(lines) == 0 {
+		m.successMsg = fmt.Sprintf("Saved %s with no lines assigned", label)
+	}
+	m.refreshPhoneInventory()
+}
+
+// provisionInventoryPhone points the selected phone at the provisioning server so it
+// downloads the configuration built from its line assignments
+func (m *model) provisionInventoryPhone() tea.Cmd {
+	phone := m.selectedInventoryPhone()
+	switch {
+	case phone == nil:
+		m.errorMsg = "No phone selected"
+		return nil
+	case phone.IP == "":
+		m.errorMsg = "The phone has no known address"
+		return nil
+	case phone.MAC == "":
+		m.errorMsg = "The phone's MAC address is unknown - the provisioning server identifies phones by MAC"
+		return nil
+	case len(phone.Lines) == 0:
+		m.errorMsg = "Assign at least one line first (Enter)"
+		return nil
+	}
+	creds := m.phoneCredentials[phone.IP]
+	if creds["password"] == "" {
+		m.errorMsg = "No credentials stored for " + phone.IP + " - add them on the VoIP Phones screen"
+		return nil
+	}
+	if m.phoneManager == nil {
+		m.phoneManager = NewPhoneManager(m.asteriskManager)
+	}
+	target := ProvisioningRolloutTarget{IP: phone.IP, Vendor: inventoryPhoneVendor(*phone), Credentials: creds}
+	settings := m.provisioningSettings()
+	pm := m.phoneManager
+	m.errorMsg = ""
+	m.successMsg = fmt.Sprintf("Pointing %s at %s...", phone.IP, settings.URL())
+	return func() tea.Msg {
+		return phoneInventoryProvisionMsg{result: rolloutProvisioningServer(pm, target, settings)}
+	}
+}
+
+// finishInventoryProvision reports the result of provisioning an inventory phone
+func (m *model) finishInventoryProvision(result ProvisioningRolloutResult) {
+	m.errorMsg = ""
+	m.successMsg = ""
+	if !result.OK {
+		m.errorMsg = fmt.Sprintf("Failed to provision %s: %s", result.IP, result.Message)
+		return
+	}
+	m.successMsg = fmt.Sprintf("%s (%s) will fetch its configuration from the provisioning server", result.IP, result.Vendor)
+	if result.Message != "" {
+		m.successMsg += " - " + result.Message
+	}
+}
+
+// handlePhoneInventoryScreen handles keys on the phone inventory list
+func (m *model) handlePhoneInventoryScreen(msg tea.KeyMsg) (*model, tea.Cmd) {
+	switch msg.String() {
+	case "up", "k":
+		if m.selectedInventoryIdx > 0 {
+			m.selectedInventoryIdx--
+		} else if len(m.inventoryPhones) > 0 {
+			m.selectedInventoryIdx = len(m.inventoryPhones) - 1
+		}
+		m.loadInventoryHistory()
+	case "down", "j":
+		if m.selectedInventoryIdx < len(m.inventoryPhones)-1 {
+			m.selectedInventoryIdx++
+		} else {
+			m.selectedInventoryIdx = 0
+		}
+		m.loadInventoryHistory()
+	case "enter", "e":
+		m.initPhoneInventoryEdit()
+	case "p":
+		return m, m.provisionInventoryPhone()
+	case "b":
+		m.initPhoneBackups()
+	case "K":
+		m.initKeyLayout()
+	case "r":
+		m.errorMsg = ""
+		m.successMsg = ""
+		m.refreshPhoneInventory()
+	case "esc":
+		m.errorMsg = ""
+		m.successMsg = ""
+		m.currentScreen = voipPhonesScreen
+	case "q":
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// renderPhoneInventory renders the inventory list with the selected phone's details, or the edit form
+func (m model) renderPhoneInventory() string {
+	content := infoStyle.Render("🗂️  Phone Inventory") + "\n\n"
+	phone := m.selectedInventoryPhone()
+
+	if m.inputMode {
+		if phone != nil {
+			content += fmt.Sprintf("Editing %s %s\n\n", phone.MAC, phone.IP)
+		}
+		for i, field := range m.inputFields {
+			cursor := "  "
+			fieldStyle := lipgloss.NewStyle()
+			if i == m.inputCursor {
+				cursor = "▶ "
+				fieldStyle = selectedItemStyle
+			}
+			value := m.inputValues[i]
+			if value == "" {
+				value = helpStyle.Render("<empty>")
+			}
+			content += fmt.Sprintf("%s%s: %s\n", cursor, fieldStyle.Render(field), value)
+		}
+		content += "\n" + helpStyle.Render("Lines take extension numbers; leave a line empty to keep its key unassigned")
+		return menuStyle.Render(content)
+	}
+
+	if len(m.inventoryPhones) == 0 {
+		content += "📭 No phones in the inventory yet - run discovery or add a phone on the VoIP Phones screen\n"
+		return menuStyle.Render(content)
+	}
+
+	content += helpStyle.Render(fmt.Sprintf("  %-17s  %-15s  %-22s  %-16s  %s", "MAC", "IP", "Vendor/Model", "Location", "Lines")) + "\n"
+	for i, p := range m.inventoryPhones {
+		cursor := " "
+		if i == m.selectedInventoryIdx {
+			cursor = "▶"
+		}
+		ip := p.IP
+		if ip == "" {
+			ip = "-"
+		}
+		line := fmt.Sprintf("%-17s  %-15s  %-22s  %-16s  %s", p.MAC, ip, truncateText(strings.TrimSpace(p.Vendor+" "+p.Model), 22),
+			truncateText(p.Location, 16), describePhoneLines(p.Lines))
+		if i == m.selectedInventoryIdx {
+			line = selectedItemStyle.Render(line)
+		}
+		content += cursor + " " + line + "\n"
+	}
+
+	if phone != nil {
+		content += "\n"
+		if phone.Name != "" {
+			content += fmt.Sprintf("Name:        %s\n", phone.Name)
+		}
+		if phone.Firmware != "" {
+			content += fmt.Sprintf("Firmware:    %s\n", phone.Firmware)
+		}
+		credentials := "not stored"
+		if phone.HasCredentials {
+			credentials = "stored (encrypted)"
+		}
+		content += fmt.Sprintf("Credentials: %s\n", credentials)
+		content += fmt.Sprintf("Last seen:   %s\n", phone.LastSeen)
+		if phone.Notes != "" {
+			content += fmt.Sprintf("Notes:       %s\n", phone.Notes)
+		}
+		if len(m.inventoryHistory) > 0 {
+			content += "\n" + helpStyle.Render("Address history:") + "\n"
+			for _, entry := range m.inventoryHistory {
+				content += fmt.Sprintf("  %-15s  %-7s  %s → %s\n", entry.IP, entry.Source, entry.FirstSeen, entry.LastSeen)
+			}
+		}
+	}
+	return menuStyle.Render(content)
+}
+
+// phoneInventoryHelp returns the key help for the phone inventory screen
+func (m model) phoneInventoryHelp() string {
+	if m.inputMode {
+		return "↑/↓: Navigate Fields • Enter: Next/Save • ESC: Cancel • q: Quit"
+	}
+	return "↑/↓: Navigate • Enter: Edit/Assign Lines • p: Provision Now • b: Config Backups • K: Keys • r: Refresh • ESC: Back to VoIP Phones • q: Quit"
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestPhoneInventoryHelpers(t *testing.T) {
+	for in, want := range map[string]string{
+		"00:0B:82:11:22:33": "00:0b:82:11:22:33",
+		"805EC0112233":      "80:5e:c0:11:22:33",
+		"00-04-13-aa-bb-cc": "00:04:13:aa:bb:cc",
+		"00:0B:82":          "",
+		"":                  "",
+	} {
+		if got := formatMAC(in); got != want {
+			t.Errorf("formatMAC(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	extensions := []Extension{{ExtensionNumber: "101"}, {ExtensionNumber: "102"}, {ExtensionNumber: "103"}}
+	lines, err := ParsePhoneLines([]string{"101", "", " 103 ", ""}, extensions)
+	if err != nil || describePhoneLines(lines) != "1:101 3:103" {
+		t.Errorf("expected lines 1:101 3:103, got %q, %v", describePhoneLines(lines), err)
+	}
+	if _, err := ParsePhoneLines([]string{"101", "999"}, extensions); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected an unknown extension on line 2 to be rejected, got %v", err)
+	}
+	if _, err := ParsePhoneLines([]string{"101", "101"}, extensions); err == nil || !strings.Contains(err.Error(), "already on line 1") {
+		t.Errorf("expected a duplicate extension to be rejected, got %v", err)
+	}
+	if got := describePhoneLines(phoneLinesFromColumn("102,,104")); got != "1:102 3:104" {
+		t.Errorf("expected the extension column to map to line keys, got %q", got)
+	}
+
+	phones := []InventoryPhone{
+		{VoIPPhoneDB: VoIPPhoneDB{ID: 1, IP: "192.168.1.20", MAC: "80:5e:c0:11:22:33"}},
+		{VoIPPhoneDB: VoIPPhoneDB{ID: 2, MAC: "00:0b:82:11:22:33"}},
+	}
+	if p := FindInventoryPhone(phones, "805EC0112233"); p == nil || p.ID != 1 {
+		t.Errorf("expected to find phone 1 by bare MAC, got %+v", p)
+	}
+	if p := FindInventoryPhone(phones, "192.168.1.20"); p == nil || p.ID != 1 {
+		t.Errorf("expected to find phone 1 by IP, got %+v", p)
+	}
+	if p := FindInventoryPhone(phones, ""); p != nil {
+		t.Errorf("expected no match for an empty key, got %+v", p)
+	}
+
+	if v := inventoryPhoneVendor(phones[0]); v != "yealink" {
+		t.Errorf("expected the Yealink OUI to select the yealink driver, got %q", v)
+	}
+	if v := inventoryPhoneVendor(InventoryPhone{}); v != "" {
+		t.Errorf("expected an unknown phone to be detected over HTTP, got %q", v)
+	}
+}
+
+func TestPhoneInventoryScreen(t *testing.T) {
+	m := initialModel(nil, nil, false)
+	m.currentScreen = voipPhonesScreen
+	m.handleVoIPPhonesKeyPress("i")
+	if m.currentScreen != phoneInventoryScreen || !strings.Contains(m.errorMsg, "Database not available") {
+		t.Fatalf("expected the inventory screen to report the missing database, got screen %d, %q", m.currentScreen, m.errorMsg)
+	}
+
+	m.inventoryPhones = []InventoryPhone{
+		{VoIPPhoneDB: VoIPPhoneDB{ID: 1, IP: "192.168.1.20", MAC: "80:5e:c0:11:22:33", Vendor: "yealink", Model: "T46S"},
+			Location: "Reception", Lines: []PhoneLine{{Line: 1, Extension: "101"}, {Line: 3, Extension: "103"}}},
+		{VoIPPhoneDB: VoIPPhoneDB{ID: 2, MAC: "00:0b:82:11:22:33", Vendor: "grandstream"}},
+	}
+	view := m.renderPhoneInventory()
+	for _, want := range []string{"80:5e:c0:11:22:33", "Reception", "1:101 3:103", "yealink T46S"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("expected the inventory list to contain %q", want)
+		}
+	}
+
+	m.handlePhoneInventoryScreen(tea.KeyMsg{Type: tea.KeyEnter})
+	if !m.inputMode || len(m.inputFields) != 3+provisioningMaxLines {
+		t.Fatalf("expected the edit form with %d fields, got %v", 3+provisioningMaxLines, m.inputFields)
+	}
+	if m.inputValues[1] != "Reception" || m.inputValues[3] != "101" || m.inputValues[4] != "" || m.inputValues[5] != "103" {
+		t.Errorf("expected the form to hold the phone's location and line keys, got %q", m.inputValues)
+	}
+	m.inputMode = false
+
+	if cmd := m.provisionInventoryPhone(); cmd != nil || !strings.Contains(m.errorMsg, "No credentials") {
+		t.Errorf("expected provisioning without credentials to be refused, got %q", m.errorMsg)
+	}
+	m.handlePhoneInventoryScreen(tea.KeyMsg{Type: tea.KeyDown})
+	if cmd := m.provisionInventoryPhone(); cmd != nil || !strings.Contains(m.errorMsg, "no known address") {
+		t.Errorf("expected provisioning a phone without an address to be refused, got %q", m.errorMsg)
+	}
+
+	m.handlePhoneInventoryScreen(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.currentScreen != voipPhonesScreen {
+		t.Errorf("expected ESC to return to the VoIP phones screen, got %d", m.currentScreen)
+	}
+}
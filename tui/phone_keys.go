@@ -0,0 +1,1015 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// DefaultKeyLayoutsFile holds the key layout profiles and the per-phone layouts
+const DefaultKeyLayoutsFile = "/etc/rayanpbx/phone-key-layouts.json"
+
+// DefaultParkingSlots are the slots of Asterisk's default parking lot (res_parking parkpos)
+const DefaultParkingSlots = "701-720"
+
+// Programmable key types
+const (
+	KeyTypeLine      = "line"
+	KeyTypeBLF       = "blf"
+	KeyTypeSpeedDial = "speed-dial"
+	KeyTypePark      = "park"
+	KeyTypeDND       = "dnd"
+	KeyTypeNone      = "none" // Leaves the key unused, e.g. to blank a profile key on one phone
+)
+
+// keyTypes lists the programmable key types in the order shown to users
+var keyTypes = []string{KeyTypeLine, KeyTypeBLF, KeyTypeSpeedDial, KeyTypePark, KeyTypeDND, KeyTypeNone}
+
+// keyLayoutMaxKeys is the number of programmable keys each vendor's parameters cover
+var keyLayoutMaxKeys = map[string]int{
+	"grandstream": 7, // Multi-purpose keys P301-P329
+	"yealink":     29,
+	"fanvil":      30,
+	"snom":        24,
+}
+
+// grandStreamKeyModes are the GrandStream multi-purpose key modes
+var grandStreamKeyModes = map[string]string{
+	KeyTypeSpeedDial: "0",
+	KeyTypeBLF:       "1",
+	KeyTypePark:      "16", // Monitored call park
+	KeyTypeDND:       "22",
+	KeyTypeNone:      "0",
+}
+
+// yealinkKeyTypes are the Yealink linekey.N.type codes
+var yealinkKeyTypes = map[string]string{
+	KeyTypeNone:      "0",
+	KeyTypeDND:       "5",
+	KeyTypePark:      "10",
+	KeyTypeSpeedDial: "13",
+	KeyTypeLine:      "15",
+	KeyTypeBLF:       "16",
+}
+
+// fvDSSKeyModule is the Fanvil config module of the DSS keys
+const fvDSSKeyModule = "DSSKEY CONFIG MODULE"
+
+// dialableNumber matches numbers a speed dial key can call
+var dialableNumber = regexp.MustCompile(`^[0-9*#+]+$`)
+
+// hintLine matches the hint priorities of a dialplan
+var hintLine = regexp.MustCompile(`^exten\s*=>\s*([^,\s]+)\s*,\s*hint\s*,\s*(.+)$`)
+
+// PhoneKey is one programmable key. Value is the extension of a BLF key, the number of a
+// speed dial, the park slot, or the account shown on a line key.
+type PhoneKey struct {
+	Key   int    `json:"key"` // Position, from 1
+	Type  string `json:"type"`
+	Value string `json:"value,omitempty"`
+	Label string `json:"label,omitempty"`
+}
+
+// KeyLayout is a named profile shared by several phones, or the layout of one phone.
+// A phone's own keys replace the keys at the same positions of its profile.
+type KeyLayout struct {
+	Name    string     `json:"name,omitempty"`    // Profile name
+	MAC     string     `json:"mac,omitempty"`     // Phone layouts only
+	Profile string     `json:"profile,omitempty"` // Profile a phone layout builds on
+	Keys    []PhoneKey `json:"keys"`
+}
+
+// KeyLayouts are the saved profiles and phone layouts
+type KeyLayouts struct {
+	Profiles []KeyLayout `json:"profiles"`
+	Phones   []KeyLayout `json:"phones"`
+}
+
+// keyLayoutsFilePath returns the key layouts path, overridable with RAYANPBX_KEY_LAYOUTS
+func keyLayoutsFilePath() string {
+	return getEnv("RAYANPBX_KEY_LAYOUTS", DefaultKeyLayoutsFile)
+}
+
+// parkingSlots returns the park slot range, overridable with ASTERISK_PARKING_SLOTS
+func parkingSlots() (from, to int) {
+	first, last := ParseExtensionRange(getEnv("ASTERISK_PARKING_SLOTS", DefaultParkingSlots))
+	from, _ = strconv.Atoi(first)
+	to, _ = strconv.Atoi(last)
+	return from, to
+}
+
+// String returns the key as POSITION=TYPE[:VALUE][/LABEL]
+func (k PhoneKey) String() string {
+	s := fmt.Sprintf("%d=%s", k.Key, k.Type)
+	if k.Value != "" {
+		s += ":" + k.Value
+	}
+	if k.Label != "" {
+		s += "/" + k.Label
+	}
+	return s
+}
+
+// account returns the account a key uses: the value of a line key, account 1 otherwise
+func (k PhoneKey) account() int {
+	if k.Type == KeyTypeLine {
+		if n, err := strconv.Atoi(k.Value); err == nil {
+			return n
+		}
+	}
+	return 1
+}
+
+// Check validates a key for a vendor ("" when unknown). BLF keys must watch an extension
+// with a dialplan hint; hints is nil when the hints are unknown.
+func (k PhoneKey) Check(vendor string, hints map[string]string) error {
+	vendor = strings.ToLower(vendor)
+	if k.Key < 1 {
+		return fmt.Errorf("key positions start at 1")
+	}
+	if max, ok := keyLayoutMaxKeys[vendor]; ok && k.Key > max {
+		return fmt.Errorf("%s phones have %d programmable keys", vendor, max)
+	}
+	switch k.Type {
+	case KeyTypeLine:
+		if n, err := strconv.Atoi(k.Value); err != nil || n < 1 || n > provisioningMaxLines {
+			return fmt.Errorf("a line key shows an account from 1 to %d", provisioningMaxLines)
+		}
+		if vendor == "grandstream" {
+			return fmt.Errorf("GrandStream multi-purpose keys cannot be line keys; accounts have their own line keys")
+		}
+	case KeyTypeBLF:
+		if k.Value == "" {
+			return fmt.Errorf("a BLF key needs the extension to watch")
+		}
+		if hints != nil && hints[k.Value] == "" {
+			return fmt.Errorf("extension %s has no dialplan hint, so its BLF key would never light", k.Value)
+		}
+	case KeyTypeSpeedDial:
+		if !dialableNumber.MatchString(k.Value) {
+			return fmt.Errorf("a speed dial key needs a number of digits, *, # or +")
+		}
+	case KeyTypePark:
+		slot, err := strconv.Atoi(k.Value)
+		from, to := parkingSlots()
+		if err != nil || slot < from || slot > to {
+			return fmt.Errorf("park slots are %d-%d", from, to)
+		}
+	case KeyTypeDND, KeyTypeNone:
+		if k.Value != "" {
+			return fmt.Errorf("%s keys take no value", k.Type)
+		}
+	default:
+		return fmt.Errorf("unknown key type %q (use %s)", k.Type, strings.Join(keyTypes, ", "))
+	}
+	return nil
+}
+
+// ValidateKeyLayout checks every key of a layout and that no position is used twice
+func ValidateKeyLayout(keys []PhoneKey, vendor string, hints map[string]string) error {
+	var problems []string
+	seen := make(map[int]bool)
+	for _, key := range keys {
+		if seen[key.Key] {
+			problems = append(problems, fmt.Sprintf("key %d is defined twice", key.Key))
+		}
+		seen[key.Key] = true
+		if err := key.Check(vendor, hints); err != nil {
+			problems = append(problems, fmt.Sprintf("key %d: %v", key.Key, err))
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// ParsePhoneKey parses POSITION=TYPE[:VALUE][/LABEL], e.g. "2=blf:101/Reception". A line key
+// without an account shows the account of the same number as its position.
+func ParsePhoneKey(spec string) (PhoneKey, error) {
+	var key PhoneKey
+	spec = strings.TrimSpace(spec)
+	eq := strings.Index(spec, "=")
+	if eq <= 0 {
+		return key, fmt.Errorf("key %q must be written as POSITION=TYPE[:VALUE][/LABEL]", spec)
+	}
+	position, err := strconv.Atoi(strings.TrimSpace(spec[:eq]))
+	if err != nil {
+		return key, fmt.Errorf("key %q must start with its position", spec)
+	}
+	key.Key = position
+	rest := spec[eq+1:]
+	if i := strings.Index(rest, "/"); i >= 0 {
+		rest, key.Label = rest[:i], strings.TrimSpace(rest[i+1:])
+	}
+	if i := strings.Index(rest, ":"); i >= 0 {
+		rest, key.Value = rest[:i], strings.TrimSpace(rest[i+1:])
+	}
+	key.Type = strings.ToLower(strings.TrimSpace(rest))
+	if key.Type == KeyTypeLine && key.Value == "" {
+		key.Value = strconv.Itoa(position)
+	}
+	return key, nil
+}
+
+// ParseKeyLayout parses keys separated by semicolons, sorted by position
+func ParseKeyLayout(spec string) ([]PhoneKey, error) {
+	var keys []PhoneKey
+	for _, part := range strings.Split(spec, ";") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		key, err := ParsePhoneKey(part)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	sortPhoneKeys(keys)
+	return keys, nil
+}
+
+// FormatKeyLayout returns keys in the form read by ParseKeyLayout
+func FormatKeyLayout(keys []PhoneKey) string {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = key.String()
+	}
+	return strings.Join(parts, ";")
+}
+
+// sortPhoneKeys sorts keys by position
+func sortPhoneKeys(keys []PhoneKey) {
+	sort.SliceStable(keys, func(i, j int) bool { return keys[i].Key < keys[j].Key })
+}
+
+// setPhoneKey replaces the key at the same position, or adds it
+func setPhoneKey(keys []PhoneKey, key PhoneKey) []PhoneKey {
+	updated := removePhoneKey(keys, key.Key)
+	updated = append(updated, key)
+	sortPhoneKeys(updated)
+	return updated
+}
+
+// removePhoneKey returns keys without the key at a position
+func removePhoneKey(keys []PhoneKey, position int) []PhoneKey {
+	var updated []PhoneKey
+	for _, key := range keys {
+		if key.Key != position {
+			updated = append(updated, key)
+		}
+	}
+	return updated
+}
+
+// DialplanHints returns the hints of a dialplan by extension
+func DialplanHints(dialplan string) map[string]string {
+	hints := make(map[string]string)
+	for _, line := range strings.Split(dialplan, "\n") {
+		if m := hintLine.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+			hints[m[1]] = strings.TrimSpace(m[2])
+		}
+	}
+	return hints
+}
+
+// KeyLayoutHints returns the hints GenerateInternalDialplan writes for the extensions
+func KeyLayoutHints(acm *AsteriskConfigManager, extensions []Extension) map[string]string {
+	return DialplanHints(acm.GenerateInternalDialplan(extensions))
+}
+
+// LoadKeyLayouts reads the key layouts. A missing file means no layouts.
+func LoadKeyLayouts(path string) (KeyLayouts, error) {
+	var layouts KeyLayouts
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return layouts, nil
+	}
+	if err != nil {
+		return layouts, fmt.Errorf("failed to read key layouts: %v", err)
+	}
+	if err := json.Unmarshal(data, &layouts); err != nil {
+		return layouts, fmt.Errorf("failed to parse key layouts %s: %v", path, err)
+	}
+	return layouts, nil
+}
+
+// saveKeyLayouts writes the key layouts sorted by profile name and MAC
+func saveKeyLayouts(path string, layouts KeyLayouts) error {
+	sort.Slice(layouts.Profiles, func(i, j int) bool { return layouts.Profiles[i].Name < layouts.Profiles[j].Name })
+	sort.Slice(layouts.Phones, func(i, j int) bool { return layouts.Phones[i].MAC < layouts.Phones[j].MAC })
+	data, err := json.MarshalIndent(layouts, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode key layouts: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to save key layouts: %v", err)
+	}
+	return nil
+}
+
+// Profile returns the profile with the given name, or nil
+func (l KeyLayouts) Profile(name string) *KeyLayout {
+	for i := range l.Profiles {
+		if l.Profiles[i].Name == name {
+			return &l.Profiles[i]
+		}
+	}
+	return nil
+}
+
+// Phone returns the layout of a phone, or nil
+func (l KeyLayouts) Phone(mac string) *KeyLayout {
+	bare := normalizeMAC(mac)
+	for i := range l.Phones {
+		if bare != "" && normalizeMAC(l.Phones[i].MAC) == bare {
+			return &l.Phones[i]
+		}
+	}
+	return nil
+}
+
+// PhoneKeys returns the keys of a phone: its profile's keys with its own keys on top
+func (l KeyLayouts) PhoneKeys(mac string) []PhoneKey {
+	phone := l.Phone(mac)
+	if phone == nil {
+		return nil
+	}
+	var keys []PhoneKey
+	if profile := l.Profile(phone.Profile); profile != nil {
+		keys = append(keys, profile.Keys...)
+	}
+	for _, key := range phone.Keys {
+		keys = setPhoneKey(keys, key)
+	}
+	return keys
+}
+
+// SetKeyProfile adds or replaces a profile. Keys are checked without a vendor, as one
+// profile can serve phones of several vendors.
+func SetKeyProfile(path string, profile KeyLayout, hints map[string]string) (KeyLayouts, error) {
+	profile.Name = strings.TrimSpace(profile.Name)
+	if !isValidProfileName(profile.Name) {
+		return KeyLayouts{}, fmt.Errorf("invalid profile name %q", profile.Name)
+	}
+	if err := ValidateKeyLayout(profile.Keys, "", hints); err != nil {
+		return KeyLayouts{}, err
+	}
+	profile.MAC, profile.Profile = "", ""
+	layouts, err := LoadKeyLayouts(path)
+	if err != nil {
+		return layouts, err
+	}
+	if existing := layouts.Profile(profile.Name); existing != nil {
+		*existing = profile
+	} else {
+		layouts.Profiles = append(layouts.Profiles, profile)
+	}
+	return layouts, saveKeyLayouts(path, layouts)
+}
+
+// DeleteKeyProfile removes a profile no phone uses
+func DeleteKeyProfile(path, name string) (KeyLayouts, error) {
+	layouts, err := LoadKeyLayouts(path)
+	if err != nil {
+		return layouts, err
+	}
+	if layouts.Profile(name) == nil {
+		return layouts, fmt.Errorf("no key layout profile named %q", name)
+	}
+	for _, phone := range layouts.Phones {
+		if phone.Profile == name {
+			return layouts, fmt.Errorf("profile %q is used by phone %s", name, phone.MAC)
+		}
+	}
+	var profiles []KeyLayout
+	for _, profile := range layouts.Profiles {
+		if profile.Name != name {
+			profiles = append(profiles, profile)
+		}
+	}
+	layouts.Profiles = profiles
+	return layouts, saveKeyLayouts(path, layouts)
+}
+
+// SetPhoneKeyLayout sets the profile and own keys of a phone, removing its layout when
+// both are empty. The resulting keys are checked for the phone's vendor.
+func SetPhoneKeyLayout(path string, layout KeyLayout, vendor string, hints map[string]string) (KeyLayouts, error) {
+	layout.MAC = formatMAC(layout.MAC)
+	if layout.MAC == "" {
+		return KeyLayouts{}, fmt.Errorf("key layouts are kept per MAC, and the phone's MAC is unknown")
+	}
+	layouts, err := LoadKeyLayouts(path)
+	if err != nil {
+		return layouts, err
+	}
+	if layout.Profile != "" && layouts.Profile(layout.Profile) == nil {
+		return layouts, fmt.Errorf("no key layout profile named %q", layout.Profile)
+	}
+	layout.Name = ""
+	var phones []KeyLayout
+	for _, phone := range layouts.Phones {
+		if normalizeMAC(phone.MAC) != normalizeMAC(layout.MAC) {
+			phones = append(phones, phone)
+		}
+	}
+	if layout.Profile != "" || len(layout.Keys) > 0 {
+		phones = append(phones, layout)
+	}
+	layouts.Phones = phones
+	if err := ValidateKeyLayout(layouts.PhoneKeys(layout.MAC), vendor, hints); err != nil {
+		return layouts, err
+	}
+	return layouts, saveKeyLayouts(path, layouts)
+}
+
+// KeyLayoutParams returns the vendor parameters of a layout, or nil when the vendor has no
+// programmable key support. Keys the vendor cannot show are left out; server is used in the
+// SIP URIs of Snom keys.
+func KeyLayoutParams(vendor string, keys []PhoneKey, server string) map[string]string {
+	vendor = strings.ToLower(vendor)
+	if _, ok := keyLayoutMaxKeys[vendor]; !ok {
+		return nil
+	}
+	params := make(map[string]string)
+	for _, key := range keys {
+		if key.Check(vendor, nil) != nil {
+			continue
+		}
+		n := key.Key
+		switch vendor {
+		case "grandstream":
+			base := 301 + 3*(n-1)
+			params[fmt.Sprintf("P%d", 322+n)] = grandStreamKeyModes[key.Type]
+			params[fmt.Sprintf("P%d", base)] = strconv.Itoa(key.account() - 1)
+			params[fmt.Sprintf("P%d", base+1)] = key.Label
+			params[fmt.Sprintf("P%d", base+2)] = key.Value
+		case "yealink":
+			prefix := fmt.Sprintf("linekey.%d.", n)
+			params[prefix+"type"] = yealinkKeyTypes[key.Type]
+			params[prefix+"line"] = strconv.Itoa(key.account())
+			params[prefix+"label"] = key.Label
+			params[prefix+"value"] = key.Value
+			if key.Type == KeyTypeLine {
+				params[prefix+"value"] = ""
+			}
+		case "fanvil":
+			prefix := fmt.Sprintf("%s/Fkey%d ", fvDSSKeyModule, n)
+			kind, value := "1", ""
+			switch key.Type {
+			case KeyTypeNone:
+				kind = "0"
+			case KeyTypeLine:
+				kind, value = "2", "SIP"+key.Value
+			case KeyTypeBLF:
+				value = fmt.Sprintf("%s@%d/ba", key.Value, key.account())
+			case KeyTypeSpeedDial, KeyTypePark:
+				value = fmt.Sprintf("%s@%d/sa", key.Value, key.account())
+			case KeyTypeDND:
+				kind, value = "3", "DND"
+			}
+			params[prefix+"Type"] = kind
+			params[prefix+"Value"] = value
+			params[prefix+"Title"] = key.Label
+		case "snom":
+			idx := strconv.Itoa(n - 1)
+			uri := key.Value
+			if server != "" {
+				uri += "@" + server
+			}
+			value := map[string]string{
+				KeyTypeNone:      "none",
+				KeyTypeLine:      "line",
+				KeyTypeBLF:       "blf <sip:" + uri + ">",
+				KeyTypeSpeedDial: "speed " + key.Value,
+				KeyTypePark:      "orbit <sip:" + uri + ">",
+				KeyTypeDND:       "keyevent F_DND",
+			}[key.Type]
+			params["fkey"+idx] = value
+			params["fkey_label"+idx] = key.Label
+		}
+	}
+	return params
+}
+
+// PushKeyLayout writes a phone's keys to it through its driver and returns the vendor
+func PushKeyLayout(pm *PhoneManager, phone BulkPhone, keys []PhoneKey) (string, error) {
+	vp, result, ok := openBulkPhone(pm, phone, CapSetConfig, "key layout")
+	if !ok {
+		return result.Vendor, errors.New(result.Message)
+	}
+	if err := ValidateKeyLayout(keys, result.Vendor, nil); err != nil {
+		return result.Vendor, err
+	}
+	params := KeyLayoutParams(result.Vendor, keys, sipServerFor(phone.IP, phone.Credentials))
+	if params == nil {
+		return result.Vendor, fmt.Errorf("programmable keys are not supported on %s phones", result.Vendor)
+	}
+	config := make(map[string]interface{}, len(params))
+	for key, value := range params {
+		config[key] = value
+	}
+	if err := vp.SetConfig(config); err != nil {
+		return result.Vendor, fmt.Errorf("failed to push the key layout: %v", err)
+	}
+	return result.Vendor, nil
+}
+
+// loadKeyLayoutHints returns the BLF targets from the dialplan hints of the database's
+// extensions, or nil when the database is not available
+func loadKeyLayoutHints(db *sql.DB, acm *AsteriskConfigManager) (map[string]string, error) {
+	if db == nil {
+		return nil, nil
+	}
+	extensions, err := GetExtensions(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load extensions: %v", err)
+	}
+	return KeyLayoutHints(acm, extensions), nil
+}
+
+// Key layout forms
+const (
+	keyLayoutFormKey = iota
+	keyLayoutFormProfile
+	keyLayoutFormSaveProfile
+)
+
+// Fields of the key form
+const (
+	keyFieldPosition = iota
+	keyFieldType
+	keyFieldValue
+	keyFieldLabel
+)
+
+// keyLayoutPushMsg carries the outcome of pushing a layout to a phone
+type keyLayoutPushMsg struct {
+	vendor string
+	keys   int
+	err    error
+}
+
+// initKeyLayout opens the key layout editor of the selected inventory phone
+func (m *model) initKeyLayout() {
+	phone := m.selectedInventoryPhone()
+	switch {
+	case phone == nil:
+		m.errorMsg = "No phone selected"
+		return
+	case phone.MAC == "":
+		m.errorMsg = "The phone's MAC address is unknown - key layouts are kept per MAC"
+		return
+	}
+	m.keyLayoutPhone = BulkPhone{IP: phone.IP, MAC: phone.MAC, Name: phone.Name, Vendor: inventoryPhoneVendor(*phone),
+		Model: phone.Model, Firmware: phone.Firmware, Credentials: m.phoneCredentials[phone.IP]}
+	m.currentScreen = phoneKeysScreen
+	m.keyLayoutProfile = ""
+	m.keyLayoutCursor = 0
+	m.keyLayoutBusy = false
+	m.errorMsg = ""
+	m.successMsg = ""
+	acm := m.configManager
+	if acm == nil {
+		acm = NewAsteriskConfigManager(false)
+	}
+	hints, err := loadKeyLayoutHints(m.db, acm)
+	if err != nil {
+		m.errorMsg = err.Error()
+	}
+	m.keyLayoutHints = hints
+	m.refreshKeyLayout()
+}
+
+// refreshKeyLayout reloads the layouts from disk
+func (m *model) refreshKeyLayout() {
+	layouts, err := LoadKeyLayouts(keyLayoutsFilePath())
+	if err != nil {
+		m.errorMsg = err.Error()
+	}
+	m.keyLayouts = layouts
+	if m.keyLayoutCursor >= len(m.keyLayoutKeys()) {
+		m.keyLayoutCursor = 0
+	}
+}
+
+// keyLayoutKeys returns the keys shown in the editor: the profile's keys while editing a
+// profile, the phone's resolved keys otherwise
+func (m model) keyLayoutKeys() []PhoneKey {
+	if m.keyLayoutProfile != "" {
+		if profile := m.keyLayouts.Profile(m.keyLayoutProfile); profile != nil {
+			return profile.Keys
+		}
+		return nil
+	}
+	return m.keyLayouts.PhoneKeys(m.keyLayoutPhone.MAC)
+}
+
+// phoneKeyLayout returns the saved layout of the edited phone, or an empty one
+func (m model) phoneKeyLayout() KeyLayout {
+	if layout := m.keyLayouts.Phone(m.keyLayoutPhone.MAC); layout != nil {
+		return *layout
+	}
+	return KeyLayout{MAC: m.keyLayoutPhone.MAC}
+}
+
+// keyFromPhone reports whether a key position is set on the phone itself rather than its profile
+func (m model) keyFromPhone(position int) bool {
+	for _, key := range m.phoneKeyLayout().Keys {
+		if key.Key == position {
+			return true
+		}
+	}
+	return false
+}
+
+// selectedPhoneKey returns the key under the cursor
+func (m model) selectedPhoneKey() *PhoneKey {
+	keys := m.keyLayoutKeys()
+	if m.keyLayoutCursor < len(keys) {
+		return &keys[m.keyLayoutCursor]
+	}
+	return nil
+}
+
+// saveEditedKeys saves the keys of the edited profile or phone
+func (m *model) saveEditedKeys(keys []PhoneKey) error {
+	path := keyLayoutsFilePath()
+	var layouts KeyLayouts
+	var err error
+	if m.keyLayoutProfile != "" {
+		layouts, err = SetKeyProfile(path, KeyLayout{Name: m.keyLayoutProfile, Keys: keys}, m.keyLayoutHints)
+	} else {
+		layout := m.phoneKeyLayout()
+		layout.Keys = keys
+		layouts, err = SetPhoneKeyLayout(path, layout, m.keyLayoutPhone.Vendor, m.keyLayoutHints)
+	}
+	if err != nil {
+		return err
+	}
+	m.keyLayouts = layouts
+	return nil
+}
+
+// editedKeys returns the keys saved on the edited profile or phone, without profile keys
+func (m model) editedKeys() []PhoneKey {
+	if m.keyLayoutProfile != "" {
+		return m.keyLayoutKeys()
+	}
+	return m.phoneKeyLayout().Keys
+}
+
+// initPhoneKeyForm opens the form for a new key, or for the selected key when edit is set
+func (m *model) initPhoneKeyForm(edit bool) {
+	key := PhoneKey{Type: KeyTypeBLF}
+	if edit {
+		selected := m.selectedPhoneKey()
+		if selected == nil {
+			m.errorMsg = "No key selected - press a to add one"
+			return
+		}
+		key = *selected
+	} else {
+		for _, existing := range m.keyLayoutKeys() {
+			if existing.Key > key.Key {
+				key.Key = existing.Key
+			}
+		}
+		key.Key++
+	}
+	m.keyLayoutForm = keyLayoutFormKey
+	m.keyLayoutEditing = 0
+	if edit {
+		m.keyLayoutEditing = key.Key
+	}
+	m.inputFields = []string{"Key", "Type (" + strings.Join(keyTypes, ", ") + ")", "Value (extension, number, park slot or account)", "Label"}
+	m.inputValues = []string{strconv.Itoa(key.Key), key.Type, key.Value, key.Label}
+	m.inputMode = true
+	m.inputCursor = keyFieldType
+	m.errorMsg = ""
+	m.successMsg = ""
+}
+
+// savePhoneKeyForm saves the key from the key form
+func (m *model) savePhoneKeyForm() {
+	spec := fmt.Sprintf("%s=%s", strings.TrimSpace(m.inputValues[keyFieldPosition]), m.inputValues[keyFieldType])
+	if value := strings.TrimSpace(m.inputValues[keyFieldValue]); value != "" {
+		spec += ":" + value
+	}
+	key, err := ParsePhoneKey(spec)
+	if err != nil {
+		m.errorMsg = err.Error()
+		return
+	}
+	key.Label = strings.TrimSpace(m.inputValues[keyFieldLabel])
+	vendor := m.keyLayoutPhone.Vendor
+	if m.keyLayoutProfile != "" {
+		vendor = ""
+	}
+	if err := key.Check(vendor, m.keyLayoutHints); err != nil {
+		m.errorMsg = fmt.Sprintf("Key %d: %v", key.Key, err)
+		return
+	}
+	keys := m.editedKeys()
+	if m.keyLayoutEditing != 0 && m.keyLayoutEditing != key.Key {
+		keys = removePhoneKey(keys, m.keyLayoutEditing)
+	}
+	if err := m.saveEditedKeys(setPhoneKey(keys, key)); err != nil {
+		m.errorMsg = err.Error()
+		return
+	}
+	m.inputMode = false
+	m.errorMsg = ""
+	m.successMsg = fmt.Sprintf("Saved key %d", key.Key)
+	for i, existing := range m.keyLayoutKeys() {
+		if existing.Key == key.Key {
+			m.keyLayoutCursor = i
+		}
+	}
+}
+
+// deletePhoneKey removes the selected key. A profile key is blanked on the phone instead.
+func (m *model) deletePhoneKey() {
+	key := m.selectedPhoneKey()
+	if key == nil {
+		m.errorMsg = "No key selected"
+		return
+	}
+	keys := m.editedKeys()
+	message := fmt.Sprintf("Removed key %d", key.Key)
+	if m.keyLayoutProfile == "" && !m.keyFromPhone(key.Key) {
+		keys = setPhoneKey(keys, PhoneKey{Key: key.Key, Type: KeyTypeNone})
+		message = fmt.Sprintf("Key %d of the profile is unused on this phone", key.Key)
+	} else {
+		keys = removePhoneKey(keys, key.Key)
+	}
+	if err := m.saveEditedKeys(keys); err != nil {
+		m.errorMsg = err.Error()
+		return
+	}
+	m.errorMsg = ""
+	m.successMsg = message
+	if m.keyLayoutCursor >= len(m.keyLayoutKeys()) && m.keyLayoutCursor > 0 {
+		m.keyLayoutCursor--
+	}
+}
+
+// initKeyProfileForm asks for the profile the phone builds on, or for the name to save the
+// phone's keys as a new profile
+func (m *model) initKeyProfileForm(form int) {
+	m.keyLayoutForm = form
+	if form == keyLayoutFormProfile {
+		m.inputFields = []string{"Profile (empty for none)"}
+		m.inputValues = []string{m.phoneKeyLayout().Profile}
+	} else {
+		m.inputFields = []string{"New profile name"}
+		m.inputValues = []string{""}
+	}
+	m.inputMode = true
+	m.inputCursor = 0
+	m.errorMsg = ""
+	m.successMsg = ""
+}
+
+// saveKeyProfileForm applies the profile form: it either sets the phone's profile, or saves
+// the phone's keys as a profile the phone then uses
+func (m *model) saveKeyProfileForm() {
+	name := strings.TrimSpace(m.inputValues[0])
+	path := keyLayoutsFilePath()
+	layout := m.phoneKeyLayout()
+	if m.keyLayoutForm == keyLayoutFormSaveProfile {
+		if m.keyLayouts.Profile(name) != nil {
+			m.errorMsg = fmt.Sprintf("Profile %q already exists", name)
+			return
+		}
+		if _, err := SetKeyProfile(path, KeyLayout{Name: name, Keys: m.keyLayoutKeys()}, m.keyLayoutHints); err != nil {
+			m.errorMsg = err.Error()
+			return
+		}
+		layout.Keys = nil
+	}
+	layout.Profile = name
+	layouts, err := SetPhoneKeyLayout(path, layout, m.keyLayoutPhone.Vendor, m.keyLayoutHints)
+	if err != nil {
+		m.errorMsg = err.Error()
+		return
+	}
+	m.keyLayouts = layouts
+	m.inputMode = false
+	m.keyLayoutCursor = 0
+	m.errorMsg = ""
+	if name == "" {
+		m.successMsg = "The phone no longer uses a profile"
+	} else {
+		m.successMsg = fmt.Sprintf("The phone uses profile %s", name)
+	}
+}
+
+// handleKeyLayoutInput submits the open key layout form
+func (m *model) handleKeyLayoutInput() {
+	if m.keyLayoutForm == keyLayoutFormKey {
+		m.savePhoneKeyForm()
+	} else {
+		m.saveKeyProfileForm()
+	}
+}
+
+// startKeyLayoutPush pushes the phone's keys to it through its driver
+func (m *model) startKeyLayoutPush() tea.Cmd {
+	phone := m.keyLayoutPhone
+	keys := m.keyLayoutKeys()
+	switch {
+	case len(keys) == 0:
+		m.errorMsg = "The phone has no keys to push"
+		return nil
+	case phone.IP == "":
+		m.errorMsg = "The phone has no known address"
+		return nil
+	case phone.Credentials["password"] == "":
+		m.errorMsg = "No credentials stored for " + phone.IP + " - add them on the VoIP Phones screen"
+		return nil
+	}
+	if m.phoneManager == nil {
+		m.phoneManager = NewPhoneManager(m.asteriskManager)
+	}
+	pm := m.phoneManager
+	m.keyLayoutBusy = true
+	m.errorMsg = ""
+	m.successMsg = fmt.Sprintf("Pushing %d key(s) to %s...", len(keys), phone.IP)
+	return func() tea.Msg {
+		vendor, err := PushKeyLayout(pm, phone, keys)
+		return keyLayoutPushMsg{vendor: vendor, keys: len(keys), err: err}
+	}
+}
+
+// finishKeyLayoutPush shows the outcome of a push
+func (m *model) finishKeyLayoutPush(msg keyLayoutPushMsg) {
+	m.keyLayoutBusy = false
+	m.successMsg = ""
+	if msg.err != nil {
+		m.errorMsg = fmt.Sprintf("Failed to push keys to %s: %v", m.keyLayoutPhone.IP, msg.err)
+		return
+	}
+	m.errorMsg = ""
+	m.successMsg = fmt.Sprintf("Pushed %d key(s) to %s", msg.keys, m.keyLayoutPhone.IP)
+}
+
+// handlePhoneKeysScreen handles keys on the key layout editor
+func (m *model) handlePhoneKeysScreen(msg tea.KeyMsg) (*model, tea.Cmd) {
+	if msg.String() == "q" || msg.String() == "ctrl+c" {
+		return m, tea.Quit
+	}
+	if m.keyLayoutBusy {
+		return m, nil // Wait for the phone
+	}
+	count := len(m.keyLayoutKeys())
+	switch msg.String() {
+	case "up", "k":
+		if m.keyLayoutCursor > 0 {
+			m.keyLayoutCursor--
+		} else if count > 0 {
+			m.keyLayoutCursor = count - 1
+		}
+	case "down", "j":
+		if m.keyLayoutCursor < count-1 {
+			m.keyLayoutCursor++
+		} else {
+			m.keyLayoutCursor = 0
+		}
+	case "a":
+		m.initPhoneKeyForm(false)
+	case "enter", "e":
+		m.initPhoneKeyForm(true)
+	case "d":
+		m.deletePhoneKey()
+	case "o":
+		if m.keyLayoutProfile == "" {
+			m.initKeyProfileForm(keyLayoutFormProfile)
+		}
+	case "S":
+		if m.keyLayoutProfile == "" {
+			m.initKeyProfileForm(keyLayoutFormSaveProfile)
+		}
+	case "E":
+		if m.keyLayoutProfile == "" {
+			if profile := m.phoneKeyLayout().Profile; profile != "" {
+				m.keyLayoutProfile = profile
+				m.keyLayoutCursor = 0
+				m.errorMsg = ""
+				m.successMsg = ""
+			} else {
+				m.errorMsg = "The phone uses no profile - press o to choose one"
+			}
+		}
+	case "p":
+		if m.keyLayoutProfile == "" {
+			return m, m.startKeyLayoutPush()
+		}
+	case "r":
+		m.errorMsg = ""
+		m.successMsg = ""
+		m.refreshKeyLayout()
+	case "esc":
+		m.errorMsg = ""
+		m.successMsg = ""
+		m.keyLayoutCursor = 0
+		if m.keyLayoutProfile != "" {
+			m.keyLayoutProfile = ""
+		} else {
+			m.currentScreen = phoneInventoryScreen
+		}
+	}
+	return m, nil
+}
+
+// renderPhoneKeys renders the key layout editor
+func (m model) renderPhoneKeys() string {
+	phone := m.keyLayoutPhone
+	content := infoStyle.Render("⌨️  Programmable Keys") + "\n\n"
+	vendor := phone.Vendor
+	if m.keyLayoutProfile != "" {
+		vendor = ""
+		content += fmt.Sprintf("Profile %s (shared by several phones)\n\n", m.keyLayoutProfile)
+	} else {
+		content += fmt.Sprintf("%s  %s  %s %s", phone.MAC, phone.IP, phone.Vendor, phone.Model)
+		if profile := m.phoneKeyLayout().Profile; profile != "" {
+			content += "  • profile " + profile
+		}
+		content += "\n\n"
+	}
+
+	if m.inputMode {
+		for i, field := range m.inputFields {
+			cursor := "  "
+			fieldStyle := lipgloss.NewStyle()
+			if i == m.inputCursor {
+				cursor = "▶ "
+				fieldStyle = selectedItemStyle
+			}
+			value := m.inputValues[i]
+			if value == "" {
+				value = helpStyle.Render("<empty>")
+			}
+			content += fmt.Sprintf("%s%s: %s\n", cursor, fieldStyle.Render(field), value)
+		}
+		if m.keyLayoutForm == keyLayoutFormKey {
+			from, to := parkingSlots()
+			content += "\n" + helpStyle.Render(fmt.Sprintf("BLF keys watch extensions with dialplan hints • park slots are %d-%d", from, to)) + "\n"
+		}
+		return menuStyle.Render(content)
+	}
+
+	keys := m.keyLayoutKeys()
+	if len(keys) == 0 {
+		content += "📭 No programmable keys - press a to add one, or o to use a profile\n"
+	} else {
+		content += helpStyle.Render(fmt.Sprintf("  %-4s  %-10s  %-14s  %-16s  %s", "Key", "Type", "Value", "Label", "From")) + "\n"
+		for i, key := range keys {
+			source := "phone"
+			if m.keyLayoutProfile != "" {
+				source = "profile"
+			} else if !m.keyFromPhone(key.Key) {
+				source = "profile"
+			}
+			line := fmt.Sprintf("%-4d  %-10s  %-14s  %-16s  %s", key.Key, key.Type, truncateText(key.Value, 14), truncateText(key.Label, 16), source)
+			if i == m.keyLayoutCursor {
+				content += "▶ " + selectedItemStyle.Render(line) + "\n"
+			} else {
+				content += "  " + line + "\n"
+			}
+			if err := key.Check(vendor, m.keyLayoutHints); err != nil {
+				content += "    " + warningStyle.Render("⚠️  "+err.Error()) + "\n"
+			}
+		}
+	}
+	if m.keyLayoutHints == nil {
+		content += "\n" + helpStyle.Render("BLF keys are not checked against the dialplan hints: database not available") + "\n"
+	}
+	return menuStyle.Render(content)
+}
+
+// phoneKeysHelp returns the key help for the key layout editor
+func (m model) phoneKeysHelp() string {
+	switch {
+	case m.inputMode:
+		return "↑/↓: Navigate Fields • Enter: Next/Save • ESC: Cancel • q: Quit"
+	case m.keyLayoutBusy:
+		return "Talking to the phone... • q: Quit"
+	case m.keyLayoutProfile != "":
+		return "↑/↓: Navigate • a: Add Key • Enter/e: Edit • d: Delete • r: Refresh • ESC: Back to Phone • q: Quit"
+	}
+	return "↑/↓: Navigate • a: Add Key • Enter/e: Edit • d: Delete • o: Profile • S: Save as Profile • E: Edit Profile • p: Push to Phone • ESC: Back • q: Quit"
+}
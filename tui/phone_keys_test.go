@@ -0,0 +1,259 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestParseKeyLayout(t *testing.T) {
+	keys, err := ParseKeyLayout("3=speed-dial:09121234567/Mobile; 1=line ;2=BLF:101/Reception;4=park:701;5=dnd")
+	if err != nil {
+		t.Fatalf("ParseKeyLayout: %v", err)
+	}
+	want := []PhoneKey{
+		{Key: 1, Type: KeyTypeLine, Value: "1"},
+		{Key: 2, Type: KeyTypeBLF, Value: "101", Label: "Reception"},
+		{Key: 3, Type: KeyTypeSpeedDial, Value: "09121234567", Label: "Mobile"},
+		{Key: 4, Type: KeyTypePark, Value: "701"},
+		{Key: 5, Type: KeyTypeDND},
+	}
+	if len(keys) != len(want) {
+		t.Fatalf("expected %d keys, got %+v", len(want), keys)
+	}
+	for i := range want {
+		if keys[i] != want[i] {
+			t.Errorf("key %d: expected %+v, got %+v", i, want[i], keys[i])
+		}
+	}
+	if got := FormatKeyLayout(keys); got != "1=line:1;2=blf:101/Reception;3=speed-dial:09121234567/Mobile;4=park:701;5=dnd" {
+		t.Errorf("unexpected formatted layout %q", got)
+	}
+	if _, err := ParseKeyLayout("blf:101"); err == nil {
+		t.Error("expected an error for a key without a position")
+	}
+}
+
+func TestPhoneKeyCheck(t *testing.T) {
+	hints := KeyLayoutHints(NewAsteriskConfigManager(false), []Extension{
+		{ExtensionNumber: "101", Enabled: true},
+		{ExtensionNumber: "102", Enabled: false},
+	})
+	if hints["101"] != "PJSIP/101" || hints["102"] != "" {
+		t.Fatalf("unexpected hints %v", hints)
+	}
+
+	tests := []struct {
+		key    PhoneKey
+		vendor string
+		want   string
+	}{
+		{PhoneKey{Key: 1, Type: KeyTypeBLF, Value: "101"}, "yealink", ""},
+		{PhoneKey{Key: 1, Type: KeyTypeBLF, Value: "102"}, "yealink", "no dialplan hint"},
+		{PhoneKey{Key: 1, Type: KeyTypeLine, Value: "1"}, "grandstream", "cannot be line keys"},
+		{PhoneKey{Key: 8, Type: KeyTypeDND}, "grandstream", "have 7 programmable keys"},
+		{PhoneKey{Key: 8, Type: KeyTypeDND}, "", ""},
+		{PhoneKey{Key: 1, Type: KeyTypePark, Value: "750"}, "", "park slots are 701-720"},
+		{PhoneKey{Key: 1, Type: KeyTypeSpeedDial, Value: "home"}, "", "number of digits"},
+		{PhoneKey{Key: 1, Type: KeyTypeDND, Value: "1"}, "", "take no value"},
+		{PhoneKey{Key: 1, Type: "intercom"}, "", "unknown key type"},
+	}
+	for _, tt := range tests {
+		err := tt.key.Check(tt.vendor, hints)
+		if tt.want == "" && err != nil {
+			t.Errorf("%s on %q: unexpected error %v", tt.key, tt.vendor, err)
+		} else if tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+			t.Errorf("%s on %q: expected %q, got %v", tt.key, tt.vendor, tt.want, err)
+		}
+	}
+
+	t.Setenv("ASTERISK_PARKING_SLOTS", "750-759")
+	if err := (PhoneKey{Key: 1, Type: KeyTypePark, Value: "750"}).Check("", nil); err != nil {
+		t.Errorf("expected the configured park slots to be used, got %v", err)
+	}
+}
+
+func TestKeyLayouts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "phone-key-layouts.json")
+	hints := map[string]string{"101": "PJSIP/101", "102": "PJSIP/102"}
+
+	if _, err := SetPhoneKeyLayout(path, KeyLayout{MAC: "80:5e:c0:11:22:33", Profile: "desk"}, "yealink", hints); err == nil {
+		t.Error("expected an error for an unknown profile")
+	}
+	desk, _ := ParseKeyLayout("1=line;2=blf:101;3=blf:102")
+	if _, err := SetKeyProfile(path, KeyLayout{Name: "desk", Keys: desk}, hints); err != nil {
+		t.Fatalf("SetKeyProfile: %v", err)
+	}
+	if _, err := SetKeyProfile(path, KeyLayout{Name: "bad", Keys: []PhoneKey{{Key: 1, Type: KeyTypeBLF, Value: "199"}}}, hints); err == nil {
+		t.Error("expected BLF keys without a hint to be rejected")
+	}
+
+	own, _ := ParseKeyLayout("3=none;4=dnd")
+	layouts, err := SetPhoneKeyLayout(path, KeyLayout{MAC: "805EC0112233", Profile: "desk", Keys: own}, "yealink", hints)
+	if err != nil {
+		t.Fatalf("SetPhoneKeyLayout: %v", err)
+	}
+	if got := FormatKeyLayout(layouts.PhoneKeys("80-5e-c0-11-22-33")); got != "1=line:1;2=blf:101;3=none;4=dnd" {
+		t.Errorf("expected the phone keys on top of the profile, got %q", got)
+	}
+	if _, err := SetPhoneKeyLayout(path, KeyLayout{MAC: "000b82112233", Profile: "desk"}, "grandstream", hints); err == nil {
+		t.Error("expected the profile's line key to be rejected on a GrandStream phone")
+	}
+	if _, err := DeleteKeyProfile(path, "desk"); err == nil || !strings.Contains(err.Error(), "80:5e:c0:11:22:33") {
+		t.Errorf("expected a profile in use to be kept, got %v", err)
+	}
+
+	// Clearing the profile and keys removes the phone's layout
+	if _, err := SetPhoneKeyLayout(path, KeyLayout{MAC: "805ec0112233"}, "yealink", hints); err != nil {
+		t.Fatalf("SetPhoneKeyLayout: %v", err)
+	}
+	layouts, err = DeleteKeyProfile(path, "desk")
+	if err != nil || len(layouts.Phones) != 0 || len(layouts.Profiles) != 0 {
+		t.Errorf("expected no layouts left, got %+v %v", layouts, err)
+	}
+}
+
+func TestKeyLayoutParams(t *testing.T) {
+	keys, _ := ParseKeyLayout("1=line:2/Sales;2=blf:101/Reception;3=park:701;9=dnd")
+
+	yealink := KeyLayoutParams("yealink", keys, "192.168.1.10")
+	for key, want := range map[string]string{"linekey.1.type": "15", "linekey.1.line": "2", "linekey.1.label": "Sales",
+		"linekey.2.type": "16", "linekey.2.value": "101", "linekey.3.type": "10", "linekey.9.type": "5"} {
+		if yealink[key] != want {
+			t.Errorf("yealink %s: expected %q, got %q", key, want, yealink[key])
+		}
+	}
+
+	// GrandStream has no line mode and seven multi-purpose keys
+	gs := KeyLayoutParams("grandstream", keys, "")
+	if gs["P324"] != "1" || gs["P304"] != "0" || gs["P305"] != "Reception" || gs["P306"] != "101" || gs["P325"] != "16" || gs["P323"] != "" || len(gs) != 8 {
+		t.Errorf("unexpected GrandStream params %v", gs)
+	}
+	snom := KeyLayoutParams("snom", keys, "192.168.1.10")
+	if snom["fkey1"] != "blf <sip:101@192.168.1.10>" || snom["fkey_label1"] != "Reception" || snom["fkey0"] != "line" {
+		t.Errorf("unexpected Snom params %v", snom)
+	}
+	fanvil := KeyLayoutParams("fanvil", keys, "")
+	if fanvil[fvDSSKeyModule+"/Fkey2 Value"] != "101@1/ba" || fanvil[fvDSSKeyModule+"/Fkey1 Value"] != "SIP2" {
+		t.Errorf("unexpected Fanvil params %v", fanvil)
+	}
+	if KeyLayoutParams("polycom", keys, "") != nil {
+		t.Error("expected no params for a vendor without key support")
+	}
+}
+
+func TestKeyLayoutProvisioning(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "phone-key-layouts.json")
+	t.Setenv("RAYANPBX_KEY_LAYOUTS", path)
+	keys, _ := ParseKeyLayout("1=line;2=blf:102/Bob")
+	if _, err := SetPhoneKeyLayout(path, KeyLayout{MAC: "805ec0112233", Keys: keys}, "yealink", nil); err != nil {
+		t.Fatalf("SetPhoneKeyLayout: %v", err)
+	}
+
+	server := newTestProvisioningServer(t, ProvisioningSettings{})
+	data, _, _, err := server.Render("805ec0112233.cfg", "")
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	for _, want := range []string{"account.1.user_name = 101", "linekey.2.type = 16", "linekey.2.value = 102", "linekey.2.label = Bob"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("missing %q in:\n%s", want, data)
+		}
+	}
+
+	// Edits to the layouts file are picked up by the running server
+	keys, _ = ParseKeyLayout("2=speed-dial:*97/Voicemail")
+	SetPhoneKeyLayout(path, KeyLayout{MAC: "805ec0112233", Keys: keys}, "yealink", nil)
+	os.Chtimes(path, time.Now().Add(time.Minute), time.Now().Add(time.Minute))
+	data, _, _, _ = server.Render("805ec0112233.cfg", "")
+	if !strings.Contains(string(data), "linekey.2.value = *97") {
+		t.Errorf("expected the changed layout, got:\n%s", data)
+	}
+}
+
+func TestPushKeyLayout(t *testing.T) {
+	fake, phone := newFakeYealink(t)
+	pm := NewPhoneManager(NewAsteriskManager())
+	target := BulkPhone{IP: phone.ip, Vendor: "yealink", Credentials: map[string]string{"username": "admin", "password": "secret", "sip_server": "192.168.1.10"}}
+
+	keys, _ := ParseKeyLayout("1=line;2=speed-dial:*97/Voicemail")
+	if _, err := PushKeyLayout(pm, target, keys); err != nil {
+		t.Fatalf("PushKeyLayout: %v", err)
+	}
+	if !strings.Contains(fake.imported, "linekey.2.type = 13") || !strings.Contains(fake.imported, "linekey.2.value = *97") {
+		t.Errorf("expected the keys to be imported, got %q", fake.imported)
+	}
+
+	target.Vendor = "polycom"
+	if _, err := PushKeyLayout(pm, target, keys); err == nil {
+		t.Error("expected an error for a vendor without key support")
+	}
+}
+
+func TestPhoneKeysScreen(t *testing.T) {
+	t.Setenv("RAYANPBX_KEY_LAYOUTS", filepath.Join(t.TempDir(), "phone-key-layouts.json"))
+
+	m := initialModel(nil, nil, false)
+	m.currentScreen = phoneInventoryScreen
+	m.inventoryPhones = []InventoryPhone{{VoIPPhoneDB: VoIPPhoneDB{ID: 1, IP: "192.0.2.30", MAC: "80:5e:c0:11:22:33", Vendor: "yealink"}}}
+	m.handlePhoneInventoryScreen(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'K'}})
+	if m.currentScreen != phoneKeysScreen || !strings.Contains(m.renderPhoneKeys(), "No programmable keys") {
+		t.Fatalf("expected the empty key editor, got screen %d", m.currentScreen)
+	}
+
+	m.handlePhoneKeysScreen(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'a'}})
+	if !m.inputMode || m.inputValues[keyFieldPosition] != "1" {
+		t.Fatalf("expected the key form for key 1, got %v", m.inputValues)
+	}
+	m.inputValues[keyFieldValue] = "101"
+	m.handleKeyLayoutInput()
+	if m.inputMode || len(m.keyLayoutKeys()) != 1 {
+		t.Fatalf("expected the BLF key to be saved, got %q", m.errorMsg)
+	}
+	if !strings.Contains(m.renderPhoneKeys(), "not checked against the dialplan hints") {
+		t.Error("expected a note that BLF keys are not checked without the database")
+	}
+
+	// Save the keys as a profile, then blank its key on this phone
+	m.handlePhoneKeysScreen(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'S'}})
+	m.inputValues[0] = "desk"
+	m.handleKeyLayoutInput()
+	if m.inputMode || m.phoneKeyLayout().Profile != "desk" || len(m.phoneKeyLayout().Keys) != 0 {
+		t.Fatalf("expected the phone to use the new profile, got %+v %q", m.phoneKeyLayout(), m.errorMsg)
+	}
+	m.handlePhoneKeysScreen(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'d'}})
+	if keys := m.keyLayoutKeys(); len(keys) != 1 || keys[0].Type != KeyTypeNone || !m.keyFromPhone(1) {
+		t.Errorf("expected the profile key to be blanked on the phone, got %+v", keys)
+	}
+
+	m.handlePhoneKeysScreen(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'E'}})
+	if m.keyLayoutProfile != "desk" || m.keyLayoutKeys()[0].Type != KeyTypeBLF {
+		t.Fatalf("expected to edit the profile, got %q", m.keyLayoutProfile)
+	}
+	m.handlePhoneKeysScreen(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'a'}})
+	m.inputValues[keyFieldType] = KeyTypePark
+	m.inputValues[keyFieldValue] = "799"
+	m.handleKeyLayoutInput()
+	if !m.inputMode || !strings.Contains(m.errorMsg, "park slots") {
+		t.Errorf("expected an invalid park slot to be rejected, got %q", m.errorMsg)
+	}
+	m.inputMode = false
+
+	m.handlePhoneKeysScreen(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'p'}})
+	if m.keyLayoutBusy {
+		t.Error("expected pushing to be unavailable while editing a profile")
+	}
+	m.handlePhoneKeysScreen(tea.KeyMsg{Type: tea.KeyEsc})
+	m.handlePhoneKeysScreen(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'p'}})
+	if !strings.Contains(m.errorMsg, "No credentials") {
+		t.Errorf("expected a missing credentials error, got %q", m.errorMsg)
+	}
+	m.handlePhoneKeysScreen(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.currentScreen != phoneInventoryScreen {
+		t.Error("expected ESC to return to the inventory")
+	}
+}
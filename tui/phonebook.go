@@ -0,0 +1,697 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Phonebook defaults
+const (
+	DefaultPhonebookFile     = "/etc/rayanpbx/phonebook.json"
+	DefaultPhonebookBaseDN   = "dc=rayanpbx"
+	DefaultPhonebookInterval = 60 // Minutes between phone phonebook downloads
+	phonebookURLPrefix       = "/phonebook/"
+	phonebookGroupExtensions = "Extensions"
+	phonebookGroupContacts   = "Contacts"
+	ciscoDirectoryMaxEntries = 32 // Cisco phones reject directories with more entries
+)
+
+// Phonebook formats served under /phonebook/
+const (
+	PhonebookGrandStream = "grandstream"
+	PhonebookYealink     = "yealink"
+	PhonebookCisco       = "cisco"
+)
+
+// GrandStream phonebook download P-values
+const (
+	gsPPhonebookDownload = "P330" // 0 off, 1 HTTP, 2 TFTP, 3 HTTPS
+	gsPPhonebookPath     = "P331"
+	gsPPhonebookInterval = "P332" // Minutes between downloads
+)
+
+// phonebookFiles maps the files served under /phonebook/ to their formats
+var phonebookFiles = map[string]string{
+	"phonebook.xml": PhonebookGrandStream,
+	"yealink.xml":   PhonebookYealink,
+	"cisco.xml":     PhonebookCisco,
+}
+
+// phonebookNumberPattern matches dialable numbers
+var phonebookNumberPattern = regexp.MustCompile(`^\+?[0-9*#]+$`)
+
+// PhonebookEntry is one directory entry: an extension or an external contact
+type PhonebookEntry struct {
+	Name      string `json:"name"`
+	Number    string `json:"number"`
+	Mobile    string `json:"mobile,omitempty"`
+	Company   string `json:"company,omitempty"`
+	Group     string `json:"group,omitempty"`
+	Extension bool   `json:"-"` // Built from the extension directory, not editable
+}
+
+// Validate checks the entry can be dialed and shown on phones
+func (e PhonebookEntry) Validate() error {
+	if strings.TrimSpace(e.Name) == "" {
+		return fmt.Errorf("the contact needs a name")
+	}
+	if strings.ContainsAny(e.Name, "\r\n\t") {
+		return fmt.Errorf("the contact name cannot contain line breaks or tabs")
+	}
+	if !phonebookNumberPattern.MatchString(e.Number) {
+		return fmt.Errorf("invalid number %q: use digits, *, # and an optional leading +", e.Number)
+	}
+	if e.Mobile != "" && !phonebookNumberPattern.MatchString(e.Mobile) {
+		return fmt.Errorf("invalid mobile number %q: use digits, *, # and an optional leading +", e.Mobile)
+	}
+	return nil
+}
+
+// FirstName returns the first word of the name
+func (e PhonebookEntry) FirstName() string {
+	first, _ := splitPhonebookName(e.Name)
+	return first
+}
+
+// LastName returns the name without its first word, or the whole name if it is one word
+func (e PhonebookEntry) LastName() string {
+	first, last := splitPhonebookName(e.Name)
+	if last == "" {
+		return first
+	}
+	return last
+}
+
+// splitPhonebookName splits a name at its first space
+func splitPhonebookName(name string) (string, string) {
+	fields := strings.Fields(name)
+	if len(fields) == 0 {
+		return "", ""
+	}
+	return fields[0], strings.Join(fields[1:], " ")
+}
+
+// phonebookFilePath returns the contacts file path, overridable with RAYANPBX_PHONEBOOK
+func phonebookFilePath() string {
+	return getEnv("RAYANPBX_PHONEBOOK", DefaultPhonebookFile)
+}
+
+// LoadPhonebookContacts reads the external contacts. A missing file means no contacts.
+func LoadPhonebookContacts(path string) ([]PhonebookEntry, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read phonebook: %v", err)
+	}
+	var saved struct {
+		Contacts []PhonebookEntry `json:"contacts"`
+	}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("failed to parse phonebook %s: %v", path, err)
+	}
+	return saved.Contacts, nil
+}
+
+// savePhonebookContacts writes the external contacts sorted by name
+func savePhonebookContacts(path string, contacts []PhonebookEntry) error {
+	sort.SliceStable(contacts, func(i, j int) bool {
+		return strings.ToLower(contacts[i].Name) < strings.ToLower(contacts[j].Name)
+	})
+	data, err := json.MarshalIndent(struct {
+		Contacts []PhonebookEntry `json:"contacts"`
+	}{contacts}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode phonebook: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to save phonebook: %v", err)
+	}
+	return nil
+}
+
+// SavePhonebookContact adds a contact, replacing one named previous (or with the same name)
+func SavePhonebookContact(path, previous string, contact PhonebookEntry) ([]PhonebookEntry, error) {
+	contact.Name = strings.TrimSpace(contact.Name)
+	contact.Extension = false
+	if err := contact.Validate(); err != nil {
+		return nil, err
+	}
+	contacts, err := LoadPhonebookContacts(path)
+	if err != nil {
+		return nil, err
+	}
+	if previous == "" {
+		previous = contact.Name
+	}
+	kept := contacts[:0]
+	for _, existing := range contacts {
+		if strings.EqualFold(existing.Name, previous) || strings.EqualFold(existing.Name, contact.Name) {
+			continue
+		}
+		kept = append(kept, existing)
+	}
+	contacts = append(kept, contact)
+	if err := savePhonebookContacts(path, contacts); err != nil {
+		return nil, err
+	}
+	return contacts, nil
+}
+
+// DeletePhonebookContact removes the contact with the given name
+func DeletePhonebookContact(path, name string) ([]PhonebookEntry, error) {
+	contacts, err := LoadPhonebookContacts(path)
+	if err != nil {
+		return nil, err
+	}
+	kept := contacts[:0]
+	for _, existing := range contacts {
+		if !strings.EqualFold(existing.Name, name) {
+			kept = append(kept, existing)
+		}
+	}
+	if len(kept) == len(contacts) {
+		return nil, fmt.Errorf("no contact named %q", name)
+	}
+	if err := savePhonebookContacts(path, kept); err != nil {
+		return nil, err
+	}
+	return kept, nil
+}
+
+// BuildPhonebook merges the enabled extensions and the external contacts, sorted by name
+func BuildPhonebook(extensions []Extension, contacts []PhonebookEntry) []PhonebookEntry {
+	var entries []PhonebookEntry
+	for _, ext := range extensions {
+		if !ext.Enabled {
+			continue
+		}
+		name := strings.TrimSpace(ext.Name)
+		if name == "" {
+			name = ext.ExtensionNumber
+		}
+		entries = append(entries, PhonebookEntry{Name: name, Number: ext.ExtensionNumber, Group: phonebookGroupExtensions, Extension: true})
+	}
+	for _, contact := range contacts {
+		if contact.Group == "" {
+			contact.Group = phonebookGroupContacts
+		}
+		entries = append(entries, contact)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := strings.ToLower(entries[i].Name), strings.ToLower(entries[j].Name)
+		if a != b {
+			return a < b
+		}
+		return entries[i].Number < entries[j].Number
+	})
+	return entries
+}
+
+// FilterPhonebook returns the entries whose name, number or company contains query
+func FilterPhonebook(entries []PhonebookEntry, query string) []PhonebookEntry {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return entries
+	}
+	var matched []PhonebookEntry
+	for _, e := range entries {
+		if strings.Contains(strings.ToLower(e.Name), query) || strings.Contains(e.Number, query) ||
+			strings.Contains(e.Mobile, query) || strings.Contains(strings.ToLower(e.Company), query) {
+			matched = append(matched, e)
+		}
+	}
+	return matched
+}
+
+// PhonebookSource returns the current directory entries
+type PhonebookSource func() ([]PhonebookEntry, error)
+
+// DBPhonebookSource builds the directory from the extensions in the database and the contacts file
+func DBPhonebookSource(db *sql.DB) PhonebookSource {
+	return func() ([]PhonebookEntry, error) {
+		extensions, err := GetExtensions(db)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load extensions: %v", err)
+		}
+		contacts, err := LoadPhonebookContacts(phonebookFilePath())
+		if err != nil {
+			return nil, err
+		}
+		return BuildPhonebook(extensions, contacts), nil
+	}
+}
+
+// gsPhonebookXML is the GrandStream phonebook.xml document
+type gsPhonebookXML struct {
+	XMLName  xml.Name           `xml:"AddressBook"`
+	Groups   []gsPhonebookGroup `xml:"pbgroup"`
+	Contacts []gsPhonebookEntry `xml:"Contact"`
+}
+
+type gsPhonebookGroup struct {
+	ID   int    `xml:"id"`
+	Name string `xml:"name"`
+}
+
+type gsPhonebookEntry struct {
+	LastName  string             `xml:"LastName"`
+	FirstName string             `xml:"FirstName"`
+	Company   string             `xml:"Company,omitempty"`
+	Phones    []gsPhonebookPhone `xml:"Phone"`
+	Group     int                `xml:"Groups>groupid"`
+}
+
+type gsPhonebookPhone struct {
+	Type    string `xml:"type,attr"`
+	Number  string `xml:"phonenumber"`
+	Account int    `xml:"accountindex"`
+}
+
+// ipPhoneDirectory is the Yealink and Cisco directory document
+type ipPhoneDirectory struct {
+	XMLName xml.Name
+	Title   string                 `xml:"Title,omitempty"`
+	Prompt  string                 `xml:"Prompt,omitempty"`
+	Entries []ipPhoneDirectoryItem `xml:"DirectoryEntry"`
+}
+
+type ipPhoneDirectoryItem struct {
+	Name      string   `xml:"Name"`
+	Telephone []string `xml:"Telephone"`
+}
+
+// RenderPhonebook renders the entries as a phonebook document in a vendor format
+func RenderPhonebook(format string, entries []PhonebookEntry) ([]byte, error) {
+	var doc interface{}
+	switch format {
+	case PhonebookGrandStream:
+		book := gsPhonebookXML{}
+		groups := make(map[string]int)
+		for _, e := range entries {
+			id, ok := groups[e.Group]
+			if !ok {
+				id = len(groups) + 1
+				groups[e.Group] = id
+				book.Groups = append(book.Groups, gsPhonebookGroup{ID: id, Name: e.Group})
+			}
+			contact := gsPhonebookEntry{FirstName: e.FirstName(), Company: e.Company, Group: id,
+				Phones: []gsPhonebookPhone{{Type: "Work", Number: e.Number, Account: 1}}}
+			if _, last := splitPhonebookName(e.Name); last != "" {
+				contact.LastName = last
+			}
+			if e.Mobile != "" {
+				contact.Phones = append(contact.Phones, gsPhonebookPhone{Type: "Cell", Number: e.Mobile, Account: 1})
+			}
+			book.Contacts = append(book.Contacts, contact)
+		}
+		doc = book
+	case PhonebookYealink, PhonebookCisco:
+		dir := ipPhoneDirectory{XMLName: xml.Name{Local: "YealinkIPPhoneDirectory"}}
+		if format == PhonebookCisco {
+			dir.XMLName.Local = "CiscoIPPhoneDirectory"
+			dir.Title = "Company Directory"
+			dir.Prompt = fmt.Sprintf("%d entries", len(entries))
+			if len(entries) > ciscoDirectoryMaxEntries {
+				dir.Prompt = fmt.Sprintf("First %d of %d entries - search to narrow", ciscoDirectoryMaxEntries, len(entries))
+				entries = entries[:ciscoDirectoryMaxEntries]
+			}
+		}
+		for _, e := range entries {
+			item := ipPhoneDirectoryItem{Name: e.Name, Telephone: []string{e.Number}}
+			if e.Mobile != "" {
+				item.Telephone = append(item.Telephone, e.Mobile)
+			}
+			dir.Entries = append(dir.Entries, item)
+		}
+		doc = dir
+	default:
+		return nil, fmt.Errorf("unknown phonebook format %q (use grandstream, yealink or cisco)", format)
+	}
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to render %s phonebook: %v", format, err)
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+// PhonebookURL returns the URL of a phonebook file, with the provisioning credentials if set
+func PhonebookURL(settings ProvisioningSettings, file string) string {
+	base := settings.URL()
+	if settings.Username != "" {
+		base = strings.Replace(base, "://", "://"+settings.Username+":"+settings.Password+"@", 1)
+	}
+	return base + strings.TrimPrefix(phonebookURLPrefix, "/") + file
+}
+
+// PhonebookLDAPAddress returns the host and port phones use for the LDAP phonebook, or false if disabled
+func (s ProvisioningSettings) PhonebookLDAPAddress() (string, string, bool) {
+	if s.PhonebookLDAPAddr == "" {
+		return "", "", false
+	}
+	host, port, err := net.SplitHostPort(s.PhonebookLDAPAddr)
+	if err != nil {
+		return "", "", false
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = s.Host
+	}
+	return host, port, true
+}
+
+// PhonebookBase returns the LDAP base DN of the phonebook
+func (s ProvisioningSettings) PhonebookBase() string {
+	if s.PhonebookBaseDN == "" {
+		return DefaultPhonebookBaseDN
+	}
+	return s.PhonebookBaseDN
+}
+
+// PhonebookParams returns the vendor parameters that point a phone at the phonebook
+func PhonebookParams(vendor string, settings ProvisioningSettings) map[string]string {
+	interval := strconv.Itoa(DefaultPhonebookInterval)
+	host, port, ldap := settings.PhonebookLDAPAddress()
+	switch vendor {
+	case "grandstream":
+		via := "1"
+		if settings.TLS() {
+			via = "3"
+		}
+		path := strings.SplitN(PhonebookURL(settings, ""), "://", 2)[1]
+		return map[string]string{
+			gsPPhonebookDownload: via,
+			gsPPhonebookPath:     strings.TrimSuffix(path, "/"),
+			gsPPhonebookInterval: interval,
+		}
+	case "yealink":
+		params := map[string]string{
+			"remote_phonebook.data.1.url":          PhonebookURL(settings, "yealink.xml"),
+			"remote_phonebook.data.1.name":         "Company",
+			"features.remote_phonebook.enable":     "1",
+			"features.remote_phonebook.flash_time": strconv.Itoa(DefaultPhonebookInterval * 60),
+		}
+		if ldap {
+			params["ldap.enable"] = "1"
+			params["ldap.host"] = host
+			params["ldap.port"] = port
+			params["ldap.base"] = settings.PhonebookBase()
+			params["ldap.user"] = settings.Username
+			params["ldap.password"] = settings.Password
+			params["ldap.version"] = "3"
+			params["ldap.name_filter"] = "(|(cn=%)(sn=%))"
+			params["ldap.number_filter"] = "(|(telephoneNumber=%)(mobile=%))"
+			params["ldap.name_attr"] = "cn sn"
+			params["ldap.numb_attr"] = "telephoneNumber mobile"
+			params["ldap.display_name"] = "%cn"
+		}
+		return params
+	case "snom":
+		if !ldap {
+			return nil
+		}
+		return map[string]string{
+			"ldap_server":            host,
+			"ldap_port":              port,
+			"ldap_base":              settings.PhonebookBase(),
+			"ldap_username":          settings.Username,
+			"ldap_password":          settings.Password,
+			"ldap_search_filter":     "(|(cn=%)(sn=%))",
+			"ldap_number_filter":     "(|(telephoneNumber=%)(mobile=%))",
+			"ldap_name_attributes":   "cn sn",
+			"ldap_number_attributes": "telephoneNumber mobile",
+			"ldap_display_name":      "%cn",
+		}
+	}
+	return nil
+}
+
+// SetPhonebook serves the directory from source under /phonebook/ and on the LDAP address
+func (ps *ProvisioningServer) SetPhonebook(source PhonebookSource) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.phonebook = source
+}
+
+// phonebookSource returns the directory source, or nil if none is set
+func (ps *ProvisioningServer) phonebookSource() PhonebookSource {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	return ps.phonebook
+}
+
+// servePhonebook serves a phonebook file, optionally narrowed with ?q=
+func (ps *ProvisioningServer) servePhonebook(w http.ResponseWriter, r *http.Request, entry *ProvisioningLogEntry) {
+	format, ok := phonebookFiles[strings.TrimPrefix(r.URL.Path, phonebookURLPrefix)]
+	source := ps.phonebookSource()
+	if !ok || source == nil {
+		entry.Status = http.StatusNotFound
+		http.NotFound(w, r)
+		return
+	}
+	entries, err := source()
+	if err == nil {
+		var data []byte
+		if data, err = RenderPhonebook(format, FilterPhonebook(entries, r.URL.Query().Get("q"))); err == nil {
+			entry.Vendor = format
+			entry.Status = http.StatusOK
+			entry.Bytes = len(data)
+			w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+			w.Header().Set("Cache-Control", "no-store")
+			w.Write(data)
+			return
+		}
+	}
+	entry.Status = http.StatusInternalServerError
+	http.Error(w, "failed to render phonebook", entry.Status)
+}
+
+// Phonebook contact form fields
+const (
+	phonebookFieldName = iota
+	phonebookFieldNumber
+	phonebookFieldMobile
+	phonebookFieldCompany
+	phonebookFieldGroup
+)
+
+// initPhonebook opens the phonebook screen from the provisioning screen
+func (m *model) initPhonebook() {
+	m.currentScreen = phonebookScreen
+	m.phonebookCursor = 0
+	m.errorMsg = ""
+	m.successMsg = ""
+	m.loadPhonebook()
+}
+
+// loadPhonebook reloads the directory, listing only the contacts without a database
+func (m *model) loadPhonebook() {
+	var extensions []Extension
+	if m.db != nil {
+		var err error
+		if extensions, err = GetExtensions(m.db); err != nil {
+			m.errorMsg = fmt.Sprintf("Failed to load extensions: %v", err)
+		}
+	}
+	contacts, err := LoadPhonebookContacts(phonebookFilePath())
+	if err != nil {
+		m.errorMsg = err.Error()
+	}
+	m.phonebookEntries = BuildPhonebook(extensions, contacts)
+	if m.phonebookCursor >= len(m.phonebookEntries) {
+		m.phonebookCursor = 0
+	}
+}
+
+// selectedPhonebookEntry returns the entry under the cursor, or nil
+func (m model) selectedPhonebookEntry() *PhonebookEntry {
+	if m.phonebookCursor < 0 || m.phonebookCursor >= len(m.phonebookEntries) {
+		return nil
+	}
+	return &m.phonebookEntries[m.phonebookCursor]
+}
+
+// initPhonebookForm opens the contact form, filled from the selected contact when editing
+func (m *model) initPhonebookForm(edit bool) {
+	contact := PhonebookEntry{Group: phonebookGroupContacts}
+	m.phonebookEditing = ""
+	if edit {
+		selected := m.selectedPhonebookEntry()
+		if selected == nil {
+			m.errorMsg = "No contact selected - press a to add one"
+			return
+		}
+		if selected.Extension {
+			m.errorMsg = "Extensions come from the extension directory - edit them on the Extensions screen"
+			return
+		}
+		contact = *selected
+		m.phonebookEditing = contact.Name
+	}
+	m.inputFields = []string{"Name", "Number", "Mobile", "Company", "Group"}
+	m.inputValues = []string{contact.Name, contact.Number, contact.Mobile, contact.Company, contact.Group}
+	m.inputMode = true
+	m.inputCursor = phonebookFieldName
+	m.errorMsg = ""
+	m.successMsg = ""
+}
+
+// savePhonebookForm saves the contact from the contact form
+func (m *model) savePhonebookForm() {
+	contact := PhonebookEntry{
+		Name:    strings.TrimSpace(m.inputValues[phonebookFieldName]),
+		Number:  strings.TrimSpace(m.inputValues[phonebookFieldNumber]),
+		Mobile:  strings.TrimSpace(m.inputValues[phonebookFieldMobile]),
+		Company: strings.TrimSpace(m.inputValues[phonebookFieldCompany]),
+		Group:   strings.TrimSpace(m.inputValues[phonebookFieldGroup]),
+	}
+	if _, err := SavePhonebookContact(phonebookFilePath(), m.phonebookEditing, contact); err != nil {
+		m.errorMsg = err.Error()
+		return
+	}
+	m.inputMode = false
+	m.errorMsg = ""
+	m.successMsg = "Saved contact " + contact.Name
+	m.loadPhonebook()
+	for i, e := range m.phonebookEntries {
+		if !e.Extension && e.Name == contact.Name {
+			m.phonebookCursor = i
+		}
+	}
+}
+
+// deletePhonebookEntry removes the selected contact
+func (m *model) deletePhonebookEntry() {
+	selected := m.selectedPhonebookEntry()
+	if selected == nil {
+		return
+	}
+	if selected.Extension {
+		m.errorMsg = "Extensions come from the extension directory - disable them on the Extensions screen"
+		return
+	}
+	name := selected.Name
+	if _, err := DeletePhonebookContact(phonebookFilePath(), name); err != nil {
+		m.errorMsg = err.Error()
+		return
+	}
+	m.errorMsg = ""
+	m.successMsg = "Deleted contact " + name
+	m.loadPhonebook()
+}
+
+// handlePhonebookScreen handles keys on the phonebook screen
+func (m *model) handlePhonebookScreen(msg tea.KeyMsg) (*model, tea.Cmd) {
+	switch msg.String() {
+	case "up", "k":
+		if m.phonebookCursor > 0 {
+			m.phonebookCursor--
+		} else if len(m.phonebookEntries) > 0 {
+			m.phonebookCursor = len(m.phonebookEntries) - 1
+		}
+	case "down", "j":
+		if m.phonebookCursor < len(m.phonebookEntries)-1 {
+			m.phonebookCursor++
+		} else {
+			m.phonebookCursor = 0
+		}
+	case "a":
+		m.initPhonebookForm(false)
+	case "enter", "e":
+		m.initPhonebookForm(true)
+	case "d":
+		m.deletePhonebookEntry()
+	case "r":
+		m.errorMsg = ""
+		m.successMsg = ""
+		m.loadPhonebook()
+	case "esc":
+		m.errorMsg = ""
+		m.successMsg = ""
+		m.currentScreen = provisioningScreen
+	case "q", "ctrl+c":
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// phonebookHelp returns the footer help of the phonebook screen
+func (m model) phonebookHelp() string {
+	if m.inputMode {
+		return "Enter: Next Field / Save • ESC: Cancel"
+	}
+	return "↑/↓: Navigate • a: Add Contact • e/Enter: Edit Contact • d: Delete Contact • r: Refresh • ESC: Back to Provisioning • q: Quit"
+}
+
+// renderPhonebook renders the directory, its URLs and the contact form
+func (m model) renderPhonebook() string {
+	content := infoStyle.Render("📒 Company Phonebook") + "\n\n"
+	if m.inputMode {
+		for i, field := range m.inputFields {
+			cursor := "  "
+			fieldStyle := lipgloss.NewStyle()
+			if i == m.inputCursor {
+				cursor = "▶ "
+				fieldStyle = selectedItemStyle
+			}
+			value := m.inputValues[i]
+			if value == "" {
+				value = helpStyle.Render("<empty>")
+			}
+			content += fmt.Sprintf("%s%s: %s\n", cursor, fieldStyle.Render(field), value)
+		}
+		return menuStyle.Render(content)
+	}
+
+	settings := m.provisioningSettings()
+	display := settings
+	display.Username = "" // Keep the password off the screen
+	content += fmt.Sprintf("GrandStream:  %s\n", PhonebookURL(display, "phonebook.xml"))
+	content += fmt.Sprintf("Yealink:      %s\n", PhonebookURL(display, "yealink.xml"))
+	content += fmt.Sprintf("Cisco:        %s\n", PhonebookURL(display, "cisco.xml"))
+	if host, port, ok := settings.PhonebookLDAPAddress(); ok {
+		content += fmt.Sprintf("LDAP:         ldap://%s (base %s)\n", net.JoinHostPort(host, port), settings.PhonebookBase())
+	} else {
+		content += helpStyle.Render("LDAP:         off (set PROVISIONING_PHONEBOOK_LDAP to enable)") + "\n"
+	}
+	if m.db == nil {
+		content += warningStyle.Render("⚠️  Database not available: extensions are not listed") + "\n"
+	}
+	content += "\n"
+
+	if len(m.phonebookEntries) == 0 {
+		return menuStyle.Render(content + "📭 The phonebook is empty - press a to add a contact\n")
+	}
+	content += helpStyle.Render(fmt.Sprintf("  %-24s  %-14s  %-14s  %-16s  %s", "Name", "Number", "Mobile", "Company", "Group")) + "\n"
+	start := 0
+	if m.phonebookCursor >= 20 {
+		start = m.phonebookCursor - 19
+	}
+	for i := start; i < len(m.phonebookEntries) && i < start+20; i++ {
+		e := m.phonebookEntries[i]
+		line := fmt.Sprintf("%-24s  %-14s  %-14s  %-16s  %s", truncateText(e.Name, 24), e.Number, e.Mobile, truncateText(e.Company, 16), e.Group)
+		if i == m.phonebookCursor {
+			content += "▶ " + selectedItemStyle.Render(line) + "\n"
+		} else {
+			content += "  " + line + "\n"
+		}
+	}
+	content += "\n" + helpStyle.Render(fmt.Sprintf("%d entries • extensions are read-only here", len(m.phonebookEntries))) + "\n"
+	return menuStyle.Render(content)
+}
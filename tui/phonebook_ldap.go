@@ -0,0 +1,473 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// LDAP protocol tags and result codes used by the read-only phonebook server (RFC 4511)
+const (
+	berSequence    = 0x30
+	berSet         = 0x31
+	berInteger     = 0x02
+	berOctetString = 0x04
+	berEnumerated  = 0x0a
+
+	ldapBindRequest       = 0x60
+	ldapBindResponse      = 0x61
+	ldapUnbindRequest     = 0x42
+	ldapSearchRequest     = 0x63
+	ldapSearchResultEntry = 0x64
+	ldapSearchResultDone  = 0x65
+	ldapAbandonRequest    = 0x50
+	ldapExtendedRequest   = 0x77
+	ldapExtendedResponse  = 0x78
+
+	ldapFilterAnd        = 0xa0
+	ldapFilterOr         = 0xa1
+	ldapFilterNot        = 0xa2
+	ldapFilterEquality   = 0xa3
+	ldapFilterSubstrings = 0xa4
+	ldapFilterGreater    = 0xa5
+	ldapFilterLess       = 0xa6
+	ldapFilterPresent    = 0x87
+	ldapFilterApprox     = 0xa8
+
+	ldapSuccess                = 0
+	ldapSizeLimitExceeded      = 4
+	ldapNoSuchObject           = 32
+	ldapInvalidCredentials     = 49
+	ldapInsufficientAccess     = 50
+	ldapUnwillingToPerform     = 53
+	ldapProtocolError          = 2
+	ldapScopeBaseObject        = 0
+	ldapMaxMessageSize         = 64 * 1024
+	ldapIdleTimeout            = 5 * time.Minute
+	ldapDefaultSearchSizeLimit = 500
+	ldapMaxFilterDepth         = 32
+)
+
+// berElement is one decoded BER element
+type berElement struct {
+	tag  byte
+	data []byte
+}
+
+// readBER reads one BER element from r
+func readBER(r *bufio.Reader) (berElement, error) {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return berElement{}, err
+	}
+	length, err := berLength(r.ReadByte)
+	if err != nil {
+		return berElement{}, err
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return berElement{}, err
+	}
+	return berElement{tag: tag, data: data}, nil
+}
+
+// berLength decodes a definite BER length from the bytes returned by next
+func berLength(next func() (byte, error)) (int, error) {
+	first, err := next()
+	if err != nil {
+		return 0, err
+	}
+	length := int(first)
+	if first&0x80 != 0 {
+		n := int(first & 0x7f)
+		if n == 0 || n > 3 {
+			return 0, fmt.Errorf("unsupported BER length")
+		}
+		length = 0
+		for i := 0; i < n; i++ {
+			b, err := next()
+			if err != nil {
+				return 0, err
+			}
+			length = length<<8 | int(b)
+		}
+	}
+	if length > ldapMaxMessageSize {
+		return 0, fmt.Errorf("LDAP message too large")
+	}
+	return length, nil
+}
+
+// parseBER splits the content of a constructed element into its children, which share data
+func parseBER(data []byte) ([]berElement, error) {
+	var elements []berElement
+	pos := 0
+	next := func() (byte, error) {
+		if pos >= len(data) {
+			return 0, io.ErrUnexpectedEOF
+		}
+		pos++
+		return data[pos-1], nil
+	}
+	for pos < len(data) {
+		tag := data[pos]
+		pos++
+		length, err := berLength(next)
+		if err != nil {
+			return nil, fmt.Errorf("malformed BER: %v", err)
+		}
+		if length > len(data)-pos {
+			return nil, fmt.Errorf("malformed BER: %v", io.ErrUnexpectedEOF)
+		}
+		elements = append(elements, berElement{tag: tag, data: data[pos : pos+length]})
+		pos += length
+	}
+	return elements, nil
+}
+
+// berInt decodes a BER integer
+func berInt(data []byte) int {
+	n := 0
+	for i, b := range data {
+		if i == 0 && b&0x80 != 0 {
+			n = -1
+		}
+		n = n<<8 | int(b)
+	}
+	return n
+}
+
+// berEncode encodes one BER element
+func berEncode(tag byte, content []byte) []byte {
+	out := []byte{tag}
+	switch n := len(content); {
+	case n < 0x80:
+		out = append(out, byte(n))
+	case n < 0x100:
+		out = append(out, 0x81, byte(n))
+	case n < 0x10000:
+		out = append(out, 0x82, byte(n>>8), byte(n))
+	default:
+		out = append(out, 0x83, byte(n>>16), byte(n>>8), byte(n))
+	}
+	return append(out, content...)
+}
+
+// berEncodeInt encodes a non-negative integer with the given tag
+func berEncodeInt(tag byte, n int) []byte {
+	content := []byte{byte(n)}
+	for n >>= 8; n > 0; n >>= 8 {
+		content = append([]byte{byte(n)}, content...)
+	}
+	if content[0]&0x80 != 0 {
+		content = append([]byte{0}, content...)
+	}
+	return berEncode(tag, content)
+}
+
+// berEncodeSeq encodes a constructed element from encoded children
+func berEncodeSeq(tag byte, children ...[]byte) []byte {
+	var content []byte
+	for _, child := range children {
+		content = append(content, child...)
+	}
+	return berEncode(tag, content)
+}
+
+// ldapMessage wraps a protocol operation in an LDAPMessage
+func ldapMessage(id int, op []byte) []byte {
+	return berEncodeSeq(berSequence, berEncodeInt(berInteger, id), op)
+}
+
+// ldapResult encodes an LDAPResult operation with the given tag
+func ldapResult(tag byte, code int, message string) []byte {
+	return berEncodeSeq(tag, berEncodeInt(berEnumerated, code), berEncode(berOctetString, nil), berEncode(berOctetString, []byte(message)))
+}
+
+// ldapEscapeDN escapes a value for use in a DN
+func ldapEscapeDN(value string) string {
+	var b strings.Builder
+	for i, c := range value {
+		if strings.ContainsRune(`,+"\<>;=`, c) || (i == 0 && (c == '#' || c == ' ')) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// PhonebookLDAPEntry returns the DN and attributes of a directory entry
+func PhonebookLDAPEntry(e PhonebookEntry, baseDN string) (string, map[string][]string) {
+	attrs := map[string][]string{
+		"objectclass":     {"top", "person", "organizationalPerson", "inetOrgPerson"},
+		"cn":              {e.Name},
+		"sn":              {e.LastName()},
+		"telephonenumber": {e.Number},
+	}
+	if first, last := splitPhonebookName(e.Name); last != "" {
+		attrs["givenname"] = []string{first}
+	}
+	if e.Mobile != "" {
+		attrs["mobile"] = []string{e.Mobile}
+	}
+	if e.Company != "" {
+		attrs["o"] = []string{e.Company}
+	}
+	if e.Group != "" {
+		attrs["ou"] = []string{e.Group}
+	}
+	return "cn=" + ldapEscapeDN(e.Name) + "," + baseDN, attrs
+}
+
+// ldapAttributeNames are the attributes sent to phones, in order
+var ldapAttributeNames = []string{"objectClass", "cn", "sn", "givenName", "telephoneNumber", "mobile", "o", "ou"}
+
+// ldapFilterMatch evaluates a search filter against an entry's attributes. depth is the
+// nesting level of filter and is limited to ldapMaxFilterDepth.
+func ldapFilterMatch(filter berElement, attrs map[string][]string, depth int) (bool, error) {
+	if depth > ldapMaxFilterDepth {
+		return false, fmt.Errorf("filter nested too deeply")
+	}
+	switch filter.tag {
+	case ldapFilterAnd, ldapFilterOr:
+		children, err := parseBER(filter.data)
+		if err != nil {
+			return false, err
+		}
+		for _, child := range children {
+			ok, err := ldapFilterMatch(child, attrs, depth+1)
+			if err != nil {
+				return false, err
+			}
+			if filter.tag == ldapFilterOr && ok {
+				return true, nil
+			}
+			if filter.tag == ldapFilterAnd && !ok {
+				return false, nil
+			}
+		}
+		return filter.tag == ldapFilterAnd, nil
+	case ldapFilterNot:
+		children, err := parseBER(filter.data)
+		if err != nil || len(children) != 1 {
+			return false, fmt.Errorf("malformed not filter")
+		}
+		ok, err := ldapFilterMatch(children[0], attrs, depth+1)
+		return !ok, err
+	case ldapFilterPresent:
+		return len(attrs[strings.ToLower(string(filter.data))]) > 0, nil
+	case ldapFilterEquality, ldapFilterApprox, ldapFilterGreater, ldapFilterLess:
+		parts, err := parseBER(filter.data)
+		if err != nil || len(parts) != 2 {
+			return false, fmt.Errorf("malformed attribute filter")
+		}
+		want := strings.ToLower(string(parts[1].data))
+		for _, value := range attrs[strings.ToLower(string(parts[0].data))] {
+			value = strings.ToLower(value)
+			switch {
+			case filter.tag == ldapFilterGreater && value >= want,
+				filter.tag == ldapFilterLess && value <= want,
+				(filter.tag == ldapFilterEquality || filter.tag == ldapFilterApprox) && value == want:
+				return true, nil
+			}
+		}
+		return false, nil
+	case ldapFilterSubstrings:
+		parts, err := parseBER(filter.data)
+		if err != nil || len(parts) != 2 {
+			return false, fmt.Errorf("malformed substrings filter")
+		}
+		subs, err := parseBER(parts[1].data)
+		if err != nil {
+			return false, err
+		}
+		for _, value := range attrs[strings.ToLower(string(parts[0].data))] {
+			if ldapSubstringsMatch(strings.ToLower(value), subs) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	// Extensible matches are not supported and match nothing
+	return false, nil
+}
+
+// ldapSubstringsMatch matches a value against initial, any and final substrings
+func ldapSubstringsMatch(value string, subs []berElement) bool {
+	for _, sub := range subs {
+		part := strings.ToLower(string(sub.data))
+		switch sub.tag {
+		case 0x80: // initial
+			if !strings.HasPrefix(value, part) {
+				return false
+			}
+			value = value[len(part):]
+		case 0x81: // any
+			i := strings.Index(value, part)
+			if i < 0 {
+				return false
+			}
+			value = value[i+len(part):]
+		case 0x82: // final
+			if !strings.HasSuffix(value, part) {
+				return false
+			}
+			value = ""
+		}
+	}
+	return true
+}
+
+// phonebookLDAPConn is one LDAP client connection
+type phonebookLDAPConn struct {
+	conn     net.Conn
+	source   PhonebookSource
+	baseDN   string
+	settings ProvisioningSettings // Bind credentials
+	bound    bool
+}
+
+// servePhonebookLDAP accepts LDAP connections until the listener is closed
+func servePhonebookLDAP(listener net.Listener, source PhonebookSource, settings ProvisioningSettings) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		c := &phonebookLDAPConn{conn: conn, source: source, baseDN: settings.PhonebookBase(),
+			settings: settings, bound: settings.Username == ""}
+		go c.serve()
+	}
+}
+
+// serve answers requests until the client unbinds, errs or goes idle
+func (c *phonebookLDAPConn) serve() {
+	defer c.conn.Close()
+	r := bufio.NewReader(c.conn)
+	for {
+		c.conn.SetDeadline(time.Now().Add(ldapIdleTimeout))
+		message, err := readBER(r)
+		if err != nil || message.tag != berSequence {
+			return
+		}
+		parts, err := parseBER(message.data)
+		if err != nil || len(parts) < 2 || parts[0].tag != berInteger {
+			return
+		}
+		id, op := berInt(parts[0].data), parts[1]
+		var responses [][]byte
+		switch op.tag {
+		case ldapBindRequest:
+			responses = [][]byte{ldapMessage(id, c.bind(op))}
+		case ldapSearchRequest:
+			responses = c.search(id, op)
+		case ldapExtendedRequest:
+			responses = [][]byte{ldapMessage(id, ldapResult(ldapExtendedResponse, ldapProtocolError, "extended operations are not supported"))}
+		case ldapAbandonRequest:
+			continue
+		default:
+			return // Unbind and write operations end the connection
+		}
+		for _, response := range responses {
+			if _, err := c.conn.Write(response); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// bind checks simple bind credentials. Without provisioning credentials every bind succeeds.
+func (c *phonebookLDAPConn) bind(op berElement) []byte {
+	parts, err := parseBER(op.data)
+	if err != nil || len(parts) < 3 {
+		return ldapResult(ldapBindResponse, ldapProtocolError, "malformed bind request")
+	}
+	if c.settings.Username == "" {
+		c.bound = true
+		return ldapResult(ldapBindResponse, ldapSuccess, "")
+	}
+	// Phones send either the bare username or its DN under the phonebook base
+	name := string(parts[1].data)
+	if strings.EqualFold(name, "cn="+ldapEscapeDN(c.settings.Username)+","+c.baseDN) {
+		name = c.settings.Username
+	}
+	if parts[2].tag == 0x80 && c.settings.CheckCredentials(name, string(parts[2].data)) {
+		c.bound = true
+		return ldapResult(ldapBindResponse, ldapSuccess, "")
+	}
+	c.bound = false
+	return ldapResult(ldapBindResponse, ldapInvalidCredentials, "invalid credentials")
+}
+
+// search returns the matching entries followed by the search result
+func (c *phonebookLDAPConn) search(id int, op berElement) [][]byte {
+	done := func(code int, message string) []byte {
+		return ldapMessage(id, ldapResult(ldapSearchResultDone, code, message))
+	}
+	parts, err := parseBER(op.data)
+	if err != nil || len(parts) < 8 {
+		return [][]byte{done(ldapProtocolError, "malformed search request")}
+	}
+	if !c.bound {
+		return [][]byte{done(ldapInsufficientAccess, "bind required")}
+	}
+	base := strings.ToLower(strings.ReplaceAll(string(parts[0].data), " ", ""))
+	baseDN := strings.ToLower(strings.ReplaceAll(c.baseDN, " ", ""))
+	if base != "" && base != baseDN && !strings.HasSuffix(base, ","+baseDN) {
+		return [][]byte{done(ldapNoSuchObject, "")}
+	}
+	scope, sizeLimit, typesOnly := berInt(parts[1].data), berInt(parts[3].data), berInt(parts[5].data) != 0
+	if sizeLimit <= 0 || sizeLimit > ldapDefaultSearchSizeLimit {
+		sizeLimit = ldapDefaultSearchSizeLimit
+	}
+	requested := make(map[string]bool)
+	if attributes, err := parseBER(parts[7].data); err == nil {
+		for _, attr := range attributes {
+			requested[strings.ToLower(string(attr.data))] = true
+		}
+	}
+	all := len(requested) == 0 || requested["*"]
+
+	entries, err := c.source()
+	if err != nil {
+		return [][]byte{done(ldapUnwillingToPerform, "phonebook unavailable")}
+	}
+	var responses [][]byte
+	for _, e := range entries {
+		dn, attrs := PhonebookLDAPEntry(e, c.baseDN)
+		if scope == ldapScopeBaseObject && strings.ToLower(strings.ReplaceAll(dn, " ", "")) != base {
+			continue
+		}
+		ok, err := ldapFilterMatch(parts[6], attrs, 0)
+		if err != nil {
+			return [][]byte{done(ldapProtocolError, err.Error())}
+		}
+		if !ok {
+			continue
+		}
+		if len(responses) == sizeLimit {
+			return append(responses, done(ldapSizeLimitExceeded, ""))
+		}
+		var encoded [][]byte
+		for _, name := range ldapAttributeNames {
+			key := strings.ToLower(name)
+			values, ok := attrs[key]
+			if !ok || (!all && !requested[key]) {
+				continue
+			}
+			var vals [][]byte
+			if !typesOnly {
+				for _, value := range values {
+					vals = append(vals, berEncode(berOctetString, []byte(value)))
+				}
+			}
+			encoded = append(encoded, berEncodeSeq(berSequence, berEncode(berOctetString, []byte(name)), berEncodeSeq(berSet, vals...)))
+		}
+		entry := berEncodeSeq(ldapSearchResultEntry, berEncode(berOctetString, []byte(dn)), berEncodeSeq(berSequence, encoded...))
+		responses = append(responses, ldapMessage(id, entry))
+	}
+	return append(responses, done(ldapSuccess, ""))
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func testPhonebook() []PhonebookEntry {
+	extensions := []Extension{
+		{ExtensionNumber: "101", Name: "Front Desk", Enabled: true},
+		{ExtensionNumber: "102", Name: "", Enabled: true},
+		{ExtensionNumber: "103", Name: "Disabled", Enabled: false},
+	}
+	contacts := []PhonebookEntry{{Name: "Ali Rezaei", Number: "+982112345678", Mobile: "09121234567", Company: "Acme & Co"}}
+	return BuildPhonebook(extensions, contacts)
+}
+
+func TestBuildPhonebook(t *testing.T) {
+	entries := testPhonebook()
+	if len(entries) != 3 {
+		t.Fatalf("expected the enabled extensions and the contact, got %+v", entries)
+	}
+	if entries[0].Number != "102" || entries[0].Name != "102" || !entries[0].Extension {
+		t.Errorf("expected an unnamed extension to use its number, got %+v", entries[0])
+	}
+	if entries[1].Name != "Ali Rezaei" || entries[1].Group != phonebookGroupContacts || entries[1].Extension {
+		t.Errorf("expected the contact in the default group, got %+v", entries[1])
+	}
+	if got := FilterPhonebook(entries, "acme"); len(got) != 1 || got[0].Name != "Ali Rezaei" {
+		t.Errorf("expected the search to match the company, got %+v", got)
+	}
+}
+
+func TestPhonebookContacts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "phonebook.json")
+	if _, err := SavePhonebookContact(path, "", PhonebookEntry{Name: "Bad", Number: "12-34"}); err == nil {
+		t.Error("expected an invalid number to be rejected")
+	}
+	SavePhonebookContact(path, "", PhonebookEntry{Name: "Zed", Number: "200"})
+	SavePhonebookContact(path, "", PhonebookEntry{Name: "Amir", Number: "300"})
+	contacts, err := SavePhonebookContact(path, "zed", PhonebookEntry{Name: "Zed Office", Number: "201"})
+	if err != nil || len(contacts) != 2 || contacts[1].Name != "Zed Office" {
+		t.Fatalf("expected the renamed contact to replace the old one, got %+v %v", contacts, err)
+	}
+	if contacts, _ = DeletePhonebookContact(path, "AMIR"); len(contacts) != 1 {
+		t.Errorf("expected the contact to be deleted, got %+v", contacts)
+	}
+	if _, err := DeletePhonebookContact(path, "Nobody"); err == nil {
+		t.Error("expected an error for a missing contact")
+	}
+	if loaded, err := LoadPhonebookContacts(path); err != nil || len(loaded) != 1 || loaded[0].Number != "201" {
+		t.Errorf("unexpected saved contacts %+v %v", loaded, err)
+	}
+}
+
+func TestRenderPhonebook(t *testing.T) {
+	entries := testPhonebook()
+	gs, err := RenderPhonebook(PhonebookGrandStream, entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"<AddressBook>", "<name>Contacts</name>", "<FirstName>Ali</FirstName>", "<LastName>Rezaei</LastName>",
+		`<Phone type="Cell">`, "<phonenumber>09121234567</phonenumber>", "Acme &amp; Co"} {
+		if !strings.Contains(string(gs), want) {
+			t.Errorf("expected %q in the GrandStream phonebook:\n%s", want, gs)
+		}
+	}
+	yealink, _ := RenderPhonebook(PhonebookYealink, entries)
+	if !strings.Contains(string(yealink), "<YealinkIPPhoneDirectory>") || !strings.Contains(string(yealink), "<Name>Front Desk</Name>") {
+		t.Errorf("unexpected Yealink phonebook:\n%s", yealink)
+	}
+	many := make([]PhonebookEntry, 40)
+	for i := range many {
+		many[i] = PhonebookEntry{Name: "Contact", Number: "100"}
+	}
+	cisco, _ := RenderPhonebook(PhonebookCisco, many)
+	if strings.Count(string(cisco), "<DirectoryEntry>") != ciscoDirectoryMaxEntries || !strings.Contains(string(cisco), "First 32 of 40") {
+		t.Errorf("expected the Cisco directory to be capped, got:\n%s", cisco)
+	}
+	if _, err := RenderPhonebook("polycom", entries); err == nil {
+		t.Error("expected an unknown format to be rejected")
+	}
+}
+
+func TestPhonebookProvisioningParams(t *testing.T) {
+	settings := ProvisioningSettings{HTTPAddr: ":8088", Host: "10.0.0.5", Username: "prov", Password: "pw", Phonebook: true,
+		PhonebookLDAPAddr: ":3389", SIPServer: "10.0.0.5"}
+	phone := &ProvisionedPhone{Lines: []ProvisionedLine{{Line: 1, Extension: Extension{ExtensionNumber: "101"}}}}
+
+	gs, _ := ProvisioningParams("grandstream", phone, settings)
+	if gs[gsPPhonebookDownload] != "1" || gs[gsPPhonebookPath] != "prov:pw@10.0.0.5:8088/phonebook" {
+		t.Errorf("expected GrandStream to download the phonebook, got %v %v", gs[gsPPhonebookDownload], gs[gsPPhonebookPath])
+	}
+	yealink, _ := ProvisioningParams("yealink", phone, settings)
+	if yealink["remote_phonebook.data.1.url"] != "http://prov:pw@10.0.0.5:8088/phonebook/yealink.xml" ||
+		yealink["ldap.host"] != "10.0.0.5" || yealink["ldap.port"] != "3389" || yealink["ldap.base"] != DefaultPhonebookBaseDN {
+		t.Errorf("expected the Yealink remote phonebook and LDAP settings, got %v", yealink)
+	}
+	snom, _ := ProvisioningParams("snom", phone, settings)
+	if snom["ldap_server"] != "10.0.0.5" || snom["ldap_username"] != "prov" {
+		t.Errorf("expected the Snom LDAP settings, got %v", snom)
+	}
+
+	settings.Phonebook = false
+	if yealink, _ := ProvisioningParams("yealink", phone, settings); yealink["remote_phonebook.data.1.url"] != "" {
+		t.Error("expected no phonebook settings when disabled")
+	}
+}
+
+func TestServePhonebook(t *testing.T) {
+	server := newTestProvisioningServer(t, ProvisioningSettings{})
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+	if rec := get("/phonebook/phonebook.xml"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 without a phonebook source, got %d", rec.Code)
+	}
+
+	server.SetPhonebook(func() ([]PhonebookEntry, error) { return testPhonebook(), nil })
+	rec := get("/phonebook/yealink.xml?q=front")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Front Desk") || strings.Contains(rec.Body.String(), "Ali") {
+		t.Errorf("expected the filtered Yealink phonebook, got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := get("/phonebook/other.xml"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown phonebook file, got %d", rec.Code)
+	}
+	if log := server.Log(); len(log) != 3 || log[1].Vendor != PhonebookYealink || log[1].Status != http.StatusOK {
+		t.Errorf("expected the phonebook requests to be logged, got %+v", log)
+	}
+}
+
+// ldapExchange sends one LDAP request and reads responses until a final result
+func ldapExchange(t *testing.T, conn net.Conn, r *bufio.Reader, request []byte, final byte) []berElement {
+	t.Helper()
+	if _, err := conn.Write(request); err != nil {
+		t.Fatal(err)
+	}
+	var ops []berElement
+	for {
+		message, err := readBER(r)
+		if err != nil {
+			t.Fatalf("failed to read LDAP response: %v", err)
+		}
+		parts, err := parseBER(message.data)
+		if err != nil || len(parts) != 2 {
+			t.Fatalf("malformed LDAP response: %v", err)
+		}
+		ops = append(ops, parts[1])
+		if parts[1].tag == final {
+			return ops
+		}
+	}
+}
+
+// ldapResultCode returns the result code of an LDAPResult operation
+func ldapResultCode(t *testing.T, op berElement) int {
+	t.Helper()
+	parts, err := parseBER(op.data)
+	if err != nil || len(parts) == 0 {
+		t.Fatalf("malformed LDAP result: %v", err)
+	}
+	return berInt(parts[0].data)
+}
+
+func TestPhonebookLDAP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go servePhonebookLDAP(listener, func() ([]PhonebookEntry, error) { return testPhonebook(), nil },
+		ProvisioningSettings{Username: "prov", Password: "pw"})
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(conn)
+
+	// (|(cn=*rez*)(telephoneNumber=101)) asking for cn and telephoneNumber only
+	filter := berEncodeSeq(ldapFilterOr,
+		berEncodeSeq(ldapFilterSubstrings, berEncode(berOctetString, []byte("cn")), berEncodeSeq(berSequence, berEncode(0x81, []byte("REZ")))),
+		berEncodeSeq(ldapFilterEquality, berEncode(berOctetString, []byte("telephoneNumber")), berEncode(berOctetString, []byte("101"))))
+	search := ldapMessage(2, berEncodeSeq(ldapSearchRequest, berEncode(berOctetString, []byte(DefaultPhonebookBaseDN)),
+		berEncodeInt(berEnumerated, 2), berEncodeInt(berEnumerated, 0), berEncodeInt(berInteger, 0), berEncodeInt(berInteger, 0),
+		berEncode(0x01, []byte{0}), filter,
+		berEncodeSeq(berSequence, berEncode(berOctetString, []byte("cn")), berEncode(berOctetString, []byte("telephoneNumber")))))
+
+	ops := ldapExchange(t, conn, r, search, ldapSearchResultDone)
+	if len(ops) != 1 || ldapResultCode(t, ops[0]) != ldapInsufficientAccess {
+		t.Fatalf("expected searches to need a bind, got %+v", ops)
+	}
+	bind := func(password string) int {
+		request := ldapMessage(1, berEncodeSeq(ldapBindRequest, berEncodeInt(berInteger, 3),
+			berEncode(berOctetString, []byte("cn=prov,dc=rayanpbx")), berEncode(0x80, []byte(password))))
+		return ldapResultCode(t, ldapExchange(t, conn, r, request, ldapBindResponse)[0])
+	}
+	if code := bind("wrong"); code != ldapInvalidCredentials {
+		t.Fatalf("expected a wrong password to be rejected, got %d", code)
+	}
+	if code := bind("pw"); code != ldapSuccess {
+		t.Fatalf("expected the bind to succeed, got %d", code)
+	}
+
+	ops = ldapExchange(t, conn, r, search, ldapSearchResultDone)
+	if len(ops) != 3 || ldapResultCode(t, ops[2]) != ldapSuccess {
+		t.Fatalf("expected two entries and a result, got %d ops", len(ops))
+	}
+	var dns []string
+	for _, op := range ops[:2] {
+		parts, _ := parseBER(op.data)
+		dns = append(dns, string(parts[0].data))
+		if attrs, _ := parseBER(parts[1].data); len(attrs) != 2 {
+			t.Errorf("expected only the requested attributes, got %d", len(attrs))
+		}
+	}
+	if strings.Join(dns, ";") != "cn=Ali Rezaei,dc=rayanpbx;cn=Front Desk,dc=rayanpbx" {
+		t.Errorf("unexpected entries %v", dns)
+	}
+}
+
+func TestLDAPFilterDepth(t *testing.T) {
+	attrs := map[string][]string{"cn": {"Front Desk"}}
+	nested := func(depth int) berElement {
+		filter := berEncode(ldapFilterPresent, []byte("cn"))
+		for i := 0; i < depth; i++ {
+			filter = berEncodeSeq(ldapFilterNot, filter)
+		}
+		elements, err := parseBER(filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return elements[0]
+	}
+	if ok, err := ldapFilterMatch(nested(ldapMaxFilterDepth), attrs, 0); err != nil || !ok {
+		t.Errorf("expected a filter at the depth limit to match, got %v %v", ok, err)
+	}
+	if _, err := ldapFilterMatch(nested(ldapMaxFilterDepth+1), attrs, 0); err == nil {
+		t.Error("expected a filter nested past the limit to be rejected")
+	}
+	if _, err := parseBER([]byte{berOctetString, 0x05, 'a'}); err == nil {
+		t.Error("expected a truncated element to be rejected")
+	}
+}
+
+func TestPhonebookScreen(t *testing.T) {
+	t.Setenv("RAYANPBX_PHONEBOOK", filepath.Join(t.TempDir(), "phonebook.json"))
+	m := initialModel(nil, nil, false)
+	m.currentScreen = provisioningScreen
+	m.handleProvisioningScreen(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'p'}})
+	if m.currentScreen != phonebookScreen || !strings.Contains(m.renderPhonebook(), "phonebook is empty") {
+		t.Fatalf("expected an empty phonebook screen, got screen %d", m.currentScreen)
+	}
+
+	m.handlePhonebookScreen(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'a'}})
+	copy(m.inputValues, []string{"Support Line", "+982100000", "", "Vendor", ""})
+	m.savePhonebookForm()
+	if m.inputMode || len(m.phonebookEntries) != 1 || m.phonebookEntries[0].Group != phonebookGroupContacts {
+		t.Fatalf("expected the contact to be saved, got %q %+v", m.errorMsg, m.phonebookEntries)
+	}
+
+	m.handlePhonebookScreen(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'e'}})
+	m.inputValues[phonebookFieldNumber] = "not a number"
+	m.savePhonebookForm()
+	if !m.inputMode || !strings.Contains(m.errorMsg, "invalid number") {
+		t.Errorf("expected an invalid number to keep the form open, got %q", m.errorMsg)
+	}
+	m.inputMode = false
+
+	m.phonebookEntries = append(m.phonebookEntries, PhonebookEntry{Name: "Front Desk", Number: "101", Extension: true})
+	m.phonebookCursor = 1
+	m.handlePhonebookScreen(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'d'}})
+	if !strings.Contains(m.errorMsg, "extension directory") {
+		t.Errorf("expected extensions to be read-only, got %q", m.errorMsg)
+	}
+	m.phonebookCursor = 0
+	m.handlePhonebookScreen(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'d'}})
+	if len(m.phonebookEntries) != 0 {
+		t.Errorf("expected the contact to be deleted, got %+v", m.phonebookEntries)
+	}
+	m.handlePhonebookScreen(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.currentScreen != provisioningScreen {
+		t.Error("expected ESC to return to the provisioning screen")
+	}
+}
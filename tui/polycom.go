@@ -0,0 +1,347 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Polycom VVX REST API endpoints (UC Software 5.x+, "REST API" enabled on the phone)
+const (
+	pcDeviceInfoPath   = "/api/v1/mgmt/device/info"
+	pcLineInfoPath     = "/api/v1/mgmt/lineInfo"
+	pcRestartPath      = "/api/v1/mgmt/safeRestart"
+	pcFactoryResetPath = "/api/v1/mgmt/factoryReset"
+	pcConfigGetPath    = "/api/v1/mgmt/config/get"
+	pcConfigSetPath    = "/api/v1/mgmt/config/set"
+	pcCallStatusPath   = "/api/v2/webCallControl/callStatus"
+	pcCallCtrlPath     = "/api/v1/callctrl/"
+	pcStatusSuccess    = "2000"
+	pcDNDParam         = "feature.doNotDisturb.enable"
+)
+
+// polycomConfigParams are read by GetConfig, since the API only returns named parameters
+var polycomConfigParams = []string{
+	"reg.1.address", "reg.1.label", "reg.1.displayName", "reg.1.server.1.address", "reg.1.server.1.port",
+	"reg.2.address", "reg.2.label", "reg.2.displayName", "reg.2.server.1.address", "reg.2.server.1.port",
+	"device.sntp.serverName", "tcpIpApp.sntp.gmtOffset", pcDNDParam,
+}
+
+// PolycomPhone implements VoIPPhone for Polycom VVX devices
+type PolycomPhone struct {
+	phoneWebClient
+}
+
+// NewPolycomPhone creates a new Polycom phone instance
+func NewPolycomPhone(ip string, credentials map[string]string, httpClient *http.Client) *PolycomPhone {
+	return &PolycomPhone{
+		phoneWebClient: newPhoneWebClient(ip, credentials, httpClient, "enable the REST API under Settings > Applications"),
+	}
+}
+
+// polycomResponse is the envelope of every Polycom REST reply
+type polycomResponse struct {
+	Status string      `json:"Status"`
+	Data   interface{} `json:"data"`
+}
+
+// call sends a REST request and decodes the data field into out
+func (pp *PolycomPhone) call(method, path string, in, out interface{}) error {
+	resp := polycomResponse{Data: out}
+	if err := pp.doJSON(method, path, in, &resp); err != nil {
+		return err
+	}
+	if resp.Status != "" && resp.Status != pcStatusSuccess {
+		return fmt.Errorf("phone returned status %s", resp.Status)
+	}
+	return nil
+}
+
+// GetStatus retrieves the phone status
+func (pp *PolycomPhone) GetStatus() (*PhoneStatus, error) {
+	var info struct {
+		ModelNumber     string `json:"ModelNumber"`
+		FirmwareRelease string `json:"FirmwareRelease"`
+		MACAddress      string `json:"MACAddress"`
+		IPV4Address     string `json:"IPV4Address"`
+		UpTime          string `json:"UpTimeSinceLastReboot"`
+	}
+	if err := pp.call("GET", pcDeviceInfoPath, nil, &info); err != nil {
+		return nil, fmt.Errorf("failed to get status: %w", err)
+	}
+	var lines []struct {
+		LineNumber         string `json:"LineNumber"`
+		UserID             string `json:"UserID"`
+		RegistrationStatus string `json:"RegistrationStatus"`
+		ProxyAddress       string `json:"ProxyAddress"`
+		Label              string `json:"Label"`
+	}
+	if err := pp.call("GET", pcLineInfoPath, nil, &lines); err != nil {
+		return nil, fmt.Errorf("failed to get line info: %w", err)
+	}
+
+	status := &PhoneStatus{
+		IP:         pp.ip,
+		MAC:        info.MACAddress,
+		Model:      info.ModelNumber,
+		Firmware:   info.FirmwareRelease,
+		Vendor:     "Polycom",
+		Uptime:     info.UpTime,
+		LastUpdate: time.Now(),
+	}
+	if info.IPV4Address != "" {
+		status.NetworkInfo = &NetworkInfo{IP: info.IPV4Address, MAC: info.MACAddress}
+	}
+	for _, line := range lines {
+		number, _ := strconv.Atoi(line.LineNumber)
+		status.Accounts = append(status.Accounts, Account{
+			Number:      number,
+			Extension:   line.UserID,
+			Status:      line.RegistrationStatus,
+			Server:      line.ProxyAddress,
+			DisplayName: line.Label,
+		})
+		if strings.EqualFold(line.RegistrationStatus, "registered") {
+			status.Registered = true
+		}
+	}
+	return status, nil
+}
+
+// Reboot reboots the phone once it is idle
+func (pp *PolycomPhone) Reboot() error {
+	if err := pp.call("POST", pcRestartPath, nil, nil); err != nil {
+		return fmt.Errorf("failed to reboot phone: %w", err)
+	}
+	return nil
+}
+
+// FactoryReset performs a factory reset
+func (pp *PolycomPhone) FactoryReset() error {
+	if err := pp.call("POST", pcFactoryResetPath, nil, nil); err != nil {
+		return fmt.Errorf("failed to factory reset phone: %w", err)
+	}
+	return nil
+}
+
+// GetConfig retrieves the commonly managed configuration parameters
+func (pp *PolycomPhone) GetConfig() (map[string]interface{}, error) {
+	values, err := pp.getParams(polycomConfigParams...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get config: %w", err)
+	}
+	config := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		config[key] = value
+	}
+	return config, nil
+}
+
+// getParams reads named configuration parameters
+func (pp *PolycomPhone) getParams(names ...string) (map[string]string, error) {
+	var raw map[string]struct {
+		Value string `json:"Value"`
+	}
+	if err := pp.call("POST", pcConfigGetPath, map[string]interface{}{"data": names}, &raw); err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(raw))
+	for key, param := range raw {
+		values[key] = param.Value
+	}
+	return values, nil
+}
+
+// SetConfig sets phone configuration parameters
+func (pp *PolycomPhone) SetConfig(config map[string]interface{}) error {
+	data := make(map[string]string, len(config))
+	for key, value := range config {
+		data[key] = fmt.Sprint(value)
+	}
+	if err := pp.call("POST", pcConfigSetPath, map[string]interface{}{"data": data}, nil); err != nil {
+		return fmt.Errorf("failed to set config: %w", err)
+	}
+	return nil
+}
+
+// PolycomLineConfig returns the registration parameters of one line
+func PolycomLineConfig(ext Extension, lineNumber int, server string) map[string]string {
+	prefix := fmt.Sprintf("reg.%d.", lineNumber)
+	config := map[string]string{
+		prefix + "address":            ext.ExtensionNumber,
+		prefix + "label":              ext.ExtensionNumber,
+		prefix + "displayName":        provisionDisplayName(ext),
+		prefix + "auth.userId":        ext.ExtensionNumber,
+		prefix + "auth.password":      ext.Secret,
+		prefix + "server.1.address":   server,
+		prefix + "server.1.port":      "5060",
+		prefix + "server.1.transport": "UDPOnly",
+	}
+	if provisionUsesTLS(ext) {
+		config[prefix+"server.1.port"] = "5061"
+		config[prefix+"server.1.transport"] = "TLS"
+	}
+	return config
+}
+
+// ProvisionExtension provisions an extension on the phone
+func (pp *PolycomPhone) ProvisionExtension(ext Extension, accountNumber int) error {
+	server := sipServerFor(pp.ip, pp.credentials)
+	if server == "" {
+		return fmt.Errorf("could not determine the SIP server address for %s", pp.ip)
+	}
+	config := make(map[string]interface{})
+	for key, value := range PolycomLineConfig(ext, accountNumber, server) {
+		config[key] = value
+	}
+	return pp.SetConfig(config)
+}
+
+// CTI Operations - Polycom callctrl API, addressed by call handle
+
+// callRef returns the handle of the first call on lineID (any line if 0) in one of the given states
+func (pp *PolycomPhone) callRef(lineID int, states ...string) (string, error) {
+	state, err := pp.GetPhoneState()
+	if err != nil {
+		return "", err
+	}
+	for _, call := range state.Calls {
+		if lineID > 0 && call.LineID != lineID {
+			continue
+		}
+		for _, s := range states {
+			if call.State == s {
+				return call.CallID, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no %s call found", strings.Join(states, "/"))
+}
+
+// callAction runs a callctrl action on the matching call
+func (pp *PolycomPhone) callAction(action string, lineID int, extra map[string]string, states ...string) error {
+	ref, err := pp.callRef(lineID, states...)
+	if err != nil {
+		return err
+	}
+	data := map[string]string{"Ref": ref}
+	for key, value := range extra {
+		data[key] = value
+	}
+	if err := pp.call("POST", pcCallCtrlPath+action, map[string]interface{}{"data": data}, nil); err != nil {
+		return fmt.Errorf("failed to %s: %w", action, err)
+	}
+	return nil
+}
+
+// AcceptCall answers an incoming call
+func (pp *PolycomPhone) AcceptCall(lineID int) error {
+	return pp.callAction("answerCall", lineID, nil, "offering", "ringing")
+}
+
+// RejectCall rejects an incoming call
+func (pp *PolycomPhone) RejectCall(lineID int) error {
+	return pp.callAction("rejectCall", lineID, nil, "offering", "ringing")
+}
+
+// EndCall terminates the current call
+func (pp *PolycomPhone) EndCall(lineID int) error {
+	return pp.callAction("endCall", lineID, nil, "connected", "hold", "dialing", "ringback")
+}
+
+// HoldCall places the call on hold
+func (pp *PolycomPhone) HoldCall(lineID int) error {
+	return pp.callAction("holdCall", lineID, nil, "connected")
+}
+
+// ResumeCall resumes a held call
+func (pp *PolycomPhone) ResumeCall(lineID int) error {
+	return pp.callAction("resumeCall", lineID, nil, "hold")
+}
+
+// Dial initiates an outgoing call
+func (pp *PolycomPhone) Dial(number string, lineID int) error {
+	if number == "" {
+		return fmt.Errorf("invalid number %q", number)
+	}
+	if lineID <= 0 {
+		lineID = 1
+	}
+	data := map[string]string{"Dest": number, "Line": strconv.Itoa(lineID), "Type": "SIP"}
+	if err := pp.call("POST", pcCallCtrlPath+"dial", map[string]interface{}{"data": data}, nil); err != nil {
+		return fmt.Errorf("failed to dial: %w", err)
+	}
+	return nil
+}
+
+// SendDTMF sends DTMF tones
+func (pp *PolycomPhone) SendDTMF(digits string, lineID int) error {
+	if _, err := dtmfKeys(digits); err != nil {
+		return err
+	}
+	return pp.callAction("sendDTMF", lineID, map[string]string{"Digits": digits}, "connected")
+}
+
+// BlindTransfer performs blind transfer
+func (pp *PolycomPhone) BlindTransfer(target string, lineID int) error {
+	if target == "" {
+		return fmt.Errorf("invalid transfer target %q", target)
+	}
+	return pp.callAction("transferCall", lineID, map[string]string{"TransferDest": target}, "connected", "hold")
+}
+
+// SetDND enables/disables Do Not Disturb
+func (pp *PolycomPhone) SetDND(enable bool) error {
+	value := "0"
+	if enable {
+		value = "1"
+	}
+	return pp.SetConfig(map[string]interface{}{pcDNDParam: value})
+}
+
+// GetPhoneState returns current phone state including call info
+func (pp *PolycomPhone) GetPhoneState() (*CTIPhoneState, error) {
+	var calls []struct {
+		CallHandle        string `json:"CallHandle"`
+		LineID            string `json:"LineID"`
+		State             string `json:"CallState"`
+		Type              string `json:"Type"`
+		RemotePartyNumber string `json:"RemotePartyNumber"`
+		RemotePartyName   string `json:"RemotePartyName"`
+		Duration          string `json:"DurationInSeconds"`
+		Muted             string `json:"Muted"`
+	}
+	if err := pp.call("GET", pcCallStatusPath, nil, &calls); err != nil {
+		return nil, fmt.Errorf("failed to get phone state: %w", err)
+	}
+	params, err := pp.getParams(pcDNDParam)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get phone state: %w", err)
+	}
+
+	state := &CTIPhoneState{DNDEnabled: params[pcDNDParam] == "1"}
+	for _, c := range calls {
+		line, _ := strconv.Atoi(c.LineID)
+		duration, _ := strconv.Atoi(c.Duration)
+		direction := "outbound"
+		if strings.EqualFold(c.Type, "incoming") {
+			direction = "inbound"
+		}
+		state.Calls = append(state.Calls, CTICallState{
+			LineID:       line,
+			CallID:       c.CallHandle,
+			State:        strings.ToLower(c.State),
+			Direction:    direction,
+			RemoteNumber: c.RemotePartyNumber,
+			RemoteName:   c.RemotePartyName,
+			Duration:     duration,
+			Muted:        c.Muted == "1",
+		})
+		if state.ActiveLine == 0 {
+			state.ActiveLine = line
+		}
+	}
+	return state, nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// fakePolycom is a minimal Polycom REST API recording callctrl and config/set requests
+type fakePolycom struct {
+	mu       sync.Mutex
+	requests []string
+	bodies   map[string]map[string]interface{}
+	calls    string
+}
+
+func newFakePolycom(t *testing.T) (*fakePolycom, *PolycomPhone) {
+	t.Helper()
+	fake := &fakePolycom{bodies: map[string]map[string]interface{}{}, calls: `[]`}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "Polycom" || pass != "456" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		fake.requests = append(fake.requests, r.URL.Path)
+		fake.bodies[r.URL.Path] = body
+
+		switch r.URL.Path {
+		case pcDeviceInfoPath:
+			w.Write([]byte(`{"Status":"2000","data":{"ModelNumber":"VVX 450","FirmwareRelease":"6.3.0.14929","MACAddress":"64167f112233","UpTimeSinceLastReboot":"0 Day 2:10:33"}}`))
+		case pcLineInfoPath:
+			w.Write([]byte(`{"Status":"2000","data":[{"LineNumber":"1","UserID":"101","RegistrationStatus":"Registered","ProxyAddress":"192.168.1.10","Label":"101"}]}`))
+		case pcCallStatusPath:
+			w.Write([]byte(`{"Status":"2000","data":` + fake.calls + `}`))
+		case pcConfigGetPath:
+			w.Write([]byte(`{"Status":"2000","data":{"reg.1.address":{"Value":"101","Source":"web"},"feature.doNotDisturb.enable":{"Value":"1","Source":"default"}}}`))
+		case pcFactoryResetPath:
+			w.Write([]byte(`{"Status":"4007"}`))
+		default:
+			w.Write([]byte(`{"Status":"2000"}`))
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	creds := map[string]string{"username": "Polycom", "password": "456", "sip_server": "192.168.1.10"}
+	return fake, NewPolycomPhone(ts.URL[7:], creds, nil)
+}
+
+func (f *fakePolycom) data(path string) map[string]interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, _ := f.bodies[path]["data"].(map[string]interface{})
+	return data
+}
+
+func TestPolycomStatusAndConfig(t *testing.T) {
+	fake, phone := newFakePolycom(t)
+
+	status, err := phone.GetStatus()
+	if err != nil {
+		t.Fatalf("GetStatus failed: %v", err)
+	}
+	if status.Model != "VVX 450" || !status.Registered || len(status.Accounts) != 1 || status.Accounts[0].Extension != "101" {
+		t.Errorf("unexpected status: %+v", status)
+	}
+
+	config, err := phone.GetConfig()
+	if err != nil || config["reg.1.address"] != "101" {
+		t.Errorf("unexpected config %v (err %v)", config, err)
+	}
+
+	if err := phone.ProvisionExtension(Extension{ExtensionNumber: "105", Secret: "pa55", Transport: "transport-tls"}, 2); err != nil {
+		t.Fatalf("ProvisionExtension failed: %v", err)
+	}
+	set := fake.data(pcConfigSetPath)
+	if set["reg.2.auth.userId"] != "105" || set["reg.2.auth.password"] != "pa55" || set["reg.2.server.1.transport"] != "TLS" || set["reg.2.server.1.address"] != "192.168.1.10" {
+		t.Errorf("unexpected provisioning parameters: %v", set)
+	}
+
+	if err := phone.FactoryReset(); err == nil {
+		t.Error("expected error for non-2000 API status")
+	}
+}
+
+func TestPolycomCallControl(t *testing.T) {
+	fake, phone := newFakePolycom(t)
+
+	if err := phone.AcceptCall(1); err == nil {
+		t.Error("expected error answering with no ringing call")
+	}
+
+	fake.mu.Lock()
+	fake.calls = `[{"CallHandle":"0x4d2","LineID":"1","CallState":"Connected","Type":"Outgoing","RemotePartyNumber":"102","DurationInSeconds":"12"}]`
+	fake.mu.Unlock()
+
+	state, err := phone.GetPhoneState()
+	if err != nil {
+		t.Fatalf("GetPhoneState failed: %v", err)
+	}
+	if !state.DNDEnabled || len(state.Calls) != 1 || state.Calls[0].State != "connected" || state.Calls[0].Duration != 12 {
+		t.Errorf("unexpected state: %+v", state)
+	}
+
+	if err := phone.HoldCall(1); err != nil {
+		t.Fatalf("HoldCall failed: %v", err)
+	}
+	if ref := fake.data(pcCallCtrlPath + "holdCall")["Ref"]; ref != "0x4d2" {
+		t.Errorf("holdCall should reference the call handle, got %v", ref)
+	}
+	if err := phone.BlindTransfer("103", 1); err != nil {
+		t.Fatalf("BlindTransfer failed: %v", err)
+	}
+	if dest := fake.data(pcCallCtrlPath + "transferCall")["TransferDest"]; dest != "103" {
+		t.Errorf("unexpected transfer destination %v", dest)
+	}
+	if err := phone.Dial("104", 0); err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	if dial := fake.data(pcCallCtrlPath + "dial"); dial["Dest"] != "104" || dial["Line"] != "1" {
+		t.Errorf("unexpected dial request: %v", dial)
+	}
+	if err := phone.SetDND(false); err != nil {
+		t.Fatalf("SetDND failed: %v", err)
+	}
+	if v := fake.data(pcConfigSetPath)[pcDNDParam]; v != "0" {
+		t.Errorf("expected DND disabled, got %v", v)
+	}
+}
@@ -0,0 +1,1018 @@
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Provisioning defaults, overridable through the PROVISIONING_* environment variables
+const (
+	DefaultProvisioningHTTPAddr     = ":8088"
+	DefaultProvisioningTemplatesDir = "/etc/rayanpbx/provisioning"
+	provisioningLogSize             = 200
+	provisioningMaxLines            = 6
+)
+
+// errProvisioningNotFound is returned when a file name or MAC has no provisioning config
+var errProvisioningNotFound = errors.New("no provisioning config")
+
+// ProvisioningSettings are the site settings of the provisioning service
+type ProvisioningSettings struct {
+	HTTPAddr          string `json:"http_addr"`
+	TFTPAddr          string `json:"tftp_addr,omitempty"` // Empty disables TFTP
+	TLSCert           string `json:"tls_cert,omitempty"`
+	TLSKey            string `json:"tls_key,omitempty"`
+	Username          string `json:"username,omitempty"` // HTTP basic auth, empty disables it
+	Password          string `json:"-"`
+	Host              string `json:"host"`       // Address phones use to reach this server
+	SIPServer         string `json:"sip_server"` // Registrar written into phone configs
+	NTPServer         string `json:"ntp_server,omitempty"`
+	AdminPassword     string `json:"-"` // Phone web admin password, left unchanged when empty
+	TemplatesDir      string `json:"templates_dir"`
+	FirmwareDir       string `json:"firmware_dir"` // Firmware repository served under /firmware/
+	LogFile           string `json:"log_file,omitempty"`
+	Phonebook         bool   `json:"phonebook"`                     // Point provisioned phones at /phonebook/
+	PhonebookLDAPAddr string `json:"phonebook_ldap_addr,omitempty"` // Empty disables the LDAP phonebook
+	PhonebookBaseDN   string `json:"phonebook_base_dn,omitempty"`
+}
+
+// LoadProvisioningSettings reads the provisioning settings from the environment
+func LoadProvisioningSettings() ProvisioningSettings {
+	settings := ProvisioningSettings{
+		HTTPAddr:          getEnv("PROVISIONING_HTTP_ADDR", DefaultProvisioningHTTPAddr),
+		TFTPAddr:          getEnv("PROVISIONING_TFTP_ADDR", ""),
+		TLSCert:           getEnv("PROVISIONING_TLS_CERT", ""),
+		TLSKey:            getEnv("PROVISIONING_TLS_KEY", ""),
+		Username:          getEnv("PROVISIONING_USERNAME", ""),
+		Password:          getEnv("PROVISIONING_PASSWORD", ""),
+		Host:              getEnv("PROVISIONING_HOST", ""),
+		SIPServer:         getEnv("PROVISIONING_SIP_SERVER", ""),
+		NTPServer:         getEnv("PROVISIONING_NTP_SERVER", ""),
+		AdminPassword:     getEnv("PROVISIONING_PHONE_ADMIN_PASSWORD", ""),
+		TemplatesDir:      getEnv("PROVISIONING_TEMPLATES_DIR", DefaultProvisioningTemplatesDir),
+		FirmwareDir:       getEnv("PROVISIONING_FIRMWARE_DIR", DefaultFirmwareDir),
+		LogFile:           getEnv("PROVISIONING_LOG", ""),
+		Phonebook:         getEnv("PROVISIONING_PHONEBOOK", "true") == "true",
+		PhonebookLDAPAddr: getEnv("PROVISIONING_PHONEBOOK_LDAP", ""),
+		PhonebookBaseDN:   getEnv("PROVISIONING_PHONEBOOK_BASE_DN", DefaultPhonebookBaseDN),
+	}
+	if settings.Host == "" {
+		if ips := GetLocalIPAddresses(); len(ips) > 0 {
+			settings.Host = ips[0]
+		}
+	}
+	if settings.SIPServer == "" {
+		settings.SIPServer = settings.Host
+	}
+	return settings
+}
+
+// TLS reports whether the HTTP listener serves HTTPS
+func (s ProvisioningSettings) TLS() bool {
+	return s.TLSCert != "" && s.TLSKey != ""
+}
+
+// CheckCredentials compares a username and password with the provisioning credentials in constant time
+func (s ProvisioningSettings) CheckCredentials(username, password string) bool {
+	userOK := subtle.ConstantTimeCompare([]byte(username), []byte(s.Username))
+	passOK := subtle.ConstantTimeCompare([]byte(password), []byte(s.Password))
+	return userOK&passOK == 1
+}
+
+// URL returns the base URL phones should fetch their configs from
+func (s ProvisioningSettings) URL() string {
+	scheme := "http"
+	if s.TLS() {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s/", scheme, addrWithHost(s.HTTPAddr, s.Host))
+}
+
+// TFTPConfigs reports whether phone configs may be served over TFTP. TFTP has no
+// authentication, so it is refused once HTTP basic auth is configured.
+func (s ProvisioningSettings) TFTPConfigs() bool {
+	return s.Username == ""
+}
+
+// TFTPURL returns the TFTP address phones should fetch their configs from, or "" if disabled
+func (s ProvisioningSettings) TFTPURL() string {
+	if s.TFTPAddr == "" || !s.TFTPConfigs() {
+		return ""
+	}
+	addr := addrWithHost(s.TFTPAddr, s.Host)
+	return "tftp://" + strings.TrimSuffix(addr, ":69") + "/"
+}
+
+// addrWithHost replaces an empty or wildcard listen host with host, dropping default ports
+func addrWithHost(listen, host string) string {
+	h, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return listen
+	}
+	if h == "" || h == "0.0.0.0" || h == "::" {
+		h = host
+	}
+	if port == "80" || port == "443" {
+		return h
+	}
+	return net.JoinHostPort(h, port)
+}
+
+// ProvisionedPhone is a phone with its assigned extensions, one per account/line
+type ProvisionedPhone struct {
+	MAC    string
+	Vendor string
+	Model  string
+	Lines  []ProvisionedLine
+	Keys   []PhoneKey // Programmable keys from the phone's key layout
+}
+
+// ProvisionedLine is an extension assigned to a phone's line key; Line is the account number
+type ProvisionedLine struct {
+	Line int
+	Extension
+}
+
+// ProvisioningLookup resolves a normalized MAC to its phone, or nil if it is not assigned
+type ProvisioningLookup func(mac string) (*ProvisionedPhone, error)
+
+// normalizeMAC returns the MAC as 12 lowercase hex digits, or "" if it is not a MAC address
+func normalizeMAC(mac string) string {
+	mac = strings.ToLower(strings.NewReplacer(":", "", "-", "", ".", "").Replace(strings.TrimSpace(mac)))
+	if len(mac) != 12 {
+		return ""
+	}
+	if _, err := strconv.ParseUint(mac, 16, 64); err != nil {
+		return ""
+	}
+	return mac
+}
+
+// DBProvisioningLookup resolves phones from the inventory. Line assignments come from
+// voip_phone_lines, or from the extension column listing one extension per line
+// separated by commas for phones assigned before line keys were tracked.
+func DBProvisioningLookup(db *sql.DB) ProvisioningLookup {
+	return func(mac string) (*ProvisionedPhone, error) {
+		if db == nil {
+			return nil, fmt.Errorf("database connection is nil")
+		}
+		var id int64
+		var vendor, model, assigned string
+		err := db.QueryRow(`SELECT id, COALESCE(vendor, ''), COALESCE(model, ''), COALESCE(extension, '')
+		                    FROM voip_phones WHERE `+macMatchSQL, mac).
+			Scan(&id, &vendor, &model, &assigned)
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up phone %s: %v", mac, err)
+		}
+
+		rows, err := db.Query("SELECT line, extension FROM voip_phone_lines WHERE voip_phone_id = ? ORDER BY line", id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load line assignments: %v", err)
+		}
+		defer rows.Close()
+		var lines []PhoneLine
+		for rows.Next() {
+			var line PhoneLine
+			if err := rows.Scan(&line.Line, &line.Extension); err != nil {
+				return nil, fmt.Errorf("failed to read line assignments: %v", err)
+			}
+			lines = append(lines, line)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read line assignments: %v", err)
+		}
+		if len(lines) == 0 {
+			lines = phoneLinesFromColumn(assigned)
+		}
+
+		extensions, err := GetExtensions(db)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load extensions: %v", err)
+		}
+		return &ProvisionedPhone{MAC: mac, Vendor: vendor, Model: model, Lines: assignedLines(lines, extensions)}, nil
+	}
+}
+
+// assignedLines resolves line assignments to enabled extensions, skipping line keys
+// the vendors' account tables do not cover
+func assignedLines(assigned []PhoneLine, extensions []Extension) []ProvisionedLine {
+	var lines []ProvisionedLine
+	for _, line := range assigned {
+		if line.Line < 1 || line.Line > provisioningMaxLines {
+			continue
+		}
+		for _, ext := range extensions {
+			if ext.ExtensionNumber == line.Extension && ext.Enabled {
+				lines = append(lines, ProvisionedLine{Line: line.Line, Extension: ext})
+				break
+			}
+		}
+	}
+	return lines
+}
+
+// Config file names requested by each vendor's auto-provisioning
+var (
+	gsConfigFilePattern   = regexp.MustCompile(`(?i)^cfg([0-9a-f]{12})(\.xml)?$`)
+	macConfigFilePattern  = regexp.MustCompile(`(?i)^([0-9a-f]{12})\.cfg$`) // Yealink and Fanvil
+	snomConfigFilePattern = regexp.MustCompile(`(?i)^snom[a-z0-9]*-([0-9a-f]{12})\.(htm|xml)$`)
+)
+
+// ParseProvisioningFile returns the MAC and vendor a config file name belongs to.
+// The vendor is "" when the name is shared by several vendors.
+func ParseProvisioningFile(name string) (mac string, vendor string, ok bool) {
+	name = strings.TrimPrefix(filepath.ToSlash(name), "/")
+	name = name[strings.LastIndex(name, "/")+1:]
+	if m := gsConfigFilePattern.FindStringSubmatch(name); m != nil {
+		return strings.ToLower(m[1]), "grandstream", true
+	}
+	if m := snomConfigFilePattern.FindStringSubmatch(name); m != nil {
+		return strings.ToLower(m[1]), "snom", true
+	}
+	if m := macConfigFilePattern.FindStringSubmatch(name); m != nil {
+		return strings.ToLower(m[1]), "", true
+	}
+	return "", "", false
+}
+
+// resolveProvisioningVendor picks the vendor for a shared <mac>.cfg name from
+// the inventory, the MAC OUI and finally the requesting User-Agent
+func resolveProvisioningVendor(phone *ProvisionedPhone, mac, userAgent string) string {
+	for _, vendor := range []string{strings.ToLower(phone.Vendor), phoneDriverVendor(PhoneDriverForMAC(mac)), phoneDriverVendor(PhoneDriverForUserAgent(userAgent))} {
+		if vendor == "yealink" || vendor == "fanvil" {
+			return vendor
+		}
+	}
+	return "yealink"
+}
+
+// phoneDriverVendor returns the driver's vendor key, or "" for nil
+func phoneDriverVendor(driver *PhoneDriver) string {
+	if driver == nil {
+		return ""
+	}
+	return driver.Vendor
+}
+
+// grandstreamAccountPValues are the GrandStream P-values of accounts 1-6:
+// active, name, SIP server, user ID, auth ID, password, display name
+var grandstreamAccountPValues = [provisioningMaxLines][7]string{
+	{"P271", "P270", "P47", "P35", "P36", "P34", "P3"},
+	{"P401", "P417", "P402", "P404", "P405", "P406", "P407"},
+	{"P501", "P517", "P502", "P504", "P505", "P506", "P507"},
+	{"P601", "P617", "P602", "P604", "P605", "P606", "P607"},
+	{"P1701", "P1717", "P1702", "P1704", "P1705", "P1706", "P1707"},
+	{"P1801", "P1817", "P1802", "P1804", "P1805", "P1806", "P1807"},
+}
+
+// GrandStream global P-values used by provisioning
+const (
+	gsPAdminPassword      = "P2"
+	gsPNTPServer          = "P30"
+	gsPConfigUpgradeVia   = "P212" // 0 TFTP, 1 HTTP, 2 HTTPS
+	gsPConfigServerPath   = "P237"
+	gsPConfigHTTPUser     = "P1359"
+	gsPConfigHTTPPassword = "P1360"
+)
+
+// GrandStreamAccountParams returns the P-values of one account
+func GrandStreamAccountParams(ext Extension, accountNumber int, server string) map[string]string {
+	if accountNumber < 1 || accountNumber > provisioningMaxLines {
+		return nil
+	}
+	p := grandstreamAccountPValues[accountNumber-1]
+	if provisionUsesTLS(ext) {
+		server += ":5061"
+	}
+	return map[string]string{
+		p[0]: "1",
+		p[1]: ext.ExtensionNumber,
+		p[2]: server,
+		p[3]: ext.ExtensionNumber,
+		p[4]: ext.ExtensionNumber,
+		p[5]: ext.Secret,
+		p[6]: provisionDisplayName(ext),
+	}
+}
+
+// ProvisioningParams returns the vendor parameters for a phone's lines and the site settings
+func ProvisioningParams(vendor string, phone *ProvisionedPhone, settings ProvisioningSettings) (map[string]string, error) {
+	params := make(map[string]string)
+	add := func(values map[string]string) {
+		for key, value := range values {
+			params[key] = value
+		}
+	}
+	for _, line := range phone.Lines {
+		ext, account := line.Extension, line.Line
+		switch vendor {
+		case "grandstream":
+			add(GrandStreamAccountParams(ext, account, settings.SIPServer))
+		case "yealink":
+			add(YealinkAccountConfig(ext, account, settings.SIPServer))
+		case "fanvil":
+			add(FanvilLineConfig(ext, account, settings.SIPServer))
+		case "snom":
+			add(SnomLineConfig(ext, account, settings.SIPServer))
+		default:
+			return nil, fmt.Errorf("provisioning files are not supported for vendor %q", vendor)
+		}
+	}
+	add(KeyLayoutParams(vendor, phone.Keys, settings.SIPServer))
+
+	switch vendor {
+	case "grandstream":
+		add(map[string]string{gsPNTPServer: settings.NTPServer, gsPAdminPassword: settings.AdminPassword})
+	case "yealink":
+		add(map[string]string{"local_time.ntp_server1": settings.NTPServer})
+		if settings.AdminPassword != "" {
+			params["static.security.user_password"] = "admin:" + settings.AdminPassword
+		}
+	case "snom":
+		add(map[string]string{"ntp_server": settings.NTPServer, "admin_mode_password": settings.AdminPassword})
+	}
+	if settings.Phonebook {
+		add(PhonebookParams(vendor, settings))
+	}
+	for key, value := range params {
+		if value == "" {
+			delete(params, key)
+		}
+	}
+	return params, nil
+}
+
+// ProvisioningTemplateData is passed to the provisioning templates
+type ProvisioningTemplateData struct {
+	MAC    string
+	Vendor string
+	Model  string
+	Lines  []ProvisionedLine
+	Keys   []PhoneKey
+	Site   ProvisioningSettings
+	Params map[string]string // Vendor parameters built from Lines and Site
+}
+
+// defaultProvisioningTemplates are used when the templates directory has no <vendor>.tmpl
+var defaultProvisioningTemplates = map[string]string{
+	"grandstream": `<?xml version="1.0" encoding="UTF-8"?>
+<gs_provision version="1">
+  <mac>{{.MAC}}</mac>
+  <config version="1">
+{{- range $key, $value := .Params}}
+    <{{$key}}>{{xml $value}}</{{$key}}>
+{{- end}}
+  </config>
+</gs_provision>
+`,
+	"yealink": `#!version:1.0.0.1
+## Generated by RayanPBX for {{.MAC}}
+{{range $key, $value := .Params}}{{$key}} = {{yealinkValue $value}}
+{{end}}`,
+	"fanvil": `{{fanvilConfig .Params}}`,
+	"snom": `<?xml version="1.0" encoding="utf-8"?>
+<settings>
+  <phone-settings>
+{{- range $key, $value := .Params}}
+    {{snomSetting $key $value}}
+{{- end}}
+  </phone-settings>
+</settings>
+`,
+}
+
+// snomIndexedSetting splits "user_name1" into the setting name and line index
+var snomIndexedSetting = regexp.MustCompile(`^(.*?[a-z_])(\d+)$`)
+
+// provisioningTemplateFuncs are available to all provisioning templates
+var provisioningTemplateFuncs = template.FuncMap{
+	"xml":          xmlEscape,
+	"yealinkValue": yealinkValue,
+	"fanvilConfig": func(params map[string]string) (string, error) {
+		return RenderFanvilConfig(params)
+	},
+	"snomSetting": func(key, value string) string {
+		if m := snomIndexedSetting.FindStringSubmatch(key); m != nil {
+			return fmt.Sprintf(`<%s idx="%s" perm="">%s</%s>`, m[1], m[2], xmlEscape(value), m[1])
+		}
+		return fmt.Sprintf(`<%s perm="">%s</%s>`, key, xmlEscape(value), key)
+	},
+}
+
+// xmlEscape escapes text for XML element content
+func xmlEscape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// loadProvisioningTemplates parses the built-in templates, replaced by <dir>/<vendor>.tmpl when present
+func loadProvisioningTemplates(dir string) (map[string]*template.Template, error) {
+	templates := make(map[string]*template.Template, len(defaultProvisioningTemplates))
+	for vendor, text := range defaultProvisioningTemplates {
+		if dir != "" {
+			data, err := os.ReadFile(filepath.Join(dir, vendor+".tmpl"))
+			if err == nil {
+				text = string(data)
+			} else if !os.IsNotExist(err) {
+				return nil, fmt.Errorf("failed to read %s template: %v", vendor, err)
+			}
+		}
+		tmpl, err := template.New(vendor).Funcs(provisioningTemplateFuncs).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s template: %v", vendor, err)
+		}
+		templates[vendor] = tmpl
+	}
+	return templates, nil
+}
+
+// ProvisioningLogEntry records one config file request
+type ProvisioningLogEntry struct {
+	Time      time.Time `json:"time"`
+	Protocol  string    `json:"protocol"`
+	Remote    string    `json:"remote"`
+	File      string    `json:"file"`
+	MAC       string    `json:"mac,omitempty"`
+	Vendor    string    `json:"vendor,omitempty"`
+	Status    int       `json:"status"` // HTTP status code, also used for TFTP results
+	Bytes     int       `json:"bytes"`
+	UserAgent string    `json:"user_agent,omitempty"`
+}
+
+// ProvisioningServer serves per-MAC phone config files over HTTP(S) and TFTP
+type ProvisioningServer struct {
+	settings  ProvisioningSettings
+	lookup    ProvisioningLookup
+	templates map[string]*template.Template
+
+	mu           sync.Mutex
+	log          []ProvisioningLogEntry
+	httpServer   *http.Server
+	tftpConn     net.PacketConn
+	phonebook    PhonebookSource // Directory served under /phonebook/ and over LDAP, nil disables both
+	ldapListener net.Listener
+	keyLayouts   KeyLayouts // Cached key layouts file, reloaded when its modification time changes
+	keyLayoutsAt time.Time
+}
+
+// NewProvisioningServer creates a provisioning server, loading templates from the settings
+func NewProvisioningServer(settings ProvisioningSettings, lookup ProvisioningLookup) (*ProvisioningServer, error) {
+	templates, err := loadProvisioningTemplates(settings.TemplatesDir)
+	if err != nil {
+		return nil, err
+	}
+	return &ProvisioningServer{settings: settings, lookup: lookup, templates: templates}, nil
+}
+
+// Settings returns the server's settings
+func (ps *ProvisioningServer) Settings() ProvisioningSettings {
+	return ps.settings
+}
+
+// Render renders the config file with the given name, returning the MAC and vendor it belongs to
+func (ps *ProvisioningServer) Render(name, userAgent string) ([]byte, string, string, error) {
+	mac, vendor, ok := ParseProvisioningFile(name)
+	if !ok {
+		return nil, "", "", errProvisioningNotFound
+	}
+	phone, err := ps.lookup(mac)
+	if err != nil {
+		return nil, mac, vendor, err
+	}
+	if phone == nil || len(phone.Lines) == 0 {
+		return nil, mac, vendor, errProvisioningNotFound
+	}
+	if vendor == "" {
+		vendor = resolveProvisioningVendor(phone, mac, userAgent)
+	}
+	keys, err := ps.phoneKeys(mac)
+	if err != nil {
+		return nil, mac, vendor, err
+	}
+	// The lookup may share its records between concurrent requests, so render a copy
+	provisioned := *phone
+	provisioned.Keys = keys
+	data, err := ps.RenderPhone(vendor, &provisioned)
+	return data, mac, vendor, err
+}
+
+// phoneKeys returns a phone's programmable keys, reading the key layouts file only when it changed
+func (ps *ProvisioningServer) phoneKeys(mac string) ([]PhoneKey, error) {
+	path := keyLayoutsFilePath()
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key layouts: %v", err)
+	}
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if !info.ModTime().Equal(ps.keyLayoutsAt) {
+		layouts, err := LoadKeyLayouts(path)
+		if err != nil {
+			return nil, err
+		}
+		ps.keyLayouts, ps.keyLayoutsAt = layouts, info.ModTime()
+	}
+	return ps.keyLayouts.PhoneKeys(mac), nil
+}
+
+// RenderPhone renders the config file of a phone for a vendor
+func (ps *ProvisioningServer) RenderPhone(vendor string, phone *ProvisionedPhone) ([]byte, error) {
+	tmpl, ok := ps.templates[vendor]
+	if !ok {
+		return nil, fmt.Errorf("provisioning files are not supported for vendor %q", vendor)
+	}
+	params, err := ProvisioningParams(vendor, phone, ps.settings)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	data := ProvisioningTemplateData{MAC: phone.MAC, Vendor: vendor, Model: phone.Model, Lines: phone.Lines, Keys: phone.Keys, Site: ps.settings, Params: params}
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render %s config: %v", vendor, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// record appends a request to the log and the optional log file
+func (ps *ProvisioningServer) record(entry ProvisioningLogEntry) {
+	ps.mu.Lock()
+	ps.log = append(ps.log, entry)
+	if len(ps.log) > provisioningLogSize {
+		ps.log = ps.log[len(ps.log)-provisioningLogSize:]
+	}
+	ps.mu.Unlock()
+
+	if ps.settings.LogFile == "" {
+		return
+	}
+	f, err := os.OpenFile(ps.settings.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintf(f, "%s %s %s %s %d %d %s %q\n", entry.Time.Format(time.RFC3339), entry.Protocol, entry.Remote,
+		entry.File, entry.Status, entry.Bytes, entry.MAC, entry.UserAgent)
+}
+
+// Log returns the recent requests, newest first
+func (ps *ProvisioningServer) Log() []ProvisioningLogEntry {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	entries := make([]ProvisioningLogEntry, len(ps.log))
+	for i, entry := range ps.log {
+		entries[len(ps.log)-1-i] = entry
+	}
+	return entries
+}
+
+// ServeHTTP serves config files by name, firmware images and phonebooks, with optional basic auth
+func (ps *ProvisioningServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	protocol := "http"
+	if r.TLS != nil {
+		protocol = "https"
+	}
+	entry := ProvisioningLogEntry{Time: time.Now(), Protocol: protocol, Remote: r.RemoteAddr, File: r.URL.Path, UserAgent: r.UserAgent()}
+	defer func() { ps.record(entry) }()
+
+	if ps.settings.Username != "" {
+		user, pass, ok := r.BasicAuth()
+		if !ok || !ps.settings.CheckCredentials(user, pass) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="RayanPBX provisioning"`)
+			entry.Status = http.StatusUnauthorized
+			http.Error(w, "unauthorized", entry.Status)
+			return
+		}
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		entry.Status = http.StatusMethodNotAllowed
+		http.Error(w, "method not allowed", entry.Status)
+		return
+	}
+
+	if strings.HasPrefix(r.URL.Path, firmwareURLPrefix) {
+		ps.serveFirmware(w, r, &entry)
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, phonebookURLPrefix) {
+		ps.servePhonebook(w, r, &entry)
+		return
+	}
+
+	data, mac, vendor, err := ps.Render(r.URL.Path, r.UserAgent())
+	entry.MAC, entry.Vendor = mac, vendor
+	if errors.Is(err, errProvisioningNotFound) {
+		entry.Status = http.StatusNotFound
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		entry.Status = http.StatusInternalServerError
+		http.Error(w, "failed to render config", entry.Status)
+		return
+	}
+
+	contentType := "text/plain; charset=utf-8"
+	if vendor == "grandstream" || vendor == "snom" {
+		contentType = "application/xml; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-store")
+	entry.Status = http.StatusOK
+	entry.Bytes = len(data)
+	w.Write(data)
+}
+
+// Start starts the HTTP(S) listener and, if configured, the TFTP and LDAP phonebook listeners
+func (ps *ProvisioningServer) Start() error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if ps.httpServer != nil {
+		return fmt.Errorf("provisioning server is already running")
+	}
+
+	listener, err := net.Listen("tcp", ps.settings.HTTPAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %v", ps.settings.HTTPAddr, err)
+	}
+	var tftpConn net.PacketConn
+	if ps.settings.TFTPAddr != "" {
+		if tftpConn, err = net.ListenPacket("udp", ps.settings.TFTPAddr); err != nil {
+			listener.Close()
+			return fmt.Errorf("failed to listen on TFTP %s: %v", ps.settings.TFTPAddr, err)
+		}
+		go ps.serveTFTP(tftpConn)
+	}
+	var ldapListener net.Listener
+	if ps.phonebook != nil && ps.settings.PhonebookLDAPAddr != "" {
+		if ldapListener, err = net.Listen("tcp", ps.settings.PhonebookLDAPAddr); err != nil {
+			listener.Close()
+			if tftpConn != nil {
+				tftpConn.Close()
+			}
+			return fmt.Errorf("failed to listen on LDAP %s: %v", ps.settings.PhonebookLDAPAddr, err)
+		}
+		go servePhonebookLDAP(ldapListener, ps.phonebook, ps.settings)
+	}
+
+	server := &http.Server{Handler: ps, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if ps.settings.TLS() {
+			server.ServeTLS(listener, ps.settings.TLSCert, ps.settings.TLSKey)
+		} else {
+			server.Serve(listener)
+		}
+	}()
+	ps.httpServer = server
+	ps.tftpConn = tftpConn
+	ps.ldapListener = ldapListener
+	return nil
+}
+
+// Running reports whether the listeners are started
+func (ps *ProvisioningServer) Running() bool {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	return ps.httpServer != nil
+}
+
+// Stop stops all listeners
+func (ps *ProvisioningServer) Stop() {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if ps.httpServer != nil {
+		ps.httpServer.Close()
+		ps.httpServer = nil
+	}
+	if ps.tftpConn != nil {
+		ps.tftpConn.Close()
+		ps.tftpConn = nil
+	}
+	if ps.ldapListener != nil {
+		ps.ldapListener.Close()
+		ps.ldapListener = nil
+	}
+}
+
+// ProvisioningServerParams returns the vendor parameters that point a phone at the provisioning URL
+func ProvisioningServerParams(vendor string, settings ProvisioningSettings) (map[string]interface{}, error) {
+	base := settings.URL()
+	switch strings.ToLower(vendor) {
+	case "grandstream":
+		via := "1"
+		if settings.TLS() {
+			via = "2"
+		}
+		params := map[string]interface{}{
+			gsPConfigUpgradeVia: via,
+			gsPConfigServerPath: strings.TrimSuffix(strings.SplitN(base, "://", 2)[1], "/"),
+		}
+		if settings.Username != "" {
+			params[gsPConfigHTTPUser] = settings.Username
+			params[gsPConfigHTTPPassword] = settings.Password
+		}
+		return params, nil
+	case "yealink":
+		params := map[string]interface{}{"static.auto_provision.server.url": base}
+		if settings.Username != "" {
+			params["static.auto_provision.server.username"] = settings.Username
+			params["static.auto_provision.server.password"] = settings.Password
+		}
+		return params, nil
+	case "fanvil":
+		params := map[string]interface{}{
+			"AUTOUPDATE CONFIG MODULE/Download Server IP": base,
+			"AUTOUPDATE CONFIG MODULE/Config File Name":   "$mac.cfg",
+		}
+		if settings.Username != "" {
+			params["AUTOUPDATE CONFIG MODULE/Download Username"] = settings.Username
+			params["AUTOUPDATE CONFIG MODULE/Download password"] = settings.Password
+		}
+		return params, nil
+	case "snom":
+		url := base
+		if settings.Username != "" {
+			url = strings.Replace(base, "://", "://"+settings.Username+":"+settings.Password+"@", 1)
+		}
+		return map[string]interface{}{"setting_server": url + "snom{mac}.htm"}, nil
+	}
+	return nil, fmt.Errorf("provisioning server is not supported for vendor %q", vendor)
+}
+
+// PointPhoneAtProvisioning sets a phone's auto-provisioning server and asks it to fetch
+// its config where the vendor supports that, returning what happens next
+func PointPhoneAtProvisioning(phone VoIPPhone, vendor string, settings ProvisioningSettings) (string, error) {
+	params, err := ProvisioningServerParams(vendor, settings)
+	if err != nil {
+		return "", err
+	}
+	if err := phone.SetConfig(params); err != nil {
+		return "", fmt.Errorf("failed to set provisioning server: %v", err)
+	}
+
+	switch p := phone.(type) {
+	case *GrandStreamPhone:
+		if _, err := NewGrandStreamCTI(p).TriggerProvision(); err != nil {
+			return "", fmt.Errorf("provisioning server set, but failed to trigger provisioning: %v", err)
+		}
+		return "Phone is fetching its config from the provisioning server", nil
+	case *YealinkPhone:
+		if err := p.AutoProvision(); err != nil {
+			return "", fmt.Errorf("provisioning server set, but failed to trigger provisioning: %v", err)
+		}
+		return "Phone is fetching its config from the provisioning server", nil
+	}
+	return "Reboot the phone to fetch its config from the provisioning server", nil
+}
+
+// Views of the provisioning server screen
+const (
+	provisioningViewLog = iota
+	provisioningViewDHCP
+	provisioningViewRollout
+)
+
+// provisioningRolloutMsg carries the results of a provisioning URL rollout
+type provisioningRolloutMsg struct {
+	results []ProvisioningRolloutResult
+}
+
+// initProvisioning opens the provisioning server screen
+func (m *model) initProvisioning() {
+	m.currentScreen = provisioningScreen
+	m.errorMsg = ""
+	m.successMsg = ""
+	m.provisioningView = provisioningViewLog
+	m.provisioningHosts = ProvisioningHostCandidates(LoadProvisioningSettings())
+	m.provisioningHostIdx = 0
+}
+
+// provisioningSettings returns the settings in use with the address selected on the screen
+func (m model) provisioningSettings() ProvisioningSettings {
+	settings := LoadProvisioningSettings()
+	if m.provisioningServer != nil && m.provisioningServer.Running() {
+		settings = m.provisioningServer.Settings()
+	}
+	if m.provisioningHostIdx < len(m.provisioningHosts) {
+		settings.Host = m.provisioningHosts[m.provisioningHostIdx]
+	}
+	return settings
+}
+
+// toggleProvisioningServer starts the provisioning server, or stops it if running
+func (m *model) toggleProvisioningServer() {
+	m.errorMsg = ""
+	m.successMsg = ""
+	if m.provisioningServer != nil && m.provisioningServer.Running() {
+		m.provisioningServer.Stop()
+		m.successMsg = "Provisioning server stopped"
+		return
+	}
+	if m.db == nil {
+		m.errorMsg = "Database connection required to look up phone assignments"
+		return
+	}
+	server, err := NewProvisioningServer(m.provisioningSettings(), DBProvisioningLookup(m.db))
+	if err != nil {
+		m.errorMsg = err.Error()
+		return
+	}
+	server.SetPhonebook(DBPhonebookSource(m.db))
+	if err := server.Start(); err != nil {
+		m.errorMsg = err.Error()
+		return
+	}
+	m.provisioningServer = server
+	m.successMsg = "Provisioning server started at " + server.Settings().URL()
+}
+
+// startProvisioningRollout points every phone with stored credentials at the provisioning server
+func (m *model) startProvisioningRollout() tea.Cmd {
+	m.errorMsg = ""
+	m.successMsg = ""
+	targets := m.provisioningRolloutTargets()
+	if len(targets) == 0 {
+		m.errorMsg = "No phones with stored credentials - add them on the VoIP Phones screen first"
+		return nil
+	}
+	settings := m.provisioningSettings()
+	pm := m.phoneManager
+	m.provisioningView = provisioningViewRollout
+	m.provisioningRollout = nil
+	m.provisioningRolloutBusy = true
+	m.successMsg = fmt.Sprintf("Pointing %d phone(s) at %s...", len(targets), settings.URL())
+	return func() tea.Msg {
+		return provisioningRolloutMsg{results: RolloutProvisioningServer(pm, targets, settings)}
+	}
+}
+
+// finishProvisioningRollout shows the per-phone results of a rollout
+func (m *model) finishProvisioningRollout(results []ProvisioningRolloutResult) {
+	m.provisioningRollout = results
+	m.provisioningRolloutBusy = false
+	failed := 0
+	for _, result := range results {
+		if !result.OK {
+			failed++
+		}
+	}
+	m.errorMsg = ""
+	m.successMsg = ""
+	if failed > 0 {
+		m.errorMsg = fmt.Sprintf("%d of %d phone(s) could not be updated", failed, len(results))
+	} else {
+		m.successMsg = fmt.Sprintf("Provisioning server set on %d phone(s)", len(results))
+	}
+}
+
+// handleProvisioningScreen handles keys on the provisioning server screen
+func (m *model) handleProvisioningScreen(msg tea.KeyMsg) (*model, tea.Cmd) {
+	switch msg.String() {
+	case "s":
+		m.toggleProvisioningServer()
+	case "d":
+		m.provisioningView = provisioningViewDHCP
+	case "l":
+		m.provisioningView = provisioningViewLog
+	case "a":
+		if len(m.provisioningHosts) > 0 {
+			m.provisioningHostIdx = (m.provisioningHostIdx + 1) % len(m.provisioningHosts)
+		}
+	case "b":
+		if !m.provisioningRolloutBusy {
+			return m, m.startProvisioningRollout()
+		}
+	case "p":
+		m.initPhonebook()
+	case "r":
+		m.errorMsg = ""
+		m.successMsg = ""
+	case "esc":
+		m.errorMsg = ""
+		m.successMsg = ""
+		m.currentScreen = mainMenu
+		m.cursor = m.mainMenuCursor
+	case "q":
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// provisioningHelp returns the footer help for the current provisioning view
+func (m model) provisioningHelp() string {
+	if m.provisioningView == provisioningViewDHCP {
+		return "a: Next Address • l: Request Log • b: Set URL on Phones • p: Phonebook • ESC: Back to Main Menu • q: Quit"
+	}
+	return "s: Start/Stop Server • d: DHCP Options • b: Set URL on Phones • p: Phonebook • l: Request Log • r: Refresh • ESC: Back to Main Menu • q: Quit"
+}
+
+// renderProvisioning renders the provisioning server status and the selected view
+func (m model) renderProvisioning() string {
+	content := infoStyle.Render("📥 Zero-Touch Provisioning") + "\n\n"
+
+	settings := m.provisioningSettings()
+	running := m.provisioningServer != nil && m.provisioningServer.Running()
+	if running {
+		content += successStyle.Render("🟢 Running") + "\n"
+	} else {
+		content += helpStyle.Render("⚪ Stopped") + "\n"
+	}
+	content += fmt.Sprintf("HTTP URL:     %s\n", settings.URL())
+	if tftp := settings.TFTPURL(); tftp != "" {
+		content += fmt.Sprintf("TFTP URL:     %s\n", tftp)
+	} else if settings.TFTPAddr != "" {
+		content += warningStyle.Render("⚠️  TFTP refuses configs while auth is on, phones must use HTTP") + "\n"
+	}
+	auth := "off"
+	if settings.Username != "" {
+		auth = "basic (" + settings.Username + ")"
+	}
+	content += fmt.Sprintf("Auth:         %s\n", auth)
+	content += fmt.Sprintf("SIP server:   %s\n", settings.SIPServer)
+	content += fmt.Sprintf("Templates:    %s\n", settings.TemplatesDir)
+	content += fmt.Sprintf("Phonebook:    %s%s (press p to edit contacts)\n", settings.URL(), strings.TrimPrefix(phonebookURLPrefix, "/"))
+	content += helpStyle.Render("Files: cfg<mac>.xml (GrandStream), <mac>.cfg (Yealink/Fanvil), snom<model>-<MAC>.htm (Snom)") + "\n\n"
+
+	switch m.provisioningView {
+	case provisioningViewDHCP:
+		content += m.renderProvisioningDHCP(settings)
+	case provisioningViewRollout:
+		content += m.renderProvisioningRollout()
+	default:
+		content += m.renderProvisioningLog(running)
+	}
+	return menuStyle.Render(content)
+}
+
+// renderProvisioningLog renders the most recent config requests
+func (m model) renderProvisioningLog(running bool) string {
+	if !running {
+		return ""
+	}
+	entries := m.provisioningServer.Log()
+	if len(entries) == 0 {
+		return helpStyle.Render("No requests yet. Point phones at the URL above.") + "\n"
+	}
+	content := fmt.Sprintf("%-9s %-6s %-22s %-6s %-8s %s\n", "Time", "Proto", "Remote", "Status", "Bytes", "File")
+	if len(entries) > 15 {
+		entries = entries[:15]
+	}
+	for _, e := range entries {
+		content += fmt.Sprintf("%-9s %-6s %-22s %-6d %-8d %s\n", e.Time.Format("15:04:05"), e.Protocol, e.Remote, e.Status, e.Bytes, e.File)
+	}
+	return content
+}
+
+// renderProvisioningDHCP renders ready-to-paste DHCP snippets for the selected address
+func (m model) renderProvisioningDHCP(settings ProvisioningSettings) string {
+	content := infoStyle.Render("🌐 DHCP Options 66 / 160 / 43") + "\n"
+	if len(m.provisioningHosts) > 1 {
+		content += helpStyle.Render(fmt.Sprintf("Address %d of %d: %s (press a for the next one)", m.provisioningHostIdx+1, len(m.provisioningHosts), settings.Host)) + "\n"
+	}
+	for _, snippet := range DHCPProvisioningSnippets(settings.URL()) {
+		content += "\n" + successStyle.Render(snippet.Title) + " " + helpStyle.Render(snippet.File) + "\n"
+		content += snippet.Text
+	}
+	return content
+}
+
+// renderProvisioningRollout renders the per-phone results of the last rollout
+func (m model) renderProvisioningRollout() string {
+	content := infoStyle.Render("📤 Provisioning URL Rollout") + "\n\n"
+	if m.provisioningRolloutBusy {
+		return content + helpStyle.Render("⏳ Updating phones...") + "\n"
+	}
+	if len(m.provisioningRollout) == 0 {
+		return content + helpStyle.Render("Press b to set the provisioning URL on all phones with stored credentials.") + "\n"
+	}
+	for _, result := range m.provisioningRollout {
+		mark := "✅"
+		if !result.OK {
+			mark = "❌"
+		}
+		content += fmt.Sprintf("%s %-16s %-12s %s\n", mark, result.IP, result.Vendor, result.Message)
+	}
+	return content
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// provisioningRolloutWorkers limits how many phones are configured at once
+const provisioningRolloutWorkers = 8
+
+// DHCPSnippet is a DHCP server configuration fragment announcing the provisioning URL
+type DHCPSnippet struct {
+	Server string `json:"server"` // Short name used by the CLI: isc, dnsmasq or kea
+	Title  string `json:"title"`
+	File   string `json:"file"` // Where the fragment usually goes
+	Text   string `json:"text"`
+}
+
+// DHCPProvisioningSnippets returns ISC dhcpd, dnsmasq and Kea fragments setting
+// option 66 (GrandStream, Yealink, Fanvil, Snom), 160 (Polycom, Yealink) and
+// 43 (the URL as plain text, read by Yealink and Fanvil) to url
+func DHCPProvisioningSnippets(url string) []DHCPSnippet {
+	option43 := hex.EncodeToString([]byte(url))
+	colonHex := make([]string, 0, len(url))
+	for i := 0; i < len(option43); i += 2 {
+		colonHex = append(colonHex, option43[i:i+2])
+	}
+
+	isc := fmt.Sprintf(`# RayanPBX phone provisioning
+option provisioning-url code 160 = text;
+option tftp-server-name "%[1]s";
+option provisioning-url "%[1]s";
+option vendor-encapsulated-options %[2]s;
+`, url, strings.Join(colonHex, ":"))
+
+	dnsmasq := fmt.Sprintf(`# RayanPBX phone provisioning
+dhcp-option=66,"%[1]s"
+dhcp-option=160,"%[1]s"
+dhcp-option=43,%[2]s
+`, url, strings.Join(colonHex, ":"))
+
+	type keaOptionDef struct {
+		Name  string `json:"name"`
+		Code  int    `json:"code"`
+		Type  string `json:"type"`
+		Space string `json:"space"`
+	}
+	type keaOptionData struct {
+		Name      string `json:"name,omitempty"`
+		Code      int    `json:"code,omitempty"`
+		Data      string `json:"data"`
+		CSVFormat *bool  `json:"csv-format,omitempty"`
+	}
+	binary := false
+	kea, _ := json.MarshalIndent(struct {
+		OptionDef  []keaOptionDef  `json:"option-def"`
+		OptionData []keaOptionData `json:"option-data"`
+	}{
+		OptionDef: []keaOptionDef{
+			{Name: "vendor-encapsulated-options", Code: 43, Type: "binary", Space: "dhcp4"},
+		},
+		OptionData: []keaOptionData{
+			{Name: "tftp-server-name", Data: url},
+			{Code: 160, Data: url}, // Kea's built-in string option 160
+			{Name: "vendor-encapsulated-options", Data: option43, CSVFormat: &binary},
+		},
+	}, "", "  ")
+
+	return []DHCPSnippet{
+		{Server: "isc", Title: "ISC dhcpd", File: "/etc/dhcp/dhcpd.conf", Text: isc},
+		{Server: "dnsmasq", Title: "dnsmasq", File: "/etc/dnsmasq.d/rayanpbx-provisioning.conf", Text: dnsmasq},
+		{Server: "kea", Title: "Kea (merge into \"Dhcp4\")", File: "/etc/kea/kea-dhcp4.conf", Text: string(kea) + "\n"},
+	}
+}
+
+// ProvisioningHostCandidates returns the configured host followed by the other local addresses
+func ProvisioningHostCandidates(settings ProvisioningSettings) []string {
+	var hosts []string
+	seen := make(map[string]bool)
+	for _, host := range append([]string{settings.Host}, GetLocalIPAddresses()...) {
+		if host != "" && !seen[host] {
+			seen[host] = true
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// ProvisioningRolloutTarget is a reachable phone to point at the provisioning server
+type ProvisioningRolloutTarget struct {
+	IP          string
+	Vendor      string // Detected over HTTP when empty
+	Credentials map[string]string
+}
+
+// ProvisioningRolloutResult is the outcome of pointing one phone at the provisioning server
+type ProvisioningRolloutResult struct {
+	IP      string `json:"ip"`
+	Vendor  string `json:"vendor"`
+	OK      bool   `json:"ok"`
+	Message string `json:"message"`
+}
+
+// RolloutProvisioningServer sets the provisioning server on every target through its
+// vendor API, returning one result per target in the same order
+func RolloutProvisioningServer(pm *PhoneManager, targets []ProvisioningRolloutTarget, settings ProvisioningSettings) []ProvisioningRolloutResult {
+	results := make([]ProvisioningRolloutResult, len(targets))
+	runPhoneWorkers(len(targets), provisioningRolloutWorkers, func(i int) {
+		results[i] = rolloutProvisioningServer(pm, targets[i], settings)
+	})
+	return results
+}
+
+// rolloutProvisioningServer points a single phone at the provisioning server
+func rolloutProvisioningServer(pm *PhoneManager, target ProvisioningRolloutTarget, settings ProvisioningSettings) ProvisioningRolloutResult {
+	result := ProvisioningRolloutResult{IP: target.IP, Vendor: target.Vendor}
+	if result.Vendor == "" {
+		vendor, err := pm.DetectPhoneVendor(target.IP)
+		if err != nil {
+			result.Message = err.Error()
+			return result
+		}
+		result.Vendor = vendor
+	}
+	phone, err := pm.CreatePhone(target.IP, result.Vendor, target.Credentials)
+	if err != nil {
+		result.Message = err.Error()
+		return result
+	}
+	note, err := PointPhoneAtProvisioning(phone, result.Vendor, settings)
+	if err != nil {
+		result.Message = err.Error()
+		return result
+	}
+	result.OK = true
+	result.Message = note
+	return result
+}
+
+// provisioningRolloutTargets returns the phones with stored credentials, sorted by IP
+func (m model) provisioningRolloutTargets() []ProvisioningRolloutTarget {
+	var targets []ProvisioningRolloutTarget
+	for ip, creds := range m.phoneCredentials {
+		if creds["password"] == "" {
+			continue
+		}
+		target := ProvisioningRolloutTarget{IP: ip, Credentials: creds}
+		for _, phone := range m.voipPhones {
+			if driver := PhoneDriverForUserAgent(phone.UserAgent); phone.IP == ip && driver != nil && driver.Manageable() {
+				target.Vendor = driver.Vendor
+			}
+		}
+		targets = append(targets, target)
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].IP < targets[j].IP })
+	return targets
+}
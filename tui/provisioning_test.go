@@ -0,0 +1,339 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestProvisioningServer returns a server with one phone per vendor assigned extension 101
+func newTestProvisioningServer(t *testing.T, settings ProvisioningSettings) *ProvisioningServer {
+	t.Helper()
+	ext := Extension{ExtensionNumber: "101", Name: "Front Desk", Secret: "s3cr<t", Enabled: true}
+	phones := map[string]*ProvisionedPhone{
+		"000b82112233": {MAC: "000b82112233", Vendor: "grandstream", Lines: []ProvisionedLine{{Line: 1, Extension: ext}}},
+		"805ec0112233": {MAC: "805ec0112233", Lines: []ProvisionedLine{{Line: 1, Extension: ext}}},
+		"0c383e112233": {MAC: "0c383e112233", Lines: []ProvisionedLine{{Line: 1, Extension: ext}}},
+		"000413112233": {MAC: "000413112233", Vendor: "snom", Lines: []ProvisionedLine{{Line: 1, Extension: ext}}},
+		"001122334455": {MAC: "001122334455"},
+	}
+	if settings.SIPServer == "" {
+		settings.SIPServer = "192.168.1.10"
+	}
+	server, err := NewProvisioningServer(settings, func(mac string) (*ProvisionedPhone, error) {
+		return phones[mac], nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server
+}
+
+func TestParseProvisioningFile(t *testing.T) {
+	tests := []struct {
+		name   string
+		mac    string
+		vendor string
+		ok     bool
+	}{
+		{"/cfg000B82112233.xml", "000b82112233", "grandstream", true},
+		{"cfg000b82112233", "000b82112233", "grandstream", true},
+		{"/provisioning/805EC0112233.cfg", "805ec0112233", "", true},
+		{"snomD785-000413112233.htm", "000413112233", "snom", true},
+		{"y000000000000.cfg", "", "", false},
+		{"/../etc/passwd", "", "", false},
+	}
+	for _, tt := range tests {
+		mac, vendor, ok := ParseProvisioningFile(tt.name)
+		if mac != tt.mac || vendor != tt.vendor || ok != tt.ok {
+			t.Errorf("ParseProvisioningFile(%q) = %q, %q, %v", tt.name, mac, vendor, ok)
+		}
+	}
+
+	if normalizeMAC("00:0B:82:11:22:33") != "000b82112233" || normalizeMAC("00:0B:82") != "" || normalizeMAC("zz0b82112233") != "" {
+		t.Error("unexpected normalizeMAC result")
+	}
+	extensions := []Extension{
+		{ExtensionNumber: "101", Enabled: true}, {ExtensionNumber: "102", Enabled: true}, {ExtensionNumber: "103"},
+	}
+	lines := assignedLines(phoneLinesFromColumn("102, 101,103"), extensions)
+	if len(lines) != 2 || lines[0].ExtensionNumber != "102" || lines[0].Line != 1 || lines[1].ExtensionNumber != "101" || lines[1].Line != 2 {
+		t.Errorf("expected enabled lines 1:102 and 2:101, got %+v", lines)
+	}
+	lines = assignedLines([]PhoneLine{{Line: 3, Extension: "101"}, {Line: 7, Extension: "102"}}, extensions)
+	if len(lines) != 1 || lines[0].Line != 3 {
+		t.Errorf("expected only line key 3 to be assigned, got %+v", lines)
+	}
+}
+
+func TestProvisioningRender(t *testing.T) {
+	server := newTestProvisioningServer(t, ProvisioningSettings{NTPServer: "pool.ntp.org"})
+
+	tests := []struct {
+		file, userAgent, vendor string
+		contains                []string
+	}{
+		{"cfg000b82112233.xml", "", "grandstream", []string{"<P271>1</P271>", "<P47>192.168.1.10</P47>", "<P34>s3cr&lt;t</P34>", "<P30>pool.ntp.org</P30>"}},
+		{"805ec0112233.cfg", "", "yealink", []string{"#!version:1.0.0.1", "account.1.user_name = 101", "local_time.ntp_server1 = pool.ntp.org"}},
+		{"0c383e112233.cfg", "", "fanvil", []string{fvConfigHeader, "SIP1 Register User", ":101"}},
+		{"0c383e112233.cfg", "Yealink SIP-T46S 66.86.0.15", "fanvil", nil}, // OUI wins over the User-Agent
+		{"snomD785-000413112233.htm", "", "snom", []string{`<user_name idx="1" perm="">101</user_name>`, `<user_pass idx="1" perm="">s3cr&lt;t</user_pass>`}},
+	}
+	for _, tt := range tests {
+		data, _, vendor, err := server.Render(tt.file, tt.userAgent)
+		if err != nil {
+			t.Fatalf("Render(%s) failed: %v", tt.file, err)
+		}
+		if vendor != tt.vendor {
+			t.Errorf("Render(%s): expected vendor %s, got %s", tt.file, tt.vendor, vendor)
+		}
+		for _, want := range tt.contains {
+			if !strings.Contains(string(data), want) {
+				t.Errorf("Render(%s): missing %q in:\n%s", tt.file, want, data)
+			}
+		}
+	}
+
+	for _, file := range []string{"cfg001122334455.xml", "cfgaabbccddeeff.xml", "index.html"} {
+		if _, _, _, err := server.Render(file, ""); err != errProvisioningNotFound {
+			t.Errorf("Render(%s): expected not found, got %v", file, err)
+		}
+	}
+}
+
+func TestProvisioningTemplateOverride(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "yealink.tmpl"), []byte("mac={{.MAC}} sip={{.Site.SIPServer}} lines={{len .Lines}}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	server := newTestProvisioningServer(t, ProvisioningSettings{TemplatesDir: dir})
+	data, _, _, err := server.Render("805ec0112233.cfg", "")
+	if err != nil || string(data) != "mac=805ec0112233 sip=192.168.1.10 lines=1\n" {
+		t.Errorf("unexpected override output %q (err %v)", data, err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "snom.tmpl"), []byte("{{.Broken"), 0644)
+	if _, err := NewProvisioningServer(ProvisioningSettings{TemplatesDir: dir}, nil); err == nil {
+		t.Error("expected error for invalid template")
+	}
+}
+
+func TestProvisioningHTTP(t *testing.T) {
+	server := newTestProvisioningServer(t, ProvisioningSettings{Username: "prov", Password: "pw"})
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	get := func(path, user, pass string) (int, string) {
+		req, _ := http.NewRequest("GET", ts.URL+path, nil)
+		if user != "" {
+			req.SetBasicAuth(user, pass)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	if status, _ := get("/cfg000b82112233.xml", "", ""); status != http.StatusUnauthorized {
+		t.Errorf("expected 401 without credentials, got %d", status)
+	}
+	if status, _ := get("/cfg000b82112233.xml", "prov", "wrong"); status != http.StatusUnauthorized {
+		t.Errorf("expected 401 with a wrong password, got %d", status)
+	}
+	if status, body := get("/cfg000b82112233.xml", "prov", "pw"); status != http.StatusOK || !strings.Contains(body, "<gs_provision") {
+		t.Errorf("expected config, got %d %q", status, body)
+	}
+	if status, _ := get("/cfgaabbccddeeff.xml", "prov", "pw"); status != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown MAC, got %d", status)
+	}
+
+	log := server.Log()
+	if len(log) != 4 || log[0].Status != http.StatusNotFound || log[1].MAC != "000b82112233" || log[1].Vendor != "grandstream" || log[1].Bytes == 0 {
+		t.Errorf("unexpected request log: %+v", log)
+	}
+}
+
+func TestProvisioningTFTP(t *testing.T) {
+	server := newTestProvisioningServer(t, ProvisioningSettings{})
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go server.serveTFTP(listener)
+
+	client, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.SetDeadline(time.Now().Add(5 * time.Second))
+
+	rrq := func(name string) {
+		packet := []byte{0, tftpOpRRQ}
+		packet = append(append(append(packet, name...), 0), "octet\x00"...)
+		client.WriteTo(packet, listener.LocalAddr())
+	}
+
+	rrq("cfg000b82112233.xml")
+	var received []byte
+	buf := make([]byte, 600)
+	for {
+		n, from, err := client.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("TFTP read failed: %v", err)
+		}
+		if binary.BigEndian.Uint16(buf) != tftpOpData {
+			t.Fatalf("expected DATA, got opcode %d", binary.BigEndian.Uint16(buf))
+		}
+		received = append(received, buf[4:n]...)
+		client.WriteTo([]byte{0, tftpOpAck, buf[2], buf[3]}, from)
+		if n-4 < tftpBlockSize {
+			break
+		}
+	}
+	expected, _, _, _ := server.Render("cfg000b82112233.xml", "")
+	if string(received) != string(expected) {
+		t.Errorf("TFTP transfer mismatch:\n%s", received)
+	}
+
+	rrq("cfgaabbccddeeff.xml")
+	n, _, err := client.ReadFrom(buf)
+	if err != nil || binary.BigEndian.Uint16(buf) != tftpOpError || binary.BigEndian.Uint16(buf[2:]) != tftpErrNotFound {
+		t.Errorf("expected file not found error, got %v (err %v)", buf[:n], err)
+	}
+
+	// With HTTP credentials configured TFTP must not hand out configs
+	authServer := newTestProvisioningServer(t, ProvisioningSettings{Username: "prov", Password: "pw"})
+	authListener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer authListener.Close()
+	go authServer.serveTFTP(authListener)
+	packet := append([]byte{0, tftpOpRRQ}, "cfg000b82112233.xml\x00octet\x00"...)
+	client.WriteTo(packet, authListener.LocalAddr())
+	n, _, err = client.ReadFrom(buf)
+	if err != nil || binary.BigEndian.Uint16(buf) != tftpOpError || binary.BigEndian.Uint16(buf[2:]) != tftpErrAccess {
+		t.Errorf("expected access violation with auth configured, got %v (err %v)", buf[:n], err)
+	}
+	if log := authServer.Log(); len(log) != 1 || log[0].Status != http.StatusUnauthorized {
+		t.Errorf("expected the refused request to be logged, got %+v", log)
+	}
+	settings := ProvisioningSettings{TFTPAddr: ":69", Host: "10.0.0.5", Username: "prov"}
+	if url := settings.TFTPURL(); url != "" {
+		t.Errorf("expected no TFTP URL with auth configured, got %s", url)
+	}
+}
+
+func TestProvisioningServerParams(t *testing.T) {
+	settings := ProvisioningSettings{HTTPAddr: ":8088", Host: "192.168.1.5", Username: "prov", Password: "pw"}
+	if url := settings.URL(); url != "http://192.168.1.5:8088/" {
+		t.Errorf("unexpected URL %s", url)
+	}
+
+	gs, err := ProvisioningServerParams("grandstream", settings)
+	if err != nil || gs[gsPConfigUpgradeVia] != "1" || gs[gsPConfigServerPath] != "192.168.1.5:8088" || gs[gsPConfigHTTPUser] != "prov" {
+		t.Errorf("unexpected GrandStream params %v (err %v)", gs, err)
+	}
+	snom, _ := ProvisioningServerParams("snom", settings)
+	if snom["setting_server"] != "http://prov:pw@192.168.1.5:8088/snom{mac}.htm" {
+		t.Errorf("unexpected Snom params %v", snom)
+	}
+	if _, err := ProvisioningServerParams("cisco", settings); err == nil {
+		t.Error("expected error for unsupported vendor")
+	}
+
+	// Yealink sets the URL through a config import, then presses AutoP
+	fake, phone := newFakeYealink(t)
+	note, err := PointPhoneAtProvisioning(phone, "yealink", settings)
+	if err != nil {
+		t.Fatalf("PointPhoneAtProvisioning failed: %v", err)
+	}
+	if !strings.Contains(note, "fetching") {
+		t.Errorf("unexpected note %q", note)
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if imported := ParseYealinkConfig(fake.imported); imported["static.auto_provision.server.url"] != "http://192.168.1.5:8088/" {
+		t.Errorf("unexpected imported config %v", imported)
+	}
+	if last := fake.keys[len(fake.keys)-1]; last != YLKeyAutoProvision {
+		t.Errorf("expected AutoP key, got %v", fake.keys)
+	}
+}
+
+func TestDHCPProvisioningSnippets(t *testing.T) {
+	snippets := DHCPProvisioningSnippets("http://10.0.0.5:8088/")
+	if len(snippets) != 3 {
+		t.Fatalf("expected 3 snippets, got %d", len(snippets))
+	}
+	hexURL := "68:74:74:70:3a:2f:2f:31:30:2e:30:2e:30:2e:35:3a:38:30:38:38:2f"
+	expected := map[string][]string{
+		"isc":     {`option tftp-server-name "http://10.0.0.5:8088/";`, "option provisioning-url code 160 = text;", "option vendor-encapsulated-options " + hexURL + ";"},
+		"dnsmasq": {`dhcp-option=66,"http://10.0.0.5:8088/"`, `dhcp-option=160,"http://10.0.0.5:8088/"`, "dhcp-option=43," + hexURL},
+		"kea":     {`"name": "tftp-server-name"`, `"code": 160`, `"data": "687474703a2f2f31302e302e302e353a383038382f"`},
+	}
+	for _, snippet := range snippets {
+		for _, want := range expected[snippet.Server] {
+			if !strings.Contains(snippet.Text, want) {
+				t.Errorf("%s snippet missing %q:\n%s", snippet.Server, want, snippet.Text)
+			}
+		}
+		if snippet.Server == "kea" {
+			var kea map[string]interface{}
+			if err := json.Unmarshal([]byte(snippet.Text), &kea); err != nil {
+				t.Errorf("Kea snippet is not valid JSON: %v", err)
+			}
+		}
+	}
+
+	hosts := ProvisioningHostCandidates(ProvisioningSettings{Host: "pbx.example.com"})
+	if len(hosts) == 0 || hosts[0] != "pbx.example.com" {
+		t.Errorf("configured host should come first, got %v", hosts)
+	}
+}
+
+func TestRolloutProvisioningServer(t *testing.T) {
+	fake, phone := newFakeYealink(t)
+	creds := map[string]string{"username": "admin", "password": "secret"}
+	targets := []ProvisioningRolloutTarget{
+		{IP: phone.ip, Vendor: "yealink", Credentials: creds},
+		{IP: "192.0.2.1", Vendor: "cisco", Credentials: creds},
+		{IP: phone.ip, Vendor: "yealink", Credentials: map[string]string{"username": "admin", "password": "wrong"}},
+	}
+	settings := ProvisioningSettings{HTTPAddr: ":8088", Host: "10.0.0.5"}
+	results := RolloutProvisioningServer(NewPhoneManager(NewAsteriskManager()), targets, settings)
+
+	if len(results) != 3 || !results[0].OK || results[1].OK || results[2].OK {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	if results[1].IP != "192.0.2.1" || !strings.Contains(results[1].Message, "unsupported vendor") {
+		t.Errorf("unexpected result for detection-only vendor: %+v", results[1])
+	}
+	if !strings.Contains(results[2].Message, "authentication failed") {
+		t.Errorf("expected authentication failure, got %+v", results[2])
+	}
+	if keys := fake.pressed(); len(keys) != 1 || keys[0] != YLKeyAutoProvision {
+		t.Errorf("expected a single AutoP key press, got %v", keys)
+	}
+
+	m := initialModel(nil, nil, false)
+	m.phoneCredentials = map[string]map[string]string{"192.168.1.20": creds, "192.168.1.10": creds, "192.168.1.30": {"username": "admin"}}
+	m.voipPhones = []PhoneInfo{{IP: "192.168.1.10", UserAgent: "Yealink SIP-T46S 66.86.0.15"}, {IP: "192.168.1.20", UserAgent: "Cisco/SPA504G"}}
+	got := m.provisioningRolloutTargets()
+	if len(got) != 2 || got[0].IP != "192.168.1.10" || got[0].Vendor != "yealink" || got[1].Vendor != "" {
+		t.Errorf("unexpected rollout targets: %+v", got)
+	}
+}
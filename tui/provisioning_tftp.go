@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// TFTP opcodes and error codes (RFC 1350)
+const (
+	tftpOpRRQ   = 1
+	tftpOpWRQ   = 2
+	tftpOpData  = 3
+	tftpOpAck   = 4
+	tftpOpError = 5
+
+	tftpErrNotFound     = 1
+	tftpErrAccess       = 2
+	tftpErrIllegalOp    = 4
+	tftpBlockSize       = 512
+	tftpRetries         = 5
+	tftpTimeout         = 2 * time.Second
+	tftpMaxRequestBytes = 516
+	tftpMaxTransfers    = 16
+)
+
+// serveTFTP answers read requests on conn until it is closed; writes are refused.
+// At most tftpMaxTransfers requests run at once, others are dropped and retried by the phone.
+func (ps *ProvisioningServer) serveTFTP(conn net.PacketConn) {
+	buf := make([]byte, tftpMaxRequestBytes)
+	transfers := make(chan struct{}, tftpMaxTransfers)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		packet := append([]byte(nil), buf[:n]...)
+		select {
+		case transfers <- struct{}{}:
+			go func() {
+				defer func() { <-transfers }()
+				ps.handleTFTPRequest(conn, addr, packet)
+			}()
+		default:
+		}
+	}
+}
+
+// handleTFTPRequest replies to a single TFTP request from its own transfer socket
+func (ps *ProvisioningServer) handleTFTPRequest(listener net.PacketConn, addr net.Addr, packet []byte) {
+	entry := ProvisioningLogEntry{Time: time.Now(), Protocol: "tftp", Remote: addr.String()}
+	if len(packet) < 4 {
+		return
+	}
+	opcode := binary.BigEndian.Uint16(packet)
+	if opcode != tftpOpRRQ {
+		if opcode == tftpOpWRQ {
+			listener.WriteTo(tftpErrorPacket(tftpErrAccess, "read-only server"), addr)
+		} else {
+			listener.WriteTo(tftpErrorPacket(tftpErrIllegalOp, "illegal operation"), addr)
+		}
+		return
+	}
+	name, _, _ := strings.Cut(string(packet[2:]), "\x00")
+	entry.File = name
+
+	// Configs hold SIP passwords, so TFTP must not bypass the HTTP credentials
+	if !ps.settings.TFTPConfigs() {
+		entry.Status = http.StatusUnauthorized
+		ps.record(entry)
+		listener.WriteTo(tftpErrorPacket(tftpErrAccess, "authentication required, use HTTP"), addr)
+		return
+	}
+
+	data, mac, vendor, err := ps.Render(name, "")
+	entry.MAC, entry.Vendor = mac, vendor
+	if err != nil {
+		entry.Status = http.StatusInternalServerError
+		if errors.Is(err, errProvisioningNotFound) {
+			entry.Status = http.StatusNotFound
+		}
+		listener.WriteTo(tftpErrorPacket(tftpErrNotFound, "file not found"), addr)
+		ps.record(entry)
+		return
+	}
+
+	// RFC 1350 transfers continue from a new port chosen by the server
+	conn, err := net.ListenPacket("udp", tftpTransferAddr(listener.LocalAddr()))
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	entry.Status = http.StatusOK
+	if err := sendTFTPData(conn, addr, data); err != nil {
+		entry.Status = http.StatusRequestTimeout
+	} else {
+		entry.Bytes = len(data)
+	}
+	ps.record(entry)
+}
+
+// tftpTransferAddr returns an ephemeral address on the same IP as the listener
+func tftpTransferAddr(listen net.Addr) string {
+	if udp, ok := listen.(*net.UDPAddr); ok && !udp.IP.IsUnspecified() {
+		return net.JoinHostPort(udp.IP.String(), "0")
+	}
+	return ":0"
+}
+
+// sendTFTPData sends data in 512-byte blocks, waiting for each block's ACK
+func sendTFTPData(conn net.PacketConn, addr net.Addr, data []byte) error {
+	ack := make([]byte, tftpMaxRequestBytes)
+	for block := 1; ; block++ {
+		start := (block - 1) * tftpBlockSize
+		end := start + tftpBlockSize
+		if end > len(data) {
+			end = len(data)
+		}
+		packet := make([]byte, 4, 4+end-start)
+		binary.BigEndian.PutUint16(packet, tftpOpData)
+		binary.BigEndian.PutUint16(packet[2:], uint16(block))
+		packet = append(packet, data[start:end]...)
+
+		acked := false
+		for attempt := 0; attempt < tftpRetries && !acked; attempt++ {
+			if _, err := conn.WriteTo(packet, addr); err != nil {
+				return err
+			}
+			conn.SetReadDeadline(time.Now().Add(tftpTimeout))
+			for {
+				n, from, err := conn.ReadFrom(ack)
+				if err != nil {
+					break // Timeout, resend the block
+				}
+				if from.String() != addr.String() || n < 4 {
+					continue
+				}
+				op := binary.BigEndian.Uint16(ack)
+				if op == tftpOpError {
+					return errors.New("transfer aborted by client")
+				}
+				if op == tftpOpAck && binary.BigEndian.Uint16(ack[2:]) == uint16(block) {
+					acked = true
+					break
+				}
+			}
+		}
+		if !acked {
+			return errors.New("timed out waiting for acknowledgement")
+		}
+		// A block shorter than 512 bytes, possibly empty, ends the transfer
+		if end-start < tftpBlockSize {
+			return nil
+		}
+	}
+}
+
+// tftpErrorPacket builds an ERROR packet
+func tftpErrorPacket(code uint16, message string) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.BigEndian, uint16(tftpOpError))
+	binary.Write(&b, binary.BigEndian, code)
+	b.WriteString(message)
+	b.WriteByte(0)
+	return b.Bytes()
+}
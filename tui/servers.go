@@ -0,0 +1,617 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// DefaultServersFile is the server inventory used by multi-server mode
+const DefaultServersFile = "/etc/rayanpbx/servers.json"
+
+// SSHTarget describes how to reach a remote PBX over SSH
+type SSHTarget struct {
+	Host    string `json:"host"`
+	Port    string `json:"port,omitempty"`
+	User    string `json:"user,omitempty"`
+	KeyFile string `json:"key_file,omitempty"`
+}
+
+// sshArgs returns the ssh options and destination, never prompting for passwords
+func (t *SSHTarget) sshArgs() []string {
+	args := []string{"-o", "BatchMode=yes", "-o", "ConnectTimeout=5"}
+	if t.Port != "" {
+		args = append(args, "-p", t.Port)
+	}
+	if t.KeyFile != "" {
+		args = append(args, "-i", t.KeyFile)
+	}
+	destination := t.Host
+	if t.User != "" {
+		destination = t.User + "@" + t.Host
+	}
+	return append(args, destination, "--")
+}
+
+// Command builds a command that runs name with args on the remote host
+func (t *SSHTarget) Command(name string, args ...string) *exec.Cmd {
+	quoted := []string{shellQuote(name)}
+	for _, arg := range args {
+		quoted = append(quoted, shellQuote(arg))
+	}
+	return exec.Command("ssh", append(t.sshArgs(), strings.Join(quoted, " "))...)
+}
+
+// ReadFile returns the content of a remote file, or os.ErrNotExist if it is missing
+func (t *SSHTarget) ReadFile(path string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := t.Command("sh", "-c", fmt.Sprintf("if [ -f %s ]; then cat %s; else exit 66; fi", shellQuote(path), shellQuote(path)))
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 66 {
+		return nil, os.ErrNotExist
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s on %s: %v %s", path, t.Host, err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
+
+// WriteFile replaces a remote file atomically with data
+func (t *SSHTarget) WriteFile(path string, data []byte) error {
+	tmp := path + ".rayanpbx-tmp"
+	cmd := t.Command("sh", "-c", fmt.Sprintf("cat > %s && mv %s %s", shellQuote(tmp), shellQuote(tmp), shellQuote(path)))
+	cmd.Stdin = bytes.NewReader(data)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to write %s on %s: %v %s", path, t.Host, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./=:,@%+", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// ServerProfile holds the connection settings of one PBX in the inventory
+type ServerProfile struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	DBHost      string     `json:"db_host,omitempty"`
+	DBPort      string     `json:"db_port,omitempty"`
+	DBDatabase  string     `json:"db_database,omitempty"`
+	DBUsername  string     `json:"db_username,omitempty"`
+	DBPassword  string     `json:"db_password,omitempty"`
+	AMIHost     string     `json:"ami_host,omitempty"`
+	AMIPort     string     `json:"ami_port,omitempty"`
+	AMIUsername string     `json:"ami_username,omitempty"`
+	AMISecret   string     `json:"ami_secret,omitempty"`
+	SSH         *SSHTarget `json:"ssh,omitempty"` // nil when Asterisk runs on this host
+}
+
+// IsRemote returns true if Asterisk on this server is managed over SSH
+func (p ServerProfile) IsRemote() bool {
+	return p.SSH != nil && p.SSH.Host != ""
+}
+
+// Config returns base with the database and AMI settings of the profile applied.
+// AMI defaults to the SSH host, since AMI usually listens on the PBX itself.
+func (p ServerProfile) Config(base *Config) *Config {
+	config := &Config{}
+	if base != nil {
+		*config = *base
+	}
+	override := func(dst *string, value string) {
+		if value != "" {
+			*dst = value
+		}
+	}
+	override(&config.DBHost, p.DBHost)
+	override(&config.DBPort, p.DBPort)
+	override(&config.DBDatabase, p.DBDatabase)
+	override(&config.DBUsername, p.DBUsername)
+	override(&config.DBPassword, p.DBPassword)
+	if p.IsRemote() {
+		override(&config.AMIHost, p.SSH.Host)
+	}
+	override(&config.AMIHost, p.AMIHost)
+	override(&config.AMIPort, p.AMIPort)
+	override(&config.AMIUsername, p.AMIUsername)
+	override(&config.AMISecret, p.AMISecret)
+	return config
+}
+
+// ServerInventory is the list of PBX servers managed from this TUI
+type ServerInventory struct {
+	Servers []ServerProfile `json:"servers"`
+}
+
+// serversFilePath returns the inventory path, overridable with RAYANPBX_SERVERS
+func serversFilePath() string {
+	return getEnv("RAYANPBX_SERVERS", DefaultServersFile)
+}
+
+// LoadServerInventory reads the inventory file. A missing file is an empty inventory.
+func LoadServerInventory(path string) (*ServerInventory, error) {
+	inventory := &ServerInventory{}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return inventory, nil
+	}
+	if err != nil {
+		return inventory, fmt.Errorf("failed to read server inventory: %v", err)
+	}
+	if err := json.Unmarshal(data, inventory); err != nil {
+		return &ServerInventory{}, fmt.Errorf("failed to parse server inventory %s: %v", path, err)
+	}
+
+	seen := make(map[string]bool)
+	for _, server := range inventory.Servers {
+		if server.Name == "" {
+			return &ServerInventory{}, fmt.Errorf("server inventory %s: every server needs a name", path)
+		}
+		if server.Name == localServerName {
+			return &ServerInventory{}, fmt.Errorf("server inventory %s: %q is reserved for this server", path, localServerName)
+		}
+		if seen[server.Name] {
+			return &ServerInventory{}, fmt.Errorf("server inventory %s: duplicate server name %q", path, server.Name)
+		}
+		seen[server.Name] = true
+	}
+	return inventory, nil
+}
+
+// Find returns the profile with the given name, or nil
+func (inv *ServerInventory) Find(name string) *ServerProfile {
+	for i := range inv.Servers {
+		if inv.Servers[i].Name == name {
+			return &inv.Servers[i]
+		}
+	}
+	return nil
+}
+
+// ServerConnection holds the database and managers of one connected server
+type ServerConnection struct {
+	Profile         ServerProfile
+	Config          *Config
+	DB              *sql.DB
+	AsteriskManager *AsteriskManager
+	ConfigManager   *AsteriskConfigManager
+	SyncManager     *ExtensionSyncManager
+}
+
+// ConnectServer connects to the database of a profile and builds its managers.
+// Config files of remote servers are mirrored into a local cache directory.
+func ConnectServer(profile ServerProfile, base *Config, verbose bool) (*ServerConnection, error) {
+	config := profile.Config(base)
+	db, err := ConnectDB(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database of %s: %v", profile.Name, err)
+	}
+
+	am := NewAsteriskManager()
+	acm := NewAsteriskConfigManager(verbose)
+	if profile.IsRemote() {
+		am = NewRemoteAsteriskManager(profile.SSH)
+		if err := acm.UseRemote(profile.SSH, serverMirrorDir(profile.Name)); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	syncManager := NewExtensionSyncManager(db, am, acm)
+	syncManager.pjsipConfigPath = acm.pjsipConfigPath
+
+	return &ServerConnection{
+		Profile:         profile,
+		Config:          config,
+		DB:              db,
+		AsteriskManager: am,
+		ConfigManager:   acm,
+		SyncManager:     syncManager,
+	}, nil
+}
+
+// Close closes the database connection
+func (sc *ServerConnection) Close() {
+	if sc.DB != nil {
+		sc.DB.Close()
+	}
+}
+
+// serverMirrorDir returns the local directory holding copies of a server's config files
+func serverMirrorDir(name string) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "rayanpbx", "servers", name)
+}
+
+// UseRemote points the config manager at local copies of a remote server's config
+// files. Changed copies are written back and PJSIP is reloaded over SSH by
+// ReloadAsterisk. TLS certificates are still generated locally.
+func (acm *AsteriskConfigManager) UseRemote(target *SSHTarget, mirrorDir string) error {
+	if err := os.MkdirAll(mirrorDir, 0700); err != nil {
+		return fmt.Errorf("failed to create config mirror: %v", err)
+	}
+	acm.remote = target
+	acm.remoteFiles = make(map[string]string)
+	acm.remoteSynced = make(map[string]string)
+	for _, path := range []*string{&acm.pjsipConfigPath, &acm.extensionsConfigPath, &acm.httpConfigPath, &acm.rtpConfigPath} {
+		remotePath := *path
+		localPath := filepath.Join(mirrorDir, filepath.Base(remotePath))
+		data, err := target.ReadFile(remotePath)
+		if err != nil && err != os.ErrNotExist {
+			return err
+		}
+		os.Remove(localPath)
+		if err == nil {
+			if err := os.WriteFile(localPath, data, 0600); err != nil {
+				return fmt.Errorf("failed to write config mirror: %v", err)
+			}
+		}
+		acm.remoteFiles[localPath] = remotePath
+		acm.remoteSynced[localPath] = string(data)
+		*path = localPath
+	}
+	return nil
+}
+
+// pushRemoteFiles writes mirrored config files that changed back to the remote server
+func (acm *AsteriskConfigManager) pushRemoteFiles() error {
+	locals := make([]string, 0, len(acm.remoteFiles))
+	for local := range acm.remoteFiles {
+		locals = append(locals, local)
+	}
+	sort.Strings(locals)
+	for _, local := range locals {
+		data, err := os.ReadFile(local)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read config mirror: %v", err)
+		}
+		if string(data) == acm.remoteSynced[local] {
+			continue
+		}
+		if err := acm.remote.WriteFile(acm.remoteFiles[local], data); err != nil {
+			return err
+		}
+		acm.remoteSynced[local] = string(data)
+	}
+	return nil
+}
+
+// reloadRemote pushes changed config files and reloads PJSIP on the remote server
+func (acm *AsteriskConfigManager) reloadRemote() error {
+	if err := acm.pushRemoteFiles(); err != nil {
+		return fmt.Errorf("failed to update config on %s: %v", acm.remote.Host, err)
+	}
+	output, err := acm.remote.Command("asterisk", "-rx", "module reload res_pjsip.so").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to reload asterisk on %s: %v %s", acm.remote.Host, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// ServerStatus is one row of the aggregated status view
+type ServerStatus struct {
+	Name                  string `json:"name"`
+	Remote                bool   `json:"remote"`
+	AsteriskUp            bool   `json:"asterisk_up"`
+	DatabaseUp            bool   `json:"database_up"`
+	EndpointsRegistered   int    `json:"endpoints_registered"`
+	EndpointsUnregistered int    `json:"endpoints_unregistered"`
+	ActiveChannels        int    `json:"active_channels"`
+	ActiveCalls           int    `json:"active_calls"`
+	TrunksRegistered      int    `json:"trunks_registered"`
+	TrunksTotal           int    `json:"trunks_total"`
+	Error                 string `json:"error,omitempty"`
+}
+
+// CollectServerStatus gathers registration and call counts from one server
+func CollectServerStatus(profile ServerProfile, base *Config) ServerStatus {
+	status := ServerStatus{Name: profile.Name, Remote: profile.IsRemote()}
+
+	if db, err := ConnectDB(profile.Config(base)); err == nil {
+		status.DatabaseUp = true
+		db.Close()
+	}
+
+	am := NewAsteriskManager()
+	if profile.IsRemote() {
+		am = NewRemoteAsteriskManager(profile.SSH)
+	}
+	if _, err := am.ExecuteCLICommand("core show uptime seconds"); err != nil {
+		status.Error = "Asterisk unreachable"
+		return status
+	}
+	status.AsteriskUp = true
+	if output, err := am.ExecuteCLICommand("core show channels count"); err == nil {
+		status.ActiveChannels, status.ActiveCalls = parseChannelsCount(output)
+	}
+	if output, err := am.ShowPeers(); err == nil {
+		for _, trunk := range ParseTrunkRegistrations(output) {
+			status.TrunksTotal++
+			if trunk.Registered() {
+				status.TrunksRegistered++
+			}
+		}
+	}
+	esm := NewExtensionSyncManager(nil, am, nil)
+	if live, err := esm.GetLiveAsteriskEndpoints(); err == nil {
+		for _, registered := range live {
+			if registered {
+				status.EndpointsRegistered++
+			} else {
+				status.EndpointsUnregistered++
+			}
+		}
+	}
+	return status
+}
+
+// CollectServerStatuses gathers the status of all servers in parallel, in inventory order
+func CollectServerStatuses(profiles []ServerProfile, base *Config) []ServerStatus {
+	statuses := make([]ServerStatus, len(profiles))
+	var wg sync.WaitGroup
+	for i, profile := range profiles {
+		wg.Add(1)
+		go func(i int, profile ServerProfile) {
+			defer wg.Done()
+			statuses[i] = CollectServerStatus(profile, base)
+		}(i, profile)
+	}
+	wg.Wait()
+	return statuses
+}
+
+// ServerStatusTotals sums the counts of reachable servers
+func ServerStatusTotals(statuses []ServerStatus) ServerStatus {
+	total := ServerStatus{Name: "Total"}
+	for _, s := range statuses {
+		if s.AsteriskUp {
+			total.AsteriskUp = true
+		}
+		total.EndpointsRegistered += s.EndpointsRegistered
+		total.EndpointsUnregistered += s.EndpointsUnregistered
+		total.ActiveChannels += s.ActiveChannels
+		total.ActiveCalls += s.ActiveCalls
+		total.TrunksRegistered += s.TrunksRegistered
+		total.TrunksTotal += s.TrunksTotal
+	}
+	return total
+}
+
+// localServerName is the switcher entry for the server the TUI was started on
+const localServerName = "local"
+
+// serverProfiles returns the switcher entries: this server first, then the inventory
+func (m model) serverProfiles() []ServerProfile {
+	profiles := []ServerProfile{{Name: localServerName, Description: "This server"}}
+	if m.serverInventory != nil {
+		profiles = append(profiles, m.serverInventory.Servers...)
+	}
+	return profiles
+}
+
+// initServers opens the server switcher
+func (m *model) initServers() {
+	m.currentScreen = serversScreen
+	m.errorMsg = ""
+	m.successMsg = ""
+	m.serverStatusView = false
+	m.selectedServerIdx = 0
+	for i, profile := range m.serverProfiles() {
+		if profile.Name == m.activeServerName() {
+			m.selectedServerIdx = i
+		}
+	}
+	if m.serverInventoryErr != nil {
+		m.errorMsg = m.serverInventoryErr.Error()
+	}
+}
+
+// activeServerName returns the name of the server being managed
+func (m model) activeServerName() string {
+	if m.serverConnection != nil {
+		return m.serverConnection.Profile.Name
+	}
+	return localServerName
+}
+
+// switchServer replaces the database and managers with those of the given profile
+func (m *model) switchServer(profile ServerProfile) {
+	if profile.Name == m.activeServerName() {
+		m.successMsg = fmt.Sprintf("Already managing %s", profile.Name)
+		return
+	}
+
+	var conn *ServerConnection
+	if profile.Name == localServerName {
+		conn = m.localConnection
+	} else {
+		var err error
+		conn, err = ConnectServer(profile, m.localConnectionConfig(), m.verbose)
+		if err != nil {
+			m.errorMsg = err.Error()
+			return
+		}
+	}
+
+	if m.localConnection == nil {
+		// Remember the original setup so we can switch back
+		m.localConnection = &ServerConnection{
+			Profile:         ServerProfile{Name: localServerName},
+			Config:          m.config,
+			DB:              m.db,
+			AsteriskManager: m.asteriskManager,
+			ConfigManager:   m.configManager,
+			SyncManager:     m.extensionSyncManager,
+		}
+	}
+	if m.serverConnection != nil {
+		m.serverConnection.Close()
+	}
+	if conn == m.localConnection {
+		m.serverConnection = nil
+	} else {
+		m.serverConnection = conn
+	}
+
+	m.db = conn.DB
+	m.config = conn.Config
+	m.asteriskManager = conn.AsteriskManager
+	m.configManager = conn.ConfigManager
+	m.extensionSyncManager = conn.SyncManager
+	m.diagnosticsManager.StopRTCPMonitor()
+	m.diagnosticsManager = NewDiagnosticsManager(conn.AsteriskManager)
+	m.diagnosticsManager.configManager = conn.ConfigManager
+	m.diagnosticsManager.db = conn.DB
+	m.diagnosticsManager.syncManager = conn.SyncManager
+	m.phoneManager = NewPhoneManager(conn.AsteriskManager)
+	m.phoneDiscovery = NewPhoneDiscovery(m.phoneManager)
+
+	// Lists of the previous server must not leak into the new one
+	m.extensions = nil
+	m.trunks = nil
+	m.extensionSyncInfos = nil
+	m.voipPhones = nil
+	m.selectedExtensionIdx = 0
+	m.extensionProfiles = nil
+	m.loadExtensionProfiles()
+
+	m.errorMsg = ""
+	m.successMsg = fmt.Sprintf("Now managing %s", profile.Name)
+}
+
+// localConnectionConfig returns the configuration the TUI was started with
+func (m model) localConnectionConfig() *Config {
+	if m.localConnection != nil {
+		return m.localConnection.Config
+	}
+	return m.config
+}
+
+// refreshServerStatuses collects the aggregated status of all servers
+func (m *model) refreshServerStatuses() {
+	m.serverStatuses = CollectServerStatuses(m.serverProfiles(), m.localConnectionConfig())
+	m.serverStatusView = true
+	m.errorMsg = ""
+	m.successMsg = fmt.Sprintf("Collected status of %d servers", len(m.serverStatuses))
+}
+
+// handleServersScreen handles keys on the server switcher
+func (m *model) handleServersScreen(msg tea.KeyMsg) (*model, tea.Cmd) {
+	profiles := m.serverProfiles()
+	switch msg.String() {
+	case "up", "k":
+		if m.selectedServerIdx > 0 {
+			m.selectedServerIdx--
+		} else {
+			m.selectedServerIdx = len(profiles) - 1
+		}
+	case "down", "j":
+		if m.selectedServerIdx < len(profiles)-1 {
+			m.selectedServerIdx++
+		} else {
+			m.selectedServerIdx = 0
+		}
+	case "enter":
+		if m.selectedServerIdx < len(profiles) {
+			m.switchServer(profiles[m.selectedServerIdx])
+		}
+	case "a", "r":
+		m.refreshServerStatuses()
+	case "l":
+		m.serverStatusView = false
+	case "esc":
+		m.errorMsg = ""
+		m.successMsg = ""
+		m.currentScreen = mainMenu
+		m.cursor = m.mainMenuCursor
+	case "q":
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// renderServers renders the server switcher or the aggregated status view
+func (m model) renderServers() string {
+	if m.serverStatusView {
+		return m.renderServerStatuses()
+	}
+
+	content := infoStyle.Render("🖥️  Servers") + "\n\n"
+	content += helpStyle.Render("Inventory: "+serversFilePath()) + "\n\n"
+	active := m.activeServerName()
+	for i, profile := range m.serverProfiles() {
+		cursor := " "
+		if i == m.selectedServerIdx {
+			cursor = "▶"
+		}
+		marker := "  "
+		if profile.Name == active {
+			marker = "🟢"
+		}
+		location := "local"
+		if profile.IsRemote() {
+			location = "ssh " + profile.SSH.Host
+		}
+		line := fmt.Sprintf("%s %s %-20s %-28s %s", cursor, marker, profile.Name, location, profile.Description)
+		if i == m.selectedServerIdx {
+			line = selectedItemStyle.Render(line)
+		}
+		content += line + "\n"
+	}
+	if m.serverInventory == nil || len(m.serverInventory.Servers) == 0 {
+		content += "\n" + helpStyle.Render("💡 Add servers to the inventory file to manage several PBX hosts") + "\n"
+	}
+	return menuStyle.Render(content)
+}
+
+// renderServerStatuses renders registration and call counts across all servers
+func (m model) renderServerStatuses() string {
+	content := infoStyle.Render("📊 All Servers") + "\n\n"
+	content += fmt.Sprintf("%-20s %-9s %-9s %-13s %-8s %-8s %s\n", "Server", "Asterisk", "Database", "Registered", "Calls", "Channels", "Trunks")
+	row := func(s ServerStatus, showUp bool) string {
+		asterisk, database := "-", "-"
+		if showUp {
+			asterisk, database = "🔴 down", "🔴 down"
+			if s.AsteriskUp {
+				asterisk = "🟢 up"
+			}
+			if s.DatabaseUp {
+				database = "🟢 up"
+			}
+		}
+		registered := fmt.Sprintf("%d/%d", s.EndpointsRegistered, s.EndpointsRegistered+s.EndpointsUnregistered)
+		trunks := fmt.Sprintf("%d/%d", s.TrunksRegistered, s.TrunksTotal)
+		return fmt.Sprintf("%-20s %-9s %-9s %-13s %-8d %-8d %s\n", s.Name, asterisk, database, registered, s.ActiveCalls, s.ActiveChannels, trunks)
+	}
+	for _, s := range m.serverStatuses {
+		line := row(s, true)
+		if !s.AsteriskUp {
+			line = errorStyle.Render(strings.TrimSuffix(line, "\n")) + "\n"
+		}
+		content += line
+	}
+	content += "\n" + row(ServerStatusTotals(m.serverStatuses), false)
+	return menuStyle.Render(content)
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// installFakeSSH puts fake ssh and asterisk commands on PATH. ssh runs the
+// remote command locally and asterisk answers the CLI commands used for status.
+func installFakeSSH(t *testing.T) string {
+	dir := t.TempDir()
+	scripts := map[string]string{
+		"ssh": `#!/bin/sh
+while [ "$1" != "--" ]; do shift; done
+shift
+exec sh -c "$*"
+`,
+		"asterisk": `#!/bin/sh
+echo "$*" >> "` + dir + `/asterisk.log"
+case "$2" in
+"core show uptime seconds") echo "System uptime: 100" ;;
+"core show channels count") printf "4 active channels\n2 active calls\n" ;;
+"pjsip show registrations") printf "=====\n provider/sip:sip.provider.com   provider-auth   Registered\n" ;;
+"pjsip show endpoints") printf "101   Not in use   0 of 1\n102   Unavailable   0 of 1\n" ;;
+esac
+`,
+	}
+	for name, script := range scripts {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return dir
+}
+
+// TestLoadServerInventory tests reading and validating the inventory file
+func TestLoadServerInventory(t *testing.T) {
+	dir := t.TempDir()
+	if inv, err := LoadServerInventory(filepath.Join(dir, "missing.json")); err != nil || len(inv.Servers) != 0 {
+		t.Errorf("Expected empty inventory for missing file, got %v (%v)", inv, err)
+	}
+
+	path := filepath.Join(dir, "servers.json")
+	os.WriteFile(path, []byte(`{"servers": [
+		{"name": "tehran", "db_host": "10.0.1.5", "db_password": "secret", "ssh": {"host": "10.0.1.5", "user": "root", "port": "2222"}},
+		{"name": "shiraz", "description": "Branch office", "db_host": "10.0.2.5", "ami_host": "10.0.2.6"}
+	]}`), 0600)
+	inv, err := LoadServerInventory(path)
+	if err != nil || len(inv.Servers) != 2 {
+		t.Fatalf("Expected 2 servers, got %v (%v)", inv, err)
+	}
+	tehran := inv.Find("tehran")
+	if tehran == nil || !tehran.IsRemote() || tehran.SSH.Port != "2222" || inv.Find("shiraz").IsRemote() || inv.Find("nope") != nil {
+		t.Errorf("Unexpected inventory: %+v", inv.Servers)
+	}
+
+	base := &Config{DBHost: "127.0.0.1", DBPort: "3306", DBDatabase: "rayanpbx", DBUsername: "rayanpbx", DBPassword: "local", AMIHost: "127.0.0.1", AMIPort: "5038"}
+	config := tehran.Config(base)
+	if config.DBHost != "10.0.1.5" || config.DBPassword != "secret" || config.DBDatabase != "rayanpbx" || config.AMIHost != "10.0.1.5" || config.AMIPort != "5038" {
+		t.Errorf("Unexpected profile config: %+v", config)
+	}
+	if config := inv.Find("shiraz").Config(base); config.AMIHost != "10.0.2.6" || config.DBPassword != "local" {
+		t.Errorf("Unexpected profile config: %+v", config)
+	}
+	if base.DBHost != "127.0.0.1" {
+		t.Error("Expected base config to be left unchanged")
+	}
+
+	for name, content := range map[string]string{
+		"duplicate": `{"servers": [{"name": "a"}, {"name": "a"}]}`,
+		"unnamed":   `{"servers": [{"db_host": "10.0.0.1"}]}`,
+		"reserved":  `{"servers": [{"name": "local"}]}`,
+		"invalid":   `{"servers": [`,
+	} {
+		os.WriteFile(path, []byte(content), 0600)
+		if _, err := LoadServerInventory(path); err == nil {
+			t.Errorf("Expected error for %s inventory", name)
+		}
+	}
+}
+
+// TestSSHTargetCommand tests building ssh command lines
+func TestSSHTargetCommand(t *testing.T) {
+	target := &SSHTarget{Host: "pbx1", User: "root", Port: "2222", KeyFile: "/root/.ssh/pbx"}
+	cmd := target.Command("asterisk", "-rx", "core show channels")
+	want := "ssh -o BatchMode=yes -o ConnectTimeout=5 -p 2222 -i /root/.ssh/pbx root@pbx1 -- asterisk -rx 'core show channels'"
+	if got := strings.Join(cmd.Args, " "); got != want {
+		t.Errorf("Unexpected command:\n%s\nwant:\n%s", got, want)
+	}
+
+	tests := map[string]string{
+		"plain":       "plain",
+		"/etc/a.conf": "/etc/a.conf",
+		"":            "''",
+		"two words":   "'two words'",
+		"it's":        `'it'\''s'`,
+		"$(reboot)":   "'$(reboot)'",
+		"a;b":         "'a;b'",
+	}
+	for in, want := range tests {
+		if got := shellQuote(in); got != want {
+			t.Errorf("shellQuote(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+// TestRemoteConfigMirror tests editing a remote server's config through the local mirror
+func TestRemoteConfigMirror(t *testing.T) {
+	fake := installFakeSSH(t)
+	remoteDir := t.TempDir()
+	remotePjsip := filepath.Join(remoteDir, "pjsip.conf")
+	os.WriteFile(remotePjsip, []byte("[transport-udp]\ntype=transport\n"), 0644)
+
+	acm := NewAsteriskConfigManager(false)
+	acm.pjsipConfigPath = remotePjsip
+	acm.extensionsConfigPath = filepath.Join(remoteDir, "extensions.conf")
+	acm.httpConfigPath = filepath.Join(remoteDir, "http.conf")
+	acm.rtpConfigPath = filepath.Join(remoteDir, "rtp.conf")
+
+	mirror := filepath.Join(t.TempDir(), "mirror")
+	if err := acm.UseRemote(&SSHTarget{Host: "pbx1"}, mirror); err != nil {
+		t.Fatalf("UseRemote failed: %v", err)
+	}
+	if acm.pjsipConfigPath != filepath.Join(mirror, "pjsip.conf") {
+		t.Fatalf("Expected mirrored pjsip.conf, got %s", acm.pjsipConfigPath)
+	}
+	if data, _ := os.ReadFile(acm.pjsipConfigPath); !strings.Contains(string(data), "transport-udp") {
+		t.Errorf("Expected remote content in mirror, got %q", data)
+	}
+	if _, err := os.Stat(acm.extensionsConfigPath); !os.IsNotExist(err) {
+		t.Error("Expected no mirror for a missing remote file")
+	}
+
+	ext := Extension{ExtensionNumber: "101", Name: "Alice", Secret: "pw", Context: "from-internal", Transport: "transport-udp", Enabled: true, MaxContacts: 1, Codecs: "ulaw"}
+	if err := acm.WritePjsipConfigSections(acm.GeneratePjsipEndpoint(ext), "Extension 101"); err != nil {
+		t.Fatalf("WritePjsipConfigSections failed: %v", err)
+	}
+	if data, _ := os.ReadFile(remotePjsip); strings.Contains(string(data), "[101]") {
+		t.Error("Expected remote file to be untouched before reload")
+	}
+	if err := acm.ReloadAsterisk(); err != nil {
+		t.Fatalf("ReloadAsterisk failed: %v", err)
+	}
+	if data, _ := os.ReadFile(remotePjsip); !strings.Contains(string(data), "[101]") || !strings.Contains(string(data), "transport-udp") {
+		t.Errorf("Expected pushed config on remote, got:\n%s", data)
+	}
+	if _, err := os.Stat(filepath.Join(remoteDir, "extensions.conf")); !os.IsNotExist(err) {
+		t.Error("Expected unchanged files not to be pushed")
+	}
+	if log, _ := os.ReadFile(filepath.Join(fake, "asterisk.log")); string(log) != "-rx module reload res_pjsip.so\n" {
+		t.Errorf("Unexpected remote asterisk calls: %q", log)
+	}
+}
+
+// TestCollectServerStatuses tests aggregating registration and call counts
+func TestCollectServerStatuses(t *testing.T) {
+	installFakeSSH(t)
+	base := &Config{DBHost: "127.0.0.1", DBPort: "1", DBDatabase: "rayanpbx", DBUsername: "u", DBPassword: "p"}
+	profiles := []ServerProfile{
+		{Name: "pbx1", SSH: &SSHTarget{Host: "pbx1"}},
+		{Name: "pbx2", SSH: &SSHTarget{Host: "pbx2"}},
+	}
+	statuses := CollectServerStatuses(profiles, base)
+	if len(statuses) != 2 || statuses[1].Name != "pbx2" {
+		t.Fatalf("Expected statuses in inventory order, got %+v", statuses)
+	}
+	s := statuses[0]
+	if !s.AsteriskUp || s.DatabaseUp || !s.Remote || s.ActiveCalls != 2 || s.ActiveChannels != 4 ||
+		s.EndpointsRegistered != 1 || s.EndpointsUnregistered != 1 || s.TrunksRegistered != 1 || s.TrunksTotal != 1 {
+		t.Errorf("Unexpected status: %+v", s)
+	}
+
+	total := ServerStatusTotals(append(statuses, ServerStatus{Name: "down", Error: "Asterisk unreachable"}))
+	if total.ActiveCalls != 4 || total.EndpointsRegistered != 2 || total.TrunksTotal != 2 {
+		t.Errorf("Unexpected totals: %+v", total)
+	}
+}
+
+// TestServerSwitcher tests the switcher screen and failed switches
+func TestServerSwitcher(t *testing.T) {
+	m := initialModel(nil, nil, false)
+	m.serverInventory = &ServerInventory{Servers: []ServerProfile{
+		{Name: "pbx1", Description: "Head office", DBHost: "127.0.0.1", DBPort: "1", SSH: &SSHTarget{Host: "pbx1"}},
+	}}
+	m.initServers()
+	if m.currentScreen != serversScreen || m.activeServerName() != localServerName {
+		t.Fatalf("Expected switcher on the local server, got screen %d and %s", m.currentScreen, m.activeServerName())
+	}
+	view := m.renderServers()
+	if !strings.Contains(view, "local") || !strings.Contains(view, "pbx1") || !strings.Contains(view, "ssh pbx1") {
+		t.Errorf("Unexpected switcher view:\n%s", view)
+	}
+
+	m.handleServersScreen(tea.KeyMsg{Type: tea.KeyDown})
+	m.handleServersScreen(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.serverConnection != nil || !strings.Contains(m.errorMsg, "failed to connect to database of pbx1") {
+		t.Errorf("Expected failed switch to keep the local server, got %q", m.errorMsg)
+	}
+
+	m.serverStatuses = []ServerStatus{{Name: "local", AsteriskUp: true, DatabaseUp: true, ActiveCalls: 3}}
+	m.serverStatusView = true
+	if view := m.renderServers(); !strings.Contains(view, "All Servers") || !strings.Contains(view, "Total") {
+		t.Errorf("Unexpected status view:\n%s", view)
+	}
+	m.handleServersScreen(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.currentScreen != mainMenu {
+		t.Error("Expected ESC to return to the main menu")
+	}
+}
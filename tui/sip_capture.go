@@ -0,0 +1,455 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SIP capture defaults
+const (
+	DefaultSIPCaptureFile     = "/tmp/rayanpbx-sip.pcap"
+	DefaultSIPCaptureSeconds  = 15
+	DefaultSIPCaptureIface    = "any"
+	sipCaptureFilter          = "port 5060"
+	pjsipLoggerHost           = "asterisk" // Local side of messages read from pjsip logger output
+	maxSIPCaptureSeconds      = 600
+	pcapMagicMicroseconds     = 0xa1b2c3d4
+	pcapMagicNanoseconds      = 0xa1b23c4d
+	pcapngBlockSectionHeader  = 0x0a0d0d0a
+	pcapngBlockInterface      = 0x00000001
+	pcapngBlockEnhancedPacket = 0x00000006
+	pcapngBlockSimplePacket   = 0x00000003
+	pcapngByteOrderMagic      = 0x1a2b3c4d
+)
+
+// Link layer types of the captures we understand
+const (
+	linkTypeNull     = 0
+	linkTypeEthernet = 1
+	linkTypeRaw      = 101
+	linkTypeLinuxSLL = 113
+	linkTypeIPv4     = 228
+	linkTypeIPv6     = 229
+)
+
+// SIPPacket is a SIP message seen on the wire or in pjsip logger output
+type SIPPacket struct {
+	Time      time.Time
+	Src       string // host:port
+	Dst       string // host:port
+	Transport string // UDP, TCP, TLS or WS as far as known
+	Message   *SIPMessage
+}
+
+// pcapPacket is a raw captured frame
+type pcapPacket struct {
+	time     time.Time
+	linkType uint32
+	data     []byte
+}
+
+// ReadSIPCapture reads SIP messages from a pcap or pcapng file
+func ReadSIPCapture(path string) ([]*SIPPacket, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read capture: %v", err)
+	}
+
+	packets, err := parsePcap(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*SIPPacket
+	for _, p := range packets {
+		result = append(result, decodeSIPPackets(p)...)
+	}
+	return result, nil
+}
+
+// parsePcap parses the frames of a classic pcap or pcapng capture
+func parsePcap(data []byte) ([]pcapPacket, error) {
+	if len(data) < 24 {
+		return nil, fmt.Errorf("capture file is too short")
+	}
+	if binary.LittleEndian.Uint32(data[0:4]) == pcapngBlockSectionHeader {
+		return parsePcapng(data)
+	}
+
+	var order binary.ByteOrder
+	nanos := false
+	switch {
+	case binary.LittleEndian.Uint32(data[0:4]) == pcapMagicMicroseconds:
+		order = binary.LittleEndian
+	case binary.BigEndian.Uint32(data[0:4]) == pcapMagicMicroseconds:
+		order = binary.BigEndian
+	case binary.LittleEndian.Uint32(data[0:4]) == pcapMagicNanoseconds:
+		order, nanos = binary.LittleEndian, true
+	case binary.BigEndian.Uint32(data[0:4]) == pcapMagicNanoseconds:
+		order, nanos = binary.BigEndian, true
+	default:
+		return nil, fmt.Errorf("not a pcap or pcapng file")
+	}
+	linkType := order.Uint32(data[20:24]) & 0x0fffffff
+
+	var packets []pcapPacket
+	for off := 24; off+16 <= len(data); {
+		sec := int64(order.Uint32(data[off : off+4]))
+		frac := int64(order.Uint32(data[off+4 : off+8]))
+		capLen := int(order.Uint32(data[off+8 : off+12]))
+		off += 16
+		if off+capLen > len(data) {
+			break // Truncated last packet, e.g. capture still running
+		}
+		if !nanos {
+			frac *= 1000
+		}
+		packets = append(packets, pcapPacket{time: time.Unix(sec, frac), linkType: linkType, data: data[off : off+capLen]})
+		off += capLen
+	}
+	return packets, nil
+}
+
+// parsePcapng parses the packet blocks of a pcapng capture
+func parsePcapng(data []byte) ([]pcapPacket, error) {
+	var order binary.ByteOrder = binary.LittleEndian
+	type iface struct {
+		linkType uint32
+		tsPerSec uint64 // Timestamp units per second
+	}
+	var ifaces []iface
+	var packets []pcapPacket
+
+	for off := 0; off+12 <= len(data); {
+		blockType := order.Uint32(data[off : off+4])
+		if blockType == pcapngBlockSectionHeader {
+			// Each section declares its own byte order
+			if binary.BigEndian.Uint32(data[off+8:off+12]) == pcapngByteOrderMagic {
+				order = binary.BigEndian
+			} else {
+				order = binary.LittleEndian
+			}
+			ifaces = nil
+		}
+		blockLen := int(order.Uint32(data[off+4 : off+8]))
+		if blockLen < 12 || off+blockLen > len(data) {
+			break
+		}
+		body := data[off+8 : off+blockLen-4]
+
+		switch blockType {
+		case pcapngBlockInterface:
+			if len(body) >= 8 {
+				ifc := iface{linkType: uint32(order.Uint16(body[0:2])), tsPerSec: 1000000}
+				// Look for the if_tsresol option
+				for opt := body[8:]; len(opt) >= 4; {
+					code, length := order.Uint16(opt[0:2]), int(order.Uint16(opt[2:4]))
+					if code == 0 || 4+length > len(opt) {
+						break
+					}
+					if code == 9 && length >= 1 {
+						res := opt[4]
+						if res&0x80 != 0 && res&0x7f < 64 {
+							ifc.tsPerSec = uint64(1) << (res & 0x7f)
+						} else if res&0x80 == 0 && res <= 19 {
+							ifc.tsPerSec = 1
+							for i := byte(0); i < res; i++ {
+								ifc.tsPerSec *= 10
+							}
+						}
+					}
+					opt = opt[4+(length+3)/4*4:]
+				}
+				ifaces = append(ifaces, ifc)
+			}
+		case pcapngBlockEnhancedPacket:
+			if len(body) >= 20 {
+				id := int(order.Uint32(body[0:4]))
+				ts := uint64(order.Uint32(body[4:8]))<<32 | uint64(order.Uint32(body[8:12]))
+				capLen := int(order.Uint32(body[12:16]))
+				if id < len(ifaces) && 20+capLen <= len(body) {
+					perSec := ifaces[id].tsPerSec
+					nsec := float64(ts%perSec) / float64(perSec) * 1e9
+					packets = append(packets, pcapPacket{
+						time:     time.Unix(int64(ts/perSec), int64(nsec)),
+						linkType: ifaces[id].linkType,
+						data:     body[20 : 20+capLen],
+					})
+				}
+			}
+		case pcapngBlockSimplePacket:
+			if len(body) >= 4 && len(ifaces) > 0 {
+				packets = append(packets, pcapPacket{linkType: ifaces[0].linkType, data: body[4:]})
+			}
+		}
+		off += blockLen
+	}
+
+	if len(ifaces) == 0 && len(packets) == 0 {
+		return nil, fmt.Errorf("pcapng file has no interfaces")
+	}
+	return packets, nil
+}
+
+// decodeSIPPackets extracts the SIP messages carried in a frame
+func decodeSIPPackets(p pcapPacket) []*SIPPacket {
+	ipData, ok := linkPayload(p.linkType, p.data)
+	if !ok || len(ipData) < 1 {
+		return nil
+	}
+
+	var src, dst net.IP
+	var proto byte
+	var payload []byte
+	switch ipData[0] >> 4 {
+	case 4:
+		if len(ipData) < 20 {
+			return nil
+		}
+		ihl := int(ipData[0]&0x0f) * 4
+		total := int(binary.BigEndian.Uint16(ipData[2:4]))
+		if ihl < 20 || total < ihl || total > len(ipData) {
+			total = len(ipData)
+		}
+		if binary.BigEndian.Uint16(ipData[6:8])&0x1fff != 0 {
+			return nil // Non-first fragments carry no transport header
+		}
+		proto = ipData[9]
+		src, dst = net.IP(ipData[12:16]), net.IP(ipData[16:20])
+		if ihl > total {
+			return nil
+		}
+		payload = ipData[ihl:total]
+	case 6:
+		if len(ipData) < 40 {
+			return nil
+		}
+		proto = ipData[6]
+		src, dst = net.IP(ipData[8:24]), net.IP(ipData[24:40])
+		end := 40 + int(binary.BigEndian.Uint16(ipData[4:6]))
+		if end > len(ipData) {
+			end = len(ipData)
+		}
+		payload = ipData[40:end]
+	default:
+		return nil
+	}
+
+	var srcPort, dstPort uint16
+	var transport string
+	switch proto {
+	case 17: // UDP
+		if len(payload) < 8 {
+			return nil
+		}
+		srcPort, dstPort = binary.BigEndian.Uint16(payload[0:2]), binary.BigEndian.Uint16(payload[2:4])
+		payload, transport = payload[8:], "UDP"
+	case 6: // TCP
+		if len(payload) < 20 {
+			return nil
+		}
+		srcPort, dstPort = binary.BigEndian.Uint16(payload[0:2]), binary.BigEndian.Uint16(payload[2:4])
+		offset := int(payload[12]>>4) * 4
+		if offset < 20 || offset > len(payload) {
+			return nil
+		}
+		payload, transport = payload[offset:], "TCP"
+	default:
+		return nil
+	}
+	if !looksLikeSIP(payload) {
+		return nil
+	}
+
+	srcAddr := net.JoinHostPort(src.String(), strconv.Itoa(int(srcPort)))
+	dstAddr := net.JoinHostPort(dst.String(), strconv.Itoa(int(dstPort)))
+
+	if transport == "UDP" {
+		msg, err := ParseSIPMessage(payload)
+		if err != nil {
+			return nil
+		}
+		return []*SIPPacket{{Time: p.time, Src: srcAddr, Dst: dstAddr, Transport: transport, Message: msg}}
+	}
+
+	// A TCP segment may carry several SIP messages
+	var result []*SIPPacket
+	reader := bufio.NewReader(bytes.NewReader(payload))
+	for {
+		msg, err := ReadSIPMessage(reader)
+		if err != nil {
+			break
+		}
+		result = append(result, &SIPPacket{Time: p.time, Src: srcAddr, Dst: dstAddr, Transport: transport, Message: msg})
+	}
+	return result
+}
+
+// linkPayload strips the link layer header of a frame
+func linkPayload(linkType uint32, data []byte) ([]byte, bool) {
+	switch linkType {
+	case linkTypeEthernet:
+		if len(data) < 14 {
+			return nil, false
+		}
+		etherType := binary.BigEndian.Uint16(data[12:14])
+		data = data[14:]
+		// Skip VLAN tags
+		for (etherType == 0x8100 || etherType == 0x88a8) && len(data) >= 4 {
+			etherType = binary.BigEndian.Uint16(data[2:4])
+			data = data[4:]
+		}
+		return data, etherType == 0x0800 || etherType == 0x86dd
+	case linkTypeLinuxSLL:
+		if len(data) < 16 {
+			return nil, false
+		}
+		etherType := binary.BigEndian.Uint16(data[14:16])
+		return data[16:], etherType == 0x0800 || etherType == 0x86dd
+	case linkTypeNull:
+		if len(data) < 4 {
+			return nil, false
+		}
+		return data[4:], true
+	case linkTypeRaw, linkTypeIPv4, linkTypeIPv6:
+		return data, true
+	}
+	return nil, false
+}
+
+// sipMethods are the request methods recognized when scanning captured payloads
+var sipMethods = []string{"INVITE", "ACK", "BYE", "CANCEL", "OPTIONS", "REGISTER", "PRACK", "SUBSCRIBE",
+	"NOTIFY", "PUBLISH", "INFO", "REFER", "MESSAGE", "UPDATE"}
+
+// looksLikeSIP checks whether a payload starts with a SIP request or status line
+func looksLikeSIP(payload []byte) bool {
+	payload = bytes.TrimLeft(payload, "\r\n")
+	if bytes.HasPrefix(payload, []byte("SIP/2.0 ")) {
+		return true
+	}
+	for _, method := range sipMethods {
+		if bytes.HasPrefix(payload, []byte(method+" ")) {
+			return true
+		}
+	}
+	return false
+}
+
+// pjsipLoggerHeader matches the header line pjsip set logger writes before each message
+var pjsipLoggerHeader = regexp.MustCompile(`<--- (Received|Transmitting) SIP (?:request|response) \(\d+ bytes\) (?:from|to) (\w+):(\S+) --->`)
+
+// pjsipLogTimestamp matches the timestamp of an Asterisk log line, e.g. [2025-11-29 10:15:01]
+var pjsipLogTimestamp = regexp.MustCompile(`^\[([^\]]+)\]`)
+
+// ParsePJSIPLogger reads SIP messages from `pjsip set logger on` output, as written
+// to the Asterisk console or the full log file
+func ParsePJSIPLogger(r io.Reader) ([]*SIPPacket, error) {
+	var packets []*SIPPacket
+	var current *SIPPacket
+	var lines []string
+
+	flush := func() {
+		if current == nil {
+			return
+		}
+		for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+			lines = lines[:len(lines)-1]
+		}
+		if msg, err := ParseSIPMessage([]byte(strings.Join(lines, "\r\n") + "\r\n")); err == nil {
+			current.Message = msg
+			packets = append(packets, current)
+		}
+		current, lines = nil, nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var lastTime time.Time
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		if m := pjsipLogTimestamp.FindStringSubmatch(line); m != nil {
+			if t, ok := parseAsteriskLogTime(m[1]); ok {
+				lastTime = t
+			}
+		}
+
+		if m := pjsipLoggerHeader.FindStringSubmatch(line); m != nil {
+			flush()
+			current = &SIPPacket{Time: lastTime, Transport: strings.ToUpper(m[2])}
+			if m[1] == "Received" {
+				current.Src, current.Dst = m[3], pjsipLoggerHost
+			} else {
+				current.Src, current.Dst = pjsipLoggerHost, m[3]
+			}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		if pjsipLogTimestamp.MatchString(line) && len(lines) > 0 {
+			// Next log entry ends the message
+			flush()
+			continue
+		}
+		lines = append(lines, line)
+	}
+	flush()
+
+	if err := scanner.Err(); err != nil {
+		return packets, fmt.Errorf("failed to read log: %v", err)
+	}
+	return packets, nil
+}
+
+// parseAsteriskLogTime parses the timestamp formats of Asterisk log files
+func parseAsteriskLogTime(value string) (time.Time, bool) {
+	for _, layout := range []string{"2006-01-02 15:04:05.000", "2006-01-02 15:04:05", "Jan _2 15:04:05.000", "Jan _2 15:04:05"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// ReadSIPLog reads SIP messages from a file with pjsip logger output
+func ReadSIPLog(path string) ([]*SIPPacket, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log: %v", err)
+	}
+	defer file.Close()
+	return ParsePJSIPLogger(file)
+}
+
+// CaptureSIP records SIP traffic on an interface to a pcap file using tcpdump (requires root)
+func CaptureSIP(iface string, seconds int, path string) error {
+	if seconds < 1 || seconds > maxSIPCaptureSeconds {
+		return fmt.Errorf("capture duration must be between 1 and %d seconds", maxSIPCaptureSeconds)
+	}
+	if _, err := exec.LookPath("tcpdump"); err != nil {
+		return fmt.Errorf("tcpdump is not installed, install it or load a pjsip logger log instead")
+	}
+
+	// -U writes each packet immediately, so the file is complete when timeout stops tcpdump
+	cmd := exec.Command("timeout", strconv.Itoa(seconds), "tcpdump", "-nn", "-U", "-s", "0",
+		"-i", iface, "-w", path, sipCaptureFilter)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		// timeout exits with 124 when it stopped tcpdump, which is the normal end of a capture
+		if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 124 {
+			return fmt.Errorf("failed to capture SIP traffic: %v (requires root/sudo): %s", err, strings.TrimSpace(string(output)))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,939 @@
+package main
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// SIP client defaults
+const (
+	DefaultSIPTLSPort   = 5061
+	DefaultSIPTransport = "udp"
+	sipT1               = 500 * time.Millisecond // RFC 3261 retransmission timer
+	sipT2               = 4 * time.Second
+	sipBranchMagic      = "z9hG4bK"
+	sipUserAgent        = "RayanPBX-TUI"
+	sipRingTimeout      = 30 * time.Second
+	sipCallHoldTime     = 2 * time.Second
+	sipTestTimeout      = 5 * time.Second // Per transaction in the TUI tests
+)
+
+// SIPResult is the outcome of one SIP transaction
+type SIPResult struct {
+	Method        string
+	StatusCode    int // Final status code, 0 on timeout
+	Reason        string
+	Provisional   []int         // Provisional status codes in the order received
+	FirstResponse time.Duration // Time to the first response, e.g. 100 Trying
+	Latency       time.Duration // Time to the final response
+	Authenticated bool          // The request was resent with digest credentials
+}
+
+// Success returns true for 2xx final responses
+func (r *SIPResult) Success() bool {
+	return r.StatusCode >= 200 && r.StatusCode < 300
+}
+
+// String formats the result for the TUI, e.g. "REGISTER: 200 OK (25ms, digest auth)"
+func (r *SIPResult) String() string {
+	status := "no response"
+	if r.StatusCode != 0 {
+		status = fmt.Sprintf("%d %s", r.StatusCode, r.Reason)
+	}
+	details := []string{formatSIPLatency(r.Latency)}
+	if len(r.Provisional) > 0 {
+		var codes []string
+		for _, code := range r.Provisional {
+			codes = append(codes, strconv.Itoa(code))
+		}
+		details = append(details, "after "+strings.Join(codes, ", "))
+	}
+	if r.Authenticated {
+		details = append(details, "digest auth")
+	}
+	return fmt.Sprintf("%s: %s (%s)", r.Method, status, strings.Join(details, ", "))
+}
+
+// formatSIPLatency formats a latency with millisecond precision
+func formatSIPLatency(d time.Duration) string {
+	return fmt.Sprintf("%.1fms", float64(d.Microseconds())/1000)
+}
+
+// SIPCallResult is the outcome of a test call
+type SIPCallResult struct {
+	Invite   *SIPResult
+	Answered bool
+	Bye      *SIPResult // Set when the call was answered
+	Cancel   *SIPResult // Set when the call rang out and was cancelled
+}
+
+// SIPClient is a minimal SIP user agent for probing a PBX over UDP, TCP or TLS
+type SIPClient struct {
+	Server    string // Resolved host:port of the PBX
+	Transport string // udp, tcp or tls
+	Domain    string // Host part of SIP URIs
+	Username  string
+	Password  string
+	Timeout   time.Duration // Timeout of non-INVITE transactions
+
+	conn       net.Conn
+	localAddr  *net.TCPAddr // Advertised in Via, Contact and SDP (UDP addresses use the same fields)
+	fromTag    string
+	registerID string // Call-ID of registrations, kept for refreshes and unregistering
+	cseq       int
+	mu         sync.Mutex
+	writeMu    sync.Mutex
+	responses  chan *SIPMessage
+	done       chan struct{}
+	closeOnce  sync.Once
+	received   []string // Methods of requests received from the server
+	autoAnswer bool     // Answer incoming INVITEs with 180 Ringing and 200 OK
+	rtpConn    net.PacketConn
+	rtpPackets int64
+}
+
+// resolveSIPServer adds the default port for the transport to a server address
+func resolveSIPServer(server, transport string) (string, error) {
+	server = strings.TrimSpace(server)
+	if server == "" {
+		return "", fmt.Errorf("server address is required")
+	}
+	host, port, err := net.SplitHostPort(server)
+	if err != nil {
+		host = strings.Trim(server, "[]")
+		port = DefaultSIPPort
+		if transport == "tls" {
+			port = strconv.Itoa(DefaultSIPTLSPort)
+		}
+	}
+	if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+		return "", fmt.Errorf("invalid port: %s", port)
+	}
+	if net.ParseIP(host) == nil && !isHostName(host) {
+		return "", fmt.Errorf("invalid server address: %s", host)
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// parseSIPTransport validates a transport name, defaulting to UDP
+func parseSIPTransport(value string) (string, error) {
+	transport := strings.ToLower(strings.TrimSpace(value))
+	switch transport {
+	case "":
+		return DefaultSIPTransport, nil
+	case "udp", "tcp", "tls":
+		return transport, nil
+	}
+	return "", fmt.Errorf("invalid transport %q (use udp, tcp or tls)", value)
+}
+
+// NewSIPClient connects to a SIP server
+func NewSIPClient(server, transport, username, password string, timeout time.Duration) (*SIPClient, error) {
+	transport, err := parseSIPTransport(transport)
+	if err != nil {
+		return nil, err
+	}
+	addr, err := resolveSIPServer(server, transport)
+	if err != nil {
+		return nil, err
+	}
+	host, _, _ := net.SplitHostPort(addr)
+
+	var conn net.Conn
+	switch transport {
+	case "udp":
+		conn, err = net.DialTimeout("udp", addr, timeout)
+	case "tcp":
+		conn, err = net.DialTimeout("tcp", addr, timeout)
+	case "tls":
+		// PBX certificates are often self-signed, they are checked by the TLS health checks instead
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: timeout}, "tcp", addr, &tls.Config{InsecureSkipVerify: true, ServerName: host})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s over %s: %v", addr, strings.ToUpper(transport), err)
+	}
+
+	local, _ := net.ResolveTCPAddr("tcp", conn.LocalAddr().String())
+	c := &SIPClient{
+		Server:     addr,
+		Transport:  transport,
+		Domain:     host,
+		Username:   username,
+		Password:   password,
+		Timeout:    timeout,
+		conn:       conn,
+		localAddr:  local,
+		fromTag:    randomSIPToken(8),
+		registerID: randomSIPToken(16),
+		responses:  make(chan *SIPMessage, 32),
+		done:       make(chan struct{}),
+	}
+	go c.readLoop()
+	return c, nil
+}
+
+// Close closes the connection to the server
+func (c *SIPClient) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		err = c.conn.Close()
+		c.mu.Lock()
+		if c.rtpConn != nil {
+			c.rtpConn.Close()
+		}
+		c.mu.Unlock()
+	})
+	return err
+}
+
+// LocalAddr returns the local address of the connection
+func (c *SIPClient) LocalAddr() string {
+	return c.conn.LocalAddr().String()
+}
+
+// SetAutoAnswer makes the client answer INVITEs from the server, e.g. as the callee of a test call
+func (c *SIPClient) SetAutoAnswer(answer bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.autoAnswer = answer
+}
+
+// Received returns the methods of requests the server sent to this client
+func (c *SIPClient) Received() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.received...)
+}
+
+// RTPPackets returns the number of RTP packets received on the media port
+func (c *SIPClient) RTPPackets() int64 {
+	return atomic.LoadInt64(&c.rtpPackets)
+}
+
+// readLoop dispatches messages from the server: responses to the waiting transaction,
+// requests to handleRequest
+func (c *SIPClient) readLoop() {
+	defer close(c.done)
+
+	var reader *bufio.Reader
+	if c.Transport != "udp" {
+		reader = bufio.NewReader(c.conn)
+	}
+	buf := make([]byte, 65535)
+
+	for {
+		var msg *SIPMessage
+		var err error
+		if reader != nil {
+			msg, err = ReadSIPMessage(reader)
+			if err != nil {
+				return
+			}
+		} else {
+			n, readErr := c.conn.Read(buf)
+			if errors.Is(readErr, syscall.ECONNREFUSED) {
+				// ICMP port unreachable, keep waiting so the transaction times out cleanly
+				continue
+			}
+			if readErr != nil {
+				return
+			}
+			if msg, err = ParseSIPMessage(buf[:n]); err != nil {
+				// Keep-alives and garbage
+				continue
+			}
+		}
+
+		if msg.IsRequest() {
+			c.handleRequest(msg)
+			continue
+		}
+		select {
+		case c.responses <- msg:
+		default:
+		}
+	}
+}
+
+// write sends a message to the server
+func (c *SIPClient) write(msg *SIPMessage) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(c.Timeout))
+	_, err := c.conn.Write(msg.Bytes())
+	return err
+}
+
+// handleRequest answers requests sent by the server, e.g. the INVITE of a test call
+func (c *SIPClient) handleRequest(req *SIPMessage) {
+	c.mu.Lock()
+	c.received = append(c.received, req.Method)
+	autoAnswer := c.autoAnswer
+	c.mu.Unlock()
+
+	switch req.Method {
+	case "ACK":
+		// No response to ACK
+	case "INVITE":
+		if !autoAnswer {
+			c.write(c.newResponse(req, 486, "Busy Here"))
+			return
+		}
+		c.write(c.newResponse(req, 180, "Ringing"))
+		ok := c.newResponse(req, 200, "OK")
+		ok.SetHeader("Contact", c.contact())
+		ok.SetHeader("Content-Type", "application/sdp")
+		ok.Body = c.sdp()
+		c.write(ok)
+	case "OPTIONS", "BYE", "CANCEL", "NOTIFY":
+		c.write(c.newResponse(req, 200, "OK"))
+	default:
+		c.write(c.newResponse(req, 501, "Not Implemented"))
+	}
+}
+
+// newResponse builds a response to a request received from the server
+func (c *SIPClient) newResponse(req *SIPMessage, code int, reason string) *SIPMessage {
+	resp := &SIPMessage{StatusCode: code, Reason: reason}
+	for _, via := range req.HeaderValues("Via") {
+		resp.AddHeader("Via", via)
+	}
+	resp.AddHeader("From", req.Header("From"))
+	to := req.Header("To")
+	if code > 100 && sipHeaderParam(to, "tag") == "" {
+		to += ";tag=" + c.fromTag
+	}
+	resp.AddHeader("To", to)
+	resp.AddHeader("Call-ID", req.CallID())
+	resp.AddHeader("CSeq", req.Header("CSeq"))
+	resp.AddHeader("User-Agent", sipUserAgent)
+	return resp
+}
+
+// uri returns a SIP URI in the server domain
+func (c *SIPClient) uri(user string) string {
+	host := c.Domain
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if user == "" {
+		return "sip:" + host
+	}
+	return fmt.Sprintf("sip:%s@%s", user, host)
+}
+
+// contact returns the Contact header value for this client
+func (c *SIPClient) contact() string {
+	return fmt.Sprintf("<sip:%s@%s;transport=%s>", c.Username, c.localAddr.String(), c.Transport)
+}
+
+// newRequest builds an out-of-dialog request
+func (c *SIPClient) newRequest(method, requestURI, to, callID string) *SIPMessage {
+	c.mu.Lock()
+	c.cseq++
+	cseq := c.cseq
+	c.mu.Unlock()
+
+	req := &SIPMessage{Method: method, RequestURI: requestURI}
+	req.AddHeader("Via", c.via())
+	req.AddHeader("Max-Forwards", "70")
+	req.AddHeader("From", fmt.Sprintf("<%s>;tag=%s", c.uri(c.Username), c.fromTag))
+	req.AddHeader("To", to)
+	req.AddHeader("Call-ID", callID)
+	req.AddHeader("CSeq", fmt.Sprintf("%d %s", cseq, method))
+	req.AddHeader("Contact", c.contact())
+	req.AddHeader("User-Agent", sipUserAgent)
+	return req
+}
+
+// via returns a Via header with a new branch
+func (c *SIPClient) via() string {
+	return fmt.Sprintf("SIP/2.0/%s %s;branch=%s%s;rport", strings.ToUpper(c.Transport), c.localAddr.String(), sipBranchMagic, randomSIPToken(12))
+}
+
+// resend prepares a request for sending again with a new branch and CSeq
+func (c *SIPClient) resend(req *SIPMessage) {
+	c.mu.Lock()
+	c.cseq++
+	cseq := c.cseq
+	c.mu.Unlock()
+	req.SetHeader("Via", c.via())
+	req.SetHeader("CSeq", fmt.Sprintf("%d %s", cseq, req.Method))
+}
+
+// transaction sends a request and waits up to wait for its final response.
+// Over UDP the request is retransmitted until a response arrives.
+func (c *SIPClient) transaction(req *SIPMessage, wait time.Duration) (*SIPMessage, *SIPResult, error) {
+	result := &SIPResult{Method: req.Method}
+	branch := sipViaBranch(req)
+	_, method := req.CSeq()
+
+	start := time.Now()
+	if err := c.write(req); err != nil {
+		return nil, result, fmt.Errorf("failed to send %s: %v", req.Method, err)
+	}
+
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+	interval := sipT1
+	retransmit := time.NewTimer(interval)
+	defer retransmit.Stop()
+	gotResponse := false
+
+	for {
+		select {
+		case resp := <-c.responses:
+			_, respMethod := resp.CSeq()
+			if sipViaBranch(resp) != branch || respMethod != method {
+				continue // Late response of an earlier transaction
+			}
+			if !gotResponse {
+				result.FirstResponse = time.Since(start)
+				gotResponse = true
+			}
+			if resp.StatusCode < 200 {
+				result.Provisional = append(result.Provisional, resp.StatusCode)
+				continue
+			}
+			result.StatusCode = resp.StatusCode
+			result.Reason = resp.Reason
+			result.Latency = time.Since(start)
+			return resp, result, nil
+		case <-retransmit.C:
+			if c.Transport == "udp" && !gotResponse {
+				c.write(req)
+				if interval *= 2; interval > sipT2 {
+					interval = sipT2
+				}
+			}
+			retransmit.Reset(interval)
+		case <-deadline.C:
+			result.Latency = time.Since(start)
+			if gotResponse {
+				return nil, result, fmt.Errorf("%s timed out after provisional response", req.Method)
+			}
+			return nil, result, fmt.Errorf("%s timed out, no response from %s", req.Method, c.Server)
+		case <-c.done:
+			return nil, result, fmt.Errorf("connection to %s closed", c.Server)
+		}
+	}
+}
+
+// authenticate adds digest credentials for a 401/407 challenge to a request
+func (c *SIPClient) authenticate(req, challenge *SIPMessage) error {
+	header, authHeader := "WWW-Authenticate", "Authorization"
+	if challenge.StatusCode == 407 {
+		header, authHeader = "Proxy-Authenticate", "Proxy-Authorization"
+	}
+	if c.Password == "" {
+		return fmt.Errorf("server requires authentication but no password was given")
+	}
+	ch, err := parseDigestChallenge(challenge.Header(header))
+	if err != nil {
+		return err
+	}
+	auth, err := ch.authorization(req.Method, req.RequestURI, c.Username, c.Password, randomSIPToken(8), 1)
+	if err != nil {
+		return err
+	}
+	c.resend(req)
+	req.SetHeader(authHeader, auth)
+	return nil
+}
+
+// request runs a non-INVITE transaction, answering one authentication challenge
+func (c *SIPClient) request(req *SIPMessage) (*SIPMessage, *SIPResult, error) {
+	resp, result, err := c.transaction(req, c.Timeout)
+	if err != nil || (resp.StatusCode != 401 && resp.StatusCode != 407) {
+		return resp, result, err
+	}
+	if err := c.authenticate(req, resp); err != nil {
+		return resp, result, err
+	}
+	resp, result, err = c.transaction(req, c.Timeout)
+	result.Authenticated = true
+	return resp, result, err
+}
+
+// Options sends an OPTIONS ping to the server
+func (c *SIPClient) Options() (*SIPResult, error) {
+	req := c.newRequest("OPTIONS", c.uri(""), fmt.Sprintf("<%s>", c.uri("")), randomSIPToken(16))
+	req.AddHeader("Accept", "application/sdp")
+	_, result, err := c.request(req)
+	return result, err
+}
+
+// Register registers the client's contact for expires seconds; 0 removes the registration
+func (c *SIPClient) Register(expires int) (*SIPResult, error) {
+	aor := fmt.Sprintf("<%s>", c.uri(c.Username))
+	req := c.newRequest("REGISTER", c.uri(""), aor, c.registerID)
+	req.AddHeader("Expires", strconv.Itoa(expires))
+	_, result, err := c.request(req)
+	return result, err
+}
+
+// Invite places a test call to target. An answered call is held for hold and then hung up,
+// a call still ringing after ringTimeout is cancelled.
+func (c *SIPClient) Invite(target string, ringTimeout, hold time.Duration) (*SIPCallResult, error) {
+	call := &SIPCallResult{}
+	requestURI := c.uri(target)
+	to := fmt.Sprintf("<%s>", requestURI)
+	req := c.newRequest("INVITE", requestURI, to, randomSIPToken(16))
+	req.AddHeader("Content-Type", "application/sdp")
+	req.AddHeader("Allow", "INVITE, ACK, CANCEL, BYE, OPTIONS")
+	req.Body = c.sdp()
+
+	authenticated := false
+	for {
+		resp, result, err := c.transaction(req, ringTimeout)
+		call.Invite = result
+		result.Authenticated = authenticated
+		if err != nil {
+			if len(result.Provisional) > 0 {
+				call.Cancel = c.cancel(req)
+			}
+			return call, err
+		}
+
+		if resp.StatusCode >= 300 {
+			// Non-2xx final responses are acknowledged within the INVITE transaction
+			c.write(c.ackRequest(req, resp, false))
+			if (resp.StatusCode == 401 || resp.StatusCode == 407) && !authenticated {
+				if err := c.authenticate(req, resp); err != nil {
+					return call, err
+				}
+				authenticated = true
+				continue
+			}
+			return call, nil
+		}
+
+		call.Answered = true
+		ack := c.ackRequest(req, resp, true)
+		if err := c.write(ack); err != nil {
+			return call, fmt.Errorf("failed to send ACK: %v", err)
+		}
+		time.Sleep(hold)
+
+		bye := c.inDialogRequest("BYE", ack)
+		_, call.Bye, err = c.transaction(bye, c.Timeout)
+		return call, err
+	}
+}
+
+// ackRequest builds the ACK for a final INVITE response. ACKs for 2xx are sent to the
+// remote target with a new branch, other ACKs reuse the INVITE branch.
+func (c *SIPClient) ackRequest(invite, resp *SIPMessage, success bool) *SIPMessage {
+	seq, _ := invite.CSeq()
+	ack := &SIPMessage{Method: "ACK", RequestURI: invite.RequestURI}
+	ack.AddHeader("Via", invite.Header("Via"))
+	if success {
+		ack.SetHeader("Via", c.via())
+		if contact := resp.Header("Contact"); contact != "" {
+			ack.RequestURI = sipContactURI(contact)
+		}
+	}
+	ack.AddHeader("Max-Forwards", "70")
+	ack.AddHeader("From", invite.Header("From"))
+	ack.AddHeader("To", resp.Header("To"))
+	ack.AddHeader("Call-ID", invite.CallID())
+	ack.AddHeader("CSeq", fmt.Sprintf("%d ACK", seq))
+	for _, auth := range []string{"Authorization", "Proxy-Authorization"} {
+		if v := invite.Header(auth); v != "" {
+			ack.AddHeader(auth, v)
+		}
+	}
+	ack.AddHeader("User-Agent", sipUserAgent)
+	return ack
+}
+
+// inDialogRequest builds a request within the dialog established by ack
+func (c *SIPClient) inDialogRequest(method string, ack *SIPMessage) *SIPMessage {
+	c.mu.Lock()
+	c.cseq++
+	cseq := c.cseq
+	c.mu.Unlock()
+
+	req := &SIPMessage{Method: method, RequestURI: ack.RequestURI}
+	req.AddHeader("Via", c.via())
+	req.AddHeader("Max-Forwards", "70")
+	req.AddHeader("From", ack.Header("From"))
+	req.AddHeader("To", ack.Header("To"))
+	req.AddHeader("Call-ID", ack.CallID())
+	req.AddHeader("CSeq", fmt.Sprintf("%d %s", cseq, method))
+	req.AddHeader("User-Agent", sipUserAgent)
+	return req
+}
+
+// cancel cancels a ringing INVITE and acknowledges the 487 that follows
+func (c *SIPClient) cancel(invite *SIPMessage) *SIPResult {
+	seq, _ := invite.CSeq()
+	req := &SIPMessage{Method: "CANCEL", RequestURI: invite.RequestURI}
+	req.AddHeader("Via", invite.Header("Via"))
+	req.AddHeader("Max-Forwards", "70")
+	req.AddHeader("From", invite.Header("From"))
+	req.AddHeader("To", invite.Header("To"))
+	req.AddHeader("Call-ID", invite.CallID())
+	req.AddHeader("CSeq", fmt.Sprintf("%d CANCEL", seq))
+	req.AddHeader("User-Agent", sipUserAgent)
+
+	result := &SIPResult{Method: "CANCEL"}
+	start := time.Now()
+	if err := c.write(req); err != nil {
+		return result
+	}
+
+	// Collect the 200 for the CANCEL and the 487 Request Terminated of the INVITE,
+	// which may arrive in either order
+	branch := sipViaBranch(invite)
+	timer := time.NewTimer(c.Timeout)
+	defer timer.Stop()
+	terminated := false
+	for result.StatusCode == 0 || !terminated {
+		select {
+		case resp := <-c.responses:
+			if sipViaBranch(resp) != branch || resp.StatusCode < 200 {
+				continue
+			}
+			switch _, method := resp.CSeq(); method {
+			case "CANCEL":
+				result.StatusCode = resp.StatusCode
+				result.Reason = resp.Reason
+				result.Latency = time.Since(start)
+			case "INVITE":
+				c.write(c.ackRequest(invite, resp, resp.StatusCode < 300))
+				terminated = true
+			}
+		case <-timer.C:
+			return result
+		case <-c.done:
+			return result
+		}
+	}
+	return result
+}
+
+// sipViaBranch returns the branch parameter of the top Via header
+func sipViaBranch(msg *SIPMessage) string {
+	via := strings.SplitN(msg.Header("Via"), ",", 2)[0]
+	return sipHeaderParam(via, "branch")
+}
+
+// sipContactURI returns the URI of a Contact header value
+func sipContactURI(contact string) string {
+	if start := strings.Index(contact, "<"); start >= 0 {
+		if end := strings.Index(contact[start:], ">"); end > 0 {
+			return contact[start+1 : start+end]
+		}
+	}
+	return strings.TrimSpace(strings.SplitN(contact, ";", 2)[0])
+}
+
+// sdp returns an audio offer/answer for the client's media port
+func (c *SIPClient) sdp() []byte {
+	ip := c.localAddr.IP
+	c.mu.Lock()
+	if c.rtpConn == nil {
+		// Listen on the advertised port so RTP sent by the PBX is counted, not rejected
+		if conn, err := net.ListenPacket("udp", net.JoinHostPort(ip.String(), "0")); err == nil {
+			c.rtpConn = conn
+			go func() {
+				buf := make([]byte, 2048)
+				for {
+					if _, _, err := conn.ReadFrom(buf); err != nil {
+						return
+					}
+					atomic.AddInt64(&c.rtpPackets, 1)
+				}
+			}()
+		}
+	}
+	port := 4000
+	if c.rtpConn != nil {
+		port = c.rtpConn.LocalAddr().(*net.UDPAddr).Port
+	}
+	c.mu.Unlock()
+
+	family := "IP4"
+	if ip.To4() == nil {
+		family = "IP6"
+	}
+	session := time.Now().Unix()
+	lines := []string{
+		"v=0",
+		fmt.Sprintf("o=- %d %d IN %s %s", session, session, family, ip),
+		"s=" + sipUserAgent,
+		fmt.Sprintf("c=IN %s %s", family, ip),
+		"t=0 0",
+		fmt.Sprintf("m=audio %d RTP/AVP 0 8 101", port),
+		"a=rtpmap:0 PCMU/8000",
+		"a=rtpmap:8 PCMA/8000",
+		"a=rtpmap:101 telephone-event/8000",
+		"a=fmtp:101 0-16",
+		"a=sendrecv",
+	}
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+// randomSIPToken returns a random hex string of n bytes for tags, branches and Call-IDs
+func randomSIPToken(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// sipDigestChallenge is a parsed WWW-Authenticate or Proxy-Authenticate header
+type sipDigestChallenge struct {
+	Realm     string
+	Nonce     string
+	Opaque    string
+	Algorithm string
+	QOP       string
+}
+
+// parseDigestChallenge parses a Digest challenge header value
+func parseDigestChallenge(header string) (*sipDigestChallenge, error) {
+	header = strings.TrimSpace(header)
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Digest ") {
+		return nil, fmt.Errorf("unsupported authentication scheme: %q", header)
+	}
+
+	params := make(map[string]string)
+	rest := header[7:]
+	for rest != "" {
+		rest = strings.TrimLeft(rest, " ,")
+		eq := strings.Index(rest, "=")
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		rest = strings.TrimLeft(rest[eq+1:], " ")
+		var value string
+		if strings.HasPrefix(rest, "\"") {
+			end := strings.Index(rest[1:], "\"")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quoted value in challenge")
+			}
+			value = rest[1 : end+1]
+			rest = rest[end+2:]
+		} else if comma := strings.Index(rest, ","); comma >= 0 {
+			value = strings.TrimSpace(rest[:comma])
+			rest = rest[comma:]
+		} else {
+			value = strings.TrimSpace(rest)
+			rest = ""
+		}
+		params[key] = value
+	}
+
+	ch := &sipDigestChallenge{
+		Realm:     params["realm"],
+		Nonce:     params["nonce"],
+		Opaque:    params["opaque"],
+		Algorithm: params["algorithm"],
+	}
+	if ch.Nonce == "" {
+		return nil, fmt.Errorf("digest challenge without nonce")
+	}
+	for _, qop := range strings.Split(params["qop"], ",") {
+		if strings.TrimSpace(qop) == "auth" {
+			ch.QOP = "auth"
+		}
+	}
+	return ch, nil
+}
+
+// authorization computes the Authorization header value for a request (RFC 2617 / RFC 8760)
+func (ch *sipDigestChallenge) authorization(method, uri, username, password, cnonce string, nc int) (string, error) {
+	var newHash func() hash.Hash
+	switch strings.ToUpper(ch.Algorithm) {
+	case "", "MD5":
+		newHash = md5.New
+	case "SHA-256":
+		newHash = sha256.New
+	default:
+		return "", fmt.Errorf("unsupported digest algorithm: %s", ch.Algorithm)
+	}
+	h := func(s string) string {
+		sum := newHash()
+		sum.Write([]byte(s))
+		return hex.EncodeToString(sum.Sum(nil))
+	}
+
+	ha1 := h(username + ":" + ch.Realm + ":" + password)
+	ha2 := h(method + ":" + uri)
+	ncValue := fmt.Sprintf("%08x", nc)
+
+	var response string
+	if ch.QOP != "" {
+		response = h(strings.Join([]string{ha1, ch.Nonce, ncValue, cnonce, ch.QOP, ha2}, ":"))
+	} else {
+		response = h(ha1 + ":" + ch.Nonce + ":" + ha2)
+	}
+
+	auth := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", response="%s"`,
+		username, ch.Realm, ch.Nonce, uri, response)
+	if ch.Algorithm != "" {
+		auth += ", algorithm=" + ch.Algorithm
+	}
+	if ch.Opaque != "" {
+		auth += fmt.Sprintf(`, opaque="%s"`, ch.Opaque)
+	}
+	if ch.QOP != "" {
+		auth += fmt.Sprintf(`, qop=%s, nc=%s, cnonce="%s"`, ch.QOP, ncValue, cnonce)
+	}
+	return auth, nil
+}
+
+// RunSIPRegisterTest checks that the server answers OPTIONS and that ext can register,
+// returning a report for the TUI
+func RunSIPRegisterTest(server, transport, ext, password string, timeout time.Duration) (string, bool) {
+	var out strings.Builder
+	out.WriteString("📞 SIP Registration Test\n")
+	out.WriteString("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n\n")
+
+	client, err := NewSIPClient(server, transport, ext, password, timeout)
+	if err != nil {
+		out.WriteString(fmt.Sprintf("❌ %v\n", err))
+		return out.String(), false
+	}
+	defer client.Close()
+	out.WriteString(fmt.Sprintf("🔌 Connected to %s over %s (local %s)\n", client.Server, strings.ToUpper(client.Transport), client.LocalAddr()))
+
+	if result, err := client.Options(); err != nil {
+		out.WriteString(fmt.Sprintf("⚠️  OPTIONS: %v\n", err))
+	} else {
+		out.WriteString(fmt.Sprintf("%s %s\n", sipResultIcon(result), result))
+	}
+
+	result, err := client.Register(60)
+	if err != nil {
+		out.WriteString(fmt.Sprintf("❌ REGISTER: %v\n", err))
+		return out.String(), false
+	}
+	out.WriteString(fmt.Sprintf("%s %s\n", sipResultIcon(result), result))
+	if !result.Success() {
+		out.WriteString("\n" + sipFailureHint(result.StatusCode) + "\n")
+		return out.String(), false
+	}
+
+	if result, err := client.Register(0); err != nil {
+		out.WriteString(fmt.Sprintf("⚠️  Unregister: %v\n", err))
+	} else {
+		out.WriteString(fmt.Sprintf("%s Unregister: %d %s\n", sipResultIcon(result), result.StatusCode, result.Reason))
+	}
+
+	out.WriteString(fmt.Sprintf("\n✅ Extension %s can register\n", ext))
+	return out.String(), true
+}
+
+// RunSIPCallTest registers both extensions, calls toExt from fromExt and lets the
+// callee answer automatically, returning a report for the TUI
+func RunSIPCallTest(server, transport, fromExt, fromPass, toExt, toPass string, timeout time.Duration) (string, bool) {
+	var out strings.Builder
+	out.WriteString("📲 SIP Call Test\n")
+	out.WriteString("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n\n")
+
+	callee, err := NewSIPClient(server, transport, toExt, toPass, timeout)
+	if err != nil {
+		out.WriteString(fmt.Sprintf("❌ %v\n", err))
+		return out.String(), false
+	}
+	defer callee.Close()
+	callee.SetAutoAnswer(true)
+
+	caller, err := NewSIPClient(server, transport, fromExt, fromPass, timeout)
+	if err != nil {
+		out.WriteString(fmt.Sprintf("❌ %v\n", err))
+		return out.String(), false
+	}
+	defer caller.Close()
+
+	for _, client := range []*SIPClient{callee, caller} {
+		result, err := client.Register(60)
+		if err != nil {
+			out.WriteString(fmt.Sprintf("❌ REGISTER %s: %v\n", client.Username, err))
+			return out.String(), false
+		}
+		out.WriteString(fmt.Sprintf("%s %s %s\n", sipResultIcon(result), client.Username, result))
+		if !result.Success() {
+			out.WriteString("\n" + sipFailureHint(result.StatusCode) + "\n")
+			return out.String(), false
+		}
+		defer client.Register(0)
+	}
+
+	call, err := caller.Invite(toExt, sipRingTimeout, sipCallHoldTime)
+	if call.Invite != nil {
+		out.WriteString(fmt.Sprintf("%s %s\n", sipResultIcon(call.Invite), call.Invite))
+		if call.Invite.FirstResponse > 0 {
+			out.WriteString(fmt.Sprintf("   First response after %s\n", formatSIPLatency(call.Invite.FirstResponse)))
+		}
+	}
+	if call.Cancel != nil {
+		out.WriteString(fmt.Sprintf("⚠️  No answer, call cancelled: %d %s\n", call.Cancel.StatusCode, call.Cancel.Reason))
+	}
+	if err != nil {
+		out.WriteString(fmt.Sprintf("❌ %v\n", err))
+		return out.String(), false
+	}
+
+	received := strings.Join(callee.Received(), ", ")
+	if received == "" {
+		received = "nothing"
+	}
+	out.WriteString(fmt.Sprintf("   Callee %s received: %s\n", toExt, received))
+
+	if !call.Answered {
+		out.WriteString("\n" + sipFailureHint(call.Invite.StatusCode) + "\n")
+		return out.String(), false
+	}
+	if call.Bye != nil {
+		out.WriteString(fmt.Sprintf("%s %s\n", sipResultIcon(call.Bye), call.Bye))
+	}
+	out.WriteString(fmt.Sprintf("   RTP packets received: caller %d, callee %d\n", caller.RTPPackets(), callee.RTPPackets()))
+
+	out.WriteString(fmt.Sprintf("\n✅ Call from %s to %s was answered\n", fromExt, toExt))
+	return out.String(), true
+}
+
+// sipResultIcon returns the status icon for a transaction result
+func sipResultIcon(result *SIPResult) string {
+	if result.Success() {
+		return "✅"
+	}
+	return "❌"
+}
+
+// sipFailureHint explains common failure codes
+func sipFailureHint(code int) string {
+	switch code {
+	case 401, 403, 407:
+		return "💡 Authentication failed: check the extension password and that the endpoint exists in pjsip.conf"
+	case 404:
+		return "💡 Not found: check the extension number and the dialplan context"
+	case 480, 408:
+		return "💡 Destination unavailable: the callee is not registered or did not answer"
+	case 486, 600:
+		return "💡 Destination busy"
+	case 488:
+		return "💡 Codec negotiation failed: allow ulaw or alaw on the endpoints"
+	case 0:
+		return "💡 No response: check the server address, transport and firewall"
+	}
+	return fmt.Sprintf("💡 Server responded with %d, check the Asterisk log for details", code)
+}
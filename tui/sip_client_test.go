@@ -0,0 +1,404 @@
+package main
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeUAS is a minimal registrar and UAS answering the SIP client under test
+type fakeUAS struct {
+	t        *testing.T
+	password string
+	nonce    string
+
+	mu       sync.Mutex
+	requests []string // Method and request URI user of every request received
+}
+
+// md5Hex returns the hex MD5 of s
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// checkAuth verifies the digest credentials of a request
+func (u *fakeUAS) checkAuth(req *SIPMessage, header string) bool {
+	auth := req.Header(header)
+	if auth == "" {
+		return false
+	}
+	params := make(map[string]string)
+	for _, part := range strings.Split(strings.TrimPrefix(auth, "Digest "), ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) == 2 {
+			params[kv[0]] = strings.Trim(kv[1], `"`)
+		}
+	}
+	ha1 := md5Hex(params["username"] + ":rayanpbx:" + u.password)
+	ha2 := md5Hex(req.Method + ":" + params["uri"])
+	want := md5Hex(strings.Join([]string{ha1, u.nonce, params["nc"], params["cnonce"], "auth", ha2}, ":"))
+	return params["nonce"] == u.nonce && params["response"] == want
+}
+
+// reply builds a response to req
+func (u *fakeUAS) reply(req *SIPMessage, code int, reason string) *SIPMessage {
+	resp := &SIPMessage{StatusCode: code, Reason: reason}
+	for _, via := range req.HeaderValues("Via") {
+		resp.AddHeader("Via", via)
+	}
+	resp.AddHeader("From", req.Header("From"))
+	to := req.Header("To")
+	if code > 100 && sipHeaderParam(to, "tag") == "" {
+		to += ";tag=uas"
+	}
+	resp.AddHeader("To", to)
+	resp.AddHeader("Call-ID", req.CallID())
+	resp.AddHeader("CSeq", req.Header("CSeq"))
+	return resp
+}
+
+// handle returns the responses to a request. INVITE targets select the behaviour:
+// 200 answers, 486 is busy and anything else keeps ringing until cancelled.
+func (u *fakeUAS) handle(req *SIPMessage) []*SIPMessage {
+	u.mu.Lock()
+	u.requests = append(u.requests, req.Method+" "+sipURIUser(req.RequestURI))
+	u.mu.Unlock()
+
+	switch req.Method {
+	case "OPTIONS", "BYE":
+		return []*SIPMessage{u.reply(req, 200, "OK")}
+	case "REGISTER":
+		if !u.checkAuth(req, "Authorization") {
+			resp := u.reply(req, 401, "Unauthorized")
+			resp.AddHeader("WWW-Authenticate", fmt.Sprintf(`Digest realm="rayanpbx", nonce="%s", qop="auth", algorithm=MD5`, u.nonce))
+			return []*SIPMessage{resp}
+		}
+		resp := u.reply(req, 200, "OK")
+		resp.AddHeader("Contact", req.Header("Contact")+";expires="+req.Header("Expires"))
+		return []*SIPMessage{resp}
+	case "INVITE":
+		if !u.checkAuth(req, "Proxy-Authorization") {
+			resp := u.reply(req, 407, "Proxy Authentication Required")
+			resp.AddHeader("Proxy-Authenticate", fmt.Sprintf(`Digest realm="rayanpbx", nonce="%s", qop="auth"`, u.nonce))
+			return []*SIPMessage{resp}
+		}
+		trying := u.reply(req, 100, "Trying")
+		switch sipURIUser(req.RequestURI) {
+		case "200":
+			ok := u.reply(req, 200, "OK")
+			ok.AddHeader("Contact", "<sip:200@127.0.0.1:5060>")
+			ok.AddHeader("Content-Type", "application/sdp")
+			ok.Body = []byte("v=0\r\n")
+			return []*SIPMessage{trying, u.reply(req, 180, "Ringing"), ok}
+		case "486":
+			return []*SIPMessage{trying, u.reply(req, 486, "Busy Here")}
+		}
+		return []*SIPMessage{trying, u.reply(req, 180, "Ringing")}
+	case "CANCEL":
+		// The 487 for the INVITE goes out before the 200 for the CANCEL
+		terminated := u.reply(req, 487, "Request Terminated")
+		terminated.SetHeader("CSeq", strings.Replace(req.Header("CSeq"), "CANCEL", "INVITE", 1))
+		return []*SIPMessage{terminated, u.reply(req, 200, "OK")}
+	}
+	return nil
+}
+
+// received returns the requests seen by the fake UAS
+func (u *fakeUAS) received() []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return append([]string(nil), u.requests...)
+}
+
+// startFakeUAS listens on UDP or TCP on localhost. The first TCP connection is passed to
+// onConn so tests can send requests to the client.
+func startFakeUAS(t *testing.T, transport string, onConn func(net.Conn)) (*fakeUAS, string) {
+	t.Helper()
+	uas := &fakeUAS{t: t, password: "s3cret", nonce: "abc123"}
+
+	if transport == "udp" {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		go func() {
+			buf := make([]byte, 65535)
+			for {
+				n, addr, err := conn.ReadFrom(buf)
+				if err != nil {
+					return
+				}
+				req, err := ParseSIPMessage(buf[:n])
+				if err != nil || !req.IsRequest() {
+					continue
+				}
+				for _, resp := range uas.handle(req) {
+					conn.WriteTo(resp.Bytes(), addr)
+				}
+			}
+		}()
+		return uas, conn.LocalAddr().String()
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if onConn != nil {
+			onConn(conn)
+			return
+		}
+		reader := bufio.NewReader(conn)
+		for {
+			req, err := ReadSIPMessage(reader)
+			if err != nil {
+				return
+			}
+			if !req.IsRequest() {
+				continue
+			}
+			for _, resp := range uas.handle(req) {
+				conn.Write(resp.Bytes())
+			}
+		}
+	}()
+	return uas, ln.Addr().String()
+}
+
+// TestSIPClientRegister tests OPTIONS and digest-authenticated REGISTER over UDP and TCP
+func TestSIPClientRegister(t *testing.T) {
+	for _, transport := range []string{"udp", "tcp"} {
+		t.Run(transport, func(t *testing.T) {
+			uas, addr := startFakeUAS(t, transport, nil)
+			client, err := NewSIPClient(addr, transport, "101", "s3cret", 2*time.Second)
+			if err != nil {
+				t.Fatalf("NewSIPClient failed: %v", err)
+			}
+			defer client.Close()
+
+			result, err := client.Options()
+			if err != nil || result.StatusCode != 200 {
+				t.Fatalf("OPTIONS failed: %v %+v", err, result)
+			}
+
+			result, err = client.Register(60)
+			if err != nil {
+				t.Fatalf("REGISTER failed: %v", err)
+			}
+			if !result.Success() || !result.Authenticated {
+				t.Errorf("Expected authenticated 200 OK, got %+v", result)
+			}
+			if result.Latency <= 0 || !strings.Contains(result.String(), "200 OK") {
+				t.Errorf("Unexpected result formatting: %s", result)
+			}
+
+			got := strings.Join(uas.received(), ",")
+			if got != "OPTIONS ,REGISTER ,REGISTER " {
+				t.Errorf("Unexpected requests: %s", got)
+			}
+
+			client.Password = "wrong"
+			if result, _ := client.Register(60); result.StatusCode != 401 {
+				t.Errorf("Expected 401 with a wrong password, got %d", result.StatusCode)
+			}
+		})
+	}
+}
+
+// TestSIPClientInvite tests test calls that are answered, busy and cancelled
+func TestSIPClientInvite(t *testing.T) {
+	uas, addr := startFakeUAS(t, "udp", nil)
+	client, err := NewSIPClient(addr, "udp", "101", "s3cret", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	call, err := client.Invite("200", 2*time.Second, 0)
+	if err != nil {
+		t.Fatalf("Invite failed: %v", err)
+	}
+	if !call.Answered || call.Invite.StatusCode != 200 || !call.Invite.Authenticated {
+		t.Errorf("Expected answered call, got %+v", call.Invite)
+	}
+	if fmt.Sprint(call.Invite.Provisional) != "[100 180]" {
+		t.Errorf("Expected 100 and 180 provisional responses, got %v", call.Invite.Provisional)
+	}
+	if call.Bye == nil || call.Bye.StatusCode != 200 {
+		t.Errorf("Expected BYE to be answered, got %+v", call.Bye)
+	}
+
+	call, err = client.Invite("486", 2*time.Second, 0)
+	if err != nil || call.Answered || call.Invite.StatusCode != 486 {
+		t.Errorf("Expected 486 Busy Here, got %+v (%v)", call.Invite, err)
+	}
+
+	call, err = client.Invite("ring", 700*time.Millisecond, 0)
+	if err == nil || call.Cancel == nil || call.Cancel.StatusCode != 200 {
+		t.Errorf("Expected ringing call to be cancelled, got %+v (%v)", call.Cancel, err)
+	}
+
+	// Every final INVITE response is acknowledged; the last ACK may still be in flight
+	acks := 0
+	for wait := 0; wait < 20 && acks != 6; wait++ {
+		time.Sleep(10 * time.Millisecond)
+		acks = 0
+		for _, req := range uas.received() {
+			if strings.HasPrefix(req, "ACK") {
+				acks++
+			}
+		}
+	}
+	if acks != 6 {
+		t.Errorf("Expected 6 ACKs (3 challenges, 200, 486 and 487), got %d: %v", acks, uas.received())
+	}
+}
+
+// TestSIPClientAutoAnswer tests answering an INVITE sent by the server over TCP
+func TestSIPClientAutoAnswer(t *testing.T) {
+	responses := make(chan *SIPMessage, 4)
+	ready := make(chan struct{})
+	_, addr := startFakeUAS(t, "tcp", func(conn net.Conn) {
+		<-ready
+		invite := &SIPMessage{Method: "INVITE", RequestURI: "sip:102@127.0.0.1"}
+		invite.AddHeader("Via", "SIP/2.0/TCP 127.0.0.1:5060;branch=z9hG4bKpbx")
+		invite.AddHeader("From", "<sip:101@127.0.0.1>;tag=pbx")
+		invite.AddHeader("To", "<sip:102@127.0.0.1>")
+		invite.AddHeader("Call-ID", "call-1")
+		invite.AddHeader("CSeq", "1 INVITE")
+		conn.Write(invite.Bytes())
+
+		reader := bufio.NewReader(conn)
+		for i := 0; i < 2; i++ {
+			resp, err := ReadSIPMessage(reader)
+			if err != nil {
+				break
+			}
+			responses <- resp
+		}
+		close(responses)
+	})
+
+	client, err := NewSIPClient(addr, "tcp", "102", "s3cret", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.SetAutoAnswer(true)
+	close(ready)
+
+	var codes []int
+	for resp := range responses {
+		codes = append(codes, resp.StatusCode)
+		if resp.StatusCode == 200 {
+			if sipHeaderParam(resp.Header("To"), "tag") == "" || !strings.Contains(string(resp.Body), "m=audio") {
+				t.Errorf("Expected To tag and SDP answer in 200 OK:\n%s", resp.Bytes())
+			}
+		}
+	}
+	if fmt.Sprint(codes) != "[180 200]" {
+		t.Errorf("Expected 180 and 200, got %v", codes)
+	}
+	if got := client.Received(); len(got) != 1 || got[0] != "INVITE" {
+		t.Errorf("Expected INVITE to be recorded, got %v", got)
+	}
+}
+
+// TestSIPClientTimeout tests a server that does not answer
+func TestSIPClientTimeout(t *testing.T) {
+	silent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+
+	client, err := NewSIPClient(silent.LocalAddr().String(), "udp", "101", "pw", 300*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if result, err := client.Options(); err == nil || result.StatusCode != 0 {
+		t.Errorf("Expected timeout, got %+v", result)
+	}
+
+	report, ok := RunSIPRegisterTest(silent.LocalAddr().String(), "udp", "101", "pw", 300*time.Millisecond)
+	if ok || !strings.Contains(report, "timed out") {
+		t.Errorf("Expected failed report, got:\n%s", report)
+	}
+}
+
+// TestDigestAuthorization tests the digest response against the RFC 2617 example
+func TestDigestAuthorization(t *testing.T) {
+	ch, err := parseDigestChallenge(`Digest realm="testrealm@host.com", qop="auth,auth-int", nonce="dcd98b7102dd2f0e8b11d0f600bfb0c093", opaque="5ccc069c403ebaf9f0171e9517f40e41"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth, err := ch.authorization("GET", "/dir/index.html", "Mufasa", "Circle Of Life", "0a4f113b", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(auth, `response="6629fae49393a05397450978507c4ef1"`) || !strings.Contains(auth, "nc=00000001") {
+		t.Errorf("Unexpected authorization: %s", auth)
+	}
+
+	if _, err := parseDigestChallenge(`Basic realm="x"`); err == nil {
+		t.Error("Expected error for Basic challenge")
+	}
+	ch.Algorithm = "SHA-512-256"
+	if _, err := ch.authorization("GET", "/", "a", "b", "c", 1); err == nil {
+		t.Error("Expected error for unsupported algorithm")
+	}
+}
+
+// TestParseSIPMessage tests parsing compact headers and folded lines
+func TestParseSIPMessage(t *testing.T) {
+	raw := "SIP/2.0 180 Ringing\r\nv: SIP/2.0/UDP 10.0.0.1;branch=z9hG4bK1\r\nf: <sip:101@pbx>;tag=a\r\nt: <sip:102@pbx>\r\n ;tag=b\r\ni: abc\r\nCSeq: 2 INVITE\r\nl: 0\r\n\r\n"
+	msg, err := ParseSIPMessage([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.StatusCode != 180 || msg.CallID() != "abc" || sipHeaderParam(msg.Header("To"), "tag") != "b" {
+		t.Errorf("Unexpected message: %+v", msg)
+	}
+	if seq, method := msg.CSeq(); seq != 2 || method != "INVITE" {
+		t.Errorf("Unexpected CSeq %d %s", seq, method)
+	}
+
+	// Oversized bodies are refused instead of allocated, on datagrams and streams
+	for _, cl := range []string{"999999999999999", "70000"} {
+		huge := "SIP/2.0 200 OK\r\nContent-Length: " + cl + "\r\n\r\n"
+		if _, err := ParseSIPMessage([]byte(huge)); err == nil {
+			t.Errorf("Expected error for Content-Length %s", cl)
+		}
+		if _, err := ReadSIPMessage(bufio.NewReader(strings.NewReader(huge))); err == nil {
+			t.Errorf("Expected stream error for Content-Length %s", cl)
+		}
+	}
+
+	for _, server := range []string{"pbx.local", "10.0.0.1:5080", "[::1]"} {
+		if _, err := resolveSIPServer(server, "udp"); err != nil {
+			t.Errorf("resolveSIPServer(%q) failed: %v", server, err)
+		}
+	}
+	if addr, _ := resolveSIPServer("pbx.local", "tls"); addr != "pbx.local:5061" {
+		t.Errorf("Expected TLS default port, got %s", addr)
+	}
+	if _, err := resolveSIPServer("bad host;rm", "udp"); err == nil {
+		t.Error("Expected error for invalid server")
+	}
+}
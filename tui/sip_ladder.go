@@ -0,0 +1,420 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Ladder layout
+const (
+	ladderColumnWidth = 24
+	ladderTimeWidth   = 10
+	ladderMaxHosts    = 6
+)
+
+// SIP ladder viewer form field indices
+const (
+	ladderFieldSource = iota
+	ladderFieldFile
+	ladderFieldInterface
+	ladderFieldSeconds
+)
+
+// SIP ladder viewer levels
+const (
+	ladderViewCalls = iota
+	ladderViewLadder
+	ladderViewMessage
+)
+
+// SIPCallFlow is the SIP messages of one Call-ID in capture order
+type SIPCallFlow struct {
+	CallID   string
+	Packets  []*SIPPacket
+	Hosts    []string // Endpoints in order of first appearance, the ladder columns
+	Method   string   // Method of the first request, e.g. INVITE or REGISTER
+	From     string   // User part of the From header
+	To       string   // User part of the To header
+	Status   string   // Last final response to the first request, e.g. "200 OK"
+	Requests int
+}
+
+// GroupSIPCallFlows groups captured messages by Call-ID, ordered by their first message
+func GroupSIPCallFlows(packets []*SIPPacket) []*SIPCallFlow {
+	byID := make(map[string]*SIPCallFlow)
+	var flows []*SIPCallFlow
+
+	sorted := append([]*SIPPacket(nil), packets...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time.Before(sorted[j].Time) })
+
+	for _, p := range sorted {
+		callID := p.Message.CallID()
+		if callID == "" {
+			continue
+		}
+		flow, ok := byID[callID]
+		if !ok {
+			flow = &SIPCallFlow{CallID: callID}
+			byID[callID] = flow
+			flows = append(flows, flow)
+		}
+		flow.Packets = append(flow.Packets, p)
+
+		for _, host := range []string{p.Src, p.Dst} {
+			if !containsString(flow.Hosts, host) {
+				flow.Hosts = append(flow.Hosts, host)
+			}
+		}
+
+		msg := p.Message
+		if msg.IsRequest() {
+			flow.Requests++
+			if flow.Method == "" {
+				flow.Method = msg.Method
+				flow.From = sipURIUser(msg.Header("From"))
+				flow.To = sipURIUser(msg.Header("To"))
+			}
+		} else if _, method := msg.CSeq(); method == flow.Method && msg.StatusCode >= 200 {
+			flow.Status = msg.Summary()
+		}
+	}
+	return flows
+}
+
+// containsString checks whether a slice contains a value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Summary returns a one-line description of the call flow for the call list
+func (f *SIPCallFlow) Summary() string {
+	status := f.Status
+	if status == "" {
+		status = "no final response"
+	}
+	start := ""
+	if len(f.Packets) > 0 && !f.Packets[0].Time.IsZero() {
+		start = f.Packets[0].Time.Format("15:04:05") + " "
+	}
+	return fmt.Sprintf("%s%-9s %s → %s  %s (%d messages)", start, f.Method, f.From, f.To, status, len(f.Packets))
+}
+
+// sipLadderLabel returns the arrow label of a message, e.g. "INVITE (SDP)" or "200 OK"
+func sipLadderLabel(msg *SIPMessage) string {
+	label := msg.Summary()
+	if strings.Contains(strings.ToLower(msg.Header("Content-Type")), "sdp") && len(msg.Body) > 0 {
+		label += " (SDP)"
+	}
+	return label
+}
+
+// RenderSIPLadder draws the call flow as an ASCII ladder diagram with one column per host.
+// The message at selected is highlighted with a marker.
+func RenderSIPLadder(flow *SIPCallFlow, selected int) string {
+	hosts := flow.Hosts
+	if len(hosts) > ladderMaxHosts {
+		hosts = hosts[:ladderMaxHosts]
+	}
+	column := make(map[string]int)
+	for i, host := range hosts {
+		column[host] = i
+	}
+	width := len(hosts) * ladderColumnWidth
+
+	var b strings.Builder
+
+	// Host names centered over their lanes
+	header := []rune(strings.Repeat(" ", width))
+	for i, host := range hosts {
+		name := []rune(host)
+		if len(name) > ladderColumnWidth-1 {
+			name = name[len(name)-(ladderColumnWidth-1):]
+		}
+		start := i*ladderColumnWidth + (ladderColumnWidth-len(name))/2
+		copy(header[start:], name)
+	}
+	b.WriteString(strings.Repeat(" ", ladderTimeWidth+2) + strings.TrimRight(string(header), " ") + "\n")
+
+	start := flow.Packets[0].Time
+	for i, p := range flow.Packets {
+		marker := "  "
+		if i == selected {
+			marker = "▶ "
+		}
+		offset := fmt.Sprintf("+%.3fs", p.Time.Sub(start).Seconds())
+		if p.Time.IsZero() || start.IsZero() {
+			offset = "#" + strconv.Itoa(i+1)
+		}
+
+		row := []rune(strings.Repeat(" ", width))
+		for c := range hosts {
+			row[c*ladderColumnWidth+ladderColumnWidth/2] = '│'
+		}
+
+		from, okFrom := column[p.Src]
+		to, okTo := column[p.Dst]
+		if okFrom && okTo && from != to {
+			left, right := from, to
+			if left > right {
+				left, right = right, left
+			}
+			l := left*ladderColumnWidth + ladderColumnWidth/2
+			r := right*ladderColumnWidth + ladderColumnWidth/2
+			for x := l + 1; x < r; x++ {
+				row[x] = '─'
+			}
+			if from < to {
+				row[r-1] = '>'
+			} else {
+				row[l+1] = '<'
+			}
+
+			// The label goes between the arrow ends, truncated to fit
+			label := []rune(" " + sipLadderLabel(p.Message) + " ")
+			space := r - l - 3
+			if len(label) > space && space > 3 {
+				label = append(label[:space-2], '…', ' ')
+			}
+			if len(label) <= space {
+				copy(row[l+2+(space-len(label))/2:], label)
+			}
+		}
+
+		line := fmt.Sprintf("%s%-*s%s", marker, ladderTimeWidth, offset, strings.TrimRight(string(row), " "))
+		if i == selected {
+			line = selectedItemStyle.Render(line)
+		}
+		b.WriteString(line + "\n")
+	}
+
+	if len(flow.Hosts) > ladderMaxHosts {
+		b.WriteString(helpStyle.Render(fmt.Sprintf("   %d more hosts not shown", len(flow.Hosts)-ladderMaxHosts)) + "\n")
+	}
+	return b.String()
+}
+
+// RenderSIPPacketDetail shows the full headers and body of a captured message
+func RenderSIPPacketDetail(p *SIPPacket) string {
+	var b strings.Builder
+	when := ""
+	if !p.Time.IsZero() {
+		when = p.Time.Format("2006-01-02 15:04:05.000") + "  "
+	}
+	b.WriteString(fmt.Sprintf("%s%s → %s (%s)\n\n", when, p.Src, p.Dst, p.Transport))
+
+	msg := p.Message
+	if msg.IsRequest() {
+		b.WriteString(fmt.Sprintf("%s %s SIP/2.0\n", msg.Method, msg.RequestURI))
+	} else {
+		b.WriteString(fmt.Sprintf("SIP/2.0 %d %s\n", msg.StatusCode, msg.Reason))
+	}
+	for _, h := range msg.Headers {
+		b.WriteString(fmt.Sprintf("%s: %s\n", h.Name, h.Value))
+	}
+	if len(msg.Body) > 0 {
+		b.WriteString("\n" + strings.ReplaceAll(strings.TrimRight(string(msg.Body), "\r\n"), "\r\n", "\n") + "\n")
+	}
+	return b.String()
+}
+
+// initSIPLadderViewer opens the SIP ladder viewer source form
+func (m *model) initSIPLadderViewer() {
+	m.currentScreen = sipLadderScreen
+	m.inputMode = true
+	m.inputFields = []string{
+		"Source (capture/pcap/log)",
+		"File",
+		"Interface",
+		"Capture seconds",
+	}
+	m.inputValues = []string{"capture", DefaultSIPCaptureFile, DefaultSIPCaptureIface, strconv.Itoa(DefaultSIPCaptureSeconds)}
+	m.inputCursor = 0
+	m.ladderFlows = nil
+	m.ladderView = ladderViewCalls
+	m.errorMsg = ""
+	m.successMsg = ""
+}
+
+// loadSIPLadder captures or reads SIP messages and groups them into call flows
+func (m *model) loadSIPLadder() {
+	source := strings.ToLower(strings.TrimSpace(m.inputValues[ladderFieldSource]))
+	path := strings.TrimSpace(m.inputValues[ladderFieldFile])
+	if path == "" {
+		m.errorMsg = "File is required"
+		return
+	}
+
+	var packets []*SIPPacket
+	var err error
+	switch source {
+	case "capture":
+		seconds, convErr := strconv.Atoi(strings.TrimSpace(m.inputValues[ladderFieldSeconds]))
+		if convErr != nil {
+			m.errorMsg = "Invalid capture duration"
+			return
+		}
+		iface := strings.TrimSpace(m.inputValues[ladderFieldInterface])
+		if iface == "" {
+			iface = DefaultSIPCaptureIface
+		}
+		if err = CaptureSIP(iface, seconds, path); err == nil {
+			packets, err = ReadSIPCapture(path)
+		}
+	case "pcap":
+		packets, err = ReadSIPCapture(path)
+	case "log":
+		packets, err = ReadSIPLog(path)
+	default:
+		m.errorMsg = "Source must be capture, pcap or log"
+		return
+	}
+	if err != nil {
+		m.errorMsg = err.Error()
+		return
+	}
+
+	m.ladderFlows = GroupSIPCallFlows(packets)
+	m.ladderFlowIdx = 0
+	m.ladderMsgIdx = 0
+	m.ladderView = ladderViewCalls
+	m.inputMode = false
+	if len(m.ladderFlows) == 0 {
+		m.errorMsg = fmt.Sprintf("No SIP messages found in %s", path)
+		return
+	}
+	m.successMsg = fmt.Sprintf("Loaded %d SIP messages in %d call flows", len(packets), len(m.ladderFlows))
+}
+
+// handleSIPLadderScreen processes keys while browsing call flows and messages
+func (m *model) handleSIPLadderScreen(msg tea.KeyMsg) (*model, tea.Cmd) {
+	count := len(m.ladderFlows)
+	cursor := &m.ladderFlowIdx
+	if m.ladderView != ladderViewCalls && m.ladderFlowIdx < len(m.ladderFlows) {
+		count = len(m.ladderFlows[m.ladderFlowIdx].Packets)
+		cursor = &m.ladderMsgIdx
+	}
+
+	switch msg.String() {
+	case "up", "k":
+		if *cursor > 0 {
+			*cursor--
+		} else if count > 0 {
+			*cursor = count - 1
+		}
+	case "down", "j":
+		if *cursor < count-1 {
+			*cursor++
+		} else {
+			*cursor = 0
+		}
+	case "enter":
+		if count == 0 {
+			break
+		}
+		if m.ladderView == ladderViewCalls {
+			m.ladderView = ladderViewLadder
+			m.ladderMsgIdx = 0
+		} else {
+			m.ladderView = ladderViewMessage
+		}
+	case "n":
+		// New capture or file
+		m.inputMode = true
+	case "esc":
+		m.errorMsg = ""
+		m.successMsg = ""
+		switch m.ladderView {
+		case ladderViewMessage:
+			m.ladderView = ladderViewLadder
+		case ladderViewLadder:
+			m.ladderView = ladderViewCalls
+		default:
+			m.currentScreen = diagnosticsMenuScreen
+			m.cursor = m.diagnosticsMenuCursor
+		}
+	case "q":
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// renderSIPLadder renders the SIP ladder viewer
+func (m model) renderSIPLadder() string {
+	content := infoStyle.Render("🪜 SIP Ladder Viewer") + "\n\n"
+
+	if m.inputMode {
+		fieldHelp := map[int]string{
+			ladderFieldSource:    "capture runs tcpdump, pcap reads a pcap/pcapng file, log reads pjsip logger output",
+			ladderFieldFile:      "Capture output, pcap file, or log such as /var/log/asterisk/full",
+			ladderFieldInterface: "Network interface for capture (any for all)",
+			ladderFieldSeconds:   "How long to capture; place test calls meanwhile",
+		}
+		for i, field := range m.inputFields {
+			cursor := "  "
+			fieldStyle := lipgloss.NewStyle()
+			if i == m.inputCursor {
+				cursor = "▶ "
+				fieldStyle = selectedItemStyle
+			}
+			value := m.inputValues[i]
+			if value == "" {
+				value = helpStyle.Render("<empty>")
+			}
+			content += fmt.Sprintf("%s%s: %s\n", cursor, fieldStyle.Render(field), value)
+			if i == m.inputCursor {
+				content += helpStyle.Render(fmt.Sprintf("   💡 %s", fieldHelp[i])) + "\n"
+			}
+		}
+		return menuStyle.Render(content)
+	}
+
+	if len(m.ladderFlows) == 0 {
+		content += "📭 No SIP call flows loaded\n"
+		return menuStyle.Render(content)
+	}
+
+	flow := m.ladderFlows[m.ladderFlowIdx]
+	switch m.ladderView {
+	case ladderViewLadder:
+		content += fmt.Sprintf("Call-ID: %s\n\n", flow.CallID)
+		content += RenderSIPLadder(flow, m.ladderMsgIdx)
+	case ladderViewMessage:
+		content += fmt.Sprintf("Message %d of %d, Call-ID: %s\n\n", m.ladderMsgIdx+1, len(flow.Packets), flow.CallID)
+		content += RenderSIPPacketDetail(flow.Packets[m.ladderMsgIdx])
+	default:
+		for i, f := range m.ladderFlows {
+			cursor := "  "
+			line := f.Summary()
+			if i == m.ladderFlowIdx {
+				cursor = "▶ "
+				line = selectedItemStyle.Render(line)
+			}
+			content += cursor + line + "\n"
+		}
+	}
+
+	return menuStyle.Render(content)
+}
+
+// sipLadderHelp returns the key help for the current viewer level
+func (m model) sipLadderHelp() string {
+	switch {
+	case m.inputMode:
+		return "↑/↓: Navigate Fields • Enter: Next/Load • ESC: Cancel • q: Quit"
+	case m.ladderView == ladderViewLadder:
+		return "↑/↓: Select Message • Enter: Show Headers/SDP • ESC: Back to Calls • q: Quit"
+	case m.ladderView == ladderViewMessage:
+		return "↑/↓: Previous/Next Message • ESC: Back to Ladder • q: Quit"
+	}
+	return "↑/↓: Navigate • Enter: Show Ladder • n: New Capture/File • ESC: Back to Diagnostics • q: Quit"
+}
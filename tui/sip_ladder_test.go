@@ -0,0 +1,239 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// TestReadSIPCapturePcap tests reading an Ethernet/UDP pcap with two call legs and a registration
+func TestReadSIPCapturePcap(t *testing.T) {
+	packets, err := ReadSIPCapture(filepath.Join("testdata", "sip_call.pcap"))
+	if err != nil {
+		t.Fatalf("ReadSIPCapture failed: %v", err)
+	}
+	// The RTP packet in the capture is skipped
+	if len(packets) != 21 {
+		t.Fatalf("Expected 21 SIP messages, got %d", len(packets))
+	}
+	first := packets[0]
+	if first.Src != "192.168.1.50:5060" || first.Dst != "192.168.1.10:5060" || first.Transport != "UDP" || first.Message.Method != "INVITE" {
+		t.Errorf("Unexpected first packet: %+v", first)
+	}
+	if first.Time.Unix() != 1732874400 {
+		t.Errorf("Unexpected timestamp %v", first.Time)
+	}
+
+	flows := GroupSIPCallFlows(packets)
+	if len(flows) != 3 {
+		t.Fatalf("Expected 3 call flows, got %d", len(flows))
+	}
+
+	tests := []struct {
+		callID   string
+		method   string
+		from, to string
+		status   string
+		messages int
+		hosts    int
+	}{
+		{"call-a@192.168.1.50", "INVITE", "101", "102", "200 OK", 10, 2},
+		{"call-b@192.168.1.10", "INVITE", "101", "102", "200 OK", 7, 2},
+		{"reg-1@192.168.1.51", "REGISTER", "102", "102", "200 OK", 4, 2},
+	}
+	for i, tt := range tests {
+		f := flows[i]
+		if f.CallID != tt.callID || f.Method != tt.method || f.From != tt.from || f.To != tt.to ||
+			f.Status != tt.status || len(f.Packets) != tt.messages || len(f.Hosts) != tt.hosts {
+			t.Errorf("Flow %d: got %s %s %s→%s %q %d messages %d hosts", i, f.CallID, f.Method, f.From, f.To, f.Status, len(f.Packets), len(f.Hosts))
+		}
+	}
+}
+
+// TestReadSIPCapturePcapng tests a pcapng capture with Linux cooked headers, TCP and
+// several SIP messages in one segment
+func TestReadSIPCapturePcapng(t *testing.T) {
+	packets, err := ReadSIPCapture(filepath.Join("testdata", "sip_options.pcapng"))
+	if err != nil {
+		t.Fatalf("ReadSIPCapture failed: %v", err)
+	}
+	if len(packets) != 4 {
+		t.Fatalf("Expected 4 SIP messages, got %d", len(packets))
+	}
+	if packets[0].Transport != "TCP" || packets[2].Message.StatusCode != 200 {
+		t.Errorf("Unexpected packets: %+v %+v", packets[0], packets[2])
+	}
+	// Nanosecond resolution from the if_tsresol option
+	if d := packets[2].Time.Sub(packets[0].Time); d != 1500*time.Microsecond {
+		t.Errorf("Expected 1.5ms between segments, got %v", d)
+	}
+	if flows := GroupSIPCallFlows(packets); len(flows) != 2 || flows[1].Status != "200 OK" {
+		t.Errorf("Unexpected flows: %d", len(flows))
+	}
+
+	if _, err := ReadSIPCapture(filepath.Join("testdata", "pjsip_logger.log")); err == nil {
+		t.Error("Expected error for a file that is not a capture")
+	}
+}
+
+// TestParsePJSIPLogger tests reading pjsip logger output from the Asterisk full log
+func TestParsePJSIPLogger(t *testing.T) {
+	packets, err := ReadSIPLog(filepath.Join("testdata", "pjsip_logger.log"))
+	if err != nil {
+		t.Fatalf("ReadSIPLog failed: %v", err)
+	}
+	if len(packets) != 3 {
+		t.Fatalf("Expected 3 SIP messages, got %d", len(packets))
+	}
+
+	invite := packets[0]
+	if invite.Src != "192.168.1.50:5060" || invite.Dst != pjsipLoggerHost || invite.Message.Method != "INVITE" {
+		t.Errorf("Unexpected INVITE packet: %+v", invite)
+	}
+	if !strings.Contains(string(invite.Message.Body), "c=IN IP4 192.168.1.50") {
+		t.Errorf("Expected SDP body, got %q", invite.Message.Body)
+	}
+	if packets[2].Dst != "192.168.1.50:5060" || packets[2].Message.StatusCode != 486 {
+		t.Errorf("Unexpected final packet: %+v", packets[2])
+	}
+	if d := packets[2].Time.Sub(invite.Time); d != 3380*time.Millisecond {
+		t.Errorf("Expected timestamps from the log, got %v", d)
+	}
+
+	flows := GroupSIPCallFlows(packets)
+	if len(flows) != 1 || flows[0].Status != "486 Busy Here" {
+		t.Errorf("Unexpected flows: %+v", flows)
+	}
+}
+
+// TestSIPCaptureOversizedMessages tests that messages with a huge Content-Length are
+// skipped without aborting the rest of the capture or log
+func TestSIPCaptureOversizedMessages(t *testing.T) {
+	packets, err := ReadSIPCapture(filepath.Join("testdata", "sip_oversized.pcap"))
+	if err != nil {
+		t.Fatalf("ReadSIPCapture failed: %v", err)
+	}
+	if len(packets) != 1 || packets[0].Message.CallID() != "ok-1" {
+		t.Errorf("Expected only the well-formed packet, got %d", len(packets))
+	}
+
+	packets, err = ReadSIPLog(filepath.Join("testdata", "pjsip_logger_oversized.log"))
+	if err != nil {
+		t.Fatalf("ReadSIPLog failed: %v", err)
+	}
+	if len(packets) != 1 || packets[0].Message.CallID() != "log-ok-1" {
+		t.Errorf("Expected only the well-formed message, got %d", len(packets))
+	}
+}
+
+// TestRenderSIPLadder tests the ASCII ladder layout
+func TestRenderSIPLadder(t *testing.T) {
+	packets, err := ReadSIPCapture(filepath.Join("testdata", "sip_call.pcap"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	flow := GroupSIPCallFlows(packets)[0]
+	ladder := RenderSIPLadder(flow, -1)
+	lines := strings.Split(strings.TrimRight(ladder, "\n"), "\n")
+	if len(lines) != 1+len(flow.Packets) {
+		t.Fatalf("Expected header and %d rows, got:\n%s", len(flow.Packets), ladder)
+	}
+	if !strings.Contains(lines[0], "192.168.1.50:5060") || !strings.Contains(lines[0], "192.168.1.10:5060") {
+		t.Errorf("Expected hosts in header: %q", lines[0])
+	}
+
+	tests := []struct {
+		row   int
+		label string
+		right bool
+	}{
+		{1, "INVITE (SDP)", true},
+		{2, "401 Unauthorized", false},
+		{6, "180 Ringing", false},
+	}
+	for _, tt := range tests {
+		row := lines[tt.row]
+		if !strings.Contains(row, tt.label) {
+			t.Errorf("Row %d: expected label %q in %q", tt.row, tt.label, row)
+		}
+		if tt.right != strings.Contains(row, "─>│") {
+			t.Errorf("Row %d: wrong arrow direction in %q", tt.row, row)
+		}
+		if !tt.right && !strings.Contains(row, "│<─") {
+			t.Errorf("Row %d: expected left arrow in %q", tt.row, row)
+		}
+	}
+	if !strings.HasPrefix(strings.TrimSpace(lines[2]), "+0.002s") {
+		t.Errorf("Expected time offset in %q", lines[2])
+	}
+
+	detail := RenderSIPPacketDetail(flow.Packets[0])
+	for _, want := range []string{"192.168.1.50:5060 → 192.168.1.10:5060 (UDP)", "INVITE sip:102@192.168.1.10 SIP/2.0", "Call-ID: call-a@192.168.1.50", "m=audio 4000 RTP/AVP 0 8"} {
+		if !strings.Contains(detail, want) {
+			t.Errorf("Expected %q in message detail:\n%s", want, detail)
+		}
+	}
+}
+
+// TestSIPLadderNavigation tests loading a capture and browsing flows in the TUI
+func TestSIPLadderNavigation(t *testing.T) {
+	m := initialModel(nil, nil, false)
+	m.initSIPLadderViewer()
+	m.inputValues[ladderFieldSource] = "pcap"
+	m.inputValues[ladderFieldFile] = filepath.Join("testdata", "sip_call.pcap")
+	m.loadSIPLadder()
+	if m.errorMsg != "" || m.inputMode || len(m.ladderFlows) != 3 {
+		t.Fatalf("Expected 3 flows loaded, got %d (%s)", len(m.ladderFlows), m.errorMsg)
+	}
+
+	keys := func(names ...string) {
+		for _, name := range names {
+			key := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(name)}
+			switch name {
+			case "enter":
+				key = tea.KeyMsg{Type: tea.KeyEnter}
+			case "esc":
+				key = tea.KeyMsg{Type: tea.KeyEsc}
+			case "down":
+				key = tea.KeyMsg{Type: tea.KeyDown}
+			}
+			m.handleSIPLadderScreen(key)
+		}
+	}
+
+	keys("down", "enter")
+	if m.ladderView != ladderViewLadder || m.ladderFlowIdx != 1 {
+		t.Fatalf("Expected ladder of second flow, got view %d flow %d", m.ladderView, m.ladderFlowIdx)
+	}
+	keys("down", "down", "enter")
+	if m.ladderView != ladderViewMessage || m.ladderMsgIdx != 2 {
+		t.Fatalf("Expected third message detail, got view %d message %d", m.ladderView, m.ladderMsgIdx)
+	}
+	if view := m.renderSIPLadder(); !strings.Contains(view, "SIP/2.0 180 Ringing") {
+		t.Errorf("Expected message detail in view:\n%s", view)
+	}
+	keys("esc", "esc", "esc")
+	if m.currentScreen != diagnosticsMenuScreen {
+		t.Errorf("Expected diagnostics menu after leaving the viewer, got %v", m.currentScreen)
+	}
+
+	m.initSIPLadderViewer()
+	m.inputValues[ladderFieldSource] = "log"
+	m.inputValues[ladderFieldFile] = filepath.Join(t.TempDir(), "missing.log")
+	m.loadSIPLadder()
+	if m.errorMsg == "" || !m.inputMode {
+		t.Error("Expected error for missing log file")
+	}
+
+	empty := filepath.Join(t.TempDir(), "empty.log")
+	os.WriteFile(empty, []byte("no sip here\n"), 0644)
+	m.inputValues[ladderFieldFile] = empty
+	m.loadSIPLadder()
+	if !strings.Contains(m.errorMsg, "No SIP messages") {
+		t.Errorf("Expected no messages error, got %q", m.errorMsg)
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// sipMaxBodySize caps the Content-Length accepted from the network, UDP datagrams are
+// never larger than this either
+const sipMaxBodySize = 64 * 1024
+
+// sipCompactHeaders maps compact header forms (RFC 3261 section 7.3.3) to their full names
+var sipCompactHeaders = map[string]string{
+	"i": "Call-ID",
+	"m": "Contact",
+	"e": "Content-Encoding",
+	"l": "Content-Length",
+	"c": "Content-Type",
+	"f": "From",
+	"s": "Subject",
+	"k": "Supported",
+	"t": "To",
+	"v": "Via",
+}
+
+// SIPHeader is a single SIP header line
+type SIPHeader struct {
+	Name  string
+	Value string
+}
+
+// SIPMessage is a parsed SIP request or response
+type SIPMessage struct {
+	Method     string // Request method, empty for responses
+	RequestURI string
+	StatusCode int // Response status code, 0 for requests
+	Reason     string
+	Headers    []SIPHeader
+	Body       []byte
+}
+
+// IsRequest returns true for SIP requests
+func (msg *SIPMessage) IsRequest() bool {
+	return msg.Method != ""
+}
+
+// Header returns the first value of a header, matching compact forms case-insensitively
+func (msg *SIPMessage) Header(name string) string {
+	for _, h := range msg.Headers {
+		if strings.EqualFold(h.Name, name) {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+// HeaderValues returns all values of a header, in message order
+func (msg *SIPMessage) HeaderValues(name string) []string {
+	var values []string
+	for _, h := range msg.Headers {
+		if strings.EqualFold(h.Name, name) {
+			values = append(values, h.Value)
+		}
+	}
+	return values
+}
+
+// SetHeader replaces all values of a header with a single value
+func (msg *SIPMessage) SetHeader(name, value string) {
+	msg.DelHeader(name)
+	msg.AddHeader(name, value)
+}
+
+// AddHeader appends a header
+func (msg *SIPMessage) AddHeader(name, value string) {
+	msg.Headers = append(msg.Headers, SIPHeader{Name: name, Value: value})
+}
+
+// DelHeader removes all values of a header
+func (msg *SIPMessage) DelHeader(name string) {
+	headers := msg.Headers[:0]
+	for _, h := range msg.Headers {
+		if !strings.EqualFold(h.Name, name) {
+			headers = append(headers, h)
+		}
+	}
+	msg.Headers = headers
+}
+
+// CallID returns the Call-ID header
+func (msg *SIPMessage) CallID() string {
+	return msg.Header("Call-ID")
+}
+
+// CSeq returns the sequence number and method of the CSeq header
+func (msg *SIPMessage) CSeq() (int, string) {
+	fields := strings.Fields(msg.Header("CSeq"))
+	if len(fields) != 2 {
+		return 0, ""
+	}
+	seq, _ := strconv.Atoi(fields[0])
+	return seq, strings.ToUpper(fields[1])
+}
+
+// Summary returns the request line method or the response status, e.g. "INVITE" or "200 OK"
+func (msg *SIPMessage) Summary() string {
+	if msg.IsRequest() {
+		return msg.Method
+	}
+	return fmt.Sprintf("%d %s", msg.StatusCode, msg.Reason)
+}
+
+// Bytes serializes the message, setting Content-Length from the body
+func (msg *SIPMessage) Bytes() []byte {
+	var buf bytes.Buffer
+	if msg.IsRequest() {
+		fmt.Fprintf(&buf, "%s %s SIP/2.0\r\n", msg.Method, msg.RequestURI)
+	} else {
+		fmt.Fprintf(&buf, "SIP/2.0 %d %s\r\n", msg.StatusCode, msg.Reason)
+	}
+	for _, h := range msg.Headers {
+		if strings.EqualFold(h.Name, "Content-Length") {
+			continue
+		}
+		fmt.Fprintf(&buf, "%s: %s\r\n", h.Name, h.Value)
+	}
+	fmt.Fprintf(&buf, "Content-Length: %d\r\n\r\n", len(msg.Body))
+	buf.Write(msg.Body)
+	return buf.Bytes()
+}
+
+// ParseSIPMessage parses a single SIP message from a datagram or buffer
+func ParseSIPMessage(data []byte) (*SIPMessage, error) {
+	return readSIPMessage(bufio.NewReader(bytes.NewReader(data)), true)
+}
+
+// ReadSIPMessage reads one SIP message from a stream, using Content-Length to find its end.
+// Keep-alive CRLFs between messages are skipped.
+func ReadSIPMessage(r *bufio.Reader) (*SIPMessage, error) {
+	return readSIPMessage(r, false)
+}
+
+// readSIPMessage reads one SIP message. Datagrams may omit Content-Length, then the body
+// is the rest of the packet; on streams a missing Content-Length means no body.
+func readSIPMessage(r *bufio.Reader, datagram bool) (*SIPMessage, error) {
+	var startLine string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil && (err != io.EOF || strings.TrimSpace(line) == "") {
+			return nil, err
+		}
+		if startLine = strings.TrimRight(line, "\r\n"); startLine != "" {
+			break
+		}
+	}
+
+	msg := &SIPMessage{}
+	if strings.HasPrefix(startLine, "SIP/2.0 ") {
+		parts := strings.SplitN(startLine, " ", 3)
+		code, err := strconv.Atoi(parts[1])
+		if err != nil || code < 100 || code > 699 {
+			return nil, fmt.Errorf("invalid SIP status line: %q", startLine)
+		}
+		msg.StatusCode = code
+		if len(parts) == 3 {
+			msg.Reason = parts[2]
+		}
+	} else {
+		parts := strings.Fields(startLine)
+		if len(parts) != 3 || parts[2] != "SIP/2.0" {
+			return nil, fmt.Errorf("invalid SIP request line: %q", startLine)
+		}
+		msg.Method = strings.ToUpper(parts[0])
+		msg.RequestURI = parts[1]
+	}
+
+	for {
+		line, err := r.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			if err != nil && err != io.EOF {
+				return nil, err
+			}
+			break
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(msg.Headers) > 0 {
+			// Folded header continuation
+			last := &msg.Headers[len(msg.Headers)-1]
+			last.Value += " " + strings.TrimSpace(line)
+		} else if idx := strings.Index(line, ":"); idx > 0 {
+			name := strings.TrimSpace(line[:idx])
+			if full, ok := sipCompactHeaders[strings.ToLower(name)]; ok {
+				name = full
+			}
+			msg.AddHeader(name, strings.TrimSpace(line[idx+1:]))
+		} else {
+			return nil, fmt.Errorf("invalid SIP header line: %q", line)
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	if cl := msg.Header("Content-Length"); cl != "" {
+		length, err := strconv.Atoi(cl)
+		if err != nil || length < 0 {
+			return nil, fmt.Errorf("invalid Content-Length: %q", cl)
+		}
+		if length > sipMaxBodySize {
+			return nil, fmt.Errorf("Content-Length %d exceeds the %d byte limit", length, sipMaxBodySize)
+		}
+		msg.Body = make([]byte, length)
+		if _, err := io.ReadFull(r, msg.Body); err != nil {
+			return nil, fmt.Errorf("failed to read SIP body: %v", err)
+		}
+	} else if datagram {
+		msg.Body, _ = io.ReadAll(r)
+	}
+
+	return msg, nil
+}
+
+// sipHeaderParam returns a parameter of a header value, e.g. the tag of a From header
+func sipHeaderParam(value, param string) string {
+	// Skip the URI, its parameters are inside angle brackets
+	if idx := strings.LastIndex(value, ">"); idx >= 0 {
+		value = value[idx+1:]
+	}
+	for _, part := range strings.Split(value, ";")[1:] {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if strings.EqualFold(kv[0], param) {
+			if len(kv) == 2 {
+				return kv[1]
+			}
+			return ""
+		}
+	}
+	return ""
+}
+
+// sipURIUser returns the user part of the SIP URI in a header value or request URI
+func sipURIUser(value string) string {
+	if start := strings.Index(value, "<"); start >= 0 {
+		value = value[start+1:]
+		if end := strings.Index(value, ">"); end >= 0 {
+			value = value[:end]
+		}
+	}
+	value = strings.TrimPrefix(strings.TrimPrefix(value, "sips:"), "sip:")
+	if at := strings.Index(value, "@"); at >= 0 {
+		return value[:at]
+	}
+	return ""
+}
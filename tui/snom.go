@@ -0,0 +1,270 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Snom web interface endpoints
+const (
+	snomCommandPath  = "/command.htm"
+	snomSettingsPath = "/settings.htm"
+	snomSavePath     = "/dummy.htm"
+	snomRebootPath   = "/advanced_update.htm?reboot=Reboot"
+	snomMaxLines     = 12
+)
+
+// Snom key events, sent as /command.htm?key=<KEY>
+const (
+	SnomKeyAccept   = "ENTER"
+	SnomKeyReject   = "CANCEL"
+	SnomKeyEndCall  = "ONHOOK"
+	SnomKeyHold     = "F_HOLD" // Toggles hold on the active call
+	SnomKeyTransfer = "F_TRANSFER"
+)
+
+// SnomPhone implements VoIPPhone for Snom D3xx/D7xx devices
+type SnomPhone struct {
+	phoneWebClient
+}
+
+// NewSnomPhone creates a new Snom phone instance
+func NewSnomPhone(ip string, credentials map[string]string, httpClient *http.Client) *SnomPhone {
+	return &SnomPhone{
+		phoneWebClient: newPhoneWebClient(ip, credentials, httpClient, "check the phone's HTTP user and web access settings"),
+	}
+}
+
+// pressKey sends one key event
+func (sp *SnomPhone) pressKey(key string) error {
+	_, err := sp.do("GET", snomCommandPath+"?key="+url.QueryEscape(key), "", nil)
+	return err
+}
+
+// settings reads the phone settings
+func (sp *SnomPhone) settings() (map[string]string, error) {
+	data, err := sp.do("GET", snomSettingsPath, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSnomSettings(string(data)), nil
+}
+
+// ParseSnomSettings parses settings.htm output ("name!: value", where ! or $ flag the permission)
+func ParseSnomSettings(content string) map[string]string {
+	values := make(map[string]string)
+	for _, line := range strings.Split(content, "\n") {
+		idx := strings.Index(line, ":")
+		if idx <= 0 {
+			continue
+		}
+		name := strings.TrimRight(strings.TrimSpace(line[:idx]), "!$&")
+		if name == "" || strings.ContainsAny(name, " <") {
+			continue
+		}
+		values[name] = strings.TrimSpace(line[idx+1:])
+	}
+	return values
+}
+
+// GetStatus retrieves the phone status
+func (sp *SnomPhone) GetStatus() (*PhoneStatus, error) {
+	settings, err := sp.settings()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status: %w", err)
+	}
+	status := &PhoneStatus{
+		IP:         sp.ip,
+		MAC:        settings["mac_address"],
+		Model:      settings["phone_type"],
+		Firmware:   settings["firmware_version"],
+		Vendor:     "Snom",
+		LastUpdate: time.Now(),
+	}
+	for i := 1; i <= snomMaxLines; i++ {
+		n := strconv.Itoa(i)
+		if settings["user_name"+n] == "" {
+			continue
+		}
+		accountStatus := "Disabled"
+		if settings["user_active"+n] == "on" {
+			accountStatus = "Enabled"
+		}
+		status.Accounts = append(status.Accounts, Account{
+			Number:      i,
+			Extension:   settings["user_name"+n],
+			Status:      accountStatus,
+			Server:      settings["user_host"+n],
+			DisplayName: settings["user_realname"+n],
+		})
+	}
+	return status, nil
+}
+
+// Reboot reboots the phone
+func (sp *SnomPhone) Reboot() error {
+	if _, err := sp.do("GET", snomRebootPath, "", nil); err != nil {
+		return fmt.Errorf("failed to reboot phone: %w", err)
+	}
+	return nil
+}
+
+// FactoryReset is not offered over the Snom HTTP interface
+func (sp *SnomPhone) FactoryReset() error {
+	return fmt.Errorf("factory reset is not supported on Snom phones; use the phone menu or provisioning server")
+}
+
+// GetConfig retrieves the phone configuration
+func (sp *SnomPhone) GetConfig() (map[string]interface{}, error) {
+	settings, err := sp.settings()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get config: %w", err)
+	}
+	config := make(map[string]interface{}, len(settings))
+	for key, value := range settings {
+		config[key] = value
+	}
+	return config, nil
+}
+
+// SetConfig sets phone configuration parameters
+func (sp *SnomPhone) SetConfig(config map[string]interface{}) error {
+	form := url.Values{"settings": {"save"}}
+	keys := make([]string, 0, len(config))
+	for key := range config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		form.Set(key, fmt.Sprint(config[key]))
+	}
+	if _, err := sp.do("POST", snomSavePath, "application/x-www-form-urlencoded", strings.NewReader(form.Encode())); err != nil {
+		return fmt.Errorf("failed to set config: %w", err)
+	}
+	return nil
+}
+
+// SnomLineConfig returns the identity settings of one line
+func SnomLineConfig(ext Extension, lineNumber int, server string) map[string]string {
+	n := strconv.Itoa(lineNumber)
+	host := server
+	if provisionUsesTLS(ext) {
+		host = server + ":5061;transport=tls"
+	}
+	return map[string]string{
+		"user_active" + n:   "on",
+		"user_realname" + n: provisionDisplayName(ext),
+		"user_name" + n:     ext.ExtensionNumber,
+		"user_pname" + n:    ext.ExtensionNumber,
+		"user_pass" + n:     ext.Secret,
+		"user_host" + n:     host,
+	}
+}
+
+// ProvisionExtension provisions an extension on the phone
+func (sp *SnomPhone) ProvisionExtension(ext Extension, accountNumber int) error {
+	if accountNumber < 1 || accountNumber > snomMaxLines {
+		return fmt.Errorf("account number must be between 1 and %d", snomMaxLines)
+	}
+	server := sipServerFor(sp.ip, sp.credentials)
+	if server == "" {
+		return fmt.Errorf("could not determine the SIP server address for %s", sp.ip)
+	}
+	config := make(map[string]interface{})
+	for key, value := range SnomLineConfig(ext, accountNumber, server) {
+		config[key] = value
+	}
+	return sp.SetConfig(config)
+}
+
+// CTI Operations - Snom command.htm key events act on the active call, lineID is ignored
+
+// AcceptCall answers an incoming call
+func (sp *SnomPhone) AcceptCall(lineID int) error {
+	return sp.pressKey(SnomKeyAccept)
+}
+
+// RejectCall rejects an incoming call
+func (sp *SnomPhone) RejectCall(lineID int) error {
+	return sp.pressKey(SnomKeyReject)
+}
+
+// EndCall terminates the current call
+func (sp *SnomPhone) EndCall(lineID int) error {
+	return sp.pressKey(SnomKeyEndCall)
+}
+
+// HoldCall places the call on hold (F_HOLD toggles, Snom exposes no call state)
+func (sp *SnomPhone) HoldCall(lineID int) error {
+	return sp.pressKey(SnomKeyHold)
+}
+
+// ResumeCall resumes a held call
+func (sp *SnomPhone) ResumeCall(lineID int) error {
+	return sp.pressKey(SnomKeyHold)
+}
+
+// Dial initiates an outgoing call
+func (sp *SnomPhone) Dial(number string, lineID int) error {
+	if number == "" {
+		return fmt.Errorf("invalid number %q", number)
+	}
+	if _, err := sp.do("GET", snomCommandPath+"?number="+url.QueryEscape(number), "", nil); err != nil {
+		return fmt.Errorf("failed to dial: %w", err)
+	}
+	return nil
+}
+
+// SendDTMF sends DTMF tones
+func (sp *SnomPhone) SendDTMF(digits string, lineID int) error {
+	if _, err := dtmfKeys(digits); err != nil {
+		return err
+	}
+	for _, d := range digits {
+		if err := sp.pressKey(string(d)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BlindTransfer performs blind transfer by pressing TRANSFER, dialing the target and confirming
+func (sp *SnomPhone) BlindTransfer(target string, lineID int) error {
+	if _, err := dtmfKeys(target); err != nil || target == "" {
+		return fmt.Errorf("invalid transfer target %q", target)
+	}
+	keys := []string{SnomKeyTransfer}
+	for _, d := range target {
+		keys = append(keys, string(d))
+	}
+	keys = append(keys, SnomKeyAccept)
+	for _, key := range keys {
+		if err := sp.pressKey(key); err != nil {
+			return fmt.Errorf("failed to transfer: %w", err)
+		}
+	}
+	return nil
+}
+
+// SetDND enables/disables Do Not Disturb
+func (sp *SnomPhone) SetDND(enable bool) error {
+	mode := "off"
+	if enable {
+		mode = "on"
+	}
+	return sp.SetConfig(map[string]interface{}{"dnd_mode": mode})
+}
+
+// GetPhoneState returns the DND state; Snom does not report calls over HTTP
+func (sp *SnomPhone) GetPhoneState() (*CTIPhoneState, error) {
+	settings, err := sp.settings()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get phone state: %w", err)
+	}
+	return &CTIPhoneState{DNDEnabled: settings["dnd_mode"] == "on"}, nil
+}
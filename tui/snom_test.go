@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// fakeSnom is a minimal Snom web server recording key events and saved settings
+type fakeSnom struct {
+	mu     sync.Mutex
+	events []string
+	saved  url.Values
+}
+
+func newFakeSnom(t *testing.T) (*fakeSnom, *SnomPhone) {
+	t.Helper()
+	fake := &fakeSnom{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "admin" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+
+		switch r.URL.Path {
+		case snomCommandPath:
+			for _, name := range []string{"key", "number"} {
+				if v := r.URL.Query().Get(name); v != "" {
+					fake.events = append(fake.events, name+"="+v)
+				}
+			}
+		case snomSettingsPath:
+			w.Write([]byte("phone_type!: snomD785\nfirmware_version!: 10.1.54.13\nmac_address!: 000413AABBCC\n" +
+				"user_active1$: on\nuser_name1$: 101\nuser_host1$: 192.168.1.10\nuser_realname1$: Front Desk\ndnd_mode&: on\n"))
+		case snomSavePath:
+			r.ParseForm()
+			fake.saved = r.PostForm
+		case "/advanced_update.htm":
+			fake.events = append(fake.events, "reboot="+r.URL.Query().Get("reboot"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	creds := map[string]string{"username": "admin", "password": "secret", "sip_server": "192.168.1.10"}
+	return fake, NewSnomPhone(ts.URL[7:], creds, nil)
+}
+
+func TestSnomStatusAndSettings(t *testing.T) {
+	fake, phone := newFakeSnom(t)
+
+	status, err := phone.GetStatus()
+	if err != nil {
+		t.Fatalf("GetStatus failed: %v", err)
+	}
+	if status.Model != "snomD785" || status.Firmware != "10.1.54.13" || len(status.Accounts) != 1 || status.Accounts[0].DisplayName != "Front Desk" {
+		t.Errorf("unexpected status: %+v", status)
+	}
+
+	state, err := phone.GetPhoneState()
+	if err != nil || !state.DNDEnabled {
+		t.Errorf("expected DND enabled, got %+v (err %v)", state, err)
+	}
+
+	if err := phone.ProvisionExtension(Extension{ExtensionNumber: "105", Name: "Sales", Secret: "pa55"}, 2); err != nil {
+		t.Fatalf("ProvisionExtension failed: %v", err)
+	}
+	if fake.saved.Get("settings") != "save" || fake.saved.Get("user_name2") != "105" || fake.saved.Get("user_pass2") != "pa55" ||
+		fake.saved.Get("user_host2") != "192.168.1.10" || fake.saved.Get("user_active2") != "on" {
+		t.Errorf("unexpected saved settings: %v", fake.saved)
+	}
+	if err := phone.ProvisionExtension(Extension{ExtensionNumber: "105"}, 13); err == nil {
+		t.Error("expected error for out of range line")
+	}
+	if err := phone.FactoryReset(); err == nil {
+		t.Error("expected factory reset to be unsupported")
+	}
+}
+
+func TestSnomKeyEvents(t *testing.T) {
+	fake, phone := newFakeSnom(t)
+
+	if err := phone.AcceptCall(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := phone.Dial("102", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := phone.SendDTMF("5#", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := phone.BlindTransfer("12", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := phone.Reboot(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"key=ENTER", "number=102", "key=5", "key=#", "key=F_TRANSFER", "key=1", "key=2", "key=ENTER", "reboot=Reboot"}
+	if !reflect.DeepEqual(fake.events, expected) {
+		t.Errorf("expected events %v, got %v", expected, fake.events)
+	}
+}
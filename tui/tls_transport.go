@@ -0,0 +1,670 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// SIP over TLS defaults
+const (
+	tlsTransportName        = "transport-tls"
+	DefaultTLSPort          = 5061
+	DefaultTLSMethod        = "tlsv1_2"
+	defaultTLSKeysDir       = "/etc/asterisk/keys"
+	localCACertName         = "ca.crt"
+	localCAKeyName          = "ca.key"
+	serverCertName          = "asterisk.crt"
+	serverKeyName           = "asterisk.key"
+	localCAValidity         = 10 * 365 * 24 * time.Hour
+	serverCertValidity      = 825 * 24 * time.Hour // Longest validity accepted by most clients
+	certExpiryWarningPeriod = 30 * 24 * time.Hour
+)
+
+// validTLSMethods lists the PJSIP transport methods offered in the TUI
+var validTLSMethods = []string{"tlsv1_2", "tlsv1_3", "sslv23"}
+
+// Certificate sources for the TLS transport form
+const (
+	tlsCertGenerate = "generate"
+	tlsCertImport   = "import"
+	tlsCertExisting = "existing"
+)
+
+// TLS transport form field indices
+const (
+	tlsFieldPort = iota
+	tlsFieldCertSource
+	tlsFieldCertFile
+	tlsFieldKeyFile
+	tlsFieldCAFile
+	tlsFieldHosts
+	tlsFieldMethod
+	tlsFieldCipher
+	tlsFieldVerifyClient
+	tlsFieldVerifyServer
+)
+
+// TLSTransportOptions holds the settings of the PJSIP TLS transport
+type TLSTransportOptions struct {
+	Port         int
+	CertFile     string
+	KeyFile      string
+	CAListFile   string // Optional CA bundle used to verify peers
+	Method       string // e.g. tlsv1_2
+	Cipher       string // Optional OpenSSL cipher list
+	VerifyClient bool   // Require and verify client certificates
+	VerifyServer bool   // Verify certificates of servers we connect to
+}
+
+// yesNo formats a boolean as an Asterisk yes/no value
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// parseMediaEncryption validates a per-extension media_encryption value.
+// An empty value disables SRTP.
+func parseMediaEncryption(value string) (string, error) {
+	switch v := strings.ToLower(strings.TrimSpace(value)); v {
+	case "", "no", "none":
+		return "no", nil
+	case "sdes", "dtls":
+		return v, nil
+	default:
+		return "", fmt.Errorf("media encryption must be no, sdes or dtls")
+	}
+}
+
+// LocalCA is a certificate authority generated by RayanPBX to sign the PBX certificate
+type LocalCA struct {
+	Cert *x509.Certificate
+	Key  crypto.Signer
+}
+
+// newSerialNumber returns a random certificate serial number
+func newSerialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+// GenerateLocalCA creates a self-signed CA certificate. RSA keys are used since
+// many desk phones do not support ECDSA.
+func GenerateLocalCA(commonName string, validity time.Duration) (*LocalCA, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate CA key: %v", err)
+	}
+	serial, err := newSerialNumber()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %v", err)
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName, Organization: []string{"RayanPBX"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CA certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA certificate: %v", err)
+	}
+
+	return &LocalCA{Cert: cert, Key: key}, nil
+}
+
+// LoadLocalCA loads a CA certificate and key from PEM files
+func LoadLocalCA(certFile, keyFile string) (*LocalCA, error) {
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load CA: %v", err)
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA certificate: %v", err)
+	}
+	if !cert.IsCA {
+		return nil, fmt.Errorf("%s is not a CA certificate", certFile)
+	}
+	signer, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported CA key type")
+	}
+	return &LocalCA{Cert: cert, Key: signer}, nil
+}
+
+// IssueServerCertificate signs a server certificate for the given host names and IP addresses.
+// It returns the PEM encoded certificate and private key.
+func (ca *LocalCA) IssueServerCertificate(hosts []string, validity time.Duration) ([]byte, []byte, error) {
+	if len(hosts) == 0 {
+		return nil, nil, fmt.Errorf("at least one host name or IP address is required")
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate key: %v", err)
+	}
+	serial, err := newSerialNumber()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate serial number: %v", err)
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: hosts[0], Organization: []string{"RayanPBX"}},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(validity),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Cert, &key.PublicKey, ca.Key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create certificate: %v", err)
+	}
+	keyPEM, err := encodePrivateKeyPEM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), keyPEM, nil
+}
+
+// WriteFiles writes the CA certificate and key as PEM files, the key readable by the owner only
+func (ca *LocalCA) WriteFiles(certFile, keyFile string) error {
+	keyPEM, err := encodePrivateKeyPEM(ca.Key)
+	if err != nil {
+		return err
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Cert.Raw})
+	return writeCertificateFiles(certFile, certPEM, keyFile, keyPEM)
+}
+
+// encodePrivateKeyPEM encodes a private key as PKCS#8 PEM
+func encodePrivateKeyPEM(key crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode private key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// writeCertificateFiles writes a certificate (0644) and its private key (0600)
+func writeCertificateFiles(certFile string, certPEM []byte, keyFile string, keyPEM []byte) error {
+	if err := os.MkdirAll(filepath.Dir(certFile), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(certFile, certPEM, 0644); err != nil {
+		return fmt.Errorf("failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		return fmt.Errorf("failed to write private key: %v", err)
+	}
+	return nil
+}
+
+// GenerateTLSCertificates issues a server certificate in dir, signed by the local CA in the
+// same directory. The CA is created on first use and reused afterwards, so phones that
+// already trust it keep working after the server certificate is renewed.
+func GenerateTLSCertificates(dir string, hosts []string) (*TLSTransportOptions, error) {
+	caCertFile := filepath.Join(dir, localCACertName)
+	caKeyFile := filepath.Join(dir, localCAKeyName)
+
+	var ca *LocalCA
+	if _, err := os.Stat(caCertFile); err == nil {
+		if ca, err = LoadLocalCA(caCertFile, caKeyFile); err != nil {
+			return nil, err
+		}
+	} else {
+		if ca, err = GenerateLocalCA("RayanPBX Local CA", localCAValidity); err != nil {
+			return nil, err
+		}
+		if err := ca.WriteFiles(caCertFile, caKeyFile); err != nil {
+			return nil, err
+		}
+	}
+
+	certPEM, keyPEM, err := ca.IssueServerCertificate(hosts, serverCertValidity)
+	if err != nil {
+		return nil, err
+	}
+	opts := &TLSTransportOptions{
+		CertFile:   filepath.Join(dir, serverCertName),
+		KeyFile:    filepath.Join(dir, serverKeyName),
+		CAListFile: caCertFile,
+	}
+	if err := writeCertificateFiles(opts.CertFile, certPEM, opts.KeyFile, keyPEM); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+// ImportTLSCertificate validates an existing certificate and key and copies them into dir.
+// The CA bundle is optional.
+func ImportTLSCertificate(certSrc, keySrc, caSrc, dir string) (*TLSTransportOptions, error) {
+	if _, err := tls.LoadX509KeyPair(certSrc, keySrc); err != nil {
+		return nil, fmt.Errorf("invalid certificate or key: %v", err)
+	}
+	if status := CheckCertificateExpiry(certSrc); status.Err != nil {
+		return nil, status.Err
+	}
+
+	certPEM, err := os.ReadFile(certSrc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate: %v", err)
+	}
+	keyPEM, err := os.ReadFile(keySrc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %v", err)
+	}
+
+	opts := &TLSTransportOptions{
+		CertFile: filepath.Join(dir, serverCertName),
+		KeyFile:  filepath.Join(dir, serverKeyName),
+	}
+	if err := writeCertificateFiles(opts.CertFile, certPEM, opts.KeyFile, keyPEM); err != nil {
+		return nil, err
+	}
+
+	if caSrc != "" {
+		caPEM, err := os.ReadFile(caSrc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %v", err)
+		}
+		if !x509.NewCertPool().AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in %s", caSrc)
+		}
+		opts.CAListFile = filepath.Join(dir, localCACertName)
+		if err := os.WriteFile(opts.CAListFile, caPEM, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write CA certificate: %v", err)
+		}
+	}
+
+	return opts, nil
+}
+
+// CertificateStatus describes the validity of a certificate file
+type CertificateStatus struct {
+	File     string
+	Subject  string
+	NotAfter time.Time
+	Err      error
+}
+
+// CheckCertificateExpiry reads the first certificate of a PEM file and checks its validity period
+func CheckCertificateExpiry(file string) CertificateStatus {
+	status := CertificateStatus{File: file}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		status.Err = fmt.Errorf("failed to read certificate: %v", err)
+		return status
+	}
+	var block *pem.Block
+	for {
+		block, data = pem.Decode(data)
+		if block == nil || block.Type == "CERTIFICATE" {
+			break
+		}
+	}
+	if block == nil {
+		status.Err = fmt.Errorf("no certificate found in %s", file)
+		return status
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		status.Err = fmt.Errorf("failed to parse certificate: %v", err)
+		return status
+	}
+
+	status.Subject = cert.Subject.CommonName
+	status.NotAfter = cert.NotAfter
+	now := time.Now()
+	if now.Before(cert.NotBefore) {
+		status.Err = fmt.Errorf("certificate is not valid before %s", cert.NotBefore.Format("2006-01-02"))
+	} else if now.After(cert.NotAfter) {
+		status.Err = fmt.Errorf("certificate expired on %s", cert.NotAfter.Format("2006-01-02"))
+	}
+	return status
+}
+
+// ExpiresSoon reports whether a valid certificate is within the expiry warning period
+func (s CertificateStatus) ExpiresSoon() bool {
+	return s.Err == nil && time.Until(s.NotAfter) < certExpiryWarningPeriod
+}
+
+// Summary returns a one-line status for health check output
+func (s CertificateStatus) Summary() string {
+	if s.Err != nil {
+		return fmt.Sprintf("❌ %s: %v", s.File, s.Err)
+	}
+	days := int(time.Until(s.NotAfter).Hours() / 24)
+	if s.ExpiresSoon() {
+		return fmt.Sprintf("⚠️  %s expires in %d days (%s)", s.File, days, s.NotAfter.Format("2006-01-02"))
+	}
+	return fmt.Sprintf("✅ %s valid until %s (%d days)", s.File, s.NotAfter.Format("2006-01-02"), days)
+}
+
+// LoadTLSTransportOptions returns the settings of the TLS transport in pjsip.conf, or nil if there is none
+func (acm *AsteriskConfigManager) LoadTLSTransportOptions() (*TLSTransportOptions, error) {
+	if _, err := os.Stat(acm.pjsipConfigPath); os.IsNotExist(err) {
+		return nil, nil
+	}
+	config, err := ParseAsteriskConfig(acm.pjsipConfigPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	for _, section := range config.Sections {
+		if section.Commented || section.Type != "transport" || section.Name != tlsTransportName {
+			continue
+		}
+		opts := &TLSTransportOptions{
+			Port:         DefaultTLSPort,
+			CertFile:     section.Properties["cert_file"],
+			KeyFile:      section.Properties["priv_key_file"],
+			CAListFile:   section.Properties["ca_list_file"],
+			Method:       section.Properties["method"],
+			Cipher:       section.Properties["cipher"],
+			VerifyClient: isAsteriskTrue(section.Properties["verify_client"]),
+			VerifyServer: isAsteriskTrue(section.Properties["verify_server"]),
+		}
+		if _, port, err := net.SplitHostPort(section.Properties["bind"]); err == nil {
+			if p, err := strconv.Atoi(port); err == nil {
+				opts.Port = p
+			}
+		}
+		return opts, nil
+	}
+	return nil, nil
+}
+
+// TLSCertificateFiles returns the certificates used by the PJSIP TLS transports and the
+// TLS listener of the HTTP server (WSS)
+func (acm *AsteriskConfigManager) TLSCertificateFiles() []string {
+	var files []string
+	seen := make(map[string]bool)
+	add := func(file string) {
+		if file != "" && !seen[file] {
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+
+	if config, err := ParseAsteriskConfig(acm.pjsipConfigPath); err == nil {
+		for _, section := range config.Sections {
+			if !section.Commented && section.Type == "transport" {
+				add(section.Properties["cert_file"])
+			}
+		}
+	}
+	if settings, err := acm.LoadHTTPTLSSettings(); err == nil && settings.TLSEnabled {
+		certFile, _ := settings.CertificatePaths()
+		add(certFile)
+	}
+
+	return files
+}
+
+// ConfigureTLSTransport prepares the certificate from the chosen source and writes the TLS transport.
+// hosts are the names and addresses put in a generated certificate.
+func (acm *AsteriskConfigManager) ConfigureTLSTransport(source string, opts TLSTransportOptions, hosts []string) (*TLSTransportOptions, error) {
+	var certs *TLSTransportOptions
+	var err error
+
+	switch source {
+	case tlsCertGenerate:
+		certs, err = GenerateTLSCertificates(acm.tlsKeysDir, hosts)
+	case tlsCertImport:
+		certs, err = ImportTLSCertificate(opts.CertFile, opts.KeyFile, opts.CAListFile, acm.tlsKeysDir)
+	case tlsCertExisting:
+		if _, err = tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile); err != nil {
+			err = fmt.Errorf("invalid certificate or key: %v", err)
+		}
+		certs = &opts
+	default:
+		err = fmt.Errorf("unknown certificate source: %s", source)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	opts.CertFile = certs.CertFile
+	opts.KeyFile = certs.KeyFile
+	opts.CAListFile = certs.CAListFile
+	if err := acm.EnsureTransportConfigWithTLS(&opts); err != nil {
+		return nil, err
+	}
+	return &opts, nil
+}
+
+// defaultCertificateHosts returns the host name and local addresses of this server
+func defaultCertificateHosts() []string {
+	hosts := []string{GetSystemHostname()}
+	for _, ip := range GetLocalIPAddresses() {
+		if ip != "127.0.0.1" {
+			hosts = append(hosts, ip)
+		}
+	}
+	return hosts
+}
+
+// initTLSTransportForm opens the TLS transport form, pre-filled from the current transport
+func (m *model) initTLSTransportForm() {
+	values := []string{
+		strconv.Itoa(DefaultTLSPort),
+		tlsCertGenerate,
+		"",
+		"",
+		"",
+		strings.Join(defaultCertificateHosts(), ","),
+		DefaultTLSMethod,
+		"",
+		"no",
+		"no",
+	}
+	if current, err := m.configManager.LoadTLSTransportOptions(); err == nil && current != nil {
+		values[tlsFieldPort] = strconv.Itoa(current.Port)
+		values[tlsFieldCertSource] = tlsCertExisting
+		values[tlsFieldCertFile] = current.CertFile
+		values[tlsFieldKeyFile] = current.KeyFile
+		values[tlsFieldCAFile] = current.CAListFile
+		if current.Method != "" {
+			values[tlsFieldMethod] = current.Method
+		}
+		values[tlsFieldCipher] = current.Cipher
+		values[tlsFieldVerifyClient] = yesNo(current.VerifyClient)
+		values[tlsFieldVerifyServer] = yesNo(current.VerifyServer)
+	}
+
+	m.currentScreen = tlsTransportScreen
+	m.inputMode = true
+	m.inputFields = []string{
+		"Port",
+		"Certificate (generate/import/existing)",
+		"Certificate file",
+		"Private key file",
+		"CA certificate file (optional)",
+		"Server names/IPs (comma-separated)",
+		"TLS method",
+		"Cipher list (optional)",
+		"Verify client (yes/no)",
+		"Verify server (yes/no)",
+	}
+	m.inputValues = values
+	m.inputCursor = 0
+	m.errorMsg = ""
+	m.successMsg = ""
+}
+
+// parseTLSTransportForm validates the TLS transport form. It returns the transport options,
+// the certificate source and the host names for a generated certificate.
+func parseTLSTransportForm(values []string) (TLSTransportOptions, string, []string, error) {
+	var opts TLSTransportOptions
+
+	port, err := strconv.Atoi(strings.TrimSpace(values[tlsFieldPort]))
+	if err != nil || port < 1 || port > 65535 {
+		return opts, "", nil, fmt.Errorf("invalid port")
+	}
+	opts.Port = port
+
+	source := strings.ToLower(strings.TrimSpace(values[tlsFieldCertSource]))
+	opts.CertFile = strings.TrimSpace(values[tlsFieldCertFile])
+	opts.KeyFile = strings.TrimSpace(values[tlsFieldKeyFile])
+	opts.CAListFile = strings.TrimSpace(values[tlsFieldCAFile])
+
+	var hosts []string
+	switch source {
+	case tlsCertGenerate:
+		for _, host := range strings.Split(values[tlsFieldHosts], ",") {
+			if host = strings.TrimSpace(host); host != "" {
+				hosts = append(hosts, host)
+			}
+		}
+		if len(hosts) == 0 {
+			return opts, "", nil, fmt.Errorf("server names are required to generate a certificate")
+		}
+	case tlsCertImport, tlsCertExisting:
+		if opts.CertFile == "" || opts.KeyFile == "" {
+			return opts, "", nil, fmt.Errorf("certificate and key files are required")
+		}
+	default:
+		return opts, "", nil, fmt.Errorf("certificate must be generate, import or existing")
+	}
+
+	opts.Method = strings.ToLower(strings.TrimSpace(values[tlsFieldMethod]))
+	if opts.Method == "" {
+		opts.Method = DefaultTLSMethod
+	}
+	valid := false
+	for _, method := range validTLSMethods {
+		if opts.Method == method {
+			valid = true
+		}
+	}
+	if !valid {
+		return opts, "", nil, fmt.Errorf("TLS method must be one of: %s", strings.Join(validTLSMethods, ", "))
+	}
+	opts.Cipher = strings.TrimSpace(values[tlsFieldCipher])
+
+	for _, field := range []int{tlsFieldVerifyClient, tlsFieldVerifyServer} {
+		v := strings.ToLower(strings.TrimSpace(values[field]))
+		if v != "yes" && v != "no" && v != "" {
+			return opts, "", nil, fmt.Errorf("verify options must be yes or no")
+		}
+	}
+	opts.VerifyClient = strings.EqualFold(strings.TrimSpace(values[tlsFieldVerifyClient]), "yes")
+	opts.VerifyServer = strings.EqualFold(strings.TrimSpace(values[tlsFieldVerifyServer]), "yes")
+
+	return opts, source, hosts, nil
+}
+
+// executeTLSTransportSetup applies the TLS transport form and reloads PJSIP
+func (m *model) executeTLSTransportSetup() {
+	opts, source, hosts, err := parseTLSTransportForm(m.inputValues)
+	if err != nil {
+		m.errorMsg = err.Error()
+		return
+	}
+
+	applied, err := m.configManager.ConfigureTLSTransport(source, opts, hosts)
+	if err != nil {
+		m.errorMsg = fmt.Sprintf("Failed to configure TLS transport: %v", err)
+		return
+	}
+
+	m.inputMode = false
+	m.currentScreen = asteriskMenuScreen
+
+	var out strings.Builder
+	out.WriteString("✅ UDP Transport: 0.0.0.0:5060\n✅ TCP Transport: 0.0.0.0:5060\n")
+	out.WriteString(fmt.Sprintf("✅ TLS Transport: 0.0.0.0:%d (%s)\n", applied.Port, applied.Method))
+	out.WriteString(fmt.Sprintf("   Certificate: %s\n", CheckCertificateExpiry(applied.CertFile).Summary()))
+	if applied.CAListFile != "" {
+		out.WriteString(fmt.Sprintf("   CA: %s (install it on phones to trust the PBX)\n", applied.CAListFile))
+	}
+	if _, err := m.asteriskManager.ReloadPJSIPQuiet(); err != nil {
+		out.WriteString("\n⚠️ Transports configured but reload failed. You may need to restart Asterisk.")
+	} else {
+		out.WriteString("\nConfiguration reloaded successfully.")
+	}
+	m.asteriskOutput = out.String()
+	m.successMsg = "PJSIP transports configured successfully (UDP, TCP and TLS)"
+}
+
+// renderTLSTransportForm renders the TLS transport form
+func (m model) renderTLSTransportForm() string {
+	content := infoStyle.Render("🔒 Configure PJSIP Transports (SIP over TLS)") + "\n\n"
+	content += "UDP and TCP on port 5060 are always configured. TLS adds an encrypted transport.\n\n"
+
+	fieldHelp := map[int]string{
+		tlsFieldPort:         "Port for SIP over TLS (5061 is standard)",
+		tlsFieldCertSource:   "generate: local CA + server certificate, import: copy files below, existing: use files in place",
+		tlsFieldCertFile:     "PEM certificate (import/existing only)",
+		tlsFieldKeyFile:      "PEM private key (import/existing only)",
+		tlsFieldCAFile:       "CA bundle used to verify phones and servers",
+		tlsFieldHosts:        "Names phones use to reach the PBX (generate only)",
+		tlsFieldMethod:       "One of: " + strings.Join(validTLSMethods, ", "),
+		tlsFieldCipher:       "OpenSSL cipher list, empty for the Asterisk default",
+		tlsFieldVerifyClient: "Require phones to present a certificate signed by the CA",
+		tlsFieldVerifyServer: "Verify certificates of trunks and servers Asterisk connects to",
+	}
+
+	for i, field := range m.inputFields {
+		cursor := "  "
+		fieldStyle := lipgloss.NewStyle()
+		if i == m.inputCursor {
+			cursor = "▶ "
+			fieldStyle = selectedItemStyle
+		}
+		value := m.inputValues[i]
+		if value == "" {
+			value = helpStyle.Render("<empty>")
+		}
+		content += fmt.Sprintf("%s%s: %s\n", cursor, fieldStyle.Render(field), value)
+		if i == m.inputCursor {
+			if help, ok := fieldHelp[i]; ok {
+				content += helpStyle.Render(fmt.Sprintf("   💡 %s", help)) + "\n"
+			}
+		}
+	}
+
+	content += "\n" + helpStyle.Render("💡 Use media encryption sdes or dtls on extensions to encrypt calls (SRTP)")
+	content += "\n" + helpStyle.Render("💡 Press ↑/↓ to navigate, Enter on last field to apply, ESC to cancel")
+
+	return menuStyle.Render(content)
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestGenerateTLSCertificates tests issuing a server certificate from the local CA
+func TestGenerateTLSCertificates(t *testing.T) {
+	dir := t.TempDir()
+	opts, err := GenerateTLSCertificates(dir, []string{"pbx.example.com", "192.168.1.10"})
+	if err != nil {
+		t.Fatalf("GenerateTLSCertificates failed: %v", err)
+	}
+
+	pair, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+	if err != nil {
+		t.Fatalf("Generated certificate does not load: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caPEM, err := os.ReadFile(opts.CAListFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(caPEM)
+	for _, host := range []string{"pbx.example.com", "192.168.1.10"} {
+		if _, err := leaf.Verify(x509.VerifyOptions{DNSName: host, Roots: roots}); err != nil {
+			t.Errorf("Certificate does not verify for %s: %v", host, err)
+		}
+	}
+
+	if info, err := os.Stat(opts.KeyFile); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected private key with mode 0600, got %v", info.Mode().Perm())
+	}
+
+	// Renewing keeps the CA phones already trust
+	if _, err := GenerateTLSCertificates(dir, []string{"pbx.example.com"}); err != nil {
+		t.Fatalf("Second GenerateTLSCertificates failed: %v", err)
+	}
+	caPEM2, _ := os.ReadFile(opts.CAListFile)
+	if string(caPEM) != string(caPEM2) {
+		t.Error("Expected existing CA to be reused")
+	}
+
+	if _, err := GenerateTLSCertificates(dir, nil); err == nil {
+		t.Error("Expected error without host names")
+	}
+}
+
+// TestImportTLSCertificate tests importing an existing certificate
+func TestImportTLSCertificate(t *testing.T) {
+	src := t.TempDir()
+	certFile, keyFile := writeTestCertificate(t, src, time.Now().Add(-time.Hour), time.Now().Add(24*time.Hour))
+
+	dest := filepath.Join(t.TempDir(), "keys")
+	opts, err := ImportTLSCertificate(certFile, keyFile, certFile, dest)
+	if err != nil {
+		t.Fatalf("ImportTLSCertificate failed: %v", err)
+	}
+	if opts.CertFile != filepath.Join(dest, serverCertName) || opts.CAListFile != filepath.Join(dest, localCACertName) {
+		t.Errorf("Unexpected paths: %+v", opts)
+	}
+	if _, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile); err != nil {
+		t.Errorf("Imported certificate does not load: %v", err)
+	}
+
+	// Key of another certificate
+	_, otherKey := writeTestCertificate(t, t.TempDir(), time.Now().Add(-time.Hour), time.Now().Add(24*time.Hour))
+	if _, err := ImportTLSCertificate(certFile, otherKey, "", dest); err == nil {
+		t.Error("Expected error for mismatched key")
+	}
+
+	expiredCert, expiredKey := writeTestCertificate(t, t.TempDir(), time.Now().Add(-48*time.Hour), time.Now().Add(-24*time.Hour))
+	if _, err := ImportTLSCertificate(expiredCert, expiredKey, "", dest); err == nil {
+		t.Error("Expected error for expired certificate")
+	}
+}
+
+// TestEnsureTransportConfigWithTLS tests adding and updating the TLS transport
+func TestEnsureTransportConfigWithTLS(t *testing.T) {
+	dir := t.TempDir()
+	acm := NewAsteriskConfigManager(false)
+	acm.pjsipConfigPath = filepath.Join(dir, "pjsip.conf")
+	if err := os.WriteFile(acm.pjsipConfigPath, []byte("[100]\ntype=endpoint\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := TLSTransportOptions{Port: 5061, CertFile: "/k/asterisk.crt", KeyFile: "/k/asterisk.key",
+		CAListFile: "/k/ca.crt", Method: "tlsv1_2", VerifyServer: true}
+	if err := acm.EnsureTransportConfigWithTLS(&opts); err != nil {
+		t.Fatalf("EnsureTransportConfigWithTLS failed: %v", err)
+	}
+	opts.Port = 5062
+	opts.Cipher = "ECDHE-RSA-AES256-GCM-SHA384"
+	if err := acm.EnsureTransportConfigWithTLS(&opts); err != nil {
+		t.Fatalf("EnsureTransportConfigWithTLS failed: %v", err)
+	}
+
+	config, err := ParseAsteriskConfig(acm.pjsipConfigPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, section := range config.Sections {
+		names = append(names, section.Name)
+	}
+	if got := strings.Join(names, ","); got != "transport-udp,transport-tcp,transport-tls,100" {
+		t.Errorf("Unexpected section order: %s", got)
+	}
+
+	loaded, err := acm.LoadTLSTransportOptions()
+	if err != nil || loaded == nil {
+		t.Fatalf("LoadTLSTransportOptions failed: %v", err)
+	}
+	if *loaded != opts {
+		t.Errorf("Round trip mismatch:\n got  %+v\n want %+v", *loaded, opts)
+	}
+
+	// Without options the TLS transport is left alone
+	if err := acm.EnsureTransportConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, _ := acm.LoadTLSTransportOptions(); loaded == nil {
+		t.Error("EnsureTransportConfig must not remove the TLS transport")
+	}
+}
+
+// TestConfigureTLSTransportGenerate tests the generate flow end to end
+func TestConfigureTLSTransportGenerate(t *testing.T) {
+	dir := t.TempDir()
+	acm := NewAsteriskConfigManager(false)
+	acm.pjsipConfigPath = filepath.Join(dir, "pjsip.conf")
+	acm.httpConfigPath = filepath.Join(dir, "http.conf")
+	acm.tlsKeysDir = filepath.Join(dir, "keys")
+
+	applied, err := acm.ConfigureTLSTransport(tlsCertGenerate, TLSTransportOptions{Port: 5061, Method: "tlsv1_2"}, []string{"pbx.local"})
+	if err != nil {
+		t.Fatalf("ConfigureTLSTransport failed: %v", err)
+	}
+	if applied.CertFile != filepath.Join(acm.tlsKeysDir, serverCertName) {
+		t.Errorf("Unexpected certificate path %s", applied.CertFile)
+	}
+
+	files := acm.TLSCertificateFiles()
+	if len(files) != 1 || files[0] != applied.CertFile {
+		t.Errorf("Expected TLS certificate in health check files, got %v", files)
+	}
+	status := CheckCertificateExpiry(files[0])
+	if status.Err != nil || status.ExpiresSoon() || !strings.HasPrefix(status.Summary(), "✅") {
+		t.Errorf("Unexpected certificate status: %s", status.Summary())
+	}
+
+	if _, err := acm.ConfigureTLSTransport(tlsCertExisting, TLSTransportOptions{CertFile: "/missing.crt", KeyFile: "/missing.key"}, nil); err == nil {
+		t.Error("Expected error for missing existing certificate")
+	}
+}
+
+// TestCheckCertificateExpirySummary tests the health check line for expiring certificates
+func TestCheckCertificateExpirySummary(t *testing.T) {
+	dir := t.TempDir()
+	certFile, _ := writeTestCertificate(t, dir, time.Now().Add(-time.Hour), time.Now().Add(10*24*time.Hour))
+	status := CheckCertificateExpiry(certFile)
+	if !status.ExpiresSoon() || !strings.Contains(status.Summary(), "expires in") {
+		t.Errorf("Expected expiry warning, got %q", status.Summary())
+	}
+
+	if status := CheckCertificateExpiry(filepath.Join(dir, "missing.crt")); status.Err == nil {
+		t.Error("Expected error for missing certificate")
+	}
+}
+
+// TestParseTLSTransportForm tests TLS form validation
+func TestParseTLSTransportForm(t *testing.T) {
+	valid := []string{"5061", "generate", "", "", "", "pbx.local, 10.0.0.1", "", "", "yes", "no"}
+	opts, source, hosts, err := parseTLSTransportForm(valid)
+	if err != nil {
+		t.Fatalf("Expected valid form, got %v", err)
+	}
+	if source != tlsCertGenerate || strings.Join(hosts, ",") != "pbx.local,10.0.0.1" {
+		t.Errorf("Unexpected source %q or hosts %v", source, hosts)
+	}
+	if opts.Method != DefaultTLSMethod || !opts.VerifyClient || opts.VerifyServer {
+		t.Errorf("Unexpected options: %+v", opts)
+	}
+
+	invalid := map[string]map[int]string{
+		"port":          {tlsFieldPort: "70000"},
+		"source":        {tlsFieldCertSource: "letsencrypt"},
+		"no hosts":      {tlsFieldHosts: " , "},
+		"import no key": {tlsFieldCertSource: "import", tlsFieldCertFile: "/a.crt"},
+		"method":        {tlsFieldMethod: "sslv3"},
+		"verify":        {tlsFieldVerifyClient: "maybe"},
+	}
+	for name, changes := range invalid {
+		t.Run(name, func(t *testing.T) {
+			values := append([]string(nil), valid...)
+			for field, value := range changes {
+				values[field] = value
+			}
+			if _, _, _, err := parseTLSTransportForm(values); err == nil {
+				t.Error("Expected validation error")
+			}
+		})
+	}
+}
+
+// TestGeneratePjsipEndpointMediaEncryption tests per-extension SRTP options
+func TestGeneratePjsipEndpointMediaEncryption(t *testing.T) {
+	acm := NewAsteriskConfigManager(false)
+	acm.httpConfigPath = filepath.Join(t.TempDir(), "http.conf")
+	base := Extension{ExtensionNumber: "101", Secret: "pw", Context: "from-internal", Transport: "transport-tls", MaxContacts: 1}
+
+	tests := []struct {
+		mode       string
+		encryption string
+		dtlsCert   string
+	}{
+		{"no", "", ""},
+		{"sdes", "sdes", ""},
+		{"dtls", "dtls", defaultWebRTCCertFile},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			ext := base
+			ext.MediaEncryption = tt.mode
+			endpoint := acm.GeneratePjsipEndpoint(ext)[0]
+			if got, _ := endpoint.GetProperty("media_encryption"); got != tt.encryption {
+				t.Errorf("Expected media_encryption=%q, got %q", tt.encryption, got)
+			}
+			if got, _ := endpoint.GetProperty("dtls_cert_file"); got != tt.dtlsCert {
+				t.Errorf("Expected dtls_cert_file=%q, got %q", tt.dtlsCert, got)
+			}
+		})
+	}
+
+	for _, value := range []string{"", "No", "SDES", "dtls"} {
+		if _, err := parseMediaEncryption(value); err != nil {
+			t.Errorf("parseMediaEncryption(%q) failed: %v", value, err)
+		}
+	}
+	if _, err := parseMediaEncryption("zrtp"); err == nil {
+		t.Error("Expected error for unsupported media encryption")
+	}
+}
@@ -198,12 +198,11 @@ func (pm *PhoneManager) extractIPFromContact(contact string) string {
 
 // CreatePhone creates a phone instance for the given IP and vendor
 func (pm *PhoneManager) CreatePhone(ip string, vendor string, credentials map[string]string) (VoIPPhone, error) {
-	switch strings.ToLower(vendor) {
-	case "grandstream":
-		return NewGrandStreamPhone(ip, credentials, pm.httpClient), nil
-	default:
-		return nil, fmt.Errorf("unsupported vendor: %s", vendor)
+	driver := LookupPhoneDriver(vendor)
+	if driver == nil || !driver.Manageable() {
+		return nil, fmt.Errorf("unsupported vendor: %s (supported: %s)", vendor, strings.Join(ManageablePhoneVendors(), ", "))
 	}
+	return driver.New(ip, credentials, pm.httpClient), nil
 }
 
 // DetectPhoneVendor attempts to detect the vendor of a phone at the given IP
@@ -216,13 +215,8 @@ func (pm *PhoneManager) DetectPhoneVendor(ip string) (string, error) {
 	defer resp.Body.Close()
 	
 	// Check Server header
-	if server := resp.Header.Get("Server"); server != "" {
-		if strings.Contains(strings.ToLower(server), "grandstream") {
-			return "grandstream", nil
-		}
-		if strings.Contains(strings.ToLower(server), "yealink") {
-			return "yealink", nil
-		}
+	if driver := PhoneDriverForHTTP(resp.Header.Get("Server")); driver != nil {
+		return driver.Vendor, nil
 	}
 	
 	// Read body to check for vendor-specific strings
@@ -231,12 +225,8 @@ func (pm *PhoneManager) DetectPhoneVendor(ip string) (string, error) {
 		return "", fmt.Errorf("failed to read response: %w", err)
 	}
 	
-	bodyStr := strings.ToLower(string(body))
-	if strings.Contains(bodyStr, "grandstream") {
-		return "grandstream", nil
-	}
-	if strings.Contains(bodyStr, "yealink") {
-		return "yealink", nil
+	if driver := PhoneDriverForHTTP(string(body)); driver != nil {
+		return driver.Vendor, nil
 	}
 	
 	return "unknown", nil
@@ -294,91 +284,26 @@ func (pm *PhoneManager) DetectPhoneVendorAndModel(ip string) (*PhoneVendorAndMod
 
 	// Check Server header first
 	if server := resp.Header.Get("Server"); server != "" {
-		serverLower := strings.ToLower(server)
-		if strings.Contains(serverLower, "grandstream") {
-			result.Vendor = "grandstream"
+		if driver := PhoneDriverForHTTP(server); driver != nil {
+			result.Vendor = driver.Vendor
 			// Model patterns are case-insensitive ((?i) flag), so use original case
-			if match := grandstreamModelPattern.FindString(server); match != "" {
-				result.Model = strings.ToUpper(match)
-			}
-		} else if strings.Contains(serverLower, "yealink") {
-			result.Vendor = "yealink"
-			if match := yealinkModelPattern.FindString(server); match != "" {
+			if match := driver.ModelPattern.FindString(server); match != "" {
 				result.Model = strings.ToUpper(match)
 			}
 		}
 	}
 
-	// Check body content for vendor and model
+	// Check body content for vendor and model, in registry order
 	// Note: All model patterns use (?i) flag for case-insensitive matching
-	
-	// GrandStream - check for model pattern first (model regex includes model prefix like GXP, GRP, etc.)
-	if result.Model == "" {
-		if match := grandstreamModelPattern.FindString(bodyStr); match != "" {
-			result.Vendor = "grandstream"
-			result.Model = strings.ToUpper(match)
-		} else if strings.Contains(bodyStrLower, "grandstream") {
-			result.Vendor = "grandstream"
-		}
-	}
-
-	// Yealink
-	if result.Model == "" {
-		if match := yealinkModelPattern.FindString(bodyStr); match != "" {
-			result.Vendor = "yealink"
-			result.Model = strings.ToUpper(match)
-		} else if strings.Contains(bodyStrLower, "yealink") {
-			result.Vendor = "yealink"
-		}
-	}
-
-	// Polycom
-	if result.Model == "" {
-		if match := polycomModelPattern.FindString(bodyStr); match != "" {
-			result.Vendor = "polycom"
-			result.Model = strings.ToUpper(match)
-		} else if strings.Contains(bodyStrLower, "polycom") {
-			result.Vendor = "polycom"
-		}
-	}
-
-	// Cisco
-	if result.Model == "" {
-		if match := ciscoModelPattern.FindString(bodyStr); match != "" {
-			result.Vendor = "cisco"
-			result.Model = strings.ToUpper(match)
-		} else if strings.Contains(bodyStrLower, "cisco") {
-			result.Vendor = "cisco"
+	for _, driver := range PhoneDrivers() {
+		if result.Model != "" {
+			break
 		}
-	}
-
-	// Snom
-	if result.Model == "" {
-		if match := snomModelPattern.FindString(bodyStr); match != "" {
-			result.Vendor = "snom"
-			result.Model = strings.ToUpper(match)
-		} else if strings.Contains(bodyStrLower, "snom") {
-			result.Vendor = "snom"
-		}
-	}
-
-	// Panasonic
-	if result.Model == "" {
-		if match := panasonicModelPattern.FindString(bodyStr); match != "" {
-			result.Vendor = "panasonic"
-			result.Model = strings.ToUpper(match)
-		} else if strings.Contains(bodyStrLower, "panasonic") {
-			result.Vendor = "panasonic"
-		}
-	}
-
-	// Fanvil
-	if result.Model == "" {
-		if match := fanvilModelPattern.FindString(bodyStr); match != "" {
-			result.Vendor = "fanvil"
+		if match := driver.ModelPattern.FindString(bodyStr); match != "" {
+			result.Vendor = driver.Vendor
 			result.Model = strings.ToUpper(match)
-		} else if strings.Contains(bodyStrLower, "fanvil") {
-			result.Vendor = "fanvil"
+		} else if driver.MatchesHTTP(bodyStrLower) {
+			result.Vendor = driver.Vendor
 		}
 	}
 
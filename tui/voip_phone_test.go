@@ -294,6 +294,11 @@ func TestCreatePhone(t *testing.T) {
 			vendor:    "grandstream",
 			shouldErr: false,
 		},
+		{
+			name:      "Yealink phone",
+			vendor:    "yealink",
+			shouldErr: false,
+		},
 		{
 			name:      "Unsupported vendor",
 			vendor:    "unknown",
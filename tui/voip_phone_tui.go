@@ -189,7 +189,9 @@ func (m model) renderVoIPPhones() string {
 	content += "\n" + helpStyle.Render("📌 Tips:") + "\n"
 	content += helpStyle.Render("   ↑/↓  Select phone    Enter  View/Edit    c  Control menu") + "\n"
 	content += helpStyle.Render("   a    Add manually    d      Delete       A  Add all discovered") + "\n"
-	content += helpStyle.Render("   r    Refresh         ESC    Back")
+	content += helpStyle.Render("   r    Refresh         i      Inventory    b  Bulk operations") + "\n"
+	content += helpStyle.Render("   F    Firmware") + "\n"
+	content += helpStyle.Render("   ESC  Back")
 	content += "\n" + helpStyle.Render("   📡 = LLDP discovered")
 	
 	return menuStyle.Render(content)
@@ -495,7 +497,8 @@ func (m *model) initVoIPPhonesScreen() {
 		m.phoneDiscovery = NewPhoneDiscovery(m.phoneManager)
 	}
 	
-	// Load registered phones and trigger background discovery
+	// Credentials saved in the inventory, then registered phones and background discovery
+	m.loadStoredPhoneCredentials()
 	m.loadRegisteredPhonesWithDiscovery()
 }
 
@@ -512,6 +515,21 @@ func (m *model) loadRegisteredPhones() {
 		phones = []PhoneInfo{}
 	}
 	
+	// Registrations keep the inventory's addresses and extensions current
+	if m.db != nil {
+		for _, p := range phones {
+			if p.IP != "" {
+				RecordPhoneSighting(m.db, PhoneSighting{
+					IP:        p.IP,
+					Extension: p.Extension,
+					UserAgent: p.UserAgent,
+					Status:    "online",
+					Source:    "sip",
+				})
+			}
+		}
+	}
+	
 	// Get phones from database
 	if m.db != nil {
 		dbPhones, err := GetVoIPPhones(m.db)
@@ -671,6 +689,15 @@ func (m *model) handleVoIPPhonesKeyPress(key string) {
 		case "A":
 			// Add all discovered phones
 			m.addAllDiscoveredPhones()
+		case "i":
+			// Phone inventory and line assignments
+			m.initPhoneInventory()
+		case "b":
+			// Bulk operations on many phones
+			m.initPhoneBulk()
+		case "F":
+			// Firmware repository, policy and staged upgrades
+			m.initFirmware()
 		case "d":
 			// Delete/remove selected phone
 			if len(m.voipPhones) > 0 {
@@ -749,7 +776,7 @@ func (m *model) handleVoIPPhonesKeyPress(key string) {
 			} else {
 				m.voipControlTab = len(voipControlTabNames) - 1
 			}
-			m.voipControlMenu = getVoIPControlMenuItems(m.voipControlTab)
+			m.voipControlMenu = m.voipControlMenuItems()
 			m.cursor = 0
 			m.voipPhoneOutput = ""
 			m.errorMsg = ""
@@ -768,7 +795,7 @@ func (m *model) handleVoIPPhonesKeyPress(key string) {
 			} else {
 				m.voipControlTab = 0
 			}
-			m.voipControlMenu = getVoIPControlMenuItems(m.voipControlTab)
+			m.voipControlMenu = m.voipControlMenuItems()
 			m.cursor = 0
 			m.voipPhoneOutput = ""
 			m.errorMsg = ""
@@ -821,6 +848,7 @@ func getVoIPControlMenuItems(tab int) []string {
 	case voipTabProvisioning:
 		return []string{
 			"🔧 Provision Extension",
+			"📥 Use Provisioning Server",
 			"📡 TR-069 Management",
 			"🔗 Webhook Configuration",
 			"🔧 Enable CTI Features",
@@ -870,6 +898,66 @@ func getVoIPControlMenuItems(tab int) []string {
 	}
 }
 
+// voipMenuCapabilities maps control menu items to the driver capability they need;
+// items not listed are shown for every phone
+var voipMenuCapabilities = map[string]PhoneCapability{
+	"📊 Get Phone Status":         CapStatus,
+	"📱 Get Phone State":          CapPhoneState,
+	"📊 Live Monitoring":          CapStatus,
+	"🧪 Test CTI/SNMP":            CapCTIFeatures,
+	"🔄 Reboot Phone":             CapReboot,
+	"🏭 Factory Reset":            CapFactoryReset,
+	"📋 Get Configuration":        CapGetConfig,
+	"⚙️ Set Configuration":       CapSetConfig,
+	"🔧 Provision Extension":      CapProvision,
+	"📥 Use Provisioning Server":  CapSetConfig,
+	"🔧 Enable CTI Features":      CapCTIFeatures,
+	"✅ Accept Call":              CapAnswer,
+	"❌ Reject Call":              CapReject,
+	"🔚 End Call":                 CapEndCall,
+	"⏸️  Hold Call":              CapHold,
+	"▶️  Resume Call":            CapHold,
+	"🔇 Mute/Unmute":              CapCTIFeatures,
+	"📲 Dial Number":              CapDial,
+	"🔢 Send DTMF":                CapDTMF,
+	"↗️  Blind Transfer":         CapBlindTransfer,
+	"👥 Attended Transfer":        CapCTIFeatures,
+	"🎙️ Conference Call":         CapCTIFeatures,
+	"🚫 Toggle DND":               CapDND,
+	"↗️  Call Forward":           CapCTIFeatures,
+	"📺 LCD Message":              CapCTIFeatures,
+	"📋 View Current Codec Order": CapCodecs,
+	"⬆️  Move Codec Up":          CapCodecs,
+	"⬇️  Move Codec Down":        CapCodecs,
+	"⭐ Apply Recommended Order":  CapCodecs,
+	"💾 Save Changes":             CapCodecs,
+	"↩️  Reset Changes":          CapCodecs,
+}
+
+// filterVoIPMenuItems keeps the menu items the driver supports
+func filterVoIPMenuItems(items []string, driver *PhoneDriver) []string {
+	if driver == nil {
+		return items
+	}
+	filtered := make([]string, 0, len(items))
+	for _, item := range items {
+		if capability, ok := voipMenuCapabilities[item]; ok && !driver.Supports(capability) {
+			continue
+		}
+		filtered = append(filtered, item)
+	}
+	return filtered
+}
+
+// voipControlMenuItems returns the current tab's menu items supported by the selected phone
+func (m *model) voipControlMenuItems() []string {
+	items := getVoIPControlMenuItems(m.voipControlTab)
+	if m.selectedPhoneIdx < len(m.voipPhones) {
+		return filterVoIPMenuItems(items, phoneDriverFor(m.voipPhones[m.selectedPhoneIdx]))
+	}
+	return items
+}
+
 // initVoIPControlMenu initializes the VoIP control menu
 func (m *model) initVoIPControlMenu() {
 	m.currentScreen = voipPhoneControlScreen
@@ -879,7 +967,7 @@ func (m *model) initVoIPControlMenu() {
 	m.errorMsg = ""
 	m.successMsg = ""
 	
-	m.voipControlMenu = getVoIPControlMenuItems(m.voipControlTab)
+	m.voipControlMenu = m.voipControlMenuItems()
 }
 
 // initManualIPInput initializes the phone edit screen for adding a new phone
@@ -960,21 +1048,13 @@ func (m *model) addAllDiscoveredPhones() {
 			}
 			m.voipPhones = append(m.voipPhones, phoneInfo)
 			
-			// Save to database
-			if m.db != nil {
-				dbPhone := &VoIPPhoneDB{
-					IP:            discovered.IP,
-					MAC:           discovered.MAC,
-					Vendor:        discovered.Vendor,
-					Model:         discovered.Model,
-					Status:        "discovered",
-					DiscoveryType: discovered.DiscoveryType,
-					UserAgent:     fmt.Sprintf("%s %s", discovered.Vendor, discovered.Model),
-				}
-				SaveVoIPPhone(m.db, dbPhone)
-			}
 			addedCount++
 		}
+		
+		// Save to the inventory, refreshing phones that are already known
+		if m.db != nil {
+			RecordPhoneSighting(m.db, discoverySighting(discovered))
+		}
 	}
 	
 	if addedCount > 0 {
@@ -1170,20 +1250,11 @@ func (m *model) mergeDiscoveredPhones(discovered []DiscoveredPhone) {
 			}
 			m.voipPhones = append(m.voipPhones, phoneInfo)
 			m.discoveredPhones = append(m.discoveredPhones, disc)
-			
-			// Save to database
-			if m.db != nil {
-				dbPhone := &VoIPPhoneDB{
-					IP:            disc.IP,
-					MAC:           disc.MAC,
-					Vendor:        disc.Vendor,
-					Model:         disc.Model,
-					Status:        "discovered",
-					DiscoveryType: disc.DiscoveryType,
-					UserAgent:     fmt.Sprintf("%s %s", disc.Vendor, disc.Model),
-				}
-				SaveVoIPPhone(m.db, dbPhone)
-			}
+		}
+		
+		// Save to the inventory, refreshing phones that are already known
+		if m.db != nil {
+			RecordPhoneSighting(m.db, discoverySighting(disc))
 		}
 	}
 	
@@ -1211,6 +1282,14 @@ func (m *model) initVoIPProvisionScreen() {
 	}
 }
 
+// phoneDriverFor returns the driver matching a phone's SIP user agent, defaulting to GrandStream
+func phoneDriverFor(phone PhoneInfo) *PhoneDriver {
+	if driver := PhoneDriverForUserAgent(phone.UserAgent); driver != nil && driver.Manageable() {
+		return driver
+	}
+	return LookupPhoneDriver(DefaultPhoneVendor)
+}
+
 // refreshPhoneStatus refreshes the current phone status
 func (m *model) refreshPhoneStatus() {
 	if m.selectedPhoneIdx >= len(m.voipPhones) {
@@ -1304,7 +1383,7 @@ func (m *model) executeVoIPControlAction() {
 		return
 	}
 	
-	vendor := "grandstream" // Default to GrandStream
+	vendor := phoneDriverFor(phone).Vendor
 	phoneInstance, err := m.phoneManager.CreatePhone(phone.IP, vendor, credentials)
 	if err != nil {
 		m.errorMsg = fmt.Sprintf("Failed to create phone instance: %v", err)
@@ -1354,12 +1433,7 @@ func (m *model) executeStatusTabAction(menuItem string, phone PhoneInfo, phoneIn
 		}
 		
 	case strings.Contains(menuItem, "Get Phone State"):
-		gsPhone, ok := phoneInstance.(*GrandStreamPhone)
-		if !ok {
-			m.errorMsg = "Phone state only available for GrandStream phones"
-			return
-		}
-		state, err := gsPhone.GetPhoneState()
+		state, err := phoneInstance.GetPhoneState()
 		if err != nil {
 			m.errorMsg = fmt.Sprintf("Failed to get phone state: %v", err)
 		} else {
@@ -1479,6 +1553,19 @@ func (m *model) executeProvisioningTabAction(menuItem string, phone PhoneInfo, p
 	case strings.Contains(menuItem, "Provision Extension"):
 		m.initVoIPProvisionScreen()
 		
+	case strings.Contains(menuItem, "Use Provisioning Server"):
+		settings := LoadProvisioningSettings()
+		note, err := PointPhoneAtProvisioning(phoneInstance, phoneDriverFor(phone).Vendor, settings)
+		if err != nil {
+			m.errorMsg = err.Error()
+			return
+		}
+		m.successMsg = "Provisioning server set to " + settings.URL()
+		m.voipPhoneOutput = "Zero-Touch Provisioning:\n\n"
+		m.voipPhoneOutput += fmt.Sprintf("Server: %s\n", settings.URL())
+		m.voipPhoneOutput += note + "\n\n"
+		m.voipPhoneOutput += "Assign extensions to the phone's MAC so the server has a config for it."
+		
 	case strings.Contains(menuItem, "TR-069"):
 		m.voipPhoneOutput = "TR-069 Management:\n\n"
 		m.voipPhoneOutput += "TR-069 (CWMP) provides advanced management:\n"
@@ -1610,18 +1697,13 @@ func (m *model) executeCTITabAction(menuItem string, phone PhoneInfo, phoneInsta
 		m.voipPhoneOutput += "action: 'conference'"
 		
 	case strings.Contains(menuItem, "Toggle DND"):
-		gsPhone, ok := phoneInstance.(*GrandStreamPhone)
-		if !ok {
-			m.errorMsg = "DND toggle only available for GrandStream phones"
-			return
-		}
-		state, err := gsPhone.GetPhoneState()
+		state, err := phoneInstance.GetPhoneState()
 		if err != nil {
 			m.errorMsg = fmt.Sprintf("Failed to get phone state: %v", err)
 			return
 		}
 		newDND := !state.DNDEnabled
-		err = gsPhone.SetDND(newDND)
+		err = phoneInstance.SetDND(newDND)
 		if err != nil {
 			m.errorMsg = fmt.Sprintf("Failed to toggle DND: %v", err)
 		} else {
@@ -1946,19 +2028,21 @@ func (m *model) executeManualIPAdd() {
 		}
 	}
 	
-	// Save to database if available
+	// Save to the inventory if available
 	if m.db != nil {
-		dbPhone := &VoIPPhoneDB{
-			IP:            ip,
-			Name:          name,
-			Extension:     extension,
-			Vendor:        vendorAndModel.Vendor,
-			Model:         vendorAndModel.Model,
-			Status:        "discovered",
-			DiscoveryType: "manual",
-			UserAgent:     vendorAndModel.UserAgent(),
-		}
-		if err := SaveVoIPPhone(m.db, dbPhone); err != nil {
+		id, err := RecordPhoneSighting(m.db, PhoneSighting{
+			IP:        ip,
+			Name:      name,
+			Extension: extension,
+			Vendor:    vendorAndModel.Vendor,
+			Model:     vendorAndModel.Model,
+			UserAgent: vendorAndModel.UserAgent(),
+			Source:    "manual",
+		})
+		if err == nil && password != "" {
+			err = SavePhoneCredentials(m.db, id, m.phoneCredentials[ip])
+		}
+		if err != nil {
 			// Non-fatal error, just log it
 			m.errorMsg = fmt.Sprintf("Phone saved but database update failed: %v", err)
 			m.inputMode = false
@@ -2014,7 +2098,7 @@ func (m *model) executeVoIPProvision() {
 		return
 	}
 	
-	vendor := "grandstream"
+	vendor := phoneDriverFor(phone).Vendor
 	phoneInstance, err := m.phoneManager.CreatePhone(phone.IP, vendor, credentials)
 	if err != nil {
 		m.errorMsg = fmt.Sprintf("Failed to create phone instance: %v", err)
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/gorilla/websocket"
+)
+
+// WebRTC defaults, matching the Asterisk WebRTC setup guide
+const (
+	webRTCTransportName    = "transport-wss"
+	DefaultHTTPTLSBindAddr = "0.0.0.0:8089"
+	defaultWebRTCCertFile  = "/etc/asterisk/keys/asterisk.crt"
+	defaultWebRTCKeyFile   = "/etc/asterisk/keys/asterisk.key"
+)
+
+// HTTPTLSSettings holds the [general] settings of http.conf that the WSS transport depends on
+type HTTPTLSSettings struct {
+	Enabled     bool   // enabled=yes
+	TLSEnabled  bool   // tlsenable=yes
+	TLSBindAddr string // tlsbindaddr, e.g. 0.0.0.0:8089
+	CertFile    string // tlscertfile
+	PrivateKey  string // tlsprivatekey
+}
+
+// isWebRTCTransport returns true for the transport used by WebRTC clients
+func isWebRTCTransport(transport string) bool {
+	return transport == webRTCTransportName
+}
+
+// TLSPort returns the port of the TLS listener, or 0 when it cannot be parsed
+func (s *HTTPTLSSettings) TLSPort() int {
+	_, port, err := net.SplitHostPort(s.TLSBindAddr)
+	if err != nil {
+		return 0
+	}
+	p, _ := strconv.Atoi(port)
+	return p
+}
+
+// CertificatePaths returns the certificate and key used for DTLS, falling back to the defaults
+func (s *HTTPTLSSettings) CertificatePaths() (string, string) {
+	certFile, keyFile := s.CertFile, s.PrivateKey
+	if certFile == "" {
+		certFile = defaultWebRTCCertFile
+	}
+	if keyFile == "" {
+		// Asterisk reads the key from the certificate file when tlsprivatekey is not set
+		keyFile = certFile
+		if s.CertFile == "" {
+			keyFile = defaultWebRTCKeyFile
+		}
+	}
+	return certFile, keyFile
+}
+
+// LoadHTTPConfig loads http.conf, creating an empty config if it is missing
+func (acm *AsteriskConfigManager) LoadHTTPConfig() (*AsteriskConfig, error) {
+	return acm.loadConfigOrNew(acm.httpConfigPath, []string{"; RayanPBX HTTP Configuration", "; Generated by RayanPBX TUI", ""})
+}
+
+// LoadHTTPTLSSettings reads the TLS settings of Asterisk's built-in HTTP server
+func (acm *AsteriskConfigManager) LoadHTTPTLSSettings() (*HTTPTLSSettings, error) {
+	settings := &HTTPTLSSettings{}
+	if _, err := os.Stat(acm.httpConfigPath); os.IsNotExist(err) {
+		return settings, nil
+	}
+
+	config, err := ParseAsteriskConfig(acm.httpConfigPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read http.conf: %v", err)
+	}
+
+	for _, section := range config.FindActiveSectionsByName("general") {
+		if v, ok := section.GetProperty("enabled"); ok {
+			settings.Enabled = isAsteriskTrue(v)
+		}
+		if v, ok := section.GetProperty("tlsenable"); ok {
+			settings.TLSEnabled = isAsteriskTrue(v)
+		}
+		if v, ok := section.GetProperty("tlsbindaddr"); ok {
+			settings.TLSBindAddr = v
+		}
+		if v, ok := section.GetProperty("tlscertfile"); ok {
+			settings.CertFile = v
+		}
+		if v, ok := section.GetProperty("tlsprivatekey"); ok {
+			settings.PrivateKey = v
+		}
+	}
+
+	return settings, nil
+}
+
+// isAsteriskTrue checks an Asterisk boolean option value
+func isAsteriskTrue(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "yes", "true", "y", "t", "1", "on":
+		return true
+	}
+	return false
+}
+
+// webRTCCertificate returns the DTLS certificate and key, taken from the http.conf TLS settings
+func (acm *AsteriskConfigManager) webRTCCertificate() (string, string) {
+	settings, err := acm.LoadHTTPTLSSettings()
+	if err != nil {
+		settings = &HTTPTLSSettings{}
+	}
+	return settings.CertificatePaths()
+}
+
+// EnsureWebRTCConfig enables TLS on Asterisk's HTTP server and adds the WSS transport.
+// Existing http.conf TLS settings are kept; missing ones get the default paths.
+func (acm *AsteriskConfigManager) EnsureWebRTCConfig() error {
+	green := color.New(color.FgGreen)
+	yellow := color.New(color.FgYellow)
+
+	changed := false
+
+	httpConfig, err := acm.LoadHTTPConfig()
+	if err != nil {
+		return err
+	}
+	var general *AsteriskSection
+	if sections := httpConfig.FindActiveSectionsByName("general"); len(sections) > 0 {
+		general = sections[0]
+	} else {
+		general = NewAsteriskSection("general", "")
+		httpConfig.Sections = append([]*AsteriskSection{general}, httpConfig.Sections...)
+	}
+
+	for _, key := range []string{"enabled", "tlsenable"} {
+		if v, ok := general.GetProperty(key); !ok || !isAsteriskTrue(v) {
+			general.SetProperty(key, "yes")
+			changed = true
+		}
+	}
+	if _, ok := general.GetProperty("tlsbindaddr"); !ok {
+		general.SetProperty("tlsbindaddr", DefaultHTTPTLSBindAddr)
+		changed = true
+	}
+	if _, ok := general.GetProperty("tlscertfile"); !ok {
+		// A configured certificate may be a combined PEM, so the key is only defaulted together with it
+		general.SetProperty("tlscertfile", defaultWebRTCCertFile)
+		if _, hasKey := general.GetProperty("tlsprivatekey"); !hasKey {
+			general.SetProperty("tlsprivatekey", defaultWebRTCKeyFile)
+		}
+		changed = true
+	}
+
+	if changed {
+		if err := httpConfig.Save(); err != nil {
+			return fmt.Errorf("failed to write http.conf: %v", err)
+		}
+	}
+
+	pjsipConfig, err := acm.LoadPjsipConfig()
+	if err != nil {
+		return err
+	}
+	if !pjsipConfig.HasSectionWithType(webRTCTransportName, "transport") {
+		// Keep transports together at the top of the file
+		idx := 0
+		for i, section := range pjsipConfig.Sections {
+			if section.Type == "transport" {
+				idx = i + 1
+			}
+		}
+		sections := make([]*AsteriskSection, 0, len(pjsipConfig.Sections)+1)
+		sections = append(sections, pjsipConfig.Sections[:idx]...)
+		sections = append(sections, CreateWSSTransportSection())
+		sections = append(sections, pjsipConfig.Sections[idx:]...)
+		pjsipConfig.Sections = sections
+
+		if err := pjsipConfig.Save(); err != nil {
+			return fmt.Errorf("failed to write config file: %v", err)
+		}
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	if acm.verbose {
+		green.Fprintln(acm.out, "✅ WebRTC (WSS) transport configured")
+	}
+
+	if err := acm.CommitConfigChange("webrtc-update", "Enabled WSS transport for WebRTC clients"); err != nil {
+		yellow.Fprintf(acm.out, "⚠️  Git commit warning: %v\n", err)
+	}
+
+	return nil
+}
+
+// CheckWebRTCCertificate verifies that the DTLS/TLS certificate and key load, match and are not expired.
+// It returns a short description of the certificate.
+func CheckWebRTCCertificate(certFile, keyFile string) (string, error) {
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to load certificate: %v", err)
+	}
+
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return "", fmt.Errorf("failed to parse certificate: %v", err)
+	}
+
+	now := time.Now()
+	if now.Before(leaf.NotBefore) {
+		return "", fmt.Errorf("certificate is not valid before %s", leaf.NotBefore.Format("2006-01-02"))
+	}
+	if now.After(leaf.NotAfter) {
+		return "", fmt.Errorf("certificate expired on %s", leaf.NotAfter.Format("2006-01-02"))
+	}
+
+	desc := fmt.Sprintf("%s, expires %s", leaf.Subject.CommonName, leaf.NotAfter.Format("2006-01-02"))
+	if names := append(leaf.DNSNames, ipStrings(leaf.IPAddresses)...); len(names) > 0 {
+		desc += fmt.Sprintf(" (%s)", strings.Join(names, ", "))
+	}
+	if leaf.NotAfter.Sub(now) < certExpiryWarningPeriod {
+		desc += " ⚠️ expires soon"
+	}
+	return desc, nil
+}
+
+// ipStrings converts IP addresses to strings
+func ipStrings(ips []net.IP) []string {
+	var result []string
+	for _, ip := range ips {
+		result = append(result, ip.String())
+	}
+	return result
+}
+
+// CheckWSSListener performs a SIP WebSocket handshake against Asterisk's /ws endpoint
+func CheckWSSListener(addr string, timeout time.Duration) error {
+	dialer := websocket.Dialer{
+		// Self-signed certificates are common for PBX deployments, the certificate is checked separately
+		TLSClientConfig:  &tls.Config{InsecureSkipVerify: true},
+		Subprotocols:     []string{"sip"},
+		HandshakeTimeout: timeout,
+	}
+
+	conn, resp, err := dialer.Dial(fmt.Sprintf("wss://%s/ws", addr), nil)
+	if err != nil {
+		if resp != nil {
+			return fmt.Errorf("WebSocket handshake failed: HTTP %d", resp.StatusCode)
+		}
+		return fmt.Errorf("WebSocket handshake failed: %v", err)
+	}
+	defer conn.Close()
+
+	if conn.Subprotocol() != "sip" {
+		return fmt.Errorf("server did not accept the sip WebSocket subprotocol")
+	}
+	return nil
+}
+
+// CheckWebRTC checks the http.conf TLS settings, the certificate and the WSS listener
+func (dm *DiagnosticsManager) CheckWebRTC(settings *HTTPTLSSettings) string {
+	var result strings.Builder
+	result.WriteString("🌐 WebRTC (WSS / DTLS-SRTP) Check\n")
+	result.WriteString("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n\n")
+
+	ok := true
+	if settings.Enabled && settings.TLSEnabled {
+		result.WriteString(fmt.Sprintf("✅ http.conf: TLS enabled on %s\n", settings.TLSBindAddr))
+	} else {
+		ok = false
+		result.WriteString("❌ http.conf: HTTP server or TLS is not enabled\n")
+		result.WriteString("   Set enabled=yes, tlsenable=yes and tlsbindaddr in /etc/asterisk/http.conf\n")
+	}
+
+	certFile, keyFile := settings.CertificatePaths()
+	if desc, err := CheckWebRTCCertificate(certFile, keyFile); err != nil {
+		ok = false
+		result.WriteString(fmt.Sprintf("❌ Certificate %s: %v\n", certFile, err))
+	} else {
+		result.WriteString(fmt.Sprintf("✅ Certificate: %s\n", desc))
+	}
+
+	port := settings.TLSPort()
+	if port == 0 {
+		ok = false
+		result.WriteString(fmt.Sprintf("❌ WSS listener: invalid tlsbindaddr %q\n", settings.TLSBindAddr))
+	} else {
+		addr := fmt.Sprintf("127.0.0.1:%d", port)
+		if err := CheckWSSListener(addr, 3*time.Second); err != nil {
+			ok = false
+			result.WriteString(fmt.Sprintf("❌ WSS listener %s: %v\n", addr, err))
+		} else {
+			result.WriteString(fmt.Sprintf("✅ WSS listener: wss://%s/ws accepts SIP WebSocket connections\n", addr))
+		}
+	}
+
+	result.WriteString("\n")
+	if ok {
+		result.WriteString("✅ WebRTC clients can connect to wss://<server>:" + strconv.Itoa(port) + "/ws\n")
+	} else {
+		result.WriteString("Troubleshooting:\n")
+		result.WriteString("  • Check the HTTP server: asterisk -rx 'http show status'\n")
+		result.WriteString("  • Check transports: asterisk -rx 'pjsip show transports'\n")
+		result.WriteString("  • Module res_http_websocket must be loaded\n")
+	}
+
+	return result.String()
+}
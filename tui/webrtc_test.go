@@ -0,0 +1,245 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// writeTestCertificate writes a self-signed certificate and key valid for the given period
+func writeTestCertificate(t *testing.T, dir string, notBefore, notAfter time.Time) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "pbx.example.com"},
+		DNSNames:     []string{"pbx.example.com"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(dir, "asterisk.crt")
+	keyFile := filepath.Join(dir, "asterisk.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+// TestGeneratePjsipEndpointWebRTC tests the options added for WSS extensions
+func TestGeneratePjsipEndpointWebRTC(t *testing.T) {
+	dir := t.TempDir()
+	acm := NewAsteriskConfigManager(false)
+	acm.httpConfigPath = filepath.Join(dir, "http.conf")
+	httpConf := "[general]\nenabled=yes\ntlsenable=yes\ntlsbindaddr=0.0.0.0:8089\ntlscertfile=/etc/asterisk/keys/pbx.pem\n"
+	if err := os.WriteFile(acm.httpConfigPath, []byte(httpConf), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ext := Extension{ExtensionNumber: "200", Secret: "pw", Context: "from-internal", Transport: webRTCTransportName,
+		Codecs: "opus,ulaw", DirectMedia: "no", MaxContacts: 3, QualifyFrequency: 30}
+	endpoint := acm.GeneratePjsipEndpoint(ext)[0]
+
+	expected := map[string]string{
+		"webrtc":           "yes",
+		"media_encryption": "dtls",
+		"ice_support":      "yes",
+		"rtcp_mux":         "yes",
+		"dtls_cert_file":   "/etc/asterisk/keys/pbx.pem",
+		"dtls_private_key": "/etc/asterisk/keys/pbx.pem",
+		"allow":            "opus,ulaw",
+		"transport":        webRTCTransportName,
+	}
+	for key, want := range expected {
+		if got, _ := endpoint.GetProperty(key); got != want {
+			t.Errorf("Expected %s=%s, got %q", key, want, got)
+		}
+	}
+
+	udp := ext
+	udp.Transport = "transport-udp"
+	if _, ok := acm.GeneratePjsipEndpoint(udp)[0].GetProperty("webrtc"); ok {
+		t.Error("UDP extensions must not get WebRTC options")
+	}
+}
+
+// TestWebRTCProfileTemplate tests that WebRTC options live in the profile template
+func TestWebRTCProfileTemplate(t *testing.T) {
+	acm := NewAsteriskConfigManager(false)
+	acm.httpConfigPath = filepath.Join(t.TempDir(), "http.conf")
+
+	var profile ExtensionProfile
+	for _, p := range DefaultExtensionProfiles() {
+		if p.Name == "softphone-webrtc" {
+			profile = p
+		}
+	}
+	if !isWebRTCTransport(profile.Transport) {
+		t.Fatalf("Expected softphone-webrtc to use %s, got %q", webRTCTransportName, profile.Transport)
+	}
+	acm.SetExtensionProfiles([]ExtensionProfile{profile})
+
+	template := acm.pjsipProfileTemplateSections(profile)[0]
+	if v, _ := template.GetProperty("dtls_cert_file"); v != defaultWebRTCCertFile {
+		t.Errorf("Expected default DTLS certificate in template, got %q", v)
+	}
+
+	ext := Extension{ExtensionNumber: "201", Secret: "pw", Context: profile.Context, Transport: profile.Transport,
+		Codecs: profile.Codecs, DirectMedia: profile.DirectMedia, MaxContacts: profile.MaxContacts,
+		QualifyFrequency: profile.QualifyFrequency, Profile: profile.Name}
+	endpoint := acm.GeneratePjsipEndpoint(ext)[0]
+	for _, key := range []string{"webrtc", "media_encryption", "dtls_cert_file", "allow"} {
+		if _, ok := endpoint.GetProperty(key); ok {
+			t.Errorf("Expected %s to be inherited, got:\n%s", key, endpoint.String())
+		}
+	}
+}
+
+// TestEnsureWebRTCConfig tests enabling TLS in http.conf and adding the WSS transport
+func TestEnsureWebRTCConfig(t *testing.T) {
+	dir := t.TempDir()
+	acm := NewAsteriskConfigManager(false)
+	acm.pjsipConfigPath = filepath.Join(dir, "pjsip.conf")
+	acm.httpConfigPath = filepath.Join(dir, "http.conf")
+
+	pjsip := "[transport-udp]\ntype=transport\nprotocol=udp\n\n[transport-tcp]\ntype=transport\nprotocol=tcp\n\n[100]\ntype=endpoint\n"
+	if err := os.WriteFile(acm.pjsipConfigPath, []byte(pjsip), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(acm.httpConfigPath, []byte("[general]\nenabled=no\nbindport=8088\ntlscertfile=/etc/ssl/pbx.pem\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := acm.EnsureWebRTCConfig(); err != nil {
+			t.Fatalf("EnsureWebRTCConfig failed: %v", err)
+		}
+	}
+
+	settings, err := acm.LoadHTTPTLSSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !settings.Enabled || !settings.TLSEnabled || settings.TLSPort() != 8089 {
+		t.Errorf("Unexpected http.conf settings: %+v", settings)
+	}
+	if certFile, keyFile := settings.CertificatePaths(); certFile != "/etc/ssl/pbx.pem" || keyFile != "/etc/ssl/pbx.pem" {
+		t.Errorf("Expected existing combined certificate to be kept, got %s and %s", certFile, keyFile)
+	}
+
+	config, err := ParseAsteriskConfig(acm.pjsipConfigPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, section := range config.Sections {
+		names = append(names, section.Name)
+	}
+	if got := strings.Join(names, ","); got != "transport-udp,transport-tcp,transport-wss,100" {
+		t.Errorf("Unexpected section order: %s", got)
+	}
+	if v, _ := config.FindSectionByNameAndType(webRTCTransportName, "transport").GetProperty("protocol"); v != "wss" {
+		t.Errorf("Expected protocol=wss, got %q", v)
+	}
+}
+
+// TestCheckWebRTCCertificate tests certificate validation
+func TestCheckWebRTCCertificate(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		notBefore time.Time
+		notAfter  time.Time
+		wantErr   bool
+		wantWarn  bool
+	}{
+		{"valid", now.Add(-time.Hour), now.Add(365 * 24 * time.Hour), false, false},
+		{"expiring soon", now.Add(-time.Hour), now.Add(7 * 24 * time.Hour), false, true},
+		{"expired", now.Add(-48 * time.Hour), now.Add(-24 * time.Hour), true, false},
+		{"not yet valid", now.Add(24 * time.Hour), now.Add(48 * time.Hour), true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			certFile, keyFile := writeTestCertificate(t, t.TempDir(), tt.notBefore, tt.notAfter)
+			desc, err := CheckWebRTCCertificate(certFile, keyFile)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckWebRTCCertificate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && strings.Contains(desc, "expires soon") != tt.wantWarn {
+				t.Errorf("Unexpected expiry warning in %q", desc)
+			}
+		})
+	}
+
+	if _, err := CheckWebRTCCertificate("/nonexistent.crt", "/nonexistent.key"); err == nil {
+		t.Error("Expected error for missing certificate")
+	}
+}
+
+// TestCheckWSSListener tests the SIP WebSocket handshake against a fake Asterisk HTTP server
+func TestCheckWSSListener(t *testing.T) {
+	upgrader := websocket.Upgrader{Subprotocols: []string{"sip"}}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ws" {
+			http.NotFound(w, r)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		conn.Close()
+	}))
+	defer server.Close()
+
+	addr := server.Listener.Addr().String()
+	if err := CheckWSSListener(addr, 2*time.Second); err != nil {
+		t.Errorf("Expected handshake to succeed, got %v", err)
+	}
+
+	plain := httptest.NewTLSServer(http.NotFoundHandler())
+	defer plain.Close()
+	if err := CheckWSSListener(plain.Listener.Addr().String(), 2*time.Second); err == nil {
+		t.Error("Expected handshake failure without a WebSocket endpoint")
+	}
+
+	// Nothing listening
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := ln.Addr().String()
+	ln.Close()
+	if err := CheckWSSListener(closed, time.Second); err == nil {
+		t.Error("Expected error for closed port")
+	}
+}
@@ -0,0 +1,430 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Yealink Action URI keys, sent as /servlet?key=<KEY>.
+// The phone must list the PBX in "Action URI Allow IP List" and accept remote control once.
+const (
+	YLKeyAccept        = "OK"
+	YLKeyReject        = "X"
+	YLKeyEndCall       = "CALLEND"
+	YLKeyHold          = "HOLD" // Toggles hold on the active call
+	YLKeyTransfer      = "F_TRANSFER"
+	YLKeyDNDOn         = "DNDOn"
+	YLKeyDNDOff        = "DNDOff"
+	YLKeyReboot        = "Reboot"
+	YLKeyFactoryReset  = "Reset"
+	YLKeyAutoProvision = "AutoP"
+	YLKeyDialPrefix    = "number=" // key=number=<digits> dials out
+)
+
+// Yealink web data endpoints
+const (
+	ylStatusPath       = "/servlet?m=mod_data&p=status-status&q=load"
+	ylCallStatePath    = "/servlet?m=mod_data&p=status-callstate&q=load"
+	ylConfigExportPath = "/servlet?m=mod_data&p=settings-config&q=export"
+	ylConfigImportPath = "/servlet?m=mod_data&p=settings-config&q=import"
+)
+
+// ylConfigHeader is the first line Yealink requires in .cfg files
+const ylConfigHeader = "#!version:1.0.0.1"
+
+// YealinkPhone implements VoIPPhone for Yealink T4x/T5x devices
+type YealinkPhone struct {
+	phoneWebClient
+}
+
+// NewYealinkPhone creates a new Yealink phone instance
+func NewYealinkPhone(ip string, credentials map[string]string, httpClient *http.Client) *YealinkPhone {
+	return &YealinkPhone{
+		phoneWebClient: newPhoneWebClient(ip, credentials, httpClient, "add this server to the phone's Action URI Allow IP List"),
+	}
+}
+
+// pressKey sends one Action URI key press
+func (yp *YealinkPhone) pressKey(key string) error {
+	_, err := yp.do("GET", "/servlet?key="+url.QueryEscape(key), "", nil)
+	return err
+}
+
+// dtmfKeys maps DTMF digits to Action URI keys
+func dtmfKeys(digits string) ([]string, error) {
+	keys := make([]string, 0, len(digits))
+	for _, d := range digits {
+		switch {
+		case d >= '0' && d <= '9':
+			keys = append(keys, string(d))
+		case d == '*':
+			keys = append(keys, "STAR")
+		case d == '#':
+			keys = append(keys, "POUND")
+		default:
+			return nil, fmt.Errorf("unsupported DTMF digit %q", d)
+		}
+	}
+	return keys, nil
+}
+
+// GetStatus retrieves the phone status
+func (yp *YealinkPhone) GetStatus() (*PhoneStatus, error) {
+	data, err := yp.do("GET", ylStatusPath, "", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status: %w", err)
+	}
+	var raw struct {
+		ModelName       string `json:"ModelName"`
+		FirmwareVersion string `json:"FirmwareVersion"`
+		MacAddress      string `json:"MacAddress"`
+		Uptime          string `json:"Uptime"`
+		IPv4            string `json:"IPv4Address"`
+		Accounts        []struct {
+			Index       int    `json:"Index"`
+			UserName    string `json:"UserName"`
+			Status      string `json:"Status"`
+			Server      string `json:"Server"`
+			DisplayName string `json:"DisplayName"`
+		} `json:"Accounts"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to decode status: %w", err)
+	}
+
+	status := &PhoneStatus{
+		IP:         yp.ip,
+		MAC:        raw.MacAddress,
+		Model:      raw.ModelName,
+		Firmware:   raw.FirmwareVersion,
+		Vendor:     "Yealink",
+		Uptime:     raw.Uptime,
+		LastUpdate: time.Now(),
+	}
+	if raw.IPv4 != "" {
+		status.NetworkInfo = &NetworkInfo{IP: raw.IPv4, MAC: raw.MacAddress}
+	}
+	for _, a := range raw.Accounts {
+		status.Accounts = append(status.Accounts, Account{
+			Number:      a.Index,
+			Extension:   a.UserName,
+			Status:      a.Status,
+			Server:      a.Server,
+			DisplayName: a.DisplayName,
+		})
+		if strings.EqualFold(a.Status, "Registered") {
+			status.Registered = true
+		}
+	}
+	return status, nil
+}
+
+// Reboot reboots the phone
+func (yp *YealinkPhone) Reboot() error {
+	if err := yp.pressKey(YLKeyReboot); err != nil {
+		return fmt.Errorf("failed to reboot phone: %w", err)
+	}
+	return nil
+}
+
+// AutoProvision makes the phone fetch its config from the auto-provisioning server now
+func (yp *YealinkPhone) AutoProvision() error {
+	if err := yp.pressKey(YLKeyAutoProvision); err != nil {
+		return fmt.Errorf("failed to trigger auto provisioning: %w", err)
+	}
+	return nil
+}
+
+// FactoryReset performs a factory reset
+func (yp *YealinkPhone) FactoryReset() error {
+	if err := yp.pressKey(YLKeyFactoryReset); err != nil {
+		return fmt.Errorf("failed to factory reset phone: %w", err)
+	}
+	return nil
+}
+
+// GetConfig retrieves the phone configuration by exporting its .cfg file
+func (yp *YealinkPhone) GetConfig() (map[string]interface{}, error) {
+	data, err := yp.do("GET", ylConfigExportPath, "", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get config: %w", err)
+	}
+	config := make(map[string]interface{})
+	for key, value := range ParseYealinkConfig(string(data)) {
+		config[key] = value
+	}
+	return config, nil
+}
+
+// SetConfig sets phone configuration parameters by importing a .cfg file
+func (yp *YealinkPhone) SetConfig(config map[string]interface{}) error {
+	values := make(map[string]string, len(config))
+	for key, value := range config {
+		values[key] = fmt.Sprint(value)
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", "rayanpbx.cfg")
+	if err != nil {
+		return fmt.Errorf("failed to build config upload: %w", err)
+	}
+	part.Write([]byte(RenderYealinkConfig(values)))
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to build config upload: %w", err)
+	}
+
+	if _, err := yp.do("POST", ylConfigImportPath, writer.FormDataContentType(), &body); err != nil {
+		return fmt.Errorf("failed to set config: %w", err)
+	}
+	return nil
+}
+
+// RenderYealinkConfig renders parameters as a Yealink .cfg file with sorted keys
+func RenderYealinkConfig(values map[string]string) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(ylConfigHeader + "\n")
+	for _, key := range keys {
+		b.WriteString(fmt.Sprintf("%s = %s\n", key, yealinkValue(values[key])))
+	}
+	return b.String()
+}
+
+// yealinkValue strips CR, LF and other control characters from a .cfg value, so a
+// display name or label cannot end its line and inject further parameters
+func yealinkValue(value string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, value)
+}
+
+// ParseYealinkConfig parses "key = value" lines of a Yealink .cfg file, skipping comments
+func ParseYealinkConfig(content string) map[string]string {
+	values := make(map[string]string)
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		idx := strings.Index(line, "=")
+		if idx <= 0 {
+			continue
+		}
+		values[strings.TrimSpace(line[:idx])] = strings.TrimSpace(line[idx+1:])
+	}
+	return values
+}
+
+// YealinkAccountConfig returns the auto-provisioning parameters of one SIP account
+func YealinkAccountConfig(ext Extension, accountNumber int, server string) map[string]string {
+	prefix := fmt.Sprintf("account.%d.", accountNumber)
+	config := map[string]string{
+		prefix + "enable":                      "1",
+		prefix + "label":                       ext.ExtensionNumber,
+		prefix + "display_name":                provisionDisplayName(ext),
+		prefix + "auth_name":                   ext.ExtensionNumber,
+		prefix + "user_name":                   ext.ExtensionNumber,
+		prefix + "password":                    ext.Secret,
+		prefix + "sip_server.1.address":        server,
+		prefix + "sip_server.1.port":           "5060",
+		prefix + "sip_server.1.transport_type": "0",
+	}
+	if provisionUsesTLS(ext) {
+		config[prefix+"sip_server.1.port"] = "5061"
+		config[prefix+"sip_server.1.transport_type"] = "2"
+	}
+	return config
+}
+
+// sipServerFor returns the address phones should register to: the sip_server
+// credential if set, otherwise the local address used to reach the phone
+func sipServerFor(phoneIP string, credentials map[string]string) string {
+	if server := credentials["sip_server"]; server != "" {
+		return server
+	}
+	host := strings.TrimPrefix(strings.TrimPrefix(phoneIP, "http://"), "https://")
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	// A UDP dial sends no packets, it only selects the outgoing interface
+	conn, err := net.Dial("udp", net.JoinHostPort(host, "5060"))
+	if err != nil {
+		return ""
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP.String()
+}
+
+// ProvisionExtension provisions an extension on the phone
+func (yp *YealinkPhone) ProvisionExtension(ext Extension, accountNumber int) error {
+	server := sipServerFor(yp.ip, yp.credentials)
+	if server == "" {
+		return fmt.Errorf("could not determine the SIP server address for %s", yp.ip)
+	}
+	config := make(map[string]interface{})
+	for key, value := range YealinkAccountConfig(ext, accountNumber, server) {
+		config[key] = value
+	}
+	return yp.SetConfig(config)
+}
+
+// CTI Operations - Yealink Action URI Implementation
+// Action URIs act on the phone's active call, so lineID is only used to check hold state.
+
+// AcceptCall answers an incoming call
+func (yp *YealinkPhone) AcceptCall(lineID int) error {
+	return yp.pressKey(YLKeyAccept)
+}
+
+// RejectCall rejects an incoming call
+func (yp *YealinkPhone) RejectCall(lineID int) error {
+	return yp.pressKey(YLKeyReject)
+}
+
+// EndCall terminates the current call
+func (yp *YealinkPhone) EndCall(lineID int) error {
+	return yp.pressKey(YLKeyEndCall)
+}
+
+// HoldCall places the call on hold
+func (yp *YealinkPhone) HoldCall(lineID int) error {
+	return yp.toggleHold(lineID, "connected")
+}
+
+// ResumeCall resumes a held call
+func (yp *YealinkPhone) ResumeCall(lineID int) error {
+	return yp.toggleHold(lineID, "hold")
+}
+
+// toggleHold presses the HOLD toggle only if the call is in the expected state
+func (yp *YealinkPhone) toggleHold(lineID int, want string) error {
+	state, err := yp.GetPhoneState()
+	if err != nil {
+		return err
+	}
+	for _, call := range state.Calls {
+		if lineID > 0 && call.LineID != lineID {
+			continue
+		}
+		if call.State == want {
+			return yp.pressKey(YLKeyHold)
+		}
+	}
+	if want == "hold" {
+		return fmt.Errorf("no held call to resume")
+	}
+	return fmt.Errorf("no connected call to hold")
+}
+
+// Dial initiates an outgoing call
+func (yp *YealinkPhone) Dial(number string, lineID int) error {
+	if _, err := dtmfKeys(number); err != nil || number == "" {
+		return fmt.Errorf("invalid number %q", number)
+	}
+	return yp.pressKey(YLKeyDialPrefix + number)
+}
+
+// SendDTMF sends DTMF tones
+func (yp *YealinkPhone) SendDTMF(digits string, lineID int) error {
+	keys, err := dtmfKeys(digits)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := yp.pressKey(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BlindTransfer performs blind transfer by pressing TRAN, dialing the target and pressing TRAN again
+func (yp *YealinkPhone) BlindTransfer(target string, lineID int) error {
+	keys, err := dtmfKeys(target)
+	if err != nil || target == "" {
+		return fmt.Errorf("invalid transfer target %q", target)
+	}
+	keys = append(append([]string{YLKeyTransfer}, keys...), YLKeyTransfer)
+	for _, key := range keys {
+		if err := yp.pressKey(key); err != nil {
+			return fmt.Errorf("failed to transfer: %w", err)
+		}
+	}
+	return nil
+}
+
+// SetDND enables/disables Do Not Disturb
+func (yp *YealinkPhone) SetDND(enable bool) error {
+	if enable {
+		return yp.pressKey(YLKeyDNDOn)
+	}
+	return yp.pressKey(YLKeyDNDOff)
+}
+
+// GetPhoneState returns current phone state including call info
+func (yp *YealinkPhone) GetPhoneState() (*CTIPhoneState, error) {
+	data, err := yp.do("GET", ylCallStatePath, "", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get phone state: %w", err)
+	}
+	var raw struct {
+		DND        string `json:"DND"`
+		Forward    string `json:"Forward"`
+		ForwardTo  string `json:"ForwardTarget"`
+		MWI        string `json:"MWI"`
+		ActiveLine string `json:"ActiveLine"`
+		Calls      []struct {
+			Line      int    `json:"Line"`
+			CallID    string `json:"CallID"`
+			State     string `json:"State"`
+			Direction string `json:"Direction"`
+			Number    string `json:"Number"`
+			Name      string `json:"Name"`
+			Duration  int    `json:"Duration"`
+			Muted     bool   `json:"Muted"`
+		} `json:"Calls"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to decode phone state: %w", err)
+	}
+
+	state := &CTIPhoneState{
+		DNDEnabled:     raw.DND == "1",
+		ForwardEnabled: raw.Forward == "1",
+		ForwardTarget:  raw.ForwardTo,
+		MWI:            raw.MWI == "1",
+	}
+	state.ActiveLine, _ = strconv.Atoi(raw.ActiveLine)
+	for _, c := range raw.Calls {
+		state.Calls = append(state.Calls, CTICallState{
+			LineID:       c.Line,
+			CallID:       c.CallID,
+			State:        strings.ToLower(c.State),
+			Direction:    strings.ToLower(c.Direction),
+			RemoteNumber: c.Number,
+			RemoteName:   c.Name,
+			Duration:     c.Duration,
+			Muted:        c.Muted,
+		})
+	}
+	return state, nil
+}
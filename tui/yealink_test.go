@@ -0,0 +1,218 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeYealink is a minimal Yealink web server recording Action URI key presses
+type fakeYealink struct {
+	mu        sync.Mutex
+	keys      []string
+	imported  string
+	callState string
+}
+
+func (f *fakeYealink) pressed() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.keys...)
+}
+
+func newFakeYealink(t *testing.T) (*fakeYealink, *YealinkPhone) {
+	t.Helper()
+	fake := &fakeYealink{callState: `{"DND":"0","Calls":[]}`}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "admin" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+
+		if key := r.URL.Query().Get("key"); key != "" {
+			fake.keys = append(fake.keys, key)
+			return
+		}
+		switch r.URL.Query().Get("p") + "/" + r.URL.Query().Get("q") {
+		case "status-status/load":
+			w.Write([]byte(`{"ModelName":"SIP-T46S","FirmwareVersion":"66.86.0.15","MacAddress":"80:5e:c0:11:22:33","Uptime":"1 day","IPv4Address":"192.168.1.50",
+				"Accounts":[{"Index":1,"UserName":"101","Status":"Registered","Server":"192.168.1.10"},{"Index":2,"UserName":"","Status":"Disabled"}]}`))
+		case "status-callstate/load":
+			w.Write([]byte(fake.callState))
+		case "settings-config/export":
+			w.Write([]byte("#!version:1.0.0.1\n## comment\naccount.1.user_name = 101\nlocal_time.time_zone = +3:30\n"))
+		case "settings-config/import":
+			file, _, err := r.FormFile("file")
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			data, _ := io.ReadAll(file)
+			fake.imported = string(data)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	phone := NewYealinkPhone(ts.URL[7:], map[string]string{"username": "admin", "password": "secret", "sip_server": "192.168.1.10"}, nil)
+	return fake, phone
+}
+
+func TestYealinkStatus(t *testing.T) {
+	_, phone := newFakeYealink(t)
+
+	status, err := phone.GetStatus()
+	if err != nil {
+		t.Fatalf("GetStatus failed: %v", err)
+	}
+	if status.Model != "SIP-T46S" || status.Firmware != "66.86.0.15" || status.Vendor != "Yealink" {
+		t.Errorf("unexpected status: %+v", status)
+	}
+	if !status.Registered || len(status.Accounts) != 2 || status.Accounts[0].Extension != "101" {
+		t.Errorf("unexpected accounts: %+v", status.Accounts)
+	}
+
+	bad := NewYealinkPhone(phone.ip, map[string]string{"username": "admin", "password": "wrong"}, nil)
+	if _, err := bad.GetStatus(); err == nil || !strings.Contains(err.Error(), "authentication failed") {
+		t.Errorf("expected authentication error, got %v", err)
+	}
+}
+
+func TestYealinkActionURI(t *testing.T) {
+	fake, phone := newFakeYealink(t)
+
+	steps := []struct {
+		name string
+		run  func() error
+		keys []string
+	}{
+		{"accept", func() error { return phone.AcceptCall(1) }, []string{"OK"}},
+		{"reject", func() error { return phone.RejectCall(1) }, []string{"X"}},
+		{"end", func() error { return phone.EndCall(1) }, []string{"CALLEND"}},
+		{"dial", func() error { return phone.Dial("102", 1) }, []string{"number=102"}},
+		{"dtmf", func() error { return phone.SendDTMF("1*#", 1) }, []string{"1", "STAR", "POUND"}},
+		{"transfer", func() error { return phone.BlindTransfer("103", 1) }, []string{"F_TRANSFER", "1", "0", "3", "F_TRANSFER"}},
+		{"dnd on", func() error { return phone.SetDND(true) }, []string{"DNDOn"}},
+		{"dnd off", func() error { return phone.SetDND(false) }, []string{"DNDOff"}},
+		{"reboot", phone.Reboot, []string{"Reboot"}},
+		{"reset", phone.FactoryReset, []string{"Reset"}},
+	}
+	for _, step := range steps {
+		before := len(fake.pressed())
+		if err := step.run(); err != nil {
+			t.Fatalf("%s failed: %v", step.name, err)
+		}
+		if got := fake.pressed()[before:]; !reflect.DeepEqual(got, step.keys) {
+			t.Errorf("%s: expected keys %v, got %v", step.name, step.keys, got)
+		}
+	}
+
+	if err := phone.SendDTMF("12A", 1); err == nil {
+		t.Error("expected error for unsupported DTMF digit")
+	}
+	if err := phone.Dial("", 1); err == nil {
+		t.Error("expected error for empty number")
+	}
+}
+
+func TestYealinkHoldAndState(t *testing.T) {
+	fake, phone := newFakeYealink(t)
+
+	if err := phone.HoldCall(1); err == nil {
+		t.Error("expected error holding without a call")
+	}
+
+	fake.mu.Lock()
+	fake.callState = `{"DND":"1","ActiveLine":"1","Calls":[{"Line":1,"CallID":"7","State":"Connected","Direction":"Outbound","Number":"102","Name":"Bob","Duration":42}]}`
+	fake.mu.Unlock()
+	state, err := phone.GetPhoneState()
+	if err != nil {
+		t.Fatalf("GetPhoneState failed: %v", err)
+	}
+	if !state.DNDEnabled || state.ActiveLine != 1 || len(state.Calls) != 1 {
+		t.Fatalf("unexpected state: %+v", state)
+	}
+	if call := state.Calls[0]; call.State != "connected" || call.RemoteNumber != "102" || call.Duration != 42 {
+		t.Errorf("unexpected call: %+v", call)
+	}
+
+	if err := phone.HoldCall(1); err != nil {
+		t.Fatalf("HoldCall failed: %v", err)
+	}
+	if err := phone.ResumeCall(1); err == nil {
+		t.Error("expected error resuming a call that is not held")
+	}
+	if keys := fake.pressed(); !reflect.DeepEqual(keys, []string{"HOLD"}) {
+		t.Errorf("expected a single HOLD press, got %v", keys)
+	}
+}
+
+func TestYealinkConfigAndProvision(t *testing.T) {
+	fake, phone := newFakeYealink(t)
+
+	config, err := phone.GetConfig()
+	if err != nil {
+		t.Fatalf("GetConfig failed: %v", err)
+	}
+	if config["account.1.user_name"] != "101" || config["local_time.time_zone"] != "+3:30" || len(config) != 2 {
+		t.Errorf("unexpected config: %v", config)
+	}
+
+	ext := Extension{ExtensionNumber: "105", Name: "Reception", Secret: "pa55"}
+	if err := phone.ProvisionExtension(ext, 2); err != nil {
+		t.Fatalf("ProvisionExtension failed: %v", err)
+	}
+	if !strings.HasPrefix(fake.imported, "#!version:1.0.0.1\n") {
+		t.Errorf("imported config missing header:\n%s", fake.imported)
+	}
+	imported := ParseYealinkConfig(fake.imported)
+	expected := map[string]string{
+		"account.2.enable":               "1",
+		"account.2.user_name":            "105",
+		"account.2.auth_name":            "105",
+		"account.2.password":             "pa55",
+		"account.2.display_name":         "Reception",
+		"account.2.sip_server.1.address": "192.168.1.10",
+		"account.2.sip_server.1.port":    "5060",
+	}
+	for key, value := range expected {
+		if imported[key] != value {
+			t.Errorf("%s: expected %q, got %q", key, value, imported[key])
+		}
+	}
+}
+
+// TestYealinkConfigControlCharacters tests that values cannot inject parameters into a .cfg file
+func TestYealinkConfigControlCharacters(t *testing.T) {
+	ext := Extension{ExtensionNumber: "105", Name: "Reception\r\nstatic.security.user_password = admin:owned\x00", Secret: "pa55", Enabled: true}
+	rendered := RenderYealinkConfig(YealinkAccountConfig(ext, 1, "192.168.1.10"))
+
+	server, err := NewProvisioningServer(ProvisioningSettings{SIPServer: "192.168.1.10"}, func(mac string) (*ProvisionedPhone, error) {
+		return &ProvisionedPhone{MAC: mac, Vendor: "yealink", Lines: []ProvisionedLine{{Line: 1, Extension: ext}}}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	provisioned, _, _, err := server.Render("805ec0112233.cfg", "")
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+
+	for name, content := range map[string]string{"RenderYealinkConfig": rendered, "provisioning template": string(provisioned)} {
+		config := ParseYealinkConfig(content)
+		if _, ok := config["static.security.user_password"]; ok {
+			t.Errorf("%s: display name injected a parameter:\n%s", name, content)
+		}
+		if got := config["account.1.display_name"]; got != "Receptionstatic.security.user_password = admin:owned" {
+			t.Errorf("%s: unexpected display name %q", name, got)
+		}
+	}
+}